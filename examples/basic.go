@@ -4,12 +4,20 @@ import (
 	"log"
 
 	"github.com/skjdfhkskjds/go-api/internal/engine"
+	"github.com/skjdfhkskjds/go-api/internal/middleware"
 	"github.com/skjdfhkskjds/go-api/internal/types"
 )
 
 func main() {
 	// Create engine with default config
-	e := engine.New(nil)
+	e, err := engine.New(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	e.Use(
+		middleware.Logger(middleware.LoggerConfig{SkipPaths: []string{"/health"}}),
+		middleware.Recovery(middleware.RecoveryConfig{}),
+	)
 
 	// Register routes
 	e.GET("/", homeHandler)
@@ -0,0 +1,54 @@
+// Package apictx passes typed values between the middlewares and handlers
+// of a request, stored with Context.Set so that both APIs coexist
+package apictx
+
+import (
+	"fmt"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Key is the key of values of type T, declared once and shared by the
+// middleware setting the value and the handlers getting it, e.g.
+//
+//	var UserKey = apictx.NewKey[*User]("user")
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a key, name being the key of Context.Set and Context.Get
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{name: name}
+}
+
+// Name returns the name of the key
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Set stores the value of the key in the context
+func Set[T any](c *types.Context, key Key[T], value T) {
+	c.Set(key.name, value)
+}
+
+// Get returns the value of the key, and false if it is missing or was
+// stored with Context.Set as another type
+func Get[T any](c *types.Context, key Key[T]) (T, bool) {
+	value, ok := c.Get(key.name)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	typed, ok := value.(T)
+	return typed, ok
+}
+
+// MustGet returns the value of the key, panicking if it is missing, e.g.
+// for values set by a middleware the route requires
+func MustGet[T any](c *types.Context, key Key[T]) T {
+	value, ok := Get(c, key)
+	if !ok {
+		panic(fmt.Sprintf("apictx: no %T value for key %q", value, key.name))
+	}
+	return value
+}
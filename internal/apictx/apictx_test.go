@@ -0,0 +1,40 @@
+package apictx
+
+import (
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID string
+}
+
+func TestKey(t *testing.T) {
+	userKey := NewKey[*user]("user")
+	c := &types.Context{}
+
+	_, ok := Get(c, userKey)
+	require.False(t, ok)
+	require.PanicsWithValue(t, `apictx: no *apictx.user value for key "user"`, func() { MustGet(c, userKey) })
+
+	Set(c, userKey, &user{ID: "42"})
+	u, ok := Get(c, userKey)
+	require.True(t, ok)
+	require.Equal(t, "42", u.ID)
+	require.Equal(t, "42", MustGet(c, userKey).ID)
+
+	// The value is shared with the plain map
+	value, ok := c.Get("user")
+	require.True(t, ok)
+	require.Same(t, u, value)
+
+	c.Set("user", "not a user")
+	_, ok = Get(c, userKey)
+	require.False(t, ok)
+
+	c.Delete("user")
+	_, ok = c.Get("user")
+	require.False(t, ok)
+}
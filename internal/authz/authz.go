@@ -0,0 +1,196 @@
+// Package authz authorizes the requests of the authenticated identities:
+// routes and groups declare the roles or permissions they require, and a
+// PolicyProvider decides whether the identity of the request has them
+package authz
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/skjdfhkskjds/go-api/internal/apictx"
+	"github.com/skjdfhkskjds/go-api/internal/jwt"
+	"github.com/skjdfhkskjds/go-api/internal/oauth"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// IdentityKey is the key of the identity of the request, set by the
+// authentication, see SetIdentity
+var IdentityKey = apictx.NewKey[*Identity]("authz.identity")
+
+// Identity is the authenticated user or client of a request
+type Identity struct {
+	Subject     string
+	Roles       []string
+	Permissions []string
+}
+
+// SetIdentity sets the identity of the request, for authentications other
+// than the jwt and oauth packages
+func SetIdentity(c *types.Context, identity *Identity) {
+	apictx.Set(c, IdentityKey, identity)
+}
+
+// FromContext returns the identity of the request: the one set with
+// SetIdentity, else that of the claims of the jwt middleware or of the
+// oauth session, whose "roles" and "permissions" claims, or "scope" for
+// the permissions, are arrays or strings separated by spaces
+func FromContext(c *types.Context) (*Identity, bool) {
+	if identity, ok := apictx.Get(c, IdentityKey); ok {
+		return identity, true
+	}
+	if claims, ok := jwt.FromContext(c); ok {
+		return FromClaims(claims), true
+	}
+	if session, ok := oauth.FromContext(c); ok {
+		return FromClaims(session.Claims), true
+	}
+	return nil, false
+}
+
+// FromClaims returns the identity of the claims of a token
+func FromClaims(claims jwt.Claims) *Identity {
+	permissions := claims.Strings("permissions")
+	if permissions == nil {
+		permissions = claims.Strings("scope")
+	}
+	return &Identity{Subject: claims.Subject(), Roles: claims.Strings("roles"), Permissions: permissions}
+}
+
+// Requirement is what a route requires of the identities
+type Requirement struct {
+	// Roles of which the identity must have one, if any
+	Roles []string
+
+	// Permissions the identity must all have
+	Permissions []string
+}
+
+// PolicyProvider decides whether identities meet requirements, e.g. from
+// a policy engine or a database of grants
+type PolicyProvider interface {
+	// Authorize reports whether the identity meets the requirement
+	//
+	// @return: the permissions it lacks, for the error of the response,
+	// and the error of the provider if it could not decide
+	Authorize(c *types.Context, identity *Identity, requirement Requirement) (bool, []string, error)
+}
+
+// RolePolicy is the default PolicyProvider, granting the permissions of
+// the identities and those of their roles
+type RolePolicy struct {
+	// RolePermissions are the permissions of the roles, by role
+	RolePermissions map[string][]string
+}
+
+// Authorize implements PolicyProvider
+func (p RolePolicy) Authorize(_ *types.Context, identity *Identity, requirement Requirement) (bool, []string, error) {
+	if len(requirement.Roles) > 0 && !slices.ContainsFunc(requirement.Roles, func(role string) bool {
+		return slices.Contains(identity.Roles, role)
+	}) {
+		return false, nil, nil
+	}
+
+	var missing []string
+	for _, permission := range requirement.Permissions {
+		if !p.granted(identity, permission) {
+			missing = append(missing, permission)
+		}
+	}
+	return len(missing) == 0, missing, nil
+}
+
+// granted reports whether the identity or one of its roles has the
+// permission
+func (p RolePolicy) granted(identity *Identity, permission string) bool {
+	if slices.Contains(identity.Permissions, permission) {
+		return true
+	}
+	for _, role := range identity.Roles {
+		if slices.Contains(p.RolePermissions[role], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Config configures an Authorizer
+type Config struct {
+	// Provider decides the access, RolePolicy without role permissions if
+	// nil
+	Provider PolicyProvider
+
+	// Identity returns the identity of the request, FromContext if nil
+	Identity func(c *types.Context) (*Identity, bool)
+}
+
+// Authorizer returns the middlewares enforcing the requirements of the
+// routes, e.g. authorizer.RequireRoles("admin") for an admin group
+type Authorizer struct {
+	config Config
+}
+
+// New creates an Authorizer, filling in defaults for unset fields
+func New(config Config) *Authorizer {
+	if config.Provider == nil {
+		config.Provider = RolePolicy{}
+	}
+	if config.Identity == nil {
+		config.Identity = FromContext
+	}
+	return &Authorizer{config: config}
+}
+
+// RequireRoles returns a middleware requiring one of the roles
+func (a *Authorizer) RequireRoles(roles ...string) types.MiddlewareFunc {
+	return a.Require(Requirement{Roles: roles})
+}
+
+// RequirePermissions returns a middleware requiring all the permissions
+func (a *Authorizer) RequirePermissions(permissions ...string) types.MiddlewareFunc {
+	return a.Require(Requirement{Permissions: permissions})
+}
+
+// Require returns a middleware requiring the requirement
+//
+// Requests without identity are rejected with 401 Unauthorized, those
+// whose identity does not meet the requirement with 403 Forbidden and
+// {"error", "message", "required_roles", "required_permissions",
+// "missing_permissions"}.
+func (a *Authorizer) Require(requirement Requirement) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			identity, ok := a.config.Identity(c)
+			if !ok || identity == nil {
+				c.Abort()
+				c.ErrorString(http.StatusUnauthorized, "authentication required")
+				return
+			}
+
+			allowed, missing, err := a.config.Provider.Authorize(c, identity, requirement)
+			if err != nil {
+				c.Abort()
+				c.Error(http.StatusServiceUnavailable, err)
+				return
+			}
+			if !allowed {
+				c.Abort()
+				body := map[string]any{
+					"error":   http.StatusText(http.StatusForbidden),
+					"message": "insufficient privileges",
+				}
+				if len(requirement.Roles) > 0 {
+					body["required_roles"] = requirement.Roles
+				}
+				if len(requirement.Permissions) > 0 {
+					body["required_permissions"] = requirement.Permissions
+				}
+				if len(missing) > 0 {
+					body["missing_permissions"] = missing
+				}
+				c.JSON(http.StatusForbidden, body)
+				return
+			}
+			next(c)
+		}
+	}
+}
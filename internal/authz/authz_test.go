@@ -0,0 +1,78 @@
+package authz
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/apictx"
+	"github.com/skjdfhkskjds/go-api/internal/jwt"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// serve handles a request of the identity through the middleware
+func serve(middleware types.MiddlewareFunc, identity *Identity) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/admin", nil), Writer: w}
+	if identity != nil {
+		SetIdentity(c, identity)
+	}
+	middleware(func(c *types.Context) { c.String(http.StatusOK, "ok") })(c)
+	return w
+}
+
+func TestAuthorizer(t *testing.T) {
+	authorizer := New(Config{Provider: RolePolicy{RolePermissions: map[string][]string{
+		"editor": {"posts:write"},
+	}}})
+	ada := &Identity{Subject: "ada", Roles: []string{"editor"}, Permissions: []string{"posts:delete"}}
+	bob := &Identity{Subject: "bob", Roles: []string{"viewer"}}
+
+	require.Equal(t, http.StatusUnauthorized, serve(authorizer.RequireRoles("admin"), nil).Code)
+
+	require.Equal(t, http.StatusOK, serve(authorizer.RequireRoles("admin", "editor"), ada).Code)
+	w := serve(authorizer.RequireRoles("admin", "editor"), bob)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.JSONEq(t, `{"error": "Forbidden", "message": "insufficient privileges", "required_roles": ["admin", "editor"]}`, w.Body.String())
+
+	// Permissions are granted directly or by the roles
+	require.Equal(t, http.StatusOK, serve(authorizer.RequirePermissions("posts:write", "posts:delete"), ada).Code)
+	w = serve(authorizer.RequirePermissions("posts:write", "posts:delete"), bob)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.JSONEq(t, `{"error": "Forbidden", "message": "insufficient privileges",
+		"required_permissions": ["posts:write", "posts:delete"],
+		"missing_permissions": ["posts:write", "posts:delete"]}`, w.Body.String())
+}
+
+// policyFunc adapts a function to a PolicyProvider
+type policyFunc func(identity *Identity) (bool, error)
+
+func (f policyFunc) Authorize(_ *types.Context, identity *Identity, _ Requirement) (bool, []string, error) {
+	allowed, err := f(identity)
+	return allowed, nil, err
+}
+
+func TestAuthorizer_Provider(t *testing.T) {
+	authorizer := New(Config{Provider: policyFunc(func(identity *Identity) (bool, error) {
+		if identity.Subject == "" {
+			return false, errors.New("policy engine unavailable")
+		}
+		return identity.Subject == "ada", nil
+	})})
+	require.Equal(t, http.StatusOK, serve(authorizer.RequireRoles("any"), &Identity{Subject: "ada"}).Code)
+	require.Equal(t, http.StatusForbidden, serve(authorizer.RequireRoles("any"), &Identity{Subject: "bob"}).Code)
+	require.Equal(t, http.StatusServiceUnavailable, serve(authorizer.RequireRoles("any"), &Identity{}).Code)
+}
+
+func TestFromContext(t *testing.T) {
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: httptest.NewRecorder()}
+	_, ok := FromContext(c)
+	require.False(t, ok)
+
+	apictx.Set(c, jwt.ClaimsKey, jwt.Claims{"sub": "ada", "roles": []any{"admin"}, "scope": "read write"})
+	identity, ok := FromContext(c)
+	require.True(t, ok)
+	require.Equal(t, &Identity{Subject: "ada", Roles: []string{"admin"}, Permissions: []string{"read", "write"}}, identity)
+}
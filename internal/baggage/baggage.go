@@ -0,0 +1,200 @@
+package baggage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Header is the W3C Baggage header name
+const Header = "Baggage"
+
+// Limits from the W3C Baggage specification
+const (
+	MaxMembers = 64
+	MaxBytes   = 8192
+)
+
+var (
+	// ErrInvalidKey is returned for keys that are not HTTP tokens
+	ErrInvalidKey = errors.New("baggage: invalid key")
+
+	// ErrInvalidMember is returned for malformed list members
+	ErrInvalidMember = errors.New("baggage: invalid member")
+
+	// ErrTooLarge is returned when the member or size limit is exceeded
+	ErrTooLarge = errors.New("baggage: too large")
+)
+
+// Member is a single baggage entry
+type Member struct {
+	Key   string
+	Value string
+
+	// Properties as found in the header, e.g. "ttl=60"
+	Properties []string
+}
+
+// Baggage is an immutable set of key value annotations propagated with
+// requests across services
+//
+// The zero value is an empty baggage.
+type Baggage struct {
+	members []Member
+}
+
+// Parse parses a baggage header value
+//
+// Empty list members are ignored. A malformed member invalidates the whole
+// header, as required by the specification.
+func Parse(header string) (Baggage, error) {
+	var b Baggage
+	if len(header) > MaxBytes {
+		return b, ErrTooLarge
+	}
+
+	for item := range strings.SplitSeq(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		fields := strings.Split(item, ";")
+		key, value, ok := strings.Cut(fields[0], "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isToken(key) {
+			return Baggage{}, fmt.Errorf("%w: %q", ErrInvalidMember, item)
+		}
+
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return Baggage{}, fmt.Errorf("%w: %q", ErrInvalidMember, item)
+		}
+
+		member := Member{Key: key, Value: value}
+		for _, property := range fields[1:] {
+			if property = strings.TrimSpace(property); property != "" {
+				member.Properties = append(member.Properties, property)
+			}
+		}
+		b.members = append(b.members, member)
+	}
+
+	if len(b.members) > MaxMembers {
+		return Baggage{}, ErrTooLarge
+	}
+	return b, nil
+}
+
+// Get returns the value of the member with the key
+func (b Baggage) Get(key string) (string, bool) {
+	for _, member := range b.members {
+		if member.Key == key {
+			return member.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set returns a copy of the baggage with the member set, replacing any
+// member with the same key
+func (b Baggage) Set(key, value string, properties ...string) (Baggage, error) {
+	if !isToken(key) {
+		return b, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+
+	members := slices.DeleteFunc(slices.Clone(b.members), func(m Member) bool {
+		return m.Key == key
+	})
+	if len(members) >= MaxMembers {
+		return b, ErrTooLarge
+	}
+	members = append(members, Member{Key: key, Value: value, Properties: properties})
+	return Baggage{members: members}, nil
+}
+
+// Delete returns a copy of the baggage without the member with the key
+func (b Baggage) Delete(key string) Baggage {
+	return Baggage{members: slices.DeleteFunc(slices.Clone(b.members), func(m Member) bool {
+		return m.Key == key
+	})}
+}
+
+// Members returns a copy of the members in order
+func (b Baggage) Members() []Member {
+	return slices.Clone(b.members)
+}
+
+// Len returns the number of members
+func (b Baggage) Len() int {
+	return len(b.members)
+}
+
+// String encodes the baggage as a header value
+func (b Baggage) String() string {
+	var sb strings.Builder
+	for i, member := range b.members {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(member.Key)
+		sb.WriteByte('=')
+		sb.WriteString(escape(member.Value))
+		for _, property := range member.Properties {
+			sb.WriteByte(';')
+			sb.WriteString(property)
+		}
+	}
+	return sb.String()
+}
+
+// escape percent-encodes the characters not allowed in baggage values
+func escape(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		// baggage-octet: printable ASCII except space, '"', ',', ';' and '\'
+		if c > 0x20 && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// isToken reports whether s is a non-empty HTTP token
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= 0x20 || c >= 0x7f || strings.IndexByte(`()<>@,;:\"/[]?={}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// contextKey is the context key of the baggage
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the baggage
+//
+// Background work started with a context derived from it keeps the
+// baggage, e.g. the tasks of types.Context.Go.
+func NewContext(ctx context.Context, b Baggage) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// FromContext returns the baggage carried by the context
+//
+// @return: the baggage, and whether the context carries any
+func FromContext(ctx context.Context) (Baggage, bool) {
+	b, ok := ctx.Value(contextKey{}).(Baggage)
+	return b, ok
+}
@@ -0,0 +1,82 @@
+package baggage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	b, err := Parse(" tenant = acme ,, note=hello%20world%2C;ttl=60 ; sampled")
+	require.NoError(t, err)
+	require.Equal(t, 2, b.Len())
+
+	tenant, ok := b.Get("tenant")
+	require.True(t, ok)
+	require.Equal(t, "acme", tenant)
+
+	members := b.Members()
+	require.Equal(t, Member{Key: "note", Value: "hello world,", Properties: []string{"ttl=60", "sampled"}}, members[1])
+
+	_, err = Parse("tenant=acme,novalue")
+	require.ErrorIs(t, err, ErrInvalidMember)
+	_, err = Parse("key=%zz")
+	require.ErrorIs(t, err, ErrInvalidMember)
+	_, err = Parse("bad key=1")
+	require.ErrorIs(t, err, ErrInvalidMember)
+	_, err = Parse(strings.Repeat("k=v,", MaxMembers+1))
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestBaggage_SetDelete(t *testing.T) {
+	var b Baggage
+	b, err := b.Set("tenant", "acme")
+	require.NoError(t, err)
+	b2, err := b.Set("user", "jane doe;admin", "ttl=5")
+	require.NoError(t, err)
+	require.Equal(t, "tenant=acme,user=jane%20doe%3Badmin;ttl=5", b2.String())
+
+	// Baggage is immutable
+	require.Equal(t, 1, b.Len())
+
+	b2, err = b2.Set("tenant", "globex")
+	require.NoError(t, err)
+	require.Equal(t, "user=jane%20doe%3Badmin;ttl=5,tenant=globex", b2.String())
+	require.Equal(t, "tenant=globex", b2.Delete("user").String())
+
+	_, err = b.Set("bad key", "x")
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	parsed, err := Parse(b2.String())
+	require.NoError(t, err)
+	require.Equal(t, b2, parsed)
+}
+
+func TestTransport(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(Header)
+	}))
+	defer server.Close()
+
+	b, err := Baggage{}.Set("tenant", "acme")
+	require.NoError(t, err)
+	ctx := NewContext(context.Background(), b)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := NewClient().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "tenant=acme", received)
+	require.Empty(t, req.Header.Get(Header))
+
+	// Detached background work keeps the baggage
+	detached, ok := FromContext(context.WithoutCancel(ctx))
+	require.True(t, ok)
+	require.Equal(t, b, detached)
+}
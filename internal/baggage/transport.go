@@ -0,0 +1,33 @@
+package baggage
+
+import "net/http"
+
+// Transport is an http.RoundTripper adding the baggage carried by the
+// request context to outbound requests
+//
+// A Baggage header already set on the request is kept.
+type Transport struct {
+	// Base performs the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if b, ok := FromContext(r.Context()); ok && b.Len() > 0 && r.Header.Get(Header) == "" {
+		// RoundTrippers must not modify the caller's request
+		r = r.Clone(r.Context())
+		r.Header.Set(Header, b.String())
+	}
+	return base.RoundTrip(r)
+}
+
+// NewClient returns an HTTP client propagating baggage from the request
+// context, e.g. client.Do(req.WithContext(c.Request.Context()))
+func NewClient() *http.Client {
+	return &http.Client{Transport: &Transport{}}
+}
@@ -0,0 +1,177 @@
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+const (
+	DefaultCookieName = "__challenge_pass"
+	DefaultVerifyPath = "/.challenge/verify"
+	DefaultPassTTL    = time.Hour
+)
+
+// Provider serves a challenge and verifies its solutions
+//
+// Implementations may be a captcha vendor integration rendering a widget
+// and calling the vendor's verification API, or a self-contained scheme
+// such as ProofOfWork.
+type Provider interface {
+	// Serve responds with a challenge whose solution is submitted to
+	// verifyPath together with the returnTo form field
+	Serve(c *types.Context, verifyPath, returnTo string)
+
+	// Verify reports whether the request carries a valid solution
+	Verify(c *types.Context) (bool, error)
+}
+
+// Config configures a Challenger
+type Config struct {
+	// Provider serving and verifying the challenge
+	Provider Provider
+
+	// Key signing the pass cookie, a random key is generated when empty,
+	// which invalidates passes on restart and across instances
+	Secret []byte
+
+	// Name of the pass cookie, defaults to DefaultCookieName
+	CookieName string
+
+	// Path the solutions are submitted to, defaults to DefaultVerifyPath
+	VerifyPath string
+
+	// Lifetime of a pass, defaults to DefaultPassTTL
+	TTL time.Duration
+
+	// Whether the pass cookie is only sent over HTTPS
+	Secure bool
+
+	// Optional client binding, e.g. the User-Agent, signed into the pass so
+	// that it cannot be replayed by a different client
+	Bind func(c *types.Context) string
+}
+
+// Challenger issues challenges and the signed passes proving they were solved
+type Challenger struct {
+	config Config
+}
+
+// New creates a Challenger, filling in defaults for unset fields
+func New(config Config) *Challenger {
+	if config.CookieName == "" {
+		config.CookieName = DefaultCookieName
+	}
+	if config.VerifyPath == "" {
+		config.VerifyPath = DefaultVerifyPath
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultPassTTL
+	}
+	if len(config.Secret) == 0 {
+		config.Secret = make([]byte, 32)
+		rand.Read(config.Secret)
+	}
+	return &Challenger{config: config}
+}
+
+// VerifyPath returns the path the VerifyHandler must be registered on
+func (ch *Challenger) VerifyPath() string {
+	return ch.config.VerifyPath
+}
+
+// Passed reports whether the request carries a valid, unexpired pass
+func (ch *Challenger) Passed(c *types.Context) bool {
+	value, err := c.GetCookie(ch.config.CookieName)
+	if err != nil {
+		return false
+	}
+
+	expiry, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(ch.sign(c, expiry)))
+}
+
+// Challenge serves the provider's challenge and aborts the chain
+//
+// Rate limiting or bot detection middleware call this when a client should
+// prove it is not automated before being let through.
+func (ch *Challenger) Challenge(c *types.Context) {
+	c.Abort()
+	ch.config.Provider.Serve(c, ch.config.VerifyPath, c.Request.URL.RequestURI())
+}
+
+// Middleware returns a middleware challenging requests for which trigger
+// returns true, unless they already carry a valid pass
+func (ch *Challenger) Middleware(trigger func(c *types.Context) bool) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if trigger(c) && !ch.Passed(c) {
+				ch.Challenge(c)
+				return
+			}
+			next(c)
+		}
+	}
+}
+
+// VerifyHandler returns the handler receiving challenge solutions
+//
+// A valid solution sets the pass cookie and redirects back to the page
+// that was challenged, an invalid one serves a fresh challenge.
+func (ch *Challenger) VerifyHandler() types.HandlerFunc {
+	return func(c *types.Context) {
+		returnTo := types.LocalPath(c.Request.FormValue("return_to"))
+
+		ok, err := ch.config.Provider.Verify(c)
+		if err != nil {
+			c.Error(http.StatusBadGateway, err)
+			return
+		}
+		if !ok {
+			ch.config.Provider.Serve(c, ch.config.VerifyPath, returnTo)
+			return
+		}
+
+		ch.SetPass(c)
+		c.Redirect(http.StatusSeeOther, returnTo)
+	}
+}
+
+// SetPass sets a signed pass cookie on the response
+func (ch *Challenger) SetPass(c *types.Context) {
+	expiry := strconv.FormatInt(time.Now().Add(ch.config.TTL).Unix(), 10)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     ch.config.CookieName,
+		Value:    expiry + "." + ch.sign(c, expiry),
+		Path:     "/",
+		MaxAge:   int(ch.config.TTL.Seconds()),
+		Secure:   ch.config.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sign returns the pass signature for an expiry and the client binding
+func (ch *Challenger) sign(c *types.Context, expiry string) string {
+	mac := hmac.New(sha256.New, ch.config.Secret)
+	mac.Write([]byte(expiry))
+	if ch.config.Bind != nil {
+		mac.Write([]byte{0})
+		mac.Write([]byte(ch.config.Bind(c)))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
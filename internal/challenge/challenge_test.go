@@ -0,0 +1,173 @@
+package challenge
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/replay"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// run serves a request through the middleware and reports whether the
+// downstream handler was reached
+func run(middleware types.MiddlewareFunc, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	w := httptest.NewRecorder()
+	reached := false
+	c := &types.Context{Request: r, Writer: w}
+	c.Execute(types.Chain([]types.MiddlewareFunc{middleware}, func(c *types.Context) {
+		reached = true
+		c.String(http.StatusOK, "ok")
+	}))
+	return w, reached
+}
+
+// verify submits a solution form to the verify handler
+func verify(challenger *Challenger, form url.Values, userAgent string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, DefaultVerifyPath, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("User-Agent", userAgent)
+
+	w := httptest.NewRecorder()
+	challenger.VerifyHandler()(&types.Context{Request: r, Writer: w})
+	return w
+}
+
+// solve brute forces a proof of work challenge
+func solve(challenge string, difficulty int) string {
+	for n := 0; ; n++ {
+		solution := strconv.Itoa(n)
+		hash := sha256.Sum256([]byte(challenge + ":" + solution))
+		if leadingZeroBits(hash[:]) >= difficulty {
+			return solution
+		}
+	}
+}
+
+func TestChallenger_ProofOfWorkFlow(t *testing.T) {
+	challenger := New(Config{
+		Provider: NewProofOfWork(ProofOfWorkConfig{Difficulty: 8}),
+		Bind:     func(c *types.Context) string { return c.GetUserAgent() },
+	})
+	always := func(*types.Context) bool { return true }
+	protected := challenger.Middleware(always)
+
+	// Unverified clients receive a challenge
+	r := httptest.NewRequest(http.MethodGet, "/search?q=1", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	w, reached := run(protected, r)
+	require.False(t, reached)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	var served powChallenge
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	require.Equal(t, DefaultVerifyPath, served.VerifyPath)
+	require.Equal(t, "/search?q=1", served.ReturnTo)
+
+	// A wrong solution is answered with a new challenge
+	form := url.Values{"challenge": {served.Challenge}, "solution": {"wrong"}, "return_to": {served.ReturnTo}}
+	w = verify(challenger, form, "test-agent")
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	// A valid solution sets the pass and redirects back
+	form.Set("solution", solve(served.Challenge, served.Difficulty))
+	w = verify(challenger, form, "test-agent")
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, "/search?q=1", w.Header().Get("Location"))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, DefaultCookieName, cookies[0].Name)
+	require.True(t, cookies[0].HttpOnly)
+
+	// The pass lets the client through
+	r = httptest.NewRequest(http.MethodGet, "/search?q=1", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	r.AddCookie(cookies[0])
+	_, reached = run(protected, r)
+	require.True(t, reached)
+
+	// The pass is bound to the client
+	r = httptest.NewRequest(http.MethodGet, "/search?q=1", nil)
+	r.Header.Set("User-Agent", "other-agent")
+	r.AddCookie(cookies[0])
+	_, reached = run(protected, r)
+	require.False(t, reached)
+
+	// A tampered pass is refused
+	r = httptest.NewRequest(http.MethodGet, "/search?q=1", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "99999999999." + strings.Split(cookies[0].Value, ".")[1]})
+	_, reached = run(protected, r)
+	require.False(t, reached)
+}
+
+func TestProofOfWork_ServeHTML(t *testing.T) {
+	provider := NewProofOfWork(ProofOfWorkConfig{})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	w := httptest.NewRecorder()
+	provider.Serve(&types.Context{Request: r, Writer: w}, "/verify", "/page")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), `action="/verify"`)
+	require.Contains(t, w.Body.String(), `const difficulty =  16 ;`)
+}
+
+func TestProofOfWork_ForeignChallenge(t *testing.T) {
+	issuer := NewProofOfWork(ProofOfWorkConfig{Difficulty: 1})
+	verifier := NewProofOfWork(ProofOfWorkConfig{Difficulty: 1})
+
+	challenge := issuer.issue()
+	form := url.Values{"challenge": {challenge}, "solution": {solve(challenge, 1)}}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ok, err := verifier.Verify(&types.Context{Request: r})
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestChallenger_ReturnTo(t *testing.T) {
+	provider := NewProofOfWork(ProofOfWorkConfig{Difficulty: 1})
+	challenger := New(Config{Provider: provider})
+
+	// The solved challenge only redirects to local paths
+	for returnTo, location := range map[string]string{
+		"/a?b=c":               "/a?b=c",
+		"":                     "/",
+		"https://evil.example": "/",
+		"//evil.example":       "/",
+		"/\\evil.example":      "/",
+		"/\t/evil.example":     "/",
+		"/\n/evil.example":     "/",
+	} {
+		challenge := provider.issue()
+		form := url.Values{"challenge": {challenge}, "solution": {solve(challenge, 1)}, "return_to": {returnTo}}
+		w := verify(challenger, form, "test-agent")
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		require.Equal(t, location, w.Header().Get("Location"), returnTo)
+	}
+}
+
+func TestProofOfWork_Replay(t *testing.T) {
+	provider := NewProofOfWork(ProofOfWorkConfig{Difficulty: 1, Replay: replay.New(replay.Config{})})
+	challenge := provider.issue()
+	form := url.Values{"challenge": {challenge}, "solution": {solve(challenge, 1)}}
+
+	// A solved challenge is accepted once
+	for _, expected := range []bool{true, false} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ok, err := provider.Verify(&types.Context{Request: r})
+		require.NoError(t, err)
+		require.Equal(t, expected, ok)
+	}
+}
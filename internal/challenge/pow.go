@@ -0,0 +1,215 @@
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/replay"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+const (
+	DefaultDifficulty   = 16
+	DefaultChallengeTTL = 2 * time.Minute
+)
+
+// ProofOfWorkConfig configures the ProofOfWork provider
+type ProofOfWorkConfig struct {
+	// Key signing issued challenges, a random key is generated when empty
+	Secret []byte
+
+	// Number of leading zero bits required in the solution hash
+	Difficulty int
+
+	// Time a client has to solve an issued challenge
+	TTL time.Duration
+
+	// Status code of challenge responses, defaults to 403 Forbidden
+	Status int
+
+	// Replay accepts every solved challenge once, may be nil. Its window
+	// must not be shorter than the TTL.
+	Replay *replay.Guard
+}
+
+// ProofOfWork is a self-contained Provider asking clients to find a
+// solution such that SHA-256("<challenge>:<solution>") starts with the
+// configured number of zero bits
+//
+// Challenges are stateless and signed, so a solved challenge can be
+// replayed until it expires, which the short TTL bounds, unless a replay
+// guard is configured. Browsers are
+// served a page solving the challenge in JavaScript, other clients a JSON
+// description of it.
+type ProofOfWork struct {
+	config ProofOfWorkConfig
+}
+
+// NewProofOfWork creates a ProofOfWork provider, filling in defaults for
+// unset fields
+func NewProofOfWork(config ProofOfWorkConfig) *ProofOfWork {
+	if len(config.Secret) == 0 {
+		config.Secret = make([]byte, 32)
+		rand.Read(config.Secret)
+	}
+	if config.Difficulty <= 0 {
+		config.Difficulty = DefaultDifficulty
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultChallengeTTL
+	}
+	if config.Status == 0 {
+		config.Status = http.StatusForbidden
+	}
+	return &ProofOfWork{config: config}
+}
+
+// powChallenge is the description of a challenge served to clients
+type powChallenge struct {
+	Challenge  string `json:"challenge"`
+	Difficulty int    `json:"difficulty"`
+	VerifyPath string `json:"verify_path"`
+	ReturnTo   string `json:"return_to"`
+}
+
+// Serve implements Provider
+func (p *ProofOfWork) Serve(c *types.Context, verifyPath, returnTo string) {
+	challenge := powChallenge{
+		Challenge:  p.issue(),
+		Difficulty: p.config.Difficulty,
+		VerifyPath: verifyPath,
+		ReturnTo:   returnTo,
+	}
+
+	if !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.JSON(p.config.Status, challenge)
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(p.config.Status)
+	powTemplate.Execute(c.Writer, challenge)
+}
+
+// Verify implements Provider
+func (p *ProofOfWork) Verify(c *types.Context) (bool, error) {
+	challenge := c.Request.FormValue("challenge")
+	solution := c.Request.FormValue("solution")
+	if challenge == "" || solution == "" || !p.valid(challenge) {
+		return false, nil
+	}
+
+	hash := sha256.Sum256([]byte(challenge + ":" + solution))
+	if leadingZeroBits(hash[:]) < p.config.Difficulty {
+		return false, nil
+	}
+
+	if p.config.Replay != nil {
+		nonce, expiry, _ := strings.Cut(challenge, ".")
+		expiry, _, _ = strings.Cut(expiry, ".")
+		unix, _ := strconv.ParseInt(expiry, 10, 64)
+		issued := time.Unix(unix, 0).Add(-p.config.TTL)
+		switch err := p.config.Replay.Check(c.Request.Context(), "pow:"+nonce, issued); {
+		case errors.Is(err, replay.ErrReplayed) || errors.Is(err, replay.ErrOutsideWindow):
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// issue returns a new signed challenge: nonce.expiry.signature
+func (p *ProofOfWork) issue() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+
+	payload := base64.RawURLEncoding.EncodeToString(nonce) + "." +
+		strconv.FormatInt(time.Now().Add(p.config.TTL).Unix(), 10)
+	return payload + "." + p.sign(payload)
+}
+
+// valid reports whether a challenge was issued by this provider and has
+// not expired
+func (p *ProofOfWork) valid(challenge string) bool {
+	idx := strings.LastIndexByte(challenge, '.')
+	if idx < 0 {
+		return false
+	}
+	payload, signature := challenge[:idx], challenge[idx+1:]
+	if !hmac.Equal([]byte(signature), []byte(p.sign(payload))) {
+		return false
+	}
+
+	_, expiry, _ := strings.Cut(payload, ".")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	return err == nil && time.Now().Unix() <= unix
+}
+
+// sign returns the signature of a challenge payload
+func (p *ProofOfWork) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.config.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// leadingZeroBits counts the leading zero bits of a hash
+func leadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
+
+var powTemplate = template.Must(template.New("pow").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Checking your browser</title></head>
+<body>
+<p>Checking your browser, this will only take a moment.</p>
+<form id="challenge" method="POST" action="{{.VerifyPath}}">
+<input type="hidden" name="challenge" value="{{.Challenge}}">
+<input type="hidden" name="solution" value="">
+<input type="hidden" name="return_to" value="{{.ReturnTo}}">
+<noscript><p>Please enable JavaScript to continue.</p></noscript>
+</form>
+<script>
+(async function () {
+  const form = document.getElementById("challenge");
+  const encoder = new TextEncoder();
+  const difficulty = {{.Difficulty}};
+  const zeroBits = (bytes) => {
+    let count = 0;
+    for (const b of bytes) {
+      if (b !== 0) return count + Math.clz32(b) - 24;
+      count += 8;
+    }
+    return count;
+  };
+  for (let n = 0; ; n++) {
+    const input = encoder.encode(form.challenge.value + ":" + n);
+    const digest = new Uint8Array(await crypto.subtle.digest("SHA-256", input));
+    if (zeroBits(digest) >= difficulty) {
+      form.solution.value = n;
+      form.submit();
+      return;
+    }
+  }
+})();
+</script>
+</body>
+</html>
+`))
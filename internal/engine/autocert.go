@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"cmp"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/skjdfhkskjds/go-api/internal/wellknown"
+)
+
+// acmeChallengePrefix is the path of the HTTP-01 challenges, see RFC 8555
+const acmeChallengePrefix = wellknown.Prefix + "acme-challenge/"
+
+// DefaultAutoCertHTTPAddress is the address of the plain HTTP server when
+// AutoCertConfig.HTTPAddress is not set, ACME CAs only connect to port 80
+const DefaultAutoCertHTTPAddress = ":80"
+
+// newCertManager creates the certificate manager of the configuration, nil
+// when autocert is disabled
+func newCertManager(config AutoCertConfig) *autocert.Manager {
+	if len(config.Domains) == 0 {
+		return nil
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.Domains...),
+		Email:      config.Email,
+	}
+	if config.CacheDir != "" {
+		manager.Cache = autocert.DirCache(config.CacheDir)
+	}
+	if config.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: config.DirectoryURL}
+	}
+	return manager
+}
+
+// CertManager returns the manager of the certificates obtained from the
+// ACME CA, nil when autocert is disabled
+func (e *Engine) CertManager() *autocert.Manager {
+	return e.certManager
+}
+
+// acmeChallenge registers the well-known route answering the HTTP-01
+// challenges of the certificate manager, which skips the engine middleware
+// so that certificate renewals cannot be blocked
+func (e *Engine) acmeChallenge() {
+	handler := e.certManager.HTTPHandler(http.NotFoundHandler())
+	e.register(e.wellKnown, http.MethodGet, acmeChallengePrefix+"*token", func(c *types.Context) {
+		handler.ServeHTTP(c.Writer, c.Request)
+	})
+}
+
+// challengeServer returns the plain HTTP server of autocert, answering the
+// challenges through the route tree and redirecting other requests to
+// HTTPS
+func (e *Engine) challengeServer() *http.Server {
+	return &http.Server{
+		Addr: cmp.Or(e.config.TLS.AutoCert.HTTPAddress, DefaultAutoCertHTTPAddress),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, acmeChallengePrefix) {
+				e.ServeHTTP(w, r)
+				return
+			}
+
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusFound)
+		}),
+		ReadTimeout:  time.Duration(e.config.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(e.config.Server.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(e.config.Server.IdleTimeout) * time.Second,
+	}
+}
@@ -1,13 +1,59 @@
 package engine
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/skjdfhkskjds/go-api/internal/idgen"
+	"github.com/skjdfhkskjds/go-api/internal/middleware"
+	"github.com/skjdfhkskjds/go-api/internal/routes"
+	"github.com/skjdfhkskjds/go-api/internal/static"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/skjdfhkskjds/go-api/internal/wellknown"
 )
 
 // Config represents the minimal application configuration
 type Config struct {
-	Server ServerConfig `yaml:"server"`
+	// Deployment environment, e.g. dev, staging or production, selecting
+	// the routes restricted with RouterGroup.WithEnv
+	Environment string `yaml:"environment"`
+
+	// Run mode: release, debug or test, see ModeRelease. It is read from
+	// ModeEnv if empty, and release if both are or ModeEnv is invalid.
+	Mode string `yaml:"mode"`
+
+	Server   ServerConfig   `yaml:"server"`
+	Routing  RoutingConfig  `yaml:"routing"`
+	Static   StaticConfig   `yaml:"static"`
+	Honeypot HoneypotConfig `yaml:"honeypot"`
+	TLS      TLSConfig      `yaml:"tls"`
+	Robots   RobotsConfig   `yaml:"robots"`
+	WarmUp   WarmUpConfig   `yaml:"warm_up"`
+	Security SecurityConfig `yaml:"security"`
+
+	// Rate limit of the client IPs, applied again when the configuration
+	// is reloaded
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	Profiling ProfilingConfig `yaml:"profiling"`
+	Logging   LoggingConfig   `yaml:"logging"`
+
+	// Redirects served before routing, e.g. of legacy or marketing URLs,
+	// applied again when the configuration is reloaded
+	Redirects []RedirectRule `yaml:"redirects"`
+
+	WellKnown wellknown.Config `yaml:"well_known"`
 }
 
 // ServerConfig contains basic HTTP server configuration
@@ -16,6 +62,172 @@ type ServerConfig struct {
 	ReadTimeout  int `yaml:"read_timeout"`  // seconds
 	WriteTimeout int `yaml:"write_timeout"` // seconds
 	IdleTimeout  int `yaml:"idle_timeout"`  // seconds
+
+	// Reject requests with ambiguous or malformed HTTP/1.x framing, inside
+	// the TLS connections as well, HTTP/2 being left to net/http
+	StrictFraming bool `yaml:"strict_framing"`
+
+	// Request size limits, 0 disables a limit
+	MaxHeaderBytes int      `yaml:"max_header_bytes"` // total, enforced by net/http
+	MaxHeaderCount int      `yaml:"max_header_count"`
+	MaxHeaderSize  int      `yaml:"max_header_size"` // per header, name and value
+	MaxURLLength   int      `yaml:"max_url_length"`
+	MaxQueryParams int      `yaml:"max_query_params"`
+	MaxBodySize    int64    `yaml:"max_body_size"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
+
+	// Body size over which requests are let through with a warning header
+	// and counted, e.g. to observe the sizes before setting max_body_size,
+	// 0 disables
+	WarnBodySize int64 `yaml:"warn_body_size"`
+
+	// Memory used to parse multipart forms, the rest of the files being
+	// stored in temporary files, 32 MiB if 0
+	MaxMultipartMemory int64 `yaml:"max_multipart_memory"`
+
+	// Handling of repeated query parameters: allow, first, last or reject
+	DuplicateQuery string `yaml:"duplicate_query"`
+
+	// Generator of the ids of Context.NewID and middleware.RequestID:
+	// uuidv7 (default), ulid or snowflake, NodeID identifying the instance
+	// for snowflake ids
+	IDGenerator string `yaml:"id_generator"`
+	NodeID      int64  `yaml:"node_id"`
+
+	// IPs or CIDR prefixes of the reverse proxies in front of the server,
+	// whose ClientIPHeader is trusted by Context.GetClientIP:
+	// X-Forwarded-For (default), X-Real-IP or Forwarded
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	ClientIPHeader string   `yaml:"client_ip_header"`
+}
+
+// RoutingConfig contains route registration settings
+type RoutingConfig struct {
+	// Accepted path parameter syntax: any, colon (:id) or braces ({id})
+	ParamSyntax string `yaml:"param_syntax"`
+}
+
+// StaticConfig contains the settings of the routes registered with
+// Engine.Static and Engine.StaticFS
+type StaticConfig struct {
+	Index         string `yaml:"index"`         // served for directories, index.html if empty
+	Listing       bool   `yaml:"listing"`       // list directories without an index
+	Precompressed bool   `yaml:"precompressed"` // serve .br and .gz sidecar files
+}
+
+// HoneypotConfig contains the settings of the decoy routes registered with
+// Engine.Honeypot
+type HoneypotConfig struct {
+	Tarpit       int `yaml:"tarpit"`        // seconds, 0 disables
+	DenyDuration int `yaml:"deny_duration"` // seconds, 24 hours if 0
+}
+
+// RobotsConfig contains the crawler settings, e.g. for staging sites
+type RobotsConfig struct {
+	// Keep the site out of search engines: every response carries
+	// X-Robots-Tag: noindex and /robots.txt disallows every crawler
+	NoIndex bool `yaml:"noindex"`
+}
+
+// SecurityConfig contains the security headers of the responses, see
+// middleware.SecureHeaders
+type SecurityConfig struct {
+	// Send Strict-Transport-Security, X-Content-Type-Options,
+	// X-Frame-Options and Referrer-Policy, and the policy if set
+	Headers bool `yaml:"headers"`
+
+	// Content-Security-Policy, whose {nonce} placeholders are replaced with
+	// the nonce of Context.CSPNonce
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	CSPReportOnly         bool   `yaml:"csp_report_only"`
+
+	HSTSMaxAge int `yaml:"hsts_max_age"` // seconds, 1 year if 0, disabled if negative
+}
+
+// secureHeaders returns the configuration of the security headers
+func (c SecurityConfig) secureHeaders() middleware.SecureHeadersConfig {
+	return middleware.SecureHeadersConfig{
+		HSTSMaxAge:            time.Duration(c.HSTSMaxAge) * time.Second,
+		ContentSecurityPolicy: c.ContentSecurityPolicy,
+		CSPReportOnly:         c.CSPReportOnly,
+	}
+}
+
+// RateLimitConfig contains the rate limit of the requests per client IP,
+// see middleware.RateLimit
+type RateLimitConfig struct {
+	Limit     int `yaml:"limit"`      // requests per period, 0 disables
+	Period    int `yaml:"period"`     // seconds, 60 if 0
+	WarnLimit int `yaml:"warn_limit"` // requests per period let through with a warning, 0 disables
+}
+
+// rateLimit returns the rate limit middleware configuration
+func (c RateLimitConfig) rateLimit() middleware.RateLimitConfig {
+	return middleware.RateLimitConfig{
+		Limit:     c.Limit,
+		Period:    time.Duration(c.Period) * time.Second,
+		Key:       middleware.ClientIP,
+		WarnLimit: c.WarnLimit,
+	}
+}
+
+// ProfilingConfig contains the settings of the profiling of the route
+// handlers in debug mode, see Engine.Profiler
+type ProfilingConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	Window     int  `yaml:"window"`      // seconds, 60 if 0
+	SampleRate int  `yaml:"sample_rate"` // 1 request in N measured, all if 0
+}
+
+// LoggingConfig contains the settings of the logger of the engine, see
+// Engine.Logger
+type LoggingConfig struct {
+	// Minimum level: debug, info, warn or error, debug in debug mode and
+	// info otherwise if empty
+	Level string `yaml:"level"`
+
+	// Format of the entries: text (default) or json
+	Format string `yaml:"format"`
+
+	// Destination of the entries: stdout, stderr or a file, appended to,
+	// the output of the standard log package if empty
+	Output string `yaml:"output"`
+}
+
+// WarmUpConfig contains the requests dispatched to the engine once it
+// listens, before it reports ready, e.g. to prime caches and connection
+// pools, see Engine.Ready
+type WarmUpConfig struct {
+	Requests []WarmUpRequest `yaml:"requests"`
+	Timeout  int             `yaml:"timeout"` // seconds, for all the requests, 30 if 0
+}
+
+// WarmUpRequest is a request dispatched during the warm-up
+type WarmUpRequest struct {
+	Method string            `yaml:"method"` // GET if empty
+	Path   string            `yaml:"path"`   // with the query, e.g. /products?page=1
+	Header map[string]string `yaml:"header"`
+	Body   string            `yaml:"body"`
+	Count  int               `yaml:"count"` // times the request is sent, 1 if 0
+}
+
+// TLSConfig contains the HTTPS settings of the server
+type TLSConfig struct {
+	AutoCert AutoCertConfig `yaml:"autocert"`
+}
+
+// AutoCertConfig contains the settings of the certificates obtained from an
+// ACME CA, e.g. Let's Encrypt, with HTTP-01 challenges. Disabled without
+// domains.
+type AutoCertConfig struct {
+	Domains      []string `yaml:"domains"`
+	Email        string   `yaml:"email"`         // contact of the ACME account
+	CacheDir     string   `yaml:"cache_dir"`     // certificates are kept in memory only if empty
+	DirectoryURL string   `yaml:"directory_url"` // ACME directory, Let's Encrypt if empty
+
+	// Address of the plain HTTP server answering the challenges and
+	// redirecting other requests to HTTPS, :80 if empty
+	HTTPAddress string `yaml:"http_address"`
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -26,42 +238,151 @@ func DefaultConfig() *Config {
 			ReadTimeout:  10,
 			WriteTimeout: 10,
 			IdleTimeout:  60,
+
+			StrictFraming: true,
+
+			MaxHeaderBytes: http.DefaultMaxHeaderBytes,
+			MaxHeaderCount: 100,
+			MaxHeaderSize:  8 << 10,
+			MaxURLLength:   8 << 10,
+			MaxQueryParams: 256,
+		},
+		Honeypot: HoneypotConfig{
+			DenyDuration: 3600,
 		},
 	}
 }
 
-// LoadConfig loads configuration from a YAML file (placeholder for now)
-// TODO: Implement YAML loading when dependencies are added
+// ConfigFormat is the format of a configuration file
+type ConfigFormat string
+
+const (
+	ConfigFormatAuto ConfigFormat = ""     // detected from the file extension
+	ConfigFormatYAML ConfigFormat = "yaml" // .yaml, .yml and unknown extensions
+	ConfigFormatJSON ConfigFormat = "json" // .json
+	ConfigFormatTOML ConfigFormat = "toml" // .toml
+)
+
+// DetectConfigFormat returns the format of a configuration file from its
+// extension, YAML for unknown extensions
+func DetectConfigFormat(filename string) ConfigFormat {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ConfigFormatJSON
+	case ".toml":
+		return ConfigFormatTOML
+	default:
+		return ConfigFormatYAML
+	}
+}
+
+// LoadConfig loads configuration from a YAML, JSON or TOML file, detected
+// from its extension. The values of the file override the defaults and a
+// missing file yields the defaults.
+//
+// Unknown fields are ignored, see LoadConfigStrict.
 func LoadConfig(filename string) (*Config, error) {
-	config := DefaultConfig()
+	return LoadConfigFormat(filename, ConfigFormatAuto, false)
+}
 
+// LoadConfigStrict loads configuration like LoadConfig, but rejects fields
+// that do not exist in Config, e.g. misspelled keys
+func LoadConfigStrict(filename string) (*Config, error) {
+	return LoadConfigFormat(filename, ConfigFormatAuto, true)
+}
+
+// LoadConfigFormat loads configuration from a file in the given format,
+// e.g. for files without a meaningful extension
+func LoadConfigFormat(filename string, format ConfigFormat, strict bool) (*Config, error) {
 	if filename == "" {
-		return config, nil
+		return DefaultConfig(), nil
+	}
+	if format == ConfigFormatAuto {
+		format = DetectConfigFormat(filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultConfig(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	config, err := DecodeConfig(data, format, strict)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
 	}
+	return config, nil
+}
+
+// DecodeConfig parses a configuration in the given format over the
+// defaults
+//
+// Field names are the yaml tags of Config in every format. JSON is parsed
+// as YAML, of which it is a subset, and TOML is converted to YAML once
+// parsed, so that all formats behave the same.
+//
+// @return: the configuration
+// @return: an error if the format is unknown, or see ParseConfig
+func DecodeConfig(data []byte, format ConfigFormat, strict bool) (*Config, error) {
+	switch format {
+	case ConfigFormatYAML, ConfigFormatJSON:
+		return ParseConfig(data, strict)
+	case ConfigFormatTOML:
+		var values map[string]any
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("parsing config: %w", err)
+		}
+		if len(values) == 0 {
+			return DefaultConfig(), nil
+		}
+		converted, err := yaml.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("parsing config: %w", err)
+		}
+		return ParseConfig(converted, strict)
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+}
+
+// ParseConfig parses a YAML configuration over the defaults
+//
+// @return: the configuration
+// @return: an error with the offending line if the YAML is malformed, has
+// values of the wrong type, or has unknown fields in strict mode
+func ParseConfig(data []byte, strict bool) (*Config, error) {
+	config := DefaultConfig()
 
-	// For now, just check if file exists and return default config
-	// YAML parsing will be implemented later with dependencies
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return config, nil
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(strict)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("parsing config: %w", err)
 	}
 
+	// A second document is most likely a mistake, e.g. a stray "---"
+	var extra yaml.Node
+	switch err := decoder.Decode(&extra); {
+	case err == nil:
+		return nil, fmt.Errorf("parsing config: unexpected document at line %d", extra.Line)
+	case err != io.EOF:
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
 	return config, nil
 }
 
-// LoadConfigWithEnv loads configuration from YAML file and overrides with environment variables
+// LoadConfigWithEnv loads configuration from YAML file and overrides with
+// environment variables, see Config.ApplyEnv
 func LoadConfigWithEnv(filename string) (*Config, error) {
 	config, err := LoadConfig(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	// Override with environment variables
-	// Basic port override for minimal config
-	if port := os.Getenv("SERVER_PORT"); port != "" {
-		// Simple conversion - in production, add proper error handling
-		fmt.Sscanf(port, "%d", &config.Server.Port)
+	if err := config.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("environment: %w", err)
 	}
-
 	return config, nil
 }
 
@@ -80,5 +401,84 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("write timeout must be positive")
 	}
 
+	if c.Server.MaxHeaderBytes < 0 || c.Server.MaxHeaderCount < 0 ||
+		c.Server.MaxHeaderSize < 0 || c.Server.MaxURLLength < 0 ||
+		c.Server.MaxQueryParams < 0 || c.Server.MaxBodySize < 0 || c.Server.WarnBodySize < 0 {
+		return fmt.Errorf("request limits must not be negative")
+	}
+
+	if c.Honeypot.Tarpit < 0 || c.Honeypot.DenyDuration < 0 {
+		return fmt.Errorf("honeypot durations must not be negative")
+	}
+
+	if c.RateLimit.Limit < 0 || c.RateLimit.Period < 0 || c.RateLimit.WarnLimit < 0 {
+		return fmt.Errorf("rate limit must not be negative")
+	}
+
+	if c.Profiling.Window < 0 || c.Profiling.SampleRate < 0 {
+		return fmt.Errorf("profiling window and sample rate must not be negative")
+	}
+
+	if err := c.Logging.validate(); err != nil {
+		return err
+	}
+
+	if _, err := middleware.ParseDuplicateQueryPolicy(c.Server.DuplicateQuery); err != nil {
+		return err
+	}
+
+	if _, err := routes.ParseParamSyntax(c.Routing.ParamSyntax); err != nil {
+		return err
+	}
+
+	if _, err := idgen.New(c.Server.IDGenerator, c.Server.NodeID); err != nil {
+		return err
+	}
+
+	if _, err := types.ParseTrustedProxies(c.Server.TrustedProxies, c.Server.ClientIPHeader); err != nil {
+		return err
+	}
+
+	if err := c.WellKnown.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := parseMode(c.Mode); err != nil {
+		return err
+	}
+
+	if err := c.WarmUp.validate(); err != nil {
+		return err
+	}
+
+	if _, err := newRedirects(c.Redirects); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// static returns the static file server configuration
+func (c *Config) static() static.Config {
+	config := static.Config{
+		Index:   c.Static.Index,
+		Listing: c.Static.Listing,
+	}
+	if c.Static.Precompressed {
+		config.Encodings = static.DefaultEncodings
+	}
+	return config
+}
+
+// limits returns the request limits middleware configuration
+func (c *Config) limits() middleware.LimitsConfig {
+	return middleware.LimitsConfig{
+		MaxHeaderCount: c.Server.MaxHeaderCount,
+		MaxHeaderSize:  c.Server.MaxHeaderSize,
+		MaxURLLength:   c.Server.MaxURLLength,
+		MaxQueryParams: c.Server.MaxQueryParams,
+		MaxBodySize:    c.Server.MaxBodySize,
+		WarnBodySize:   c.Server.WarnBodySize,
+		AllowedHeaders: c.Server.AllowedHeaders,
+	}
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(`
+server:
+  port: 9090
+  allowed_headers: [Authorization, Content-Type]
+  duplicate_query: reject
+routing:
+  param_syntax: braces
+unknown: true
+`), 0o600))
+
+	// Values of the file override the defaults, unknown fields are ignored
+	config, err := LoadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, 9090, config.Server.Port)
+	require.Equal(t, 10, config.Server.ReadTimeout)
+	require.Equal(t, []string{"Authorization", "Content-Type"}, config.Server.AllowedHeaders)
+	require.Equal(t, "reject", config.Server.DuplicateQuery)
+	require.Equal(t, "braces", config.Routing.ParamSyntax)
+	require.NoError(t, config.Validate())
+
+	// Strict mode rejects unknown fields
+	_, err = LoadConfigStrict(filename)
+	require.ErrorContains(t, err, "line 8: field unknown not found")
+
+	// Missing and empty files yield the defaults
+	config, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, DefaultConfig(), config)
+	config, err = ParseConfig(nil, true)
+	require.NoError(t, err)
+	require.Equal(t, DefaultConfig(), config)
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	for name, data := range map[string]string{
+		"malformed":      "server:\n  port: [9090\n",
+		"wrong type":     "server:\n  port: http\n",
+		"many documents": "server:\n  port: 1\n---\nserver:\n  port: 2\n",
+	} {
+		_, err := ParseConfig([]byte(data), false)
+		require.Error(t, err, name)
+		require.ErrorContains(t, err, "line", name)
+	}
+}
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"SERVER_PORT":            "9090",
+		"SERVER_READ_TIMEOUT":    " 30 ",
+		"SERVER_STRICT_FRAMING":  "false",
+		"SERVER_ALLOWED_HEADERS": "Authorization, Content-Type,",
+		"ROUTING_PARAM_SYNTAX":   "colon",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	config := DefaultConfig()
+	require.NoError(t, config.ApplyEnv(lookup))
+	require.Equal(t, 9090, config.Server.Port)
+	require.Equal(t, 30, config.Server.ReadTimeout)
+	require.Equal(t, 10, config.Server.WriteTimeout)
+	require.False(t, config.Server.StrictFraming)
+	require.Equal(t, []string{"Authorization", "Content-Type"}, config.Server.AllowedHeaders)
+	require.Equal(t, "colon", config.Routing.ParamSyntax)
+
+	// Every invalid value is reported, the valid ones are still applied
+	env = map[string]string{
+		"SERVER_PORT":           "80a",
+		"SERVER_STRICT_FRAMING": "maybe",
+		"HONEYPOT_TARPIT":       "5",
+	}
+	config = DefaultConfig()
+	err := config.ApplyEnv(lookup)
+	require.ErrorContains(t, err, `SERVER_PORT: invalid integer "80a"`)
+	require.ErrorContains(t, err, `SERVER_STRICT_FRAMING: invalid boolean "maybe"`)
+	require.Equal(t, 5, config.Honeypot.Tarpit)
+
+	// The resulting configuration is validated
+	env = map[string]string{"SERVER_PORT": "70000"}
+	config = DefaultConfig()
+	require.ErrorContains(t, config.ApplyEnv(lookup), "invalid server port: 70000")
+
+	require.Contains(t, EnvNames(), "SERVER_MAX_HEADER_BYTES")
+	require.Contains(t, EnvNames(), "STATIC_PRECOMPRESSED")
+}
+
+func TestLoadConfig_Formats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": "{\n\t\"server\": {\n\t\t\"port\": 9090,\n\t\t\"allowed_headers\": [\"Authorization\"]\n\t},\n\t\"static\": {\"listing\": true}\n}\n",
+		"config.toml": "[server]\nport = 9090\nallowed_headers = [\"Authorization\"]\n\n[static]\nlisting = true\n",
+		"config.conf": "server:\n  port: 9090\n  allowed_headers: [Authorization]\nstatic:\n  listing: true\n",
+	}
+	for name, data := range files {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(filename, []byte(data), 0o600))
+
+		config, err := LoadConfigStrict(filename)
+		require.NoError(t, err, name)
+		require.Equal(t, 9090, config.Server.Port, name)
+		require.Equal(t, 10, config.Server.ReadTimeout, name)
+		require.Equal(t, []string{"Authorization"}, config.Server.AllowedHeaders, name)
+		require.True(t, config.Static.Listing, name)
+	}
+
+	// The format can be given explicitly
+	filename := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(filename, []byte(files["config.toml"]), 0o600))
+	config, err := LoadConfigFormat(filename, ConfigFormatTOML, true)
+	require.NoError(t, err)
+	require.Equal(t, 9090, config.Server.Port)
+
+	// Unknown fields and malformed files are reported in every format
+	_, err = DecodeConfig([]byte("[server]\nprot = 1\n"), ConfigFormatTOML, true)
+	require.ErrorContains(t, err, "field prot not found")
+	_, err = DecodeConfig([]byte("[server\n"), ConfigFormatTOML, false)
+	require.ErrorContains(t, err, "line 2")
+	_, err = DecodeConfig([]byte(`{"server": {"port": "http"}}`), ConfigFormatJSON, false)
+	require.Error(t, err)
+	_, err = DecodeConfig(nil, "ini", false)
+	require.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// DispatchOption customizes the request of Engine.Dispatch
+type DispatchOption func(r *http.Request)
+
+// DispatchBody sets the body of the request and its Content-Type
+func DispatchBody(contentType string, body io.Reader) DispatchOption {
+	return func(r *http.Request) {
+		r.Header.Set("Content-Type", contentType)
+		r.Body = io.NopCloser(body)
+		r.ContentLength = -1
+		if lener, ok := body.(interface{ Len() int }); ok {
+			r.ContentLength = int64(lener.Len())
+		}
+	}
+}
+
+// DispatchHeader adds a header to the request
+func DispatchHeader(key, value string) DispatchOption {
+	return func(r *http.Request) {
+		r.Header.Add(key, value)
+	}
+}
+
+// DispatchContext sets the context of the request, e.g. to carry the
+// deadline or the values of an outer request
+func DispatchContext(ctx context.Context) DispatchOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(ctx)
+	}
+}
+
+// RecordedResponse is the response of a dispatched request
+type RecordedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Dispatch runs a request through the engine in-process, with the engine
+// and route middleware, e.g. for batch endpoints or cache warming
+//
+// The path may carry a query string. The request comes from 127.0.0.1 and
+// has no body unless set with DispatchBody.
+//
+// @return: the recorded response
+// @return: an error if the engine failed to register its routes or the
+// path is invalid
+func (e *Engine) Dispatch(method, path string, opts ...DispatchOption) (*RecordedResponse, error) {
+	if err := e.Err(); err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(method, path, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	r.RequestURI = r.URL.RequestURI()
+	r.RemoteAddr = "127.0.0.1:0"
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	w := &responseRecorder{header: make(http.Header)}
+	e.ServeHTTP(w, r)
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return &RecordedResponse{Status: w.status, Header: w.sent, Body: w.body.Bytes()}, nil
+}
+
+// responseRecorder records the response of a dispatched request
+type responseRecorder struct {
+	header http.Header
+	sent   http.Header // header as sent with the status
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter
+func (w *responseRecorder) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter, informational statuses are
+// not recorded
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+		w.sent = w.header.Clone()
+	}
+}
+
+// Write implements http.ResponseWriter
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
+
+// Flush implements http.Flusher, the response is only available once the
+// request completed
+func (w *responseRecorder) Flush() {}
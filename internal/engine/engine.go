@@ -1,24 +1,107 @@
 package engine
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/skjdfhkskjds/go-api/internal/events"
+	"github.com/skjdfhkskjds/go-api/internal/guard"
+	"github.com/skjdfhkskjds/go-api/internal/idgen"
+	"github.com/skjdfhkskjds/go-api/internal/middleware"
 	"github.com/skjdfhkskjds/go-api/internal/routes"
+	"github.com/skjdfhkskjds/go-api/internal/templates"
 	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/skjdfhkskjds/go-api/internal/useragent"
+	"github.com/skjdfhkskjds/go-api/internal/wellknown"
 )
 
 // Engine is the core framework engine
 type Engine struct {
-	config *Config
-	routes *routes.RouteNode
-	server *http.Server
+	config      *Config
+	mode        string
+	routes      *routes.RouteNode
+	middlewares []types.MiddlewareFunc
+
+	// Routes below /.well-known/, served without the engine middleware
+	wellKnown *routes.RouteNode
+
+	// Counters for requests rejected by the framing guard
+	framingStats guard.Stats
+
+	// Dynamic deny list fed by honeypot routes, nil until first used
+	denyList *middleware.DenyList
+
+	// Parser for Context.Client, nil uses the default parser
+	clientParser useragent.Parser
+
+	// Generator of Context.NewID
+	idGenerator idgen.Generator
+
+	// Profiler of the route handlers in debug mode, nil unless enabled
+	profiler *middleware.Profiler
+
+	// Logger of the engine and of Context.Logger, see Engine.SetLogger,
+	// and its level unless configured
+	logger   types.Logger
+	logLevel *slog.LevelVar
+
+	// Proxies trusted by Context.GetClientIP
+	trustedProxies *types.TrustedProxies
+
+	// Settings applied while serving, see ReloadConfig
+	runtime     atomic.Pointer[Config]
+	limiter     *middleware.Limiter
+	rateLimiter *middleware.RateLimiter
+	timeouts    atomic.Pointer[timeouts]
+	redirects   atomic.Pointer[redirects]
+	reloadMu    sync.Mutex
+	reloads     []ConfigReloadFunc
+
+	// Server state, set when the engine starts listening
+	serverMu   sync.Mutex
+	server     *http.Server
+	listener   net.Listener
+	challenges *http.Server
+
+	// Certificates obtained from an ACME CA, nil unless autocert is enabled
+	certManager *autocert.Manager
+
+	// Templates of Context.HTMLTemplate, nil until loaded
+	templates *templates.Set
+
+	// Checks run by Preflight besides the built-in ones
+	preflight []preflightCheck
+
+	// Set once the server listens and warmed up, see Ready
+	ready atomic.Bool
+
+	// Lifecycle and request events, see Events
+	events events.Bus
+
+	// Run after every request, see Finally
+	finalizers []func(c *types.Context)
+
+	// Errors encountered while registering routes
+	errs []error
 }
 
-// New creates a new Engine instance with the provided configuration
-func New(config *Config) *Engine {
+// New creates a new Engine instance with the provided configuration, the
+// defaults if nil
+//
+// @return: an error if the configuration is invalid, e.g. its mode or
+// parameter syntax
+func New(config *Config) (*Engine, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
@@ -26,48 +109,360 @@ func New(config *Config) *Engine {
 	engine := &Engine{
 		config: config,
 		routes: routes.NewRouteNode("", routes.RouteTypeNone, "", nil),
+
+		wellKnown: routes.NewRouteNode("", routes.RouteTypeNone, "", nil),
+	}
+
+	mode, err := parseMode(config.Mode)
+	if err != nil {
+		return nil, err
+	}
+	var envErr error
+	if config.Mode == "" {
+		mode, envErr = envMode()
+	}
+	engine.mode = mode
+
+	if err := engine.setUpLogger(config.Logging); err != nil {
+		return nil, err
+	}
+	if envErr != nil {
+		engine.logger.Warn("Invalid run mode, using release", "error", envErr)
+	}
+
+	// The limiter is installed even without limits, so that reloaded
+	// configurations can enable them
+	engine.limiter = middleware.NewLimiter(config.limits())
+	engine.Use(engine.limiter.Middleware())
+	engine.rateLimiter = middleware.NewRateLimiter(config.RateLimit.rateLimit())
+	engine.Use(engine.rateLimiter.Middleware())
+	engine.runtime.Store(config)
+
+	policy, err := middleware.ParseDuplicateQueryPolicy(config.Server.DuplicateQuery)
+	if err != nil {
+		return nil, err
+	}
+	if policy != middleware.DuplicateQueryAllow {
+		engine.Use(middleware.DuplicateQuery(policy))
+	}
+
+	if config.Profiling.Enabled {
+		engine.profiler = middleware.NewProfiler(middleware.ProfilerConfig{
+			Window:     time.Duration(config.Profiling.Window) * time.Second,
+			SampleRate: config.Profiling.SampleRate,
+		})
 	}
 
+	if config.Security.Headers {
+		engine.Use(middleware.SecureHeaders(config.Security.secureHeaders()))
+	}
+
+	redirects, err := newRedirects(config.Redirects)
+	if err != nil {
+		return nil, err
+	}
+	engine.redirects.Store(redirects)
+
+	if engine.idGenerator, err = idgen.New(config.Server.IDGenerator, config.Server.NodeID); err != nil {
+		return nil, err
+	}
+
+	if engine.trustedProxies, err = types.ParseTrustedProxies(config.Server.TrustedProxies, config.Server.ClientIPHeader); err != nil {
+		return nil, err
+	}
+
+	syntax, err := routes.ParseParamSyntax(config.Routing.ParamSyntax)
+	if err != nil {
+		return nil, err
+	}
+	engine.routes.SetParamSyntax(syntax)
+	engine.wellKnown.SetParamSyntax(syntax)
+
+	if engine.certManager = newCertManager(config.TLS.AutoCert); engine.certManager != nil {
+		engine.acmeChallenge()
+	}
+	engine.wellKnownDocuments(&config.WellKnown)
+	if config.Robots.NoIndex {
+		engine.robotsDenyAll()
+	}
+
+	return engine, nil
+}
+
+// MustNew creates an engine like New, panicking if the configuration is
+// invalid, e.g. in tests and examples
+func MustNew(config *Config) *Engine {
+	engine, err := New(config)
+	if err != nil {
+		panic(err)
+	}
 	return engine
 }
 
+// Events returns the event bus of the engine, publishing the
+// events.RouteRegistered, events.RequestCompleted and ConfigReloaded
+// events
+//
+//	events.Subscribe(e.Events(), func(ev events.RequestCompleted) { ... })
+func (e *Engine) Events() *events.Bus {
+	return &e.events
+}
+
+// TrustedProxies returns the reverse proxies whose forwarding headers are
+// trusted, see ServerConfig.TrustedProxies, e.g. for proxy.HashClientIP
+func (e *Engine) TrustedProxies() *types.TrustedProxies {
+	return e.trustedProxies
+}
+
 // ServeHTTP implements http.Handler interface
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Finalizers run last, once the deferred writers finished the response
+	var ctx *types.Context
+	if len(e.finalizers) > 0 {
+		defer func() {
+			if ctx != nil {
+				e.finalize(ctx)
+			}
+		}()
+	}
+
+	// The TLS connections validated by the framing guard are not *tls.Conn
+	guard.RestoreTLS(r)
+
+	if e.config.Robots.NoIndex {
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+
+	// Reloaded timeouts replace the deadlines set by the server
+	if t := e.timeouts.Load(); t != nil {
+		t.apply(w)
+	}
+
+	if events.Subscribed[events.RequestCompleted](&e.events) {
+		writer := types.NewResponseWriter(w)
+		start := time.Now()
+		defer func() {
+			events.Publish(&e.events, events.RequestCompleted{
+				Request:  r,
+				Status:   writer.Status(),
+				Size:     writer.Size(),
+				Duration: time.Since(start),
+			})
+		}()
+		w = writer
+	}
+
+	// Responses to HEAD requests have their body discarded whatever the
+	// handler writes
+	if r.Method == http.MethodHead {
+		writer := &headWriter{ResponseWriter: w}
+		defer writer.finish()
+		w = writer
+	}
+
 	// Convert net/http request to our Context type
-	ctx := &types.Context{
-		Request:    r,
-		Writer:     w,
-		PathParams: make(map[string]string),
+	ctx = &types.Context{
+		Request: r,
+		Writer:  w,
+
+		ClientParser:       e.clientParser,
+		IDGenerator:        e.idGenerator,
+		TrustedProxies:     e.trustedProxies,
+		Log:                e.logger,
+		MaxMultipartMemory: e.config.Server.MaxMultipartMemory,
+		Debug:              e.IsDebug(),
+		Development:        e.Mode() != ModeRelease,
+	}
+	if e.templates != nil {
+		ctx.Templates = e.templates
 	}
 
-	// Find matching route using RouteNode
+	// Well-known documents skip the engine middleware, so that e.g.
+	// authentication or maintenance modes cannot hide them. Unmatched
+	// requests fall through to the other routes.
+	if strings.HasPrefix(r.URL.Path, wellknown.Prefix) {
+		if route, err := e.wellKnown.Find(r.Method, r.URL.Path); err == nil {
+			ctx.Params, ctx.Route = route.Params, route.Pattern
+			ctx.Execute(types.Chain(route.Middlewares, route.Handler))
+			return
+		}
+	}
+
+	// Redirects take precedence over the routes
+	if redirects := e.redirects.Load(); redirects != nil {
+		if handler := redirects.find(r); handler != nil {
+			ctx.Execute(types.Chain(e.middlewares, handler))
+			return
+		}
+	}
+
+	// Find matching route using RouteNode, unmatched requests still run
+	// through the engine middleware so they are logged, recovered, etc.
 	route, err := e.routes.Find(r.Method, r.URL.Path)
 	if err != nil {
-		ctx.ErrorString(http.StatusNotFound, "Not Found")
+		if route := e.headRoute(r, err); route != nil {
+			ctx.Params, ctx.Route = route.Params, route.Pattern
+			ctx.Execute(route.Handlers)
+			return
+		}
+		if route := e.preflightRoute(r, err); route != nil {
+			ctx.Params, ctx.Route = route.Params, route.Pattern
+			ctx.Execute(route.Handlers)
+			return
+		}
+		ctx.Execute(types.Chain(e.middlewares, routeErrorHandler(err)))
 		return
 	}
 
 	// Set path parameters from route matching
-	ctx.PathParams = route.PathParams
+	ctx.Params, ctx.Route = route.Params, route.Pattern
+
+	// Execute engine middleware, then route middleware, then the handler,
+	// compiled into the route at registration, see Engine.Use
+	ctx.Execute(route.Handlers)
+}
 
-	// Execute handler (middleware support can be added later)
-	route.Handler(ctx)
+// preflightRoute routes CORS preflight requests to paths without OPTIONS
+// handler through the engine middleware, then the middleware of the route
+// of the requested method, so that a middleware.CORS among them answers
+// them. Without one, they are answered with 204 No Content and Allow.
+//
+// @return: the route, nil if the request is not a preflight request
+func (e *Engine) preflightRoute(r *http.Request, err error) *routes.Route {
+	var methodErr *routes.MethodNotAllowedError
+	requested := r.Header.Get("Access-Control-Request-Method")
+	if r.Method != http.MethodOptions || requested == "" || !errors.As(err, &methodErr) {
+		return nil
+	}
+	route, err := e.routes.Find(requested, r.URL.Path)
+	if err != nil {
+		return nil
+	}
+	allowed := append(allowedMethods(methodErr), http.MethodOptions)
+	route.Handler = func(ctx *types.Context) {
+		ctx.Header("Allow", strings.Join(allowed, ", "))
+		ctx.Status(http.StatusNoContent)
+	}
+	route.Handlers = types.Chain(route.Middlewares, route.Handler)
+	return route
+}
+
+// routeErrorHandler returns the handler responding to a failed route lookup
+func routeErrorHandler(err error) types.HandlerFunc {
+	return func(ctx *types.Context) {
+		var methodErr *routes.MethodNotAllowedError
+		if errors.As(err, &methodErr) {
+			ctx.Header("Allow", strings.Join(allowedMethods(methodErr), ", "))
+			ctx.ErrorString(http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		ctx.ErrorString(http.StatusNotFound, "Not Found")
+	}
 }
 
 // Run starts the HTTP server
 func (e *Engine) Run(addr ...string) error {
-	address := e.resolveAddress(addr)
+	if err := e.Err(); err != nil {
+		return err
+	}
+
+	ln, err := e.listen(e.resolveAddress(addr))
+	if err != nil {
+		return err
+	}
+	return e.serve(ln)
+}
+
+// Shutdown gracefully stops the HTTP server started by Run, see
+// http.Server.Shutdown, the engine no longer reporting ready
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.ready.Store(false)
+	e.serverMu.Lock()
+	server, challenges := e.server, e.challenges
+	e.serverMu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	if challenges != nil {
+		challenges.Shutdown(ctx)
+	}
+	return server.Shutdown(ctx)
+}
+
+// Addr returns the address the server listens on, nil until it started
+func (e *Engine) Addr() net.Addr {
+	e.serverMu.Lock()
+	defer e.serverMu.Unlock()
+	if e.listener == nil {
+		return nil
+	}
+	return e.listener.Addr()
+}
 
-	e.server = &http.Server{
+// listen creates the HTTP server and its listener
+func (e *Engine) listen(address string) (net.Listener, error) {
+	server := &http.Server{
 		Addr:         address,
 		Handler:      e,
 		ReadTimeout:  time.Duration(e.config.Server.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(e.config.Server.WriteTimeout) * time.Second,
 		IdleTimeout:  time.Duration(e.config.Server.IdleTimeout) * time.Second,
+
+		MaxHeaderBytes: e.config.Server.MaxHeaderBytes,
+	}
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	var challenges *http.Server
+	if e.certManager != nil {
+		server.TLSConfig = e.certManager.TLSConfig()
+		ln = tls.NewListener(ln, server.TLSConfig)
+		challenges = e.challengeServer()
+	}
+	if e.config.Server.StrictFraming {
+		guardConfig := guard.Config{Stats: &e.framingStats}
+		if e.certManager != nil {
+			// The guard validates the requests inside the TLS connections,
+			// whose state ServeHTTP restores, see guard.NewTLSListener
+			ln = guard.NewTLSListener(ln, guardConfig)
+			server.ConnContext = guard.ConnContext
+		} else {
+			ln = guard.NewListener(ln, guardConfig)
+		}
 	}
 
-	log.Printf("Server starting on %s", address)
-	return e.server.ListenAndServe()
+	e.serverMu.Lock()
+	e.server, e.challenges, e.listener = server, challenges, ln
+	e.serverMu.Unlock()
+	return ln, nil
+}
+
+// serve serves the requests of the listener until the server is shut down
+func (e *Engine) serve(ln net.Listener) error {
+	e.serverMu.Lock()
+	server, challenges := e.server, e.challenges
+	e.serverMu.Unlock()
+
+	if challenges != nil {
+		go func() {
+			if err := challenges.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				e.logger.Error("ACME challenge server failed", "addr", challenges.Addr, "error", err)
+			}
+		}()
+	}
+
+	e.logger.Info("Server starting", "addr", ln.Addr().String())
+	go e.warmUp()
+	return server.Serve(ln)
+}
+
+// FramingStats returns the counters of requests rejected for ambiguous or
+// malformed framing, such as request smuggling attempts
+func (e *Engine) FramingStats() *guard.Stats {
+	return &e.framingStats
 }
 
 // resolveAddress resolves the server address
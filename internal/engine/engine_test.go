@@ -0,0 +1,828 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/http/fcgi"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/events"
+	"github.com/skjdfhkskjds/go-api/internal/fastcgi"
+	"github.com/skjdfhkskjds/go-api/internal/guard"
+	"github.com/skjdfhkskjds/go-api/internal/middleware"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/skjdfhkskjds/go-api/internal/wellknown"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serve runs a request through the engine
+func serve(e *Engine, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func newTestHandler(name string) types.HandlerFunc {
+	return func(c *types.Context) {
+		c.String(http.StatusOK, name)
+	}
+}
+
+// tagMiddleware appends its name to the X-Trace response header
+func tagMiddleware(name string) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.Writer.Header().Add("X-Trace", name)
+			next(c)
+		}
+	}
+}
+
+func TestEngine_CompiledChains(t *testing.T) {
+	passThrough := func(next types.HandlerFunc) types.HandlerFunc { return next }
+	allocs := func(middlewares int) float64 {
+		e := MustNew(nil)
+		e.Use(slices.Repeat([]types.MiddlewareFunc{passThrough}, middlewares)...)
+		e.GET("/users/:id", func(c *types.Context) {}, slices.Repeat([]types.MiddlewareFunc{passThrough}, middlewares)...)
+		// Engine middleware added after the route is compiled into it too
+		e.Use(slices.Repeat([]types.MiddlewareFunc{passThrough}, middlewares)...)
+
+		r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		w := httptest.NewRecorder()
+		return testing.AllocsPerRun(100, func() {
+			e.ServeHTTP(w, r)
+		})
+	}
+
+	// The chains of matched routes are compiled at registration, so that
+	// middleware adds no allocations to the requests
+	require.Equal(t, allocs(0), allocs(4))
+}
+
+func TestEngine_NestedGroups(t *testing.T) {
+	e := MustNew(nil)
+	e.Use(tagMiddleware("engine"))
+
+	api := e.Group("/api", tagMiddleware("api"))
+	v1 := api.Group("/v1")
+	v1.GET("/status", newTestHandler("status"))
+
+	admin := v1.Group("/admin").Use(tagMiddleware("admin"))
+	admin.
+		GET("/users", newTestHandler("users"), tagMiddleware("route")).
+		DELETE("/users", newTestHandler("delete"))
+	require.NoError(t, e.Err())
+
+	w := serve(e, http.MethodGet, "/api/v1/status")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "status", w.Body.String())
+	require.Equal(t, []string{"engine", "api"}, w.Header().Values("X-Trace"))
+
+	w = serve(e, http.MethodGet, "/api/v1/admin/users")
+	require.Equal(t, "users", w.Body.String())
+	require.Equal(t, []string{"engine", "api", "admin", "route"}, w.Header().Values("X-Trace"))
+
+	w = serve(e, http.MethodDelete, "/api/v1/admin/users")
+	require.Equal(t, "delete", w.Body.String())
+
+	// Re-opening a group adds to the same prefix
+	e.Group("/api/v1").POST("/status", newTestHandler("post"))
+	require.NoError(t, e.Err())
+	require.Equal(t, "post", serve(e, http.MethodPost, "/api/v1/status").Body.String())
+}
+
+func TestEngine_Head(t *testing.T) {
+	e := MustNew(nil)
+	e.Use(tagMiddleware("engine"))
+	e.GET("/users", func(c *types.Context) {
+		c.Header("ETag", `"v1"`)
+		c.JSON(http.StatusOK, []string{"ada", "bob"})
+	})
+	e.HEAD("/files", func(c *types.Context) {
+		c.String(http.StatusOK, "file content")
+	})
+	e.GET("/stream", func(c *types.Context) {
+		c.String(http.StatusOK, "part")
+		c.Writer.(http.Flusher).Flush()
+	})
+
+	get := serve(e, http.MethodGet, "/users")
+
+	// HEAD requests are routed to the GET handler, without body
+	w := serve(e, http.MethodHead, "/users")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Equal(t, strconv.Itoa(get.Body.Len()), w.Header().Get("Content-Length"))
+	require.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	require.Equal(t, []string{"engine"}, w.Header().Values("X-Trace"))
+
+	// and so are the HEAD handlers
+	w = serve(e, http.MethodHead, "/files")
+	require.Empty(t, w.Body.String())
+	require.Equal(t, "12", w.Header().Get("Content-Length"))
+
+	// Flushed responses have no known length
+	w = serve(e, http.MethodHead, "/stream")
+	require.Empty(t, w.Body.String())
+	require.Empty(t, w.Header().Get("Content-Length"))
+
+	w = serve(e, http.MethodHead, "/missing")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Empty(t, w.Body.String())
+}
+
+func TestEngine_HeadMiddleware(t *testing.T) {
+	e := MustNew(nil)
+	e.Use(middleware.Compress(middleware.CompressConfig{MinSize: 1}), middleware.Digest(middleware.DigestConfig{}))
+	e.GET("/users", func(c *types.Context) {
+		c.String(http.StatusOK, strings.Repeat("ada bob ", 100))
+	})
+
+	// HEAD responses carry the headers of the GET responses compressed and
+	// digested by the middleware
+	requests := map[string]*http.Request{
+		http.MethodGet:  httptest.NewRequest(http.MethodGet, "/users", nil),
+		http.MethodHead: httptest.NewRequest(http.MethodHead, "/users", nil),
+	}
+	responses := make(map[string]*httptest.ResponseRecorder)
+	for method, r := range requests {
+		r.Header.Set("Accept-Encoding", "gzip")
+		responses[method] = httptest.NewRecorder()
+		e.ServeHTTP(responses[method], r)
+	}
+	get, head := responses[http.MethodGet], responses[http.MethodHead]
+	require.Equal(t, "gzip", head.Header().Get("Content-Encoding"))
+	require.Equal(t, get.Header().Get("Content-Digest"), head.Header().Get("Content-Digest"))
+	require.NotEmpty(t, head.Header().Get("Content-Digest"))
+	require.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"))
+	require.Empty(t, head.Body.String())
+}
+
+func TestEngine_GroupPrefixIsNotARoute(t *testing.T) {
+	e := MustNew(nil)
+	e.Group("/api").GET("/status", newTestHandler("status"))
+
+	w := serve(e, http.MethodGet, "/api")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestEngine_MethodNotAllowed(t *testing.T) {
+	e := MustNew(nil)
+	e.Use(tagMiddleware("engine"))
+	e.GET("/users", newTestHandler("get")).POST("/users", newTestHandler("post"))
+
+	w := serve(e, http.MethodPut, "/users")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "GET, HEAD, POST", w.Header().Get("Allow"))
+
+	// Engine middleware also runs for unmatched requests
+	require.Equal(t, []string{"engine"}, w.Header().Values("X-Trace"))
+}
+
+func TestEngine_RegistrationErrors(t *testing.T) {
+	e := MustNew(nil)
+	e.GET("/users", newTestHandler("first"))
+	e.Group("/users").GET("/", newTestHandler("second"))
+
+	require.Error(t, e.Err())
+	require.Error(t, e.Run(":0"))
+}
+
+func TestEngine_ParamSyntax(t *testing.T) {
+	e := MustNew(nil)
+	e.GET("/users/:id", func(c *types.Context) {
+		c.String(http.StatusOK, c.GetParam("id"))
+	})
+	require.NoError(t, e.Err())
+	require.Equal(t, "42", serve(e, http.MethodGet, "/users/42").Body.String())
+
+	config := DefaultConfig()
+	config.Routing.ParamSyntax = "braces"
+	require.NoError(t, config.Validate())
+
+	e = MustNew(config)
+	e.GET("/users/:id", newTestHandler("user"))
+	require.Error(t, e.Err())
+
+	config.Routing.ParamSyntax = "dollar"
+	require.Error(t, config.Validate())
+	_, err := New(config)
+	require.Error(t, err)
+}
+
+func TestEngine_Static(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>home</h1>"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.css"), []byte("body{}"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "img"), 0o755))
+
+	config := DefaultConfig()
+	config.Static.Listing = true
+	e := MustNew(config)
+	e.Static("/assets", dir)
+	e.StaticFile("/favicon.ico", filepath.Join(dir, "app.css"))
+	e.Group("/v1").StaticFS("/files", http.Dir(dir))
+	require.NoError(t, e.Err())
+
+	w := serve(e, http.MethodGet, "/assets/app.css")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "body{}", w.Body.String())
+	require.Contains(t, w.Header().Get("Content-Type"), "text/css")
+
+	w = serve(e, http.MethodGet, "/assets/")
+	require.Equal(t, "<h1>home</h1>", w.Body.String())
+
+	w = serve(e, http.MethodGet, "/assets")
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+
+	w = serve(e, http.MethodGet, "/v1/files/img/")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "<html")
+
+	w = serve(e, http.MethodHead, "/favicon.ico")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Body.String())
+
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/assets/missing.js").Code)
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/assets/../../etc/passwd").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, serve(e, http.MethodPost, "/assets/app.css").Code)
+}
+
+func TestEngine_StaticEmbed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dist/index.html": {Data: []byte("<h1>app</h1>")},
+		"dist/app.js":     {Data: []byte("console.log(1)")},
+		"secret.txt":      {Data: []byte("secret")},
+	}
+
+	e := MustNew(nil)
+	e.StaticEmbed("/", fsys, "dist")
+	require.NoError(t, e.Err())
+
+	w := serve(e, http.MethodGet, "/")
+	require.Equal(t, "<h1>app</h1>", w.Body.String())
+
+	w = serve(e, http.MethodGet, "/app.js")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotEmpty(t, w.Header().Get("ETag"))
+
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/secret.txt").Code)
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/../secret.txt").Code)
+
+	e.StaticEmbed("/other", fsys, "../dist")
+	require.Error(t, e.Err())
+}
+
+func TestEngine_FastCGI(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go fcgi.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := fcgi.ProcessEnv(r)
+		w.Write([]byte(r.Method + " " + env["SCRIPT_FILENAME"] + " " + env["REMOTE_USER"]))
+	}))
+
+	e := MustNew(nil)
+	e.Group("/php").FastCGI("/app", fastcgi.Config{
+		Address: ln.Addr().String(),
+		Root:    "/var/www",
+		Params: func(c *types.Context) map[string]string {
+			return map[string]string{"REMOTE_USER": c.Request.Header.Get("X-User")}
+		},
+	})
+	require.NoError(t, e.Err())
+
+	r := httptest.NewRequest(http.MethodPost, "/php/app/admin/users.php", nil)
+	r.Header.Set("X-User", "alice")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "POST /var/www/admin/users.php alice", w.Body.String())
+
+	w = serve(e, http.MethodGet, "/php/app")
+	require.Equal(t, "GET /var/www/index.php ", w.Body.String())
+}
+
+func TestEngine_CGI(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "echo.sh")
+	body := "#!/bin/sh\nprintf 'Content-Type: text/plain\\n\\n%s %s' \"$SCRIPT_NAME\" \"$PATH_INFO\"\n"
+	require.NoError(t, os.WriteFile(script, []byte(body), 0o755))
+
+	e := MustNew(nil)
+	e.Group("/v1").CGI("/cgi", &cgi.Handler{Path: script})
+	require.NoError(t, e.Err())
+
+	w := serve(e, http.MethodGet, "/v1/cgi/users/1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "/v1/cgi /users/1", w.Body.String())
+}
+
+func TestEngine_ACMEChallenge(t *testing.T) {
+	config := DefaultConfig()
+	config.TLS.AutoCert.Domains = []string{"example.com"}
+	e := MustNew(config)
+	e.Use(func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.ErrorString(http.StatusServiceUnavailable, "maintenance")
+		}
+	})
+	require.NoError(t, e.Err())
+	require.NotNil(t, e.CertManager())
+
+	// Challenges reach the certificate manager, which knows no token yet,
+	// while other requests run through the engine middleware
+	w := serve(e, http.MethodGet, "/.well-known/acme-challenge/token")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, http.StatusServiceUnavailable, serve(e, http.MethodGet, "/").Code)
+
+	// The plain HTTP server redirects everything else to HTTPS
+	server := e.challengeServer()
+	require.Equal(t, DefaultAutoCertHTTPAddress, server.Addr)
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com:80/a?b=c", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "https://example.com/a?b=c", w.Header().Get("Location"))
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/.well-known/acme-challenge/token", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	require.Nil(t, MustNew(nil).CertManager())
+}
+
+// certCache is an in-memory autocert.Cache
+type certCache map[string][]byte
+
+func (c certCache) Get(_ context.Context, key string) ([]byte, error) {
+	if data, ok := c[key]; ok {
+		return data, nil
+	}
+	return nil, autocert.ErrCacheMiss
+}
+
+func (c certCache) Put(_ context.Context, key string, data []byte) error {
+	c[key] = data
+	return nil
+}
+
+func (c certCache) Delete(_ context.Context, key string) error {
+	delete(c, key)
+	return nil
+}
+
+// selfSignedCert returns the autocert cache entry of a self-signed ECDSA
+// certificate for the domain: the private key followed by the certificate
+func selfSignedCert(t *testing.T, domain string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	return append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})...)
+}
+
+func TestEngine_AutoCertTLS(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.StrictFraming = true
+	config.TLS.AutoCert.Domains = []string{"example.com"}
+	config.TLS.AutoCert.HTTPAddress = "127.0.0.1:0"
+	e := MustNew(config)
+	e.CertManager().Cache = certCache{"example.com": selfSignedCert(t, "example.com")}
+
+	e.GET("/", func(c *types.Context) {
+		c.String(http.StatusOK, c.Request.Proto+" tls="+strconv.FormatBool(c.Request.TLS != nil))
+	})
+
+	ln, err := e.listen("127.0.0.1:0")
+	require.NoError(t, err)
+	go e.serve(ln)
+	defer e.Shutdown(context.Background())
+
+	// HTTP/2 is still negotiated with the framing guard
+	get := func(http2 bool) string {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"},
+			ForceAttemptHTTP2: http2,
+		}}
+		resp, err := client.Get("https://" + ln.Addr().String() + "/")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return string(body)
+	}
+	require.Equal(t, "HTTP/2.0 tls=true", get(true))
+
+	// and HTTP/1.1 requests inside TLS are validated, keeping Request.TLS
+	require.Equal(t, "HTTP/1.1 tls=true", get(false))
+	require.Zero(t, e.FramingStats().Total())
+
+	c, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"})
+	require.NoError(t, err)
+	defer c.Close()
+	io.WriteString(c, "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n"+
+		"Transfer-Encoding: chunked\r\n\r\n0\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, uint64(1), e.FramingStats().Count(guard.ReasonConflictingFraming))
+}
+
+func TestEngine_WellKnown(t *testing.T) {
+	config := DefaultConfig()
+	config.WellKnown.ChangePassword = "/account/password"
+	e := MustNew(config)
+	e.Use(func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.ErrorString(http.StatusUnauthorized, "login required")
+		}
+	})
+	e.WellKnown("openid-configuration", wellknown.JSONFunc(func(c *types.Context) (any, error) {
+		return map[string]string{"issuer": "https://" + c.Request.Host}, nil
+	}), tagMiddleware("well-known"))
+	require.NoError(t, e.Err())
+
+	w := serve(e, http.MethodGet, "/.well-known/change-password")
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "/account/password", w.Header().Get("Location"))
+
+	w = serve(e, http.MethodGet, "/.well-known/openid-configuration")
+	require.JSONEq(t, `{"issuer":"https://example.com"}`, w.Body.String())
+	require.Equal(t, "well-known", w.Header().Get("X-Trace"))
+
+	// Other paths run through the engine middleware
+	require.Equal(t, http.StatusUnauthorized, serve(e, http.MethodGet, "/.well-known/security.txt").Code)
+
+	config = DefaultConfig()
+	config.WellKnown.OAuthAuthorizationServer = map[string]any{"scopes_supported": []string{"read"}}
+	require.Error(t, MustNew(config).Err())
+}
+
+func TestEngine_RobotsNoIndex(t *testing.T) {
+	config := DefaultConfig()
+	config.Robots.NoIndex = true
+	e := MustNew(config)
+	e.GET("/", newTestHandler("home"))
+	require.NoError(t, e.Err())
+
+	w := serve(e, http.MethodGet, "/robots.txt")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "User-agent: *\nDisallow: /\n", w.Body.String())
+
+	require.Equal(t, "noindex", serve(e, http.MethodGet, "/").Header().Get("X-Robots-Tag"))
+	require.Equal(t, "noindex", serve(e, http.MethodGet, "/missing").Header().Get("X-Robots-Tag"))
+
+	e = MustNew(nil)
+	require.Empty(t, serve(e, http.MethodGet, "/").Header().Get("X-Robots-Tag"))
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/robots.txt").Code)
+}
+
+func TestEngine_SecureHeaders(t *testing.T) {
+	config := DefaultConfig()
+	config.Security.Headers = true
+	config.Security.ContentSecurityPolicy = "script-src 'nonce-{nonce}'"
+	e := MustNew(config)
+	e.GET("/", func(c *types.Context) { c.String(http.StatusOK, c.CSPNonce()) })
+
+	w := serve(e, http.MethodGet, "/")
+	require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, "script-src 'nonce-"+w.Body.String()+"'", w.Header().Get("Content-Security-Policy"))
+	require.Equal(t, "nosniff", serve(e, http.MethodGet, "/missing").Header().Get("X-Content-Type-Options"))
+
+	require.Empty(t, serve(MustNew(nil), http.MethodGet, "/").Header().Get("X-Content-Type-Options"))
+}
+
+func TestEngine_MaxMultipartMemory(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.MaxMultipartMemory = 1 << 10
+	e := MustNew(config)
+	e.POST("/upload", func(c *types.Context) {
+		c.String(http.StatusOK, strconv.FormatInt(c.MaxMultipartMemory, 10))
+	})
+	require.NoError(t, e.Err())
+
+	require.Equal(t, "1024", serve(e, http.MethodPost, "/upload").Body.String())
+}
+
+func TestEngine_Dispatch(t *testing.T) {
+	e := MustNew(nil)
+	e.Use(func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.Header("X-Middleware", "engine")
+			next(c)
+		}
+	})
+	e.POST("/users/:id", func(c *types.Context) {
+		var body struct{ Name string }
+		require.NoError(t, c.BindJSON(&body))
+		c.JSON(http.StatusCreated, map[string]string{
+			"id":     c.GetParam("id"),
+			"name":   body.Name,
+			"page":   c.GetQuery("page"),
+			"token":  c.GetHeader("X-Token"),
+			"remote": c.Request.RemoteAddr,
+		})
+		c.Header("X-Late", "ignored")
+	})
+
+	resp, err := e.Dispatch(http.MethodPost, "/users/7?page=2",
+		DispatchBody("application/json", strings.NewReader(`{"name":"ada"}`)),
+		DispatchHeader("X-Token", "abc"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.Status)
+	require.Equal(t, "engine", resp.Header.Get("X-Middleware"))
+	require.Empty(t, resp.Header.Get("X-Late"))
+	require.JSONEq(t, `{"id":"7","name":"ada","page":"2","token":"abc","remote":"127.0.0.1:0"}`, string(resp.Body))
+
+	resp, err = e.Dispatch(http.MethodGet, "/missing")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.Status)
+
+	_, err = e.Dispatch(http.MethodGet, "%zz")
+	require.Error(t, err)
+
+	e.POST("/users/:id", newTestHandler("duplicate"))
+	_, err = e.Dispatch(http.MethodGet, "/users/7")
+	require.Error(t, err)
+}
+
+func TestEngine_Events(t *testing.T) {
+	e := MustNew(nil)
+	var registered []string
+	events.Subscribe(e.Events(), func(ev events.RouteRegistered) {
+		registered = append(registered, ev.Method+" "+ev.Path)
+	})
+	var completed []events.RequestCompleted
+	events.Subscribe(e.Events(), func(ev events.RequestCompleted) { completed = append(completed, ev) })
+	var reloaded []ConfigReloaded
+	events.Subscribe(e.Events(), func(ev ConfigReloaded) { reloaded = append(reloaded, ev) })
+
+	e.GET("/users/:id", newTestHandler("user"))
+	e.Group("/admin").POST("/jobs", newTestHandler("jobs"))
+	e.GET("/users/:id", newTestHandler("duplicate"))
+	require.Equal(t, []string{"GET /users/:id", "POST /admin/jobs"}, registered)
+
+	serve(e, http.MethodGet, "/users/7")
+	serve(e, http.MethodGet, "/missing")
+	require.Len(t, completed, 2)
+	require.Equal(t, "/users/7", completed[0].Request.URL.Path)
+	require.Equal(t, http.StatusOK, completed[0].Status)
+	require.Positive(t, completed[0].Size)
+	require.Equal(t, http.StatusNotFound, completed[1].Status)
+
+	config := DefaultConfig()
+	require.NoError(t, e.ReloadConfig(config))
+	require.Len(t, reloaded, 1)
+	require.Same(t, config, reloaded[0].Config)
+}
+
+func TestEngine_WithEnv(t *testing.T) {
+	for _, env := range []string{"dev", "production", ""} {
+		config := DefaultConfig()
+		config.Environment = env
+		e := MustNew(config)
+
+		var registered []string
+		events.Subscribe(e.Events(), func(ev events.RouteRegistered) { registered = append(registered, ev.Path) })
+
+		debug := e.Group("/debug").WithEnv("dev", "staging")
+		debug.GET("/vars", newTestHandler("vars"))
+		debug.Group("/pprof").GET("/heap", newTestHandler("heap"))
+		debug.WithEnv("production").GET("/never", newTestHandler("never"))
+		e.Group("/api").GET("/users", newTestHandler("users"))
+		require.NoError(t, e.Err())
+
+		require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/api/users").Code, env)
+		require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/debug/never").Code, env)
+		if env == "dev" {
+			require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/debug/vars").Code)
+			require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/debug/pprof/heap").Code)
+			require.Equal(t, []string{"/debug/vars", "/debug/pprof/heap", "/api/users"}, registered)
+		} else {
+			require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/debug/vars").Code, env)
+			require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/debug/pprof/heap").Code, env)
+			require.Equal(t, []string{"/api/users"}, registered, env)
+		}
+	}
+}
+
+func TestEngine_LoadHTMLGlob(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	require.NoError(t, os.WriteFile(file, []byte(`<p>v1</p>`), 0o600))
+
+	for _, mode := range []string{ModeDebug, ModeRelease} {
+		config := DefaultConfig()
+		config.Mode = mode
+		e := MustNew(config)
+		require.NoError(t, os.WriteFile(file, []byte(`<p>v1</p>`), 0o600))
+		require.NoError(t, e.LoadHTMLGlob(nil, filepath.Join(dir, "*.html")))
+		e.GET("/", func(c *types.Context) { c.HTMLTemplate(http.StatusOK, "index.html", nil) })
+
+		require.NoError(t, os.WriteFile(file, []byte(`<p>{{if}}</p>`), 0o600))
+		w := serve(e, http.MethodGet, "/")
+		if mode == ModeDebug {
+			// The edit is parsed again, and its error shown
+			require.Equal(t, http.StatusInternalServerError, w.Code)
+			require.Contains(t, w.Body.String(), "Template error in index.html")
+		} else {
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, "<p>v1</p>", w.Body.String())
+		}
+	}
+
+	config := DefaultConfig()
+	config.Mode = "verbose"
+	require.Error(t, config.Validate())
+}
+
+func TestEngine_Mode(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	t.Setenv(ModeEnv, ModeDebug)
+	e := MustNew(nil)
+	require.Equal(t, ModeDebug, e.Mode())
+	e.GET("/users/:id", newTestHandler("user"))
+	require.Regexp(t, `level=DEBUG msg="Route registered" method=GET path=/users/:id handler=.*newTestHandler.* middlewares=2`, logs.String())
+
+	// The configuration takes precedence over the environment
+	config := DefaultConfig()
+	config.Mode = ModeRelease
+	logs.Reset()
+	e = MustNew(config)
+	require.Equal(t, ModeRelease, e.Mode())
+	e.GET("/users/:id", newTestHandler("user"))
+	require.Empty(t, logs.String())
+
+	require.NoError(t, e.SetMode(ModeTest))
+	require.Equal(t, ModeTest, e.Mode())
+	require.Error(t, e.SetMode("verbose"))
+	require.Equal(t, ModeTest, e.Mode())
+
+	// Invalid environments fall back to release with a warning
+	t.Setenv(ModeEnv, "verbose")
+	logs.Reset()
+	e = MustNew(nil)
+	require.Equal(t, ModeRelease, e.Mode())
+	require.Contains(t, logs.String(), `level=WARN msg="Invalid run mode, using release" error="GO_API_MODE: invalid mode \"verbose\""`)
+}
+
+func TestEngine_CORSPreflight(t *testing.T) {
+	e := MustNew(nil)
+	e.Use(middleware.CORS(middleware.CORSConfig{AllowOrigins: []string{"https://app.example.com"}}))
+	e.GET("/users", newTestHandler("users"))
+	public := e.Group("/public", middleware.CORS(middleware.CORSConfig{}))
+	public.GET("/status", newTestHandler("status"))
+	private := e.Group("/private", func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if c.GetHeader("Authorization") == "" {
+				c.Abort()
+				c.ErrorString(http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			next(c)
+		}
+	})
+	private.PUT("/account", newTestHandler("account"))
+
+	preflight := func(path, origin, method string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, path, nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	// Preflight requests are answered without OPTIONS routes
+	w := preflight("/users", "https://app.example.com", http.MethodGet)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Empty(t, preflight("/users", "https://other.io", http.MethodGet).Header().Get("Access-Control-Allow-Origin"))
+
+	// without running the middleware requiring credentials, which preflight
+	// requests do not carry
+	w = preflight("/private/account", "https://app.example.com", http.MethodPut)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, http.StatusUnauthorized, serve(e, http.MethodPut, "/private/account").Code)
+
+	// The CORS middleware of the group overrides the one of the engine for
+	// the actual requests
+	r := httptest.NewRequest(http.MethodGet, "/public/status", nil)
+	r.Header.Set("Origin", "https://other.io")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	require.Equal(t, http.StatusMethodNotAllowed, serve(e, http.MethodOptions, "/users").Code)
+
+	// Without CORS middleware, preflight requests are answered with Allow
+	e = MustNew(nil)
+	e.GET("/users", newTestHandler("users"))
+	w = preflight("/users", "https://app.example.com", http.MethodGet)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+	require.Equal(t, http.StatusNotFound, preflight("/missing", "https://app.example.com", http.MethodGet).Code)
+}
+
+func TestEngine_TrustedProxies(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.TrustedProxies = []string{"192.0.2.0/24"}
+	e := MustNew(config)
+	e.GET("/ip", func(c *types.Context) {
+		c.String(http.StatusOK, c.GetClientIP())
+	})
+
+	// httptest requests come from 192.0.2.1
+	r := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	r.Header.Set("X-Forwarded-For", "1.1.1.1, 203.0.113.9")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	require.Equal(t, "203.0.113.9", w.Body.String())
+
+	config.Server.ClientIPHeader = "X-Client-IP"
+	require.Error(t, config.Validate())
+	_, err := New(config)
+	require.Error(t, err)
+}
+
+func TestEngine_HoneypotTrustedProxies(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.TrustedProxies = []string{"192.0.2.0/24"}
+	e := MustNew(config)
+	e.Honeypot("/wp-admin")
+	e.GET("/users", newTestHandler("users"))
+
+	forwarded := func(path, ip string) *httptest.ResponseRecorder {
+		// httptest requests come from the proxy 192.0.2.1
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	forwarded("/wp-admin", "203.0.113.9")
+	require.Equal(t, http.StatusForbidden, forwarded("/users", "203.0.113.9").Code)
+	require.Equal(t, http.StatusOK, forwarded("/users", "203.0.113.10").Code)
+	require.False(t, e.DenyList().Contains("192.0.2.1"))
+}
+
+func TestEngine_Profiler(t *testing.T) {
+	config := DefaultConfig()
+	config.Mode = ModeDebug
+	config.Profiling.Enabled = true
+	e := MustNew(config)
+	api := e.Group("/api")
+	api.GET("/users/:id", newTestHandler("user"))
+	e.GET("/debug/profile", e.Profiler().Handler())
+
+	serve(e, http.MethodGet, "/api/users/1")
+	serve(e, http.MethodGet, "/api/users/2")
+	w := serve(e, http.MethodGet, "/debug/profile")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"route":"GET /api/users/:id","requests":2`)
+
+	require.Nil(t, MustNew(nil).Profiler())
+
+	config.Profiling.SampleRate = -1
+	require.Error(t, config.Validate())
+}
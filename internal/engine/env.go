@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ApplyEnv overrides the configuration with environment variables
+//
+// Variable names are derived from the yaml tags of the fields, joined with
+// underscores and upper-cased, e.g. SERVER_READ_TIMEOUT for
+// server.read_timeout. Lists are comma-separated.
+//
+// @return: an error naming every variable that could not be parsed, the
+// other variables are still applied
+// @return: an error if the configuration is invalid then, see Validate
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	if err := applyEnv(reflect.ValueOf(c).Elem(), "", lookup); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// EnvNames returns the names of the environment variables read by ApplyEnv
+func EnvNames() []string {
+	var names []string
+	collectEnvNames(reflect.TypeFor[Config](), "", &names)
+	return names
+}
+
+// applyEnv overrides the fields of a struct with environment variables
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	var errs []error
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name, ok := envName(field, prefix)
+		if !ok {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			errs = append(errs, applyEnv(v.Field(i), name, lookup))
+			continue
+		}
+
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvField(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectEnvNames appends the environment variable names of a struct
+func collectEnvNames(t reflect.Type, prefix string, names *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, ok := envName(field, prefix)
+		if !ok {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			collectEnvNames(field.Type, name, names)
+			continue
+		}
+		*names = append(*names, name)
+	}
+}
+
+// envName returns the environment variable name of a field
+//
+// @return: the name, and false for fields without a yaml name
+func envName(field reflect.StructField, prefix string) (string, bool) {
+	tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if tag == "" || tag == "-" || !field.IsExported() {
+		return "", false
+	}
+
+	name := strings.ToUpper(tag)
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	return name, true
+}
+
+// setEnvField parses an environment variable value into a field
+func setEnvField(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for item := range strings.SplitSeq(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"runtime/debug"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Finally registers a finalizer run once the response of every request is
+// complete, even when a handler panicked or the chain was aborted, e.g. to
+// return pooled resources or emit the final audit events. Finalizers run in
+// registration order, after the engine and route middleware.
+//
+// Panics of the finalizers are logged, the other finalizers still running.
+// A panic of a handler goes on once they ran.
+func (e *Engine) Finally(fn func(c *types.Context)) *Engine {
+	e.finalizers = append(e.finalizers, fn)
+	return e
+}
+
+// finalize runs the finalizers for the request
+func (e *Engine) finalize(c *types.Context) {
+	for _, fn := range e.finalizers {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					c.Logger().Error("Finalizer panicked", "panic", err, "stack", string(debug.Stack()))
+				}
+			}()
+			fn(c)
+		}()
+	}
+}
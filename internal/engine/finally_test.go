@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Finally(t *testing.T) {
+	var ran []string
+	e := MustNew(nil)
+	e.Use(func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if c.Request.URL.Path == "/denied" {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			next(c)
+		}
+	})
+	e.Finally(func(c *types.Context) { ran = append(ran, "first "+c.Request.URL.Path) })
+	e.Finally(func(c *types.Context) { panic("broken finalizer") })
+	e.Finally(func(c *types.Context) { ran = append(ran, "last "+c.Request.URL.Path) })
+	e.GET("/ok", newTestHandler("ok"))
+	e.GET("/denied", newTestHandler("ok"))
+	e.GET("/panic", func(c *types.Context) { panic("broken handler") })
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/ok").Code)
+	require.Equal(t, http.StatusForbidden, serve(e, http.MethodGet, "/denied").Code)
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/missing").Code)
+
+	// Panics of the handlers go on once the finalizers ran
+	require.PanicsWithValue(t, "broken handler", func() { serve(e, http.MethodGet, "/panic") })
+
+	require.Equal(t, []string{
+		"first /ok", "last /ok",
+		"first /denied", "last /denied",
+		"first /missing", "last /missing",
+		"first /panic", "last /panic",
+	}, ran)
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/cgi"
+	"slices"
+
+	"github.com/skjdfhkskjds/go-api/internal/fastcgi"
+	"github.com/skjdfhkskjds/go-api/internal/routes"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// RouterGroup is a set of routes sharing a path prefix and middleware
+//
+// Group middleware only runs for the routes registered in the group and
+// its nested groups, after the engine middleware. Registration errors are
+// collected by the engine, see Engine.Err.
+type RouterGroup struct {
+	engine *Engine
+	node   *routes.RouteNode
+
+	// Set when the group is restricted to other environments, its routes
+	// are then not registered, see WithEnv
+	skip bool
+}
+
+// Use adds middleware to the group
+func (g *RouterGroup) Use(middlewares ...types.MiddlewareFunc) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	g.node.Use(middlewares...)
+	return g
+}
+
+// Group creates a nested group with the specified prefix and middleware
+func (g *RouterGroup) Group(prefix string, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	return g.engine.group(g.node, prefix, middlewares...)
+}
+
+// WithEnv restricts the routes registered through the returned group to
+// the environments, e.g. "dev" and "staging" for debug endpoints. In other
+// environments, see Config.Environment, they are never registered.
+//
+// The returned group shares the prefix and the middleware of g, nested
+// groups keep the restriction.
+func (g *RouterGroup) WithEnv(envs ...string) *RouterGroup {
+	return &RouterGroup{
+		engine: g.engine,
+		node:   g.node,
+		skip:   g.skip || !slices.Contains(envs, g.engine.config.Environment),
+	}
+}
+
+// Handle registers a route for the method in the group
+func (g *RouterGroup) Handle(
+	method string,
+	path string,
+	handler types.HandlerFunc,
+	middlewares ...types.MiddlewareFunc,
+) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	g.engine.register(g.node, method, path, handler, middlewares...)
+	return g
+}
+
+// GET registers a GET route in the group
+func (g *RouterGroup) GET(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return g.Handle(http.MethodGet, path, handler, middlewares...)
+}
+
+// POST registers a POST route in the group
+func (g *RouterGroup) POST(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return g.Handle(http.MethodPost, path, handler, middlewares...)
+}
+
+// PUT registers a PUT route in the group
+func (g *RouterGroup) PUT(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return g.Handle(http.MethodPut, path, handler, middlewares...)
+}
+
+// DELETE registers a DELETE route in the group
+func (g *RouterGroup) DELETE(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return g.Handle(http.MethodDelete, path, handler, middlewares...)
+}
+
+// PATCH registers a PATCH route in the group
+func (g *RouterGroup) PATCH(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return g.Handle(http.MethodPatch, path, handler, middlewares...)
+}
+
+// OPTIONS registers an OPTIONS route in the group
+func (g *RouterGroup) OPTIONS(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return g.Handle(http.MethodOptions, path, handler, middlewares...)
+}
+
+// HEAD registers a HEAD route in the group
+func (g *RouterGroup) HEAD(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return g.Handle(http.MethodHead, path, handler, middlewares...)
+}
+
+// Static serves the files below the root directory under the path prefix
+// in the group
+func (g *RouterGroup) Static(prefix, root string) *RouterGroup {
+	return g.StaticFS(prefix, http.Dir(root))
+}
+
+// StaticFS serves the files of the file system under the path prefix in
+// the group
+func (g *RouterGroup) StaticFS(prefix string, fsys http.FileSystem) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	g.engine.static(g.node, prefix, fsys)
+	return g
+}
+
+// StaticEmbed serves the files below the root directory of an embedded file
+// system under the path prefix in the group
+func (g *RouterGroup) StaticEmbed(prefix string, fsys fs.FS, root string) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	g.engine.staticEmbed(g.node, prefix, fsys, root)
+	return g
+}
+
+// StaticFile serves a single file at the path in the group
+func (g *RouterGroup) StaticFile(path, file string) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	g.engine.staticFile(g.node, path, file)
+	return g
+}
+
+// FastCGI routes the requests below the path prefix in the group to a
+// FastCGI server
+func (g *RouterGroup) FastCGI(prefix string, config fastcgi.Config) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	g.engine.fastCGI(g.node, prefix, config)
+	return g
+}
+
+// CGI routes the requests below the path prefix in the group to a CGI
+// program
+func (g *RouterGroup) CGI(prefix string, handler *cgi.Handler) *RouterGroup {
+	if g.skip {
+		return g
+	}
+	g.engine.cgi(g.node, prefix, handler)
+	return g
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/skjdfhkskjds/go-api/internal/routes"
+)
+
+// headRoute routes HEAD requests to paths without HEAD handler to their
+// GET handler, as net/http.ServeMux does
+//
+// @return: the route, nil if the path has no GET handler
+func (e *Engine) headRoute(r *http.Request, err error) *routes.Route {
+	var methodErr *routes.MethodNotAllowedError
+	if r.Method != http.MethodHead || !errors.As(err, &methodErr) ||
+		!slices.Contains(methodErr.Allowed, http.MethodGet) {
+		return nil
+	}
+	route, err := e.routes.Find(http.MethodGet, r.URL.Path)
+	if err != nil {
+		return nil
+	}
+	return route
+}
+
+// allowedMethods returns the methods allowed on a path, HEAD included
+// when GET is
+func allowedMethods(methodErr *routes.MethodNotAllowedError) []string {
+	allowed := methodErr.Allowed
+	if slices.Contains(allowed, http.MethodGet) && !slices.Contains(allowed, http.MethodHead) {
+		allowed = append(slices.Clone(allowed), http.MethodHead)
+		slices.Sort(allowed)
+	}
+	return allowed
+}
+
+// headWriter discards the body of the responses to HEAD requests, counting
+// it so that their Content-Length is the one of the GET response
+//
+// The header is held until the handler returns or flushes, since the
+// length is only known then. Handlers writing no body, e.g. because they
+// special-case HEAD, are left without Content-Length.
+type headWriter struct {
+	http.ResponseWriter
+
+	status int
+	size   int64
+	sent   bool // the header was sent
+}
+
+// WriteHeader implements http.ResponseWriter, informational responses are
+// sent right away
+func (w *headWriter) WriteHeader(status int) {
+	if status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write implements http.ResponseWriter, counting the body
+func (w *headWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += int64(len(b))
+	return len(b), nil
+}
+
+// Flush implements http.Flusher, sending the header without
+// Content-Length since the length is not known yet
+func (w *headWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.send()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// send sends the header once
+func (w *headWriter) send() {
+	if w.sent {
+		return
+	}
+	w.sent = true
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// finish sends the header, with the Content-Length of the body written
+// unless the handler set it or the status has no body
+func (w *headWriter) finish() {
+	if w.sent || w.status == 0 {
+		return
+	}
+	header := w.Header()
+	if w.size > 0 && header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" &&
+		w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		header.Set("Content-Length", strconv.FormatInt(w.size, 10))
+	}
+	w.send()
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Logger returns the logger of the engine, which is also the base of
+// Context.Logger
+func (e *Engine) Logger() types.Logger {
+	return e.logger
+}
+
+// SetLogger replaces the logger of the engine and of Context.Logger, e.g.
+// with a *slog.Logger of the application, before serving
+func (e *Engine) SetLogger(logger types.Logger) *Engine {
+	e.logger = logger
+	return e
+}
+
+// setUpLogger creates the logger of the configuration
+func (e *Engine) setUpLogger(config LoggingConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	e.logLevel = new(slog.LevelVar)
+	e.logLevel.Set(config.level(e.mode))
+
+	var output io.Writer = stdLogWriter{}
+	switch config.Output {
+	case "":
+	case "stdout":
+		output = os.Stdout
+	case "stderr":
+		output = os.Stderr
+	default:
+		file, err := os.OpenFile(config.Output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("log output: %w", err)
+		}
+		output = file
+	}
+
+	options := &slog.HandlerOptions{Level: e.logLevel}
+	if strings.ToLower(config.Format) == "json" {
+		e.logger = slog.New(slog.NewJSONHandler(output, options))
+	} else {
+		e.logger = slog.New(slog.NewTextHandler(output, options))
+	}
+	return nil
+}
+
+// validate checks the level and the format of the configuration
+func (c LoggingConfig) validate() error {
+	if _, err := parseLogLevel(c.Level); c.Level != "" && err != nil {
+		return err
+	}
+	switch strings.ToLower(c.Format) {
+	case "", "text", "json":
+		return nil
+	}
+	return fmt.Errorf("invalid log format %q", c.Format)
+}
+
+// level returns the minimum level of the configuration, which must be
+// valid, in a run mode
+func (c LoggingConfig) level(mode string) slog.Level {
+	if c.Level == "" {
+		return defaultLogLevel(mode)
+	}
+	level, _ := parseLogLevel(c.Level)
+	return level
+}
+
+// parseLogLevel parses a level of LoggingConfig
+func parseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", level)
+	}
+	return l, nil
+}
+
+// defaultLogLevel returns the level of a run mode when not configured
+func defaultLogLevel(mode string) slog.Level {
+	if mode == ModeDebug {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// stdLogWriter writes to the current output of the standard log package,
+// so that log.SetOutput also redirects the engine's logger
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	return log.Writer().Write(p)
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/middleware"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Logging(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "app.log")
+	config := DefaultConfig()
+	config.Logging = LoggingConfig{Level: "warn", Format: "json", Output: output}
+	require.NoError(t, config.Validate())
+
+	e := MustNew(config)
+	e.Use(middleware.RequestID(middleware.RequestIDConfig{}))
+	e.GET("/users/:id", func(c *types.Context) {
+		c.Logger().Info("not logged below the level")
+		c.Logger().Warn("user lookup slow", "id", c.GetParam("id"))
+		c.String(http.StatusOK, "user")
+	})
+	w := serve(e, http.MethodGet, "/users/42")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, "WARN", entry["level"])
+	require.Equal(t, "user lookup slow", entry["msg"])
+	require.Equal(t, "42", entry["id"])
+	require.Equal(t, "/users/:id", entry["route"])
+	require.Equal(t, w.Header().Get(middleware.DefaultRequestIDHeader), entry["request_id"])
+
+	config.Logging = LoggingConfig{Level: "verbose"}
+	require.Error(t, config.Validate())
+	config.Logging = LoggingConfig{Format: "xml"}
+	require.Error(t, config.Validate())
+	_, err = New(config)
+	require.Error(t, err)
+}
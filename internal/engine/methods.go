@@ -1,42 +1,379 @@
 package engine
 
 import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/cgi"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/events"
+	"github.com/skjdfhkskjds/go-api/internal/fastcgi"
+	"github.com/skjdfhkskjds/go-api/internal/idgen"
+	"github.com/skjdfhkskjds/go-api/internal/middleware"
+	"github.com/skjdfhkskjds/go-api/internal/oauth"
 	"github.com/skjdfhkskjds/go-api/internal/routes"
+	"github.com/skjdfhkskjds/go-api/internal/static"
 	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/skjdfhkskjds/go-api/internal/useragent"
+	"github.com/skjdfhkskjds/go-api/internal/wellknown"
 )
 
+// Handle registers a route for the method
+func (e *Engine) Handle(method, path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, method, path, handler, middlewares...)
+	return e
+}
+
+// Use adds middleware that runs for every request handled by the engine,
+// including requests that match no route
+//
+// The middleware is compiled into the chains of the routes, ahead of
+// their own, recompiling those already registered.
+func (e *Engine) Use(middlewares ...types.MiddlewareFunc) *Engine {
+	e.middlewares = append(e.middlewares, middlewares...)
+	e.routes.Use(middlewares...)
+	return e
+}
+
 // GET registers a GET route
-func (e *Engine) GET(path string, handler types.HandlerFunc) *Engine {
-	e.routes.GET(path, handler)
+func (e *Engine) GET(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, http.MethodGet, path, handler, middlewares...)
 	return e
 }
 
 // POST registers a POST route
-func (e *Engine) POST(path string, handler types.HandlerFunc) *Engine {
-	e.routes.POST(path, handler)
+func (e *Engine) POST(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, http.MethodPost, path, handler, middlewares...)
 	return e
 }
 
 // PUT registers a PUT route
-func (e *Engine) PUT(path string, handler types.HandlerFunc) *Engine {
-	e.routes.PUT(path, handler)
+func (e *Engine) PUT(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, http.MethodPut, path, handler, middlewares...)
 	return e
 }
 
 // DELETE registers a DELETE route
-func (e *Engine) DELETE(path string, handler types.HandlerFunc) *Engine {
-	e.routes.DELETE(path, handler)
+func (e *Engine) DELETE(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, http.MethodDelete, path, handler, middlewares...)
 	return e
 }
 
 // PATCH registers a PATCH route
-func (e *Engine) PATCH(path string, handler types.HandlerFunc) *Engine {
-	e.routes.PATCH(path, handler)
+func (e *Engine) PATCH(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, http.MethodPatch, path, handler, middlewares...)
+	return e
+}
+
+// Static serves the files below the root directory under the path prefix,
+// e.g. e.Static("/assets", "./public")
+func (e *Engine) Static(prefix, root string) *Engine {
+	return e.StaticFS(prefix, http.Dir(root))
+}
+
+// StaticFS serves the files of the file system under the path prefix
+//
+// Directories are served by their index file or, when enabled in the
+// configuration, a directory listing.
+func (e *Engine) StaticFS(prefix string, fsys http.FileSystem) *Engine {
+	e.static(e.routes, prefix, fsys)
+	return e
+}
+
+// StaticEmbed serves the files below the root directory of an embedded file
+// system under the path prefix, e.g. e.StaticEmbed("/assets", dist, "dist")
+//
+// Embedded files carry no modification time, so their ETags are derived
+// from their content.
+func (e *Engine) StaticEmbed(prefix string, fsys fs.FS, root string) *Engine {
+	e.staticEmbed(e.routes, prefix, fsys, root)
+	return e
+}
+
+// StaticFile serves a single file at the path, e.g.
+// e.StaticFile("/favicon.ico", "./public/favicon.ico")
+func (e *Engine) StaticFile(path, file string) *Engine {
+	e.staticFile(e.routes, path, file)
+	return e
+}
+
+// commonMethods are the methods decoy routes and gateway routes, e.g.
+// FastCGI, respond to
+var commonMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// Honeypot registers decoy routes, e.g. /wp-admin, for every common method
+//
+// Clients hitting them are logged, added to the engine's deny list and,
+// when configured, tarpitted. The first call installs an IP filter on the
+// engine that rejects the denied clients. Clients are identified by the
+// IP read from the forwarding headers of the engine's trusted proxies.
+func (e *Engine) Honeypot(paths ...string) *Engine {
+	handler := middleware.Honeypot(middleware.HoneypotConfig{
+		Tarpit:   time.Duration(e.config.Honeypot.Tarpit) * time.Second,
+		DenyList: e.DenyList(),
+		DenyTTL:  time.Duration(e.config.Honeypot.DenyDuration) * time.Second,
+		ClientIP: middleware.ClientIP,
+	})
+
+	for _, path := range paths {
+		for _, method := range commonMethods {
+			e.register(e.routes, method, path, handler)
+		}
+	}
+	return e
+}
+
+// DenyList returns the engine's dynamic deny list, installing the IP
+// filter enforcing it on first use
+func (e *Engine) DenyList() *middleware.DenyList {
+	if e.denyList == nil {
+		e.denyList = middleware.NewDenyList()
+		e.Use(middleware.IPFilter(middleware.IPFilterConfig{
+			DenyList: e.denyList,
+			ClientIP: middleware.ClientIP,
+		}))
+	}
+	return e.denyList
+}
+
+// OPTIONS registers an OPTIONS route
+func (e *Engine) OPTIONS(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, http.MethodOptions, path, handler, middlewares...)
+	return e
+}
+
+// HEAD registers a HEAD route
+func (e *Engine) HEAD(path string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	e.register(e.routes, http.MethodHead, path, handler, middlewares...)
+	return e
+}
+
+// SetClientParser replaces the parser used by Context.Client
+func (e *Engine) SetClientParser(parser useragent.Parser) *Engine {
+	e.clientParser = parser
+	return e
+}
+
+// SetIDGenerator replaces the generator of Context.NewID, e.g. with ids of
+// the database
+func (e *Engine) SetIDGenerator(generator idgen.Generator) *Engine {
+	e.idGenerator = generator
+	return e
+}
+
+// Profiler returns the profiler of the route handlers, nil unless
+// profiling is enabled, e.g. to serve its report on a debug route:
+//
+//	e.GET("/debug/profile", e.Profiler().Handler())
+//
+// Only the handlers of the routes registered in debug mode are measured.
+func (e *Engine) Profiler() *middleware.Profiler {
+	return e.profiler
+}
+
+// OAuth registers the login, callback and logout routes of the provider,
+// protect routes with provider.Require
+func (e *Engine) OAuth(provider *oauth.Provider) *Engine {
+	for _, route := range provider.Routes() {
+		e.register(e.routes, route.Method, route.Path, route.Handler)
+	}
+	return e
+}
+
+// Group creates a route group with the specified prefix and middleware
+func (e *Engine) Group(prefix string, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	return e.group(e.routes, prefix, middlewares...)
+}
+
+// Err returns the errors encountered while registering routes, if any
+//
+// Run refuses to start the server while registration errors exist.
+func (e *Engine) Err() error {
+	return errors.Join(e.errs...)
+}
+
+// group creates a route group below the parent node
+func (e *Engine) group(parent *routes.RouteNode, prefix string, middlewares ...types.MiddlewareFunc) *RouterGroup {
+	node, err := parent.Group(prefix, middlewares...)
+	if err != nil {
+		e.errs = append(e.errs, err)
+		// Keep the group usable, its routes are simply not reachable
+		node = routes.NewRouteNode(prefix, routes.RouteTypeStatic, "", nil)
+	}
+	return &RouterGroup{engine: e, node: node}
+}
+
+// FastCGI routes the requests below the path prefix to a FastCGI server,
+// e.g. php-fpm, for every common method
+func (e *Engine) FastCGI(prefix string, config fastcgi.Config) *Engine {
+	e.fastCGI(e.routes, prefix, config)
+	return e
+}
+
+// CGI routes the requests below the path prefix to a CGI program, for
+// every common method. The prefix is used as the root of the handler.
+func (e *Engine) CGI(prefix string, handler *cgi.Handler) *Engine {
+	e.cgi(e.routes, prefix, handler)
 	return e
 }
 
-// Group creates a route group with the specified prefix
-func (e *Engine) Group(prefix string) *routes.RouteNode {
-	group, _ := e.routes.Group(prefix)
-	return group
+// WellKnown serves a document below /.well-known/, e.g.
+// WellKnown("security.txt", handler), for GET and HEAD requests
+//
+// Well-known routes skip the engine middleware, only the given middleware
+// runs, so that documents stay reachable whatever authentication or
+// maintenance middleware the engine uses.
+func (e *Engine) WellKnown(name string, handler types.HandlerFunc, middlewares ...types.MiddlewareFunc) *Engine {
+	path := wellknown.Prefix + strings.TrimPrefix(name, "/")
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		e.register(e.wellKnown, method, path, handler, middlewares...)
+	}
+	return e
+}
+
+// wellKnownDocuments registers the well-known documents of the
+// configuration
+func (e *Engine) wellKnownDocuments(config *wellknown.Config) {
+	documents, err := config.Documents()
+	if err != nil {
+		e.errs = append(e.errs, err)
+		return
+	}
+	for name, handler := range documents {
+		e.WellKnown(name, handler)
+	}
+}
+
+// robotsDenyAll registers the /robots.txt disallowing every crawler
+func (e *Engine) robotsDenyAll() {
+	handler := func(c *types.Context) {
+		c.String(http.StatusOK, "User-agent: *\nDisallow: /\n")
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		e.register(e.routes, method, "/robots.txt", handler)
+	}
+}
+
+// staticParam is the wildcard parameter holding the requested file path
+const staticParam = "filepath"
+
+// static registers GET and HEAD routes serving the file system below the
+// node, both at the prefix itself and for every path below it
+func (e *Engine) static(node *routes.RouteNode, prefix string, fsys http.FileSystem) {
+	server := static.NewServer(fsys, e.config.static())
+	handler := func(c *types.Context) {
+		if err := server.Serve(c.Writer, c.Request, c.GetParam(staticParam)); err != nil {
+			status := static.ErrorStatus(err)
+			c.ErrorString(status, http.StatusText(status))
+		}
+	}
+
+	pattern := strings.TrimSuffix(prefix, "/") + "/*" + staticParam
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		e.register(node, method, prefix, handler)
+		e.register(node, method, pattern, handler)
+	}
+}
+
+// staticEmbed registers the routes serving the root directory of the file
+// system below the node
+func (e *Engine) staticEmbed(node *routes.RouteNode, prefix string, fsys fs.FS, root string) {
+	sub, err := fs.Sub(fsys, root)
+	if err != nil {
+		e.errs = append(e.errs, err)
+		return
+	}
+	e.static(node, prefix, http.FS(sub))
+}
+
+// staticFile registers GET and HEAD routes serving a single file below
+// the node
+func (e *Engine) staticFile(node *routes.RouteNode, path, file string) {
+	dir, name := filepath.Split(file)
+	fsys := http.Dir(dir)
+	handler := func(c *types.Context) {
+		if err := static.ServeFile(c.Writer, c.Request, fsys, name, nil); err != nil {
+			status := static.ErrorStatus(err)
+			c.ErrorString(status, http.StatusText(status))
+		}
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		e.register(node, method, path, handler)
+	}
+}
+
+// fastCGI registers the routes sending the requests below the prefix to a
+// FastCGI server
+func (e *Engine) fastCGI(node *routes.RouteNode, prefix string, config fastcgi.Config) {
+	server := fastcgi.NewHandler(config)
+	e.gateway(node, prefix, func(c *types.Context) {
+		server.Handle(c, c.GetParam(staticParam))
+	})
+}
+
+// cgi registers the routes sending the requests below the prefix to a CGI
+// program
+func (e *Engine) cgi(node *routes.RouteNode, prefix string, handler *cgi.Handler) {
+	h := *handler
+	h.Root = strings.TrimSuffix(node.Path(), "/") + strings.TrimSuffix(prefix, "/")
+	e.gateway(node, prefix, func(c *types.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	})
+}
+
+// gateway registers a handler for every common method, both at the prefix
+// itself and for every path below it
+func (e *Engine) gateway(node *routes.RouteNode, prefix string, handler types.HandlerFunc) {
+	pattern := strings.TrimSuffix(prefix, "/") + "/*" + staticParam
+	for _, method := range commonMethods {
+		e.register(node, method, prefix, handler)
+		e.register(node, method, pattern, handler)
+	}
+}
+
+// register adds a route below the node, recording any error
+func (e *Engine) register(
+	node *routes.RouteNode,
+	method string,
+	path string,
+	handler types.HandlerFunc,
+	middlewares ...types.MiddlewareFunc,
+) {
+	routeHandler := handler
+	if e.profiler != nil && e.IsDebug() {
+		routeHandler = e.profiler.Middleware()(handler)
+	}
+	child, err := node.Route(method, path, routeHandler, middlewares...)
+	if err != nil {
+		e.errs = append(e.errs, err)
+		return
+	}
+	events.Publish(&e.events, events.RouteRegistered{Method: method, Path: child.Path()})
+
+	if e.IsDebug() {
+		e.logger.Debug("Route registered", "method", method, "path", child.Path(),
+			"handler", handlerName(handler), "middlewares", len(e.middlewares)+len(middlewares))
+	}
+}
+
+// handlerName returns the name of the function of a handler
+func handlerName(handler types.HandlerFunc) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "?"
 }
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+
+	"github.com/skjdfhkskjds/go-api/internal/templates"
+)
+
+// Run modes of the engine, see Config.Mode
+//
+// Debug logs the registered routes, reloads templates and details errors,
+// including the stack trace of recovered panics. Release stays quiet and
+// caches what it can, and test behaves like release while enabling the
+// testing aids, see Context.Development.
+const (
+	ModeRelease = "release"
+	ModeDebug   = "debug"
+	ModeTest    = "test"
+)
+
+// ModeEnv is the environment variable selecting the run mode when the
+// configuration does not, e.g. GO_API_MODE=debug
+const ModeEnv = "GO_API_MODE"
+
+// parseMode validates a run mode, release if empty
+func parseMode(mode string) (string, error) {
+	switch mode {
+	case "", ModeRelease:
+		return ModeRelease, nil
+	case ModeDebug, ModeTest:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid mode %q", mode)
+}
+
+// Mode returns the run mode of the engine
+func (e *Engine) Mode() string {
+	return e.mode
+}
+
+// SetMode changes the run mode of the engine, before registering routes
+// and loading templates, which depend on it, as does the default level of
+// the logger
+//
+// @return: an error if the mode is invalid
+func (e *Engine) SetMode(mode string) error {
+	mode, err := parseMode(mode)
+	if err != nil {
+		return err
+	}
+	e.mode = mode
+	if e.logLevel != nil {
+		e.logLevel.Set(e.Config().Logging.level(mode))
+	}
+	return nil
+}
+
+// IsDebug reports whether the engine runs in debug mode
+func (e *Engine) IsDebug() bool {
+	return e.mode == ModeDebug
+}
+
+// LoadHTMLGlob loads the templates matching the patterns, rendered with
+// Context.HTMLTemplate, e.g. "templates/*.html"
+//
+// In debug mode, the templates are parsed again on every request, so that
+// edits show up without restarting, and their errors are shown in detail.
+// They are parsed once otherwise.
+//
+// @return: an error if the templates could not be parsed
+func (e *Engine) LoadHTMLGlob(funcs template.FuncMap, patterns ...string) error {
+	set, err := templates.New(templates.Config{Patterns: patterns, Funcs: funcs, Reload: e.IsDebug()})
+	if err != nil {
+		return err
+	}
+	e.templates = set
+	return nil
+}
+
+// envMode returns the run mode of ModeEnv, for configurations not setting
+// one
+//
+// @return: release and an error if the mode is invalid, e.g. to be logged
+// as a warning
+func envMode() (string, error) {
+	mode, err := parseMode(os.Getenv(ModeEnv))
+	if err != nil {
+		return ModeRelease, fmt.Errorf("%s: %w", ModeEnv, err)
+	}
+	return mode, nil
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/health"
+)
+
+// DefaultPreflightTimeout bounds every preflight check
+const DefaultPreflightTimeout = 10 * time.Second
+
+// PreflightResult is the outcome of a preflight check
+type PreflightResult struct {
+	Name     string        `json:"name"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// PreflightReport is the outcome of Engine.Preflight
+type PreflightReport struct {
+	OK     bool              `json:"ok"`
+	Checks []PreflightResult `json:"checks"`
+}
+
+// String returns the diagnostic summary of the report, one line per check
+func (r *PreflightReport) String() string {
+	var b strings.Builder
+	for _, check := range r.Checks {
+		status := "ok"
+		if check.Error != "" {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%-4s %s (%s)", status, check.Name, check.Duration.Round(time.Millisecond))
+		if check.Error != "" {
+			fmt.Fprintf(&b, ": %s", check.Error)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// preflightCheck is a check registered with Engine.PreflightCheck
+type preflightCheck struct {
+	name    string
+	checker health.Checker
+}
+
+// PreflightCheck registers a check run by Engine.Preflight, e.g. that the
+// database is reachable or that its migrations were applied
+func (e *Engine) PreflightCheck(name string, checker health.Checker) {
+	e.preflight = append(e.preflight, preflightCheck{name: name, checker: checker})
+}
+
+// Preflight checks that the engine is ready to serve, before listening:
+// the configuration is valid, the routes were registered, the TLS material
+// is usable and the registered checks pass. The checks run in order, each
+// within DefaultPreflightTimeout, and their outcome is logged.
+//
+// The caller decides what failures mean, e.g. main exiting with status 1
+// so that broken deployments fail fast.
+//
+// @return: the report of every check
+// @return: an error joining the failures of the checks
+func (e *Engine) Preflight(ctx context.Context) (*PreflightReport, error) {
+	checks := []preflightCheck{
+		{name: "config", checker: health.CheckerFunc(func(context.Context) error { return e.config.Validate() })},
+		{name: "routes", checker: health.CheckerFunc(func(context.Context) error { return e.Err() })},
+	}
+	if len(e.config.TLS.AutoCert.Domains) > 0 {
+		checks = append(checks, preflightCheck{name: "tls", checker: health.CheckerFunc(func(context.Context) error {
+			return checkAutoCert(&e.config.TLS.AutoCert)
+		})})
+	}
+	checks = append(checks, e.preflight...)
+
+	report := &PreflightReport{OK: true}
+	var errs []error
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(ctx, DefaultPreflightTimeout)
+		start := time.Now()
+		err := check.checker.Check(ctx)
+		cancel()
+
+		result := PreflightResult{Name: check.name, Duration: time.Since(start)}
+		if err != nil {
+			result.Error = err.Error()
+			report.OK = false
+			errs = append(errs, fmt.Errorf("preflight %s: %w", check.name, err))
+			e.logger.Error("Preflight check failed", "check", check.name, "duration", result.Duration, "error", err)
+		} else {
+			e.logger.Info("Preflight check passed", "check", check.name, "duration", result.Duration)
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report, errors.Join(errs...)
+}
+
+// checkAutoCert verifies that the certificates of the configuration can
+// be obtained and stored
+func checkAutoCert(config *AutoCertConfig) error {
+	for _, domain := range config.Domains {
+		if domain == "" || strings.ContainsAny(domain, "/:*") {
+			return fmt.Errorf("invalid autocert domain %q", domain)
+		}
+	}
+	if config.HTTPAddress != "" {
+		if _, _, err := net.SplitHostPort(config.HTTPAddress); err != nil {
+			return fmt.Errorf("invalid autocert HTTP address: %w", err)
+		}
+	}
+
+	// Certificates that cannot be cached are requested again on every
+	// start, quickly hitting the rate limits of the CA
+	if config.CacheDir != "" {
+		if err := os.MkdirAll(config.CacheDir, 0o700); err != nil {
+			return fmt.Errorf("autocert cache: %w", err)
+		}
+		file, err := os.CreateTemp(config.CacheDir, ".preflight-*")
+		if err != nil {
+			return fmt.Errorf("autocert cache is not writable: %w", err)
+		}
+		file.Close()
+		os.Remove(file.Name())
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Preflight(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	e := MustNew(nil)
+	var order []string
+	e.PreflightCheck("database", health.CheckerFunc(func(ctx context.Context) error {
+		order = append(order, "database")
+		_, ok := ctx.Deadline()
+		require.True(t, ok)
+		return nil
+	}))
+	e.PreflightCheck("migrations", health.CheckerFunc(func(context.Context) error {
+		order = append(order, "migrations")
+		return nil
+	}))
+
+	report, err := e.Preflight(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.OK)
+	require.Equal(t, []string{"database", "migrations"}, order)
+	require.Len(t, report.Checks, 4)
+	require.Regexp(t, `^ok   config \(\d+m?s\)\nok   routes`, report.String())
+
+	// Every failure is reported
+	config := DefaultConfig()
+	config.TLS.AutoCert.Domains = []string{"example.com"}
+	config.TLS.AutoCert.CacheDir = filepath.Join(t.TempDir(), "certs")
+	config.Mode = ModeTest
+	e = MustNew(config)
+	e.GET("/", newTestHandler("first"))
+	e.GET("/", newTestHandler("duplicate"))
+	e.PreflightCheck("migrations", health.CheckerFunc(func(context.Context) error {
+		return errors.New("2 migrations pending")
+	}))
+	config.Server.Port = 0
+
+	report, err = e.Preflight(context.Background())
+	require.Error(t, err)
+	require.ErrorContains(t, err, "preflight config: invalid server port: 0")
+	require.ErrorContains(t, err, "preflight migrations: 2 migrations pending")
+	require.False(t, report.OK)
+	require.Equal(t, []string{"config", "routes", "tls", "migrations"}, []string{
+		report.Checks[0].Name, report.Checks[1].Name, report.Checks[2].Name, report.Checks[3].Name,
+	})
+	require.NotEmpty(t, report.Checks[1].Error)
+	require.Empty(t, report.Checks[2].Error)
+	require.DirExists(t, config.TLS.AutoCert.CacheDir)
+	require.Contains(t, report.String(), "FAIL migrations")
+
+	// Failures are returned in release mode too, for main to decide
+	require.NoError(t, e.SetMode(ModeRelease))
+	report, err = e.Preflight(context.Background())
+	require.Error(t, err)
+	require.False(t, report.OK)
+}
+
+func TestCheckAutoCert(t *testing.T) {
+	require.NoError(t, checkAutoCert(&AutoCertConfig{Domains: []string{"example.com"}, HTTPAddress: ":8080"}))
+	require.Error(t, checkAutoCert(&AutoCertConfig{Domains: []string{"https://example.com"}}))
+	require.Error(t, checkAutoCert(&AutoCertConfig{Domains: []string{"example.com"}, HTTPAddress: "80"}))
+
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	require.Error(t, checkAutoCert(&AutoCertConfig{Domains: []string{"example.com"}, CacheDir: file}))
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// RedirectRule redirects the requests of a path, served before routing
+// with the engine middleware, see Config.Redirects
+type RedirectRule struct {
+	// From is the redirected path, exact or a pattern of :name segments
+	// and a final *name segment, e.g. /blog/:year/:slug or /docs/*page
+	From string `yaml:"from"`
+
+	// To is the target, a path or a URL, where the parameters of From are
+	// replaced, e.g. /articles/:slug. The query of the request is kept
+	// unless To has one.
+	To string `yaml:"to"`
+
+	// Status of the redirect: 301 if 0, 302, 303, 307 or 308
+	Status int `yaml:"status"`
+}
+
+// redirects matches the requests against the rules
+type redirects struct {
+	exact    map[string]*redirectRule
+	patterns []*redirectRule
+}
+
+// redirectRule is a parsed rule
+type redirectRule struct {
+	RedirectRule
+	segments []string // of From
+}
+
+// newRedirects parses the rules, exact paths taking precedence over
+// patterns and patterns matching in order
+//
+// @return: nil without rules
+func newRedirects(rules []RedirectRule) (*redirects, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	r := &redirects{exact: make(map[string]*redirectRule)}
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.From, "/") || rule.To == "" {
+			return nil, fmt.Errorf("redirect %q: from must be a path and to must be set", rule.From)
+		}
+		switch rule.Status {
+		case 0:
+			rule.Status = http.StatusMovedPermanently
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return nil, fmt.Errorf("redirect %q: invalid status %d", rule.From, rule.Status)
+		}
+
+		parsed := &redirectRule{RedirectRule: rule, segments: strings.Split(rule.From[1:], "/")}
+		params := map[string]bool{}
+		for i, segment := range parsed.segments {
+			if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+				if strings.HasPrefix(segment, "*") && i != len(parsed.segments)-1 {
+					return nil, fmt.Errorf("redirect %q: wildcard must be the last segment", rule.From)
+				}
+				params[segment[1:]] = true
+			}
+		}
+		for _, segment := range strings.Split(rule.To, "/") {
+			if strings.HasPrefix(segment, ":") && !params[segment[1:]] {
+				return nil, fmt.Errorf("redirect %q: unknown parameter %s in %q", rule.From, segment, rule.To)
+			}
+		}
+
+		if len(params) == 0 {
+			if _, ok := r.exact[rule.From]; ok {
+				return nil, fmt.Errorf("redirect %q: duplicate", rule.From)
+			}
+			r.exact[rule.From] = parsed
+		} else {
+			r.patterns = append(r.patterns, parsed)
+		}
+	}
+	return r, nil
+}
+
+// find returns the handler redirecting the request, nil if no rule
+// matches
+func (r *redirects) find(req *http.Request) types.HandlerFunc {
+	path := req.URL.Path
+	if rule, ok := r.exact[path]; ok {
+		return rule.handler(rule.To)
+	}
+
+	// Paths not starting with a slash, e.g. the empty path of CONNECT
+	// requests, match no pattern
+	if path == "" || path[0] != '/' {
+		return nil
+	}
+	segments := strings.Split(path[1:], "/")
+	for _, rule := range r.patterns {
+		if params, ok := rule.match(segments); ok {
+			if target, ok := rule.target(params); ok {
+				return rule.handler(target)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// match matches the segments of a path against the rule
+//
+// @return: the parameters of the path
+func (r *redirectRule) match(segments []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, segment := range r.segments {
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			if i >= len(segments) {
+				return nil, false
+			}
+			params[segment[1:]] = strings.Join(segments[i:], "/")
+			return params, true
+		case i >= len(segments):
+			return nil, false
+		case strings.HasPrefix(segment, ":"):
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[segment[1:]] = segments[i]
+		case segment != segments[i]:
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(r.segments)
+}
+
+// target returns the target of the rule with the parameters, escaped
+//
+// @return: false if the target would leave the site, e.g. //evil.com from
+// the empty segments of a wildcard
+func (r *redirectRule) target(params map[string]string) (string, bool) {
+	segments := strings.Split(r.To, "/")
+	for i, segment := range segments {
+		if value, ok := params[strings.TrimPrefix(segment, ":")]; ok && strings.HasPrefix(segment, ":") {
+			// Wildcards span segments, whose separators are kept
+			escaped := strings.Split(value, "/")
+			for j := range escaped {
+				escaped[j] = url.PathEscape(escaped[j])
+			}
+			segments[i] = strings.Join(escaped, "/")
+		}
+	}
+	target := strings.Join(segments, "/")
+	if strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "", false
+	}
+	return target, true
+}
+
+// handler returns the handler redirecting to the target, with the query
+// of the request
+func (r *redirectRule) handler(target string) types.HandlerFunc {
+	return func(c *types.Context) {
+		if query := c.Request.URL.RawQuery; query != "" && !strings.Contains(target, "?") {
+			target += "?" + query
+		}
+		c.Redirect(r.Status, target)
+	}
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Redirects(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(`
+redirects:
+  - from: /summer-sale
+    to: https://shop.example.com/sale?utm_source=site
+    status: 302
+  - from: /blog/:year/:slug
+    to: /articles/:slug
+  - from: /docs/*page
+    to: /manual/:page
+    status: 308
+  - from: /go/*target
+    to: /:target
+`), 0o644))
+	config, err := LoadConfig(filename)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+
+	e := MustNew(config)
+	e.Use(tagMiddleware("engine"))
+	e.GET("/blog/:year/:slug", newTestHandler("post"))
+
+	w := serve(e, http.MethodGet, "/summer-sale?ref=mail")
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "https://shop.example.com/sale?utm_source=site", w.Header().Get("Location"))
+	require.Equal(t, []string{"engine"}, w.Header().Values("X-Trace"))
+
+	// Redirects take precedence over the routes, keeping the query
+	w = serve(e, http.MethodGet, "/blog/2024/hello?page=2")
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "/articles/hello?page=2", w.Header().Get("Location"))
+
+	w = serve(e, http.MethodPost, "/docs/guide/install")
+	require.Equal(t, http.StatusPermanentRedirect, w.Code)
+	require.Equal(t, "/manual/guide/install", w.Header().Get("Location"))
+
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/blog/2024").Code)
+
+	// Parameters are escaped, and targets cannot leave the site
+	w = serve(e, http.MethodGet, "/blog/2024/a%3Fb%20c")
+	require.Equal(t, "/articles/a%3Fb%20c", w.Header().Get("Location"))
+	w = serve(e, http.MethodGet, "/go/%5Cevil.com")
+	require.Equal(t, "/%5Cevil.com", w.Header().Get("Location"))
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/go//evil.com").Code)
+	require.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/go/%2F%2Fevil.com").Code)
+
+	// Authority-form CONNECT requests have an empty path
+	r := httptest.NewRequest(http.MethodConnect, "example.com:443", nil)
+	require.Empty(t, r.URL.Path)
+	w = httptest.NewRecorder()
+	require.NotPanics(t, func() { e.ServeHTTP(w, r) })
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// Reloaded configurations replace the redirects
+	reloaded := DefaultConfig()
+	require.NoError(t, e.ReloadConfig(reloaded))
+	require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/blog/2024/hello").Code)
+}
+
+func TestNewRedirects_Errors(t *testing.T) {
+	for _, rules := range [][]RedirectRule{
+		{{From: "old", To: "/new"}},
+		{{From: "/old"}},
+		{{From: "/old", To: "/new", Status: 200}},
+		{{From: "/old/*path/edit", To: "/new"}},
+		{{From: "/old/:id", To: "/new/:name"}},
+		{{From: "/old", To: "/new"}, {From: "/old", To: "/newer"}},
+	} {
+		_, err := newRedirects(rules)
+		require.Error(t, err, rules)
+	}
+}
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/events"
+)
+
+// DefaultConfigPollInterval is how often WatchConfig checks the
+// configuration file when no interval is given
+const DefaultConfigPollInterval = 2 * time.Second
+
+// ConfigReloadFunc is called after a configuration was applied while
+// serving, with the previous and the new configuration
+type ConfigReloadFunc func(previous, config *Config)
+
+// ConfigReloaded is published on the events of the engine when a
+// configuration was reloaded, see Engine.Events
+type ConfigReloaded struct {
+	Previous *Config
+	Config   *Config
+}
+
+// OnConfigReload registers a hook called after every reload, e.g. to adjust
+// the log level of the application. Hooks run in registration order.
+func (e *Engine) OnConfigReload(hook ConfigReloadFunc) *Engine {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+	e.reloads = append(e.reloads, hook)
+	return e
+}
+
+// Config returns the configuration in effect, the last one reloaded if any
+func (e *Engine) Config() *Config {
+	return e.runtime.Load()
+}
+
+// ReloadConfig applies the settings of a configuration that are safe to
+// change while serving:
+//   - the read and write timeouts, for the requests starting afterwards
+//   - the request limits and the rate limit
+//   - the log level
+//   - the redirects
+//
+// The other settings, e.g. the port or the static file options, keep their
+// value until the engine is restarted.
+//
+// @return: an error if the configuration is invalid, nothing is applied
+// then
+func (e *Engine) ReloadConfig(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("reloading config: %w", err)
+	}
+	redirects, _ := newRedirects(config.Redirects) // validated
+
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	e.limiter.Update(config.limits())
+	e.rateLimiter.Update(config.RateLimit.rateLimit())
+	e.logLevel.Set(config.Logging.level(e.mode))
+	e.redirects.Store(redirects)
+	if config.Server.ReadTimeout != e.config.Server.ReadTimeout ||
+		config.Server.WriteTimeout != e.config.Server.WriteTimeout {
+		e.timeouts.Store(&timeouts{
+			read:  time.Duration(config.Server.ReadTimeout) * time.Second,
+			write: time.Duration(config.Server.WriteTimeout) * time.Second,
+		})
+	} else {
+		e.timeouts.Store(nil)
+	}
+
+	previous := e.runtime.Swap(config)
+	for _, hook := range e.reloads {
+		hook(previous, config)
+	}
+	events.Publish(&e.events, ConfigReloaded{Previous: previous, Config: config})
+	return nil
+}
+
+// WatchConfig polls the configuration file and reloads it with
+// LoadConfigWithEnv when it changes, every DefaultConfigPollInterval if the
+// interval is 0. Invalid configurations are logged and the configuration in
+// effect is kept.
+//
+// @return: a function stopping the watcher
+func (e *Engine) WatchConfig(filename string, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultConfigPollInterval
+	}
+
+	done := make(chan struct{})
+	last, _ := os.Stat(filename)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			// A missing file is usually being replaced, e.g. by an editor,
+			// rather than meant to restore the defaults
+			info, err := os.Stat(filename)
+			if err != nil {
+				continue
+			}
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+
+			config, err := LoadConfigWithEnv(filename)
+			if err == nil {
+				err = e.ReloadConfig(config)
+			}
+			if err != nil {
+				e.logger.Error("Config not reloaded", "file", filename, "error", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// timeouts are the reloaded server timeouts, applied to every request
+type timeouts struct {
+	read  time.Duration
+	write time.Duration
+}
+
+// apply sets the deadlines of the connection of a request, when supported
+func (t *timeouts) apply(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	now := time.Now()
+	if t.read > 0 {
+		rc.SetReadDeadline(now.Add(t.read))
+	}
+	if t.write > 0 {
+		rc.SetWriteDeadline(now.Add(t.write))
+	}
+}
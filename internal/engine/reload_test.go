@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_ReloadConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.MaxURLLength = 0
+	e := MustNew(config)
+	e.GET("/*path", newTestHandler("ok"))
+
+	var reloads []*Config
+	e.OnConfigReload(func(previous, config *Config) {
+		require.Same(t, e.Config(), config)
+		reloads = append(reloads, previous)
+	})
+
+	long := "/" + strings.Repeat("a", 100)
+	require.Equal(t, http.StatusOK, serve(e, http.MethodGet, long).Code)
+
+	reloaded := DefaultConfig()
+	reloaded.Server.MaxURLLength = 64
+	reloaded.Server.WriteTimeout = 30
+	require.NoError(t, e.ReloadConfig(reloaded))
+	require.Equal(t, http.StatusRequestURITooLong, serve(e, http.MethodGet, long).Code)
+	require.Equal(t, []*Config{config}, reloads)
+	require.Equal(t, 30*time.Second, e.timeouts.Load().write)
+
+	// Invalid configurations are not applied
+	invalid := DefaultConfig()
+	invalid.Server.ReadTimeout = 0
+	require.Error(t, e.ReloadConfig(invalid))
+	require.Same(t, reloaded, e.Config())
+	require.Len(t, reloads, 1)
+}
+
+func TestEngine_ReloadRateLimit(t *testing.T) {
+	e := MustNew(DefaultConfig())
+	e.GET("/", newTestHandler("ok"))
+
+	for range 3 {
+		require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/").Code)
+	}
+
+	reloaded := DefaultConfig()
+	reloaded.RateLimit.Limit = 2
+	require.NoError(t, e.ReloadConfig(reloaded))
+	require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/").Code)
+	require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/").Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(e, http.MethodGet, "/").Code)
+
+	// Without a limit, the requests are let through again
+	require.NoError(t, e.ReloadConfig(DefaultConfig()))
+	require.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/").Code)
+}
+
+func TestEngine_ReloadLogLevel(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	e := MustNew(DefaultConfig())
+	e.Logger().Debug("before")
+	require.NotContains(t, output.String(), "before")
+
+	reloaded := DefaultConfig()
+	reloaded.Logging.Level = "debug"
+	require.NoError(t, e.ReloadConfig(reloaded))
+	e.Logger().Debug("after")
+	require.Contains(t, output.String(), "after")
+
+	// Without a level, the default of the run mode is restored
+	require.NoError(t, e.ReloadConfig(DefaultConfig()))
+	require.Equal(t, slog.LevelInfo, e.logLevel.Level())
+}
+
+func TestEngine_WatchConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte("server:\n  max_url_length: 0\n"), 0o644))
+
+	config, err := LoadConfig(filename)
+	require.NoError(t, err)
+	e := MustNew(config)
+
+	reloaded := make(chan *Config, 1)
+	e.OnConfigReload(func(_, config *Config) { reloaded <- config })
+	stop := e.WatchConfig(filename, 10*time.Millisecond)
+	defer stop()
+
+	// Invalid files are skipped, the next valid one is applied
+	require.NoError(t, os.WriteFile(filename, []byte("server: [\n"), 0o644))
+	require.NoError(t, os.Chtimes(filename, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(50 * time.Millisecond)
+	require.Same(t, config, e.Config())
+
+	require.NoError(t, os.WriteFile(filename, []byte("server:\n  max_url_length: 16\n"), 0o644))
+	require.NoError(t, os.Chtimes(filename, time.Now(), time.Now().Add(2*time.Second)))
+	select {
+	case config := <-reloaded:
+		require.Equal(t, 16, config.Server.MaxURLLength)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config not reloaded")
+	}
+	stop()
+}
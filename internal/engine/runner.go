@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultShutdownTimeout bounds the shutdown of each engine of a Runner
+// when Runner.ShutdownTimeout is not set
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Runner runs several engines with a shared lifecycle, e.g. a public API
+// on :8080 and an internal admin plane on :9090
+//
+// Every engine starts listening before any serves, so that a busy address
+// starts none of them. When the context is done or an engine fails, the
+// engines are shut down one after another in the order they were added,
+// e.g. the public API first while the admin plane still reports its
+// state. The zero value is ready to use.
+type Runner struct {
+	// ShutdownTimeout bounds the shutdown of each engine,
+	// DefaultShutdownTimeout if 0
+	ShutdownTimeout time.Duration
+
+	engines []runnerEngine
+}
+
+// runnerEngine is an engine managed by a Runner
+type runnerEngine struct {
+	name    string
+	engine  *Engine
+	address string
+}
+
+// Add adds an engine listening on the address, the name identifies it in
+// the errors
+func (r *Runner) Add(name string, e *Engine, address string) *Runner {
+	r.engines = append(r.engines, runnerEngine{name: name, engine: e, address: address})
+	return r
+}
+
+// Run starts the engines and blocks until the context is done or an
+// engine fails, then shuts every engine down
+//
+// @return: the errors of the engines, each prefixed with its name, or nil
+// after a clean shutdown
+func (r *Runner) Run(ctx context.Context) error {
+	if len(r.engines) == 0 {
+		return errors.New("runner: no engines")
+	}
+
+	var errs []error
+	for _, re := range r.engines {
+		if err := re.engine.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", re.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	listeners := make([]net.Listener, 0, len(r.engines))
+	for _, re := range r.engines {
+		ln, err := re.engine.listen(re.address)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return fmt.Errorf("%s: %w", re.name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	type result struct {
+		index int
+		err   error
+	}
+	results := make(chan result, len(r.engines))
+	for i, re := range r.engines {
+		go func() {
+			results <- result{i, re.engine.serve(listeners[i])}
+		}()
+	}
+
+	// Engines stopping on their own are failures, even without error
+	served := make([]error, len(r.engines))
+	pending := len(r.engines)
+	select {
+	case <-ctx.Done():
+	case res := <-results:
+		served[res.index] = res.err
+		if res.err == nil || errors.Is(res.err, http.ErrServerClosed) {
+			served[res.index] = errors.New("stopped unexpectedly")
+		}
+		pending--
+	}
+
+	timeout := r.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	for _, re := range r.engines {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := re.engine.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: shutdown: %w", re.name, err))
+		}
+		cancel()
+	}
+
+	for ; pending > 0; pending-- {
+		res := <-results
+		if !errors.Is(res.err, http.ErrServerClosed) {
+			served[res.index] = res.err
+		}
+	}
+	for i, err := range served {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.engines[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
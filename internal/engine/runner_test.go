@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner(t *testing.T) {
+	public := MustNew(nil).GET("/", newTestHandler("public"))
+	admin := MustNew(nil).GET("/", newTestHandler("admin"))
+
+	var runner Runner
+	runner.Add("public", public, "127.0.0.1:0").Add("admin", admin, "127.0.0.1:0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.Run(ctx) }()
+
+	for name, e := range map[string]*Engine{"public": public, "admin": admin} {
+		require.Eventually(t, func() bool { return e.Addr() != nil }, time.Second, 10*time.Millisecond)
+		resp, err := http.Get("http://" + e.Addr().String())
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Equal(t, name, string(body))
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+}
+
+func TestRunner_Errors(t *testing.T) {
+	// A busy address starts none of the engines
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	var runner Runner
+	runner.Add("public", MustNew(nil), "127.0.0.1:0").Add("admin", MustNew(nil), ln.Addr().String())
+	err = runner.Run(context.Background())
+	require.ErrorContains(t, err, "admin: ")
+
+	// Registration errors are reported before listening
+	broken := MustNew(nil).GET("/a", newTestHandler("a")).GET("/a", newTestHandler("a"))
+	err = (&Runner{}).Add("broken", broken, "127.0.0.1:0").Run(context.Background())
+	require.ErrorContains(t, err, "broken: ")
+
+	require.Error(t, (&Runner{}).Run(context.Background()))
+}
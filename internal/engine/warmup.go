@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/health"
+)
+
+// DefaultWarmUpTimeout bounds the warm-up when WarmUpConfig.Timeout is not
+// set
+const DefaultWarmUpTimeout = 30 * time.Second
+
+// ErrNotReady is reported by the readiness check until the engine is ready
+var ErrNotReady = errors.New("engine: not ready")
+
+// validate validates the warm-up requests
+func (c *WarmUpConfig) validate() error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("warm-up timeout must not be negative")
+	}
+	for _, r := range c.Requests {
+		if !strings.HasPrefix(r.Path, "/") {
+			return fmt.Errorf("warm-up request path %q must start with /", r.Path)
+		}
+		if r.Count < 0 {
+			return fmt.Errorf("warm-up request count must not be negative")
+		}
+	}
+	return nil
+}
+
+// Ready reports whether the engine listens and dispatched its warm-up
+// requests, see Config.WarmUp
+func (e *Engine) Ready() bool {
+	return e.ready.Load()
+}
+
+// ReadinessCheck returns a check failing with ErrNotReady until the engine
+// is ready, e.g. to register in the health registry of a readiness probe
+// so that no traffic is routed to the instance before it warmed up
+func (e *Engine) ReadinessCheck() health.Checker {
+	return health.CheckerFunc(func(context.Context) error {
+		if !e.Ready() {
+			return ErrNotReady
+		}
+		return nil
+	})
+}
+
+// warmUp dispatches the warm-up requests to the engine, then reports it
+// ready. Failed requests are logged and do not delay readiness further.
+func (e *Engine) warmUp() {
+	defer e.ready.Store(true)
+
+	config := e.config.WarmUp
+	if len(config.Requests) == 0 {
+		return
+	}
+	timeout := DefaultWarmUpTimeout
+	if config.Timeout > 0 {
+		timeout = time.Duration(config.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	sent, failed := 0, 0
+	for _, wr := range config.Requests {
+		for range max(wr.Count, 1) {
+			if ctx.Err() != nil {
+				e.logger.Warn("Warm-up timed out", "requests", sent)
+				return
+			}
+			sent++
+			if status := e.dispatch(ctx, wr); status >= http.StatusBadRequest {
+				failed++
+				e.logger.Warn("Warm-up request failed", "method", wr.Method, "path", wr.Path, "status", status)
+			}
+		}
+	}
+	e.logger.Info("Warm-up done", "requests", sent, "failed", failed, "duration", time.Since(start).Round(time.Millisecond))
+}
+
+// dispatch serves a warm-up request, as sent from the loopback interface
+//
+// @return: the status of the response
+func (e *Engine) dispatch(ctx context.Context, wr WarmUpRequest) int {
+	method := wr.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequestWithContext(ctx, method, wr.Path, strings.NewReader(wr.Body))
+	if err != nil {
+		return http.StatusBadRequest
+	}
+	r.RequestURI = wr.Path
+	r.RemoteAddr = "127.0.0.1:0"
+	if addr := e.Addr(); addr != nil {
+		r.Host = addr.String()
+	}
+	for name, value := range wr.Header {
+		if strings.EqualFold(name, "Host") {
+			r.Host = value
+			continue
+		}
+		r.Header.Set(name, value)
+	}
+
+	w := &warmUpWriter{header: make(http.Header)}
+	e.ServeHTTP(w, r)
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// warmUpWriter discards the responses of warm-up requests
+type warmUpWriter struct {
+	header http.Header
+	status int
+}
+
+// Header implements http.ResponseWriter
+func (w *warmUpWriter) Header() http.Header {
+	return w.header
+}
+
+// Write implements http.ResponseWriter
+func (w *warmUpWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *warmUpWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+}
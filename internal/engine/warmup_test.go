@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_WarmUp(t *testing.T) {
+	config := DefaultConfig()
+	config.WarmUp.Requests = []WarmUpRequest{
+		{Path: "/products?page=1", Count: 3},
+		{Method: http.MethodPost, Path: "/cache", Header: map[string]string{"X-Warm-Up": "1"}, Body: "prime"},
+		{Path: "/missing"},
+	}
+	require.NoError(t, config.Validate())
+
+	var products, primed atomic.Int32
+	e := MustNew(config)
+	e.GET("/products", func(c *types.Context) {
+		if c.GetQuery("page") == "1" {
+			products.Add(1)
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	e.POST("/cache", func(c *types.Context) {
+		if c.GetHeader("X-Warm-Up") == "1" {
+			primed.Add(1)
+		}
+		c.Status(http.StatusNoContent)
+	})
+	require.ErrorIs(t, e.ReadinessCheck().Check(context.Background()), ErrNotReady)
+
+	done := make(chan error, 1)
+	go func() { done <- e.Run("127.0.0.1:0") }()
+	require.Eventually(t, e.Ready, time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 3, products.Load())
+	require.EqualValues(t, 1, primed.Load())
+	require.NoError(t, e.ReadinessCheck().Check(context.Background()))
+
+	require.NoError(t, e.Shutdown(context.Background()))
+	require.ErrorIs(t, <-done, http.ErrServerClosed)
+	require.False(t, e.Ready())
+}
+
+func TestWarmUpConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.WarmUp.Requests = []WarmUpRequest{{Path: "products"}}
+	require.Error(t, config.Validate())
+
+	config.WarmUp.Requests = []WarmUpRequest{{Path: "/", Count: -1}}
+	require.Error(t, config.Validate())
+}
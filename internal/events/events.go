@@ -0,0 +1,96 @@
+// Package events is a typed in-process event bus, letting extensions such
+// as metrics, documentation or auditing observe the framework without a
+// dedicated hook for each of them
+//
+// Events are plain values identified by their type. The framework events
+// are defined here, packages may publish events of their own types.
+package events
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RouteRegistered is published when a route is registered
+type RouteRegistered struct {
+	Method string
+	Path   string
+}
+
+// RequestCompleted is published once a request was handled
+type RequestCompleted struct {
+	Request  *http.Request
+	Status   int
+	Size     int64 // body bytes written
+	Duration time.Duration
+}
+
+// PanicRecovered is published when a handler panic was recovered
+type PanicRecovered struct {
+	Request *http.Request
+	Value   any
+	Stack   []byte
+}
+
+// Bus delivers events to the subscribers of their type. The zero value is
+// ready to use.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[reflect.Type][]*subscriber
+}
+
+// subscriber is a subscription to a type of events
+type subscriber struct {
+	fn any // func(E) of the event type E
+}
+
+// Subscribe calls fn with every event of type E published on the bus
+//
+// Subscribers are called synchronously, in the order they subscribed, by
+// the goroutine publishing the event, so they must not block, e.g. while
+// handling requests.
+//
+// @return: a function removing the subscription
+func Subscribe[E any](bus *Bus, fn func(E)) (unsubscribe func()) {
+	typ := reflect.TypeFor[E]()
+	sub := &subscriber{fn: fn}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.subscribers == nil {
+		bus.subscribers = make(map[reflect.Type][]*subscriber)
+	}
+	bus.subscribers[typ] = append(bus.subscribers[typ], sub)
+
+	return func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		// Publishing iterates over the slices unlocked, so they are
+		// replaced rather than modified
+		bus.subscribers[typ] = slices.DeleteFunc(slices.Clone(bus.subscribers[typ]), func(s *subscriber) bool {
+			return s == sub
+		})
+	}
+}
+
+// Publish delivers an event to the subscribers of its type
+func Publish[E any](bus *Bus, event E) {
+	bus.mu.RLock()
+	subscribers := bus.subscribers[reflect.TypeFor[E]()]
+	bus.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		sub.fn.(func(E))(event)
+	}
+}
+
+// Subscribed reports whether events of type E have subscribers, to skip
+// preparing events nobody receives
+func Subscribed[E any](bus *Bus) bool {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	return len(bus.subscribers[reflect.TypeFor[E]()]) > 0
+}
@@ -0,0 +1,33 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	var bus Bus
+	require.False(t, Subscribed[RouteRegistered](&bus))
+	Publish(&bus, RouteRegistered{Method: "GET", Path: "/"})
+
+	var calls []string
+	unsubscribe := Subscribe(&bus, func(e RouteRegistered) { calls = append(calls, "first "+e.Path) })
+	Subscribe(&bus, func(e RouteRegistered) { calls = append(calls, "second "+e.Path) })
+	Subscribe(&bus, func(e PanicRecovered) { calls = append(calls, "panic") })
+	require.True(t, Subscribed[RouteRegistered](&bus))
+
+	Publish(&bus, RouteRegistered{Method: "GET", Path: "/users"})
+	require.Equal(t, []string{"first /users", "second /users"}, calls)
+
+	// Events of other types, even with the same fields, are not delivered
+	type custom RouteRegistered
+	Publish(&bus, custom{Path: "/custom"})
+	require.Len(t, calls, 2)
+
+	calls = nil
+	unsubscribe()
+	unsubscribe()
+	Publish(&bus, RouteRegistered{Path: "/admin"})
+	require.Equal(t, []string{"second /admin"}, calls)
+}
@@ -0,0 +1,363 @@
+// Package examples records an example request and response of each route
+// while the engine runs in debug mode, to document the API with realistic
+// samples rather than hand-written ones
+package examples
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultMaxBodySize is the size of the bodies recorded when
+// Config.MaxBodySize is not set
+const DefaultMaxBodySize = 16 << 10
+
+// Redacted replaces the sensitive values of the examples
+const Redacted = "REDACTED"
+
+// DefaultSensitiveHeaders are removed from the examples when
+// Config.SensitiveHeaders is not set
+var DefaultSensitiveHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+	"X-Api-Key", "X-Csrf-Token",
+}
+
+// DefaultSensitiveFields are redacted from the JSON bodies and the queries
+// of the examples when Config.SensitiveFields is not set, matching the
+// names that contain them regardless of case, e.g. "access_token"
+var DefaultSensitiveFields = []string{
+	"password", "secret", "token", "api_key", "apikey", "credit_card", "ssn",
+}
+
+// Config configures a Recorder
+type Config struct {
+	// MaxBodySize of the bodies recorded, larger bodies are left out of
+	// the examples, DefaultMaxBodySize if 0
+	MaxBodySize int
+
+	// SensitiveHeaders removed from the examples, DefaultSensitiveHeaders
+	// if nil
+	SensitiveHeaders []string
+
+	// SensitiveFields redacted from the JSON bodies and the queries,
+	// DefaultSensitiveFields if nil
+	SensitiveFields []string
+}
+
+// Example is a request and its response
+type Example struct {
+	Method   string   `json:"method"`
+	Route    string   `json:"route"`
+	Query    string   `json:"query,omitempty"`
+	Request  Message  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Message is the header and the body of a request or a response
+type Message struct {
+	Header      http.Header `json:"header,omitempty"`
+	ContentType string      `json:"content_type,omitempty"`
+	Body        string      `json:"body,omitempty"`
+}
+
+// Response is the message of a response and its status
+type Response struct {
+	Message
+	Status int `json:"status"`
+}
+
+// Recorder records the first successful request of each route
+type Recorder struct {
+	config Config
+
+	mu       sync.Mutex
+	examples map[string]*Example // by method and route
+}
+
+// NewRecorder creates a recorder with the configuration
+func NewRecorder(config Config) *Recorder {
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	if config.SensitiveHeaders == nil {
+		config.SensitiveHeaders = DefaultSensitiveHeaders
+	}
+	if config.SensitiveFields == nil {
+		config.SensitiveFields = DefaultSensitiveFields
+	}
+	return &Recorder{config: config, examples: make(map[string]*Example)}
+}
+
+// Middleware returns the middleware recording the examples, used by the
+// engine or by the routes to document
+//
+// It does nothing outside of the debug mode of the engine, and once the
+// route has its example, so that it costs nothing in production. Only
+// requests answered with a 2xx status are recorded.
+func (r *Recorder) Middleware() types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if !c.Debug || c.Route == "" || r.recorded(c.Request.Method, c.Route) {
+				next(c)
+				return
+			}
+
+			var body []byte
+			if c.Request.Body != nil {
+				body, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(r.config.MaxBodySize)+1))
+				c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+			}
+
+			writer := &recordWriter{ResponseWriter: c.Writer, limit: r.config.MaxBodySize}
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+
+			if writer.status < 200 || writer.status > 299 {
+				return
+			}
+			example := &Example{
+				Method:  c.Request.Method,
+				Route:   c.Route,
+				Query:   r.sanitizeQuery(c.Request.URL.Query()),
+				Request: r.message(c.Request.Header, body),
+				Response: Response{
+					Message: r.message(writer.Header(), writer.body()),
+					Status:  writer.status,
+				},
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if _, ok := r.examples[example.Method+" "+example.Route]; !ok {
+				r.examples[example.Method+" "+example.Route] = example
+			}
+		}
+	}
+}
+
+// Examples returns the examples recorded, sorted by route and method
+func (r *Recorder) Examples() []Example {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	examples := make([]Example, 0, len(r.examples))
+	for _, example := range r.examples {
+		examples = append(examples, *example)
+	}
+	slices.SortFunc(examples, func(a, b Example) int {
+		return strings.Compare(a.Route+" "+a.Method, b.Route+" "+b.Method)
+	})
+	return examples
+}
+
+// Paths returns the examples as an OpenAPI paths object, to be merged into
+// a specification, with the example of each request body and response
+//
+// Parameters are written {name} as in OpenAPI, wildcards included.
+func (r *Recorder) Paths() map[string]any {
+	paths := make(map[string]any)
+	for _, example := range r.Examples() {
+		path := openAPIPath(example.Route)
+		operations, ok := paths[path].(map[string]any)
+		if !ok {
+			operations = make(map[string]any)
+			paths[path] = operations
+		}
+
+		operation := map[string]any{
+			"responses": map[string]any{
+				strconv.Itoa(example.Response.Status): map[string]any{
+					"description": http.StatusText(example.Response.Status),
+					"content":     openAPIContent(example.Response.Message),
+				},
+			},
+		}
+		if content := openAPIContent(example.Request); content != nil {
+			operation["requestBody"] = map[string]any{"content": content}
+		}
+		operations[strings.ToLower(example.Method)] = operation
+	}
+	return paths
+}
+
+// Handler returns the handler serving the examples as JSON
+func (r *Recorder) Handler() types.HandlerFunc {
+	return func(c *types.Context) {
+		c.JSON(http.StatusOK, r.Examples())
+	}
+}
+
+// recorded reports whether the route has its example
+func (r *Recorder) recorded(method, route string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.examples[method+" "+route]
+	return ok
+}
+
+// message returns the sanitized message of the header and the body,
+// leaving out bodies above the limit or neither JSON, forms nor text
+func (r *Recorder) message(header http.Header, body []byte) Message {
+	message := Message{Header: header.Clone()}
+	for _, name := range r.config.SensitiveHeaders {
+		message.Header.Del(name)
+	}
+	if len(message.Header) == 0 {
+		message.Header = nil
+	}
+
+	message.ContentType = header.Get("Content-Type")
+	if len(body) == 0 || len(body) > r.config.MaxBodySize {
+		return message
+	}
+	mediaType, _, _ := mime.ParseMediaType(message.ContentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var value any
+		if json.Unmarshal(body, &value) != nil {
+			return message
+		}
+		if data, err := json.Marshal(r.redact(value)); err == nil {
+			message.Body = string(data)
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			message.Body = r.sanitizeQuery(values)
+		}
+	case strings.HasPrefix(mediaType, "text/"):
+		message.Body = string(body)
+	}
+	return message
+}
+
+// redact redacts the sensitive fields of a JSON value
+func (r *Recorder) redact(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if r.sensitive(key) {
+				value[key] = Redacted
+			} else {
+				value[key] = r.redact(field)
+			}
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = r.redact(item)
+		}
+	}
+	return value
+}
+
+// sanitizeQuery returns the encoded query with its sensitive fields
+// redacted
+func (r *Recorder) sanitizeQuery(values url.Values) string {
+	for key, vs := range values {
+		if r.sensitive(key) {
+			for i := range vs {
+				vs[i] = Redacted
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// sensitive reports whether the field name is sensitive
+func (r *Recorder) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range r.config.SensitiveFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath returns the route with its parameters written {name}
+func openAPIPath(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// openAPIContent returns the OpenAPI content of the message, nil without
+// body
+func openAPIContent(message Message) map[string]any {
+	if message.Body == "" {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(message.ContentType)
+	var example any = message.Body
+	if json.Valid([]byte(message.Body)) && strings.HasSuffix(mediaType, "json") {
+		example = json.RawMessage(message.Body)
+	}
+	return map[string]any{mediaType: map[string]any{"example": example}}
+}
+
+// recordWriter keeps the status and the start of the body of a response
+type recordWriter struct {
+	http.ResponseWriter
+	limit int
+
+	status int
+	buf    bytes.Buffer
+	size   int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *recordWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *recordWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += len(b)
+	if w.buf.Len() <= w.limit {
+		w.buf.Write(b[:min(len(b), w.limit+1-w.buf.Len())])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (w *recordWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *recordWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// body returns the body written, nil if larger than the limit
+func (w *recordWriter) body() []byte {
+	if w.size > w.limit {
+		return nil
+	}
+	return w.buf.Bytes()
+}
+
+// readCloser reads from a reader and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
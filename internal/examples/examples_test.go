@@ -0,0 +1,103 @@
+package examples
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// serve serves the request with the recorder and the handler
+func serve(recorder *Recorder, r *http.Request, route string, debug bool, handler types.HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: r, Writer: w, Route: route, Debug: debug}
+	c.Execute(types.Chain([]types.MiddlewareFunc{recorder.Middleware()}, handler))
+	return w
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(Config{})
+	login := func(c *types.Context) {
+		var body map[string]string
+		require.NoError(t, c.BindJSON(&body))
+		require.Equal(t, "hunter2", body["password"])
+		c.Header("Set-Cookie", "session=abc")
+		c.JSON(http.StatusCreated, map[string]any{"user": body["user"], "access_token": "xyz"})
+	}
+
+	// Requests are not recorded outside of the debug mode
+	r := httptest.NewRequest(http.MethodPost, "/login?api_key=k&next=/", strings.NewReader(`{"user":"ada","password":"hunter2"}`))
+	r.Header.Set("Content-Type", "application/json")
+	serve(recorder, r, "/login", false, login)
+	require.Empty(t, recorder.Examples())
+
+	r = httptest.NewRequest(http.MethodPost, "/login?api_key=k&next=/", strings.NewReader(`{"user":"ada","password":"hunter2"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer secret")
+	w := serve(recorder, r, "/login", true, login)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	examples := recorder.Examples()
+	require.Len(t, examples, 1)
+	example := examples[0]
+	require.Equal(t, "POST", example.Method)
+	require.Equal(t, "/login", example.Route)
+	require.Equal(t, "api_key=REDACTED&next=%2F", example.Query)
+	require.Empty(t, example.Request.Header.Get("Authorization"))
+	require.JSONEq(t, `{"user":"ada","password":"REDACTED"}`, example.Request.Body)
+	require.Equal(t, http.StatusCreated, example.Response.Status)
+	require.Empty(t, example.Response.Header.Get("Set-Cookie"))
+	require.JSONEq(t, `{"user":"ada","access_token":"REDACTED"}`, example.Response.Body)
+
+	// Only the first example of a route is kept
+	r = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"user":"bob","password":"hunter2"}`))
+	serve(recorder, r, "/login", true, login)
+	require.Equal(t, example, recorder.Examples()[0])
+}
+
+func TestRecorder_Skipped(t *testing.T) {
+	recorder := NewRecorder(Config{MaxBodySize: 8})
+
+	// Errors are not examples
+	serve(recorder, httptest.NewRequest(http.MethodGet, "/users/7", nil), "/users/:id", true, func(c *types.Context) {
+		c.ErrorString(http.StatusNotFound, "not found")
+	})
+	require.Empty(t, recorder.Examples())
+
+	// Bodies above the limit are left out
+	serve(recorder, httptest.NewRequest(http.MethodGet, "/users/7", nil), "/users/:id", true, func(c *types.Context) {
+		c.String(http.StatusOK, "a long response body")
+	})
+	examples := recorder.Examples()
+	require.Len(t, examples, 1)
+	require.Empty(t, examples[0].Response.Body)
+	require.Equal(t, http.StatusOK, examples[0].Response.Status)
+}
+
+func TestRecorder_Paths(t *testing.T) {
+	recorder := NewRecorder(Config{})
+	r := httptest.NewRequest(http.MethodPut, "/users/7", strings.NewReader(`{"name":"ada"}`))
+	r.Header.Set("Content-Type", "application/json")
+	serve(recorder, r, "/users/:id", true, func(c *types.Context) {
+		c.JSON(http.StatusOK, map[string]any{"id": 7, "name": "ada"})
+	})
+	serve(recorder, httptest.NewRequest(http.MethodGet, "/files/a/b", nil), "/files/*path", true, func(c *types.Context) {
+		c.String(http.StatusOK, "content")
+	})
+
+	data, err := json.Marshal(recorder.Paths())
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"/users/{id}": {"put": {
+			"requestBody": {"content": {"application/json": {"example": {"name": "ada"}}}},
+			"responses": {"200": {"description": "OK", "content": {"application/json": {"example": {"id": 7, "name": "ada"}}}}}
+		}},
+		"/files/{path}": {"get": {
+			"responses": {"200": {"description": "OK", "content": {"text/plain": {"example": "content"}}}}
+		}}
+	}`, string(data))
+}
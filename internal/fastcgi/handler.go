@@ -0,0 +1,287 @@
+package fastcgi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Defaults of the handler configuration
+const (
+	DefaultIndex       = "index.php"
+	DefaultSplitPath   = ".php"
+	DefaultDialTimeout = 5 * time.Second
+)
+
+// Config configures the requests sent to a FastCGI server, e.g. php-fpm
+type Config struct {
+	// Network and address of the server, e.g. "tcp" and "127.0.0.1:9000",
+	// or "unix" and "/run/php/php-fpm.sock". The network is tcp if empty.
+	Network string
+	Address string
+
+	// Root is the document root on the FastCGI server, scripts are
+	// resolved below it
+	Root string
+
+	// Index is the script serving directories, DefaultIndex if empty
+	Index string
+
+	// SplitPath ends the script name in request paths, the rest being the
+	// PATH_INFO, e.g. /index.php/users, DefaultSplitPath if empty
+	SplitPath string
+
+	// Script serves the requests not naming a script, e.g. "/index.php"
+	// for front controllers. They name the requested file if empty.
+	Script string
+
+	// DialTimeout of the connections to the server, DefaultDialTimeout if 0
+	DialTimeout time.Duration
+
+	// Params maps values of the request context to CGI variables, e.g. the
+	// authenticated user to REMOTE_USER, may be nil
+	Params func(c *types.Context) map[string]string
+
+	// ErrorLog receives the stderr output of the scripts and the errors
+	// occurring once the response started, the standard logger if nil
+	ErrorLog *log.Logger
+}
+
+// Handler sends requests to a FastCGI server as a responder, one
+// connection per request
+type Handler struct {
+	config Config
+	dialer net.Dialer
+	logger *log.Logger
+}
+
+// NewHandler creates a FastCGI handler
+func NewHandler(config Config) *Handler {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	if config.Index == "" {
+		config.Index = DefaultIndex
+	}
+	if config.SplitPath == "" {
+		config.SplitPath = DefaultSplitPath
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = DefaultDialTimeout
+	}
+
+	logger := config.ErrorLog
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Handler{
+		config: config,
+		dialer: net.Dialer{Timeout: config.DialTimeout},
+		logger: logger,
+	}
+}
+
+// Serve sends the request to the FastCGI server and writes its response
+//
+// The name is the request path below the prefix the handler is mounted
+// at, it selects the script. The params are added to the CGI variables,
+// overriding them.
+//
+// @return: an error if the server could not be reached or sent an invalid
+// response before anything was written
+func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, name string, params map[string]string) error {
+	conn, err := h.dialer.DialContext(r.Context(), h.config.Network, h.config.Address)
+	if err != nil {
+		return err
+	}
+
+	// Abandon the request when the client goes away
+	stop := context.AfterFunc(r.Context(), func() { conn.Close() })
+	defer stop()
+
+	vars := h.params(r, name)
+	for key, value := range params {
+		vars[key] = value
+	}
+
+	// The request is written while the response is read, so that large
+	// bodies cannot block both sides. The request body must not be read
+	// once the handler returned.
+	written := make(chan struct{})
+	defer func() {
+		conn.Close()
+		<-written
+	}()
+	go func() {
+		defer close(written)
+		rw := &recordWriter{w: bufio.NewWriter(conn)}
+		err := rw.beginRequest()
+		if err == nil {
+			err = rw.stream(typeParams, bytes.NewReader(encodeParams(vars)))
+		}
+		if err == nil {
+			var body io.Reader = http.NoBody
+			if r.Body != nil {
+				body = r.Body
+			}
+			err = rw.stream(typeStdin, body)
+		}
+		if err != nil {
+			conn.Close()
+		}
+	}()
+
+	stdout := &stdoutReader{r: bufio.NewReader(conn)}
+	defer func() {
+		if stdout.stderr.Len() > 0 {
+			h.logger.Printf("fastcgi: %s: %s", vars["SCRIPT_FILENAME"], strings.TrimSpace(stdout.stderr.String()))
+		}
+	}()
+
+	body := bufio.NewReader(stdout)
+	header, err := textproto.NewReader(body).ReadMIMEHeader()
+	if err != nil {
+		return fmt.Errorf("fastcgi: reading response header: %w", err)
+	}
+
+	status := http.StatusOK
+	if value := header.Get("Status"); value != "" {
+		code, _, _ := strings.Cut(value, " ")
+		if status, err = strconv.Atoi(code); err != nil || status < 100 || status > 999 {
+			return fmt.Errorf("%w: invalid status %q", ErrProtocol, value)
+		}
+		header.Del("Status")
+	} else if header.Get("Location") != "" {
+		status = http.StatusFound
+	}
+
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(status)
+
+	if _, err := io.Copy(w, body); err != nil && !errors.Is(err, net.ErrClosed) {
+		h.logger.Printf("fastcgi: %s %s: %v", r.Method, r.URL.Path, err)
+	}
+	return nil
+}
+
+// Handle serves the request of the context, for use in a route handler
+//
+// Unreachable servers and invalid responses are answered with 502 Bad
+// Gateway.
+func (h *Handler) Handle(c *types.Context, name string) {
+	var params map[string]string
+	if h.config.Params != nil {
+		params = h.config.Params(c)
+	}
+
+	if err := h.Serve(c.Writer, c.Request, name, params); err != nil {
+		h.logger.Printf("fastcgi: %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		c.ErrorString(http.StatusBadGateway, http.StatusText(http.StatusBadGateway))
+	}
+}
+
+// params returns the CGI variables of a request, see RFC 3875
+func (h *Handler) params(r *http.Request, name string) map[string]string {
+	script, pathInfo := h.resolve(name)
+
+	// The script name is a URL path, below the prefix of the handler
+	prefix := strings.TrimSuffix(r.URL.Path, strings.TrimPrefix(name, "/"))
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	requestURI := r.RequestURI
+	if requestURI == "" {
+		requestURI = r.URL.RequestURI()
+	}
+
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "go-api",
+		"SERVER_PROTOCOL":   r.Proto,
+		"REQUEST_METHOD":    r.Method,
+		"REQUEST_URI":       requestURI,
+		"QUERY_STRING":      r.URL.RawQuery,
+		"DOCUMENT_ROOT":     h.config.Root,
+		"SCRIPT_NAME":       prefix + script,
+		"SCRIPT_FILENAME":   path.Join(h.config.Root, script),
+		"PATH_INFO":         pathInfo,
+		"CONTENT_TYPE":      r.Header.Get("Content-Type"),
+		"CONTENT_LENGTH":    "",
+	}
+	if r.ContentLength >= 0 {
+		params["CONTENT_LENGTH"] = strconv.FormatInt(r.ContentLength, 10)
+	}
+	if pathInfo != "" {
+		params["PATH_TRANSLATED"] = path.Join(h.config.Root, pathInfo)
+	}
+
+	if host, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		params["REMOTE_ADDR"] = host
+		params["REMOTE_PORT"] = port
+	}
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, "80"
+		if r.TLS != nil {
+			port = "443"
+		}
+	}
+	params["SERVER_NAME"] = host
+	params["SERVER_PORT"] = port
+	if r.TLS != nil {
+		params["HTTPS"] = "on"
+		params["REQUEST_SCHEME"] = "https"
+	} else {
+		params["REQUEST_SCHEME"] = "http"
+	}
+
+	for key, values := range r.Header {
+		// The Proxy header would be read as HTTP_PROXY, see httpoxy
+		if key == "Proxy" || key == "Content-Type" || key == "Content-Length" {
+			continue
+		}
+		name := "HTTP_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		params[name] = strings.Join(values, ", ")
+	}
+	return params
+}
+
+// resolve splits a request path into the script and the PATH_INFO
+func (h *Handler) resolve(name string) (string, string) {
+	name = "/" + strings.TrimPrefix(name, "/")
+
+	// The split path must end a segment, so that /a.phpx is not a script
+	for offset := 0; ; {
+		i := strings.Index(name[offset:], h.config.SplitPath)
+		if i < 0 {
+			break
+		}
+		end := offset + i + len(h.config.SplitPath)
+		if end == len(name) || name[end] == '/' {
+			return path.Clean(name[:end]), name[end:]
+		}
+		offset = end
+	}
+
+	if h.config.Script != "" {
+		return h.config.Script, name
+	}
+	if strings.HasSuffix(name, "/") {
+		return path.Clean(name + h.config.Index), ""
+	}
+	return path.Clean(name), ""
+}
@@ -0,0 +1,112 @@
+package fastcgi
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newFastCGIServer starts a FastCGI server, returning its address
+func newFastCGIServer(t *testing.T, handler http.HandlerFunc) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go fcgi.Serve(ln, handler)
+	return ln.Addr().String()
+}
+
+func TestHandler_Serve(t *testing.T) {
+	addr := newFastCGIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		env := fcgi.ProcessEnv(r)
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("X-Script", env["SCRIPT_FILENAME"])
+		w.Header().Set("X-User", env["REMOTE_USER"])
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + r.URL.RawQuery + " " + r.Header.Get("X-Token") + " " + string(body)))
+	})
+	h := NewHandler(Config{Address: addr, Root: "/var/www"})
+
+	r := httptest.NewRequest(http.MethodPost, "/app/index.php/users?page=2", strings.NewReader("data"))
+	r.Header.Set("X-Token", "abc")
+	w := httptest.NewRecorder()
+	require.NoError(t, h.Serve(w, r, "index.php/users", map[string]string{"REMOTE_USER": "alice"}))
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "/var/www/index.php", w.Header().Get("X-Script"))
+	require.Equal(t, "alice", w.Header().Get("X-User"))
+	require.Equal(t, "POST page=2 abc data", w.Body.String())
+}
+
+func TestHandler_Params(t *testing.T) {
+	h := NewHandler(Config{Root: "/var/www"})
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/app/index.php/users?page=2", nil)
+	r.Header.Set("Proxy", "evil")
+	r.Header.Set("Accept-Language", "en")
+
+	params := h.params(r, "index.php/users")
+	require.Equal(t, "/app/index.php", params["SCRIPT_NAME"])
+	require.Equal(t, "/var/www/index.php", params["SCRIPT_FILENAME"])
+	require.Equal(t, "/users", params["PATH_INFO"])
+	require.Equal(t, "/var/www/users", params["PATH_TRANSLATED"])
+	require.Equal(t, "page=2", params["QUERY_STRING"])
+	require.Equal(t, "example.com", params["SERVER_NAME"])
+	require.Equal(t, "443", params["SERVER_PORT"])
+	require.Equal(t, "on", params["HTTPS"])
+	require.Equal(t, "en", params["HTTP_ACCEPT_LANGUAGE"])
+	require.NotContains(t, params, "HTTP_PROXY")
+}
+
+func TestHandler_Resolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		path     string
+		script   string
+		pathInfo string
+	}{
+		{name: "script", path: "admin/users.php", script: "/admin/users.php"},
+		{name: "path info", path: "index.php/a/b", script: "/index.php", pathInfo: "/a/b"},
+		{name: "directory index", path: "admin/", script: "/admin/index.php"},
+		{name: "root index", path: "", script: "/index.php"},
+		{name: "split path ends a segment", path: "a.phpx/b.php", script: "/a.phpx/b.php"},
+		{name: "other file", path: "robots.txt", script: "/robots.txt"},
+		{name: "front controller", config: Config{Script: "/index.php"}, path: "users/1", script: "/index.php", pathInfo: "/users/1"},
+		{name: "traversal", path: "../../etc/x.php", script: "/etc/x.php"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, pathInfo := NewHandler(tt.config).resolve(tt.path)
+			require.Equal(t, tt.script, script)
+			require.Equal(t, tt.pathInfo, pathInfo)
+		})
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	// Nothing listens on the address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	h := NewHandler(Config{Address: addr, ErrorLog: log.New(io.Discard, "", 0)})
+	err = h.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "", nil)
+	require.Error(t, err)
+
+	// Large responses are streamed across many records
+	addr = newFastCGIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 200<<10)))
+	})
+	w := httptest.NewRecorder()
+	require.NoError(t, NewHandler(Config{Address: addr}).Serve(w, httptest.NewRequest(http.MethodGet, "/", nil), "", nil))
+	require.Equal(t, 200<<10, w.Body.Len())
+}
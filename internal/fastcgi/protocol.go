@@ -0,0 +1,202 @@
+package fastcgi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Record types, see the FastCGI specification
+const (
+	typeBeginRequest uint8 = 1
+	typeEndRequest   uint8 = 3
+	typeParams       uint8 = 4
+	typeStdin        uint8 = 5
+	typeStdout       uint8 = 6
+	typeStderr       uint8 = 7
+)
+
+const (
+	version1      = 1
+	roleResponder = 1
+
+	// Every request uses its own connection, so the id never changes
+	requestID = 1
+
+	// Largest content of a record
+	maxContent = 65535
+
+	// Largest amount of stderr output kept for logging
+	maxStderr = 64 << 10
+
+	// Protocol status of a completed request
+	statusRequestComplete = 0
+)
+
+// ErrProtocol is returned when the FastCGI server sends invalid records
+var ErrProtocol = errors.New("fastcgi: protocol error")
+
+// recordWriter writes the records of a request
+type recordWriter struct {
+	w *bufio.Writer
+}
+
+// write writes a record with its padding
+func (rw *recordWriter) write(recordType uint8, content []byte) error {
+	padding := -len(content) & 7
+	header := [8]byte{
+		version1, recordType,
+		byte(requestID >> 8), byte(requestID),
+		byte(len(content) >> 8), byte(len(content)),
+		byte(padding), 0,
+	}
+	rw.w.Write(header[:])
+	rw.w.Write(content)
+	_, err := rw.w.Write(make([]byte, padding))
+	return err
+}
+
+// beginRequest writes the record starting a responder request
+func (rw *recordWriter) beginRequest() error {
+	return rw.write(typeBeginRequest, []byte{0, roleResponder, 0, 0, 0, 0, 0, 0})
+}
+
+// stream writes the content of a stream in records, terminated by an empty
+// record
+func (rw *recordWriter) stream(recordType uint8, r io.Reader) error {
+	buf := make([]byte, maxContent)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := rw.write(recordType, buf[:n]); err != nil {
+				return err
+			}
+			// Stdin is flushed as it is read, so that streamed uploads
+			// reach the application
+			if err := rw.w.Flush(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := rw.write(recordType, nil); err != nil {
+		return err
+	}
+	return rw.w.Flush()
+}
+
+// encodeParams encodes name-value pairs
+func encodeParams(params map[string]string) []byte {
+	var buf bytes.Buffer
+	for name, value := range params {
+		writeLength(&buf, len(name))
+		writeLength(&buf, len(value))
+		buf.WriteString(name)
+		buf.WriteString(value)
+	}
+	return buf.Bytes()
+}
+
+// writeLength encodes the length of a name or value, on 1 byte below 128
+// and on 4 bytes with the high bit set otherwise
+func writeLength(buf *bytes.Buffer, n int) {
+	if n < 128 {
+		buf.WriteByte(byte(n))
+		return
+	}
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)|1<<31))
+}
+
+// stdoutReader reads the stdout stream of a response, collecting stderr
+// on the way until the request ends
+type stdoutReader struct {
+	r      *bufio.Reader
+	stderr bytes.Buffer
+
+	remaining int // content bytes left in the current stdout record
+	padding   int
+	done      bool
+}
+
+// Read implements io.Reader
+func (s *stdoutReader) Read(p []byte) (int, error) {
+	for s.remaining == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := s.r.Read(p[:min(len(p), s.remaining)])
+	s.remaining -= n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if s.remaining == 0 && err == nil {
+		_, err = s.r.Discard(s.padding)
+	}
+	return n, err
+}
+
+// next reads the next record header, consuming the records other than
+// stdout
+func (s *stdoutReader) next() error {
+	var header [8]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if header[0] != version1 {
+		return fmt.Errorf("%w: version %d", ErrProtocol, header[0])
+	}
+	length := int(binary.BigEndian.Uint16(header[4:6]))
+	padding := int(header[6])
+
+	switch header[1] {
+	case typeStdout:
+		s.remaining, s.padding = length, padding
+		if length == 0 {
+			_, err := s.r.Discard(padding)
+			return err
+		}
+		return nil
+	case typeStderr:
+		content := make([]byte, length)
+		if _, err := io.ReadFull(s.r, content); err != nil {
+			return err
+		}
+		if room := maxStderr - s.stderr.Len(); room > 0 {
+			s.stderr.Write(content[:min(room, len(content))])
+		}
+	case typeEndRequest:
+		if length < 8 {
+			return fmt.Errorf("%w: short end request", ErrProtocol)
+		}
+		content := make([]byte, length)
+		if _, err := io.ReadFull(s.r, content); err != nil {
+			return err
+		}
+		s.done = true
+		if status := content[4]; status != statusRequestComplete {
+			return fmt.Errorf("%w: request rejected with status %d", ErrProtocol, status)
+		}
+	default:
+		if _, err := s.r.Discard(length); err != nil {
+			return err
+		}
+	}
+	_, err := s.r.Discard(padding)
+	return err
+}
@@ -0,0 +1,392 @@
+package guard
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// tlsHandshakeRecord is the first byte of a TLS connection, never the one
+// of an HTTP/1.x request
+const tlsHandshakeRecord = 0x16
+
+// state is the position of the framer within the request stream
+type state int
+
+const (
+	stateHead      state = iota // request line and header fields
+	stateBody                   // Content-Length delimited body
+	stateChunkSize              // chunk size line with extensions
+	stateChunkData              // chunk payload
+	stateChunkEnd               // CRLF terminating a chunk payload
+	stateTrailer                // trailer fields after the last chunk
+	stateOpaque                 // no longer HTTP/1.x, passed through as is
+)
+
+// framer is a state machine tracking HTTP/1.x message boundaries
+//
+// It is fed the unvalidated bytes of a connection and reports how many of
+// them form a complete, valid unit that may be handed to net/http.
+type framer struct {
+	config *Config
+	state  state
+
+	// Bytes left in the current body or chunk
+	remaining int64
+
+	// Whether the connection leaves HTTP/1.x after the current message
+	upgrade bool
+
+	// Whether any byte of the current request was handed to net/http
+	released bool
+}
+
+// advance validates the next unit at the start of raw
+//
+// @return: the number of validated bytes, 0 if more data is needed
+// @return: the reason of the violation if the bytes are invalid
+// @return: false if the bytes are invalid
+func (f *framer) advance(raw []byte) (int, Reason, bool) {
+	if len(raw) == 0 {
+		return 0, 0, true
+	}
+
+	switch f.state {
+	case stateHead:
+		return f.head(raw)
+	case stateBody, stateChunkData:
+		n := int(min(f.remaining, int64(len(raw))))
+		f.remaining -= int64(n)
+		if f.remaining == 0 {
+			if f.state == stateBody {
+				f.endMessage()
+			} else {
+				f.state = stateChunkEnd
+			}
+		}
+		return n, 0, true
+	case stateChunkSize:
+		return f.chunkSize(raw)
+	case stateChunkEnd:
+		if raw[0] != '\r' || (len(raw) > 1 && raw[1] != '\n') {
+			return 0, ReasonMalformedChunk, false
+		}
+		if len(raw) < 2 {
+			return 0, 0, true
+		}
+		f.state = stateChunkSize
+		return 2, 0, true
+	case stateTrailer:
+		return f.trailer(raw)
+	default:
+		return len(raw), 0, true
+	}
+}
+
+// endMessage resets the framer for the next request on the connection
+func (f *framer) endMessage() {
+	f.released = false
+	f.state = stateHead
+	if f.upgrade {
+		f.state = stateOpaque
+	}
+}
+
+// head validates a complete request head
+func (f *framer) head(raw []byte) (int, Reason, bool) {
+	// HTTP/2 prior knowledge, leave it to net/http
+	if bytes.HasPrefix(raw, []byte("PRI ")) {
+		f.state = stateOpaque
+		return len(raw), 0, true
+	}
+
+	// TLS handshake record, the requests are encrypted and left to net/http
+	if raw[0] == tlsHandshakeRecord {
+		f.state = stateOpaque
+		return len(raw), 0, true
+	}
+
+	end := bytes.Index(raw, []byte("\r\n\r\n"))
+	scan := raw
+	if end >= 0 {
+		scan = raw[:end+4]
+	}
+	if hasBareLF(scan) {
+		return 0, ReasonMalformedHeader, false
+	}
+	if end < 0 {
+		if len(raw) > f.config.MaxHeadBytes {
+			// Too large to inspect, net/http rejects it on its own
+			f.state = stateOpaque
+			return len(raw), 0, true
+		}
+		return 0, 0, true
+	}
+
+	lines := strings.Split(strings.TrimLeft(string(raw[:end]), "\r\n"), "\r\n")
+	method, _, _ := strings.Cut(lines[0], " ")
+	http10 := strings.HasSuffix(lines[0], " HTTP/1.0")
+
+	var (
+		contentLength, transferEncoding    []string
+		hosts                              int
+		connectionUpgrade, upgradeProtocol bool
+	)
+	for _, line := range lines[1:] {
+		name, value, ok := parseField(line)
+		if !ok {
+			return 0, ReasonMalformedHeader, false
+		}
+
+		switch strings.ToLower(name) {
+		case "content-length":
+			contentLength = append(contentLength, value)
+		case "transfer-encoding":
+			transferEncoding = append(transferEncoding, value)
+		case "host":
+			hosts++
+		case "connection":
+			connectionUpgrade = connectionUpgrade || hasToken(value, "upgrade")
+			continue
+		case "upgrade":
+			upgradeProtocol = true
+			continue
+		default:
+			continue
+		}
+
+		if len(value) > f.config.MaxCriticalHeaderBytes {
+			return 0, ReasonOversizedHeader, false
+		}
+	}
+
+	if len(transferEncoding) > 0 && (len(contentLength) > 0 || http10) {
+		return 0, ReasonConflictingFraming, false
+	}
+	if len(contentLength) > 1 || len(transferEncoding) > 1 || hosts > 1 {
+		return 0, ReasonDuplicateHeader, false
+	}
+
+	next, remaining := stateHead, int64(0)
+	switch {
+	case len(transferEncoding) == 1:
+		if !isChunkedFinal(transferEncoding[0]) {
+			return 0, ReasonMalformedHeader, false
+		}
+		next = stateChunkSize
+	case len(contentLength) == 1:
+		if strings.Contains(contentLength[0], ",") {
+			return 0, ReasonDuplicateHeader, false
+		}
+		length, err := strconv.ParseInt(contentLength[0], 10, 64)
+		if err != nil || length < 0 || contentLength[0][0] == '+' {
+			return 0, ReasonMalformedHeader, false
+		}
+		if length > 0 {
+			next, remaining = stateBody, length
+		}
+	}
+
+	f.upgrade = method == "CONNECT" || (connectionUpgrade && upgradeProtocol)
+	f.state, f.remaining = next, remaining
+	f.released = true
+	if next == stateHead {
+		f.endMessage()
+	}
+	return end + 4, 0, true
+}
+
+// chunkSize validates a chunk size line and its extensions
+func (f *framer) chunkSize(raw []byte) (int, Reason, bool) {
+	end := bytes.IndexByte(raw, '\n')
+	if end < 0 {
+		if len(raw) > f.config.MaxChunkLineBytes {
+			return 0, ReasonMalformedChunk, false
+		}
+		return 0, 0, true
+	}
+	if end+1 > f.config.MaxChunkLineBytes || end == 0 || raw[end-1] != '\r' {
+		return 0, ReasonMalformedChunk, false
+	}
+
+	line := string(raw[:end-1])
+	digits := 0
+	for digits < len(line) && isHex(line[digits]) {
+		digits++
+	}
+	if digits == 0 {
+		return 0, ReasonMalformedChunk, false
+	}
+	size, err := strconv.ParseInt(line[:digits], 16, 64)
+	if err != nil || !validChunkExtensions(line[digits:]) {
+		return 0, ReasonMalformedChunk, false
+	}
+
+	if size == 0 {
+		f.state = stateTrailer
+	} else {
+		f.state = stateChunkData
+		f.remaining = size
+	}
+	return end + 1, 0, true
+}
+
+// trailer validates a single trailer field line, or the terminating CRLF
+func (f *framer) trailer(raw []byte) (int, Reason, bool) {
+	end := bytes.IndexByte(raw, '\n')
+	if end < 0 {
+		if len(raw) > f.config.MaxHeadBytes {
+			return 0, ReasonMalformedHeader, false
+		}
+		return 0, 0, true
+	}
+	if end == 0 || raw[end-1] != '\r' {
+		return 0, ReasonMalformedChunk, false
+	}
+
+	line := string(raw[:end-1])
+	if line == "" {
+		f.endMessage()
+		return end + 1, 0, true
+	}
+	if _, _, ok := parseField(line); !ok {
+		return 0, ReasonMalformedHeader, false
+	}
+	return end + 1, 0, true
+}
+
+// parseField splits a header field line into its name and trimmed value
+//
+// @return: false for obsolete line folding, a missing colon, or a name
+// that is not a token (including whitespace before the colon)
+func parseField(line string) (string, string, bool) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || tokenLen(name) != len(name) || len(name) == 0 {
+		return "", "", false
+	}
+	return name, strings.Trim(value, " \t"), true
+}
+
+// validChunkExtensions validates the chunk-ext production of RFC 9112:
+//
+//	chunk-ext = *( BWS ";" BWS ext-name [ BWS "=" BWS ext-val ] )
+func validChunkExtensions(s string) bool {
+	for {
+		s = trimBWS(s)
+		if s == "" {
+			return true
+		}
+		if s[0] != ';' {
+			return false
+		}
+
+		s = trimBWS(s[1:])
+		n := tokenLen(s)
+		if n == 0 {
+			return false
+		}
+		s = trimBWS(s[n:])
+
+		if s == "" || s[0] != '=' {
+			continue
+		}
+		s = trimBWS(s[1:])
+		if s != "" && s[0] == '"' {
+			n = quotedStringLen(s)
+		} else {
+			n = tokenLen(s)
+		}
+		if n <= 0 {
+			return false
+		}
+		s = s[n:]
+	}
+}
+
+// quotedStringLen returns the length of the quoted-string at the start of
+// s, or -1 if it is malformed or unterminated
+func quotedStringLen(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return i + 1
+		case c == '\\':
+			i++
+			if i >= len(s) || !isQuotedText(s[i]) && s[i] != '"' && s[i] != '\\' {
+				return -1
+			}
+		case !isQuotedText(c):
+			return -1
+		}
+	}
+	return -1
+}
+
+// isQuotedText reports whether c is qdtext
+func isQuotedText(c byte) bool {
+	return c == '\t' || c == ' ' || c == 0x21 ||
+		(c >= 0x23 && c <= 0x5b) || (c >= 0x5d && c <= 0x7e) || c >= 0x80
+}
+
+// tokenLen returns the length of the token at the start of s
+func tokenLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// isTokenChar reports whether c is a tchar
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	default:
+		return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+	}
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// trimBWS trims leading optional whitespace
+func trimBWS(s string) string {
+	return strings.TrimLeft(s, " \t")
+}
+
+// hasBareLF reports whether b contains a LF not preceded by a CR
+func hasBareLF(b []byte) bool {
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			return true
+		}
+	}
+	return false
+}
+
+// hasToken reports whether a comma separated header value contains token
+func hasToken(value, token string) bool {
+	for part := range strings.SplitSeq(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// isChunkedFinal reports whether chunked is the final, and only, chunked
+// transfer coding in a Transfer-Encoding value
+func isChunkedFinal(value string) bool {
+	codings := strings.Split(value, ",")
+	for i, coding := range codings {
+		chunked := strings.EqualFold(strings.TrimSpace(coding), "chunked")
+		if chunked != (i == len(codings)-1) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package guard
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// DefaultMaxCriticalHeaderBytes is the default size limit for the values
+	// of the Content-Length, Transfer-Encoding and Host headers
+	DefaultMaxCriticalHeaderBytes = 512
+
+	// DefaultMaxHeadBytes is the default amount of request head inspected
+	// before deferring to net/http, which rejects it with its own limits
+	DefaultMaxHeadBytes = 1<<20 + 4096
+
+	// DefaultMaxChunkLineBytes is the default size limit for a chunk size
+	// line including its extensions
+	DefaultMaxChunkLineBytes = 4096
+)
+
+// Config configures the framing checks applied to each connection
+type Config struct {
+	// Size limit for the values of critical framing headers
+	MaxCriticalHeaderBytes int
+
+	// Amount of request head inspected before deferring to net/http
+	MaxHeadBytes int
+
+	// Size limit for a chunk size line including its extensions
+	MaxChunkLineBytes int
+
+	// Counters for rejected requests, may be nil
+	Stats *Stats
+
+	// Called for every rejected request, may be nil
+	OnReject func(reason Reason, remote net.Addr)
+
+	// Time allowed for the TLS handshakes of NewTLSListener
+	HandshakeTimeout time.Duration
+}
+
+// withDefaults returns the configuration with the defaults of the unset
+// limits
+func (c Config) withDefaults() Config {
+	if c.MaxCriticalHeaderBytes <= 0 {
+		c.MaxCriticalHeaderBytes = DefaultMaxCriticalHeaderBytes
+	}
+	if c.MaxHeadBytes <= 0 {
+		c.MaxHeadBytes = DefaultMaxHeadBytes
+	}
+	if c.MaxChunkLineBytes <= 0 {
+		c.MaxChunkLineBytes = DefaultMaxChunkLineBytes
+	}
+	if c.HandshakeTimeout <= 0 {
+		c.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	return c
+}
+
+// NewListener wraps a listener so that every accepted connection validates
+// HTTP/1.x message framing before the bytes reach net/http
+//
+// The guard rejects requests carrying both Transfer-Encoding and
+// Content-Length, duplicate or oversized framing headers, bare LF line
+// endings, obsolete line folding and malformed chunk size lines. Rejected
+// requests receive a 400 response when nothing of them has been delivered
+// yet, otherwise the connection read fails and net/http closes it.
+//
+// Connections opening with a TLS handshake are passed through unchecked,
+// use NewTLSListener over the TLS listener to validate their requests.
+// HTTP/2 connections and upgraded or tunnelled connections are passed
+// through as well.
+func NewListener(ln net.Listener, config Config) net.Listener {
+	return &listener{Listener: ln, config: config.withDefaults()}
+}
+
+// listener wraps accepted connections with framing validation
+type listener struct {
+	net.Listener
+	config Config
+}
+
+// Accept implements net.Listener
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newConn(c, &l.config), nil
+}
+
+// conn validates the framing of the requests read from a connection
+//
+// Bytes are read into raw, validated by the framing state machine and only
+// then moved to ready, from which Read serves the caller.
+type conn struct {
+	net.Conn
+	config *Config
+	framer framer
+	buf    [4096]byte
+	raw    []byte
+	ready  []byte
+	err    error
+}
+
+func newConn(c net.Conn, config *Config) *conn {
+	return &conn{
+		Conn:   c,
+		config: config,
+		framer: framer{config: config},
+	}
+}
+
+// Read implements net.Conn
+func (c *conn) Read(p []byte) (int, error) {
+	for len(c.ready) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+
+		// Nothing left to validate, avoid the extra copy
+		if c.framer.state == stateOpaque && len(c.raw) == 0 {
+			return c.Conn.Read(p)
+		}
+
+		n, reason, ok := c.framer.advance(c.raw)
+		if !ok {
+			c.reject(reason)
+			continue
+		}
+		if n > 0 {
+			c.ready = append(c.ready, c.raw[:n]...)
+			c.raw = c.raw[n:]
+			continue
+		}
+
+		read, err := c.Conn.Read(c.buf[:])
+		c.raw = append(c.raw, c.buf[:read]...)
+		if err != nil {
+			// Hand whatever is left to net/http and let it report the error
+			c.ready = append(c.ready, c.raw...)
+			c.raw = nil
+			c.err = err
+		}
+	}
+
+	n := copy(p, c.ready)
+	c.ready = c.ready[n:]
+	return n, nil
+}
+
+// CloseWrite shuts down the writing side of the connection if supported
+func (c *conn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// ReadFrom lets responses use the underlying connection's fast path
+func (c *conn) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := c.Conn.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(c.Conn, r)
+}
+
+// reject records a framing violation and terminates the connection
+func (c *conn) reject(reason Reason) {
+	c.config.Stats.record(reason)
+	if c.config.OnReject != nil {
+		c.config.OnReject(reason, c.RemoteAddr())
+	}
+
+	c.raw = nil
+	if !c.framer.released {
+		// Nothing of this request reached net/http, so answer it here and
+		// let the server see a cleanly closed connection
+		io.WriteString(c.Conn, badRequestResponse)
+		c.err = io.EOF
+		return
+	}
+	c.err = &RejectError{Reason: reason}
+}
+
+const badRequestResponse = "HTTP/1.1 400 Bad Request\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Connection: close\r\n" +
+	"Content-Length: 15\r\n" +
+	"\r\n" +
+	"400 Bad Request"
+
+// RejectError is returned from reads on a connection whose request was
+// rejected after part of it had already been delivered
+type RejectError struct {
+	Reason Reason
+}
+
+func (e *RejectError) Error() string {
+	return "guard: request rejected: " + e.Reason.String()
+}
@@ -0,0 +1,255 @@
+package guard
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer starts an HTTP server behind the guard that echoes the
+// request body, or the body read error
+func newTestServer(t *testing.T, stats *Stats) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Write(body)
+		}),
+	}
+	go server.Serve(NewListener(ln, Config{Stats: stats}))
+	t.Cleanup(func() { server.Close() })
+
+	return ln.Addr().String()
+}
+
+// roundTrip writes a raw request and reads the responses that follow
+func roundTrip(t *testing.T, addr, raw string, responses int) []*http.Response {
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.SetDeadline(time.Now().Add(5*time.Second)))
+
+	_, err = io.WriteString(c, raw)
+	require.NoError(t, err)
+
+	var result []*http.Response
+	reader := bufio.NewReader(c)
+	for range responses {
+		resp, err := http.ReadResponse(reader, nil)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body = io.NopCloser(strings.NewReader(string(body)))
+		result = append(result, resp)
+	}
+	return result
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestListener_ValidRequests(t *testing.T) {
+	stats := &Stats{}
+	addr := newTestServer(t, stats)
+
+	raw := "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"3;name=value;quoted=\"a b\\\"c\"\r\nwor\r\n2;flag\r\nld\r\n0\r\nX-Trailer: done\r\n\r\n" +
+		"GET / HTTP/1.1\r\nHost: x\r\n\r\n"
+
+	responses := roundTrip(t, addr, raw, 3)
+	require.Equal(t, http.StatusOK, responses[0].StatusCode)
+	require.Equal(t, "hello", readBody(t, responses[0]))
+	require.Equal(t, http.StatusOK, responses[1].StatusCode)
+	require.Equal(t, "world", readBody(t, responses[1]))
+	require.Equal(t, http.StatusOK, responses[2].StatusCode)
+	require.Zero(t, stats.Total())
+}
+
+func TestListener_RejectedHeads(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		reason Reason
+	}{
+		{
+			"transfer-encoding and content-length",
+			"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+			ReasonConflictingFraming,
+		},
+		{
+			"transfer-encoding on HTTP/1.0",
+			"POST / HTTP/1.0\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+			ReasonConflictingFraming,
+		},
+		{
+			"duplicate content-length",
+			"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\nhello",
+			ReasonDuplicateHeader,
+		},
+		{
+			"content-length list",
+			"POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5, 5\r\n\r\nhello",
+			ReasonDuplicateHeader,
+		},
+		{
+			"duplicate transfer-encoding",
+			"POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+			ReasonDuplicateHeader,
+		},
+		{
+			"oversized host",
+			"GET / HTTP/1.1\r\nHost: " + strings.Repeat("a", DefaultMaxCriticalHeaderBytes+1) + "\r\n\r\n",
+			ReasonOversizedHeader,
+		},
+		{
+			"bare LF",
+			"GET / HTTP/1.1\nHost: x\n\n",
+			ReasonMalformedHeader,
+		},
+		{
+			"obsolete line folding",
+			"GET / HTTP/1.1\r\nHost: x\r\nX-Folded: a\r\n b\r\n\r\n",
+			ReasonMalformedHeader,
+		},
+		{
+			"whitespace before colon",
+			"POST / HTTP/1.1\r\nHost: x\r\nContent-Length : 5\r\n\r\nhello",
+			ReasonMalformedHeader,
+		},
+		{
+			"chunked not final",
+			"POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked, gzip\r\n\r\n0\r\n\r\n",
+			ReasonMalformedHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &Stats{}
+			addr := newTestServer(t, stats)
+
+			responses := roundTrip(t, addr, tt.raw, 1)
+			require.Equal(t, http.StatusBadRequest, responses[0].StatusCode)
+			require.Equal(t, uint64(1), stats.Count(tt.reason))
+			require.Equal(t, uint64(1), stats.Total())
+		})
+	}
+}
+
+func TestListener_RejectedChunks(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"unterminated quoted extension", "5;ext=\"bad\r\nhello\r\n0\r\n\r\n"},
+		{"empty extension name", "5;=value\r\nhello\r\n0\r\n\r\n"},
+		{"invalid size", "zz\r\nhello\r\n0\r\n\r\n"},
+		{"bare LF after size", "5\nhello\r\n0\r\n\r\n"},
+		{"missing chunk terminator", "5\r\nhelloXX0\r\n\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &Stats{}
+			addr := newTestServer(t, stats)
+
+			raw := "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n" + tt.raw
+			responses := roundTrip(t, addr, raw, 1)
+			require.Equal(t, http.StatusBadRequest, responses[0].StatusCode)
+			require.Contains(t, readBody(t, responses[0]), ReasonMalformedChunk.String())
+			require.Equal(t, uint64(1), stats.Count(ReasonMalformedChunk))
+		})
+	}
+}
+
+func TestListener_TLS(t *testing.T) {
+	stats := &Stats{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto+" tls="+strconv.FormatBool(r.TLS != nil))
+	}))
+	server.Listener = NewListener(server.Listener, Config{Stats: stats})
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	// The handshake passes through the guard, below the TLS listener
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "HTTP/2.0 tls=true", readBody(t, resp))
+	require.Zero(t, stats.Total())
+}
+
+func TestTLSListener(t *testing.T) {
+	stats := &Stats{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RestoreTLS(r)
+		io.WriteString(w, r.Proto+" tls="+strconv.FormatBool(r.TLS != nil))
+	})
+	// The certificate of an httptest server, trusted by its client
+	certs := httptest.NewTLSServer(handler)
+	certs.Close()
+	config := certs.TLS.Clone()
+	config.NextProtos = []string{"h2", "http/1.1"}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: handler, TLSConfig: config, ConnContext: ConnContext}
+	go server.Serve(NewTLSListener(tls.NewListener(ln, config), Config{Stats: stats}))
+	defer server.Close()
+	url := "https://" + ln.Addr().String()
+
+	// HTTP/2 connections keep their *tls.Conn
+	trusted := certs.Client().Transport.(*http.Transport).TLSClientConfig
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: trusted.Clone(), ForceAttemptHTTP2: true}}
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0 tls=true", readBody(t, resp))
+
+	// HTTP/1.1 ones are validated, keeping their TLS state
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: trusted.Clone()}}
+	resp, err = client.Get(url)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/1.1 tls=true", readBody(t, resp))
+	require.Zero(t, stats.Total())
+
+	c, err := tls.Dial("tcp", ln.Addr().String(), trusted.Clone())
+	require.NoError(t, err)
+	defer c.Close()
+	io.WriteString(c, "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n"+
+		"Transfer-Encoding: chunked\r\n\r\n0\r\n\r\n")
+	resp, err = http.ReadResponse(bufio.NewReader(c), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, uint64(1), stats.Count(ReasonConflictingFraming))
+}
+
+func TestValidChunkExtensions(t *testing.T) {
+	valid := []string{"", ";a", "; a = b", ";a=b;c", `;a="x\"y"`, " ;a", "\t"}
+	for _, ext := range valid {
+		require.True(t, validChunkExtensions(ext), ext)
+	}
+
+	invalid := []string{";", ";a=", `;a="x`, ";a b", "x", ";a=b c", ";a=\"\x01\""}
+	for _, ext := range invalid {
+		require.False(t, validChunkExtensions(ext), ext)
+	}
+}
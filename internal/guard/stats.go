@@ -0,0 +1,65 @@
+package guard
+
+import "sync/atomic"
+
+// Reason identifies why a request was rejected
+type Reason int
+
+const (
+	ReasonConflictingFraming Reason = iota // both Transfer-Encoding and Content-Length
+	ReasonDuplicateHeader                  // repeated Content-Length, Transfer-Encoding or Host
+	ReasonOversizedHeader                  // critical header value over the configured limit
+	ReasonMalformedHeader                  // bare LF, obsolete line folding, invalid field syntax
+	ReasonMalformedChunk                   // invalid chunk size, extension or terminator
+	numReasons
+)
+
+// String returns a short description of the reason
+func (r Reason) String() string {
+	switch r {
+	case ReasonConflictingFraming:
+		return "conflicting framing"
+	case ReasonDuplicateHeader:
+		return "duplicate header"
+	case ReasonOversizedHeader:
+		return "oversized header"
+	case ReasonMalformedHeader:
+		return "malformed header"
+	case ReasonMalformedChunk:
+		return "malformed chunk"
+	default:
+		return "unknown"
+	}
+}
+
+// Stats counts rejected requests by reason
+//
+// The zero value is ready to use and safe for concurrent use.
+type Stats struct {
+	counters [numReasons]atomic.Uint64
+}
+
+// Count returns the number of requests rejected for the given reason
+func (s *Stats) Count(reason Reason) uint64 {
+	if reason < 0 || reason >= numReasons {
+		return 0
+	}
+	return s.counters[reason].Load()
+}
+
+// Total returns the number of rejected requests across all reasons
+func (s *Stats) Total() uint64 {
+	var total uint64
+	for i := range s.counters {
+		total += s.counters[i].Load()
+	}
+	return total
+}
+
+// record increments the counter for the given reason
+func (s *Stats) record(reason Reason) {
+	if s == nil || reason < 0 || reason >= numReasons {
+		return
+	}
+	s.counters[reason].Add(1)
+}
@@ -0,0 +1,146 @@
+package guard
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHandshakeTimeout is the default time allowed for the TLS
+// handshakes of NewTLSListener
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// NewTLSListener wraps a listener of TLS connections, e.g. of
+// tls.NewListener, so that the HTTP/1.x requests inside them are validated
+// like those of NewListener
+//
+// The handshakes complete concurrently before the connections are
+// accepted, within the handshake timeout, so that the negotiated protocol
+// is known: HTTP/2 and other negotiated protocols keep their *tls.Conn,
+// HTTP/1.x connections are validated. As net/http only sets Request.TLS
+// for *tls.Conn, servers set ConnContext as their http.Server.ConnContext
+// and call RestoreTLS in their handler.
+func NewTLSListener(ln net.Listener, config Config) net.Listener {
+	return &tlsListener{
+		Listener: ln,
+		config:   config.withDefaults(),
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+}
+
+// tlsListener accepts the connections of the wrapped listener in the
+// background, handing them to Accept once their handshake completed
+type tlsListener struct {
+	net.Listener
+	config    Config
+	start     sync.Once
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept implements net.Listener
+func (l *tlsListener) Accept() (net.Conn, error) {
+	l.start.Do(func() { go l.acceptLoop() })
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener, closing the connections still in their
+// handshake once it completes
+func (l *tlsListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// acceptLoop accepts the connections and starts their handshake
+func (l *tlsListener) acceptLoop() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go l.handshake(c)
+	}
+}
+
+// handshake completes the handshake of a connection and hands it to
+// Accept, wrapped with framing validation for HTTP/1.x
+func (l *tlsListener) handshake(c net.Conn) {
+	if tc, ok := c.(*tls.Conn); ok {
+		tc.SetDeadline(time.Now().Add(l.config.HandshakeTimeout))
+		if err := tc.Handshake(); err != nil {
+			tc.Close()
+			return
+		}
+		tc.SetDeadline(time.Time{})
+
+		switch tc.ConnectionState().NegotiatedProtocol {
+		case "", "http/1.1", "http/1.0":
+			c = &tlsConn{newConn(tc, &l.config)}
+		}
+	}
+
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		c.Close()
+	}
+}
+
+// tlsConn validates the framing of the requests read from a TLS
+// connection
+type tlsConn struct {
+	*conn
+}
+
+// ConnectionState returns the state of the TLS connection
+func (c *tlsConn) ConnectionState() tls.ConnectionState {
+	return c.Conn.(*tls.Conn).ConnectionState()
+}
+
+// tlsStateKey is the context key of the TLS state saved by ConnContext
+type tlsStateKey struct{}
+
+// ConnContext saves the TLS state of the validated connections of
+// NewTLSListener, for http.Server.ConnContext, see RestoreTLS
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if tc, ok := c.(*tlsConn); ok {
+		state := tc.ConnectionState()
+		return context.WithValue(ctx, tlsStateKey{}, &state)
+	}
+	return ctx
+}
+
+// RestoreTLS sets the Request.TLS that net/http leaves nil for the
+// validated connections of NewTLSListener, from the state saved by
+// ConnContext
+func RestoreTLS(r *http.Request) {
+	if r.TLS != nil {
+		return
+	}
+	if state, ok := r.Context().Value(tlsStateKey{}).(*tls.ConnectionState); ok {
+		r.TLS = state
+	}
+}
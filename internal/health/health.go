@@ -0,0 +1,122 @@
+// Package health aggregates the checks of the dependencies of a service,
+// e.g. its databases, and serves their status
+package health
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultTimeout bounds every check when Registry.Timeout is not set
+const DefaultTimeout = 5 * time.Second
+
+// Checker checks that a dependency is usable
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check implements Checker
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the outcome of every check of a registry
+type Report struct {
+	Healthy bool              `json:"healthy"`
+	Checks  map[string]Result `json:"checks"`
+}
+
+// Registry runs named checkers concurrently. The zero value is ready to
+// use.
+type Registry struct {
+	// Timeout of every check, DefaultTimeout if 0
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	names    []string
+	checkers map[string]Checker
+}
+
+// Register adds a checker, replacing any checker with the same name
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.checkers == nil {
+		r.checkers = make(map[string]Checker)
+	}
+	if _, ok := r.checkers[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checkers[name] = checker
+}
+
+// Check runs every checker, the report is healthy when all of them are
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	names := slices.Clone(r.names)
+	checkers := make([]Checker, len(names))
+	for i, name := range names {
+		checkers[i] = r.checkers[name]
+	}
+	r.mu.RUnlock()
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checker.Check(ctx)
+			results[i] = Result{Healthy: err == nil, Duration: time.Since(start)}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Healthy: true, Checks: make(map[string]Result, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		report.Healthy = report.Healthy && results[i].Healthy
+	}
+	return report
+}
+
+// Handler serves the report of the registry as JSON, with 503 Service
+// Unavailable when a check fails
+func (r *Registry) Handler() types.HandlerFunc {
+	return func(c *types.Context) {
+		report := r.Check(c.Request.Context())
+		status := http.StatusOK
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
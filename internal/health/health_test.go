@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Pinger = (*sql.DB)(nil)
+
+// fakeDB is a database answering pings after a delay
+type fakeDB struct {
+	delay time.Duration
+}
+
+func (db fakeDB) PingContext(ctx context.Context) error {
+	select {
+	case <-time.After(db.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	var registry Registry
+	registry.Register("db", SQL(fakeDB{}, time.Second))
+	registry.Register("cache", CheckerFunc(func(context.Context) error { return nil }))
+
+	report := registry.Check(context.Background())
+	require.True(t, report.Healthy)
+	require.Len(t, report.Checks, 2)
+
+	// Slow databases time out
+	registry.Register("db", SQL(fakeDB{delay: time.Second}, 10*time.Millisecond))
+	registry.Register("queue", CheckerFunc(func(context.Context) error { return errors.New("unreachable") }))
+	report = registry.Check(context.Background())
+	require.False(t, report.Healthy)
+	require.Len(t, report.Checks, 3)
+	require.True(t, report.Checks["cache"].Healthy)
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Checks["db"].Error)
+	require.Equal(t, "unreachable", report.Checks["queue"].Error)
+
+	w := httptest.NewRecorder()
+	registry.Handler()(&types.Context{Request: httptest.NewRequest(http.MethodGet, "/health", nil), Writer: w})
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var served Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	require.False(t, served.Healthy)
+	require.False(t, served.Checks["queue"].Healthy)
+}
@@ -0,0 +1,24 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+// Pinger is a database connection pool, e.g. *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// SQL checks a database by pinging it, within the timeout when it is
+// shorter than the timeout of the registry
+func SQL(db Pinger, timeout time.Duration) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return db.PingContext(ctx)
+	})
+}
@@ -0,0 +1,243 @@
+package httpsig
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// message is the request or response whose components are signed
+type message struct {
+	request *http.Request // the request, or the request of the response
+	header  http.Header
+	status  int // 0 for requests
+}
+
+// component is a covered component, e.g. "@method" or "content-digest";req
+type component struct {
+	name string
+	req  bool // the component of the request of a response
+}
+
+// parseComponent parses a component as written in configurations, e.g.
+// "@method;req"
+func parseComponent(s string) (component, error) {
+	name, params, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ";")
+	c := component{name: name}
+	switch params {
+	case "":
+	case "req":
+		c.req = true
+	default:
+		return c, fmt.Errorf("httpsig: unsupported component parameters %q", params)
+	}
+	if name == "" {
+		return c, fmt.Errorf("httpsig: empty component in %q", s)
+	}
+	return c, nil
+}
+
+// item returns the structured field item identifying the component
+func (c component) item() item {
+	it := item{value: c.name}
+	if c.req {
+		it.params = []param{{name: "req", value: true}}
+	}
+	return it
+}
+
+// value returns the value of a component of the message
+//
+// @return: an error if the message does not have the component
+func (m *message) value(c component) (string, error) {
+	if c.req {
+		if m.status == 0 {
+			return "", fmt.Errorf("httpsig: %s;req in a request", c.name)
+		}
+		if m.request == nil {
+			return "", fmt.Errorf("httpsig: %s;req without request", c.name)
+		}
+		m = &message{request: m.request, header: m.request.Header}
+	}
+
+	r := m.request
+	if r == nil && c.name != "@status" && strings.HasPrefix(c.name, "@") {
+		return "", fmt.Errorf("httpsig: %s without request", c.name)
+	}
+	switch c.name {
+	case "@method":
+		return r.Method, nil
+	case "@target-uri":
+		return scheme(r) + "://" + authority(r) + requestTarget(r), nil
+	case "@authority":
+		return authority(r), nil
+	case "@scheme":
+		return scheme(r), nil
+	case "@request-target":
+		return requestTarget(r), nil
+	case "@path":
+		if path := r.URL.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	case "@status":
+		if m.status == 0 {
+			return "", fmt.Errorf("httpsig: @status in a request")
+		}
+		return strconv.Itoa(m.status), nil
+	}
+	if strings.HasPrefix(c.name, "@") {
+		return "", fmt.Errorf("httpsig: unsupported component %s", c.name)
+	}
+
+	values := m.header.Values(c.name)
+	if len(values) == 0 {
+		return "", fmt.Errorf("httpsig: missing header %s", c.name)
+	}
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+	}
+	return strings.Join(values, ", "), nil
+}
+
+// scheme returns the scheme of a request, lower-cased
+func scheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// authority returns the host of a request, lower-cased and without the
+// default port of its scheme
+func authority(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	host = strings.ToLower(host)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if (port == "80" && scheme(r) == "http") || (port == "443" && scheme(r) == "https") {
+			return h
+		}
+	}
+	return host
+}
+
+// requestTarget returns the path and query of a request
+func requestTarget(r *http.Request) string {
+	if r.RequestURI != "" && !strings.Contains(r.RequestURI, "://") {
+		return r.RequestURI
+	}
+	return r.URL.RequestURI()
+}
+
+// signature is a parsed Signature-Input member
+type signature struct {
+	label      string
+	components []component
+	params     []param
+
+	created int64 // 0 if absent
+	expires int64 // 0 if absent
+	keyID   string
+	alg     string
+	nonce   string
+}
+
+// parseSignatures parses the Signature-Input of a message
+//
+// @return: the signatures, in order
+func parseSignatures(header http.Header) ([]*signature, error) {
+	members, err := parseDictionary(strings.Join(header.Values("Signature-Input"), ", "))
+	if err != nil {
+		return nil, fmt.Errorf("%w: Signature-Input: %v", ErrInvalidSignature, err)
+	}
+
+	sigs := make([]*signature, 0, len(members))
+	for _, m := range members {
+		if m.list == nil && m.value != nil {
+			return nil, fmt.Errorf("%w: Signature-Input %s is not a list", ErrInvalidSignature, m.name)
+		}
+		sig := &signature{label: m.name, params: m.params}
+		for _, it := range m.list {
+			name, ok := it.value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid component in %s", ErrInvalidSignature, m.name)
+			}
+			c := component{name: name}
+			for _, p := range it.params {
+				if p.name != "req" || p.value != true {
+					return nil, fmt.Errorf("%w: unsupported component parameter %s", ErrInvalidSignature, p.name)
+				}
+				c.req = true
+			}
+			sig.components = append(sig.components, c)
+		}
+		for _, p := range m.params {
+			var ok bool
+			switch p.name {
+			case "created":
+				sig.created, ok = p.value.(int64)
+			case "expires":
+				sig.expires, ok = p.value.(int64)
+			case "keyid":
+				sig.keyID, ok = p.value.(string)
+			case "alg":
+				sig.alg, ok = p.value.(string)
+			case "nonce":
+				sig.nonce, ok = p.value.(string)
+			default:
+				ok = true
+			}
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid parameter %s in %s", ErrInvalidSignature, p.name, m.name)
+			}
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// input returns the serialized Signature-Input member value, also the
+// value of the @signature-params component
+func (s *signature) input() string {
+	items := make([]item, len(s.components))
+	for i, c := range s.components {
+		items[i] = c.item()
+	}
+	return serializeInnerList(items, s.params)
+}
+
+// base returns the signature base of the message, see RFC 9421 section 2.5
+//
+// @return: an error if the message does not have a covered component
+func (s *signature) base(m *message) ([]byte, error) {
+	var b strings.Builder
+	seen := make(map[component]bool, len(s.components))
+	for _, c := range s.components {
+		if seen[c] {
+			return nil, fmt.Errorf("httpsig: duplicate component %s", c.name)
+		}
+		seen[c] = true
+
+		value, err := m.value(c)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(serializeItem(c.item().value, c.item().params))
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(s.input())
+	return []byte(b.String()), nil
+}
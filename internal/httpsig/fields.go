@@ -0,0 +1,308 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// The Signature-Input and Signature fields are structured field
+// dictionaries, see RFC 8941. Only the parts used by signatures are
+// supported: no decimals and no nested lists in members.
+
+// token is a structured field token, serialized without quotes
+type token string
+
+// param is a structured field parameter, kept in order
+type param struct {
+	name  string
+	value any // int64, string, token, []byte or bool
+}
+
+// item is a structured field item with its parameters
+type item struct {
+	value  any
+	params []param
+}
+
+// member is a dictionary member, an item or an inner list
+type member struct {
+	name   string
+	value  any    // set for items
+	list   []item // set for inner lists
+	params []param
+}
+
+// errMalformed is returned for fields that are not valid dictionaries
+var errMalformed = errors.New("malformed structured field")
+
+// fieldParser parses structured fields
+type fieldParser struct {
+	s string
+	i int
+}
+
+// parseDictionary parses a dictionary field
+func parseDictionary(s string) ([]member, error) {
+	p := &fieldParser{s: s}
+	p.skip(" \t")
+
+	var members []member
+	for p.i < len(p.s) {
+		name, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		m := member{name: name, value: true}
+		if p.consume('=') {
+			if p.peek() == '(' {
+				m.value = nil
+				if m.list, err = p.innerList(); err != nil {
+					return nil, err
+				}
+			} else if m.value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		if m.params, err = p.params(); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+
+		p.skip(" \t")
+		if p.i == len(p.s) {
+			break
+		}
+		if !p.consume(',') {
+			return nil, errMalformed
+		}
+		p.skip(" \t")
+		if p.i == len(p.s) {
+			return nil, errMalformed
+		}
+	}
+	return members, nil
+}
+
+// peek returns the next byte, 0 at the end
+func (p *fieldParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+// consume skips the next byte if it is c
+func (p *fieldParser) consume(c byte) bool {
+	if p.peek() == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// skip skips the bytes in chars
+func (p *fieldParser) skip(chars string) {
+	for p.i < len(p.s) && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// key parses a dictionary or parameter key
+func (p *fieldParser) key() (string, error) {
+	start := p.i
+	if c := p.peek(); !isLower(c) && c != '*' {
+		return "", errMalformed
+	}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !isLower(c) && !isDigit(c) && !strings.ContainsRune("_-.*", rune(c)) {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+// innerList parses a parenthesized list of items
+func (p *fieldParser) innerList() ([]item, error) {
+	p.consume('(')
+	var items []item
+	for {
+		p.skip(" ")
+		if p.consume(')') {
+			return items, nil
+		}
+		if p.i == len(p.s) {
+			return nil, errMalformed
+		}
+		value, err := p.bareItem()
+		if err != nil {
+			return nil, err
+		}
+		params, err := p.params()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item{value: value, params: params})
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, errMalformed
+		}
+	}
+}
+
+// params parses the parameters following an item or list
+func (p *fieldParser) params() ([]param, error) {
+	var params []param
+	for p.consume(';') {
+		p.skip(" ")
+		name, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var value any = true
+		if p.consume('=') {
+			if value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, param{name: name, value: value})
+	}
+	return params, nil
+}
+
+// bareItem parses an integer, string, token, byte sequence or boolean
+func (p *fieldParser) bareItem() (any, error) {
+	switch c := p.peek(); {
+	case c == '-' || isDigit(c):
+		start := p.i
+		p.consume('-')
+		p.skip("0123456789")
+		if p.i-start > 15 || p.peek() == '.' {
+			return nil, errMalformed
+		}
+		return strconv.ParseInt(p.s[start:p.i], 10, 64)
+	case c == '"':
+		var b strings.Builder
+		for p.i++; p.i < len(p.s); p.i++ {
+			switch c := p.s[p.i]; c {
+			case '"':
+				p.i++
+				return b.String(), nil
+			case '\\':
+				p.i++
+				if c := p.peek(); c != '"' && c != '\\' {
+					return nil, errMalformed
+				}
+				b.WriteByte(p.s[p.i])
+			default:
+				if c < 0x20 || c > 0x7e {
+					return nil, errMalformed
+				}
+				b.WriteByte(c)
+			}
+		}
+		return nil, errMalformed
+	case c == ':':
+		end := strings.IndexByte(p.s[p.i+1:], ':')
+		if end < 0 {
+			return nil, errMalformed
+		}
+		decoded, err := base64.StdEncoding.DecodeString(p.s[p.i+1 : p.i+1+end])
+		if err != nil {
+			return nil, errMalformed
+		}
+		p.i += end + 2
+		return decoded, nil
+	case c == '?':
+		p.i++
+		switch {
+		case p.consume('1'):
+			return true, nil
+		case p.consume('0'):
+			return false, nil
+		}
+		return nil, errMalformed
+	case isLower(c) || (c >= 'A' && c <= 'Z') || c == '*':
+		start := p.i
+		for p.i < len(p.s) && (isTokenChar(p.s[p.i]) || p.s[p.i] == ':' || p.s[p.i] == '/') {
+			p.i++
+		}
+		return token(p.s[start:p.i]), nil
+	}
+	return nil, errMalformed
+}
+
+// serializeItem serializes an item with its parameters
+func serializeItem(value any, params []param) string {
+	var b strings.Builder
+	writeBareItem(&b, value)
+	writeParams(&b, params)
+	return b.String()
+}
+
+// serializeInnerList serializes an inner list with its parameters
+func serializeInnerList(items []item, params []param) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, it := range items {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(serializeItem(it.value, it.params))
+	}
+	b.WriteByte(')')
+	writeParams(&b, params)
+	return b.String()
+}
+
+// writeParams serializes parameters, true booleans without value
+func writeParams(b *strings.Builder, params []param) {
+	for _, p := range params {
+		b.WriteByte(';')
+		b.WriteString(p.name)
+		if p.value != true {
+			b.WriteByte('=')
+			writeBareItem(b, p.value)
+		}
+	}
+}
+
+// writeBareItem serializes a bare item
+func writeBareItem(b *strings.Builder, value any) {
+	switch v := value.(type) {
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case string:
+		b.WriteByte('"')
+		for i := range len(v) {
+			if v[i] == '"' || v[i] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(v[i])
+		}
+		b.WriteByte('"')
+	case token:
+		b.WriteString(string(v))
+	case []byte:
+		b.WriteByte(':')
+		b.WriteString(base64.StdEncoding.EncodeToString(v))
+		b.WriteByte(':')
+	case bool:
+		if v {
+			b.WriteString("?1")
+		} else {
+			b.WriteString("?0")
+		}
+	}
+}
+
+func isLower(c byte) bool { return c >= 'a' && c <= 'z' }
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// isTokenChar reports whether c is a tchar, see RFC 9110
+func isTokenChar(c byte) bool {
+	return isLower(c) || isDigit(c) || (c >= 'A' && c <= 'Z') || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
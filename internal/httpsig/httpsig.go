@@ -0,0 +1,176 @@
+// Package httpsig signs and verifies HTTP messages, see RFC 9421
+//
+// Signatures cover a chosen set of components of a message, e.g. the
+// method, the target URI and the Content-Digest of a request, so that
+// business partners can authenticate each other's requests and responses
+// without relying on the transport alone.
+package httpsig
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Signature algorithms, see RFC 9421 section 3.3
+const (
+	AlgorithmHMACSHA256      = "hmac-sha256"
+	AlgorithmEd25519         = "ed25519"
+	AlgorithmECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgorithmRSAPSSSHA512    = "rsa-pss-sha512"
+	AlgorithmRSAv15SHA256    = "rsa-v1_5-sha256"
+)
+
+var (
+	// ErrNoSignature is returned when verifying an unsigned message
+	ErrNoSignature = errors.New("httpsig: no signature")
+
+	// ErrInvalidSignature is returned when a signature does not verify
+	ErrInvalidSignature = errors.New("httpsig: invalid signature")
+
+	// ErrUnknownKey is returned by key stores for unknown key ids
+	ErrUnknownKey = errors.New("httpsig: unknown key")
+)
+
+// Key is a signing or verification key
+type Key struct {
+	// ID identifies the key to the other party, sent as the keyid
+	// parameter of the signatures
+	ID string
+
+	// Algorithm of the signatures, e.g. AlgorithmEd25519
+	Algorithm string
+
+	// Secret of the hmac-sha256 keys
+	Secret []byte
+
+	// PrivateKey signs with the asymmetric algorithms: an
+	// ed25519.PrivateKey, *ecdsa.PrivateKey or *rsa.PrivateKey
+	PrivateKey crypto.Signer
+
+	// PublicKey verifies with the asymmetric algorithms, the public key of
+	// PrivateKey if nil
+	PublicKey crypto.PublicKey
+}
+
+// KeyStore resolves the keys of signatures by key id
+type KeyStore interface {
+	// Key returns the key with the id, or ErrUnknownKey
+	Key(ctx context.Context, id string) (*Key, error)
+}
+
+// Keys is a static KeyStore
+type Keys map[string]*Key
+
+// Key implements KeyStore
+func (k Keys) Key(_ context.Context, id string) (*Key, error) {
+	if key, ok := k[id]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// sign signs a signature base
+func (k *Key) sign(base []byte) ([]byte, error) {
+	if k.Algorithm == AlgorithmHMACSHA256 {
+		if len(k.Secret) == 0 {
+			return nil, errors.New("httpsig: hmac key without secret")
+		}
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(base)
+		return mac.Sum(nil), nil
+	}
+
+	switch key := k.PrivateKey.(type) {
+	case ed25519.PrivateKey:
+		if k.Algorithm == AlgorithmEd25519 {
+			return ed25519.Sign(key, base), nil
+		}
+	case *ecdsa.PrivateKey:
+		if k.Algorithm == AlgorithmECDSAP256SHA256 {
+			digest := sha256.Sum256(base)
+			r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+			if err != nil {
+				return nil, err
+			}
+			// The signature is r and s, each on 32 bytes
+			sig := make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+			return sig, nil
+		}
+	case *rsa.PrivateKey:
+		switch k.Algorithm {
+		case AlgorithmRSAPSSSHA512:
+			digest := sha512.Sum512(base)
+			return rsa.SignPSS(rand.Reader, key, crypto.SHA512, digest[:], &rsa.PSSOptions{SaltLength: 64})
+		case AlgorithmRSAv15SHA256:
+			digest := sha256.Sum256(base)
+			return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		}
+	}
+	return nil, fmt.Errorf("httpsig: key %q cannot sign with %s", k.ID, k.Algorithm)
+}
+
+// verify verifies the signature of a signature base
+func (k *Key) verify(base, sig []byte) error {
+	if k.Algorithm == AlgorithmHMACSHA256 {
+		expected, err := k.sign(base)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal(expected, sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	public := k.PublicKey
+	if public == nil && k.PrivateKey != nil {
+		public = k.PrivateKey.Public()
+	}
+
+	switch key := public.(type) {
+	case ed25519.PublicKey:
+		if k.Algorithm == AlgorithmEd25519 {
+			return checkValid(ed25519.Verify(key, base, sig))
+		}
+	case *ecdsa.PublicKey:
+		if k.Algorithm == AlgorithmECDSAP256SHA256 {
+			if len(sig) != 64 {
+				return ErrInvalidSignature
+			}
+			digest := sha256.Sum256(base)
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			return checkValid(ecdsa.Verify(key, digest[:], r, s))
+		}
+	case *rsa.PublicKey:
+		switch k.Algorithm {
+		case AlgorithmRSAPSSSHA512:
+			digest := sha512.Sum512(base)
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}
+			return checkValid(rsa.VerifyPSS(key, crypto.SHA512, digest[:], sig, opts) == nil)
+		case AlgorithmRSAv15SHA256:
+			digest := sha256.Sum256(base)
+			return checkValid(rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil)
+		}
+	}
+	return fmt.Errorf("httpsig: key %q cannot verify %s", k.ID, k.Algorithm)
+}
+
+// checkValid returns ErrInvalidSignature unless valid
+func checkValid(valid bool) error {
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
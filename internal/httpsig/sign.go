@@ -0,0 +1,194 @@
+package httpsig
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultLabel labels the signatures when SignConfig.Label is not set
+const DefaultLabel = "sig1"
+
+var (
+	// DefaultRequestComponents are signed in requests when
+	// SignConfig.Components is not set
+	DefaultRequestComponents = []string{"@method", "@target-uri", "content-type", "content-digest"}
+
+	// DefaultResponseComponents are signed in responses when
+	// SignConfig.Components is not set
+	DefaultResponseComponents = []string{"@status", "content-type", "content-digest"}
+)
+
+// SignConfig configures the signatures of messages
+type SignConfig struct {
+	// Key signing the messages, required
+	Key *Key
+
+	// Label of the signatures, DefaultLabel if empty
+	Label string
+
+	// Components covered by the signatures, e.g. "@method", "@path" or
+	// "content-digest", and "@method;req" for components of the request of
+	// a response. Headers missing from a message are not covered.
+	// DefaultRequestComponents or DefaultResponseComponents if nil.
+	Components []string
+
+	// Expires limits the validity of the signatures, unlimited if 0
+	Expires time.Duration
+
+	// Nonce adds a random nonce to the signatures, for verifiers rejecting
+	// replayed messages, see VerifyConfig.Replay
+	Nonce bool
+
+	// Tag is sent as the tag parameter, naming the application of the
+	// signatures, may be empty
+	Tag string
+}
+
+// SignRequest signs a request, e.g. an outbound request to a partner API,
+// adding its Signature-Input and Signature headers
+//
+// Content-Digest is covered when set, see middleware.Digest for the
+// digests of the responses.
+//
+// @return: an error if the configuration is invalid
+func SignRequest(r *http.Request, config SignConfig) error {
+	if config.Components == nil {
+		config.Components = DefaultRequestComponents
+	}
+	return sign(&message{request: r, header: r.Header}, config)
+}
+
+// SignResponse signs the header of a response to the request, before it
+// is written
+//
+// @return: an error if the configuration is invalid
+func SignResponse(header http.Header, status int, r *http.Request, config SignConfig) error {
+	if config.Components == nil {
+		config.Components = DefaultResponseComponents
+	}
+	return sign(&message{request: r, header: header, status: status}, config)
+}
+
+// SignResponses returns a middleware signing the responses when their
+// header is written
+//
+// It runs before middleware.Digest, so that the Content-Digest set once
+// the body is buffered is covered. Responses that cannot be signed are
+// sent unsigned and the error logged.
+func SignResponses(config SignConfig) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			writer := &signWriter{ResponseWriter: c.Writer, request: c.Request, context: c, config: config}
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+		}
+	}
+}
+
+// sign adds the signature of a message to its header
+func sign(m *message, config SignConfig) error {
+	if config.Key == nil {
+		return errors.New("httpsig: no signing key")
+	}
+	if config.Label == "" {
+		config.Label = DefaultLabel
+	}
+
+	sig := &signature{label: config.Label}
+	for _, s := range config.Components {
+		c, err := parseComponent(s)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(c.name, "@") {
+			header := m.header
+			if c.req && m.request != nil {
+				header = m.request.Header
+			}
+			if len(header.Values(c.name)) == 0 {
+				continue
+			}
+		}
+		sig.components = append(sig.components, c)
+	}
+
+	created := time.Now()
+	sig.params = append(sig.params, param{name: "created", value: created.Unix()})
+	if config.Expires > 0 {
+		sig.params = append(sig.params, param{name: "expires", value: created.Add(config.Expires).Unix()})
+	}
+	if config.Nonce {
+		nonce := make([]byte, 16)
+		rand.Read(nonce)
+		sig.params = append(sig.params, param{name: "nonce", value: base64.RawURLEncoding.EncodeToString(nonce)})
+	}
+	sig.params = append(sig.params,
+		param{name: "keyid", value: config.Key.ID},
+		param{name: "alg", value: config.Key.Algorithm},
+	)
+	if config.Tag != "" {
+		sig.params = append(sig.params, param{name: "tag", value: config.Tag})
+	}
+
+	base, err := sig.base(m)
+	if err != nil {
+		return err
+	}
+	signed, err := config.Key.sign(base)
+	if err != nil {
+		return err
+	}
+
+	m.header.Add("Signature-Input", config.Label+"="+sig.input())
+	m.header.Add("Signature", config.Label+"=:"+base64.StdEncoding.EncodeToString(signed)+":")
+	return nil
+}
+
+// signWriter signs a response when its header is written
+type signWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	context *types.Context
+	config  SignConfig
+	signed  bool
+}
+
+// WriteHeader implements http.ResponseWriter, informational responses are
+// not signed
+func (w *signWriter) WriteHeader(status int) {
+	if !w.signed && status >= 200 {
+		w.signed = true
+		if err := SignResponse(w.Header(), status, w.request, w.config); err != nil {
+			w.context.Logger().Error("httpsig: signing failed", "error", err)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *signWriter) Write(b []byte) (int, error) {
+	if !w.signed {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (w *signWriter) Flush() {
+	if !w.signed {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *signWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
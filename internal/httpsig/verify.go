@@ -0,0 +1,211 @@
+package httpsig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/replay"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultMaxAge is how old signatures may be when VerifyConfig.MaxAge is
+// not set
+const DefaultMaxAge = 5 * time.Minute
+
+// maxClockSkew tolerates signatures created slightly in the future
+const maxClockSkew = time.Minute
+
+// DefaultRequiredComponents must be covered by the signatures of requests
+// when VerifyConfig.Required is not set
+var DefaultRequiredComponents = []string{"@method", "@target-uri"}
+
+// VerifyConfig configures the verification of signatures
+type VerifyConfig struct {
+	// Keys resolves the keys of the signatures, required
+	Keys KeyStore
+
+	// Label selects the signature to verify. Any signature verifying with
+	// a known key is accepted if empty.
+	Label string
+
+	// Required components must be covered by the signatures, e.g.
+	// "content-digest" along with middleware.Digest to authenticate the
+	// bodies. DefaultRequiredComponents if nil.
+	Required []string
+
+	// MaxAge rejects signatures created longer ago, DefaultMaxAge if 0 and
+	// unlimited if negative
+	MaxAge time.Duration
+
+	// Replay rejects the signatures without nonce and those seen already,
+	// may be nil. Its window applies to the created parameter along with
+	// MaxAge.
+	Replay *replay.Guard
+}
+
+// keyKey is the context key of the verified key
+type keyKey struct{}
+
+// KeyFromContext returns the key that verified the request, set by the
+// Verify middleware
+func KeyFromContext(ctx context.Context) (*Key, bool) {
+	key, ok := ctx.Value(keyKey{}).(*Key)
+	return key, ok
+}
+
+// Verify returns a middleware rejecting the requests without a valid
+// signature with 401 Unauthorized
+//
+// The key that verified the request is available with KeyFromContext,
+// e.g. to identify the partner.
+func Verify(config VerifyConfig) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			key, err := VerifyRequest(c.Request, config)
+			if err != nil {
+				c.Abort()
+				c.ErrorString(http.StatusUnauthorized, err.Error())
+				return
+			}
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), keyKey{}, key))
+			next(c)
+		}
+	}
+}
+
+// VerifyRequest verifies the signature of a request
+//
+// @return: the key that verified the signature
+// @return: an error wrapping ErrNoSignature or ErrInvalidSignature
+func VerifyRequest(r *http.Request, config VerifyConfig) (*Key, error) {
+	if config.Required == nil {
+		config.Required = DefaultRequiredComponents
+	}
+	return verify(r.Context(), &message{request: r, header: r.Header}, config)
+}
+
+// VerifyResponse verifies the signature of a response, e.g. from a partner
+// API. Its request provides the components marked with req.
+//
+// @return: the key that verified the signature
+// @return: an error wrapping ErrNoSignature or ErrInvalidSignature
+func VerifyResponse(resp *http.Response, config VerifyConfig) (*Key, error) {
+	if config.Required == nil {
+		config.Required = []string{"@status"}
+	}
+	ctx := context.Background()
+	if resp.Request != nil {
+		ctx = resp.Request.Context()
+	}
+	return verify(ctx, &message{request: resp.Request, header: resp.Header, status: resp.StatusCode}, config)
+}
+
+// verify verifies the signatures of a message in order, until one
+// verifies
+func verify(ctx context.Context, m *message, config VerifyConfig) (*Key, error) {
+	if config.Keys == nil {
+		return nil, errors.New("httpsig: no key store")
+	}
+	required := make([]component, 0, len(config.Required))
+	for _, s := range config.Required {
+		c, err := parseComponent(s)
+		if err != nil {
+			return nil, err
+		}
+		required = append(required, c)
+	}
+
+	sigs, err := parseSignatures(m.header)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseDictionary(strings.Join(m.header.Values("Signature"), ", "))
+	if err != nil {
+		return nil, fmt.Errorf("%w: Signature: %v", ErrInvalidSignature, err)
+	}
+	signed := make(map[string][]byte, len(values))
+	for _, v := range values {
+		if b, ok := v.value.([]byte); ok {
+			signed[v.name] = b
+		}
+	}
+
+	var first error
+	for _, sig := range sigs {
+		if config.Label != "" && sig.label != config.Label {
+			continue
+		}
+		key, err := verifySignature(ctx, m, sig, signed[sig.label], required, config)
+		if err == nil {
+			return key, nil
+		}
+		if first == nil {
+			first = fmt.Errorf("%s: %w", sig.label, err)
+		}
+	}
+	if first == nil {
+		return nil, ErrNoSignature
+	}
+	return nil, first
+}
+
+// verifySignature verifies a signature of a message
+func verifySignature(ctx context.Context, m *message, sig *signature, signed []byte, required []component, config VerifyConfig) (*Key, error) {
+	if signed == nil {
+		return nil, fmt.Errorf("%w: missing Signature", ErrInvalidSignature)
+	}
+
+	for _, c := range required {
+		if !slices.Contains(sig.components, c) {
+			return nil, fmt.Errorf("%w: %s not covered", ErrInvalidSignature, c.name)
+		}
+	}
+
+	now := time.Now()
+	maxAge := config.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	if maxAge > 0 {
+		created := time.Unix(sig.created, 0)
+		if sig.created == 0 || now.Sub(created) > maxAge || created.Sub(now) > maxClockSkew {
+			return nil, fmt.Errorf("%w: created outside of the accepted window", ErrInvalidSignature)
+		}
+	}
+	if sig.expires != 0 && !now.Before(time.Unix(sig.expires, 0)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidSignature)
+	}
+
+	key, err := config.Keys.Key(ctx, sig.keyID)
+	if err != nil {
+		return nil, err
+	}
+	if sig.alg != "" && sig.alg != key.Algorithm {
+		return nil, fmt.Errorf("%w: algorithm %s does not match the key", ErrInvalidSignature, sig.alg)
+	}
+
+	base, err := sig.base(m)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if err := key.verify(base, signed); err != nil {
+		return nil, err
+	}
+
+	// Nonces are only recorded once the signature is verified, so that
+	// forged messages cannot burn them
+	if config.Replay != nil {
+		if sig.nonce == "" {
+			return nil, fmt.Errorf("%w: missing nonce", ErrInvalidSignature)
+		}
+		if err := config.Replay.Check(ctx, sig.keyID+":"+sig.nonce, time.Unix(sig.created, 0)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,99 @@
+package i18n
+
+import (
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// currencies maps ISO 4217 codes to their symbol and minor unit digits
+var currencies = map[string]struct {
+	symbol   string
+	decimals int
+}{
+	"USD": {"$", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+	"CNY": {"¥", 2},
+	"CHF": {"CHF", 2},
+	"INR": {"₹", 2},
+}
+
+// FormatNumber formats a number with the locale's separators and the given
+// number of decimals
+func (l Locale) FormatNumber(value float64, decimals int) string {
+	negative := value < 0
+	digits := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	integer, fraction, _ := strings.Cut(digits, ".")
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.GroupSeparator)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(l.DecimalSeparator)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// FormatCurrency formats an amount in the currency with the given ISO 4217
+// code, using its symbol when known
+func (l Locale) FormatCurrency(amount float64, code string) string {
+	code = strings.ToUpper(code)
+	symbol, decimals := code, 2
+	if currency, ok := currencies[code]; ok {
+		symbol, decimals = currency.symbol, currency.decimals
+	}
+
+	number := l.FormatNumber(amount, decimals)
+	if l.CurrencySuffix {
+		// Non-breaking, so that the symbol never wraps onto its own line
+		return number + "\u00a0" + symbol
+	}
+	if rest, ok := strings.CutPrefix(number, "-"); ok {
+		return "-" + symbol + rest
+	}
+	return symbol + number
+}
+
+// FormatDate formats the date part of a time
+func (l Locale) FormatDate(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+// FormatDateTime formats a time with date and time of day
+func (l Locale) FormatDateTime(t time.Time) string {
+	return t.Format(l.DateTimeLayout)
+}
+
+// FuncMap returns the template functions formatting values for the locale:
+//
+//	{{ number .Count 0 }}
+//	{{ currency .Price "EUR" }}
+//	{{ date .CreatedAt }}
+//	{{ datetime .CreatedAt }}
+func (l Locale) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"number":   l.FormatNumber,
+		"currency": l.FormatCurrency,
+		"date":     l.FormatDate,
+		"datetime": l.FormatDateTime,
+		"locale":   func() string { return l.Tag },
+	}
+}
+
+// FuncMap returns the formatting functions for the default locale, to be
+// added to templates before parsing and replaced per locale, see Localize
+func FuncMap() template.FuncMap {
+	return DefaultLocale.FuncMap()
+}
@@ -0,0 +1,104 @@
+package i18n
+
+import (
+	"context"
+	"html/template"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	supported := []string{"en", "de", "fr-CA"}
+
+	require.Equal(t, "de", Negotiate("de-AT,de;q=0.9,en;q=0.8", supported))
+	require.Equal(t, "fr-CA", Negotiate("fr;q=0.9, de;q=0.5", supported))
+	require.Equal(t, "de", Negotiate("es, de;q=0.1", supported))
+	require.Equal(t, "en", Negotiate("es", supported))
+	require.Equal(t, "en", Negotiate("", supported))
+	require.Equal(t, "en", Negotiate("de;q=0, *", supported))
+	require.Equal(t, "en", Negotiate("de", nil))
+}
+
+func TestLookup(t *testing.T) {
+	require.Equal(t, "de", Lookup("DE").Tag)
+
+	locale := Lookup("de-AT")
+	require.Equal(t, "de-AT", locale.Tag)
+	require.Equal(t, ",", locale.DecimalSeparator)
+
+	require.Equal(t, DefaultLocale, Lookup("xx"))
+}
+
+func TestLocale_Format(t *testing.T) {
+	en, de, fr := Lookup("en"), Lookup("de"), Lookup("fr")
+
+	require.Equal(t, "1,234,567.89", en.FormatNumber(1234567.891, 2))
+	require.Equal(t, "1.234.567,89", de.FormatNumber(1234567.891, 2))
+	require.Equal(t, "-999", en.FormatNumber(-999, 0))
+	require.Equal(t, "-1,000", en.FormatNumber(-1000, 0))
+
+	require.Equal(t, "$1,234.50", en.FormatCurrency(1234.5, "usd"))
+	require.Equal(t, "-$5.00", en.FormatCurrency(-5, "USD"))
+	require.Equal(t, "1.234,50\u00a0€", de.FormatCurrency(1234.5, "EUR"))
+	require.Equal(t, "1\u202f235\u00a0¥", fr.FormatCurrency(1234.6, "JPY"))
+	require.Equal(t, "SEK10.00", en.FormatCurrency(10, "SEK"))
+
+	date := time.Date(2024, time.March, 7, 14, 5, 0, 0, time.UTC)
+	require.Equal(t, "03/07/2024", en.FormatDate(date))
+	require.Equal(t, "07.03.2024 14:05", de.FormatDateTime(date))
+}
+
+func TestLocale_FuncMap(t *testing.T) {
+	tmpl := template.Must(template.New("price").Funcs(FuncMap()).Parse(
+		`{{ locale }}: {{ currency .Price "EUR" }} on {{ date .Date }}`,
+	))
+
+	var b strings.Builder
+	clone := template.Must(tmpl.Clone()).Funcs(Lookup("de").FuncMap())
+	require.NoError(t, clone.Execute(&b, map[string]any{
+		"Price": 9.99,
+		"Date":  time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC),
+	}))
+	require.Equal(t, "de: 9,99\u00a0€ on 07.03.2024", b.String())
+}
+
+func TestLocalize(t *testing.T) {
+	tmpl := template.Must(template.New("price").Funcs(FuncMap()).Parse(`{{ locale }}: {{ number .Price 2 }}`))
+	require.NoError(t, LocalizeAll(tmpl))
+
+	// Templates are cloned once per locale, concurrently with registrations
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Register(Locale{Tag: "x-test", DecimalSeparator: ",", GroupSeparator: " "})
+			de, err := Localize(tmpl, Lookup("de"))
+			require.NoError(t, err)
+			again, err := Localize(tmpl, Lookup("de"))
+			require.NoError(t, err)
+			require.Same(t, de, again)
+		}()
+	}
+	wg.Wait()
+
+	for tag, want := range map[string]string{"de": "de: 1.234,50", "de-AT": "de-AT: 1.234,50", "x-test": "x-test: 1 234,50"} {
+		localized, err := Localize(tmpl, Lookup(tag))
+		require.NoError(t, err)
+		var b strings.Builder
+		require.NoError(t, localized.Execute(&b, map[string]any{"Price": 1234.5}))
+		require.Equal(t, want, b.String())
+	}
+	require.Contains(t, Locales(), Lookup("x-test"))
+}
+
+func TestFromContext(t *testing.T) {
+	require.Equal(t, DefaultLocale, FromContext(context.Background()))
+
+	ctx := WithLocale(context.Background(), Lookup("ja"))
+	require.Equal(t, "ja", FromContext(ctx).Tag)
+}
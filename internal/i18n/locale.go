@@ -0,0 +1,156 @@
+package i18n
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Locale describes the formatting conventions of a language or region
+type Locale struct {
+	Tag string // BCP 47 language tag, e.g. de-DE
+
+	DecimalSeparator string
+	GroupSeparator   string
+
+	DateLayout     string // time.Format layout
+	DateTimeLayout string // time.Format layout
+
+	CurrencySuffix bool // symbol after the amount, e.g. 1.234,50 €
+}
+
+// DefaultLocale is used when no supported locale matches
+var DefaultLocale = Locale{
+	Tag:              "en",
+	DecimalSeparator: ".",
+	GroupSeparator:   ",",
+	DateLayout:       "01/02/2006",
+	DateTimeLayout:   "01/02/2006 3:04 PM",
+}
+
+// localesMu guards locales, which Register changes
+var localesMu sync.RWMutex
+
+// locales are the built-in and registered locales by lowercase tag
+var locales = map[string]Locale{
+	"en":    DefaultLocale,
+	"en-gb": {Tag: "en-GB", DecimalSeparator: ".", GroupSeparator: ",", DateLayout: "02/01/2006", DateTimeLayout: "02/01/2006 15:04"},
+	"de":    {Tag: "de", DecimalSeparator: ",", GroupSeparator: ".", DateLayout: "02.01.2006", DateTimeLayout: "02.01.2006 15:04", CurrencySuffix: true},
+	"fr":    {Tag: "fr", DecimalSeparator: ",", GroupSeparator: "\u202f", DateLayout: "02/01/2006", DateTimeLayout: "02/01/2006 15:04", CurrencySuffix: true},
+	"es":    {Tag: "es", DecimalSeparator: ",", GroupSeparator: ".", DateLayout: "02/01/2006", DateTimeLayout: "02/01/2006 15:04", CurrencySuffix: true},
+	"it":    {Tag: "it", DecimalSeparator: ",", GroupSeparator: ".", DateLayout: "02/01/2006", DateTimeLayout: "02/01/2006 15:04", CurrencySuffix: true},
+	"nl":    {Tag: "nl", DecimalSeparator: ",", GroupSeparator: ".", DateLayout: "02-01-2006", DateTimeLayout: "02-01-2006 15:04"},
+	"pt":    {Tag: "pt", DecimalSeparator: ",", GroupSeparator: ".", DateLayout: "02/01/2006", DateTimeLayout: "02/01/2006 15:04"},
+	"ja":    {Tag: "ja", DecimalSeparator: ".", GroupSeparator: ",", DateLayout: "2006/01/02", DateTimeLayout: "2006/01/02 15:04"},
+	"zh":    {Tag: "zh", DecimalSeparator: ".", GroupSeparator: ",", DateLayout: "2006/01/02", DateTimeLayout: "2006/01/02 15:04"},
+}
+
+// Register adds or replaces a locale, looked up by its tag
+func Register(locale Locale) {
+	localesMu.Lock()
+	defer localesMu.Unlock()
+	locales[strings.ToLower(locale.Tag)] = locale
+}
+
+// Locales returns the registered locales, sorted by tag
+func Locales() []Locale {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	result := make([]Locale, 0, len(locales))
+	for _, locale := range locales {
+		result = append(result, locale)
+	}
+	slices.SortFunc(result, func(a, b Locale) int { return strings.Compare(a.Tag, b.Tag) })
+	return result
+}
+
+// Lookup returns the locale for a language tag, falling back to its base
+// language, e.g. de-AT to de, and then to DefaultLocale
+func Lookup(tag string) Locale {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	key := strings.ToLower(tag)
+	if locale, ok := locales[key]; ok {
+		return locale
+	}
+	if base, _, ok := strings.Cut(key, "-"); ok {
+		if locale, ok := locales[base]; ok {
+			locale.Tag = tag
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// Negotiate picks the supported language tag best matching an
+// Accept-Language header
+//
+// Tags match exactly or by base language, in order of the client's
+// preference.
+//
+// @return: the matching supported tag, or the first supported tag if
+// none matches
+func Negotiate(acceptLanguage string, supported []string) string {
+	if len(supported) == 0 {
+		return DefaultLocale.Tag
+	}
+
+	type preference struct {
+		tag string
+		q   float64
+	}
+	var preferences []preference
+	for part := range strings.SplitSeq(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if tag != "" && q > 0 {
+			preferences = append(preferences, preference{strings.ToLower(tag), q})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].q > preferences[j].q
+	})
+
+	for _, pref := range preferences {
+		if pref.tag == "*" {
+			return supported[0]
+		}
+		if i := slices.IndexFunc(supported, func(s string) bool {
+			return strings.EqualFold(s, pref.tag)
+		}); i >= 0 {
+			return supported[i]
+		}
+		base, _, _ := strings.Cut(pref.tag, "-")
+		if i := slices.IndexFunc(supported, func(s string) bool {
+			supportedBase, _, _ := strings.Cut(strings.ToLower(s), "-")
+			return supportedBase == base
+		}); i >= 0 {
+			return supported[i]
+		}
+	}
+	return supported[0]
+}
+
+// localeKey is the context key of the negotiated locale
+type localeKey struct{}
+
+// WithLocale returns a copy of the context carrying the locale
+func WithLocale(ctx context.Context, locale Locale) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// FromContext returns the locale carried by the context, or DefaultLocale
+func FromContext(ctx context.Context) Locale {
+	if locale, ok := ctx.Value(localeKey{}).(Locale); ok {
+		return locale
+	}
+	return DefaultLocale
+}
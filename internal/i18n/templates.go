@@ -0,0 +1,67 @@
+package i18n
+
+import (
+	"html/template"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// localized holds the clones of the templates per locale, dropped along
+// with their template, e.g. once templates are parsed again
+var localized = struct {
+	mu     sync.RWMutex
+	clones map[weak.Pointer[template.Template]]map[Locale]*template.Template
+}{clones: make(map[weak.Pointer[template.Template]]map[Locale]*template.Template)}
+
+// Localize returns the clone of a template whose formatting functions are
+// bound to the locale, see Locale.FuncMap
+//
+// Templates are cloned once per locale and never executed themselves, so
+// that they can be cloned for the locales registered later on.
+//
+// @return: an error if the template was executed already
+func Localize(tmpl *template.Template, locale Locale) (*template.Template, error) {
+	key := weak.Make(tmpl)
+	localized.mu.RLock()
+	clone, ok := localized.clones[key][locale]
+	localized.mu.RUnlock()
+	if ok {
+		return clone, nil
+	}
+
+	localized.mu.Lock()
+	defer localized.mu.Unlock()
+	clones, ok := localized.clones[key]
+	if !ok {
+		clones = make(map[Locale]*template.Template)
+		localized.clones[key] = clones
+		runtime.AddCleanup(tmpl, func(key weak.Pointer[template.Template]) {
+			localized.mu.Lock()
+			defer localized.mu.Unlock()
+			delete(localized.clones, key)
+		}, key)
+	}
+	if clone, ok := clones[locale]; ok {
+		return clone, nil
+	}
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	clones[locale] = clone.Funcs(locale.FuncMap())
+	return clones[locale], nil
+}
+
+// LocalizeAll clones a template for every registered locale, see Localize,
+// e.g. once parsed so that requests do not clone it
+//
+// @return: an error if the template was executed already
+func LocalizeAll(tmpl *template.Template) error {
+	for _, locale := range Locales() {
+		if _, err := Localize(tmpl, locale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
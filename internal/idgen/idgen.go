@@ -0,0 +1,199 @@
+// Package idgen generates unique ids sortable by creation time, e.g. for
+// request ids and database keys, with interchangeable formats
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the generators, see New
+const (
+	NameUUIDv7    = "uuidv7"
+	NameULID      = "ulid"
+	NameSnowflake = "snowflake"
+)
+
+// DefaultSnowflakeEpoch is the start of the timestamps of the snowflake
+// ids, giving them about 69 years
+var DefaultSnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// MaxSnowflakeNode is the largest node of a snowflake generator
+const MaxSnowflakeNode = 1<<10 - 1
+
+// ErrUnknownGenerator is returned by New for unknown names
+var ErrUnknownGenerator = errors.New("idgen: unknown generator")
+
+// Generator generates unique ids, safe for concurrent use
+type Generator interface {
+	NewID() string
+}
+
+// Default is the generator used when none is configured
+var Default Generator = NewUUIDv7()
+
+// New returns the generator with the name, UUIDv7 if empty, node
+// identifying the instance for snowflake ids
+func New(name string, node int64) (Generator, error) {
+	switch strings.ToLower(name) {
+	case "", NameUUIDv7:
+		return NewUUIDv7(), nil
+	case NameULID:
+		return NewULID(), nil
+	case NameSnowflake:
+		return NewSnowflake(node, DefaultSnowflakeEpoch)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownGenerator, name)
+}
+
+// UUIDv7 generates UUIDs version 7, a millisecond timestamp followed by
+// random bits, see RFC 9562
+type UUIDv7 struct {
+	now func() time.Time
+}
+
+// NewUUIDv7 creates a UUIDv7 generator
+func NewUUIDv7() *UUIDv7 {
+	return &UUIDv7{now: time.Now}
+}
+
+// NewID implements Generator, e.g. "01890a5d-ac96-774b-bcce-b302099a8057"
+func (g *UUIDv7) NewID() string {
+	var id [16]byte
+	rand.Read(id[6:])
+	ms := uint64(g.now().UnixMilli())
+	id[0], id[1], id[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	id[3], id[4], id[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	id[6] = id[6]&0x0f | 0x70 // version 7
+	id[8] = id[8]&0x3f | 0x80 // variant 10
+
+	var s [36]byte
+	hex.Encode(s[0:8], id[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], id[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], id[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], id[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], id[10:])
+	return string(s[:])
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates ULIDs, a millisecond timestamp followed by random bits
+// in Crockford's base32, see https://github.com/ulid/spec
+//
+// Ids of the same millisecond increment the random bits of the previous
+// one, so that they sort in the order they were generated.
+type ULID struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	lastMS  uint64
+	entropy [10]byte
+}
+
+// NewULID creates a ULID generator
+func NewULID() *ULID {
+	return &ULID{now: time.Now}
+}
+
+// NewID implements Generator, e.g. "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+func (g *ULID) NewID() string {
+	var id [16]byte
+	ms := uint64(g.now().UnixMilli())
+	binary.BigEndian.PutUint64(id[:8], ms<<16)
+
+	g.mu.Lock()
+	if ms <= g.lastMS {
+		// Increment the entropy, overflows being astronomically unlikely
+		for i := len(g.entropy) - 1; i >= 0; i-- {
+			g.entropy[i]++
+			if g.entropy[i] != 0 {
+				break
+			}
+		}
+		ms = g.lastMS
+		binary.BigEndian.PutUint64(id[:8], ms<<16)
+	} else {
+		g.lastMS = ms
+		rand.Read(g.entropy[:])
+	}
+	copy(id[6:], g.entropy[:])
+	g.mu.Unlock()
+
+	// 128 bits in 26 characters of 5 bits, the first one holding 3
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	var s [26]byte
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// Snowflake generates 63-bit ids of a millisecond timestamp, the node and
+// a sequence, as decimal strings, e.g. for databases with integer keys
+//
+// Every instance must have its own node. Up to 4096 ids are generated per
+// millisecond, the next ones waiting for the next millisecond.
+type Snowflake struct {
+	node  int64
+	epoch time.Time
+	now   func() time.Time
+
+	mu       sync.Mutex
+	lastMS   int64
+	sequence int64
+}
+
+// NewSnowflake creates a snowflake generator for the node, from 0 to
+// MaxSnowflakeNode, with timestamps from the epoch
+func NewSnowflake(node int64, epoch time.Time) (*Snowflake, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, fmt.Errorf("idgen: snowflake node %d out of range [0, %d]", node, MaxSnowflakeNode)
+	}
+	return &Snowflake{node: node, epoch: epoch, now: time.Now}, nil
+}
+
+// NewID implements Generator, e.g. "7159012846911488"
+func (g *Snowflake) NewID() string {
+	return strconv.FormatInt(g.Next(), 10)
+}
+
+// Next returns the next id as an integer
+func (g *Snowflake) Next() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().Sub(g.epoch).Milliseconds()
+	if ms < g.lastMS {
+		// The clock went back, the ids keep increasing from the last one
+		ms = g.lastMS
+	}
+	if ms == g.lastMS {
+		g.sequence = (g.sequence + 1) & 0xfff
+		if g.sequence == 0 {
+			for ms <= g.lastMS {
+				time.Sleep(100 * time.Microsecond)
+				ms = g.now().Sub(g.epoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMS = ms
+	return ms<<22 | g.node<<12 | g.sequence
+}
@@ -0,0 +1,72 @@
+package idgen
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUIDv7(t *testing.T) {
+	g := NewUUIDv7()
+	g.now = func() time.Time { return time.UnixMilli(0x01890a5dac96) }
+
+	id := g.NewID()
+	require.Regexp(t, regexp.MustCompile(`^01890a5d-ac96-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+	require.NotEqual(t, id, g.NewID())
+}
+
+func TestULID(t *testing.T) {
+	now := time.UnixMilli(1469918176385)
+	g := NewULID()
+	g.now = func() time.Time { return now }
+
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = g.NewID()
+	}
+	require.Regexp(t, regexp.MustCompile(`^01ARYZ6S41[0-9A-HJKMNP-TV-Z]{16}$`), ids[0])
+
+	// Ids of the same millisecond, or of a clock going back, stay sorted
+	now = now.Add(-time.Second)
+	ids = append(ids, g.NewID())
+	now = now.Add(time.Hour)
+	ids = append(ids, g.NewID())
+	require.True(t, slices.IsSorted(ids))
+	require.Len(t, slices.Compact(slices.Clone(ids)), len(ids))
+}
+
+func TestSnowflake(t *testing.T) {
+	_, err := NewSnowflake(MaxSnowflakeNode+1, DefaultSnowflakeEpoch)
+	require.Error(t, err)
+
+	g, err := NewSnowflake(5, DefaultSnowflakeEpoch)
+	require.NoError(t, err)
+	now := DefaultSnowflakeEpoch.Add(time.Second)
+	g.now = func() time.Time { return now }
+
+	first := g.Next()
+	require.Equal(t, int64(1000<<22|5<<12), first)
+	require.Equal(t, first+1, g.Next())
+
+	now = now.Add(-time.Millisecond)
+	require.Equal(t, first+2, g.Next())
+
+	now = now.Add(time.Second)
+	id, err := strconv.ParseInt(g.NewID(), 10, 64)
+	require.NoError(t, err)
+	require.Equal(t, int64(1999<<22|5<<12), id)
+}
+
+func TestNew(t *testing.T) {
+	for name, expected := range map[string]any{"": &UUIDv7{}, "ULID": &ULID{}, "snowflake": &Snowflake{}} {
+		g, err := New(name, 1)
+		require.NoError(t, err)
+		require.IsType(t, expected, g)
+	}
+	_, err := New("uuidv4", 0)
+	require.ErrorIs(t, err, ErrUnknownGenerator)
+}
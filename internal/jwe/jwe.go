@@ -0,0 +1,271 @@
+// Package jwe encrypts and decrypts payloads as JSON Web Encryption in
+// compact serialization, see RFC 7516, for clients requiring
+// application-level encryption on top of TLS
+package jwe
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Key management algorithms, see RFC 7518 section 4.1
+const (
+	AlgorithmDirect     = "dir"
+	AlgorithmA128KW     = "A128KW"
+	AlgorithmA256KW     = "A256KW"
+	AlgorithmRSAOAEP256 = "RSA-OAEP-256"
+)
+
+// Content encryption algorithms, see RFC 7518 section 5.1
+const (
+	EncryptionA128GCM = "A128GCM"
+	EncryptionA256GCM = "A256GCM"
+)
+
+// DefaultEncryption encrypts the contents when none is configured
+const DefaultEncryption = EncryptionA256GCM
+
+var (
+	// ErrMalformed is returned when decrypting invalid compact
+	// serializations
+	ErrMalformed = errors.New("jwe: malformed token")
+
+	// ErrDecryption is returned when a token cannot be decrypted, e.g. with
+	// a wrong key or after tampering
+	ErrDecryption = errors.New("jwe: decryption failed")
+
+	// ErrUnknownKey is returned by key providers for unknown key ids
+	ErrUnknownKey = errors.New("jwe: unknown key")
+)
+
+// Key is an encryption key
+type Key struct {
+	// ID identifies the key, sent as the kid header parameter so that
+	// rotated keys can still decrypt
+	ID string
+
+	// Algorithm managing the content encryption keys, e.g. AlgorithmA256KW
+	Algorithm string
+
+	// Secret of the dir and AES key wrap algorithms, of the size of the
+	// content encryption key for dir
+	Secret []byte
+
+	// PrivateKey decrypts with RSA-OAEP-256
+	PrivateKey *rsa.PrivateKey
+
+	// PublicKey encrypts with RSA-OAEP-256, the public key of PrivateKey
+	// if nil
+	PublicKey *rsa.PublicKey
+}
+
+// KeyProvider provides the keys encrypting and decrypting payloads,
+// e.g. rotating keys: payloads are encrypted with the current key and
+// decrypted with the key they name
+type KeyProvider interface {
+	// EncryptionKey returns the key encrypting payloads in the context,
+	// e.g. the key of the authenticated client
+	EncryptionKey(ctx context.Context) (*Key, error)
+
+	// DecryptionKey returns the key with the id, or ErrUnknownKey
+	DecryptionKey(ctx context.Context, id string) (*Key, error)
+}
+
+// KeySet is a static KeyProvider with a current key and retired keys
+// still accepted for decryption
+type KeySet struct {
+	Current *Key
+	Retired []*Key
+}
+
+// EncryptionKey implements KeyProvider
+func (s *KeySet) EncryptionKey(context.Context) (*Key, error) {
+	if s.Current == nil {
+		return nil, ErrUnknownKey
+	}
+	return s.Current, nil
+}
+
+// DecryptionKey implements KeyProvider
+func (s *KeySet) DecryptionKey(_ context.Context, id string) (*Key, error) {
+	if s.Current != nil && s.Current.ID == id {
+		return s.Current, nil
+	}
+	for _, key := range s.Retired {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Header is the protected header of a token
+type Header struct {
+	Algorithm   string `json:"alg"`
+	Encryption  string `json:"enc"`
+	KeyID       string `json:"kid,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// Encrypt encrypts a payload with the key, enc being the content
+// encryption algorithm, DefaultEncryption if empty, and cty the content
+// type of the payload, may be empty
+//
+// @return: the token in compact serialization
+func Encrypt(payload []byte, key *Key, enc, cty string) (string, error) {
+	if enc == "" {
+		enc = DefaultEncryption
+	}
+	size, err := keySize(enc)
+	if err != nil {
+		return "", err
+	}
+
+	// The content encryption key is the secret for dir, and random otherwise
+	cek := make([]byte, size)
+	var encryptedKey []byte
+	switch key.Algorithm {
+	case AlgorithmDirect:
+		if len(key.Secret) != size {
+			return "", fmt.Errorf("jwe: %s needs a %d bytes key", enc, size)
+		}
+		cek = key.Secret
+	case AlgorithmA128KW, AlgorithmA256KW:
+		rand.Read(cek)
+		if encryptedKey, err = wrapKey(key, cek); err != nil {
+			return "", err
+		}
+	case AlgorithmRSAOAEP256:
+		public := key.PublicKey
+		if public == nil && key.PrivateKey != nil {
+			public = &key.PrivateKey.PublicKey
+		}
+		if public == nil {
+			return "", errors.New("jwe: RSA key without public key")
+		}
+		rand.Read(cek)
+		if encryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, public, cek, nil); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("jwe: unsupported algorithm %q", key.Algorithm)
+	}
+
+	header, err := json.Marshal(Header{Algorithm: key.Algorithm, Encryption: enc, KeyID: key.ID, ContentType: cty})
+	if err != nil {
+		return "", err
+	}
+	protected := encode(header)
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	rand.Read(iv)
+	sealed := gcm.Seal(nil, iv, payload, []byte(protected))
+	ciphertext, tag := sealed[:len(payload)], sealed[len(payload):]
+
+	return strings.Join([]string{protected, encode(encryptedKey), encode(iv), encode(ciphertext), encode(tag)}, "."), nil
+}
+
+// Decrypt decrypts a token in compact serialization with the key it names
+//
+// @return: the payload and the header of the token
+// @return: an error wrapping ErrMalformed or ErrDecryption, or the error
+// of the provider
+func Decrypt(ctx context.Context, token string, keys KeyProvider) ([]byte, *Header, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 5 {
+		return nil, nil, ErrMalformed
+	}
+	decoded := make([][]byte, 5)
+	for i, part := range parts {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return nil, nil, ErrMalformed
+		}
+		decoded[i] = b
+	}
+
+	var header Header
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, nil, ErrMalformed
+	}
+	size, err := keySize(header.Encryption)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := keys.DecryptionKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key.Algorithm != header.Algorithm {
+		return nil, nil, fmt.Errorf("%w: algorithm %q does not match the key", ErrDecryption, header.Algorithm)
+	}
+
+	var cek []byte
+	switch key.Algorithm {
+	case AlgorithmDirect:
+		cek = key.Secret
+	case AlgorithmA128KW, AlgorithmA256KW:
+		cek, err = unwrapKey(key, decoded[1])
+	case AlgorithmRSAOAEP256:
+		if key.PrivateKey == nil {
+			return nil, nil, errors.New("jwe: RSA key without private key")
+		}
+		cek, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, key.PrivateKey, decoded[1], nil)
+	default:
+		return nil, nil, fmt.Errorf("jwe: unsupported algorithm %q", key.Algorithm)
+	}
+	if err != nil || len(cek) != size {
+		return nil, nil, ErrDecryption
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(decoded[2]) != gcm.NonceSize() {
+		return nil, nil, ErrMalformed
+	}
+	payload, err := gcm.Open(nil, decoded[2], append(decoded[3], decoded[4]...), []byte(parts[0]))
+	if err != nil {
+		return nil, nil, ErrDecryption
+	}
+	return payload, &header, nil
+}
+
+// keySize returns the size of the content encryption keys of enc
+func keySize(enc string) (int, error) {
+	switch enc {
+	case EncryptionA128GCM:
+		return 16, nil
+	case EncryptionA256GCM:
+		return 32, nil
+	}
+	return 0, fmt.Errorf("jwe: unsupported encryption %q", enc)
+}
+
+// newGCM returns AES-GCM with the key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encode encodes in unpadded base64url
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,133 @@
+package jwe
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapKey(t *testing.T) {
+	// Test vector of RFC 3394 section 4.1
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	cek, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	key := &Key{Algorithm: AlgorithmA128KW, Secret: kek}
+
+	wrapped, err := wrapKey(key, cek)
+	require.NoError(t, err)
+	require.Equal(t, "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5", strings.ToUpper(hex.EncodeToString(wrapped)))
+
+	unwrapped, err := unwrapKey(key, wrapped)
+	require.NoError(t, err)
+	require.Equal(t, cek, unwrapped)
+
+	wrapped[0] ^= 1
+	_, err = unwrapKey(key, wrapped)
+	require.ErrorIs(t, err, ErrDecryption)
+}
+
+func TestEncrypt(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys := []*Key{
+		{ID: "dir", Algorithm: AlgorithmDirect, Secret: make([]byte, 32)},
+		{ID: "a128kw", Algorithm: AlgorithmA128KW, Secret: make([]byte, 16)},
+		{ID: "a256kw", Algorithm: AlgorithmA256KW, Secret: make([]byte, 32)},
+		{ID: "rsa", Algorithm: AlgorithmRSAOAEP256, PrivateKey: rsaKey},
+	}
+	for _, key := range keys {
+		rand.Read(key.Secret)
+		t.Run(key.ID, func(t *testing.T) {
+			set := &KeySet{Current: key}
+			token, err := Encrypt([]byte(`{"card":"4242"}`), key, "", "application/json")
+			require.NoError(t, err)
+			require.Len(t, strings.Split(token, "."), 5)
+			require.NotContains(t, token, "4242")
+
+			payload, header, err := Decrypt(context.Background(), token, set)
+			require.NoError(t, err)
+			require.Equal(t, `{"card":"4242"}`, string(payload))
+			require.Equal(t, Header{Algorithm: key.Algorithm, Encryption: EncryptionA256GCM, KeyID: key.ID, ContentType: "application/json"}, *header)
+
+			// Tampered ciphertexts are rejected
+			parts := strings.Split(token, ".")
+			parts[3] = strings.Repeat("A", len(parts[3]))
+			_, _, err = Decrypt(context.Background(), strings.Join(parts, "."), set)
+			require.ErrorIs(t, err, ErrDecryption)
+		})
+	}
+
+	// Retired keys still decrypt once rotated
+	old, current := keys[2], &Key{ID: "next", Algorithm: AlgorithmA256KW, Secret: make([]byte, 32)}
+	token, err := Encrypt([]byte("data"), old, EncryptionA128GCM, "")
+	require.NoError(t, err)
+	_, _, err = Decrypt(context.Background(), token, &KeySet{Current: current, Retired: []*Key{old}})
+	require.NoError(t, err)
+	_, _, err = Decrypt(context.Background(), token, &KeySet{Current: current})
+	require.ErrorIs(t, err, ErrUnknownKey)
+
+	_, _, err = Decrypt(context.Background(), "a.b.c", &KeySet{})
+	require.ErrorIs(t, err, ErrMalformed)
+	_, err = Encrypt([]byte("data"), &Key{Algorithm: AlgorithmDirect, Secret: make([]byte, 16)}, "", "")
+	require.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	key := &Key{ID: "k1", Algorithm: AlgorithmA256KW, Secret: make([]byte, 32)}
+	rand.Read(key.Secret)
+	keys := &KeySet{Current: key}
+
+	serve := func(config Config, r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: r, Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{Middleware(config)}, func(c *types.Context) {
+			var body struct {
+				Name string `json:"name"`
+			}
+			if c.Request.Body != http.NoBody {
+				require.NoError(t, c.BindJSON(&body))
+			}
+			c.JSON(http.StatusCreated, map[string]string{"hello": body.Name})
+		}))
+		return w
+	}
+
+	// Encrypted requests get encrypted responses
+	token, err := Encrypt([]byte(`{"name":"alice"}`), key, "", "")
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(token))
+	r.Header.Set("Content-Type", MediaType)
+	w := serve(Config{Keys: keys}, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, MediaType, w.Header().Get("Content-Type"))
+
+	payload, header, err := Decrypt(context.Background(), w.Body.String(), keys)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"hello":"alice"}`, string(payload))
+	require.Equal(t, "application/json", header.ContentType)
+
+	// Responses are encrypted for clients accepting them
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json, application/jose")
+	require.Equal(t, MediaType, serve(Config{Keys: keys}, r).Header().Get("Content-Type"))
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	w = serve(Config{Keys: keys, Required: true}, r)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"alice"}`))
+	require.Equal(t, http.StatusUnsupportedMediaType, serve(Config{Keys: keys, Required: true}, r).Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not a token"))
+	r.Header.Set("Content-Type", MediaType)
+	require.Equal(t, http.StatusBadRequest, serve(Config{Keys: keys}, r).Code)
+}
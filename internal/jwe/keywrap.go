@@ -0,0 +1,85 @@
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// keyWrapIV is the initial value of the AES key wrap, see RFC 3394
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// kekSize returns the size of the key encryption key of an AES key wrap
+// algorithm
+func kekSize(algorithm string) int {
+	if algorithm == AlgorithmA128KW {
+		return 16
+	}
+	return 32
+}
+
+// wrapKey wraps a content encryption key with the AES key wrap
+func wrapKey(key *Key, cek []byte) ([]byte, error) {
+	if len(key.Secret) != kekSize(key.Algorithm) {
+		return nil, fmt.Errorf("jwe: %s needs a %d bytes key", key.Algorithm, kekSize(key.Algorithm))
+	}
+	block, err := aes.NewCipher(key.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(cek) / 8
+	out := make([]byte, 8+len(cek))
+	copy(out, keyWrapIV)
+	copy(out[8:], cek)
+
+	var b [16]byte
+	for j := range 6 {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[8*i:8*i+8])
+			block.Encrypt(b[:], b[:])
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[8*i:], b[8:])
+		}
+	}
+	return out, nil
+}
+
+// unwrapKey unwraps a content encryption key wrapped with the AES key wrap
+func unwrapKey(key *Key, wrapped []byte) ([]byte, error) {
+	if len(key.Secret) != kekSize(key.Algorithm) {
+		return nil, fmt.Errorf("jwe: %s needs a %d bytes key", key.Algorithm, kekSize(key.Algorithm))
+	}
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, ErrDecryption
+	}
+	block, err := aes.NewCipher(key.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(out[:8])^t)
+			copy(b[8:], out[8*i:8*i+8])
+			block.Decrypt(b[:], b[:])
+
+			copy(out[:8], b[:8])
+			copy(out[8*i:], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(out[:8], keyWrapIV) != 1 {
+		return nil, ErrDecryption
+	}
+	return out[8:], nil
+}
@@ -0,0 +1,176 @@
+package jwe
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// MediaType is the content type of payloads in compact serialization
+const MediaType = "application/jose"
+
+// DefaultMaxBodySize limits the encrypted request bodies when
+// Config.MaxBodySize is not set
+const DefaultMaxBodySize = 10 << 20
+
+// Config configures the encryption of request and response bodies
+type Config struct {
+	// Keys provides the keys, required
+	Keys KeyProvider
+
+	// Encryption of the responses, DefaultEncryption if empty
+	Encryption string
+
+	// Required rejects the request bodies that are not encrypted with 415
+	// Unsupported Media Type
+	Required bool
+
+	// MaxBodySize limits the encrypted request bodies, which are decrypted
+	// in memory, DefaultMaxBodySize if 0
+	MaxBodySize int64
+}
+
+// Middleware returns a middleware decrypting the request bodies sent as
+// MediaType and encrypting the responses of the clients that encrypt their
+// requests or accept MediaType
+//
+// Decrypted bodies are passed on with the content type of the cty header
+// parameter, application/json if absent, so that handlers bind them as
+// usual. Bodies that cannot be decrypted are rejected with 400 Bad
+// Request. Responses are buffered to be encrypted, flushing them has no
+// effect.
+func Middleware(config Config) types.MiddlewareFunc {
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			r := c.Request
+			encrypted := false
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == MediaType {
+				encrypted = true
+				if !decryptBody(c, config) {
+					return
+				}
+			} else if config.Required && r.Body != nil && r.Body != http.NoBody {
+				c.Abort()
+				c.ErrorString(http.StatusUnsupportedMediaType, "request body must be encrypted as "+MediaType)
+				return
+			}
+
+			c.Writer.Header().Add("Vary", "Accept")
+			if !encrypted && !acceptsJOSE(r.Header.Values("Accept")) {
+				next(c)
+				return
+			}
+
+			writer := encryptWriter{types.NewBufferedWriter(c.Writer)}
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+			writer.finish(c, config)
+		}
+	}
+}
+
+// decryptBody replaces the encrypted body of the request with its payload
+//
+// @return: false if the request was rejected
+func decryptBody(c *types.Context, config Config) bool {
+	token, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, config.MaxBodySize))
+	if err != nil {
+		c.Abort()
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.ErrorString(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+		} else {
+			c.ErrorString(http.StatusBadRequest, "invalid request body")
+		}
+		return false
+	}
+
+	payload, header, err := Decrypt(c.Request.Context(), string(token), config.Keys)
+	if err != nil {
+		c.Abort()
+		c.ErrorString(http.StatusBadRequest, "request body could not be decrypted")
+		return false
+	}
+
+	contentType := header.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	} else if !strings.Contains(contentType, "/") {
+		// The cty parameter may omit the application/ prefix, see RFC 7516
+		contentType = "application/" + contentType
+	}
+	c.Request.Header.Set("Content-Type", contentType)
+	c.Request.Header.Set("Content-Length", strconv.Itoa(len(payload)))
+	c.Request.ContentLength = int64(len(payload))
+	c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+	return true
+}
+
+// acceptsJOSE reports whether Accept headers list MediaType explicitly
+func acceptsJOSE(accept []string) bool {
+	for _, header := range accept {
+		for value := range strings.SplitSeq(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+			if err == nil && mediaType == MediaType && params["q"] != "0" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// encryptWriter buffers a response to encrypt it
+type encryptWriter struct {
+	*types.BufferedWriter
+}
+
+// Flush implements http.Flusher, responses are only sent once encrypted
+func (w encryptWriter) Flush() {}
+
+// finish encrypts and sends the buffered response, empty bodies are sent
+// as is
+func (w encryptWriter) finish(c *types.Context, config Config) {
+	if w.Status() == 0 {
+		return
+	}
+	header := w.Header()
+	body := w.Body()
+	if body.Len() == 0 {
+		w.Send()
+		return
+	}
+
+	token, err := w.encrypt(c, config)
+	if err != nil {
+		c.Logger().Error("jwe: encryption failed", "error", err)
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		c.ErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	header.Set("Content-Type", MediaType)
+	header.Set("Content-Length", strconv.Itoa(len(token)))
+	body.Reset()
+	body.WriteString(token)
+	w.Send()
+}
+
+// encrypt encrypts the buffered body with the current key
+func (w encryptWriter) encrypt(c *types.Context, config Config) (string, error) {
+	key, err := config.Keys.EncryptionKey(c.Request.Context())
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(w.Body().Bytes(), key, config.Encryption, w.Header().Get("Content-Type"))
+}
@@ -0,0 +1,410 @@
+// Package jwt signs and validates JSON Web Tokens in compact
+// serialization, see RFC 7519, and authenticates requests bearing them
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Signature algorithms, see RFC 7518 section 3.1
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+	ES256 = "ES256"
+	ES384 = "ES384"
+	ES512 = "ES512"
+)
+
+var (
+	// ErrNoToken is returned when authenticating requests without token
+	ErrNoToken = errors.New("jwt: no token")
+
+	// ErrMalformed is returned when parsing invalid compact serializations
+	ErrMalformed = errors.New("jwt: malformed token")
+
+	// ErrInvalidSignature is returned when a signature does not verify
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+
+	// ErrUnknownKey is returned by key stores for unknown key ids
+	ErrUnknownKey = errors.New("jwt: unknown key")
+
+	// ErrExpired is returned for tokens past their exp claim
+	ErrExpired = errors.New("jwt: token expired")
+
+	// ErrNotYetValid is returned for tokens before their nbf claim
+	ErrNotYetValid = errors.New("jwt: token not valid yet")
+
+	// ErrInvalidIssuer is returned for tokens of another issuer
+	ErrInvalidIssuer = errors.New("jwt: invalid issuer")
+
+	// ErrInvalidAudience is returned for tokens not intended for the
+	// audience
+	ErrInvalidAudience = errors.New("jwt: invalid audience")
+)
+
+// Key is a signing or verification key
+type Key struct {
+	// ID identifies the key, sent as the kid header parameter so that
+	// rotated keys can still verify
+	ID string
+
+	// Algorithm of the signatures, e.g. ES256, tokens signed with another
+	// algorithm are rejected
+	Algorithm string
+
+	// Secret of the HMAC keys
+	Secret []byte
+
+	// PrivateKey signs with the RSA and ECDSA algorithms: an
+	// *rsa.PrivateKey or *ecdsa.PrivateKey
+	PrivateKey crypto.Signer
+
+	// PublicKey verifies with the RSA and ECDSA algorithms, the public key
+	// of PrivateKey if nil
+	PublicKey crypto.PublicKey
+}
+
+// KeyStore resolves the keys of tokens by key id, empty for tokens without
+// kid
+type KeyStore interface {
+	// Key returns the key with the id, or ErrUnknownKey
+	Key(ctx context.Context, id string) (*Key, error)
+}
+
+// Keys is a static KeyStore
+type Keys map[string]*Key
+
+// Key implements KeyStore
+func (k Keys) Key(_ context.Context, id string) (*Key, error) {
+	if key, ok := k[id]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// ValidateConfig configures the validation of tokens
+type ValidateConfig struct {
+	// Keys resolves the keys of the tokens, required
+	Keys KeyStore
+
+	// Issuer required in the iss claim, not checked if empty
+	Issuer string
+
+	// Audience required in the aud claim, not checked if empty
+	Audience string
+
+	// Leeway tolerates the clock skew between the issuer and the server
+	// when checking the exp and nbf claims
+	Leeway time.Duration
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Sign returns the token of the claims signed with the key
+func Sign(claims Claims, key *Key) (string, error) {
+	h, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := key.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Validate verifies the signature of a token and checks its claims
+//
+// @return: the claims of the token
+// @return: an error wrapping one of the errors of the package
+func Validate(ctx context.Context, token string, config ValidateConfig) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if config.Keys == nil {
+		return nil, fmt.Errorf("%w: no key store", ErrUnknownKey)
+	}
+	key, err := config.Keys.Key(ctx, h.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	// The algorithm is the one of the key, never the one the token names
+	if h.Algorithm != key.Algorithm {
+		return nil, fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidSignature, h.Algorithm)
+	}
+	if err := key.verify([]byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := claims.validate(config, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a JSON segment of a token
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return nil
+}
+
+// hashes are the hash functions of the algorithms
+var hashes = map[string]crypto.Hash{
+	HS256: crypto.SHA256, HS384: crypto.SHA384, HS512: crypto.SHA512,
+	RS256: crypto.SHA256, RS384: crypto.SHA384, RS512: crypto.SHA512,
+	ES256: crypto.SHA256, ES384: crypto.SHA384, ES512: crypto.SHA512,
+}
+
+// newHash returns the constructor of the hash function
+func newHash(h crypto.Hash) func() hash.Hash {
+	switch h {
+	case crypto.SHA384:
+		return sha512.New384
+	case crypto.SHA512:
+		return sha512.New
+	}
+	return sha256.New
+}
+
+// digest returns the digest of the signing input with the hash of the
+// algorithm
+func digest(algorithm string, input []byte) (crypto.Hash, []byte, error) {
+	h, ok := hashes[algorithm]
+	if !ok {
+		return 0, nil, fmt.Errorf("jwt: unsupported algorithm %q", algorithm)
+	}
+	d := newHash(h)()
+	d.Write(input)
+	return h, d.Sum(nil), nil
+}
+
+// sign signs the signing input of a token
+func (k *Key) sign(input []byte) ([]byte, error) {
+	h, sum, err := digest(k.Algorithm, input)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k.Algorithm[:2] {
+	case "HS":
+		if len(k.Secret) == 0 {
+			return nil, errors.New("jwt: hmac key without secret")
+		}
+		mac := hmac.New(newHash(h), k.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case "RS":
+		if key, ok := k.PrivateKey.(*rsa.PrivateKey); ok {
+			return rsa.SignPKCS1v15(rand.Reader, key, h, sum)
+		}
+	case "ES":
+		if key, ok := k.PrivateKey.(*ecdsa.PrivateKey); ok {
+			r, s, err := ecdsa.Sign(rand.Reader, key, sum)
+			if err != nil {
+				return nil, err
+			}
+			// The signature is r and s, each on the size of the curve
+			size := (key.Curve.Params().BitSize + 7) / 8
+			sig := make([]byte, 2*size)
+			r.FillBytes(sig[:size])
+			s.FillBytes(sig[size:])
+			return sig, nil
+		}
+	}
+	return nil, fmt.Errorf("jwt: key %q cannot sign with %s", k.ID, k.Algorithm)
+}
+
+// verify verifies the signature of the signing input of a token
+func (k *Key) verify(input, sig []byte) error {
+	h, sum, err := digest(k.Algorithm, input)
+	if err != nil {
+		return err
+	}
+
+	public := k.PublicKey
+	if public == nil && k.PrivateKey != nil {
+		public = k.PrivateKey.Public()
+	}
+	valid := false
+	switch k.Algorithm[:2] {
+	case "HS":
+		mac := hmac.New(newHash(h), k.Secret)
+		mac.Write(input)
+		valid = len(k.Secret) > 0 && hmac.Equal(sig, mac.Sum(nil))
+	case "RS":
+		if key, ok := public.(*rsa.PublicKey); ok {
+			valid = rsa.VerifyPKCS1v15(key, h, sum, sig) == nil
+		}
+	case "ES":
+		if key, ok := public.(*ecdsa.PublicKey); ok {
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if len(sig) == 2*size {
+				r := new(big.Int).SetBytes(sig[:size])
+				s := new(big.Int).SetBytes(sig[size:])
+				valid = ecdsa.Verify(key, sum, r, s)
+			}
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Claims are the claims of a token, decoded as JSON
+type Claims map[string]any
+
+// Subject returns the sub claim, e.g. the id of the user
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Issuer returns the iss claim
+func (c Claims) Issuer() string {
+	return c.String("iss")
+}
+
+// Audience returns the aud claim, a string or an array of strings
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		audience := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
+
+// String returns a string claim, empty if it is missing or not a string
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim listing strings, an array of strings or a string
+// separated by spaces, e.g. "roles" or "scope"
+func (c Claims) Strings(name string) []string {
+	switch claim := c[name].(type) {
+	case string:
+		return strings.Fields(claim)
+	case []any:
+		values := make([]string, 0, len(claim))
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Time returns a NumericDate claim, e.g. "exp", and whether it is set,
+// false if it is missing or not a number
+func (c Claims) Time(name string) (time.Time, bool) {
+	seconds, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), true
+}
+
+// registeredTime returns a registered NumericDate claim and whether it is
+// set
+//
+// @return: ErrMalformed if the claim is set but not a number, so that
+// tokens cannot skip the checks of their exp or nbf with a string
+func (c Claims) registeredTime(name string) (time.Time, bool, error) {
+	if _, set := c[name]; !set {
+		return time.Time{}, false, nil
+	}
+	t, ok := c.Time(name)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: invalid %s claim", ErrMalformed, name)
+	}
+	return t, true, nil
+}
+
+// validate checks the registered claims at the time
+func (c Claims) validate(config ValidateConfig, now time.Time) error {
+	exp, ok, err := c.registeredTime("exp")
+	if err != nil {
+		return err
+	}
+	if ok && !now.Before(exp.Add(config.Leeway)) {
+		return ErrExpired
+	}
+	nbf, ok, err := c.registeredTime("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Before(nbf.Add(-config.Leeway)) {
+		return ErrNotYetValid
+	}
+	if config.Issuer != "" && c.Issuer() != config.Issuer {
+		return ErrInvalidIssuer
+	}
+	if config.Audience != "" && !slices.Contains(c.Audience(), config.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
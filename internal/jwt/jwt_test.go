@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignValidate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	keys := []*Key{
+		{ID: "hs256", Algorithm: HS256, Secret: []byte("secret")},
+		{ID: "hs512", Algorithm: HS512, Secret: []byte("secret")},
+		{ID: "rs256", Algorithm: RS256, PrivateKey: rsaKey},
+		{ID: "es256", Algorithm: ES256, PrivateKey: p256},
+		{ID: "es512", Algorithm: ES512, PrivateKey: p521},
+	}
+	store := Keys{}
+	for _, key := range keys {
+		// Verification only needs the public keys
+		store[key.ID] = &Key{ID: key.ID, Algorithm: key.Algorithm, Secret: key.Secret}
+		if key.PrivateKey != nil {
+			store[key.ID].PublicKey = key.PrivateKey.Public()
+		}
+	}
+	config := ValidateConfig{Keys: store}
+
+	for _, key := range keys {
+		token, err := Sign(Claims{"sub": "ada"}, key)
+		require.NoError(t, err, key.ID)
+		claims, err := Validate(t.Context(), token, config)
+		require.NoError(t, err, key.ID)
+		require.Equal(t, "ada", claims.Subject())
+
+		// Tampered tokens are rejected
+		parts := strings.Split(token, ".")
+		forged, err := Sign(Claims{"sub": "admin"}, &Key{Algorithm: HS256, Secret: []byte("other")})
+		require.NoError(t, err)
+		_, err = Validate(t.Context(), parts[0]+"."+strings.Split(forged, ".")[1]+"."+parts[2], config)
+		require.ErrorIs(t, err, ErrInvalidSignature, key.ID)
+	}
+
+	// The algorithm of the key applies, e.g. HMAC with an RSA public key
+	// is rejected
+	token, err := Sign(Claims{"sub": "ada"}, &Key{ID: "rs256", Algorithm: HS256, Secret: []byte("x")})
+	require.NoError(t, err)
+	_, err = Validate(t.Context(), token, config)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	token, err = Sign(Claims{}, &Key{ID: "unknown", Algorithm: HS256, Secret: []byte("x")})
+	require.NoError(t, err)
+	_, err = Validate(t.Context(), token, config)
+	require.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = Validate(t.Context(), "not.a-token", config)
+	require.ErrorIs(t, err, ErrMalformed)
+
+	// Without key store, every token is rejected
+	_, err = Validate(t.Context(), token, ValidateConfig{})
+	require.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestClaims_Validate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	config := ValidateConfig{Issuer: "https://auth.example.com", Audience: "api", Leeway: time.Minute}
+	valid := func() Claims {
+		return Claims{"iss": "https://auth.example.com", "aud": []any{"web", "api"}, "exp": float64(now.Unix() + 1), "nbf": float64(now.Unix())}
+	}
+
+	require.NoError(t, valid().validate(config, now))
+
+	// Within the leeway
+	claims := valid()
+	claims["exp"] = float64(now.Unix() - 30)
+	require.NoError(t, claims.validate(config, now))
+	claims["exp"] = float64(now.Unix() - 60)
+	require.ErrorIs(t, claims.validate(config, now), ErrExpired)
+
+	claims = valid()
+	claims["nbf"] = float64(now.Unix() + 120)
+	require.ErrorIs(t, claims.validate(config, now), ErrNotYetValid)
+
+	claims = valid()
+	claims["iss"] = "https://evil.example.com"
+	require.ErrorIs(t, claims.validate(config, now), ErrInvalidIssuer)
+
+	claims = valid()
+	claims["aud"] = "web"
+	require.ErrorIs(t, claims.validate(config, now), ErrInvalidAudience)
+
+	// Malformed time claims are rejected rather than ignored
+	for _, name := range []string{"exp", "nbf"} {
+		for _, value := range []any{"1700000000", nil, true} {
+			claims = valid()
+			claims[name] = value
+			require.ErrorIs(t, claims.validate(config, now), ErrMalformed, name)
+		}
+	}
+	claims = valid()
+	delete(claims, "exp")
+	delete(claims, "nbf")
+	require.NoError(t, claims.validate(config, now))
+}
+
+func TestMiddleware(t *testing.T) {
+	key := &Key{Algorithm: HS256, Secret: []byte("secret")}
+	serve := func(config Config, authorization string) (*httptest.ResponseRecorder, Claims, bool) {
+		config.Keys = Keys{"": key}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		var claims Claims
+		reached := false
+		c := &types.Context{Request: r, Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{Middleware(config)}, func(c *types.Context) {
+			reached = true
+			claims, _ = FromContext(c)
+		}))
+		return w, claims, reached
+	}
+
+	token, err := Sign(Claims{"sub": "ada", "exp": float64(time.Now().Add(time.Hour).Unix())}, key)
+	require.NoError(t, err)
+	_, claims, reached := serve(Config{}, "Bearer "+token)
+	require.True(t, reached)
+	require.Equal(t, "ada", claims.Subject())
+
+	w, _, reached := serve(Config{}, "")
+	require.False(t, reached)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+	require.JSONEq(t, `{"error":"Unauthorized","message":"no token"}`, w.Body.String())
+
+	expired, err := Sign(Claims{"exp": float64(time.Now().Add(-time.Hour).Unix())}, key)
+	require.NoError(t, err)
+	w, _, reached = serve(Config{}, "Bearer "+expired)
+	require.False(t, reached)
+	require.JSONEq(t, `{"error":"Unauthorized","message":"token expired"}`, w.Body.String())
+
+	// Optional authentication still rejects invalid tokens
+	_, claims, reached = serve(Config{Optional: true}, "")
+	require.True(t, reached)
+	require.Nil(t, claims)
+	_, _, reached = serve(Config{Optional: true}, "Bearer "+expired)
+	require.False(t, reached)
+}
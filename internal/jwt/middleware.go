@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/apictx"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// ClaimsKey is the key of the claims of the authenticated requests, see
+// Context.Get and apictx.Get
+var ClaimsKey = apictx.NewKey[Claims]("jwt.claims")
+
+// Config configures the authentication of requests
+type Config struct {
+	ValidateConfig
+
+	// Token returns the token of a request, the bearer token of the
+	// Authorization header if nil, e.g. to read it from a cookie
+	Token func(c *types.Context) string
+
+	// Optional lets the requests without token through unauthenticated,
+	// e.g. for routes personalized for the users that are logged in.
+	// Invalid tokens are rejected nonetheless.
+	Optional bool
+}
+
+// Middleware returns a middleware rejecting the requests without a valid
+// token with 401 Unauthorized
+//
+// The claims of the token are available with FromContext, or
+// c.Get(ClaimsKey.Name()), e.g. to identify the user with their subject.
+func Middleware(config Config) types.MiddlewareFunc {
+	if config.Token == nil {
+		config.Token = BearerToken
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			token := config.Token(c)
+			if token == "" && config.Optional {
+				next(c)
+				return
+			}
+
+			var claims Claims
+			err := ErrNoToken
+			if token != "" {
+				claims, err = Validate(c.Request.Context(), token, config.ValidateConfig)
+			}
+			if err != nil {
+				c.Abort()
+				c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+				c.ErrorString(http.StatusUnauthorized, strings.TrimPrefix(err.Error(), "jwt: "))
+				return
+			}
+			apictx.Set(c, ClaimsKey, claims)
+			next(c)
+		}
+	}
+}
+
+// BearerToken returns the bearer token of the Authorization header of the
+// request, see RFC 6750
+func BearerToken(c *types.Context) string {
+	scheme, token, ok := strings.Cut(c.Request.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// FromContext returns the claims of the request, set by Middleware
+func FromContext(c *types.Context) (Claims, bool) {
+	return apictx.Get(c, ClaimsKey)
+}
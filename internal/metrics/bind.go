@@ -0,0 +1,22 @@
+package metrics
+
+import "github.com/skjdfhkskjds/go-api/internal/types"
+
+// BindCacheStatser reports the statistics of a binding cache, e.g.
+// types.DefaultBindCache
+type BindCacheStatser interface {
+	Stats() types.BindCacheStats
+}
+
+// BindCache exports the lookups and the types of the cache of the struct
+// fields bound to requests
+func BindCache(s BindCacheStatser) Collector {
+	return CollectorFunc(func() []Sample {
+		stats := s.Stats()
+		return []Sample{
+			{Name: "http_bind_cache_hits_total", Help: "Total number of binding lookups of cached struct types.", Type: Counter, Value: float64(stats.Hits)},
+			{Name: "http_bind_cache_misses_total", Help: "Total number of binding lookups reflecting on a struct type.", Type: Counter, Value: float64(stats.Misses)},
+			{Name: "http_bind_cache_types", Help: "Number of struct types cached for binding.", Type: Gauge, Value: float64(stats.Types)},
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import "github.com/skjdfhkskjds/go-api/internal/middleware"
+
+// ConcurrencyStatser reports the requests of every route, e.g.
+// *middleware.ConcurrencyLimiter
+type ConcurrencyStatser interface {
+	Stats() map[string]middleware.ConcurrencyStats
+}
+
+// Concurrency exports the requests in flight and queued per route of a
+// concurrency limiter, labelled with the route
+func Concurrency(s ConcurrencyStatser) Collector {
+	return CollectorFunc(func() []Sample {
+		stats := s.Stats()
+		samples := make([]Sample, 0, 3*len(stats))
+		for route, stat := range stats {
+			labels := map[string]string{"route": route}
+			samples = append(samples,
+				Sample{Name: "http_requests_in_flight", Help: "Number of requests being handled.", Type: Gauge, Labels: labels, Value: float64(stat.InFlight)},
+				Sample{Name: "http_requests_queued", Help: "Number of requests waiting for a concurrency slot.", Type: Gauge, Labels: labels, Value: float64(stat.Queued)},
+				Sample{Name: "http_requests_rejected_total", Help: "Total number of requests rejected over the concurrency limit.", Type: Counter, Labels: labels, Value: float64(stat.Rejected)},
+			)
+		}
+		return samples
+	})
+}
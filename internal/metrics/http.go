@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// HTTP counts the requests and their duration, labelled with their
+// method, status and the route tags of its keys, see middleware.Tags
+type HTTP struct {
+	tags []string
+
+	mu     sync.Mutex
+	series map[string]*httpSeries
+	now    func() time.Time
+}
+
+// httpSeries are the counters of a set of labels
+type httpSeries struct {
+	labels   map[string]string
+	requests float64
+	duration float64 // seconds
+}
+
+// NewHTTP creates the request metrics, labelled with the tags of the keys,
+// e.g. "team" and "tier". Tags are labels only when listed, so that the
+// cardinality of the metrics stays under control.
+func NewHTTP(tags ...string) *HTTP {
+	return &HTTP{tags: tags, series: make(map[string]*httpSeries), now: time.Now}
+}
+
+// Middleware returns the middleware counting the requests, used by the
+// engine so that the tags of every route are seen
+func (h *HTTP) Middleware() types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			start := h.now()
+			writer := types.NewResponseWriter(c.Writer)
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+
+			labels := map[string]string{
+				"method": c.Request.Method,
+				"status": strconv.Itoa(writer.Status()),
+			}
+			tags := c.Tags()
+			for _, key := range h.tags {
+				labels[key] = tags[key]
+			}
+			h.observe(labels, h.now().Sub(start))
+		}
+	}
+}
+
+// observe counts a request
+func (h *HTTP) observe(labels map[string]string, duration time.Duration) {
+	key := formatLabels(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &httpSeries{labels: labels}
+		h.series[key] = series
+	}
+	series.requests++
+	series.duration += duration.Seconds()
+}
+
+// Collect implements Collector
+func (h *HTTP) Collect() []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := make([]Sample, 0, 2*len(h.series))
+	for _, series := range h.series {
+		samples = append(samples,
+			Sample{Name: "http_requests_total", Help: "Total number of requests handled.", Type: Counter, Labels: series.labels, Value: series.requests},
+			Sample{Name: "http_request_duration_seconds_total", Help: "Total time spent handling requests.", Type: Counter, Labels: series.labels, Value: series.duration},
+		)
+	}
+	return samples
+}
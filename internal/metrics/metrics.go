@@ -0,0 +1,157 @@
+// Package metrics gathers metrics from collectors and exposes them in the
+// Prometheus text format
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Type is the type of a metric
+type Type string
+
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+)
+
+// Sample is the value of a metric when it was gathered
+type Sample struct {
+	Name   string
+	Help   string
+	Type   Type
+	Labels map[string]string
+	Value  float64
+}
+
+// Collector produces samples when the metrics are gathered
+type Collector interface {
+	Collect() []Sample
+}
+
+// CollectorFunc adapts a function to Collector
+type CollectorFunc func() []Sample
+
+// Collect implements Collector
+func (f CollectorFunc) Collect() []Sample {
+	return f()
+}
+
+// Registry gathers the samples of its collectors. The zero value is ready
+// to use.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+// Register adds a collector
+func (r *Registry) Register(collector Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collector)
+}
+
+// Gather collects the samples of every collector, sorted by name and
+// labels
+func (r *Registry) Gather() []Sample {
+	r.mu.RLock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.RUnlock()
+
+	var samples []Sample
+	for _, collector := range collectors {
+		samples = append(samples, collector.Collect()...)
+	}
+	slices.SortStableFunc(samples, func(a, b Sample) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(formatLabels(a.Labels), formatLabels(b.Labels)))
+	})
+	return samples
+}
+
+// Handler serves the gathered samples in the Prometheus text format
+func (r *Registry) Handler() types.HandlerFunc {
+	return func(c *types.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		WriteText(c.Writer, r.Gather())
+	}
+}
+
+// WriteText writes samples sorted by name in the Prometheus text format,
+// with the help and type of every metric before its first sample
+func WriteText(w io.Writer, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+	for i, sample := range samples {
+		if i == 0 || samples[i-1].Name != sample.Name {
+			if sample.Help != "" {
+				fmt.Fprintf(bw, "# HELP %s %s\n", sample.Name, escapeHelp(sample.Help))
+			}
+			if sample.Type != "" {
+				fmt.Fprintf(bw, "# TYPE %s %s\n", sample.Name, sample.Type)
+			}
+		}
+		fmt.Fprintf(bw, "%s%s %s\n", sample.Name, formatLabels(sample.Labels), formatValue(sample.Value))
+	}
+	return bw.Flush()
+}
+
+// formatLabels formats labels sorted by name, e.g. {db="main"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range slices.Sorted(maps.Keys(labels)) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// formatValue formats a sample value, with the special values spelled as
+// Prometheus expects them
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escapeHelp escapes the text of a HELP line
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+// escapeLabel escapes a label value
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
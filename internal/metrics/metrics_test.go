@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/middleware"
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ DBStatser     = (*sql.DB)(nil)
+	_ MemoryStatser = (*store.Memory)(nil)
+)
+
+// fakeDB is a database reporting fixed statistics
+type fakeDB sql.DBStats
+
+func (db fakeDB) Stats() sql.DBStats {
+	return sql.DBStats(db)
+}
+
+// fakeStore is a memory store reporting fixed statistics
+type fakeStore store.MemoryStats
+
+func (s fakeStore) Stats() store.MemoryStats {
+	return store.MemoryStats(s)
+}
+
+func TestRegistry(t *testing.T) {
+	var registry Registry
+	registry.Register(CollectorFunc(func() []Sample {
+		return []Sample{
+			{Name: "queue_depth", Help: "Jobs waiting\nto run.", Type: Gauge, Labels: map[string]string{"queue": `"mail"`}, Value: 3},
+			{Name: "queue_depth", Type: Gauge, Labels: map[string]string{"queue": "billing"}, Value: math.Inf(1)},
+		}
+	}))
+	registry.Register(DBStats("main", fakeDB{OpenConnections: 4, InUse: 1, Idle: 3, WaitDuration: 1500 * time.Millisecond}))
+	registry.Register(MemoryStore("cache", fakeStore{Entries: 2, Hits: 5}))
+
+	w := httptest.NewRecorder()
+	registry.Handler()(&types.Context{Request: httptest.NewRequest(http.MethodGet, "/metrics", nil), Writer: w})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "version=0.0.4")
+
+	body := w.Body.String()
+	require.Contains(t, body, "# HELP queue_depth Jobs waiting\\nto run.\n"+
+		"# TYPE queue_depth gauge\n"+
+		"queue_depth{queue=\"\\\"mail\\\"\"} 3\n"+
+		"queue_depth{queue=\"billing\"} +Inf\n")
+	require.Equal(t, 1, strings.Count(body, "# TYPE queue_depth"))
+	require.Contains(t, body, "# TYPE sql_open_connections gauge\nsql_open_connections{db=\"main\"} 4\n")
+	require.Contains(t, body, "sql_wait_duration_seconds_total{db=\"main\"} 1.5\n")
+	require.Contains(t, body, "store_entries{store=\"cache\"} 2\n")
+	require.Contains(t, body, "# TYPE store_hits_total counter\nstore_hits_total{store=\"cache\"} 5\n")
+}
+
+func TestHTTP(t *testing.T) {
+	h := NewHTTP("team")
+	now := time.Unix(0, 0)
+	h.now = func() time.Time {
+		now = now.Add(250 * time.Millisecond)
+		return now
+	}
+
+	serve := func(middlewares []types.MiddlewareFunc, status int) {
+		c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: httptest.NewRecorder()}
+		middlewares = append([]types.MiddlewareFunc{h.Middleware()}, middlewares...)
+		c.Execute(types.Chain(middlewares, func(c *types.Context) { c.Status(status) }))
+	}
+	payments := []types.MiddlewareFunc{middleware.Tags("team:payments", "tier:critical")}
+	serve(payments, http.StatusOK)
+	serve(payments, http.StatusOK)
+	serve(nil, http.StatusNotFound)
+
+	var registry Registry
+	registry.Register(h)
+	var b strings.Builder
+	require.NoError(t, WriteText(&b, registry.Gather()))
+	require.Contains(t, b.String(), "http_requests_total{method=\"GET\",status=\"200\",team=\"payments\"} 2\n")
+	require.Contains(t, b.String(), "http_requests_total{method=\"GET\",status=\"404\",team=\"\"} 1\n")
+	require.Contains(t, b.String(), "http_request_duration_seconds_total{method=\"GET\",status=\"200\",team=\"payments\"} 0.5\n")
+	require.NotContains(t, b.String(), "tier")
+}
+
+func TestConcurrency(t *testing.T) {
+	limiter := middleware.NewConcurrencyLimiter(middleware.ConcurrencyConfig{Limit: 1})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/users/7", nil), Writer: httptest.NewRecorder(), Route: "/users/:id"}
+		c.Execute(types.Chain([]types.MiddlewareFunc{limiter.Middleware()}, func(*types.Context) { <-release }))
+	}()
+	require.Eventually(t, func() bool { return limiter.Stats()["/users/:id"].InFlight == 1 }, time.Second, time.Millisecond)
+
+	var registry Registry
+	registry.Register(Concurrency(limiter))
+	var b strings.Builder
+	require.NoError(t, WriteText(&b, registry.Gather()))
+	require.Contains(t, b.String(), "# TYPE http_requests_in_flight gauge\nhttp_requests_in_flight{route=\"/users/:id\"} 1\n")
+	require.Contains(t, b.String(), "http_requests_queued{route=\"/users/:id\"} 0\n")
+	close(release)
+	<-done
+}
+
+func TestLimitWarnings(t *testing.T) {
+	warnings := &middleware.LimitWarnings{}
+	limits := middleware.Limits(middleware.LimitsConfig{WarnBodySize: 1, Warnings: warnings})
+	limits(func(*types.Context) {})(&types.Context{
+		Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader("large")),
+		Writer:  httptest.NewRecorder(),
+	})
+
+	var registry Registry
+	registry.Register(LimitWarnings(warnings))
+	var b strings.Builder
+	require.NoError(t, WriteText(&b, registry.Gather()))
+	require.Contains(t, b.String(), "# TYPE http_limit_warnings_total counter\nhttp_limit_warnings_total{limit=\"body-size\"} 1\n")
+}
+
+func TestBindCache(t *testing.T) {
+	cache := &types.BindCache{}
+	var registry Registry
+	registry.Register(BindCache(cache))
+	var b strings.Builder
+	require.NoError(t, WriteText(&b, registry.Gather()))
+	require.Contains(t, b.String(), "# TYPE http_bind_cache_hits_total counter\nhttp_bind_cache_hits_total 0\n")
+	require.Contains(t, b.String(), "# TYPE http_bind_cache_types gauge\nhttp_bind_cache_types 0\n")
+}
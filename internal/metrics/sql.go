@@ -0,0 +1,32 @@
+package metrics
+
+import "database/sql"
+
+// DBStatser is a database connection pool reporting its statistics, e.g.
+// *sql.DB
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// DBStats exports the statistics of a database connection pool, labelled
+// with the name of the database
+func DBStats(name string, db DBStatser) Collector {
+	labels := map[string]string{"db": name}
+	return CollectorFunc(func() []Sample {
+		stats := db.Stats()
+		sample := func(name, help string, typ Type, value float64) Sample {
+			return Sample{Name: name, Help: help, Type: typ, Labels: labels, Value: value}
+		}
+		return []Sample{
+			sample("sql_max_open_connections", "Maximum number of open connections to the database.", Gauge, float64(stats.MaxOpenConnections)),
+			sample("sql_open_connections", "Number of established connections, in use and idle.", Gauge, float64(stats.OpenConnections)),
+			sample("sql_in_use_connections", "Number of connections currently in use.", Gauge, float64(stats.InUse)),
+			sample("sql_idle_connections", "Number of idle connections.", Gauge, float64(stats.Idle)),
+			sample("sql_wait_count_total", "Total number of connections waited for.", Counter, float64(stats.WaitCount)),
+			sample("sql_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", Counter, stats.WaitDuration.Seconds()),
+			sample("sql_max_idle_closed_total", "Total number of connections closed due to the maximum of idle connections.", Counter, float64(stats.MaxIdleClosed)),
+			sample("sql_max_idle_time_closed_total", "Total number of connections closed due to the maximum idle time.", Counter, float64(stats.MaxIdleTimeClosed)),
+			sample("sql_max_lifetime_closed_total", "Total number of connections closed due to the maximum lifetime.", Counter, float64(stats.MaxLifetimeClosed)),
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import "github.com/skjdfhkskjds/go-api/internal/store"
+
+// MemoryStatser is a memory store reporting its statistics, e.g.
+// *store.Memory
+type MemoryStatser interface {
+	Stats() store.MemoryStats
+}
+
+// MemoryStore exports the statistics of a memory store, labelled with the
+// name of the store
+func MemoryStore(name string, s MemoryStatser) Collector {
+	labels := map[string]string{"store": name}
+	return CollectorFunc(func() []Sample {
+		stats := s.Stats()
+		sample := func(name, help string, typ Type, value float64) Sample {
+			return Sample{Name: name, Help: help, Type: typ, Labels: labels, Value: value}
+		}
+		return []Sample{
+			sample("store_entries", "Number of keys in the store.", Gauge, float64(stats.Entries)),
+			sample("store_hits_total", "Total number of reads of existing keys.", Counter, float64(stats.Hits)),
+			sample("store_misses_total", "Total number of reads of missing keys.", Counter, float64(stats.Misses)),
+			sample("store_evictions_total", "Total number of keys evicted to bound the store.", Counter, float64(stats.Evictions)),
+			sample("store_expirations_total", "Total number of expired keys removed.", Counter, float64(stats.Expirations)),
+			sample("store_subscribers", "Number of subscriptions to channels.", Gauge, float64(stats.Subscribers)),
+			sample("store_dropped_messages_total", "Total number of messages dropped for full subscribers.", Counter, float64(stats.Dropped)),
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package metrics
+
+// LimitWarningsCounter reports the requests over the soft limits, e.g.
+// middleware.DefaultLimitWarnings
+type LimitWarningsCounter interface {
+	Counts() map[string]uint64
+}
+
+// LimitWarnings exports the number of requests let through over the soft
+// limits, labelled with the limit, e.g. to tune the limits before
+// enforcing them
+func LimitWarnings(w LimitWarningsCounter) Collector {
+	return CollectorFunc(func() []Sample {
+		counts := w.Counts()
+		samples := make([]Sample, 0, len(counts))
+		for limit, count := range counts {
+			samples = append(samples, Sample{
+				Name:   "http_limit_warnings_total",
+				Help:   "Total number of requests over a soft limit.",
+				Type:   Counter,
+				Labels: map[string]string{"limit": limit},
+				Value:  float64(count),
+			})
+		}
+		return samples
+	})
+}
@@ -0,0 +1,393 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Defaults of the response cache
+const (
+	// DefaultCacheTTL is how long responses are cached when CacheConfig.TTL
+	// is not set
+	DefaultCacheTTL = time.Minute
+
+	// DefaultCacheMaxEntries bounds the store created when
+	// CacheConfig.Store is not set, evicting the least recently used
+	// responses
+	DefaultCacheMaxEntries = 10000
+)
+
+// CacheConfig configures the response cache
+type CacheConfig struct {
+	// Store keeps the cached responses, e.g. a redisstore.Store shared by
+	// the instances, a store.Memory bounded to DefaultCacheMaxEntries if
+	// nil
+	Store store.Store
+
+	// TTL of the cached responses, DefaultCacheTTL if 0
+	TTL time.Duration
+
+	// RefreshAhead refreshes cached responses in the background when they
+	// are read less than this long before they expire, so that hot
+	// endpoints are never served a miss. 0 disables refreshing.
+	RefreshAhead time.Duration
+
+	// Key identifies the cached response of a request, the method, the
+	// request URI and the Vary headers if nil
+	Key func(c *types.Context) string
+
+	// Vary are the request headers whose values select the response, e.g.
+	// Accept-Language, part of the default key. Responses varying on other
+	// headers are not cached.
+	Vary []string
+
+	// Bypass reports whether a request skips the cache, e.g. for
+	// authenticated administrators, its response being neither read from
+	// nor stored in the cache
+	Bypass func(c *types.Context) bool
+
+	// Invalidate returns the paths whose cached responses are invalidated
+	// by a successful unsafe request, e.g. POST /users, its own path if
+	// nil, see InvalidateCache
+	Invalidate func(c *types.Context) []string
+}
+
+// cachedResponse is a response kept in the store
+type cachedResponse struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Stored  time.Time   `json:"stored"`
+	Expires time.Time   `json:"expires"`
+}
+
+// Cache returns a middleware caching the successful responses of GET
+// requests, which HEAD requests are served as well
+//
+// Responses are served from the cache with X-Cache: HIT and an Age header,
+// and stored with X-Cache: MISS, or marked X-Cache: BYPASS. Responses
+// setting cookies, marked no-store or private, or varying on headers other
+// than the Vary ones are not cached. Requests carrying credentials, an
+// Authorization or a Cookie header, are only served and stored responses
+// marked Cache-Control: public, see RFC 9111. With RefreshAhead, the remaining
+// handlers run again in the background for responses about to expire, at
+// most once per RefreshAhead across the instances sharing the store.
+//
+// Cached responses are grouped by path, a generation of each path being
+// kept in the store so that invalidating a path drops the responses of all
+// its queries and variants at once, at the cost of a lookup per request.
+func Cache(config CacheConfig) types.MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = store.NewMemory(store.MemoryConfig{MaxEntries: DefaultCacheMaxEntries})
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultCacheTTL
+	}
+	if config.Key == nil {
+		config.Key = func(c *types.Context) string { return cacheKey(c, config.Vary) }
+	}
+	if config.Invalidate == nil {
+		config.Invalidate = func(c *types.Context) []string { return []string{c.Request.URL.Path} }
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if config.Bypass != nil && config.Bypass(c) {
+				c.Header("X-Cache", "BYPASS")
+				next(c)
+				return
+			}
+			if !isSafeMethod(c.Request.Method) {
+				writer := types.NewResponseWriter(c.Writer)
+				c.Writer = writer
+				next(c)
+				c.Writer = writer.ResponseWriter
+				if writer.Status() < 400 {
+					if err := InvalidateCache(c.Request.Context(), config.Store, config.Invalidate(c)...); err != nil {
+						c.Logger().Error("cache: invalidation failed", "error", err)
+					}
+				}
+				return
+			}
+			if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+				next(c)
+				return
+			}
+
+			ctx := c.Request.Context()
+			generation, err := config.Store.Get(ctx, cacheGenerationKey(c.Request.URL.Path))
+			if errors.Is(err, store.ErrNotFound) {
+				err = nil
+			}
+			key := "cache:" + string(generation) + ":" + config.Key(c)
+			var data []byte
+			if err == nil {
+				data, err = config.Store.Get(ctx, key)
+			}
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				c.Logger().Error("cache: store failed", "key", key, "error", err)
+				next(c)
+				return
+			}
+
+			// Responses to requests with credentials may be personal, they are
+			// only shared when marked public
+			credentials := hasCredentials(c.Request)
+			var cached cachedResponse
+			if err == nil && json.Unmarshal(data, &cached) == nil && (!credentials || cacheDirective(cached.Header, "public")) {
+				if config.RefreshAhead > 0 && time.Until(cached.Expires) <= config.RefreshAhead {
+					refreshCache(c, &config, key)
+				}
+				serveCached(c, &cached)
+				return
+			}
+
+			// Only the headers of the remaining handlers are cached, those of
+			// the outer middleware, e.g. X-Request-Id or the Vary of
+			// Compress, being set again on every request
+			c.Header("X-Cache", "MISS")
+			if c.Request.Method == http.MethodHead {
+				// Handlers may leave the body of HEAD responses out
+				next(c)
+				return
+			}
+			writer := newCacheWriter(c.Writer)
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+
+			if !c.IsAborted() {
+				storeCached(ctx, c.Logger(), &config, key, writer.response(), credentials)
+			}
+		}
+	}
+}
+
+// serveCached writes a cached response, adding its headers to those of
+// the outer middleware
+func serveCached(c *types.Context, cached *cachedResponse) {
+	header := c.Writer.Header()
+	for name, values := range cached.Header {
+		header[name] = append(header[name], values...)
+	}
+	header.Set("X-Cache", "HIT")
+	header.Set("Age", strconv.Itoa(int(max(0, time.Since(cached.Stored).Seconds()))))
+	c.Writer.WriteHeader(cached.Status)
+	c.Writer.Write(cached.Body)
+}
+
+// refreshCache runs the remaining handlers of a request in the background
+// and stores their response, unless a refresh of the key is in progress
+func refreshCache(c *types.Context, config *CacheConfig, key string) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	if ok, err := config.Store.SetNX(ctx, key+":refresh", nil, config.RefreshAhead); err != nil || !ok {
+		if err != nil {
+			c.Logger().Error("cache: store failed", "key", key, "error", err)
+		}
+		return
+	}
+
+	r := c.Request.Clone(ctx)
+	r.Method = http.MethodGet // refreshed by HEAD requests as well
+	r.Body = http.NoBody
+	writer := newCacheWriter(&discardWriter{header: make(http.Header)})
+	fork := c.Fork(writer, r)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				fork.Logger().Error("cache: refresh panicked", "key", key, "error", err)
+			}
+		}()
+		fork.Next()
+		if !fork.IsAborted() {
+			storeCached(ctx, fork.Logger(), config, key, writer.response(), hasCredentials(r))
+		}
+	}()
+}
+
+// InvalidateCache drops the responses cached for the paths in the store of
+// the Cache middleware, whatever their query and variant, e.g. from a
+// handler or a subscriber of a store.PubSub
+func InvalidateCache(ctx context.Context, s store.Store, paths ...string) error {
+	generation := []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+	for _, path := range paths {
+		if err := s.Set(ctx, cacheGenerationKey(path), generation, 0); err != nil {
+			return fmt.Errorf("invalidating %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// cacheGenerationKey returns the key of the generation of the responses of
+// a path
+func cacheGenerationKey(path string) string {
+	return "cache-generation:" + path
+}
+
+// cacheKey returns the default key of a request, its method, GET for HEAD
+// requests, its URI and the values of the Vary headers
+func cacheKey(c *types.Context, vary []string) string {
+	var b strings.Builder
+	if c.Request.Method == http.MethodHead {
+		b.WriteString(http.MethodGet)
+	} else {
+		b.WriteString(c.Request.Method)
+	}
+	b.WriteByte(' ')
+	b.WriteString(c.Request.URL.RequestURI())
+	for _, name := range vary {
+		b.WriteByte(0)
+		b.WriteString(strings.Join(c.Request.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// isSafeMethod reports whether the method does not change the resources,
+// see RFC 9110
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// hasCredentials reports whether a request carries credentials, whose
+// responses are not shared unless marked public
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != ""
+}
+
+// cacheDirective reports whether the Cache-Control header has a directive,
+// e.g. no-store
+func cacheDirective(header http.Header, name string) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(value, ",") {
+			directive, _, _ = strings.Cut(strings.TrimSpace(directive), "=")
+			if strings.EqualFold(directive, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// storeCached stores a response if it can be cached, the responses to
+// requests with credentials being stored only when marked public
+func storeCached(ctx context.Context, logger types.Logger, config *CacheConfig, key string, response *cachedResponse, credentials bool) {
+	if response.Status != http.StatusOK || len(response.Header.Values("Set-Cookie")) > 0 {
+		return
+	}
+	if credentials && !cacheDirective(response.Header, "public") {
+		return
+	}
+	for _, value := range response.Header.Values("Vary") {
+		for name := range strings.SplitSeq(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" || !slices.ContainsFunc(config.Vary, func(v string) bool { return strings.EqualFold(v, name) }) {
+				return
+			}
+		}
+	}
+	if cacheDirective(response.Header, "no-store") || cacheDirective(response.Header, "private") {
+		return
+	}
+
+	response.Header.Del("X-Cache")
+	response.Stored = time.Now()
+	response.Expires = response.Stored.Add(config.TTL)
+	data, err := json.Marshal(response)
+	if err == nil {
+		err = config.Store.Set(ctx, key, data, config.TTL)
+	}
+	if err != nil {
+		logger.Error("cache: store failed", "key", key, "error", err)
+	}
+}
+
+// cacheWriter records a response while writing it
+type cacheWriter struct {
+	http.ResponseWriter
+	entry  http.Header // as set by the outer middleware
+	status int
+	header http.Header // as sent with the status
+	body   bytes.Buffer
+}
+
+// newCacheWriter creates a writer recording the headers added to those
+// already set
+func newCacheWriter(w http.ResponseWriter) *cacheWriter {
+	return &cacheWriter{ResponseWriter: w, entry: w.Header().Clone()}
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// response returns the recorded response, with the header values added
+// since the writer was created
+func (w *cacheWriter) response() *cachedResponse {
+	if w.status == 0 {
+		w.status, w.header = http.StatusOK, w.Header().Clone()
+	}
+	header := make(http.Header, len(w.header))
+	for name, values := range w.header {
+		entry := w.entry[name]
+		if len(values) >= len(entry) && slices.Equal(values[:len(entry)], entry) {
+			values = values[len(entry):]
+		}
+		if len(values) > 0 {
+			header[name] = values
+		}
+	}
+	return &cachedResponse{Status: w.status, Header: header, Body: w.body.Bytes()}
+}
+
+// discardWriter is the writer of background requests, nobody reads their
+// response besides the cache
+type discardWriter struct {
+	header http.Header
+}
+
+// Header implements http.ResponseWriter
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+// Write implements http.ResponseWriter
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *discardWriter) WriteHeader(int) {}
@@ -0,0 +1,235 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	memory := store.NewMemory(store.MemoryConfig{})
+	defer memory.Close()
+
+	var calls atomic.Int64
+	handler := Cache(CacheConfig{Store: memory})(func(c *types.Context) {
+		n := calls.Add(1)
+		switch c.Request.URL.Path {
+		case "/private":
+			c.Header("Cache-Control", "private")
+		case "/missing":
+			c.ErrorString(http.StatusNotFound, "missing")
+			return
+		}
+		c.Header("X-Call", strconv.FormatInt(n, 10))
+		c.String(http.StatusOK, "call "+strconv.FormatInt(n, 10))
+	})
+	run := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(&types.Context{Request: httptest.NewRequest(method, target, nil), Writer: w})
+		return w
+	}
+
+	w := run(http.MethodGet, "/users?page=1")
+	require.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	require.Equal(t, "call 1", w.Body.String())
+
+	w = run(http.MethodGet, "/users?page=1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, "0", w.Header().Get("Age"))
+	require.Equal(t, "1", w.Header().Get("X-Call"))
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	require.Equal(t, "call 1", w.Body.String())
+
+	// Other requests and uncacheable responses reach the handler
+	require.Equal(t, "call 2", run(http.MethodGet, "/users?page=2").Body.String())
+	require.Equal(t, "call 3", run(http.MethodPost, "/users?page=1").Body.String())
+	run(http.MethodGet, "/private")
+	require.Equal(t, "MISS", run(http.MethodGet, "/private").Header().Get("X-Cache"))
+	run(http.MethodGet, "/missing")
+	require.Equal(t, http.StatusNotFound, run(http.MethodGet, "/missing").Code)
+	require.Equal(t, int64(7), calls.Load())
+
+	// HEAD requests are served the GET responses, but not stored
+	run(http.MethodGet, "/users?page=1")
+	w = run(http.MethodHead, "/users?page=1")
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, "8", w.Header().Get("X-Call"))
+	require.Equal(t, "MISS", run(http.MethodHead, "/users?page=3").Header().Get("X-Cache"))
+	require.Equal(t, "MISS", run(http.MethodHead, "/users?page=3").Header().Get("X-Cache"))
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	memory := store.NewMemory(store.MemoryConfig{})
+	defer memory.Close()
+
+	var calls atomic.Int64
+	run := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/hot", nil), Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{
+			Cache(CacheConfig{Store: memory, TTL: time.Minute, RefreshAhead: time.Minute}),
+		}, func(c *types.Context) {
+			c.String(http.StatusOK, "call "+strconv.FormatInt(calls.Add(1), 10))
+		}))
+		return w
+	}
+
+	require.Equal(t, "call 1", run().Body.String())
+
+	// The hit is served from the cache while the response is refreshed in
+	// the background, once
+	require.Equal(t, "call 1", run().Body.String())
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return run().Body.String() == "call 2" }, time.Second, 5*time.Millisecond)
+	require.Equal(t, int64(2), calls.Load())
+}
+
+func TestCache_VaryBypassInvalidate(t *testing.T) {
+	memory := store.NewMemory(store.MemoryConfig{})
+	defer memory.Close()
+
+	var calls atomic.Int64
+	cache := Cache(CacheConfig{
+		Store:  memory,
+		Vary:   []string{"Accept-Language"},
+		Bypass: func(c *types.Context) bool { return c.GetHeader("X-Admin") != "" },
+	})
+	run := func(method, target string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: r, Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{cache}, func(c *types.Context) {
+			n := strconv.FormatInt(calls.Add(1), 10)
+			switch c.Request.URL.Path {
+			case "/users/cookies":
+				c.Header("Vary", "Cookie")
+			case "/users/fail":
+				c.ErrorString(http.StatusBadRequest, "invalid")
+				return
+			}
+			c.String(http.StatusOK, c.GetHeader("Accept-Language")+" "+n)
+		}))
+		return w
+	}
+
+	// Variants are cached apart
+	require.Equal(t, "en 1", run(http.MethodGet, "/users", "Accept-Language", "en").Body.String())
+	require.Equal(t, "fr 2", run(http.MethodGet, "/users", "Accept-Language", "fr").Body.String())
+	require.Equal(t, "en 1", run(http.MethodGet, "/users", "Accept-Language", "en").Body.String())
+	require.Equal(t, "en 3", run(http.MethodGet, "/users?page=2", "Accept-Language", "en").Body.String())
+
+	// Responses varying on other headers are not cached
+	run(http.MethodGet, "/users/cookies")
+	require.Equal(t, "MISS", run(http.MethodGet, "/users/cookies").Header().Get("X-Cache"))
+
+	// Bypassed requests skip the cache
+	w := run(http.MethodGet, "/users", "Accept-Language", "en", "X-Admin", "1")
+	require.Equal(t, "BYPASS", w.Header().Get("X-Cache"))
+	require.NotEqual(t, "en 1", w.Body.String())
+
+	// Failed unsafe requests keep the cache, successful ones invalidate
+	// every variant of their path
+	run(http.MethodPost, "/users/fail")
+	require.Equal(t, "HIT", run(http.MethodGet, "/users", "Accept-Language", "fr").Header().Get("X-Cache"))
+	run(http.MethodPost, "/users")
+	require.Equal(t, "MISS", run(http.MethodGet, "/users", "Accept-Language", "fr").Header().Get("X-Cache"))
+	require.Equal(t, "MISS", run(http.MethodGet, "/users", "Accept-Language", "en").Header().Get("X-Cache"))
+
+	// and so does InvalidateCache
+	require.NoError(t, InvalidateCache(t.Context(), memory, "/users"))
+	require.Equal(t, "MISS", run(http.MethodGet, "/users", "Accept-Language", "en").Header().Get("X-Cache"))
+}
+
+func TestCache_OuterVary(t *testing.T) {
+	var calls atomic.Int64
+	handler := Compress(CompressConfig{})(Cache(CacheConfig{})(func(c *types.Context) {
+		c.String(http.StatusOK, "call "+strconv.FormatInt(calls.Add(1), 10))
+	}))
+	for range 2 {
+		w := httptest.NewRecorder()
+		handler(&types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w})
+		require.Equal(t, "call 1", w.Body.String())
+	}
+}
+
+func TestCache_Credentials(t *testing.T) {
+	var calls atomic.Int64
+	handler := Cache(CacheConfig{})(func(c *types.Context) {
+		n := strconv.FormatInt(calls.Add(1), 10)
+		switch c.Request.URL.Path {
+		case "/public":
+			c.Header("Cache-Control", "public, max-age=60")
+		case "/session":
+			c.SetCookie("session", n, 0, "/", "", true, true)
+		}
+		c.String(http.StatusOK, c.GetHeader("Authorization")+" "+n)
+	})
+	run := func(target string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler(&types.Context{Request: r, Writer: w})
+		return w
+	}
+
+	// Responses to requests with credentials are neither stored nor served
+	// from the cache
+	require.Equal(t, "alice 1", run("/me", "Authorization", "alice").Body.String())
+	require.Equal(t, "bob 2", run("/me", "Authorization", "bob").Body.String())
+	require.Equal(t, " 3", run("/me").Body.String())
+	require.Equal(t, " 4", run("/me", "Cookie", "session=bob").Body.String())
+	require.Equal(t, " 3", run("/me").Body.String())
+
+	// unless marked public
+	require.Equal(t, "alice 5", run("/public", "Authorization", "alice").Body.String())
+	require.Equal(t, "alice 5", run("/public", "Cookie", "session=bob").Body.String())
+	require.Equal(t, "alice 5", run("/public").Body.String())
+
+	// Responses setting cookies are never stored
+	run("/session")
+	w := run("/session")
+	require.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	require.Equal(t, " 7", w.Body.String())
+}
+
+func TestCache_OuterHeaders(t *testing.T) {
+	var requests atomic.Int64
+	outer := func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.Header("X-Request-Id", strconv.FormatInt(requests.Add(1), 10))
+			c.Header("Vary", "Origin")
+			next(c)
+		}
+	}
+	handler := outer(Cache(CacheConfig{Vary: []string{"Accept-Language"}})(func(c *types.Context) {
+		c.Header("Vary", "Accept-Language")
+		c.Header("X-Version", "1")
+		c.String(http.StatusOK, "ok")
+	}))
+	run := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(&types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w})
+		return w
+	}
+
+	// Hits keep the headers of the outer middleware of their own request
+	require.Equal(t, "MISS", run().Header().Get("X-Cache"))
+	w := run()
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, []string{"2"}, w.Header().Values("X-Request-Id"))
+	require.Equal(t, []string{"Origin", "Accept-Language"}, w.Header().Values("Vary"))
+	require.Equal(t, "1", w.Header().Get("X-Version"))
+}
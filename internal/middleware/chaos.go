@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Request headers injecting faults when ChaosConfig.Headers is set
+const (
+	ChaosLatencyHeader = "X-Chaos-Latency" // a duration, e.g. 500ms
+	ChaosErrorHeader   = "X-Chaos-Error"   // a status code, e.g. 503
+	ChaosDropHeader    = "X-Chaos-Drop"    // true to drop the connection
+)
+
+// ChaosConfig configures the Chaos middleware, rates being probabilities
+// from 0 to 1
+type ChaosConfig struct {
+	// Latency added to a LatencyRate of the requests
+	Latency     time.Duration
+	LatencyRate float64
+
+	// ErrorStatus answers an ErrorRate of the requests,
+	// http.StatusServiceUnavailable if 0
+	ErrorStatus int
+	ErrorRate   float64
+
+	// DropRate of the requests whose connection is dropped without
+	// response
+	DropRate float64
+
+	// Headers lets the clients request faults with ChaosLatencyHeader,
+	// ChaosErrorHeader and ChaosDropHeader, on top of the rates
+	Headers bool
+}
+
+// Chaos returns a middleware injecting latency, errors and dropped
+// connections, so that the retry logic of clients can be tested against
+// the server, e.g. on the routes of a group
+//
+// It only injects faults in the debug and test modes of the engine, see
+// Context.Development, and does nothing in release mode or outside the
+// engine. The faults injected
+// are listed in the X-Chaos response header, unless the connection is
+// dropped. Latency ends early when the request is canceled.
+func Chaos(config ChaosConfig) types.MiddlewareFunc {
+	return chaos(config, rand.Float64)
+}
+
+// chaos returns the Chaos middleware drawing the faults from random
+func chaos(config ChaosConfig, random func() float64) types.MiddlewareFunc {
+	if config.ErrorStatus == 0 {
+		config.ErrorStatus = http.StatusServiceUnavailable
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if !c.Development {
+				next(c)
+				return
+			}
+
+			latency, status, drop := time.Duration(0), 0, false
+			if config.LatencyRate > 0 && random() < config.LatencyRate {
+				latency = config.Latency
+			}
+			if config.DropRate > 0 && random() < config.DropRate {
+				drop = true
+			}
+			if config.ErrorRate > 0 && random() < config.ErrorRate {
+				status = config.ErrorStatus
+			}
+			if config.Headers {
+				header := c.Request.Header
+				if d, err := time.ParseDuration(header.Get(ChaosLatencyHeader)); err == nil && d > 0 {
+					latency = d
+				}
+				if s, err := strconv.Atoi(header.Get(ChaosErrorHeader)); err == nil && s >= 400 && s <= 599 {
+					status = s
+				}
+				if b, err := strconv.ParseBool(header.Get(ChaosDropHeader)); err == nil && b {
+					drop = true
+				}
+			}
+
+			var faults []string
+			if latency > 0 {
+				faults = append(faults, "latency="+latency.String())
+				timer := time.NewTimer(latency)
+				select {
+				case <-timer.C:
+				case <-c.Request.Context().Done():
+					timer.Stop()
+				}
+			}
+			if drop {
+				// net/http closes the connection without response
+				panic(http.ErrAbortHandler)
+			}
+			if status != 0 {
+				faults = append(faults, "error="+strconv.Itoa(status))
+			}
+			if len(faults) > 0 {
+				c.Header("X-Chaos", strings.Join(faults, ", "))
+			}
+			if status != 0 {
+				c.Abort()
+				c.ErrorString(status, "fault injected")
+				return
+			}
+			next(c)
+		}
+	}
+}
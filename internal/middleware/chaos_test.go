@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// development runs the chain outside the release mode of the engine
+func development(next types.HandlerFunc) types.HandlerFunc {
+	return func(c *types.Context) {
+		c.Development = true
+		next(c)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	draws := []float64{}
+	random := func() float64 {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+	config := ChaosConfig{Latency: 20 * time.Millisecond, LatencyRate: 0.5, ErrorRate: 0.1, DropRate: 0.01}
+	chaosed := chaos(config, random)
+
+	// Latency, no drop, no error
+	draws = []float64{0.4, 0.5, 0.5}
+	start := time.Now()
+	w, _, reached := serve(httptest.NewRequest(http.MethodGet, "/", nil), development, chaosed)
+	require.True(t, reached)
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	require.Equal(t, "latency=20ms", w.Header().Get("X-Chaos"))
+
+	// Error
+	draws = []float64{0.9, 0.5, 0.05}
+	w, _, reached = serve(httptest.NewRequest(http.MethodGet, "/", nil), development, chaosed)
+	require.False(t, reached)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "error=503", w.Header().Get("X-Chaos"))
+
+	// Drop
+	draws = []float64{0.9, 0.001, 0.5}
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		serve(httptest.NewRequest(http.MethodGet, "/", nil), development, chaosed)
+	})
+
+	// Nothing in release mode
+	_, _, reached = serve(httptest.NewRequest(http.MethodGet, "/", nil), chaos(ChaosConfig{ErrorRate: 1}, random))
+	require.True(t, reached)
+}
+
+func TestChaos_Headers(t *testing.T) {
+	chaosed := Chaos(ChaosConfig{Headers: true})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(ChaosErrorHeader, "502")
+	r.Header.Set(ChaosLatencyHeader, "1ms")
+	w, _, reached := serve(r, development, chaosed)
+	require.False(t, reached)
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	require.Equal(t, "latency=1ms, error=502", w.Header().Get("X-Chaos"))
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(ChaosDropHeader, "true")
+	require.Panics(t, func() { serve(r, development, chaosed) })
+
+	// Headers are ignored unless enabled
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(ChaosErrorHeader, "500")
+	_, _, reached = serve(r, development, Chaos(ChaosConfig{}))
+	require.True(t, reached)
+}
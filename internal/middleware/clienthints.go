@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/skjdfhkskjds/go-api/internal/useragent"
+)
+
+// DefaultClientHints are the high entropy hints requested by ClientHints
+// when none are configured
+var DefaultClientHints = []string{
+	useragent.HeaderUAFullVersionList,
+	useragent.HeaderUAPlatformVersion,
+}
+
+// ClientHintsConfig configures the client hints requested from browsers
+type ClientHintsConfig struct {
+	// High entropy hints to request, DefaultClientHints if empty. The low
+	// entropy Sec-CH-UA, Sec-CH-UA-Mobile and Sec-CH-UA-Platform hints are
+	// sent by browsers without being requested.
+	Hints []string
+}
+
+// ClientHints returns a middleware for responses that depend on
+// Context.Client
+//
+// It advertises the requested hints with Accept-CH, so that browsers send
+// them on subsequent requests, and lists every header the client
+// description is derived from in Vary, so that caches keep the variants
+// apart.
+func ClientHints(config ClientHintsConfig) types.MiddlewareFunc {
+	hints := config.Hints
+	if len(hints) == 0 {
+		hints = DefaultClientHints
+	}
+
+	acceptCH := strings.Join(hints, ", ")
+	vary := strings.Join(append([]string{
+		"User-Agent",
+		useragent.HeaderUA,
+		useragent.HeaderUAMobile,
+		useragent.HeaderUAPlatform,
+	}, hints...), ", ")
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			header := c.Writer.Header()
+			header.Set("Accept-CH", acceptCH)
+			header.Add("Vary", vary)
+			next(c)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientHints(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:126.0) Gecko/20100101 Firefox/126.0")
+
+	w, c, called := serve(r, ClientHints(ClientHintsConfig{}))
+	require.True(t, called)
+	require.Equal(t, "Sec-CH-UA-Full-Version-List, Sec-CH-UA-Platform-Version", w.Header().Get("Accept-CH"))
+	require.Contains(t, w.Header().Get("Vary"), "User-Agent, Sec-CH-UA, Sec-CH-UA-Mobile, Sec-CH-UA-Platform")
+	require.Equal(t, "Firefox", c.Client().Family)
+}
@@ -0,0 +1,316 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Default levels of the zstd and brotli encoders, favoring speed since
+// responses are compressed on the fly
+const (
+	DefaultZstdLevel   = 3
+	DefaultBrotliLevel = 4
+)
+
+// DefaultCompressMinSize is the size below which responses are not
+// compressed when CompressConfig.MinSize is not set
+const DefaultCompressMinSize = 1024
+
+// DefaultCompressExcludedTypes are the content types not compressed when
+// CompressConfig.ExcludedTypes is not set, compressed already or streamed
+var DefaultCompressExcludedTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/*", "audio/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed",
+	"text/event-stream",
+}
+
+// CompressConfig configures the Compress middleware
+type CompressConfig struct {
+	// Encoders offered to the clients, in order of preference when they
+	// accept several equally, DefaultCompressEncoders if nil
+	Encoders []CompressEncoder
+
+	// Level of the gzip and deflate compression of the default encoders,
+	// from flate.BestSpeed to flate.BestCompression, flate.DefaultCompression
+	// if 0
+	Level int
+
+	// MinSize is the size below which responses are not compressed, since
+	// the encoding would outweigh the savings, DefaultCompressMinSize if 0
+	MinSize int
+
+	// ExcludedTypes are the content types not compressed, with wildcards
+	// such as "video/*", DefaultCompressExcludedTypes if nil
+	ExcludedTypes []string
+}
+
+// CompressEncoder is a content coding of the responses
+type CompressEncoder struct {
+	// Name of the content coding in Accept-Encoding and Content-Encoding,
+	// e.g. "br"
+	Name string
+
+	// New returns a compressor writing to w
+	New func(w io.Writer) (Compressor, error)
+}
+
+// Compressor compresses a response, Close writing its end
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// DefaultCompressEncoders returns the encoders of the Compress middleware
+// when CompressConfig.Encoders is not set, zstd and brotli with their
+// default level, and gzip and deflate with the level
+func DefaultCompressEncoders(level int) []CompressEncoder {
+	return []CompressEncoder{
+		ZstdEncoder(DefaultZstdLevel),
+		BrotliEncoder(DefaultBrotliLevel),
+		GzipEncoder(level),
+		DeflateEncoder(level),
+	}
+}
+
+// GzipEncoder returns the gzip encoder with the level, see gzip.NewWriterLevel
+func GzipEncoder(level int) CompressEncoder {
+	return CompressEncoder{Name: "gzip", New: func(w io.Writer) (Compressor, error) {
+		return gzip.NewWriterLevel(w, level)
+	}}
+}
+
+// DeflateEncoder returns the deflate encoder with the level, see
+// flate.NewWriter
+func DeflateEncoder(level int) CompressEncoder {
+	return CompressEncoder{Name: "deflate", New: func(w io.Writer) (Compressor, error) {
+		return flate.NewWriter(w, level)
+	}}
+}
+
+// BrotliEncoder returns the brotli encoder with the level, from
+// brotli.BestSpeed to brotli.BestCompression
+func BrotliEncoder(level int) CompressEncoder {
+	return CompressEncoder{Name: "br", New: func(w io.Writer) (Compressor, error) {
+		return brotli.NewWriterLevel(w, level), nil
+	}}
+}
+
+// ZstdEncoder returns the zstd encoder with the level, from 1 to 22 as
+// in the zstd command
+//
+// Encoders are pooled since they are costly to create, and keep the window
+// within the 8 MiB clients must support, see RFC 8878.
+func ZstdEncoder(level int) CompressEncoder {
+	pool := &sync.Pool{}
+	return CompressEncoder{Name: "zstd", New: func(w io.Writer) (Compressor, error) {
+		if encoder, ok := pool.Get().(*zstd.Encoder); ok {
+			encoder.Reset(w)
+			return &zstdCompressor{Encoder: encoder, pool: pool}, nil
+		}
+		encoder, err := zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+			zstd.WithEncoderConcurrency(1),
+			zstd.WithWindowSize(8<<20),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &zstdCompressor{Encoder: encoder, pool: pool}, nil
+	}}
+}
+
+// zstdCompressor returns its encoder to the pool once closed
+type zstdCompressor struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+// Close implements Compressor
+func (c *zstdCompressor) Close() error {
+	err := c.Encoder.Close()
+	c.Encoder.Reset(nil)
+	c.pool.Put(c.Encoder)
+	return err
+}
+
+// Compress returns a middleware compressing the responses with the content
+// coding the client prefers, see the q-values of Accept-Encoding, among
+// zstd, brotli, gzip and deflate by default
+//
+// Responses are buffered up to MinSize to decide whether they are worth
+// compressing. Responses with a Content-Encoding or an excluded content
+// type are sent as is, and so are responses flushed before MinSize is
+// reached, e.g. server-sent events, so that streaming is never delayed.
+// Strong ETags of compressed responses are made weak.
+func Compress(config CompressConfig) types.MiddlewareFunc {
+	if config.Level == 0 {
+		config.Level = flate.DefaultCompression
+	}
+	if config.Encoders == nil {
+		config.Encoders = DefaultCompressEncoders(config.Level)
+	}
+	if config.MinSize == 0 {
+		config.MinSize = DefaultCompressMinSize
+	}
+	if config.ExcludedTypes == nil {
+		config.ExcludedTypes = DefaultCompressExcludedTypes
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.Vary("Accept-Encoding")
+			encoder := negotiateEncoding(c.Request.Header.Get("Accept-Encoding"), config.Encoders)
+			if encoder == nil {
+				next(c)
+				return
+			}
+
+			writer := &compressWriter{BufferedWriter: types.NewBufferedWriter(c.Writer), config: &config, encoder: encoder}
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+			writer.finish()
+		}
+	}
+}
+
+// negotiateEncoding returns the encoder with the highest q-value in the
+// Accept-Encoding header, the first of the encoders on ties, or nil
+func negotiateEncoding(header string, encoders []CompressEncoder) *CompressEncoder {
+	if header == "" {
+		return nil
+	}
+	qs := make(map[string]float64)
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = v
+			}
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var best *CompressEncoder
+	bestQ := 0.0
+	for i := range encoders {
+		q, ok := qs[strings.ToLower(encoders[i].Name)]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = &encoders[i], q
+		}
+	}
+	return best
+}
+
+// compressWriter compresses a response once it reaches the minimum size
+type compressWriter struct {
+	*types.BufferedWriter
+	config  *CompressConfig
+	encoder *CompressEncoder
+
+	compressor Compressor // set if the response is compressed
+}
+
+// Write implements http.ResponseWriter
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.Sent() {
+		if w.compressor != nil {
+			return w.compressor.Write(b)
+		}
+		return w.BufferedWriter.Write(b)
+	}
+
+	n, _ := w.BufferedWriter.Write(b)
+	if compressible := w.compressible(); !compressible || w.Body().Len() >= w.config.MinSize {
+		if err := w.decide(compressible); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Flush implements http.Flusher, responses flushed before they are
+// compressed are sent as is
+func (w *compressWriter) Flush() {
+	if w.compressor != nil {
+		w.compressor.Flush()
+	}
+	w.BufferedWriter.Flush()
+}
+
+// compressible reports whether the response may be compressed
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	status := w.Status()
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent ||
+		header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.Body().Bytes())
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, excluded := range w.config.ExcludedTypes {
+		if ok, _ := path.Match(excluded, mediaType); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// decide sends the header and the buffered body, compressing the response
+// if compress is set
+func (w *compressWriter) decide(compress bool) error {
+	if !compress {
+		return w.Send()
+	}
+
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoder.Name)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	compressor, err := w.encoder.New(w.ResponseWriter)
+	if err != nil {
+		return err
+	}
+	w.compressor = compressor
+
+	// The header is sent alone, the body held going to the compressor
+	body := bytes.Clone(w.Body().Bytes())
+	w.Body().Reset()
+	w.Send()
+	_, err = compressor.Write(body)
+	return err
+}
+
+// finish sends the rest of the response once the handler returned
+func (w *compressWriter) finish() {
+	w.Send()
+	if w.compressor != nil {
+		w.compressor.Close()
+	}
+}
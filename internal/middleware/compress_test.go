@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// serveCompressed serves a request accepting the encodings with the
+// handler behind Compress
+func serveCompressed(acceptEncoding string, handler types.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: r, Writer: w}
+	c.Execute(types.Chain([]types.MiddlewareFunc{Compress(CompressConfig{})}, handler))
+	return w
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("compressible text ", 100)
+	handler := func(c *types.Context) {
+		c.Header("ETag", `"v1"`)
+		c.String(http.StatusOK, body)
+	}
+
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, br", "br"},
+		{"gzip;q=0, br;q=0", ""},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"br;q=0.8, zstd;q=0.9, gzip", "gzip"},
+		{"*", "zstd"},
+		{"identity", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			w := serveCompressed(tt.acceptEncoding, handler)
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			require.Equal(t, tt.encoding, w.Header().Get("Content-Encoding"))
+
+			var reader io.Reader = w.Body
+			switch tt.encoding {
+			case "gzip":
+				gz, err := gzip.NewReader(w.Body)
+				require.NoError(t, err)
+				reader = gz
+				require.Equal(t, `W/"v1"`, w.Header().Get("ETag"))
+			case "deflate":
+				reader = flate.NewReader(w.Body)
+			case "br":
+				reader = brotli.NewReader(w.Body)
+			case "zstd":
+				zr, err := zstd.NewReader(w.Body)
+				require.NoError(t, err)
+				defer zr.Close()
+				reader = zr
+			default:
+				require.Equal(t, `"v1"`, w.Header().Get("ETag"))
+			}
+			decoded, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.Equal(t, body, string(decoded))
+		})
+	}
+}
+
+func TestCompress_Skipped(t *testing.T) {
+	// Small responses
+	w := serveCompressed("gzip", func(c *types.Context) {
+		c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	})
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	// Compressed content types
+	w = serveCompressed("gzip", func(c *types.Context) {
+		c.Data(http.StatusOK, "image/png", make([]byte, 4096))
+	})
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Equal(t, 4096, w.Body.Len())
+
+	// Responses flushed before they are compressed
+	w = serveCompressed("gzip", func(c *types.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.Writer.Write([]byte("data: 1\n\n"))
+		http.NewResponseController(c.Writer).Flush()
+		c.Writer.Write([]byte(strings.Repeat("x", 4096)))
+	})
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.True(t, w.Flushed)
+	require.Equal(t, 9+4096, w.Body.Len())
+
+	// Responses without body
+	w = serveCompressed("gzip", func(c *types.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestCompress_Encoders(t *testing.T) {
+	compress := Compress(CompressConfig{Encoders: []CompressEncoder{GzipEncoder(gzip.BestSpeed)}, MinSize: 1})
+	for range 2 {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", "br, gzip;q=0.5")
+		w, _, _ := serve(r, compress)
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	}
+
+	// Pooled zstd encoders start afresh
+	zstdEncoder := ZstdEncoder(DefaultZstdLevel)
+	for _, body := range []string{"first", "second"} {
+		var b strings.Builder
+		compressor, err := zstdEncoder.New(&b)
+		require.NoError(t, err)
+		io.WriteString(compressor, body)
+		require.NoError(t, compressor.Close())
+
+		zr, err := zstd.NewReader(strings.NewReader(b.String()))
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(zr)
+		zr.Close()
+		require.NoError(t, err)
+		require.Equal(t, body, string(decoded))
+	}
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultConcurrencyQueueTimeout is how long requests wait for a slot when
+// ConcurrencyConfig.QueueTimeout is not set
+const DefaultConcurrencyQueueTimeout = 5 * time.Second
+
+// ConcurrencyConfig configures the ConcurrencyLimiter
+type ConcurrencyConfig struct {
+	// Limit is the number of requests of a route handled at once, required
+	Limit int
+
+	// Queue is the number of requests of a route waiting for a slot, the
+	// others being rejected right away, 0 for no queue
+	Queue int
+
+	// QueueTimeout rejects the requests waiting longer for a slot,
+	// DefaultConcurrencyQueueTimeout if 0
+	QueueTimeout time.Duration
+
+	// Key groups the requests sharing a limit, the route pattern if nil,
+	// see Context.Route
+	Key func(c *types.Context) string
+}
+
+// ConcurrencyStats is a snapshot of the requests of a route
+type ConcurrencyStats struct {
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// ConcurrencyLimiter bounds the number of requests handled at once per
+// route, e.g. to protect the endpoints hitting a slow dependency without
+// limiting the whole process
+type ConcurrencyLimiter struct {
+	config ConcurrencyConfig
+
+	mu     sync.Mutex
+	routes map[string]*concurrencyRoute
+}
+
+// concurrencyRoute are the slots of a route
+type concurrencyRoute struct {
+	slots    chan struct{}
+	queued   int // guarded by the mutex of the limiter
+	rejected uint64
+}
+
+// NewConcurrencyLimiter creates a limiter with the configuration
+func NewConcurrencyLimiter(config ConcurrencyConfig) *ConcurrencyLimiter {
+	if config.Limit <= 0 {
+		panic("middleware: concurrency limit must be positive")
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = DefaultConcurrencyQueueTimeout
+	}
+	if config.Key == nil {
+		config.Key = func(c *types.Context) string { return c.Route }
+	}
+	return &ConcurrencyLimiter{config: config, routes: make(map[string]*concurrencyRoute)}
+}
+
+// ConcurrencyLimit returns a middleware bounding the number of requests
+// handled at once per route, see ConcurrencyLimiter.Middleware
+func ConcurrencyLimit(config ConcurrencyConfig) types.MiddlewareFunc {
+	return NewConcurrencyLimiter(config).Middleware()
+}
+
+// Middleware returns the middleware of the limiter
+//
+// Requests over the limit wait in the queue of their route, and are
+// rejected with 503 Service Unavailable and a Retry-After header when it is
+// full or when they waited for QueueTimeout.
+func (l *ConcurrencyLimiter) Middleware() types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			route := l.route(l.config.Key(c))
+			if !l.acquire(c, route) {
+				c.Abort()
+				c.Header("Retry-After", strconv.Itoa(int(max(time.Second, l.config.QueueTimeout)/time.Second)))
+				c.ErrorString(http.StatusServiceUnavailable, "too many concurrent requests")
+				return
+			}
+			defer func() { <-route.slots }()
+			next(c)
+		}
+	}
+}
+
+// Stats returns the requests of every route seen, by key
+func (l *ConcurrencyLimiter) Stats() map[string]ConcurrencyStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make(map[string]ConcurrencyStats, len(l.routes))
+	for key, route := range l.routes {
+		stats[key] = ConcurrencyStats{InFlight: len(route.slots), Queued: route.queued, Rejected: route.rejected}
+	}
+	return stats
+}
+
+// route returns the slots of the key
+func (l *ConcurrencyLimiter) route(key string) *concurrencyRoute {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	route, ok := l.routes[key]
+	if !ok {
+		route = &concurrencyRoute{slots: make(chan struct{}, l.config.Limit)}
+		l.routes[key] = route
+	}
+	return route
+}
+
+// acquire takes a slot of the route, waiting in its queue if needed
+//
+// @return: false if the request was rejected
+func (l *ConcurrencyLimiter) acquire(c *types.Context, route *concurrencyRoute) bool {
+	select {
+	case route.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	l.mu.Lock()
+	if route.queued >= l.config.Queue {
+		route.rejected++
+		l.mu.Unlock()
+		return false
+	}
+	route.queued++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.config.QueueTimeout)
+	defer timer.Stop()
+	acquired := false
+	select {
+	case route.slots <- struct{}{}:
+		acquired = true
+	case <-timer.C:
+	case <-c.Request.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	route.queued--
+	if !acquired {
+		route.rejected++
+	}
+	return acquired
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyConfig{Limit: 1, Queue: 1, QueueTimeout: time.Second})
+	release := make(chan struct{})
+	run := func(route string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w, Route: route}
+		c.Execute(types.Chain([]types.MiddlewareFunc{limiter.Middleware()}, func(c *types.Context) {
+			if c.Route == "/slow" {
+				<-release
+			}
+			c.Status(http.StatusOK)
+		}))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- run("/slow").Code
+		}()
+	}
+	require.Eventually(t, func() bool {
+		stats := limiter.Stats()["/slow"]
+		return stats.InFlight == 1 && stats.Queued == 1
+	}, time.Second, time.Millisecond)
+
+	// The queue is full, other routes have their own limit
+	w := run("/slow")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, run("/fast").Code)
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		require.Equal(t, http.StatusOK, code)
+	}
+	require.Equal(t, ConcurrencyStats{Rejected: 1}, limiter.Stats()["/slow"])
+}
+
+func TestConcurrencyLimiter_QueueTimeout(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyConfig{Limit: 1, Queue: 1, QueueTimeout: 10 * time.Millisecond})
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: httptest.NewRecorder()}
+		c.Execute(types.Chain([]types.MiddlewareFunc{limiter.Middleware()}, func(*types.Context) { <-release }))
+	}()
+	require.Eventually(t, func() bool { return limiter.Stats()[""].InFlight == 1 }, time.Second, time.Millisecond)
+
+	_, _, reached := serve(httptest.NewRequest(http.MethodGet, "/", nil), limiter.Middleware())
+	require.False(t, reached)
+	require.Equal(t, uint64(1), limiter.Stats()[""].Rejected)
+}
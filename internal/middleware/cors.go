@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultCORSMethods are the methods allowed when CORSConfig.AllowMethods
+// is not set
+var DefaultCORSMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// CORSConfig configures cross-origin requests, see the Fetch standard
+type CORSConfig struct {
+	// AllowOrigins are the origins allowed to send requests, e.g.
+	// "https://app.example.com", with a wildcard for subdomains, e.g.
+	// "https://*.example.com", or "*" for any origin. Any origin is
+	// allowed if empty.
+	AllowOrigins []string
+
+	// AllowMethods are the methods allowed in requests,
+	// DefaultCORSMethods if empty
+	AllowMethods []string
+
+	// AllowHeaders are the request headers allowed, those requested by the
+	// preflight requests if empty
+	AllowHeaders []string
+
+	// ExposeHeaders are the response headers readable by scripts besides
+	// the CORS-safelisted ones, may be empty
+	ExposeHeaders []string
+
+	// AllowCredentials allows cookies and authorization headers. Origins
+	// allowed with "*" are not granted credentials.
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache the preflight responses, not
+	// sent if 0
+	MaxAge time.Duration
+}
+
+// corsKey is the context key of the CORS policy of a request
+type corsKey struct{}
+
+// corsPolicy is the CORS configuration applied to a response, replaced by
+// the CORS middleware nested deeper in the chain
+type corsPolicy struct {
+	config *CORSConfig
+}
+
+// CORS returns a middleware allowing cross-origin requests
+//
+// The CORS headers are added to the responses as they are written, so that
+// a CORS middleware of a group or route overrides the one of the engine.
+// Preflight requests are answered with 204 No Content by the first CORS
+// middleware of the chain, without running the rest of it, so that the
+// middleware rejecting requests without credentials never see them: the
+// policy of a group or route only applies to its preflight requests when
+// the engine has no CORS middleware. The engine routes preflight requests
+// to the middleware of the requested method when the route has no OPTIONS
+// handler.
+func CORS(config CORSConfig) types.MiddlewareFunc {
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultCORSMethods
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if policy, ok := c.Request.Context().Value(corsKey{}).(*corsPolicy); ok {
+				policy.config = &config
+				next(c)
+				return
+			}
+
+			if isPreflight(c.Request) {
+				config.apply(c.Writer.Header(), c.Request)
+				c.Abort()
+				c.Status(http.StatusNoContent)
+				return
+			}
+
+			policy := &corsPolicy{config: &config}
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), corsKey{}, policy))
+			writer := &corsWriter{ResponseWriter: c.Writer, request: c.Request, policy: policy}
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+		}
+	}
+}
+
+// isPreflight reports whether a request is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// apply adds the CORS headers of a response to the request
+func (config *CORSConfig) apply(header http.Header, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	preflight := isPreflight(r)
+	if preflight {
+		header.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	} else {
+		header.Add("Vary", "Origin")
+	}
+
+	wildcard, ok := config.matchOrigin(origin)
+	if !ok {
+		return
+	}
+	if preflight && !slices.Contains(config.AllowMethods, r.Header.Get("Access-Control-Request-Method")) {
+		return
+	}
+
+	if wildcard {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if len(config.ExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(config.ExposeHeaders, ", "))
+		}
+		return
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(config.AllowMethods, ", "))
+	if len(config.AllowHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(config.AllowHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if config.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+	}
+}
+
+// matchOrigin matches an origin against the allowed origins
+//
+// @return: whether the origin is allowed by "*", and whether it is allowed
+func (config *CORSConfig) matchOrigin(origin string) (bool, bool) {
+	if len(config.AllowOrigins) == 0 {
+		return true, true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range config.AllowOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" {
+			return true, true
+		}
+		prefix, suffix, ok := strings.Cut(allowed, "*")
+		if !ok {
+			if origin == allowed {
+				return false, true
+			}
+			continue
+		}
+		// The wildcard stands for subdomains, not for other origins
+		if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:") {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// corsWriter adds the CORS headers of the policy when the header is
+// written
+type corsWriter struct {
+	http.ResponseWriter
+	request     *http.Request
+	policy      *corsPolicy
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *corsWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= 200 {
+		w.wroteHeader = true
+		w.policy.config.apply(w.Header(), w.request)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *corsWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (w *corsWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *corsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// serveCORS runs a request from the origin through the CORS middleware
+func serveCORS(config CORSConfig, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: r, Writer: w}
+	c.Execute(types.Chain([]types.MiddlewareFunc{CORS(config)}, func(c *types.Context) {
+		if c.Request.Method != http.MethodOptions {
+			c.String(http.StatusOK, "ok")
+		}
+	}))
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	config := CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		ExposeHeaders:    []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	w := serveCORS(config, http.MethodGet, "https://app.example.com", nil)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	// Wildcards match subdomains only
+	for origin, allowed := range map[string]bool{
+		"https://eu.api.example.org":      true,
+		"https://example.org":             false,
+		"https://evil.com/.example.org":   false,
+		"http://app.example.org":          false,
+		"https://app.example.com.evil.io": false,
+	} {
+		w := serveCORS(config, http.MethodGet, origin, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, allowed, w.Header().Get("Access-Control-Allow-Origin") != "", origin)
+	}
+
+	// Preflight requests are answered
+	preflight := map[string]string{
+		"Access-Control-Request-Method":  http.MethodPut,
+		"Access-Control-Request-Headers": "content-type, x-token",
+	}
+	w = serveCORS(config, http.MethodOptions, "https://app.example.com", preflight)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "content-type, x-token", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	require.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+
+	config.AllowMethods = []string{http.MethodGet}
+	w = serveCORS(config, http.MethodOptions, "https://app.example.com", preflight)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Any origin is allowed without credentials
+	w = serveCORS(CORSConfig{AllowCredentials: true}, http.MethodGet, "https://other.io", nil)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	w = serveCORS(CORSConfig{}, http.MethodGet, "", nil)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, w.Header().Get("Vary"))
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Defaults of the Dedup middleware
+const (
+	DefaultDedupWindow      = 10 * time.Second
+	DefaultDedupMaxBodySize = 1 << 20
+)
+
+// DefaultDedupMethods are deduplicated when DedupConfig.Methods is not set
+var DefaultDedupMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch}
+
+// DedupConfig configures the Dedup middleware
+type DedupConfig struct {
+	// Store keeps the responses of the requests within the window, a
+	// store.Memory with the default configuration if nil
+	Store store.Store
+
+	// Window within which identical requests are duplicates,
+	// DefaultDedupWindow if 0
+	Window time.Duration
+
+	// Methods deduplicated, DefaultDedupMethods if nil
+	Methods []string
+
+	// Key identifies the client of a request, e.g. the authenticated user,
+	// so that identical requests of different clients are not duplicates,
+	// DedupClient if nil
+	Key func(c *types.Context) string
+
+	// MaxBodySize of the requests deduplicated, larger requests are passed
+	// through, DefaultDedupMaxBodySize if 0
+	MaxBodySize int64
+}
+
+// Dedup returns a middleware short-circuiting the exact duplicates of a
+// request within a window, e.g. forms submitted twice by flaky clients
+//
+// Requests are identical when their client, method, URI, content type and
+// body are. Duplicates are answered with the response of the first
+// request and an X-Deduplicated header, or 409 Conflict while it is still
+// in progress. Unlike idempotency keys, this requires no cooperation from
+// the clients. Server errors are not replayed, so that the request can be
+// retried, and neither are the cookies set by the first response.
+func Dedup(config DedupConfig) types.MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = store.NewMemory(store.MemoryConfig{})
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultDedupWindow
+	}
+	if config.Methods == nil {
+		config.Methods = DefaultDedupMethods
+	}
+	if config.Key == nil {
+		config.Key = DedupClient
+	}
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = DefaultDedupMaxBodySize
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if !slices.Contains(config.Methods, c.Request.Method) || c.Request.Body == nil {
+				next(c)
+				return
+			}
+
+			// The body is read up to the limit, and passed on in full
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, config.MaxBodySize+1))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+			if err != nil || int64(len(body)) > config.MaxBodySize {
+				next(c)
+				return
+			}
+
+			key := "dedup:" + dedupHash(c, config.Key(c), body)
+			ctx := c.Request.Context()
+			first, err := config.Store.SetNX(ctx, key, nil, config.Window)
+			if err != nil {
+				c.Logger().Error("dedup: store failed", "key", key, "error", err)
+				next(c)
+				return
+			}
+			if !first {
+				serveDuplicate(c, config.Store, key)
+				return
+			}
+
+			writer := newCacheWriter(c.Writer)
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+
+			response := writer.response()
+			response.Header.Del("Set-Cookie")
+			if response.Status >= 500 {
+				err = config.Store.Delete(ctx, key)
+			} else {
+				response.Stored = time.Now()
+				var data []byte
+				if data, err = json.Marshal(response); err == nil {
+					err = config.Store.Set(ctx, key, data, config.Window)
+				}
+			}
+			if err != nil {
+				c.Logger().Error("dedup: store failed", "key", key, "error", err)
+			}
+		}
+	}
+}
+
+// DedupClient identifies the client of a request by its IP and
+// credentials, the Authorization and Cookie headers, so that the users
+// behind a shared IP are told apart
+func DedupClient(c *types.Context) string {
+	return ClientIP(c) + "\x00" + c.Request.Header.Get("Authorization") + "\x00" + strings.Join(c.Request.Header.Values("Cookie"), "; ")
+}
+
+// dedupHash returns the hash identifying a request of the client
+func dedupHash(c *types.Context, client string, body []byte) string {
+	h := sha256.New()
+	for _, s := range []string{client, c.Request.Method, c.Request.URL.RequestURI(), c.Request.Header.Get("Content-Type")} {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// serveDuplicate answers a duplicate with the response of the first
+// request, or 409 Conflict while it is in progress
+func serveDuplicate(c *types.Context, s store.Store, key string) {
+	c.Abort()
+	data, err := s.Get(c.Request.Context(), key)
+	var response cachedResponse
+	if err != nil || len(data) == 0 || json.Unmarshal(data, &response) != nil {
+		c.ErrorString(http.StatusConflict, "duplicate request in progress")
+		return
+	}
+
+	header := c.Writer.Header()
+	for name, values := range response.Header {
+		if name != "Set-Cookie" {
+			header[name] = append(header[name], values...)
+		}
+	}
+	header.Set("X-Deduplicated", "true")
+	c.Writer.WriteHeader(response.Status)
+	c.Writer.Write(response.Body)
+}
+
+// readCloser reads from a reader and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
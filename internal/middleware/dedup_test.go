@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup(t *testing.T) {
+	dedup := Dedup(DedupConfig{})
+	calls := 0
+	handler := func(c *types.Context) {
+		calls++
+		var form struct {
+			Amount int `json:"amount"`
+		}
+		require.NoError(t, c.BindJSON(&form))
+		c.Header("X-Order", "1")
+		c.JSON(http.StatusCreated, map[string]int{"amount": form.Amount})
+	}
+	send := func(remoteAddr, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: r, Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{dedup}, handler))
+		return w
+	}
+
+	w := send("10.0.0.1:1234", `{"amount":5}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Empty(t, w.Header().Get("X-Deduplicated"))
+
+	w = send("10.0.0.1:1234", `{"amount":5}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "true", w.Header().Get("X-Deduplicated"))
+	require.Equal(t, "1", w.Header().Get("X-Order"))
+	require.JSONEq(t, `{"amount":5}`, w.Body.String())
+	require.Equal(t, 1, calls)
+
+	// Other bodies and clients are not duplicates
+	send("10.0.0.1:1234", `{"amount":6}`)
+	send("10.0.0.2:1234", `{"amount":5}`)
+	require.Equal(t, 3, calls)
+}
+
+func TestDedup_Credentials(t *testing.T) {
+	dedup := Dedup(DedupConfig{})
+	calls := 0
+	send := func(header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("a"))
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: r, Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{dedup}, func(c *types.Context) {
+			calls++
+			c.SetCookie("session", "new", 0, "/", "", true, true)
+			c.String(http.StatusOK, "ok")
+		}))
+		return w
+	}
+
+	// The users behind an IP are told apart by their credentials
+	send("Authorization", "Bearer alice")
+	send("Authorization", "Bearer bob")
+	send("Cookie", "session=carol")
+	require.Equal(t, 3, calls)
+
+	// and duplicates are not given the cookies of the first response
+	w := send("Authorization", "Bearer alice")
+	require.Equal(t, "true", w.Header().Get("X-Deduplicated"))
+	require.Empty(t, w.Header().Values("Set-Cookie"))
+	require.Equal(t, 3, calls)
+}
+
+func TestDedup_InProgress(t *testing.T) {
+	dedup := Dedup(DedupConfig{})
+	var inner *httptest.ResponseRecorder
+	handler := func(c *types.Context) {
+		if inner == nil {
+			// A duplicate sent while the first request is handled
+			inner, _, _ = serve(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a")), dedup)
+		}
+		c.String(http.StatusOK, "ok")
+	}
+
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a")), Writer: w}
+	c.Execute(types.Chain([]types.MiddlewareFunc{dedup}, handler))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusConflict, inner.Code)
+}
+
+func TestDedup_ServerErrors(t *testing.T) {
+	dedup := Dedup(DedupConfig{MaxBodySize: 4})
+	calls := 0
+	send := func(body string) {
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{dedup}, func(c *types.Context) {
+			calls++
+			c.String(http.StatusBadGateway, "upstream down")
+		}))
+	}
+
+	// Server errors can be retried
+	send("a")
+	send("a")
+	require.Equal(t, 2, calls)
+
+	// Larger bodies are not deduplicated, and passed on in full
+	_, c, reached := serve(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("abcdef")), dedup)
+	require.True(t, reached)
+	body, err := io.ReadAll(c.Request.Body)
+	require.NoError(t, err)
+	require.Equal(t, "abcdef", string(body))
+}
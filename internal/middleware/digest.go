@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// ErrDigestMismatch is answered to requests whose body does not match its
+// Content-Digest
+var ErrDigestMismatch = errors.New("content digest mismatch")
+
+// DefaultDigestMaxBodySize is the default size of the request bodies whose
+// digest is verified
+const DefaultDigestMaxBodySize = 10 << 20
+
+// DigestConfig configures the integrity digests, see RFC 9530
+type DigestConfig struct {
+	// SkipResponses disables the digests of the responses
+	SkipResponses bool
+
+	// Legacy also sends the digests in the obsolete Digest header of RFC
+	// 3230, for older clients
+	Legacy bool
+
+	// SkipRequests disables the validation of the request digests
+	SkipRequests bool
+
+	// Required rejects the requests with a body but without a supported
+	// Content-Digest
+	Required bool
+
+	// MaxBodySize of the requests whose digest is verified, larger ones
+	// being rejected, DefaultDigestMaxBodySize if 0
+	MaxBodySize int64
+}
+
+// digestAlgorithms are the supported algorithms by Content-Digest key
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// Digest returns a middleware adding a SHA-256 Content-Digest to the
+// responses and validating the Content-Digest of the requests
+//
+// Responses are buffered to compute their digest, unless they are flushed,
+// e.g. streamed events, which are then sent without digest. Request bodies
+// with a digest are read up to MaxBodySize and verified before the handler
+// runs, so that it never sees a body that does not match: mismatches and
+// unparsable digests are answered with 400 Bad Request, and larger bodies
+// with 413 Request Entity Too Large.
+func Digest(config DigestConfig) types.MiddlewareFunc {
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = DefaultDigestMaxBodySize
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if !config.SkipRequests && c.Request.Body != nil && c.Request.Body != http.NoBody {
+				header := c.Request.Header.Get("Content-Digest")
+				algorithm, expected, err := parseContentDigest(header)
+				switch {
+				case err != nil:
+					c.Abort()
+					c.ErrorString(http.StatusBadRequest, "invalid Content-Digest")
+					return
+				case algorithm == "" && config.Required:
+					c.Abort()
+					c.ErrorString(http.StatusBadRequest, "missing Content-Digest")
+					return
+				case algorithm != "":
+					if !verifyDigest(c, config.MaxBodySize, digestAlgorithms[algorithm](), expected) {
+						return
+					}
+				}
+			}
+
+			if config.SkipResponses {
+				next(c)
+				return
+			}
+
+			writer := types.NewBufferedWriter(c.Writer)
+			c.Writer = writer
+			next(c)
+			c.Writer = writer.ResponseWriter
+			sendDigest(writer, config.Legacy)
+		}
+	}
+}
+
+// verifyDigest reads the body of a request up to the limit and passes it
+// on if it matches its digest, answering the request otherwise
+//
+// @return: whether the body matches
+func verifyDigest(c *types.Context, limit int64, h hash.Hash, expected []byte) bool {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge) || int64(len(body)) > limit:
+		c.Abort()
+		c.ErrorString(http.StatusRequestEntityTooLarge, "body too large")
+		return false
+	case err != nil:
+		c.Abort()
+		c.ErrorString(http.StatusBadRequest, "unreadable body")
+		return false
+	}
+
+	h.Write(body)
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		c.Abort()
+		c.ErrorString(http.StatusBadRequest, ErrDigestMismatch.Error())
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return true
+}
+
+// parseContentDigest returns the strongest supported digest of a
+// Content-Digest header, e.g. sha-256=:base64:
+//
+// @return: the algorithm and the digest, empty if none is supported
+// @return: an error if the header is malformed
+func parseContentDigest(header string) (string, []byte, error) {
+	if header == "" {
+		return "", nil, nil
+	}
+
+	var algorithm string
+	var digest []byte
+	for member := range strings.SplitSeq(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return "", nil, errors.New("malformed Content-Digest")
+		}
+		key = strings.ToLower(key)
+		if _, ok := digestAlgorithms[key]; !ok || algorithm == "sha-512" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			return "", nil, err
+		}
+		algorithm, digest = key, decoded
+	}
+	return algorithm, digest, nil
+}
+
+// sendDigest sends the buffered response with its digest, unless it was
+// streamed
+func sendDigest(w *types.BufferedWriter, legacy bool) {
+	if w.Sent() {
+		return
+	}
+	if body := w.Body(); body.Len() > 0 {
+		sum := sha256.Sum256(body.Bytes())
+		encoded := base64.StdEncoding.EncodeToString(sum[:])
+		header := w.Header()
+		header.Set("Content-Digest", "sha-256=:"+encoded+":")
+		if legacy {
+			header.Set("Digest", "SHA-256="+encoded)
+		}
+		header.Set("Content-Length", strconv.Itoa(body.Len()))
+	}
+	w.Send()
+}
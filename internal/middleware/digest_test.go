@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// contentDigest returns the sha-256 Content-Digest of data
+func contentDigest(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+func TestDigest_Responses(t *testing.T) {
+	run := func(config DigestConfig, method string, handler types.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: httptest.NewRequest(method, "/", nil), Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{Digest(config)}, handler))
+		return w
+	}
+
+	w := run(DigestConfig{Legacy: true}, http.MethodGet, func(c *types.Context) {
+		c.String(http.StatusCreated, "artifact")
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "artifact", w.Body.String())
+	require.Equal(t, contentDigest("artifact"), w.Header().Get("Content-Digest"))
+	require.Equal(t, "SHA-256="+strings.Trim(strings.TrimPrefix(contentDigest("artifact"), "sha-256="), ":"), w.Header().Get("Digest"))
+	require.Equal(t, "8", w.Header().Get("Content-Length"))
+
+	w = run(DigestConfig{}, http.MethodGet, func(c *types.Context) { c.Status(http.StatusNoContent) })
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Header().Get("Content-Digest"))
+
+	// Streamed responses are sent as they are written
+	w = run(DigestConfig{}, http.MethodGet, func(c *types.Context) {
+		c.SSEvent("tick", 1)
+		c.SSEvent("tick", 2)
+	})
+	require.Equal(t, "event: tick\ndata: 1\n\nevent: tick\ndata: 2\n\n", w.Body.String())
+	require.Empty(t, w.Header().Get("Content-Digest"))
+
+	w = run(DigestConfig{SkipResponses: true}, http.MethodGet, func(c *types.Context) { c.String(http.StatusOK, "x") })
+	require.Empty(t, w.Header().Get("Content-Digest"))
+}
+
+func TestDigest_Requests(t *testing.T) {
+	run := func(config DigestConfig, body, digest string) (*httptest.ResponseRecorder, error) {
+		var readErr error
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPut, "/artifacts/1", strings.NewReader(body))
+		if digest != "" {
+			r.Header.Set("Content-Digest", digest)
+		}
+		c := &types.Context{Request: r, Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{Digest(config)}, func(c *types.Context) {
+			data, err := io.ReadAll(c.Request.Body)
+			if readErr = err; err != nil {
+				c.Header("X-Handler", "failed")
+				return
+			}
+			c.String(http.StatusOK, "stored "+string(data))
+		}))
+		return w, readErr
+	}
+
+	w, err := run(DigestConfig{}, "payload", contentDigest("payload"))
+	require.NoError(t, err)
+	require.Equal(t, "stored payload", w.Body.String())
+
+	// The strongest supported digest is verified
+	w, err = run(DigestConfig{}, "payload", "md5=:AAAA:, "+contentDigest("payload"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Bodies are verified before the handler reads them
+	w, err = run(DigestConfig{}, "tampered", contentDigest("payload"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "content digest mismatch")
+	require.NotContains(t, w.Body.String(), "stored")
+
+	w, _ = run(DigestConfig{MaxBodySize: 4}, "payload", contentDigest("payload"))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	w, _ = run(DigestConfig{MaxBodySize: 7}, "payload", contentDigest("payload"))
+	require.Equal(t, "stored payload", w.Body.String())
+
+	w, _ = run(DigestConfig{SkipResponses: true}, "tampered", contentDigest("payload"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = run(DigestConfig{}, "payload", "sha-256=payload")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = run(DigestConfig{}, "payload", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w, _ = run(DigestConfig{Required: true}, "payload", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = run(DigestConfig{SkipRequests: true, Required: true}, "tampered", contentDigest("payload"))
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// ExpectContinueConfig configures the ExpectContinue middleware
+type ExpectContinueConfig struct {
+	// MaxBodySize rejects the requests declaring a larger Content-Length
+	// with 413 Content Too Large, 0 for no limit
+	MaxBodySize int64
+
+	// Check returns the status rejecting a request before its body is
+	// sent, e.g. 401 for clients failing authentication or 429 for clients
+	// over their quota, 0 to accept it
+	Check func(c *types.Context) int
+
+	// Eager sends 100 Continue once the request is accepted, rather than
+	// when the handler first reads the body
+	Eager bool
+}
+
+// ExpectContinue returns a middleware accepting or rejecting the bodies of
+// the requests before they are sent, see Context.Reject100
+//
+// It only handles requests expecting 100 Continue, the other clients
+// sending their body with the request. It must run before anything reads
+// the body, e.g. Dedup.
+func ExpectContinue(config ExpectContinueConfig) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if !c.ExpectsContinue() {
+				next(c)
+				return
+			}
+
+			if config.MaxBodySize > 0 && c.Request.ContentLength > config.MaxBodySize {
+				c.Reject100(http.StatusRequestEntityTooLarge)
+				return
+			}
+			if config.Check != nil {
+				if status := config.Check(c); status != 0 {
+					c.Reject100(status)
+					return
+				}
+			}
+			if config.Eager {
+				c.Continue()
+			}
+			next(c)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader counts the bytes read from it
+type countingReader struct {
+	io.Reader
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+func TestExpectContinue(t *testing.T) {
+	expect := ExpectContinue(ExpectContinueConfig{
+		MaxBodySize: 1 << 10,
+		Check: func(c *types.Context) int {
+			if c.GetHeader("Authorization") == "" {
+				return http.StatusUnauthorized
+			}
+			return 0
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &types.Context{Request: r, Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{expect}, func(c *types.Context) {
+			body, _ := io.ReadAll(c.Request.Body)
+			c.String(http.StatusOK, string(body))
+		}))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+
+	upload := func(size int, authorization string) (*http.Response, *countingReader) {
+		body := &countingReader{Reader: strings.NewReader(strings.Repeat("a", size))}
+		r, err := http.NewRequest(http.MethodPost, server.URL, body)
+		require.NoError(t, err)
+		r.ContentLength = int64(size)
+		r.Header.Set("Expect", "100-continue")
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		resp, err := client.Do(r)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp, body
+	}
+
+	// Rejected bodies are never sent
+	resp, body := upload(100, "")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Zero(t, body.n.Load())
+
+	resp, body = upload(2<<10, "Bearer token")
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	require.Zero(t, body.n.Load())
+
+	resp, body = upload(100, "Bearer token")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(100), body.n.Load())
+}
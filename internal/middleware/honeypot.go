@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// HoneypotConfig configures the Honeypot handler
+type HoneypotConfig struct {
+	// Delay before responding, tying up automated scanners, 0 disables
+	Tarpit time.Duration
+
+	// Deny list the client is added to, may be nil
+	DenyList *DenyList
+
+	// Duration of the denial, DefaultDenyTTL if <= 0
+	DenyTTL time.Duration
+
+	// Logger recording the hits, defaults to Context.Logger
+	Logger types.Logger
+
+	// Called for every hit, e.g. to flag the client elsewhere
+	OnHit func(c *types.Context)
+
+	// Extracts the client IP, defaults to the connection's remote address
+	ClientIP func(c *types.Context) string
+}
+
+// Honeypot returns a handler for decoy routes such as /wp-admin
+//
+// Hits are logged and reported, the client is added to the deny list and
+// the response, an ordinary 404, is optionally delayed.
+func Honeypot(config HoneypotConfig) types.HandlerFunc {
+	clientIP := config.ClientIP
+	if clientIP == nil {
+		clientIP = RemoteIP
+	}
+
+	return func(c *types.Context) {
+		ip := clientIP(c)
+		logger, attrs := requestLogger(c, config.Logger)
+		logger.Warn("honeypot hit", append(attrs, "client_ip", ip)...)
+
+		if config.OnHit != nil {
+			config.OnHit(c)
+		}
+		if config.DenyList != nil {
+			config.DenyList.Add(ip, config.DenyTTL)
+		}
+
+		if config.Tarpit > 0 {
+			timer := time.NewTimer(config.Tarpit)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		c.ErrorString(http.StatusNotFound, "Not Found")
+	}
+}
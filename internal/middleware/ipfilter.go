@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultDenyTTL is how long an IP is denied when no duration is given
+const DefaultDenyTTL = 24 * time.Hour
+
+// denySweepInterval is how often the expired entries of a DenyList are
+// dropped
+const denySweepInterval = time.Minute
+
+// DenyList is a set of client IPs denied until an expiry, safe for
+// concurrent use
+//
+// Expired entries are dropped at most every minute as IPs are added, so
+// that the list does not grow with every client ever denied.
+type DenyList struct {
+	now func() time.Time
+
+	mu        sync.RWMutex
+	entries   map[netip.Addr]time.Time
+	lastSweep time.Time
+}
+
+// NewDenyList creates an empty deny list
+func NewDenyList() *DenyList {
+	return newDenyList(time.Now)
+}
+
+// newDenyList creates an empty deny list with the clock
+func newDenyList(now func() time.Time) *DenyList {
+	return &DenyList{now: now, entries: make(map[netip.Addr]time.Time), lastSweep: now()}
+}
+
+// Add denies the IP for the duration, DefaultDenyTTL if ttl <= 0
+func (d *DenyList) Add(ip string, ttl time.Duration) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	if ttl <= 0 {
+		ttl = DefaultDenyTTL
+	}
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.lastSweep) >= denySweepInterval {
+		d.lastSweep = now
+		for addr, expiry := range d.entries {
+			if !now.Before(expiry) {
+				delete(d.entries, addr)
+			}
+		}
+	}
+	d.entries[addr.Unmap()] = now.Add(ttl)
+}
+
+// Remove lifts the denial of the IP
+func (d *DenyList) Remove(ip string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	delete(d.entries, addr.Unmap())
+	d.mu.Unlock()
+}
+
+// Contains reports whether the IP is currently denied
+func (d *DenyList) Contains(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return d.contains(addr.Unmap())
+}
+
+// contains reports whether the address is denied, expiring stale entries
+func (d *DenyList) contains(addr netip.Addr) bool {
+	d.mu.RLock()
+	expiry, ok := d.entries[addr]
+	d.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if d.now().Before(expiry) {
+		return true
+	}
+
+	d.mu.Lock()
+	if current, ok := d.entries[addr]; ok && current.Equal(expiry) {
+		delete(d.entries, addr)
+	}
+	d.mu.Unlock()
+	return false
+}
+
+// IPFilterConfig configures the IPFilter middleware
+type IPFilterConfig struct {
+	// IPs or CIDR prefixes allowed, when set all other clients are denied
+	Allow []string
+
+	// IPs or CIDR prefixes denied
+	Deny []string
+
+	// Dynamic deny list, e.g. fed by honeypot routes, may be nil
+	DenyList *DenyList
+
+	// Extracts the client IP, defaults to the connection's remote address
+	ClientIP func(c *types.Context) string
+}
+
+// IPFilter returns a middleware rejecting denied clients with 403
+//
+// It panics if an entry of Allow or Deny is neither an IP nor a CIDR
+// prefix, since that is a programming error.
+func IPFilter(config IPFilterConfig) types.MiddlewareFunc {
+	allow := mustParsePrefixes(config.Allow)
+	deny := mustParsePrefixes(config.Deny)
+	clientIP := config.ClientIP
+	if clientIP == nil {
+		clientIP = RemoteIP
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			addr, err := netip.ParseAddr(clientIP(c))
+			if err != nil || !permitted(addr.Unmap(), allow, deny, config.DenyList) {
+				c.Abort()
+				c.ErrorString(http.StatusForbidden, "Forbidden")
+				return
+			}
+			next(c)
+		}
+	}
+}
+
+// permitted reports whether the address passes the filter
+func permitted(addr netip.Addr, allow, deny []netip.Prefix, denyList *DenyList) bool {
+	if len(allow) > 0 && !containsAddr(allow, addr) {
+		return false
+	}
+	if containsAddr(deny, addr) {
+		return false
+	}
+	return denyList == nil || !denyList.contains(addr)
+}
+
+// containsAddr reports whether any prefix contains the address
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustParsePrefixes parses IPs and CIDR prefixes, panicking on error
+func mustParsePrefixes(values []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			panic("middleware: invalid IP or CIDR prefix: " + value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// RemoteIP returns the IP of the connection's remote address, ignoring any
+// forwarding headers
+func RemoteIP(c *types.Context) string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return c.Request.RemoteAddr
+	}
+	return host
+}
+
+// ClientIP returns the IP of the client, read from the forwarding headers
+// of the engine's trusted proxies, see Context.GetClientIP
+func ClientIP(c *types.Context) string {
+	return c.GetClientIP()
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func requestFrom(remoteAddr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestIPFilter(t *testing.T) {
+	filter := IPFilter(IPFilterConfig{
+		Allow: []string{"10.0.0.0/8", "192.168.1.10"},
+		Deny:  []string{"10.0.1.0/24"},
+	})
+
+	tests := []struct {
+		remoteAddr string
+		allowed    bool
+	}{
+		{"10.0.0.1:1234", true},
+		{"192.168.1.10:1234", true},
+		{"[::ffff:10.0.0.1]:1234", true},
+		{"10.0.1.5:1234", false},
+		{"192.168.1.11:1234", false},
+		{"invalid", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			w, _, reached := serve(requestFrom(tt.remoteAddr), filter)
+			require.Equal(t, tt.allowed, reached)
+			if !tt.allowed {
+				require.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+func TestIPFilter_IgnoresForwardedFor(t *testing.T) {
+	filter := IPFilter(IPFilterConfig{Deny: []string{"10.0.0.1"}})
+
+	r := requestFrom("10.0.0.1:1234")
+	r.Header.Set("X-Forwarded-For", "8.8.8.8")
+	_, _, reached := serve(r, filter)
+	require.False(t, reached)
+}
+
+func TestIPFilter_InvalidPrefix(t *testing.T) {
+	require.Panics(t, func() {
+		IPFilter(IPFilterConfig{Deny: []string{"not-an-ip"}})
+	})
+}
+
+func TestDenyList(t *testing.T) {
+	now := time.Unix(0, 0)
+	denyList := newDenyList(func() time.Time { return now })
+	denyList.Add("10.0.0.1", 0)
+	denyList.Add("10.0.0.2", time.Second)
+	denyList.Add("bogus", 0)
+
+	require.True(t, denyList.Contains("10.0.0.1"))
+	require.True(t, denyList.Contains("::ffff:10.0.0.1"))
+	require.True(t, denyList.Contains("10.0.0.2"))
+	require.False(t, denyList.Contains("10.0.0.3"))
+
+	now = now.Add(2 * time.Second)
+	require.False(t, denyList.Contains("10.0.0.2"))
+
+	// Entries without duration expire as well, and expired entries are
+	// swept as IPs are added
+	denyList.Add("10.0.0.3", time.Second)
+	now = now.Add(DefaultDenyTTL)
+	require.False(t, denyList.Contains("10.0.0.1"))
+	denyList.Add("10.0.0.4", time.Hour)
+	require.Len(t, denyList.entries, 1)
+
+	denyList.Remove("10.0.0.4")
+	require.False(t, denyList.Contains("10.0.0.4"))
+}
+
+func TestHoneypot(t *testing.T) {
+	var logs bytes.Buffer
+	var flagged []string
+	denyList := NewDenyList()
+
+	honeypot := Honeypot(HoneypotConfig{
+		DenyList: denyList,
+		DenyTTL:  time.Minute,
+		Tarpit:   10 * time.Millisecond,
+		Logger:   slog.New(slog.NewTextHandler(&logs, nil)),
+		OnHit:    func(c *types.Context) { flagged = append(flagged, c.Request.URL.Path) },
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/wp-admin", nil)
+	r.RemoteAddr = "10.0.0.7:4321"
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	honeypot(&types.Context{Request: r, Writer: w})
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, []string{"/wp-admin"}, flagged)
+	require.Contains(t, logs.String(), `msg="honeypot hit" method=GET path=/wp-admin client_ip=10.0.0.7`)
+	require.True(t, denyList.Contains("10.0.0.7"))
+
+	// The client is now rejected by a filter sharing the deny list
+	_, _, reached := serve(requestFrom("10.0.0.7:5555"), IPFilter(IPFilterConfig{DenyList: denyList}))
+	require.False(t, reached)
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// LimitsConfig configures the request size limits, zero disables a limit
+type LimitsConfig struct {
+	// Maximum number of header fields, counting repeated fields
+	MaxHeaderCount int
+
+	// Maximum size of a single header field, name and value
+	MaxHeaderSize int
+
+	// Maximum length of the request target
+	MaxURLLength int
+
+	// Maximum number of query parameters
+	MaxQueryParams int
+
+	// Maximum size of the body, larger requests being rejected with 413
+	// Content Too Large
+	MaxBodySize int64
+
+	// Size of the body over which requests are let through with a
+	// LimitWarningHeader and counted in Warnings, e.g. to observe the
+	// sizes before setting MaxBodySize
+	WarnBodySize int64
+
+	// Counter of the warnings, DefaultLimitWarnings if nil
+	Warnings *LimitWarnings
+
+	// When set, only these headers are passed on to handlers
+	AllowedHeaders []string
+}
+
+// Enabled reports whether any limit is configured
+func (c LimitsConfig) Enabled() bool {
+	return c.MaxHeaderCount > 0 || c.MaxHeaderSize > 0 || c.MaxURLLength > 0 ||
+		c.MaxQueryParams > 0 || c.MaxBodySize > 0 || c.WarnBodySize > 0 || len(c.AllowedHeaders) > 0
+}
+
+// Limits returns a middleware enforcing the configured request limits
+//
+// Requests exceeding the header limits are rejected with 431 Request Header
+// Fields Too Large, requests exceeding the URL or query limits with 414
+// URI Too Long, and requests declaring a body over MaxBodySize with 413
+// Content Too Large, the bodies of unknown size failing to read past it.
+// Headers missing from AllowedHeaders are dropped before the request
+// reaches the next handler.
+func Limits(config LimitsConfig) types.MiddlewareFunc {
+	return NewLimiter(config).Middleware()
+}
+
+// Limiter enforces request limits that can be replaced while serving, e.g.
+// when the configuration is reloaded
+type Limiter struct {
+	limits atomic.Pointer[limits]
+}
+
+// limits is a limits configuration prepared for the requests
+type limits struct {
+	config  LimitsConfig
+	enabled bool
+	allowed map[string]struct{}
+}
+
+// NewLimiter creates a limiter enforcing the configured request limits
+func NewLimiter(config LimitsConfig) *Limiter {
+	l := &Limiter{}
+	l.Update(config)
+	return l
+}
+
+// Update replaces the limits, for the requests starting afterwards
+func (l *Limiter) Update(config LimitsConfig) {
+	allowed := make(map[string]struct{}, len(config.AllowedHeaders))
+	for _, name := range config.AllowedHeaders {
+		allowed[textproto.CanonicalMIMEHeaderKey(name)] = struct{}{}
+	}
+	l.limits.Store(&limits{config: config, enabled: config.Enabled(), allowed: allowed})
+}
+
+// Config returns the current limits
+func (l *Limiter) Config() LimitsConfig {
+	return l.limits.Load().config
+}
+
+// Middleware returns a middleware enforcing the current limits, see Limits
+func (l *Limiter) Middleware() types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			limits := l.limits.Load()
+			if !limits.enabled {
+				next(c)
+				return
+			}
+
+			if status, message := checkLimits(&limits.config, c.Request); status != 0 {
+				c.Abort()
+				c.ErrorString(status, message)
+				return
+			}
+
+			if config := &limits.config; config.MaxBodySize > 0 || config.WarnBodySize > 0 {
+				checkBodySize(c, config)
+			}
+
+			if len(limits.allowed) > 0 {
+				for name := range c.Request.Header {
+					if _, ok := limits.allowed[name]; !ok {
+						c.Request.Header.Del(name)
+					}
+				}
+			}
+			next(c)
+		}
+	}
+}
+
+// checkLimits validates a request against the limits
+//
+// @return: the status code and message to reject the request with, or 0 if
+// the request is within limits
+func checkLimits(config *LimitsConfig, r *http.Request) (int, string) {
+	if config.MaxURLLength > 0 && len(r.RequestURI) > config.MaxURLLength {
+		return http.StatusRequestURITooLong, "URI too long"
+	}
+
+	if config.MaxQueryParams > 0 && countQueryParams(r.URL.RawQuery) > config.MaxQueryParams {
+		return http.StatusRequestURITooLong, "too many query parameters"
+	}
+
+	count := 0
+	for name, values := range r.Header {
+		count += len(values)
+		if config.MaxHeaderSize <= 0 {
+			continue
+		}
+		for _, value := range values {
+			if len(name)+len(value) > config.MaxHeaderSize {
+				return http.StatusRequestHeaderFieldsTooLarge, "header " + name + " too large"
+			}
+		}
+	}
+	if config.MaxHeaderCount > 0 && count > config.MaxHeaderCount {
+		return http.StatusRequestHeaderFieldsTooLarge, "too many headers"
+	}
+
+	if config.MaxBodySize > 0 && r.ContentLength > config.MaxBodySize {
+		return http.StatusRequestEntityTooLarge, "body too large"
+	}
+
+	return 0, ""
+}
+
+// checkBodySize limits the body of the request to MaxBodySize, and warns
+// about bodies over WarnBodySize, when they are read for the bodies of
+// unknown size
+func checkBodySize(c *types.Context, config *LimitsConfig) {
+	r := c.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	if config.MaxBodySize > 0 {
+		r.Body = http.MaxBytesReader(c.Writer, r.Body, config.MaxBodySize)
+	}
+	if config.WarnBodySize <= 0 {
+		return
+	}
+	value := LimitBodySize + "; limit=" + strconv.FormatInt(config.WarnBodySize, 10)
+	warnings := limitWarnings(config.Warnings)
+	if r.ContentLength > config.WarnBodySize {
+		warnings.warn(c, LimitBodySize, value)
+	} else if r.ContentLength < 0 {
+		r.Body = &warnBodyReader{ReadCloser: r.Body, left: config.WarnBodySize, warn: func() {
+			warnings.warn(c, LimitBodySize, value)
+		}}
+	}
+}
+
+// warnBodyReader warns once more than its size was read
+type warnBodyReader struct {
+	io.ReadCloser
+	left int64
+	warn func()
+}
+
+// Read implements io.Reader
+func (r *warnBodyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.left >= 0 {
+		if r.left -= int64(n); r.left < 0 {
+			r.warn()
+		}
+	}
+	return n, err
+}
+
+// countQueryParams counts the parameters of a raw query without parsing it
+func countQueryParams(query string) int {
+	count := 0
+	for part := range strings.SplitSeq(query, "&") {
+		if part != "" {
+			count++
+		}
+	}
+	return count
+}
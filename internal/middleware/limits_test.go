@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// serve runs a request through the middlewares and a handler recording
+// whether it was reached
+func serve(r *http.Request, middlewares ...types.MiddlewareFunc) (*httptest.ResponseRecorder, *types.Context, bool) {
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: r, Writer: w}
+
+	reached := false
+	c.Execute(types.Chain(middlewares, func(c *types.Context) {
+		reached = true
+		c.String(http.StatusOK, "ok")
+	}))
+	return w, c, reached
+}
+
+func TestLimits(t *testing.T) {
+	config := LimitsConfig{
+		MaxHeaderCount: 3,
+		MaxHeaderSize:  32,
+		MaxURLLength:   64,
+		MaxQueryParams: 2,
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		status  int
+	}{
+		{"within limits", "/path?a=1&b=2", map[string]string{"X-A": "1"}, http.StatusOK},
+		{"url too long", "/" + strings.Repeat("a", 64), nil, http.StatusRequestURITooLong},
+		{"too many query params", "/path?a=1&b=2&c=3", nil, http.StatusRequestURITooLong},
+		{"header too large", "/", map[string]string{"X-A": strings.Repeat("a", 30)}, http.StatusRequestHeaderFieldsTooLarge},
+		{"too many headers", "/", map[string]string{"X-A": "1", "X-B": "2", "X-C": "3", "X-D": "4"}, http.StatusRequestHeaderFieldsTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			w, c, reached := serve(r, Limits(config))
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.status == http.StatusOK, reached)
+			require.Equal(t, tt.status != http.StatusOK, c.IsAborted())
+		})
+	}
+}
+
+func TestLimits_AllowedHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("X-Debug", "1")
+
+	_, c, reached := serve(r, Limits(LimitsConfig{AllowedHeaders: []string{"authorization"}}))
+	require.True(t, reached)
+	require.Equal(t, "Bearer token", c.GetHeader("Authorization"))
+	require.Empty(t, c.GetHeader("X-Debug"))
+}
+
+func TestLimits_BodySize(t *testing.T) {
+	warnings := &LimitWarnings{}
+	limits := Limits(LimitsConfig{MaxBodySize: 16, WarnBodySize: 8, Warnings: warnings})
+	read := func(r *http.Request) (*httptest.ResponseRecorder, bool) {
+		w := httptest.NewRecorder()
+		reached := false
+		limits(func(c *types.Context) {
+			reached = true
+			if _, err := io.ReadAll(c.Request.Body); err != nil {
+				c.ErrorString(http.StatusRequestEntityTooLarge, err.Error())
+				return
+			}
+			c.String(http.StatusOK, "ok")
+		})(&types.Context{Request: r, Writer: w})
+		return w, reached
+	}
+
+	w, reached := read(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small")))
+	require.True(t, reached)
+	require.Empty(t, w.Header().Get(LimitWarningHeader))
+
+	// Bodies over the soft limit are let through with a warning
+	w, reached = read(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("medium body")))
+	require.True(t, reached)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "body-size; limit=8", w.Header().Get(LimitWarningHeader))
+
+	// and bodies over the hard limit rejected, before or while reading them
+	w, reached = read(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a body that is too large")))
+	require.False(t, reached)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	r := httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("a body that is too large")))
+	r.ContentLength = -1
+	w, reached = read(r)
+	require.True(t, reached)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Equal(t, "body-size; limit=8", w.Header().Get(LimitWarningHeader))
+
+	require.Equal(t, map[string]uint64{LimitBodySize: 2}, warnings.Counts())
+}
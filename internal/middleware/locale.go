@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/skjdfhkskjds/go-api/internal/i18n"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// LocaleConfig configures the locale negotiation
+type LocaleConfig struct {
+	// Supported language tags, the first one is the default. When empty,
+	// i18n.DefaultLocale is always used.
+	Supported []string
+
+	// Query parameter overriding the Accept-Language header, e.g. ?lang=de
+	QueryParam string
+}
+
+// Locale returns a middleware negotiating the request locale from the
+// Accept-Language header, see Context.Locale
+//
+// The negotiated tag is sent in the Content-Language header.
+func Locale(config LocaleConfig) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			acceptLanguage := c.Request.Header.Get("Accept-Language")
+			if config.QueryParam != "" {
+				if lang := c.GetQuery(config.QueryParam); lang != "" {
+					acceptLanguage = lang
+				}
+			}
+
+			locale := i18n.Lookup(i18n.Negotiate(acceptLanguage, config.Supported))
+			c.Request = c.Request.WithContext(i18n.WithLocale(c.Request.Context(), locale))
+
+			header := c.Writer.Header()
+			header.Set("Content-Language", locale.Tag)
+			header.Add("Vary", "Accept-Language")
+			next(c)
+		}
+	}
+}
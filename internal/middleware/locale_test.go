@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocale(t *testing.T) {
+	config := LocaleConfig{Supported: []string{"en", "de"}, QueryParam: "lang"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "de-CH, en;q=0.5")
+	w, c, called := serve(r, Locale(config))
+	require.True(t, called)
+	require.Equal(t, "de", c.Locale().Tag)
+	require.Equal(t, "de", w.Header().Get("Content-Language"))
+	require.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+
+	// Templates rendered through the context use the negotiated locale
+	tmpl := template.Must(template.New("total").Funcs(i18n.FuncMap()).Parse(`{{ number .Total 2 }}`))
+	w = httptest.NewRecorder()
+	c.Writer = w
+	require.NoError(t, c.Render(http.StatusOK, tmpl, "total", map[string]float64{"Total": 1234.5}))
+	require.Equal(t, "1.234,50", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/?lang=en", nil)
+	r.Header.Set("Accept-Language", "de")
+	_, c, _ = serve(r, Locale(config))
+	require.Equal(t, "en", c.Locale().Tag)
+}
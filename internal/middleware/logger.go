@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// LogEntry describes a completed request
+type LogEntry struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Status   int           `json:"status"`
+	Latency  time.Duration `json:"latency"`
+	Bytes    int64         `json:"bytes"`
+	ClientIP string        `json:"client_ip"`
+
+	// RequestID set by the RequestID middleware, if any
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// LogFormatter writes a log entry as a single line
+type LogFormatter func(buf *bytes.Buffer, entry LogEntry)
+
+// TextLogFormatter formats entries as space separated fields:
+//
+//	2006-01-02T15:04:05Z GET /users/1 200 1.2ms 42B 10.0.0.1
+func TextLogFormatter(buf *bytes.Buffer, entry LogEntry) {
+	fmt.Fprintf(buf, "%s %s %s %d %s %dB %s\n",
+		entry.Time.UTC().Format(time.RFC3339),
+		entry.Method,
+		entry.Path,
+		entry.Status,
+		entry.Latency,
+		entry.Bytes,
+		entry.ClientIP,
+	)
+}
+
+// JSONLogFormatter formats entries as JSON objects, with the latency in
+// nanoseconds
+func JSONLogFormatter(buf *bytes.Buffer, entry LogEntry) {
+	json.NewEncoder(buf).Encode(entry)
+}
+
+// LoggerConfig configures the Logger middleware
+type LoggerConfig struct {
+	// Destination of the log lines, defaults to os.Stderr
+	Output io.Writer
+
+	// Line format, defaults to TextLogFormatter
+	Formatter LogFormatter
+
+	// Paths that are not logged, e.g. health checks
+	SkipPaths []string
+}
+
+// Logger returns a middleware logging the method, path, status, latency,
+// response size and client IP of every request
+func Logger(config LoggerConfig) types.MiddlewareFunc {
+	output := config.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	formatter := config.Formatter
+	if formatter == nil {
+		formatter = TextLogFormatter
+	}
+	skip := make(map[string]struct{}, len(config.SkipPaths))
+	for _, path := range config.SkipPaths {
+		skip[path] = struct{}{}
+	}
+
+	var mu sync.Mutex
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			path := c.Request.URL.Path
+			if _, ok := skip[path]; ok {
+				next(c)
+				return
+			}
+
+			start := time.Now()
+			writer := types.NewResponseWriter(c.Writer)
+			c.Writer = writer
+			defer func() { c.Writer = writer.ResponseWriter }()
+
+			next(c)
+
+			var buf bytes.Buffer
+			formatter(&buf, LogEntry{
+				Time:     start,
+				Method:   c.Request.Method,
+				Path:     path,
+				Status:   writer.Status(),
+				Latency:  time.Since(start),
+				Bytes:    writer.Size(),
+				ClientIP: c.GetClientIP(),
+
+				RequestID: RequestIDFromContext(c.Request.Context()),
+			})
+
+			mu.Lock()
+			output.Write(buf.Bytes())
+			mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_Text(t *testing.T) {
+	var out bytes.Buffer
+	r := httptest.NewRequest(http.MethodGet, "/users/1?x=1", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+
+	_, _, reached := serve(r, Logger(LoggerConfig{Output: &out}))
+	require.True(t, reached)
+
+	fields := strings.Fields(out.String())
+	require.Len(t, fields, 7)
+	require.Equal(t, []string{"GET", "/users/1", "200"}, fields[1:4])
+	require.Equal(t, "2B", fields[5])
+	require.Equal(t, "10.0.0.1", fields[6])
+}
+
+func TestLogger_JSON(t *testing.T) {
+	var out bytes.Buffer
+	r := httptest.NewRequest(http.MethodPost, "/items", nil)
+
+	serve(r, Logger(LoggerConfig{Output: &out, Formatter: JSONLogFormatter}))
+
+	var entry LogEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	require.Equal(t, http.MethodPost, entry.Method)
+	require.Equal(t, "/items", entry.Path)
+	require.Equal(t, http.StatusOK, entry.Status)
+	require.Equal(t, int64(2), entry.Bytes)
+	require.Positive(t, entry.Latency)
+}
+
+func TestLogger_SkipPaths(t *testing.T) {
+	var out bytes.Buffer
+	logger := Logger(LoggerConfig{Output: &out, SkipPaths: []string{"/health"}})
+
+	_, _, reached := serve(httptest.NewRequest(http.MethodGet, "/health", nil), logger)
+	require.True(t, reached)
+	require.Empty(t, out.String())
+}
+
+func TestLogger_RecordsStatus(t *testing.T) {
+	var out bytes.Buffer
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-A", "1")
+	r.Header.Set("X-B", "2")
+
+	// The logger sees the status set by downstream middleware
+	w, _, _ := serve(r, Logger(LoggerConfig{Output: &out}), Limits(LimitsConfig{MaxHeaderCount: 1}))
+	require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	require.Contains(t, out.String(), " 431 ")
+}
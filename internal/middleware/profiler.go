@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"cmp"
+	"net/http"
+	"runtime/metrics"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultProfileWindow is the default sampling window of a Profiler
+const DefaultProfileWindow = time.Minute
+
+// Orders of the profile reports, see Profiler.Report
+const (
+	ProfileByTime    = "time"    // total time spent, the default
+	ProfileByLatency = "latency" // mean latency
+	ProfileByAllocs  = "allocs"  // total bytes allocated
+)
+
+// ProfilerConfig contains the settings of a Profiler
+type ProfilerConfig struct {
+	// Window over which the handlers are measured, DefaultProfileWindow if
+	// 0. Reports cover the last complete window, or the current one until
+	// a window completes.
+	Window time.Duration
+
+	// Measure 1 request in SampleRate, every request if 0 or 1
+	SampleRate int
+}
+
+// RouteProfile is the profile of a route over a window
+type RouteProfile struct {
+	Route        string        `json:"route"`
+	Requests     int64         `json:"requests"`
+	TotalTime    time.Duration `json:"total_time_ns"`
+	MeanLatency  time.Duration `json:"mean_latency_ns"`
+	MaxLatency   time.Duration `json:"max_latency_ns"`
+	AllocBytes   uint64        `json:"alloc_bytes"`
+	BytesPerReq  uint64        `json:"bytes_per_request"`
+	AllocsPerReq uint64        `json:"allocs_per_request"`
+
+	allocObjects uint64
+}
+
+// ProfileReport is the ranked profile of the routes over a window
+type ProfileReport struct {
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+	Routes []RouteProfile `json:"routes"`
+}
+
+// Profiler measures the latency and the allocations of route handlers,
+// e.g. in debug mode to find the hottest endpoints
+//
+// Allocations are read from the runtime's process-wide counters, so that
+// the allocations of concurrent requests are attributed to each other:
+// the figures are accurate when profiling one request at a time, e.g.
+// under a sequential load test, and indicative otherwise.
+type Profiler struct {
+	window     time.Duration
+	sampleRate uint64
+	requests   atomic.Uint64
+	now        func() time.Time
+
+	mu       sync.Mutex
+	start    time.Time
+	current  map[string]*RouteProfile
+	previous *ProfileReport
+}
+
+// NewProfiler creates a profiler, see Profiler.Middleware and
+// Profiler.Handler
+func NewProfiler(config ProfilerConfig) *Profiler {
+	return newProfiler(config, time.Now)
+}
+
+// newProfiler creates a profiler with the clock
+func newProfiler(config ProfilerConfig, now func() time.Time) *Profiler {
+	if config.Window <= 0 {
+		config.Window = DefaultProfileWindow
+	}
+	if config.SampleRate <= 0 {
+		config.SampleRate = 1
+	}
+	return &Profiler{
+		window:     config.Window,
+		sampleRate: uint64(config.SampleRate),
+		now:        now,
+		start:      now(),
+		current:    make(map[string]*RouteProfile),
+	}
+}
+
+// Middleware returns a middleware measuring the rest of the chain under
+// the method and route of the request, e.g. "GET /users/:id"
+func (p *Profiler) Middleware() types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			route := c.Route
+			if route == "" {
+				route = c.Request.URL.Path
+			}
+			p.measure(c.Request.Method+" "+route, c, next)
+		}
+	}
+}
+
+// measure runs a handler, recording it when sampled
+func (p *Profiler) measure(route string, c *types.Context, handler types.HandlerFunc) {
+	if p.requests.Add(1)%p.sampleRate != 0 {
+		handler(c)
+		return
+	}
+
+	samples := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}, {Name: "/gc/heap/allocs:objects"}}
+	metrics.Read(samples)
+	bytes, objects := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		metrics.Read(samples)
+		p.record(route, elapsed, samples[0].Value.Uint64()-bytes, samples[1].Value.Uint64()-objects)
+	}()
+	handler(c)
+}
+
+// record adds a measured request to the current window
+func (p *Profiler) record(route string, elapsed time.Duration, bytes, objects uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roll()
+
+	profile := p.current[route]
+	if profile == nil {
+		profile = &RouteProfile{Route: route}
+		p.current[route] = profile
+	}
+	profile.Requests++
+	profile.TotalTime += elapsed
+	profile.MaxLatency = max(profile.MaxLatency, elapsed)
+	profile.AllocBytes += bytes
+	profile.allocObjects += objects
+}
+
+// roll starts a new window once the current one is complete, keeping its
+// report
+func (p *Profiler) roll() {
+	now := p.now()
+	if now.Sub(p.start) < p.window {
+		return
+	}
+	end := p.start.Add(p.window)
+	p.previous = p.report(end)
+	// Windows without requests are skipped
+	for now.Sub(end) >= p.window {
+		end = end.Add(p.window)
+	}
+	p.start = end
+	p.current = make(map[string]*RouteProfile)
+}
+
+// Report returns the profile of the routes over the last complete window,
+// or the current one until a window completes, ranked by ProfileByTime,
+// ProfileByLatency or ProfileByAllocs
+func (p *Profiler) Report(order string) ProfileReport {
+	p.mu.Lock()
+	p.roll()
+	report := p.previous
+	if report == nil {
+		report = p.report(p.now())
+	}
+	p.mu.Unlock()
+
+	routes := slices.Clone(report.Routes)
+	slices.SortStableFunc(routes, func(a, b RouteProfile) int {
+		switch order {
+		case ProfileByLatency:
+			return cmp.Compare(b.MeanLatency, a.MeanLatency)
+		case ProfileByAllocs:
+			return cmp.Compare(b.AllocBytes, a.AllocBytes)
+		default:
+			return cmp.Compare(b.TotalTime, a.TotalTime)
+		}
+	})
+	return ProfileReport{Start: report.Start, End: report.End, Routes: routes}
+}
+
+// report returns the profile of the current window, ending at end
+func (p *Profiler) report(end time.Time) *ProfileReport {
+	routes := make([]RouteProfile, 0, len(p.current))
+	for _, profile := range p.current {
+		result := *profile
+		result.MeanLatency = result.TotalTime / time.Duration(result.Requests)
+		result.BytesPerReq = result.AllocBytes / uint64(result.Requests)
+		result.AllocsPerReq = result.allocObjects / uint64(result.Requests)
+		routes = append(routes, result)
+	}
+	slices.SortFunc(routes, func(a, b RouteProfile) int { return cmp.Compare(a.Route, b.Route) })
+	return &ProfileReport{Start: p.start, End: end, Routes: routes}
+}
+
+// Handler returns a handler reporting the profile of the routes, ranked
+// by the order of the sort query parameter, see Profiler.Report:
+// {"start", "end", "routes": [{"route", "requests", ...}]}
+func (p *Profiler) Handler() types.HandlerFunc {
+	return func(c *types.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, p.Report(c.GetQuery("sort")))
+	}
+}
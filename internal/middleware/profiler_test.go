@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiler(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	profiler := newProfiler(ProfilerConfig{Window: time.Minute}, func() time.Time { return now })
+	profile := profiler.Middleware()
+
+	var sink [][]byte
+	slow := profile(func(c *types.Context) {
+		time.Sleep(5 * time.Millisecond)
+	})
+	heavy := profile(func(c *types.Context) {
+		for range 10 {
+			sink = append(sink, make([]byte, 64<<10))
+		}
+	})
+	run := func(handler types.HandlerFunc, route string) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		handler(&types.Context{Request: r, Writer: httptest.NewRecorder(), Route: route})
+	}
+	run(slow, "/slow")
+	run(slow, "/slow")
+	run(heavy, "/heavy")
+	require.NotEmpty(t, sink)
+
+	// The current window is reported until it completes
+	report := profiler.Report("")
+	require.Len(t, report.Routes, 2)
+	require.Equal(t, "GET /slow", report.Routes[0].Route)
+	require.Equal(t, int64(2), report.Routes[0].Requests)
+	require.GreaterOrEqual(t, report.Routes[0].MeanLatency, 5*time.Millisecond)
+	require.Equal(t, "GET /heavy", profiler.Report(ProfileByAllocs).Routes[0].Route)
+	require.GreaterOrEqual(t, profiler.Report(ProfileByAllocs).Routes[0].BytesPerReq, uint64(640<<10))
+
+	// then the last complete one
+	now = now.Add(90 * time.Second)
+	run(heavy, "/heavy")
+	report = profiler.Report(ProfileByTime)
+	require.Len(t, report.Routes, 2)
+	require.Equal(t, time.Minute, report.End.Sub(report.Start))
+	now = now.Add(time.Minute)
+	report = profiler.Report(ProfileByTime)
+	require.Len(t, report.Routes, 1)
+	require.Equal(t, int64(1), report.Routes[0].Requests)
+
+	w := httptest.NewRecorder()
+	profiler.Handler()(&types.Context{Request: httptest.NewRequest(http.MethodGet, "/?sort=latency", nil), Writer: w})
+	var body ProfileReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "GET /heavy", body.Routes[0].Route)
+}
+
+func TestProfiler_SampleRate(t *testing.T) {
+	profiler := NewProfiler(ProfilerConfig{SampleRate: 3})
+	for range 7 {
+		serve(httptest.NewRequest(http.MethodGet, "/items", nil), profiler.Middleware())
+	}
+	require.Equal(t, int64(2), profiler.Report("").Routes[0].Requests)
+}
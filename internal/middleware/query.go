@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DuplicateQueryPolicy defines how repeated query parameters are handled
+type DuplicateQueryPolicy int
+
+const (
+	DuplicateQueryAllow  DuplicateQueryPolicy = iota // keep every value
+	DuplicateQueryFirst                              // keep the first value
+	DuplicateQueryLast                               // keep the last value
+	DuplicateQueryReject                             // reject with 400
+)
+
+// ParseDuplicateQueryPolicy parses a policy name: allow, first, last or
+// reject, the empty string is treated as allow
+func ParseDuplicateQueryPolicy(name string) (DuplicateQueryPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "allow":
+		return DuplicateQueryAllow, nil
+	case "first":
+		return DuplicateQueryFirst, nil
+	case "last":
+		return DuplicateQueryLast, nil
+	case "reject":
+		return DuplicateQueryReject, nil
+	default:
+		return DuplicateQueryAllow, fmt.Errorf("unknown duplicate query policy: %q", name)
+	}
+}
+
+// DuplicateQuery returns a middleware normalizing repeated query parameters
+// according to the policy before any handler or binding reads them
+//
+// The first and last policies rewrite the raw query so that every accessor
+// sees the same single value, keeping the original parameter order. Query
+// parameter pollution otherwise lets a proxy and a handler disagree on
+// which value of a parameter is authoritative.
+func DuplicateQuery(policy DuplicateQueryPolicy) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		if policy == DuplicateQueryAllow {
+			return next
+		}
+
+		return func(c *types.Context) {
+			query, duplicate := normalizeQuery(c.Request.URL.RawQuery, policy)
+			if duplicate && policy == DuplicateQueryReject {
+				c.Abort()
+				c.ErrorString(http.StatusBadRequest, "duplicate query parameter")
+				return
+			}
+			c.Request.URL.RawQuery = query
+			next(c)
+		}
+	}
+}
+
+// normalizeQuery keeps a single occurrence of every parameter
+//
+// @return: the normalized raw query
+// @return: whether the query contained duplicate parameters
+func normalizeQuery(rawQuery string, policy DuplicateQueryPolicy) (string, bool) {
+	if rawQuery == "" {
+		return rawQuery, false
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	keep := make(map[string]int, len(pairs))
+	duplicate := false
+	for i, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+
+		key := queryKey(pair)
+		if _, seen := keep[key]; seen {
+			duplicate = true
+			if policy == DuplicateQueryFirst {
+				continue
+			}
+		}
+		keep[key] = i
+	}
+	if !duplicate {
+		return rawQuery, false
+	}
+
+	kept := make([]string, 0, len(keep))
+	for i, pair := range pairs {
+		if pair != "" && keep[queryKey(pair)] == i {
+			kept = append(kept, pair)
+		}
+	}
+	return strings.Join(kept, "&"), true
+}
+
+// queryKey returns the decoded key of a raw query pair
+func queryKey(pair string) string {
+	key, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicateQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   DuplicateQueryPolicy
+		query    string
+		status   int
+		expected string
+	}{
+		{"allow", DuplicateQueryAllow, "role=user&id=1&role=admin", http.StatusOK, "role=user&id=1&role=admin"},
+		{"first", DuplicateQueryFirst, "role=user&id=1&role=admin", http.StatusOK, "role=user&id=1"},
+		{"last", DuplicateQueryLast, "role=user&id=1&role=admin", http.StatusOK, "id=1&role=admin"},
+		{"encoded keys", DuplicateQueryFirst, "r%6Fle=user&role=admin", http.StatusOK, "r%6Fle=user"},
+		{"reject", DuplicateQueryReject, "role=user&role=admin", http.StatusBadRequest, ""},
+		{"reject without duplicates", DuplicateQueryReject, "role=user&id=1", http.StatusOK, "role=user&id=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			w, c, reached := serve(r, DuplicateQuery(tt.policy))
+
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.status == http.StatusOK, reached)
+			if reached {
+				require.Equal(t, tt.expected, c.Request.URL.RawQuery)
+			}
+		})
+	}
+}
+
+func TestParseDuplicateQueryPolicy(t *testing.T) {
+	policy, err := ParseDuplicateQueryPolicy("Last")
+	require.NoError(t, err)
+	require.Equal(t, DuplicateQueryLast, policy)
+
+	policy, err = ParseDuplicateQueryPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, DuplicateQueryAllow, policy)
+
+	_, err = ParseDuplicateQueryPolicy("random")
+	require.Error(t, err)
+}
@@ -0,0 +1,363 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultRateLimitPeriod is the period of the rate limits when
+// RateLimitConfig.Period is not set
+const DefaultRateLimitPeriod = time.Minute
+
+// rateLimitPrefix prefixes the keys of the counters in RateLimitConfig.Store
+const rateLimitPrefix = "ratelimit:"
+
+// RateLimitConfig configures the RateLimit middleware
+type RateLimitConfig struct {
+	// Limit is the number of requests allowed per period and key, which
+	// may all be sent at once
+	Limit int
+
+	// Period over which Limit requests are allowed, DefaultRateLimitPeriod
+	// if 0
+	Period time.Duration
+
+	// Key extracts the key limited from a request, e.g. RateLimitByHeader,
+	// RemoteIP if nil. Requests with an empty key are not limited.
+	Key func(c *types.Context) string
+
+	// Store counts the requests of the keys in fixed windows of Period,
+	// e.g. a redisstore.Store so that the limits hold across the instances
+	// behind a load balancer. Token buckets kept in memory if nil.
+	Store store.Counter
+
+	// WarnLimit is the number of requests per period and key over which
+	// requests are let through with a LimitWarningHeader and counted in
+	// Warnings. Without Limit, the requests over WarnLimit are all let
+	// through, e.g. to observe the rates before enforcing a limit.
+	WarnLimit int
+
+	// Counter of the warnings, DefaultLimitWarnings if nil
+	Warnings *LimitWarnings
+
+	// Name identifies the limit in the usage reports, e.g. "burst" or
+	// "daily-quota", see RateLimitUsageHandler
+	Name string
+}
+
+// RateLimitUsage is the usage of a limit by a key
+type RateLimitUsage struct {
+	Name      string `json:"name,omitempty"`
+	Policy    string `json:"policy"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	Reset     int    `json:"reset"` // seconds until the limit is fully available
+}
+
+// RateLimitByHeader returns a key extraction limiting the requests per
+// value of a header, e.g. an API key
+func RateLimitByHeader(name string) func(c *types.Context) string {
+	return func(c *types.Context) string {
+		return c.Request.Header.Get(name)
+	}
+}
+
+// RateLimit returns a middleware limiting the requests per key
+//
+// Every key has a bucket of Limit tokens, refilled continuously over the
+// period, and every request takes a token. With a Store, Limit requests
+// are allowed per window of the period instead, and requests are let
+// through when the store fails, logging the error. The responses carry the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and
+// RateLimit-Policy, see the IETF RateLimit header fields draft. Requests
+// finding the bucket empty are rejected with 429 Too Many Requests and a
+// Retry-After header. Requests over WarnLimit are let through with a
+// LimitWarningHeader, without the RateLimit headers when Limit is not set.
+func RateLimit(config RateLimitConfig) types.MiddlewareFunc {
+	return NewRateLimiter(config).Middleware()
+}
+
+// RateLimiter keeps the token buckets of the keys, see RateLimit
+type RateLimiter struct {
+	name     string
+	limits   atomic.Pointer[rateLimits]
+	warnings *LimitWarnings
+	key      func(c *types.Context) string
+	store    store.Counter
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// rateLimits are the limits of a RateLimiter, replaced by Update
+type rateLimits struct {
+	limit float64 // 0 if the limiter is disabled
+
+	// Soft limit, enforce being false when only WarnLimit is set
+	enforce   bool
+	warnLimit int
+	warning   string
+
+	period time.Duration
+	policy string
+}
+
+// newRateLimits returns the limits of the configuration
+func newRateLimits(config RateLimitConfig) *rateLimits {
+	if config.Period <= 0 {
+		config.Period = DefaultRateLimitPeriod
+	}
+	enforce := config.Limit > 0
+	if !enforce {
+		config.Limit = config.WarnLimit
+	}
+	return &rateLimits{
+		limit:     float64(config.Limit),
+		enforce:   enforce,
+		warnLimit: config.WarnLimit,
+		warning:   LimitRateLimit + "; limit=" + strconv.Itoa(config.WarnLimit),
+		period:    config.Period,
+		policy:    strconv.Itoa(config.Limit) + ";w=" + strconv.Itoa(int(math.Ceil(config.Period.Seconds()))),
+	}
+}
+
+// rateLimitResult is the outcome of a request
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int           // requests left
+	reset      time.Duration // until the limit is fully available again
+	retryAfter time.Duration // until the next request is allowed, if denied
+}
+
+// tokenBucket is the bucket of a key
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a rate limiter with the configuration, whose
+// usage may be reported with RateLimitUsageHandler
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return newRateLimiter(config, time.Now)
+}
+
+// newRateLimiter creates a rate limiter with the clock
+func newRateLimiter(config RateLimitConfig, now func() time.Time) *RateLimiter {
+	if config.Key == nil {
+		config.Key = RemoteIP
+	}
+	l := &RateLimiter{
+		name:      config.Name,
+		warnings:  limitWarnings(config.Warnings),
+		key:       config.Key,
+		store:     config.Store,
+		now:       now,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: now(),
+	}
+	l.Update(config)
+	return l
+}
+
+// Update replaces the Limit, Period and WarnLimit of the limiter, for the
+// requests starting afterwards, e.g. when the configuration is reloaded.
+// The other settings are kept. Without Limit nor WarnLimit, the requests
+// are not limited.
+func (l *RateLimiter) Update(config RateLimitConfig) {
+	l.limits.Store(newRateLimits(config))
+}
+
+// Middleware returns the middleware enforcing the limits, see RateLimit
+func (l *RateLimiter) Middleware() types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			limits := l.limits.Load()
+			if limits.limit == 0 {
+				next(c)
+				return
+			}
+			key := l.key(c)
+			if key == "" {
+				next(c)
+				return
+			}
+
+			var result rateLimitResult
+			if l.store == nil {
+				result = l.take(limits, key)
+			} else {
+				var err error
+				if result, err = l.countN(c.Request.Context(), limits, key, 1); err != nil {
+					c.Logger().Error("ratelimit: store failed", "error", err)
+					next(c)
+					return
+				}
+			}
+
+			if !limits.enforce {
+				if !result.allowed {
+					l.warnings.warn(c, LimitRateLimit, limits.warning)
+				}
+				next(c)
+				return
+			}
+
+			header := c.Writer.Header()
+			header.Set("RateLimit-Policy", limits.policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(int(limits.limit)))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(seconds(result.reset)))
+			if !result.allowed {
+				header.Set("Retry-After", strconv.Itoa(seconds(result.retryAfter)))
+				c.Abort()
+				c.ErrorString(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+				return
+			}
+			if limits.warnLimit > 0 && int(limits.limit)-result.remaining > limits.warnLimit {
+				l.warnings.warn(c, LimitRateLimit, limits.warning)
+			}
+			next(c)
+		}
+	}
+}
+
+// take takes a token from the bucket of the key
+func (l *RateLimiter) take(limits *rateLimits, key string) rateLimitResult {
+	now := l.now()
+	rate := limits.limit / limits.period.Seconds() // tokens per second
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Full buckets are dropped, a new bucket being full as well
+	if now.Sub(l.lastSweep) > limits.period {
+		l.lastSweep = now
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*rate >= limits.limit {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: limits.limit, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(limits.limit, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := rateLimitResult{allowed: b.tokens >= 1}
+	if result.allowed {
+		b.tokens--
+	} else {
+		result.retryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	result.remaining = int(b.tokens)
+	result.reset = time.Duration((limits.limit - b.tokens) / rate * float64(time.Second))
+	return result
+}
+
+// peek returns the tokens left in the bucket of the key, without taking one
+func (l *RateLimiter) peek(limits *rateLimits, key string) rateLimitResult {
+	now := l.now()
+	rate := limits.limit / limits.period.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tokens := limits.limit
+	if b, ok := l.buckets[key]; ok {
+		tokens = math.Min(limits.limit, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	return rateLimitResult{
+		allowed:   tokens >= 1,
+		remaining: int(tokens),
+		reset:     time.Duration((limits.limit - tokens) / rate * float64(time.Second)),
+	}
+}
+
+// countN counts n requests of the key in the window of the store
+func (l *RateLimiter) countN(ctx context.Context, limits *rateLimits, key string, n int64) (rateLimitResult, error) {
+	count, ttl, err := l.store.Increment(ctx, rateLimitPrefix+key, n, limits.period)
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	ttl = min(ttl, limits.period) // the store may measure it from a later clock reading
+	result := rateLimitResult{
+		allowed:   count <= int64(limits.limit),
+		remaining: max(0, int(int64(limits.limit)-count)),
+		reset:     ttl,
+	}
+	if !result.allowed {
+		result.retryAfter = ttl
+	}
+	return result, nil
+}
+
+// Usage returns the usage of the limit by the key of the request, without
+// counting the request nor starting a window for it
+//
+// @return: false if the request has no key, or the limiter no limit, and
+// is not limited
+func (l *RateLimiter) Usage(c *types.Context) (RateLimitUsage, bool, error) {
+	limits := l.limits.Load()
+	key := l.key(c)
+	if key == "" || limits.limit == 0 {
+		return RateLimitUsage{}, false, nil
+	}
+
+	var result rateLimitResult
+	if l.store == nil {
+		result = l.peek(limits, key)
+	} else {
+		var err error
+		if result, err = l.countN(c.Request.Context(), limits, key, 0); err != nil {
+			return RateLimitUsage{}, false, err
+		}
+	}
+	return RateLimitUsage{
+		Name:      l.name,
+		Policy:    limits.policy,
+		Limit:     int(limits.limit),
+		Remaining: result.remaining,
+		Reset:     seconds(result.reset),
+	}, true, nil
+}
+
+// RateLimitUsageHandler returns a handler reporting the usage of the
+// limits by the key of the request, e.g. the API key, so that clients can
+// throttle themselves: {"limits": [{"name", "policy", "limit",
+// "remaining", "reset"}]}
+func RateLimitUsageHandler(limiters ...*RateLimiter) types.HandlerFunc {
+	return func(c *types.Context) {
+		usages := make([]RateLimitUsage, 0, len(limiters))
+		for _, l := range limiters {
+			usage, ok, err := l.Usage(c)
+			if err != nil {
+				c.Error(http.StatusServiceUnavailable, err)
+				return
+			}
+			if ok {
+				usages = append(usages, usage)
+			}
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, map[string]any{"limits": usages})
+	}
+}
+
+// seconds rounds a duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
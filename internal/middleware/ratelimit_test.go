@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := newRateLimiter(RateLimitConfig{Limit: 2, Period: time.Minute}, func() time.Time { return now })
+	limit := limiter.Middleware()
+
+	w, _, reached := serve(requestFrom("10.0.0.1:1234"), limit)
+	require.True(t, reached)
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w, _, reached = serve(requestFrom("10.0.0.1:1234"), limit)
+	require.True(t, reached)
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+	w, _, reached = serve(requestFrom("10.0.0.1:1234"), limit)
+	require.False(t, reached)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// Other keys have their own bucket
+	_, _, reached = serve(requestFrom("10.0.0.2:1234"), limit)
+	require.True(t, reached)
+
+	// A token is refilled every 30 seconds
+	now = now.Add(30 * time.Second)
+	_, _, reached = serve(requestFrom("10.0.0.1:1234"), limit)
+	require.True(t, reached)
+	_, _, reached = serve(requestFrom("10.0.0.1:1234"), limit)
+	require.False(t, reached)
+}
+
+func TestRateLimit_Sweep(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := newRateLimiter(RateLimitConfig{Limit: 1}, func() time.Time { return now })
+	limit := limiter.Middleware()
+
+	serve(requestFrom("10.0.0.1:1234"), limit)
+	serve(requestFrom("10.0.0.2:1234"), limit)
+	require.Len(t, limiter.buckets, 2)
+
+	now = now.Add(2 * DefaultRateLimitPeriod)
+	serve(requestFrom("10.0.0.3:1234"), limit)
+	require.Len(t, limiter.buckets, 1)
+}
+
+func TestRateLimitByHeader(t *testing.T) {
+	limit := RateLimit(RateLimitConfig{Limit: 1, Key: RateLimitByHeader("X-API-Key")})
+
+	r := requestFrom("10.0.0.1:1234")
+	r.Header.Set("X-API-Key", "a")
+	_, _, reached := serve(r, limit)
+	require.True(t, reached)
+	_, _, reached = serve(r, limit)
+	require.False(t, reached)
+
+	r = requestFrom("10.0.0.1:1234")
+	r.Header.Set("X-API-Key", "b")
+	_, _, reached = serve(r, limit)
+	require.True(t, reached)
+
+	// Requests without key are not limited
+	for range 3 {
+		w, _, reached := serve(requestFrom("10.0.0.1:1234"), limit)
+		require.True(t, reached)
+		require.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimit_Store(t *testing.T) {
+	counter := store.NewMemory(store.MemoryConfig{})
+	config := RateLimitConfig{Limit: 2, Store: counter}
+
+	// Instances sharing the store share the limits
+	instances := []types.MiddlewareFunc{RateLimit(config), RateLimit(config)}
+	for i, limit := range instances {
+		w, _, reached := serve(requestFrom("10.0.0.1:1234"), limit)
+		require.True(t, reached)
+		require.Equal(t, strconv.Itoa(1-i), w.Header().Get("RateLimit-Remaining"))
+		require.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	}
+
+	w, _, reached := serve(requestFrom("10.0.0.1:1234"), instances[0])
+	require.False(t, reached)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	_, _, reached = serve(requestFrom("10.0.0.2:1234"), instances[1])
+	require.True(t, reached)
+}
+
+func TestRateLimit_StoreError(t *testing.T) {
+	counter := store.NewMemory(store.MemoryConfig{})
+	require.NoError(t, counter.Set(context.Background(), "ratelimit:10.0.0.1", []byte("not a count"), 0))
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// Requests are let through when the store fails
+	w, _, reached := serve(requestFrom("10.0.0.1:1234"), RateLimit(RateLimitConfig{Limit: 1, Store: counter}))
+	require.True(t, reached)
+	require.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitUsageHandler(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	burst := newRateLimiter(RateLimitConfig{Limit: 2, Name: "burst"}, func() time.Time { return now })
+	counter := store.NewMemory(store.MemoryConfig{})
+	quota := NewRateLimiter(RateLimitConfig{Limit: 1000, Period: 24 * time.Hour, Name: "daily", Store: counter})
+	usage := RateLimitUsageHandler(burst, quota)
+	report := func() string {
+		w := httptest.NewRecorder()
+		usage(&types.Context{Request: requestFrom("10.0.0.1:1234"), Writer: w})
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	// Reporting the usage does not count as a request, nor starts a window
+	require.JSONEq(t, `{"limits": [
+		{"name": "burst", "policy": "2;w=60", "limit": 2, "remaining": 2, "reset": 0},
+		{"name": "daily", "policy": "1000;w=86400", "limit": 1000, "remaining": 1000, "reset": 0}
+	]}`, report())
+	require.JSONEq(t, `{"limits": [
+		{"name": "burst", "policy": "2;w=60", "limit": 2, "remaining": 2, "reset": 0},
+		{"name": "daily", "policy": "1000;w=86400", "limit": 1000, "remaining": 1000, "reset": 0}
+	]}`, report())
+	_, err := counter.Get(context.Background(), rateLimitPrefix+"10.0.0.1")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	serve(requestFrom("10.0.0.1:1234"), burst.Middleware(), quota.Middleware())
+	require.JSONEq(t, `{"limits": [
+		{"name": "burst", "policy": "2;w=60", "limit": 2, "remaining": 1, "reset": 30},
+		{"name": "daily", "policy": "1000;w=86400", "limit": 1000, "remaining": 999, "reset": 86400}
+	]}`, report())
+}
+
+func TestRateLimit_Warn(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	warnings := &LimitWarnings{}
+
+	// Requests over the soft limit are let through with a warning
+	limit := newRateLimiter(RateLimitConfig{Limit: 3, WarnLimit: 1, Warnings: warnings}, func() time.Time { return now }).Middleware()
+	w, _, _ := serve(requestFrom("10.0.0.1:1234"), limit)
+	require.Empty(t, w.Header().Get(LimitWarningHeader))
+	w, _, reached := serve(requestFrom("10.0.0.1:1234"), limit)
+	require.True(t, reached)
+	require.Equal(t, "rate-limit; limit=1", w.Header().Get(LimitWarningHeader))
+
+	// and only warned about without hard limit
+	warnOnly := newRateLimiter(RateLimitConfig{WarnLimit: 1, Warnings: warnings}, func() time.Time { return now }).Middleware()
+	for i := range 3 {
+		w, _, reached = serve(requestFrom("10.0.0.1:1234"), warnOnly)
+		require.True(t, reached)
+		require.Empty(t, w.Header().Get("RateLimit-Limit"))
+		require.Equal(t, i > 0, w.Header().Get(LimitWarningHeader) != "")
+	}
+	require.Equal(t, map[string]uint64{LimitRateLimit: 3}, warnings.Counts())
+}
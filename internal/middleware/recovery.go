@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/events"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// PanicHandler responds to a recovered panic
+type PanicHandler func(c *types.Context, recovered any, stack []byte)
+
+// RecoveryConfig configures the Recovery middleware
+type RecoveryConfig struct {
+	// Logger receives the panic value and stack trace, defaults to
+	// Context.Logger
+	Logger types.Logger
+
+	// Handler replaces the default 500 JSON response
+	Handler PanicHandler
+
+	// Events receives an events.PanicRecovered for every recovered panic,
+	// e.g. Engine.Events, may be nil
+	Events *events.Bus
+}
+
+// Recovery returns a middleware that recovers from panics in downstream
+// handlers, logs the panic with its stack trace and responds with a 500
+// JSON error, or calls the configured handler instead
+//
+// In the debug mode of the engine, the error includes the panic value and
+// the stack trace.
+//
+// Panics with http.ErrAbortHandler are propagated so that net/http can
+// abort the response as intended.
+func Recovery(config RecoveryConfig) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				stack := debug.Stack()
+				logger, attrs := requestLogger(c, config.Logger)
+				logger.Error("panic recovered", append(attrs, "panic", recovered, "stack", string(stack))...)
+				if config.Events != nil {
+					events.Publish(config.Events, events.PanicRecovered{Request: c.Request, Value: recovered, Stack: stack})
+				}
+
+				c.Abort()
+				if config.Handler != nil {
+					config.Handler(c, recovered, stack)
+					return
+				}
+				if c.Debug {
+					c.JSON(http.StatusInternalServerError, map[string]any{
+						"error":   http.StatusText(http.StatusInternalServerError),
+						"message": fmt.Sprintf("panic: %v", recovered),
+						"stack":   strings.Split(strings.TrimSpace(string(stack)), "\n"),
+					})
+					return
+				}
+				c.ErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			}()
+
+			next(c)
+		}
+	}
+}
+
+// requestLogger returns the logger of a middleware, Context.Logger if nil,
+// and the attributes identifying the request that a configured logger lacks
+func requestLogger(c *types.Context, logger types.Logger) (types.Logger, []any) {
+	if logger == nil {
+		return c.Logger(), nil
+	}
+	return logger, []any{"method", c.Request.Method, "path", c.Request.URL.Path}
+}
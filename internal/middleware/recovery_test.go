@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/events"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	var bus events.Bus
+	var recovered []events.PanicRecovered
+	events.Subscribe(&bus, func(e events.PanicRecovered) { recovered = append(recovered, e) })
+	recovery := Recovery(RecoveryConfig{Logger: slog.New(slog.NewTextHandler(&logs, nil)), Events: &bus})
+
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/boom", nil), Writer: w}
+	c.Execute(types.Chain([]types.MiddlewareFunc{recovery}, func(c *types.Context) {
+		panic("boom")
+	}))
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"error":"Internal Server Error","message":"Internal Server Error"}`, w.Body.String())
+	require.True(t, c.IsAborted())
+	require.Contains(t, logs.String(), `msg="panic recovered" method=GET path=/boom panic=boom`)
+	require.Contains(t, logs.String(), "recovery_test.go")
+
+	require.Len(t, recovered, 1)
+	require.Equal(t, "boom", recovered[0].Value)
+	require.Equal(t, "/boom", recovered[0].Request.URL.Path)
+	require.Contains(t, string(recovered[0].Stack), "recovery_test.go")
+}
+
+func TestRecovery_Handler(t *testing.T) {
+	var got any
+	recovery := Recovery(RecoveryConfig{
+		Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		Handler: func(c *types.Context, recovered any, stack []byte) {
+			got = recovered
+			c.String(http.StatusServiceUnavailable, "custom")
+		},
+	})
+
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w}
+	c.Execute(types.Chain([]types.MiddlewareFunc{recovery}, func(c *types.Context) {
+		panic(42)
+	}))
+
+	require.Equal(t, 42, got)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "custom", w.Body.String())
+}
+
+func TestRecovery_ErrAbortHandler(t *testing.T) {
+	recovery := Recovery(RecoveryConfig{Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))})
+
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: httptest.NewRecorder()}
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		c.Execute(types.Chain([]types.MiddlewareFunc{recovery}, func(c *types.Context) {
+			panic(http.ErrAbortHandler)
+		}))
+	})
+}
+
+func TestRecovery_Debug(t *testing.T) {
+	recovery := Recovery(RecoveryConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/boom", nil), Writer: w, Debug: true}
+	c.Execute(types.Chain([]types.MiddlewareFunc{recovery}, func(c *types.Context) {
+		panic("boom")
+	}))
+
+	var body struct {
+		Message string   `json:"message"`
+		Stack   []string `json:"stack"`
+	}
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "panic: boom", body.Message)
+	require.Contains(t, strings.Join(body.Stack, "\n"), "recovery_test.go")
+}
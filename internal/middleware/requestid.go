@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/skjdfhkskjds/go-api/internal/idgen"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultRequestIDHeader carries the request ids when RequestIDConfig.Header
+// is not set
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the request ids accepted from the clients
+const maxRequestIDLength = 128
+
+// RequestIDConfig configures the RequestID middleware
+type RequestIDConfig struct {
+	// Header carrying the ids, DefaultRequestIDHeader if empty
+	Header string
+
+	// Generator of the ids, the one of the engine if nil, see
+	// Context.NewID
+	Generator idgen.Generator
+
+	// TrustIncoming keeps the ids sent by the clients, e.g. behind a
+	// gateway generating them, rather than replacing them
+	TrustIncoming bool
+}
+
+// requestIDKey is the context key of the request id
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the id of the request, set by the RequestID
+// middleware, e.g. to add it to the logs of a handler
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID returns a middleware identifying every request with an id,
+// sent back in the response header and logged by Logger and
+// Context.Logger
+func RequestID(config RequestIDConfig) types.MiddlewareFunc {
+	if config.Header == "" {
+		config.Header = DefaultRequestIDHeader
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			id := ""
+			if config.TrustIncoming {
+				id = c.Request.Header.Get(config.Header)
+				if len(id) > maxRequestIDLength || !printableASCII(id) {
+					id = ""
+				}
+			}
+			if id == "" && config.Generator != nil {
+				id = config.Generator.NewID()
+			} else if id == "" {
+				id = c.NewID()
+			}
+
+			c.Request.Header.Set(config.Header, id)
+			c.Header(config.Header, id)
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+			c.AddLogAttrs("request_id", id)
+			next(c)
+		}
+	}
+}
+
+// printableASCII reports whether the string only holds printable ASCII
+// characters, so that it can be logged and sent back as is
+func printableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
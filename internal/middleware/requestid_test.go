@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/idgen"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// sequence generates ids from a counter
+type sequence struct{ n int }
+
+func (s *sequence) NewID() string {
+	s.n++
+	return "id-" + strings.Repeat("x", s.n)
+}
+
+var _ idgen.Generator = (*sequence)(nil)
+
+func TestRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	generator := &sequence{}
+	logger := Logger(LoggerConfig{Output: &logs, Formatter: JSONLogFormatter})
+
+	// Loggers running before RequestID see the id
+	w, c, _ := serve(httptest.NewRequest(http.MethodGet, "/", nil), logger, RequestID(RequestIDConfig{Generator: generator}))
+	require.Equal(t, "id-x", w.Header().Get("X-Request-Id"))
+	require.Equal(t, "id-x", RequestIDFromContext(c.Request.Context()))
+	require.Contains(t, logs.String(), `"request_id":"id-x"`)
+
+	// Incoming ids are replaced unless trusted
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "upstream")
+	w, _, _ = serve(r, RequestID(RequestIDConfig{Generator: generator}))
+	require.Equal(t, "id-xx", w.Header().Get("X-Request-Id"))
+	require.Equal(t, "id-xx", r.Header.Get("X-Request-Id"))
+
+	r.Header.Set("X-Request-Id", "upstream")
+	w, _, _ = serve(r, RequestID(RequestIDConfig{Generator: generator, TrustIncoming: true}))
+	require.Equal(t, "upstream", w.Header().Get("X-Request-Id"))
+
+	r.Header.Set("X-Request-Id", "bad id\n")
+	w, _, _ = serve(r, RequestID(RequestIDConfig{Generator: generator, TrustIncoming: true}))
+	require.Equal(t, "id-xxx", w.Header().Get("X-Request-Id"))
+
+	// The generator of the context applies by default
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	w = httptest.NewRecorder()
+	ctx := &types.Context{Request: r, Writer: w, IDGenerator: generator}
+	ctx.Execute(types.Chain([]types.MiddlewareFunc{RequestID(RequestIDConfig{Header: "X-Trace-Id"})}, func(*types.Context) {}))
+	require.Equal(t, "id-xxxx", w.Header().Get("X-Trace-Id"))
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Defaults of the SecureHeaders middleware
+const (
+	DefaultHSTSMaxAge     = 365 * 24 * time.Hour
+	DefaultFrameOptions   = "DENY"
+	DefaultReferrerPolicy = "strict-origin-when-cross-origin"
+)
+
+// CSPNonce is the placeholder of the nonce in
+// SecureHeadersConfig.ContentSecurityPolicy, e.g.
+// "script-src 'self' 'nonce-{nonce}'"
+const CSPNonce = "{nonce}"
+
+// SecureHeadersConfig configures the SecureHeaders middleware, whose zero
+// value sends the defaults without Content-Security-Policy
+type SecureHeadersConfig struct {
+	// HSTSMaxAge is how long browsers only connect over HTTPS,
+	// DefaultHSTSMaxAge if 0, no Strict-Transport-Security if negative
+	HSTSMaxAge time.Duration
+
+	// HSTSIncludeSubdomains and HSTSPreload add the directives of the
+	// same name, see https://hstspreload.org before setting HSTSPreload
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	// FrameOptions is the X-Frame-Options header, DefaultFrameOptions if
+	// empty, "-" to omit it
+	FrameOptions string
+
+	// ReferrerPolicy is the Referrer-Policy header, DefaultReferrerPolicy
+	// if empty, "-" to omit it
+	ReferrerPolicy string
+
+	// ContentSecurityPolicy is the Content-Security-Policy header, omitted
+	// if empty. Every CSPNonce placeholder is replaced with a nonce of the
+	// request, see Context.CSPNonce.
+	ContentSecurityPolicy string
+
+	// CSPReportOnly sends the policy as
+	// Content-Security-Policy-Report-Only, e.g. while rolling it out
+	CSPReportOnly bool
+}
+
+// SecureHeaders returns a middleware sending the security headers of the
+// responses: Strict-Transport-Security, X-Content-Type-Options: nosniff,
+// X-Frame-Options, Referrer-Policy and Content-Security-Policy
+//
+// Headers set by the handlers take precedence, e.g. to allow framing a
+// page.
+func SecureHeaders(config SecureHeadersConfig) types.MiddlewareFunc {
+	headers := map[string]string{"X-Content-Type-Options": "nosniff"}
+	if config.HSTSMaxAge == 0 {
+		config.HSTSMaxAge = DefaultHSTSMaxAge
+	}
+	if config.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(int(config.HSTSMaxAge/time.Second))
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+		headers["Strict-Transport-Security"] = hsts
+	}
+	if config.FrameOptions == "" {
+		config.FrameOptions = DefaultFrameOptions
+	}
+	if config.FrameOptions != "-" {
+		headers["X-Frame-Options"] = config.FrameOptions
+	}
+	if config.ReferrerPolicy == "" {
+		config.ReferrerPolicy = DefaultReferrerPolicy
+	}
+	if config.ReferrerPolicy != "-" {
+		headers["Referrer-Policy"] = config.ReferrerPolicy
+	}
+	cspHeader := "Content-Security-Policy"
+	if config.CSPReportOnly {
+		cspHeader += "-Report-Only"
+	}
+	withNonce := strings.Contains(config.ContentSecurityPolicy, CSPNonce)
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			header := c.Writer.Header()
+			for name, value := range headers {
+				header.Set(name, value)
+			}
+			switch {
+			case withNonce:
+				b := make([]byte, 16)
+				rand.Read(b)
+				nonce := base64.StdEncoding.EncodeToString(b)
+				c.SetCSPNonce(nonce)
+				header.Set(cspHeader, strings.ReplaceAll(config.ContentSecurityPolicy, CSPNonce, nonce))
+			case config.ContentSecurityPolicy != "":
+				header.Set(cspHeader, config.ContentSecurityPolicy)
+			}
+			next(c)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	w, _, reached := serve(httptest.NewRequest(http.MethodGet, "/", nil), SecureHeaders(SecureHeadersConfig{}))
+	require.True(t, reached)
+	require.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+	require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, DefaultFrameOptions, w.Header().Get("X-Frame-Options"))
+	require.Equal(t, DefaultReferrerPolicy, w.Header().Get("Referrer-Policy"))
+	require.Empty(t, w.Header().Get("Content-Security-Policy"))
+
+	w, _, _ = serve(httptest.NewRequest(http.MethodGet, "/", nil), SecureHeaders(SecureHeadersConfig{
+		HSTSMaxAge:            time.Hour,
+		HSTSIncludeSubdomains: true,
+		FrameOptions:          "-",
+		ContentSecurityPolicy: "default-src 'self'",
+		CSPReportOnly:         true,
+	}))
+	require.Equal(t, "max-age=3600; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+	require.NotContains(t, w.Header(), "X-Frame-Options")
+	require.Equal(t, "default-src 'self'", w.Header().Get("Content-Security-Policy-Report-Only"))
+}
+
+func TestSecureHeaders_Nonce(t *testing.T) {
+	secure := SecureHeaders(SecureHeadersConfig{ContentSecurityPolicy: "script-src 'nonce-{nonce}'; style-src 'nonce-{nonce}'"})
+	var nonces []string
+	for range 2 {
+		w := httptest.NewRecorder()
+		secure(func(c *types.Context) {
+			nonces = append(nonces, c.CSPNonce())
+		})(&types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w})
+
+		nonce := nonces[len(nonces)-1]
+		require.Len(t, nonce, 24)
+		require.Equal(t, "script-src 'nonce-"+nonce+"'; style-src 'nonce-"+nonce+"'", w.Header().Get("Content-Security-Policy"))
+	}
+	require.NotEqual(t, nonces[0], nonces[1])
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/skjdfhkskjds/go-api/internal/session"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Sessions returns a middleware loading the session of every request, see
+// Context.Session, and saving it with the response
+//
+// The session is saved right before the header of the response is
+// written, so that its cookie is part of it. Failures of the store are
+// logged, the request going on with a new session when it cannot be loaded.
+func Sessions(manager *session.Manager) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			s, err := manager.Load(c.Request)
+			if err != nil {
+				c.Logger().Error("sessions: load failed", "error", err)
+			}
+			c.SetSession(s)
+
+			writer := &sessionWriter{ResponseWriter: c.Writer, save: func(w http.ResponseWriter) {
+				if err := manager.Save(w, c.Request, s); err != nil {
+					c.Logger().Error("sessions: save failed", "error", err)
+				}
+			}}
+			c.Writer = writer
+			defer func() { c.Writer = writer.ResponseWriter }()
+
+			next(c)
+			writer.commit()
+		}
+	}
+}
+
+// sessionWriter saves the session before the header is written
+type sessionWriter struct {
+	http.ResponseWriter
+	save      func(w http.ResponseWriter)
+	committed bool
+}
+
+// commit saves the session once
+func (w *sessionWriter) commit() {
+	if !w.committed {
+		w.committed = true
+		w.save(w.ResponseWriter)
+	}
+}
+
+// WriteHeader saves the session and writes the header
+func (w *sessionWriter) WriteHeader(status int) {
+	w.commit()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write saves the session and writes the body
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush saves the session and flushes the response
+func (w *sessionWriter) Flush() {
+	w.commit()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
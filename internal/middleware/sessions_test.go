@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/session"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	sessions := Sessions(session.NewManager(session.Config{}))
+	serve := func(cookie *http.Cookie, handler types.HandlerFunc) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		sessions(handler)(&types.Context{Request: r, Writer: w})
+		return w
+	}
+
+	// The cookie is set before the body is written
+	w := serve(nil, func(c *types.Context) {
+		c.Session().Set("cart", "3 items")
+		c.String(http.StatusOK, "added")
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	cookie := w.Result().Cookies()[0]
+	require.Equal(t, session.DefaultCookieName, cookie.Name)
+
+	w = serve(cookie, func(c *types.Context) {
+		c.String(http.StatusOK, c.Session().GetString("cart"))
+	})
+	require.Equal(t, "3 items", w.Body.String())
+
+	// and so are the changes of handlers without body
+	w = serve(cookie, func(c *types.Context) { c.Session().Destroy() })
+	require.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Tags returns a middleware tagging the requests of a group or route with
+// metadata, e.g. "team:payments" and "tier:critical", so that metrics and
+// spans can be sliced by ownership, see metrics.HTTP and
+// types.TagsFromContext
+//
+// Tags of a route are merged over the tags of its groups and the engine.
+// It panics on tags without a colon, since they are a programming error.
+func Tags(tags ...string) types.MiddlewareFunc {
+	parsed := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok || key == "" {
+			panic(fmt.Sprintf("middleware: invalid tag %q, expected key:value", tag))
+		}
+		parsed[key] = value
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.Request = c.Request.WithContext(types.WithTags(c.Request.Context(), parsed))
+			next(c)
+		}
+	}
+}
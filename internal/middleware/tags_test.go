@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	_, c, _ := serve(httptest.NewRequest(http.MethodGet, "/", nil),
+		Tags("team:platform", "tier:low"),
+		Tags("team:payments", "owner:alice"),
+	)
+	require.Equal(t, map[string]string{"team": "payments", "tier": "low", "owner": "alice"}, c.Tags())
+
+	require.Panics(t, func() { Tags("team") })
+}
@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultTimeout is the budget of the requests when TimeoutConfig.Timeout
+// is not set
+const DefaultTimeout = 30 * time.Second
+
+// ErrTimeout is the cause of the request contexts canceled by Timeout, see
+// context.Cause
+var ErrTimeout = errors.New("request timeout")
+
+// TimeoutConfig configures the Timeout middleware
+type TimeoutConfig struct {
+	// Timeout is the budget of the requests, DefaultTimeout if 0, and
+	// unlimited if negative, e.g. for streaming routes
+	Timeout time.Duration
+
+	// Status of the responses of the requests exceeding their budget,
+	// http.StatusServiceUnavailable if 0, e.g. http.StatusGatewayTimeout
+	// for handlers waiting on upstream services
+	Status int
+}
+
+// timeoutKey is the context key of the budget of a request
+type timeoutKey struct{}
+
+// Timeout returns a middleware canceling the context of the requests
+// exceeding their budget and answering them with 503 Service Unavailable
+//
+// The budget runs from the outermost Timeout, a Timeout of a group or a
+// route replacing it, e.g. to give long-running exports more time. The
+// rest of the chain runs in its own goroutine, with its response buffered
+// until it returns, so that the timeout response is never mixed with a
+// partial response. Once a handler flushes, its response is streamed and
+// the timeout only cancels the context. Handlers should return once the
+// context is canceled, their writes failing with http.ErrHandlerTimeout.
+func Timeout(config TimeoutConfig) types.MiddlewareFunc {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Status == 0 {
+		config.Status = http.StatusServiceUnavailable
+	}
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if budget, ok := c.Request.Context().Value(timeoutKey{}).(*timeoutBudget); ok {
+				budget.reset(config)
+				next(c)
+				return
+			}
+
+			ctx, cancel := context.WithCancelCause(c.Request.Context())
+			defer cancel(nil)
+			writer := &timeoutWriter{BufferedWriter: types.NewBufferedWriter(c.Writer), header: c.Writer.Header().Clone()}
+			budget := &timeoutBudget{start: time.Now(), cancel: cancel}
+			budget.reset(config)
+			defer budget.stop()
+
+			// The chain runs with a copy of the context, so that it can be
+			// left running once the request timed out
+			inner := *c
+			inner.Request = c.Request.WithContext(context.WithValue(ctx, timeoutKey{}, budget))
+			inner.Writer = writer
+
+			done := make(chan any, 1)
+			go func() {
+				defer func() { done <- recover() }()
+				next(&inner)
+			}()
+
+			select {
+			case p := <-done:
+				if p != nil {
+					panic(p)
+				}
+			case <-ctx.Done():
+				if errors.Is(context.Cause(ctx), ErrTimeout) && writer.timeout(budget.status()) {
+					return
+				}
+				// The client left or the response was streamed already, the
+				// handler must return before the request ends
+				if p := <-done; p != nil {
+					panic(p)
+				}
+			}
+
+			inner.Request = inner.Request.WithContext(c.Request.Context())
+			inner.Writer = c.Writer
+			*c = inner
+			writer.finish()
+		}
+	}
+}
+
+// timeoutBudget is the budget of a request, shared by its Timeout
+// middlewares
+type timeoutBudget struct {
+	start  time.Time
+	cancel context.CancelCauseFunc
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	config TimeoutConfig
+}
+
+// reset replaces the budget, still counted from the start of the request
+func (b *timeoutBudget) reset(config TimeoutConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.config = config
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if config.Timeout < 0 {
+		return
+	}
+	b.timer = time.AfterFunc(config.Timeout-time.Since(b.start), func() {
+		b.cancel(ErrTimeout)
+	})
+}
+
+// stop stops the timer of the budget
+func (b *timeoutBudget) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// status returns the status of the timeout response
+func (b *timeoutBudget) status() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.config.Status
+}
+
+// timeoutWriter buffers a response until the handler returns or flushes
+type timeoutWriter struct {
+	*types.BufferedWriter
+	header http.Header
+
+	mu       sync.Mutex
+	timedOut bool
+}
+
+// Header implements http.ResponseWriter
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut {
+		w.BufferedWriter.WriteHeader(status)
+	}
+}
+
+// Write implements http.ResponseWriter
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.BufferedWriter.Write(b)
+}
+
+// Flush implements http.Flusher, sending the buffered response and
+// streaming the rest of it
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if !w.Sent() {
+		w.copyHeader()
+	}
+	w.BufferedWriter.Flush()
+}
+
+// copyHeader replaces the header of ResponseWriter with the one of the
+// handler, with the lock held
+func (w *timeoutWriter) copyHeader() {
+	header := w.ResponseWriter.Header()
+	clear(header)
+	maps.Copy(header, w.header)
+}
+
+// finish sends the buffered response once the handler returned
+func (w *timeoutWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.Sent() {
+		// Left to the outer middlewares if nothing was written
+		w.copyHeader()
+		w.Send()
+	}
+}
+
+// timeout sends the timeout response, unless the response was streamed
+//
+// @return: false if the response was streamed already
+func (w *timeoutWriter) timeout(status int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Sent() {
+		return false
+	}
+	w.timedOut = true
+
+	c := &types.Context{Writer: w.ResponseWriter}
+	c.ErrorString(status, "request exceeded its time budget")
+	return true
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// serveTimeout serves a request with the middlewares around a handler
+// taking the duration, or until its context is canceled
+func serveTimeout(d time.Duration, middlewares ...types.MiddlewareFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w}
+	c.Execute(types.Chain(middlewares, func(c *types.Context) {
+		select {
+		case <-time.After(d):
+			c.String(http.StatusOK, "ok")
+		case <-c.Request.Context().Done():
+			c.String(http.StatusOK, "late")
+		}
+	}))
+	return w
+}
+
+func TestTimeout(t *testing.T) {
+	timeout := Timeout(TimeoutConfig{Timeout: 50 * time.Millisecond})
+
+	w := serveTimeout(0, timeout)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "ok", w.Body.String())
+
+	w = serveTimeout(time.Second, timeout)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "request exceeded its time budget")
+	require.NotContains(t, w.Body.String(), "late")
+}
+
+func TestTimeout_Override(t *testing.T) {
+	timeout := Timeout(TimeoutConfig{Timeout: 20 * time.Millisecond})
+
+	// A route timeout replaces the budget of the engine
+	long := Timeout(TimeoutConfig{Timeout: time.Second})
+	w := serveTimeout(50*time.Millisecond, timeout, long)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	short := Timeout(TimeoutConfig{Timeout: 10 * time.Millisecond, Status: http.StatusGatewayTimeout})
+	w = serveTimeout(time.Second, Timeout(TimeoutConfig{Timeout: time.Second}), short)
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	unlimited := Timeout(TimeoutConfig{Timeout: -1})
+	w = serveTimeout(50*time.Millisecond, timeout, unlimited)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTimeout_Streamed(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w}
+	var cause error
+	c.Execute(types.Chain([]types.MiddlewareFunc{Timeout(TimeoutConfig{Timeout: 20 * time.Millisecond})}, func(c *types.Context) {
+		c.String(http.StatusOK, "first")
+		http.NewResponseController(c.Writer).Flush()
+		<-c.Request.Context().Done()
+		cause = context.Cause(c.Request.Context())
+	}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "first", w.Body.String())
+	require.True(t, w.Flushed)
+	require.ErrorIs(t, cause, ErrTimeout)
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w}
+	require.PanicsWithValue(t, "boom", func() {
+		c.Execute(types.Chain([]types.MiddlewareFunc{Timeout(TimeoutConfig{})}, func(c *types.Context) {
+			panic("boom")
+		}))
+	})
+}
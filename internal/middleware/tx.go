@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// TxOpener begins the transaction of a request
+type TxOpener func(ctx context.Context) (types.Tx, error)
+
+// Tx returns a middleware running every request in a transaction, see
+// Context.Tx
+//
+// The transaction is committed when the handler returns with a 2xx
+// status, and rolled back otherwise, or when the handler panics. Requests
+// whose transaction cannot begin, or which fail to commit before anything
+// was written, are answered with 500 Internal Server Error. Failures
+// after the response started are logged.
+func Tx(opener TxOpener) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			ctx := c.Request.Context()
+			tx, err := opener(ctx)
+			if err != nil {
+				c.Logger().Error("tx: begin failed", "error", err)
+				c.Abort()
+				c.ErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+
+			writer, ok := c.Writer.(*types.ResponseWriter)
+			if !ok {
+				writer = types.NewResponseWriter(c.Writer)
+				c.Writer = writer
+				defer func() { c.Writer = writer.ResponseWriter }()
+			}
+			c.Request = c.Request.WithContext(types.WithTx(ctx, tx))
+
+			// Rollbacks must happen even when the client went away, failed
+			// commits end the transaction already
+			ended := false
+			defer func() {
+				if ended {
+					return
+				}
+				if err := tx.Rollback(context.WithoutCancel(ctx)); err != nil {
+					c.Logger().Error("tx: rollback failed", "error", err)
+				}
+			}()
+
+			next(c)
+
+			if status := writer.Status(); status < 200 || status > 299 || c.IsAborted() {
+				return
+			}
+			ended = true
+			if err := tx.Commit(ctx); err != nil {
+				c.Logger().Error("tx: commit failed", "error", err)
+				if !writer.Written() {
+					c.ErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				}
+			}
+		}
+	}
+}
+
+// SQLTx adapts a database/sql transaction to types.Tx, its other methods,
+// e.g. ExecContext, stay available
+//
+//	middleware.Tx(func(ctx context.Context) (types.Tx, error) {
+//		tx, err := db.BeginTx(ctx, nil)
+//		return middleware.SQLTx{Tx: tx}, err
+//	})
+type SQLTx struct {
+	*sql.Tx
+}
+
+// Commit implements types.Tx
+func (tx SQLTx) Commit(context.Context) error {
+	return tx.Tx.Commit()
+}
+
+// Rollback implements types.Tx
+func (tx SQLTx) Rollback(context.Context) error {
+	return tx.Tx.Rollback()
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTx records how a transaction ended
+type fakeTx struct {
+	ended     string
+	commitErr error
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.ended = "commit"
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	tx.ended = "rollback"
+	return nil
+}
+
+func TestTx(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	run := func(tx *fakeTx, handler types.HandlerFunc) *httptest.ResponseRecorder {
+		opener := func(context.Context) (types.Tx, error) { return tx, nil }
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: httptest.NewRequest(http.MethodPost, "/", nil), Writer: w}
+		c.Execute(types.Chain([]types.MiddlewareFunc{Tx(opener)}, handler))
+		return w
+	}
+
+	tx := &fakeTx{}
+	w := run(tx, func(c *types.Context) {
+		require.Same(t, tx, c.Tx())
+		got, ok := types.TxFromContext(c.Request.Context())
+		require.True(t, ok)
+		require.Same(t, tx, got)
+		c.String(http.StatusCreated, "ok")
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "commit", tx.ended)
+
+	tx = &fakeTx{}
+	run(tx, func(c *types.Context) { c.ErrorString(http.StatusBadRequest, "invalid") })
+	require.Equal(t, "rollback", tx.ended)
+
+	tx = &fakeTx{}
+	run(tx, func(c *types.Context) {})
+	require.Equal(t, "commit", tx.ended)
+
+	// Panics roll back and propagate
+	tx = &fakeTx{}
+	require.Panics(t, func() { run(tx, func(c *types.Context) { panic("boom") }) })
+	require.Equal(t, "rollback", tx.ended)
+
+	// Commit failures are reported when nothing was written
+	tx = &fakeTx{commitErr: errors.New("serialization failure")}
+	w = run(tx, func(c *types.Context) {})
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w}
+	failing := Tx(func(context.Context) (types.Tx, error) { return nil, errors.New("no connection") })
+	c.Execute(types.Chain([]types.MiddlewareFunc{failing}, func(c *types.Context) {
+		t.Fatal("handler reached")
+	}))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// LimitWarningHeader carries the soft limits a request went over, e.g.
+// "body-size; limit=1048576" or "rate-limit; limit=100; remaining=2", one
+// value per limit
+const LimitWarningHeader = "X-Limit-Warning"
+
+// Names of the soft limits, in LimitWarningHeader and LimitWarnings
+const (
+	LimitBodySize  = "body-size"
+	LimitRateLimit = "rate-limit"
+)
+
+// LimitWarnings counts the requests over the soft limits, let through
+// with a LimitWarningHeader, e.g. to tune the limits before enforcing them,
+// see metrics.LimitWarnings
+type LimitWarnings struct {
+	counts sync.Map // limit name -> *atomic.Uint64
+}
+
+// DefaultLimitWarnings counts the warnings of the limits configured
+// without their own LimitWarnings
+var DefaultLimitWarnings = &LimitWarnings{}
+
+// Counts returns the number of warnings, by limit name
+func (w *LimitWarnings) Counts() map[string]uint64 {
+	counts := make(map[string]uint64)
+	w.counts.Range(func(name, count any) bool {
+		counts[name.(string)] = count.(*atomic.Uint64).Load()
+		return true
+	})
+	return counts
+}
+
+// warn counts a warning of the limit and adds its header to the response
+func (w *LimitWarnings) warn(c *types.Context, name, value string) {
+	count, _ := w.counts.LoadOrStore(name, &atomic.Uint64{})
+	count.(*atomic.Uint64).Add(1)
+	c.Writer.Header().Add(LimitWarningHeader, value)
+}
+
+// limitWarnings returns the warnings, DefaultLimitWarnings if nil
+func limitWarnings(w *LimitWarnings) *LimitWarnings {
+	if w == nil {
+		return DefaultLimitWarnings
+	}
+	return w
+}
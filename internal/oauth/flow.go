@@ -0,0 +1,306 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/jwt"
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// pendingLogin is a login waiting for the callback, keyed by its state
+type pendingLogin struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	ReturnTo string `json:"return_to"`
+}
+
+// tokenResponse is the response of the token endpoint
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Error        string `json:"error"`
+}
+
+// Login returns the handler redirecting the users to the provider, back
+// to the path of the return_to query parameter once signed in
+//
+// The hash of the state is set in a cookie bound to the browser starting
+// the login, so that the callback cannot complete a login started by
+// someone else, see RFC 6749 section 10.12.
+func (p *Provider) Login() types.HandlerFunc {
+	return func(c *types.Context) {
+		login := pendingLogin{Verifier: randomString(), Nonce: randomString(), ReturnTo: types.LocalPath(c.GetQuery("return_to"))}
+		state := randomString()
+		data, _ := json.Marshal(login)
+		if err := p.config.Store.Set(c.Request.Context(), "oauth:state:"+state, data, DefaultLoginTimeout); err != nil {
+			c.Logger().Error("oauth: storing the login failed", "error", err)
+			c.ErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     p.stateCookie(),
+			Value:    stateHash(state),
+			Path:     p.callbackPath,
+			MaxAge:   int(DefaultLoginTimeout / time.Second),
+			Secure:   strings.HasPrefix(p.config.RedirectURL, "https://"),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		challenge := sha256.Sum256([]byte(login.Verifier))
+		query := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {p.config.ClientID},
+			"redirect_uri":          {p.config.RedirectURL},
+			"state":                 {state},
+			"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+			"code_challenge_method": {"S256"},
+		}
+		if len(p.config.Scopes) > 0 {
+			query.Set("scope", strings.Join(p.config.Scopes, " "))
+		}
+		if slices.Contains(p.config.Scopes, "openid") {
+			query.Set("nonce", login.Nonce)
+		}
+		separator := "?"
+		if strings.Contains(p.config.AuthURL, "?") {
+			separator = "&"
+		}
+		c.Redirect(http.StatusFound, p.config.AuthURL+separator+query.Encode())
+	}
+}
+
+// Callback returns the handler exchanging the code sent back by the
+// provider, starting the session and redirecting the user where the login
+// started
+func (p *Provider) Callback() types.HandlerFunc {
+	return func(c *types.Context) {
+		session, returnTo, err := p.callback(c)
+		if err != nil {
+			c.Logger().Warn("oauth: callback failed", "error", err)
+			c.ErrorString(http.StatusUnauthorized, "login failed")
+			return
+		}
+
+		id := randomString()
+		data, _ := json.Marshal(session)
+		if err := p.config.Store.Set(c.Request.Context(), "oauth:session:"+id, data, p.config.SessionTTL); err != nil {
+			c.Logger().Error("oauth: storing the session failed", "error", err)
+			c.ErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     p.config.CookieName,
+			Value:    id,
+			Path:     "/",
+			MaxAge:   int(p.config.SessionTTL / time.Second),
+			Secure:   strings.HasPrefix(p.config.RedirectURL, "https://"),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		c.Redirect(http.StatusFound, returnTo)
+	}
+}
+
+// Logout returns the handler ending the session of the user
+func (p *Provider) Logout() types.HandlerFunc {
+	return func(c *types.Context) {
+		if id, err := c.GetCookie(p.config.CookieName); err == nil {
+			if err := p.config.Store.Delete(c.Request.Context(), "oauth:session:"+id); err != nil {
+				c.Logger().Error("oauth: deleting the session failed", "error", err)
+			}
+		}
+		http.SetCookie(c.Writer, &http.Cookie{Name: p.config.CookieName, Path: "/", MaxAge: -1, HttpOnly: true})
+		c.Redirect(http.StatusSeeOther, types.LocalPath(c.GetQuery("return_to")))
+	}
+}
+
+// callback checks the state of the callback and exchanges its code
+//
+// @return: the session of the user and where to redirect them
+func (p *Provider) callback(c *types.Context) (*Session, string, error) {
+	ctx := c.Request.Context()
+	if e := c.GetQuery("error"); e != "" {
+		return nil, "", fmt.Errorf("%w: %s", ErrLogin, e)
+	}
+
+	// The state must be the one of the login started by the browser
+	state := c.GetQuery("state")
+	cookie, err := c.GetCookie(p.stateCookie())
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(stateHash(state))) != 1 {
+		return nil, "", fmt.Errorf("%w: state mismatch", ErrLogin)
+	}
+	http.SetCookie(c.Writer, &http.Cookie{Name: p.stateCookie(), Path: p.callbackPath, MaxAge: -1, HttpOnly: true})
+
+	// The state is single use
+	key := "oauth:state:" + state
+	data, err := p.config.Store.Get(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, "", fmt.Errorf("%w: unknown state", ErrLogin)
+	} else if err != nil {
+		return nil, "", err
+	}
+	if err := p.config.Store.Delete(ctx, key); err != nil {
+		return nil, "", err
+	}
+	var login pendingLogin
+	if err := json.Unmarshal(data, &login); err != nil {
+		return nil, "", err
+	}
+
+	token, err := p.exchange(ctx, c.GetQuery("code"), login.Verifier)
+	if err != nil {
+		return nil, "", err
+	}
+	session := &Session{AccessToken: token.AccessToken, RefreshToken: token.RefreshToken, Claims: jwt.Claims{}}
+	if token.ExpiresIn > 0 {
+		session.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.IDToken != "" {
+		if session.Claims, err = p.idTokenClaims(token.IDToken, login.Nonce); err != nil {
+			return nil, "", err
+		}
+	}
+	if p.config.UserInfoURL != "" {
+		if err := p.userInfo(ctx, session); err != nil {
+			return nil, "", err
+		}
+	}
+	return session, login.ReturnTo, nil
+}
+
+// exchange exchanges the code for the tokens at the token endpoint
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		r.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.config.Client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("token endpoint: status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint: status %d: %s", ErrLogin, resp.StatusCode, token.Error)
+	}
+	return &token, nil
+}
+
+// idTokenClaims returns the claims of an ID token received from the token
+// endpoint, whose signature need not be verified since it comes straight
+// from the provider over TLS, see OpenID Connect Core section 3.1.3.7
+func (p *Provider) idTokenClaims(token, nonce string) (jwt.Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, jwt.ErrMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, jwt.ErrMalformed
+	}
+	var claims jwt.Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, jwt.ErrMalformed
+	}
+
+	switch exp, ok := claims.Time("exp"); {
+	case p.config.Issuer != "" && claims.Issuer() != p.config.Issuer:
+		return nil, jwt.ErrInvalidIssuer
+	case !slices.Contains(claims.Audience(), p.config.ClientID):
+		return nil, jwt.ErrInvalidAudience
+	case !ok:
+		// exp is required in ID tokens, see OpenID Connect Core section 2
+		return nil, fmt.Errorf("%w: invalid exp claim", jwt.ErrMalformed)
+	case time.Now().After(exp):
+		return nil, jwt.ErrExpired
+	case claims.String("nonce") != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrLogin)
+	}
+	return claims, nil
+}
+
+// userInfo adds the claims of the userinfo endpoint to the session
+func (p *Provider) userInfo(ctx context.Context, session *Session) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.UserInfoURL, nil)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	r.Header.Set("Accept", "application/json")
+	resp, err := p.config.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("userinfo endpoint: status %d", resp.StatusCode)
+	}
+	var claims jwt.Claims
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&claims); err != nil {
+		return fmt.Errorf("userinfo endpoint: %w", err)
+	}
+	// The subject of the ID token must match, see OpenID Connect Core
+	// section 5.3.2
+	if sub := session.Claims.Subject(); sub != "" && claims.Subject() != sub {
+		return fmt.Errorf("%w: userinfo subject mismatch", ErrLogin)
+	}
+	for name, value := range claims {
+		session.Claims[name] = value
+	}
+	return nil
+}
+
+// stateCookie returns the name of the cookie carrying the hash of the
+// state of a login
+func (p *Provider) stateCookie() string {
+	return p.config.CookieName + "_state"
+}
+
+// stateHash returns the hash of a state, kept in the state cookie
+func stateHash(state string) string {
+	hash := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// randomString returns 32 random bytes in base64url, for states, nonces,
+// verifiers and session ids
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,154 @@
+// Package oauth signs users in with an OAuth 2.0 or OpenID Connect
+// provider, "login with X", with the authorization code flow and PKCE, see
+// RFC 6749 and RFC 7636, keeping the signed-in users in sessions
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Defaults of the provider configuration
+const (
+	DefaultLoginPath    = "/auth/login"
+	DefaultLogoutPath   = "/auth/logout"
+	DefaultCookieName   = "session"
+	DefaultSessionTTL   = 24 * time.Hour
+	DefaultLoginTimeout = 10 * time.Minute
+)
+
+// ErrLogin is returned when the provider did not sign the user in, e.g.
+// the user denied access or the state expired
+var ErrLogin = errors.New("oauth: login failed")
+
+// Endpoints are the endpoints of a provider
+type Endpoints struct {
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"` // optional
+	Issuer      string `json:"issuer"`            // checked in ID tokens if set
+}
+
+// Discover fetches the endpoints of an OpenID Connect provider from its
+// discovery document, e.g. Discover(ctx, "https://accounts.google.com", nil)
+func Discover(ctx context.Context, issuer string, client *http.Client) (Endpoints, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var endpoints Endpoints
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return endpoints, err
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return endpoints, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return endpoints, fmt.Errorf("oauth: discovery of %s: status %d", issuer, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&endpoints); err != nil {
+		return endpoints, fmt.Errorf("oauth: discovery of %s: %w", issuer, err)
+	}
+	if endpoints.Issuer != strings.TrimSuffix(issuer, "/") && endpoints.Issuer != issuer {
+		return endpoints, fmt.Errorf("oauth: discovery of %s: issuer %q", issuer, endpoints.Issuer)
+	}
+	return endpoints, nil
+}
+
+// Config configures a Provider
+type Config struct {
+	Endpoints
+
+	// Credentials of the client registered with the provider
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the absolute URL of the callback registered with the
+	// provider, whose path is routed to the callback handler
+	RedirectURL string
+
+	// Scopes requested, e.g. "openid", "email"
+	Scopes []string
+
+	// Paths starting and ending the sessions, DefaultLoginPath and
+	// DefaultLogoutPath if empty
+	LoginPath  string
+	LogoutPath string
+
+	// Store keeps the pending logins and the sessions, a store.Memory with
+	// the default configuration if nil
+	Store store.Store
+
+	// Cookie carrying the session id, DefaultCookieName if empty. The
+	// pending logins use the cookie of the same name suffixed with _state.
+	CookieName string
+
+	// SessionTTL is how long sessions last, DefaultSessionTTL if 0
+	SessionTTL time.Duration
+
+	// Client exchanging the codes, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Provider signs users in with an OAuth 2.0 or OpenID Connect provider
+type Provider struct {
+	config       Config
+	callbackPath string
+}
+
+// Route is a route of a provider, registered with Engine.OAuth
+type Route struct {
+	Method  string
+	Path    string
+	Handler types.HandlerFunc
+}
+
+// New creates a provider with the configuration
+func New(config Config) (*Provider, error) {
+	if config.AuthURL == "" || config.TokenURL == "" || config.ClientID == "" {
+		return nil, errors.New("oauth: endpoints and client id are required")
+	}
+	redirect, err := url.Parse(config.RedirectURL)
+	if err != nil || !redirect.IsAbs() {
+		return nil, fmt.Errorf("oauth: redirect url %q is not absolute", config.RedirectURL)
+	}
+	if config.LoginPath == "" {
+		config.LoginPath = DefaultLoginPath
+	}
+	if config.LogoutPath == "" {
+		config.LogoutPath = DefaultLogoutPath
+	}
+	if config.Store == nil {
+		config.Store = store.NewMemory(store.MemoryConfig{})
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCookieName
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = DefaultSessionTTL
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &Provider{config: config, callbackPath: redirect.Path}, nil
+}
+
+// Routes returns the login, callback and logout routes of the provider
+func (p *Provider) Routes() []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: p.config.LoginPath, Handler: p.Login()},
+		{Method: http.MethodGet, Path: p.callbackPath, Handler: p.Callback()},
+		{Method: http.MethodPost, Path: p.config.LogoutPath, Handler: p.Logout()},
+	}
+}
@@ -0,0 +1,187 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/jwt"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider is an OpenID Connect provider signing in "ada" right away
+func fakeProvider(t *testing.T) *httptest.Server {
+	var challenge, nonce string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		require.Equal(t, "S256", query.Get("code_challenge_method"))
+		challenge, nonce = query.Get("code_challenge"), query.Get("nonce")
+		http.Redirect(w, r, query.Get("redirect_uri")+"?code=the-code&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if id != "client" || secret != "secret" || r.PostFormValue("code") != "the-code" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		token, err := jwt.Sign(jwt.Claims{
+			"iss": "https://issuer", "aud": "client", "sub": "ada", "nonce": nonce,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}, &jwt.Key{Algorithm: jwt.HS256, Secret: []byte("provider")})
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "access", "id_token": token, "expires_in": 3600})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"sub":"ada","email":"ada@example.com"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serve(handler types.HandlerFunc, method, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Accept", "text/html")
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler(&types.Context{Request: r, Writer: w})
+	return w
+}
+
+func TestProvider(t *testing.T) {
+	server := fakeProvider(t)
+	provider, err := New(Config{
+		Endpoints:    Endpoints{AuthURL: server.URL + "/authorize", TokenURL: server.URL + "/token", UserInfoURL: server.URL + "/userinfo", Issuer: "https://issuer"},
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.example.com/auth/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultLoginPath, "/auth/callback", DefaultLogoutPath},
+		[]string{provider.Routes()[0].Path, provider.Routes()[1].Path, provider.Routes()[2].Path})
+
+	var session *Session
+	protected := provider.Require()(func(c *types.Context) {
+		session, _ = FromContext(c)
+		c.String(http.StatusOK, "secret page")
+	})
+
+	// Browsers without session are sent to the login, APIs rejected
+	w := serve(protected, http.MethodGet, "/account?tab=1")
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "/auth/login?return_to=%2Faccount%3Ftab%3D1", w.Header().Get("Location"))
+	w = serve(protected, http.MethodPost, "/account")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The login redirects to the provider, which redirects to the callback
+	w = serve(provider.Login(), http.MethodGet, "/auth/login?return_to=%2Faccount%3Ftab%3D1")
+	require.Equal(t, http.StatusFound, w.Code)
+	state := w.Result().Cookies()[0]
+	require.Equal(t, DefaultCookieName+"_state", state.Name)
+	require.Equal(t, "/auth/callback", state.Path)
+	require.True(t, state.HttpOnly)
+	require.Equal(t, http.SameSiteLaxMode, state.SameSite)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(w.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "/auth/callback", callback.Path)
+
+	// The callback requires the state cookie of the browser starting the
+	// login
+	w = serve(provider.Callback(), http.MethodGet, callback.RequestURI())
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serve(provider.Callback(), http.MethodGet, callback.RequestURI(), &http.Cookie{Name: state.Name, Value: "other"})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve(provider.Callback(), http.MethodGet, callback.RequestURI(), state)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	require.Equal(t, "/account?tab=1", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	require.Equal(t, state.Name, cookies[0].Name)
+	require.Equal(t, -1, cookies[0].MaxAge)
+	cookie := cookies[1]
+	require.Equal(t, DefaultCookieName, cookie.Name)
+	require.True(t, cookie.HttpOnly)
+	require.True(t, cookie.Secure)
+
+	// The state is single use
+	w = serve(provider.Callback(), http.MethodGet, callback.RequestURI(), state)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The session carries the claims of the ID token and the userinfo
+	w = serve(protected, http.MethodGet, "/account", cookie)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "ada", session.Claims.Subject())
+	require.Equal(t, "ada@example.com", session.Claims.String("email"))
+	require.Equal(t, "access", session.AccessToken)
+
+	// until the logout
+	w = serve(provider.Logout(), http.MethodPost, "/auth/logout", cookie)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	w = serve(protected, http.MethodPost, "/account", cookie)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestReturnTo(t *testing.T) {
+	provider, err := New(Config{
+		Endpoints:   Endpoints{AuthURL: "https://issuer/authorize", TokenURL: "https://issuer/token"},
+		ClientID:    "client",
+		RedirectURL: "https://app.example.com/auth/callback",
+	})
+	require.NoError(t, err)
+
+	// The login and the logout only redirect to local paths
+	for returnTo, location := range map[string]string{
+		"%2Faccount%3Ftab%3D1":        "/account?tab=1",
+		"":                            "/",
+		"https%3A%2F%2Fevil.com":      "/",
+		"%2F%2Fevil.com":              "/",
+		"%2F%5Cevil.com":              "/",
+		"%2F%09%2Fevil.com":           "/",
+		"%2F%0A%2Fevil.com":           "/",
+		"%2Faccount%0D%0ASet-Cookie:": "/",
+	} {
+		w := serve(provider.Logout(), http.MethodGet, "/auth/logout?return_to="+returnTo)
+		require.Equal(t, location, w.Header().Get("Location"), returnTo)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+		})
+	}))
+	defer server.Close()
+
+	endpoints, err := Discover(t.Context(), server.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, Endpoints{AuthURL: server.URL + "/authorize", TokenURL: server.URL + "/token", Issuer: server.URL}, endpoints)
+
+	_, err = Discover(t.Context(), server.URL+"/other", nil)
+	require.Error(t, err)
+}
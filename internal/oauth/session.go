@@ -0,0 +1,81 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/apictx"
+	"github.com/skjdfhkskjds/go-api/internal/jwt"
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// SessionKey is the key of the session of the signed-in requests, see
+// Context.Get and apictx.Get
+var SessionKey = apictx.NewKey[*Session]("oauth.session")
+
+// Session is the session of a signed-in user
+type Session struct {
+	// Claims identifying the user, from the ID token and the userinfo
+	// endpoint, e.g. "sub" and "email"
+	Claims jwt.Claims `json:"claims"`
+
+	// Tokens granted by the provider, e.g. to call its APIs on behalf of
+	// the user
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+}
+
+// FromContext returns the session of the request, set by Provider.Require
+func FromContext(c *types.Context) (*Session, bool) {
+	return apictx.Get(c, SessionKey)
+}
+
+// Require returns a middleware rejecting the requests without session,
+// e.g. for a group of routes
+//
+// Browsers navigating to a page are redirected to the login path, coming
+// back to the page once signed in, other requests are answered with 401
+// Unauthorized.
+func (p *Provider) Require() types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			session, err := p.session(c)
+			if err != nil {
+				if !errors.Is(err, store.ErrNotFound) && !errors.Is(err, http.ErrNoCookie) {
+					c.Logger().Error("oauth: session failed", "error", err)
+				}
+				c.Abort()
+				if c.Request.Method == http.MethodGet && c.Accepts("text/html", "application/json") == "text/html" {
+					c.Redirect(http.StatusFound, p.config.LoginPath+"?return_to="+url.QueryEscape(c.Request.URL.RequestURI()))
+					return
+				}
+				c.ErrorString(http.StatusUnauthorized, "login required")
+				return
+			}
+			apictx.Set(c, SessionKey, session)
+			next(c)
+		}
+	}
+}
+
+// session loads the session of the request
+func (p *Provider) session(c *types.Context) (*Session, error) {
+	id, err := c.GetCookie(p.config.CookieName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.config.Store.Get(c.Request.Context(), "oauth:session:"+id)
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
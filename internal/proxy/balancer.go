@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Balancer selects the upstream serving a request
+//
+// Upstreams are passed in configuration order and are never empty. Ejected
+// upstreams are only passed when every upstream is ejected.
+type Balancer interface {
+	Pick(r *http.Request, upstreams []*Upstream) *Upstream
+}
+
+// HashKey extracts the key requests are hashed by, an empty key falls back
+// to round-robin
+type HashKey func(r *http.Request) string
+
+// RoundRobin returns a balancer cycling through the upstreams
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+// Pick implements Balancer
+func (b *roundRobin) Pick(r *http.Request, upstreams []*Upstream) *Upstream {
+	return upstreams[(b.next.Add(1)-1)%uint64(len(upstreams))]
+}
+
+// LeastConnections returns a balancer selecting the upstream with the
+// fewest requests in flight, the earliest one on ties
+func LeastConnections() Balancer {
+	return leastConnections{}
+}
+
+type leastConnections struct{}
+
+// Pick implements Balancer
+func (leastConnections) Pick(r *http.Request, upstreams []*Upstream) *Upstream {
+	best := upstreams[0]
+	for _, upstream := range upstreams[1:] {
+		if upstream.Active() < best.Active() {
+			best = upstream
+		}
+	}
+	return best
+}
+
+// ConsistentHash returns a balancer sending requests with the same key to
+// the same upstream, e.g. for sticky sessions
+//
+// Rendezvous hashing is used, so adding or ejecting an upstream only moves
+// the keys of that upstream.
+func ConsistentHash(key HashKey) Balancer {
+	return &consistentHash{key: key, fallback: RoundRobin()}
+}
+
+type consistentHash struct {
+	key      HashKey
+	fallback Balancer
+}
+
+// Pick implements Balancer
+func (b *consistentHash) Pick(r *http.Request, upstreams []*Upstream) *Upstream {
+	key := b.key(r)
+	if key == "" {
+		return b.fallback.Pick(r, upstreams)
+	}
+
+	var best *Upstream
+	var bestScore uint64
+	for _, upstream := range upstreams {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(upstream.URL.String()))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = upstream, score
+		}
+	}
+	return best
+}
+
+// HashCookie hashes requests by the value of a cookie
+func HashCookie(name string) HashKey {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// HashHeader hashes requests by the value of a header
+func HashHeader(name string) HashKey {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// HashClientIP hashes requests by the IP of the client, read from the
+// forwarding headers of the trusted proxies, e.g. Engine.TrustedProxies,
+// so that the clients behind a load balancer are not all hashed alike.
+// Without trusted proxies, the address of the connected client is used.
+func HashClientIP(proxies *types.TrustedProxies) HashKey {
+	return func(r *http.Request) string {
+		return proxies.ClientIP(r)
+	}
+}
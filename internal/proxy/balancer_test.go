@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpstreams(n int) []*Upstream {
+	upstreams := make([]*Upstream, n)
+	for i := range upstreams {
+		upstreams[i] = &Upstream{URL: &url.URL{Scheme: "http", Host: fmt.Sprintf("10.0.0.%d:80", i+1)}}
+	}
+	return upstreams
+}
+
+func TestRoundRobin(t *testing.T) {
+	upstreams := newUpstreams(3)
+	balancer := RoundRobin()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := range 6 {
+		require.Same(t, upstreams[i%3], balancer.Pick(r, upstreams))
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	upstreams := newUpstreams(3)
+	upstreams[0].active.Store(2)
+	upstreams[1].active.Store(1)
+	upstreams[2].active.Store(1)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.Same(t, upstreams[1], LeastConnections().Pick(r, upstreams))
+}
+
+func TestConsistentHash(t *testing.T) {
+	upstreams := newUpstreams(5)
+	balancer := ConsistentHash(HashCookie("session"))
+
+	pick := func(session string, upstreams []*Upstream) *Upstream {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: "session", Value: session})
+		return balancer.Pick(r, upstreams)
+	}
+
+	// Sessions stick to their upstream, and removing an upstream only
+	// moves the sessions it served
+	moved := 0
+	for i := range 200 {
+		session := fmt.Sprint("session-", i)
+		before := pick(session, upstreams)
+		require.Same(t, before, pick(session, upstreams))
+
+		after := pick(session, upstreams[1:])
+		if before != upstreams[0] {
+			require.Same(t, before, after)
+		} else {
+			moved++
+		}
+	}
+	require.Greater(t, moved, 0)
+	require.Less(t, moved, 100)
+
+	// Requests without the cookie are balanced round-robin
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NotSame(t, balancer.Pick(r, upstreams), balancer.Pick(r, upstreams))
+
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	require.Equal(t, "192.0.2.1", HashClientIP(nil)(r))
+	proxies, err := types.ParseTrustedProxies([]string{"192.0.2.0/24"}, "")
+	require.NoError(t, err)
+	require.Equal(t, "203.0.113.9", HashClientIP(proxies)(r))
+	r.Header.Set("X-User", "42")
+	require.Equal(t, "42", HashHeader("X-User")(r))
+}
+
+func TestProxy_PassiveHealth(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("healthy"))
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	p, err := New(Config{
+		Targets:  []string{failing.URL, healthy.URL},
+		Balancer: LeastConnections(),
+		Health:   HealthConfig{MaxFails: 2, EjectDuration: time.Minute},
+		ErrorLog: log.New(io.Discard, "", 0),
+	})
+	require.NoError(t, err)
+
+	serve := func() int {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	// Least connections always prefers the first upstream while idle,
+	// until it is ejected
+	require.Equal(t, http.StatusServiceUnavailable, serve())
+	require.Equal(t, http.StatusServiceUnavailable, serve())
+	require.Equal(t, http.StatusOK, serve())
+	require.Equal(t, http.StatusOK, serve())
+
+	stats := p.Upstreams()
+	require.False(t, stats[0].Healthy)
+	require.Equal(t, uint64(2), stats[0].Requests)
+	require.Equal(t, uint64(2), stats[0].Failures)
+	require.Equal(t, uint64(1), stats[0].Ejections)
+	require.True(t, stats[1].Healthy)
+	require.Equal(t, uint64(2), stats[1].Requests)
+	require.Zero(t, stats[1].Failures)
+	require.Zero(t, stats[1].Active)
+
+	_, err = New(Config{})
+	require.ErrorIs(t, err, ErrNoTargets)
+}
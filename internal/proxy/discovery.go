@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDiscoveryInterval is the interval between DNS resolutions when
+// DiscoveryConfig.Interval is not set
+const DefaultDiscoveryInterval = 30 * time.Second
+
+// Resolver looks up DNS records, implemented by net.Resolver
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DiscoveryConfig configures the resolution of upstreams from DNS, e.g.
+// from the records of a Kubernetes headless service
+type DiscoveryConfig struct {
+	// Name to resolve, e.g. api.default.svc.cluster.local for A and AAAA
+	// records, or _http._tcp.api.default.svc.cluster.local for SRV records
+	Name string
+
+	// SRV resolves SRV records, whose targets and ports are the upstreams.
+	// Only the records with the lowest priority are used.
+	SRV bool
+
+	// Scheme of the upstream URLs, http if empty
+	Scheme string
+
+	// Port of the upstreams resolved from A and AAAA records, the default
+	// port of the scheme if 0
+	Port int
+
+	// Interval between resolutions, DefaultDiscoveryInterval if 0
+	Interval time.Duration
+
+	// Resolver performing the lookups, net.DefaultResolver if nil
+	Resolver Resolver
+}
+
+// discoverer keeps the discovered upstreams of a proxy in sync with DNS
+//
+// Upstreams whose records disappear stop receiving new requests and are
+// drained: their requests in flight complete, after which their idle
+// connections are closed.
+type discoverer struct {
+	proxy     *Proxy
+	config    DiscoveryConfig
+	transport http.RoundTripper
+
+	mu         sync.Mutex
+	discovered []*Upstream
+	draining   []*Upstream
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startDiscovery resolves the upstreams and keeps re-resolving them until
+// stopped
+//
+// @return: the discoverer
+// @return: an error if the initial resolution failed
+func startDiscovery(p *Proxy, config DiscoveryConfig, transport http.RoundTripper) (*discoverer, error) {
+	if config.Scheme == "" {
+		config.Scheme = "http"
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultDiscoveryInterval
+	}
+	if config.Resolver == nil {
+		config.Resolver = net.DefaultResolver
+	}
+
+	d := &discoverer{
+		proxy:     p,
+		config:    config,
+		transport: transport,
+		done:      make(chan struct{}),
+	}
+	if err := d.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.run(ctx)
+	return d, nil
+}
+
+// stop stops the resolutions
+func (d *discoverer) stop() {
+	d.cancel()
+	<-d.done
+}
+
+// run re-resolves the upstreams every interval until the context is
+// canceled, keeping the current upstreams when a resolution fails
+func (d *discoverer) run(ctx context.Context) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := d.refresh(ctx); err != nil && ctx.Err() == nil {
+			d.proxy.logger.Printf("proxy: resolving %s: %v", d.config.Name, err)
+		}
+	}
+}
+
+// refresh resolves the upstreams and replaces the discovered ones
+func (d *discoverer) refresh(ctx context.Context) error {
+	hosts, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Keep the upstreams still resolved, so that their metrics and health
+	// survive the refresh
+	existing := make(map[string]*Upstream, len(d.discovered))
+	for _, upstream := range d.discovered {
+		existing[upstream.URL.Host] = upstream
+	}
+
+	discovered := make([]*Upstream, 0, len(hosts))
+	for _, host := range hosts {
+		upstream, ok := existing[host]
+		if !ok {
+			upstream = &Upstream{URL: &url.URL{Scheme: d.config.Scheme, Host: host}}
+		}
+		delete(existing, host)
+		discovered = append(discovered, upstream)
+	}
+	for _, upstream := range existing {
+		d.draining = append(d.draining, upstream)
+	}
+	d.discovered = discovered
+
+	upstreams := slices.Concat(d.proxy.static, discovered)
+	d.proxy.upstreams.Store(&upstreams)
+
+	d.drain()
+	return nil
+}
+
+// drain forgets the draining upstreams without requests in flight and
+// closes the idle connections to them
+func (d *discoverer) drain() {
+	n := len(d.draining)
+	d.draining = slices.DeleteFunc(d.draining, func(u *Upstream) bool {
+		return u.Active() == 0
+	})
+	if len(d.draining) == n {
+		return
+	}
+
+	if closer, ok := d.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// drainingUpstreams returns the upstreams removed from DNS with requests
+// still in flight
+func (d *discoverer) drainingUpstreams() []*Upstream {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.draining)
+}
+
+// resolve looks up the upstream addresses
+//
+// @return: the sorted host:port addresses
+// @return: an error if the lookup failed
+func (d *discoverer) resolve(ctx context.Context) ([]string, error) {
+	var hosts []string
+
+	if d.config.SRV {
+		_, records, err := d.config.Resolver.LookupSRV(ctx, "", "", d.config.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if record.Priority != records[0].Priority {
+				continue
+			}
+			host := strings.TrimSuffix(record.Target, ".")
+			hosts = append(hosts, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+		}
+	} else {
+		addrs, err := d.config.Resolver.LookupHost(ctx, d.config.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		port := d.config.Port
+		if port == 0 {
+			port = 80
+			if d.config.Scheme == "https" {
+				port = 443
+			}
+		}
+		for _, addr := range addrs {
+			hosts = append(hosts, net.JoinHostPort(addr, strconv.Itoa(port)))
+		}
+	}
+
+	slices.Sort(hosts)
+	return slices.Compact(hosts), nil
+}
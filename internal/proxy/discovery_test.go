@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver answers lookups from records that can be replaced
+type fakeResolver struct {
+	mu    sync.Mutex
+	hosts []string
+	srv   []*net.SRV
+	err   error
+}
+
+func (r *fakeResolver) set(hosts []string, srv []*net.SRV, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hosts, r.srv, r.err = hosts, srv, err
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts, r.err
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return name, r.srv, r.err
+}
+
+// hosts returns the hosts of the upstreams
+func hosts(upstreams []*Upstream) []string {
+	hosts := make([]string, len(upstreams))
+	for i, upstream := range upstreams {
+		hosts[i] = upstream.URL.Host
+	}
+	return hosts
+}
+
+func TestDiscovery_Records(t *testing.T) {
+	resolver := &fakeResolver{}
+	resolver.set([]string{"10.0.0.2", "10.0.0.1", "10.0.0.2"}, nil, nil)
+
+	p, err := New(Config{
+		Target:    "http://static:8080",
+		Discovery: &DiscoveryConfig{Name: "api.svc", Port: 9000, Resolver: resolver},
+	})
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, []string{"static:8080", "10.0.0.1:9000", "10.0.0.2:9000"}, hosts(p.current()))
+
+	resolver.set(nil, []*net.SRV{
+		{Target: "api-1.api.svc.", Port: 8443, Priority: 1},
+		{Target: "api-0.api.svc.", Port: 8443, Priority: 1},
+		{Target: "backup.api.svc.", Port: 8443, Priority: 2},
+	}, nil)
+	srv, err := New(Config{
+		Discovery: &DiscoveryConfig{Name: "_https._tcp.api.svc", SRV: true, Scheme: "https", Resolver: resolver},
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+	require.Equal(t, []string{"api-0.api.svc:8443", "api-1.api.svc:8443"}, hosts(srv.current()))
+	require.Equal(t, "https", srv.current()[0].URL.Scheme)
+}
+
+func TestDiscovery_Refresh(t *testing.T) {
+	resolver := &fakeResolver{}
+	resolver.set([]string{"10.0.0.1", "10.0.0.2"}, nil, nil)
+
+	p, err := New(Config{
+		Discovery: &DiscoveryConfig{Name: "api.svc", Interval: time.Hour, Resolver: resolver},
+		ErrorLog:  log.New(io.Discard, "", 0),
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	kept, removed := p.current()[0], p.current()[1]
+	kept.requests.Add(5)
+	removed.active.Add(1) // a request in flight
+
+	// 10.0.0.2 disappears, 10.0.0.3 appears
+	resolver.set([]string{"10.0.0.3", "10.0.0.1"}, nil, nil)
+	require.NoError(t, p.discoverer.refresh(context.Background()))
+	require.Equal(t, []string{"10.0.0.1:80", "10.0.0.3:80"}, hosts(p.current()))
+	require.Same(t, kept, p.current()[0])
+	require.Equal(t, []*Upstream{removed}, p.discoverer.drainingUpstreams())
+	require.Len(t, p.Upstreams(), 3)
+
+	// Failed resolutions keep the current upstreams
+	resolver.set(nil, nil, errors.New("no such host"))
+	require.Error(t, p.discoverer.refresh(context.Background()))
+	require.Len(t, p.current(), 2)
+
+	// The draining upstream is forgotten once its request completed
+	removed.active.Add(-1)
+	resolver.set([]string{"10.0.0.1", "10.0.0.3"}, nil, nil)
+	require.NoError(t, p.discoverer.refresh(context.Background()))
+	require.Empty(t, p.discoverer.drainingUpstreams())
+}
+
+func TestDiscovery_Errors(t *testing.T) {
+	resolver := &fakeResolver{}
+	resolver.set(nil, nil, errors.New("no such host"))
+
+	_, err := New(Config{Discovery: &DiscoveryConfig{Name: "api.svc", Resolver: resolver}})
+	require.Error(t, err)
+
+	// Requests are rejected while no record is resolved
+	resolver.set(nil, nil, nil)
+	p, err := New(Config{Discovery: &DiscoveryConfig{Name: "api.svc", Resolver: resolver}})
+	require.NoError(t, err)
+	defer p.Close()
+	require.Nil(t, p.pick(nil))
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultMaxMatchLength is the longest match of a pattern replacement when
+// Replacement.MaxLength is not set
+const DefaultMaxMatchLength = 1024
+
+// DefaultFilterContentTypes are the media types filtered when
+// FilterConfig.ContentTypes is empty
+var DefaultFilterContentTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/javascript",
+	"text/xml",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+}
+
+// filterChunkSize is the amount of the response body read at once
+const filterChunkSize = 32 << 10
+
+// Replacement replaces matches in response bodies
+type Replacement struct {
+	// Old is the literal text replaced, ignored if Pattern is set
+	Old string
+
+	// Pattern matches the text replaced. Matches are searched in a sliding
+	// window, so patterns must not use anchors and must not match more
+	// than MaxLength bytes.
+	Pattern *regexp.Regexp
+
+	// New replaces the matches, with $1 or ${name} expanded to the
+	// submatches of Pattern as in regexp.Regexp.Expand
+	New string
+
+	// MaxLength is the longest match of Pattern, DefaultMaxMatchLength if 0
+	MaxLength int
+}
+
+// RewriteURL returns the replacements rewriting a backend base URL into the
+// public one in response bodies, including the JSON form with escaped
+// slashes, e.g. RewriteURL("http://10.0.0.1:8080", "https://example.com")
+func RewriteURL(from, to string) []Replacement {
+	replacements := []Replacement{{Old: from, New: to}}
+	if escaped := strings.ReplaceAll(from, "/", `\/`); escaped != from {
+		replacements = append(replacements, Replacement{
+			Old: escaped,
+			New: strings.ReplaceAll(to, "/", `\/`),
+		})
+	}
+	return replacements
+}
+
+// FilterConfig configures the transformation of response bodies
+//
+// Bodies are transformed while they are streamed, holding back at most the
+// longest possible match of each replacement, so that large and unbounded
+// responses are never buffered. Content-Length is removed from filtered
+// responses and ETags are weakened. Responses marked Cache-Control:
+// no-transform are passed through.
+//
+// The Accept-Encoding of the client is not forwarded, gzip responses are
+// decompressed and other encodings are not filtered. Range and
+// upgrade responses are never filtered.
+type FilterConfig struct {
+	// Replacements applied in order, nothing is filtered if empty
+	Replacements []Replacement
+
+	// Media types filtered, DefaultFilterContentTypes if empty
+	ContentTypes []string
+}
+
+// enabled reports whether responses are filtered
+func (f *FilterConfig) enabled() bool {
+	return len(f.Replacements) > 0
+}
+
+// filterResponse transforms the body of a backend response
+func (f *FilterConfig) filterResponse(resp *http.Response) error {
+	if !f.filterable(resp) {
+		return nil
+	}
+
+	body := resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return err
+		}
+		body = &readCloser{Reader: gz, Closer: body}
+		resp.Header.Del("Content-Encoding")
+		resp.Uncompressed = true
+	}
+
+	var r io.Reader = body
+	for _, replacement := range f.Replacements {
+		if replacement.Pattern == nil && replacement.Old == "" {
+			continue
+		}
+		r = newReplaceReader(r, replacement)
+	}
+	resp.Body = &readCloser{Reader: r, Closer: body}
+
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+	return nil
+}
+
+// filterable reports whether the body of a response is filtered
+func (f *FilterConfig) filterable(resp *http.Response) bool {
+	switch {
+	case resp.Request != nil && resp.Request.Method == http.MethodHead,
+		resp.StatusCode < http.StatusOK,
+		resp.StatusCode == http.StatusNoContent,
+		resp.StatusCode == http.StatusPartialContent,
+		resp.StatusCode == http.StatusNotModified,
+		strings.Contains(resp.Header.Get("Cache-Control"), "no-transform"):
+		return false
+	}
+
+	switch encoding := resp.Header.Get("Content-Encoding"); {
+	case encoding == "", strings.EqualFold(encoding, "identity"), strings.EqualFold(encoding, "gzip"):
+	default:
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	contentTypes := f.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultFilterContentTypes
+	}
+	return slices.Contains(contentTypes, mediaType)
+}
+
+// readCloser reads from a reader and closes an underlying body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// replaceReader applies a replacement to a stream
+//
+// Matches starting in the last window bytes read are deferred until more
+// input arrives, so that matches spanning two reads are found.
+type replaceReader struct {
+	src      io.Reader
+	pattern  *regexp.Regexp
+	template []byte
+	expand   bool // whether the template references submatches
+	window   int
+
+	chunk  []byte
+	in     []byte // read, not yet replaced
+	out    []byte // replaced, not yet returned
+	outBuf []byte
+	err    error
+}
+
+// newReplaceReader returns a reader applying the replacement to src
+func newReplaceReader(src io.Reader, replacement Replacement) *replaceReader {
+	r := &replaceReader{
+		src:      src,
+		template: []byte(replacement.New),
+		chunk:    make([]byte, filterChunkSize),
+	}
+	if replacement.Pattern != nil {
+		r.pattern = replacement.Pattern
+		r.expand = true
+		r.window = replacement.MaxLength
+		if r.window == 0 {
+			r.window = DefaultMaxMatchLength
+		}
+	} else {
+		r.pattern = regexp.MustCompile(regexp.QuoteMeta(replacement.Old))
+		r.window = len(replacement.Old)
+	}
+	return r
+}
+
+// Read implements io.Reader
+func (r *replaceReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.src.Read(r.chunk)
+		r.in = append(r.in, r.chunk[:n]...)
+		if err != nil {
+			r.err = err
+		}
+		r.replace(err != nil)
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// replace moves the input that can no longer be part of a deferred match
+// to the output, replacing the matches
+func (r *replaceReader) replace(final bool) {
+	safe := len(r.in)
+	if !final {
+		safe -= r.window
+		if safe <= 0 {
+			return
+		}
+	}
+
+	// The output is only replaced once it was entirely returned
+	out := r.outBuf[:0]
+	pos := 0
+	for _, match := range r.pattern.FindAllSubmatchIndex(r.in, -1) {
+		if match[0] >= safe && !final {
+			break
+		}
+		out = append(out, r.in[pos:match[0]]...)
+		if r.expand {
+			out = r.pattern.Expand(out, r.template, r.in, match)
+		} else {
+			out = append(out, r.template...)
+		}
+		pos = match[1]
+	}
+
+	end := max(pos, safe)
+	out = append(out, r.in[pos:end]...)
+	r.out, r.outBuf = out, out
+	r.in = append(r.in[:0], r.in[end:]...)
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceReader(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		replacement Replacement
+		expected    string
+	}{
+		{
+			name:        "literal",
+			input:       "see http://backend:8080/a and http://backend:8080/b",
+			replacement: Replacement{Old: "http://backend:8080", New: "https://example.com"},
+			expected:    "see https://example.com/a and https://example.com/b",
+		},
+		{
+			name:        "literal removed",
+			input:       "a<!-- x -->b",
+			replacement: Replacement{Old: "<!-- x -->"},
+			expected:    "ab",
+		},
+		{
+			name:  "pattern with submatches",
+			input: `{"id": 12, "next": 13}`,
+			replacement: Replacement{
+				Pattern:   regexp.MustCompile(`"(\w+)": (\d+)`),
+				New:       `"$1": "$2"`,
+				MaxLength: 32,
+			},
+			expected: `{"id": "12", "next": "13"}`,
+		},
+		{
+			name:        "no match",
+			input:       "unchanged",
+			replacement: Replacement{Old: "missing", New: "x"},
+			expected:    "unchanged",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reading one byte at a time splits every match across reads
+			r := newReplaceReader(iotest.OneByteReader(strings.NewReader(tt.input)), tt.replacement)
+			output, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(output))
+
+			r = newReplaceReader(strings.NewReader(tt.input), tt.replacement)
+			output, err = io.ReadAll(iotest.OneByteReader(r))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(output))
+		})
+	}
+}
+
+func TestProxy_Filter(t *testing.T) {
+	// The backend links to itself, its URL is only known once started
+	var self string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := `<a href="` + self + `/next">next</a> {"url":"` + strings.ReplaceAll(self, "/", `\/`) + `"}`
+
+		w.Header().Set("ETag", `"v1"`)
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(page))
+			gz.Close()
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte(page))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page))
+		}
+	}))
+	defer backend.Close()
+	self = backend.URL
+
+	p, err := New(Config{
+		Target: backend.URL,
+		Filter: FilterConfig{Replacements: RewriteURL(backend.URL, "https://example.com")},
+	})
+	require.NoError(t, err)
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	get := func(path string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	for _, path := range []string{"/", "/gzip"} {
+		resp, body := get(path)
+		require.Equal(t, `<a href="https://example.com/next">next</a> {"url":"https:\/\/example.com"}`, body, path)
+		require.Equal(t, int64(-1), resp.ContentLength, path)
+		require.Empty(t, resp.Header.Get("Content-Encoding"), path)
+		require.Equal(t, `W/"v1"`, resp.Header.Get("ETag"), path)
+	}
+
+	// Other media types are passed through
+	resp, body := get("/binary")
+	require.Contains(t, body, backend.URL)
+	require.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+}
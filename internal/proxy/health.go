@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Health check defaults
+const (
+	DefaultEjectDuration      = 30 * time.Second
+	DefaultProbeTimeout       = 2 * time.Second
+	DefaultHealthyThreshold   = 2
+	DefaultUnhealthyThreshold = 3
+)
+
+// HealthConfig configures the health checking of upstreams
+//
+// Passive checks eject upstreams failing consecutive requests, where a
+// request fails when the backend cannot be reached or answers with 502,
+// 503 or 504. Active checks probe every upstream periodically and take
+// upstreams failing their probes out of rotation until they recover.
+type HealthConfig struct {
+	// Consecutive failures before an upstream is ejected, 0 disables
+	// passive health checking
+	MaxFails int
+
+	// How long an upstream stays ejected, DefaultEjectDuration if 0
+	EjectDuration time.Duration
+
+	// Interval between probes, 0 disables active health checking
+	Interval time.Duration
+
+	// Random delay of up to Jitter added to every interval, so that probes
+	// of many proxies do not synchronize
+	Jitter time.Duration
+
+	// Timeout of a probe, DefaultProbeTimeout if 0
+	Timeout time.Duration
+
+	// Path probed with GET requests, answered with a 2xx or 3xx status by
+	// healthy upstreams. Upstreams are probed by opening a TCP connection
+	// if empty.
+	Path string
+
+	// Consecutive probe results needed to mark an upstream healthy or
+	// unhealthy, DefaultHealthyThreshold and DefaultUnhealthyThreshold if 0
+	HealthyThreshold   int
+	UnhealthyThreshold int
+}
+
+// healthChecker probes upstreams in the background
+type healthChecker struct {
+	config HealthConfig
+	client *http.Client
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startHealthChecks starts probing the upstreams until stopped
+func startHealthChecks(config HealthConfig, transport http.RoundTripper, upstreams func() []*Upstream) *healthChecker {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultProbeTimeout
+	}
+	if config.HealthyThreshold == 0 {
+		config.HealthyThreshold = DefaultHealthyThreshold
+	}
+	if config.UnhealthyThreshold == 0 {
+		config.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &healthChecker{
+		config: config,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+			// A redirect is an answer, following it would probe another host
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go h.run(ctx, upstreams)
+	return h
+}
+
+// stop stops probing and waits for running probes to finish
+func (h *healthChecker) stop() {
+	h.cancel()
+	<-h.done
+}
+
+// run probes the upstreams every interval until the context is canceled
+func (h *healthChecker) run(ctx context.Context, upstreams func() []*Upstream) {
+	defer close(h.done)
+	for {
+		h.probeAll(ctx, upstreams())
+
+		delay := h.config.Interval
+		if h.config.Jitter > 0 {
+			delay += rand.N(h.config.Jitter)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// probeAll probes the upstreams concurrently
+func (h *healthChecker) probeAll(ctx context.Context, upstreams []*Upstream) {
+	done := make(chan struct{}, len(upstreams))
+	for _, upstream := range upstreams {
+		go func() {
+			err := h.probe(ctx, upstream)
+			if ctx.Err() == nil {
+				upstream.recordProbe(err, &h.config)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for range upstreams {
+		<-done
+	}
+}
+
+// probe checks a single upstream
+//
+// @return: an error describing why the upstream is unhealthy
+func (h *healthChecker) probe(ctx context.Context, upstream *Upstream) error {
+	if h.config.Path == "" {
+		dialer := net.Dialer{Timeout: h.config.Timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", hostPort(upstream.URL.Scheme, upstream.URL.Host))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	target := upstream.URL.JoinPath(h.config.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unhealthy status: %s", resp.Status)
+	}
+	return nil
+}
+
+// recordProbe updates the health of the upstream with a probe result
+func (u *Upstream) recordProbe(err error, config *HealthConfig) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.lastCheck = time.Now()
+	u.lastError = ""
+	if err != nil {
+		u.lastError = err.Error()
+	}
+
+	// Count the results contradicting the current state until a threshold
+	// flips it
+	if (err != nil) != u.down {
+		u.probes++
+	} else {
+		u.probes = 0
+	}
+
+	threshold := config.UnhealthyThreshold
+	if u.down {
+		threshold = config.HealthyThreshold
+	}
+	if u.probes >= threshold {
+		u.down = !u.down
+		u.probes = 0
+	}
+}
+
+// hostPort adds the default port of the scheme to a host without one
+func hostPort(scheme, host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if scheme == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
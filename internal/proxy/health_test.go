@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstream_RecordProbe(t *testing.T) {
+	config := &HealthConfig{HealthyThreshold: 2, UnhealthyThreshold: 3}
+	upstream := &Upstream{URL: &url.URL{Scheme: "http", Host: "10.0.0.1"}}
+	probeErr := errors.New("connection refused")
+
+	upstream.recordProbe(probeErr, config)
+	upstream.recordProbe(probeErr, config)
+	require.True(t, upstream.Healthy())
+
+	// A success resets the count of failures
+	upstream.recordProbe(nil, config)
+	upstream.recordProbe(probeErr, config)
+	upstream.recordProbe(probeErr, config)
+	require.True(t, upstream.Healthy())
+	upstream.recordProbe(probeErr, config)
+	require.False(t, upstream.Healthy())
+	require.Equal(t, "connection refused", upstream.Stats().LastError)
+
+	upstream.recordProbe(nil, config)
+	require.False(t, upstream.Healthy())
+	upstream.recordProbe(nil, config)
+	require.True(t, upstream.Healthy())
+	require.Empty(t, upstream.Stats().LastError)
+}
+
+func TestProxy_ActiveHealthChecks(t *testing.T) {
+	var healthy atomic.Bool
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" && !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("backend"))
+	}))
+	defer backend.Close()
+
+	// Nothing listens on the second upstream
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := "http://" + ln.Addr().String()
+	ln.Close()
+
+	p, err := New(Config{
+		Targets: []string{backend.URL, closed},
+		Health: HealthConfig{
+			Interval:           5 * time.Millisecond,
+			Jitter:             time.Millisecond,
+			Path:               "/healthz",
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+		ErrorLog: log.New(io.Discard, "", 0),
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	status := func() int {
+		w := httptest.NewRecorder()
+		c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w}
+		p.StatusHandler()(c)
+		return w.Code
+	}
+
+	require.Eventually(t, func() bool {
+		stats := p.Upstreams()
+		return !stats[0].Healthy && !stats[1].Healthy
+	}, time.Second, time.Millisecond)
+	require.Equal(t, http.StatusServiceUnavailable, status())
+
+	healthy.Store(true)
+	require.Eventually(t, func() bool {
+		return p.Upstreams()[0].Healthy
+	}, time.Second, time.Millisecond)
+	require.Equal(t, http.StatusOK, status())
+	require.False(t, p.Upstreams()[1].Healthy)
+
+	// Requests only go to the healthy upstream
+	for range 4 {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, "backend", w.Body.String())
+	}
+}
+
+func TestHealthChecker_TCPProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	h := &healthChecker{config: HealthConfig{Timeout: time.Second}}
+	upstream := &Upstream{URL: &url.URL{Scheme: "http", Host: ln.Addr().String()}}
+	require.NoError(t, h.probe(t.Context(), upstream))
+
+	ln.Close()
+	require.Error(t, h.probe(t.Context(), upstream))
+
+	require.Equal(t, "example.com:443", hostPort("https", "example.com"))
+	require.Equal(t, "example.com:8080", hostPort("http", "example.com:8080"))
+}
@@ -0,0 +1,356 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Connection pool defaults, matching http.DefaultTransport
+const (
+	DefaultMaxIdleConns          = 100
+	DefaultIdleConnTimeout       = 90 * time.Second
+	DefaultDialTimeout           = 30 * time.Second
+	DefaultKeepAlive             = 30 * time.Second
+	DefaultTLSHandshakeTimeout   = 10 * time.Second
+	DefaultTLSSessionCacheSize   = 64
+	defaultExpectContinueTimeout = time.Second
+)
+
+// PoolConfig tunes the connections to the backends
+type PoolConfig struct {
+	// Idle connections kept across all backends, DefaultMaxIdleConns if 0
+	MaxIdleConns int
+
+	// Idle connections kept per backend, http.DefaultMaxIdleConnsPerHost
+	// if 0
+	MaxIdleConnsPerHost int
+
+	// Connections per backend, including those in use, 0 is unlimited
+	MaxConnsPerHost int
+
+	// How long idle connections are kept, DefaultIdleConnTimeout if 0
+	IdleConnTimeout time.Duration
+
+	// Timeout of connection attempts, DefaultDialTimeout if 0
+	DialTimeout time.Duration
+
+	// Interval of TCP keep-alive probes, DefaultKeepAlive if 0 and
+	// disabled if negative
+	KeepAlive time.Duration
+
+	// Timeout of TLS handshakes, DefaultTLSHandshakeTimeout if 0
+	TLSHandshakeTimeout time.Duration
+
+	// TLS configuration of the connections to https backends, may be nil
+	TLSClientConfig *tls.Config
+
+	// TLS sessions cached for resumption, DefaultTLSSessionCacheSize if 0
+	// and disabled if negative. Ignored when TLSClientConfig has its own
+	// session cache.
+	TLSSessionCacheSize int
+
+	// HTTP/2 settings of the connections
+	HTTP2 HTTP2Config
+}
+
+// HTTP2Config configures HTTP/2 to the backends
+//
+// HTTP/2 is negotiated with https backends unless disabled. Zero limits
+// use the defaults of net/http.
+type HTTP2Config struct {
+	// Disabled restricts the connections to HTTP/1.1
+	Disabled bool
+
+	// Cleartext speaks HTTP/2 with prior knowledge to http backends, e.g.
+	// gRPC servers without TLS. HTTP/1.1 is not used at all.
+	Cleartext bool
+
+	// Concurrent streams per connection before a new connection is opened
+	MaxConcurrentStreams int
+
+	// Largest frame accepted from the backends
+	MaxReadFrameSize int
+
+	// Flow control windows of the connections and of each stream
+	MaxReceiveBufferPerConnection int
+	MaxReceiveBufferPerStream     int
+
+	// Idle time before a connection is health checked with a ping, and how
+	// long the ping may take before the connection is closed
+	SendPingTimeout time.Duration
+	PingTimeout     time.Duration
+}
+
+// PoolStats is a snapshot of the metrics of a connection pool
+type PoolStats struct {
+	Open  int64 `json:"open"`
+	Idle  int64 `json:"idle"`
+	InUse int64 `json:"in_use"`
+
+	Dials       uint64        `json:"dials"`
+	DialErrors  uint64        `json:"dial_errors"`
+	DialLatency time.Duration `json:"dial_latency"` // average
+
+	// Requests sent, and the fraction of them sent over a reused connection
+	Requests  uint64  `json:"requests"`
+	Reused    uint64  `json:"reused"`
+	ReuseRate float64 `json:"reuse_rate"`
+}
+
+// Pool is an http.RoundTripper pooling the connections to the backends and
+// measuring their use
+//
+// Connections are idle when no response is being received over them. With
+// HTTP/2, a connection is in use as long as one of its streams is.
+type Pool struct {
+	transport *http.Transport
+
+	open  atomic.Int64
+	inUse atomic.Int64
+
+	dials       atomic.Uint64
+	dialErrors  atomic.Uint64
+	dialLatency atomic.Int64 // total, in nanoseconds
+
+	requests atomic.Uint64
+	reused   atomic.Uint64
+}
+
+// NewPool creates a connection pool
+func NewPool(config PoolConfig) *Pool {
+	if config.MaxIdleConns == 0 {
+		config.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if config.IdleConnTimeout == 0 {
+		config.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = DefaultDialTimeout
+	}
+	if config.KeepAlive == 0 {
+		config.KeepAlive = DefaultKeepAlive
+	}
+	if config.TLSHandshakeTimeout == 0 {
+		config.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+
+	tlsConfig := config.TLSClientConfig.Clone()
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ClientSessionCache == nil && config.TLSSessionCacheSize >= 0 {
+		size := config.TLSSessionCacheSize
+		if size == 0 {
+			size = DefaultTLSSessionCacheSize
+		}
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(size)
+	}
+
+	p := &Pool{}
+	dialer := &net.Dialer{Timeout: config.DialTimeout, KeepAlive: config.KeepAlive}
+	p.transport = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           p.dial(dialer),
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		TLSClientConfig:       tlsConfig,
+		ExpectContinueTimeout: defaultExpectContinueTimeout,
+		ForceAttemptHTTP2:     !config.HTTP2.Disabled,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams:          config.HTTP2.MaxConcurrentStreams,
+			MaxReadFrameSize:              config.HTTP2.MaxReadFrameSize,
+			MaxReceiveBufferPerConnection: config.HTTP2.MaxReceiveBufferPerConnection,
+			MaxReceiveBufferPerStream:     config.HTTP2.MaxReceiveBufferPerStream,
+			SendPingTimeout:               config.HTTP2.SendPingTimeout,
+			PingTimeout:                   config.HTTP2.PingTimeout,
+		},
+	}
+
+	switch {
+	case config.HTTP2.Cleartext:
+		p.transport.Protocols = new(http.Protocols)
+		p.transport.Protocols.SetHTTP2(true)
+		p.transport.Protocols.SetUnencryptedHTTP2(true)
+	case config.HTTP2.Disabled:
+		p.transport.Protocols = new(http.Protocols)
+		p.transport.Protocols.SetHTTP1(true)
+	}
+	return p
+}
+
+// Transport returns the underlying transport
+func (p *Pool) Transport() *http.Transport {
+	return p.transport
+}
+
+// CloseIdleConnections closes the connections not in use
+func (p *Pool) CloseIdleConnections() {
+	p.transport.CloseIdleConnections()
+}
+
+// Stats returns a snapshot of the pool metrics
+func (p *Pool) Stats() PoolStats {
+	stats := PoolStats{
+		Open:       p.open.Load(),
+		InUse:      p.inUse.Load(),
+		Dials:      p.dials.Load(),
+		DialErrors: p.dialErrors.Load(),
+		Requests:   p.requests.Load(),
+		Reused:     p.reused.Load(),
+	}
+	stats.Idle = max(stats.Open-stats.InUse, 0)
+	if stats.Dials > 0 {
+		stats.DialLatency = time.Duration(p.dialLatency.Load() / int64(stats.Dials))
+	}
+	if stats.Requests > 0 {
+		stats.ReuseRate = float64(stats.Reused) / float64(stats.Requests)
+	}
+	return stats
+}
+
+// RoundTrip implements http.RoundTripper
+func (p *Pool) RoundTrip(r *http.Request) (*http.Response, error) {
+	lease := &poolLease{pool: p}
+	trace := &httptrace.ClientTrace{GotConn: lease.acquire}
+	resp, err := p.transport.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	if err != nil {
+		lease.release()
+		return nil, err
+	}
+
+	// The connection is in use until the response body is consumed
+	body := &poolBody{ReadCloser: resp.Body, lease: lease}
+	if rw, ok := resp.Body.(io.ReadWriteCloser); ok {
+		// Switching Protocols responses have writable bodies, which the
+		// reverse proxy relies on to tunnel the upgraded connection
+		resp.Body = &poolUpgradeBody{poolBody: body, writer: rw}
+	} else {
+		resp.Body = body
+	}
+	return resp, nil
+}
+
+// dial opens connections counted by the pool
+func (p *Pool) dial(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, network, addr)
+		p.dials.Add(1)
+		p.dialLatency.Add(int64(time.Since(start)))
+		if err != nil {
+			p.dialErrors.Add(1)
+			return nil, err
+		}
+
+		p.open.Add(1)
+		return &poolConn{Conn: conn, pool: p}, nil
+	}
+}
+
+// poolConn is a connection counted by its pool
+type poolConn struct {
+	net.Conn
+	pool   *Pool
+	active atomic.Int64 // requests over the connection
+	closed atomic.Bool
+}
+
+// Close implements net.Conn
+func (c *poolConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.pool.open.Add(-1)
+	}
+	return c.Conn.Close()
+}
+
+// poolLease tracks the connection used by a request
+type poolLease struct {
+	pool *Pool
+
+	mu   sync.Mutex
+	conn *poolConn
+}
+
+// acquire records the connection obtained for the request, replacing the
+// previous one when the request is retried
+func (l *poolLease) acquire(info httptrace.GotConnInfo) {
+	l.pool.requests.Add(1)
+	if info.Reused {
+		l.pool.reused.Add(1)
+	}
+
+	// TLS connections wrap the dialed ones
+	conn := info.Conn
+	if tlsConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tlsConn.NetConn()
+	}
+	pc, ok := conn.(*poolConn)
+	if !ok {
+		return
+	}
+
+	l.release()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conn = pc
+	if pc.active.Add(1) == 1 {
+		l.pool.inUse.Add(1)
+	}
+}
+
+// release returns the connection of the request to the pool
+func (l *poolLease) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return
+	}
+	if l.conn.active.Add(-1) == 0 {
+		l.pool.inUse.Add(-1)
+	}
+	l.conn = nil
+}
+
+// poolBody releases the connection of a response once its body is consumed
+// or closed
+type poolBody struct {
+	io.ReadCloser
+	lease *poolLease
+}
+
+// Read implements io.Reader
+func (b *poolBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.lease.release()
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *poolBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.lease.release()
+	return err
+}
+
+// poolUpgradeBody is a poolBody over an upgraded connection
+type poolUpgradeBody struct {
+	*poolBody
+	writer io.Writer
+}
+
+// Write implements io.Writer
+func (b *poolUpgradeBody) Write(p []byte) (int, error) {
+	return b.writer.Write(p)
+}
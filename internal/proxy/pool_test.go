@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_Stats(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			w.WriteHeader(http.StatusOK)
+			http.NewResponseController(w).Flush()
+			<-release
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	pool := NewPool(PoolConfig{})
+	client := &http.Client{Transport: pool}
+	for range 3 {
+		resp, err := client.Get(backend.URL)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	stats := pool.Stats()
+	require.Equal(t, int64(1), stats.Open)
+	require.Equal(t, int64(1), stats.Idle)
+	require.Zero(t, stats.InUse)
+	require.Equal(t, uint64(1), stats.Dials)
+	require.Positive(t, stats.DialLatency)
+	require.Equal(t, uint64(3), stats.Requests)
+	require.Equal(t, uint64(2), stats.Reused)
+	require.InDelta(t, 2.0/3, stats.ReuseRate, 1e-9)
+
+	// The connection is in use until the response body is consumed
+	resp, err := client.Get(backend.URL + "/slow")
+	require.NoError(t, err)
+	stats = pool.Stats()
+	require.Equal(t, int64(1), stats.InUse)
+	require.Zero(t, stats.Idle)
+
+	close(release)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.Zero(t, pool.Stats().InUse)
+
+	pool.CloseIdleConnections()
+	require.Zero(t, pool.Stats().Open)
+}
+
+func TestPool_HTTP2Cleartext(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetHTTP1(true)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	defer backend.Close()
+
+	get := func(pool *Pool) string {
+		resp, err := (&http.Client{Transport: pool}).Get(backend.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	require.Equal(t, "HTTP/1.1", get(NewPool(PoolConfig{})))
+
+	pool := NewPool(PoolConfig{HTTP2: HTTP2Config{Cleartext: true}})
+	require.Equal(t, "HTTP/2.0", get(pool))
+	require.Equal(t, "HTTP/2.0", get(pool))
+	require.Equal(t, uint64(1), pool.Stats().Dials)
+}
+
+func TestProxy_Pool(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	p, err := New(Config{Target: backend.URL})
+	require.NoError(t, err)
+	for range 2 {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	stats := p.Pool()
+	require.Equal(t, uint64(2), stats.Requests)
+	require.Equal(t, uint64(1), stats.Reused)
+	require.Equal(t, int64(1), stats.Idle)
+}
@@ -0,0 +1,370 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+var (
+	// ErrInvalidTarget is returned for backend URLs without scheme or host
+	ErrInvalidTarget = errors.New("proxy: invalid target")
+
+	// ErrNoTargets is returned when no backend is configured
+	ErrNoTargets = errors.New("proxy: no targets")
+)
+
+// Config configures a reverse proxy
+type Config struct {
+	// Target is the backend base URL, e.g. http://10.0.0.1:8080/api
+	Target string
+
+	// Targets are additional backends requests are balanced across
+	Targets []string
+
+	// Discovery resolves the backends from DNS, in addition to the targets
+	Discovery *DiscoveryConfig
+
+	// Balancer selects the backend of each request, RoundRobin if nil
+	Balancer Balancer
+
+	// Health configures the ejection of failing backends
+	Health HealthConfig
+
+	// Filter transforms the response bodies, e.g. to rewrite backend URLs
+	Filter FilterConfig
+
+	// Tunnel configures upgraded connections and CONNECT tunnels
+	Tunnel TunnelConfig
+
+	// StripPrefix is removed from the request path before it is appended
+	// to the target path, when it matches whole segments, e.g. /api from
+	// /api and /api/users but not /apiv2
+	StripPrefix string
+
+	// Transport performs the backend requests, a Pool configured by Pool
+	// if nil. Expect: 100-continue is only forwarded by transports with a
+	// non-zero ExpectContinueTimeout.
+	Transport http.RoundTripper
+
+	// Pool tunes the connections to the backends when Transport is nil
+	Pool PoolConfig
+
+	// FlushInterval between writes of the response body to the client,
+	// 0 flushes after every write so that streams are not delayed
+	FlushInterval time.Duration
+
+	// ErrorLog receives backend errors, the standard logger if nil
+	ErrorLog *log.Logger
+}
+
+// Proxy forwards requests to a set of backends without buffering bodies
+//
+// Each request is sent to the backend selected by the balancer among the
+// healthy ones, see Config.Balancer and Config.Health.
+//
+// Request and response bodies are streamed in both directions at the same
+// time, so interactive protocols such as gRPC-web and long uploads work
+// through the proxy. Expect: 100-continue is answered by the backend, and
+// trailers are propagated in both directions. Upgraded connections, e.g.
+// WebSockets, and CONNECT tunnels are spliced, see Config.Tunnel.
+type Proxy struct {
+	// Current upstreams, replaced as a whole when discovery updates them
+	upstreams atomic.Pointer[[]*Upstream]
+	static    []*Upstream
+	balancer  Balancer
+	prefix    string
+	filter    FilterConfig
+	tunnels   *tunnels
+	connect   bool
+	logger    *log.Logger
+	reverse   *httputil.ReverseProxy
+
+	// Connection pool, nil if Config.Transport is set
+	pool *Pool
+
+	// Active health checks and DNS discovery, nil if disabled
+	checker    *healthChecker
+	discoverer *discoverer
+}
+
+// New creates a reverse proxy for the configured backends
+//
+// @return: the proxy
+// @return: ErrInvalidTarget if a target URL is not absolute, ErrNoTargets,
+// or an error if the initial DNS resolution failed
+func New(config Config) (*Proxy, error) {
+	targets := config.Targets
+	if config.Target != "" {
+		targets = append([]string{config.Target}, targets...)
+	}
+	if len(targets) == 0 && config.Discovery == nil {
+		return nil, ErrNoTargets
+	}
+
+	upstreams := make([]*Upstream, 0, len(targets))
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTarget, target)
+		}
+		upstreams = append(upstreams, &Upstream{URL: u})
+	}
+
+	balancer := config.Balancer
+	if balancer == nil {
+		balancer = RoundRobin()
+	}
+
+	var pool *Pool
+	transport := config.Transport
+	if transport == nil {
+		pool = NewPool(config.Pool)
+		transport = pool
+	}
+
+	flushInterval := config.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = -1
+	}
+
+	logger := config.ErrorLog
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	p := &Proxy{
+		static:   upstreams,
+		pool:     pool,
+		balancer: balancer,
+		prefix:   strings.TrimSuffix(config.StripPrefix, "/"),
+		filter:   config.Filter,
+		tunnels:  newTunnels(config.Tunnel),
+		connect:  config.Tunnel.Connect,
+		logger:   logger,
+	}
+	p.reverse = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      &trackingTransport{base: transport, health: config.Health},
+		FlushInterval:  flushInterval,
+		ErrorLog:       logger,
+		ErrorHandler:   p.handleError,
+		ModifyResponse: p.modifyResponse,
+	}
+
+	p.upstreams.Store(&upstreams)
+
+	if config.Discovery != nil {
+		d, err := startDiscovery(p, *config.Discovery, transport)
+		if err != nil {
+			return nil, err
+		}
+		p.discoverer = d
+	}
+	if config.Health.Interval > 0 {
+		p.checker = startHealthChecks(config.Health, transport, p.current)
+	}
+	return p, nil
+}
+
+// Close stops the active health checks and DNS discovery
+func (p *Proxy) Close() error {
+	if p.checker != nil {
+		p.checker.stop()
+	}
+	if p.discoverer != nil {
+		p.discoverer.stop()
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Without full duplex, net/http stops reading the request body once
+	// the response is started, which breaks bidirectional streams
+	_ = http.NewResponseController(w).EnableFullDuplex()
+
+	upstream := p.pick(r)
+	if upstream == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	upstream.active.Add(1)
+	defer upstream.active.Add(-1)
+
+	if r.Method == http.MethodConnect {
+		if !p.connect {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if err := p.tunnels.connect(w, r, upstream); err != nil {
+			p.handleError(w, r, err)
+		}
+		return
+	}
+
+	p.reverse.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), upstreamKey{}, upstream)))
+}
+
+// Upstreams returns the metrics of the backends, the configured targets
+// first and then the discovered ones, followed by the draining ones
+func (p *Proxy) Upstreams() []UpstreamStats {
+	upstreams := p.current()
+	if p.discoverer != nil {
+		upstreams = append(slices.Clip(upstreams), p.discoverer.drainingUpstreams()...)
+	}
+
+	stats := make([]UpstreamStats, len(upstreams))
+	for i, upstream := range upstreams {
+		stats[i] = upstream.Stats()
+	}
+	return stats
+}
+
+// Pool returns the metrics of the connections to the backends, zero when
+// Config.Transport is set
+func (p *Proxy) Pool() PoolStats {
+	if p.pool == nil {
+		return PoolStats{}
+	}
+	return p.pool.Stats()
+}
+
+// Tunnels returns the metrics of the upgraded connections and CONNECT
+// tunnels
+func (p *Proxy) Tunnels() TunnelStats {
+	return p.tunnels.stats()
+}
+
+// current returns the upstreams requests are balanced across
+func (p *Proxy) current() []*Upstream {
+	return *p.upstreams.Load()
+}
+
+// StatusHandler returns a handler reporting the upstream, connection pool
+// and tunnel metrics as JSON, for use as an admin endpoint
+//
+// It responds with 503 Service Unavailable when no upstream is healthy.
+func (p *Proxy) StatusHandler() types.HandlerFunc {
+	return func(c *types.Context) {
+		stats := p.Upstreams()
+
+		status := http.StatusServiceUnavailable
+		for _, upstream := range stats {
+			if upstream.Healthy {
+				status = http.StatusOK
+				break
+			}
+		}
+		c.JSON(status, map[string]any{
+			"upstreams": stats,
+			"pool":      p.Pool(),
+			"tunnels":   p.Tunnels(),
+		})
+	}
+}
+
+// pick selects the upstream for a request among the healthy ones, or
+// among all of them if every upstream is ejected
+//
+// @return: the upstream, or nil if there is none
+func (p *Proxy) pick(r *http.Request) *Upstream {
+	upstreams := p.current()
+	switch len(upstreams) {
+	case 0:
+		return nil
+	case 1:
+		return upstreams[0]
+	}
+
+	healthy := make([]*Upstream, 0, len(upstreams))
+	for _, upstream := range upstreams {
+		if upstream.Healthy() {
+			healthy = append(healthy, upstream)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = upstreams
+	}
+	return p.balancer.Pick(r, healthy)
+}
+
+// Handle proxies the request of the context, for use as a route handler
+func (p *Proxy) Handle(c *types.Context) {
+	p.ServeHTTP(c.Writer, c.Request)
+}
+
+// rewrite builds the backend request
+func (p *Proxy) rewrite(r *httputil.ProxyRequest) {
+	if path, ok := stripPrefix(r.Out.URL.Path, p.prefix); ok {
+		r.Out.URL.Path = path
+		// The escaped path is derived from the path again if its prefix
+		// is escaped differently
+		r.Out.URL.RawPath, _ = stripPrefix(r.Out.URL.RawPath, p.prefix)
+	}
+	r.SetURL(upstreamFromContext(r.In.Context()).URL)
+	r.SetXForwarded()
+
+	// The outbound request is a deep copy, share the inbound trailer map
+	// so that trailers received after the body are forwarded
+	r.Out.Trailer = r.In.Trailer
+
+	// Compressed responses cannot be filtered, net/http transports then
+	// ask for gzip themselves and decompress the response
+	if p.filter.enabled() {
+		r.Out.Header.Del("Accept-Encoding")
+	}
+}
+
+// modifyResponse tracks upgraded connections and filters the response
+// bodies
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		p.tunnels.upgrade(resp)
+		return nil
+	}
+	if p.filter.enabled() {
+		return p.filter.filterResponse(resp)
+	}
+	return nil
+}
+
+// handleError responds to requests the backend could not serve
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	// Clients going away are not backend failures
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	p.logger.Printf("proxy: %s %s: %v", r.Method, r.URL.Path, err)
+
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// stripPrefix removes a prefix of whole segments from a path, keeping it
+// absolute, e.g. /api from /api/users to /users and from /api to /
+//
+// @return: false if the path does not start with the prefix segments
+func stripPrefix(path, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, prefix)
+	if prefix == "" || !ok || (rest != "" && rest[0] != '/') {
+		return "", false
+	}
+	if rest == "" {
+		return "/", true
+	}
+	return rest, true
+}
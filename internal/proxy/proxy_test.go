@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newProxyServer starts a server proxying to the backend handler
+func newProxyServer(t *testing.T, backend http.Handler, config Config) *httptest.Server {
+	upstream := httptest.NewServer(backend)
+	t.Cleanup(upstream.Close)
+
+	config.Target = upstream.URL
+	config.ErrorLog = log.New(io.Discard, "", 0)
+	p, err := New(config)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxy_StripPrefix(t *testing.T) {
+	server := newProxyServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI() + " " + r.Header.Get("X-Forwarded-Host")))
+	}), Config{StripPrefix: "/api"})
+
+	resp, err := http.Get(server.URL + "/api/users?id=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	require.Equal(t, "/users?id=1 "+strings.TrimPrefix(server.URL, "http://"), string(body))
+
+	// Only whole segments are stripped, the path staying absolute
+	for path, want := range map[string]string{"/api": "/", "/api/": "/", "/apiv2/users": "/apiv2/users", "/api/a%2Fb": "/a%2Fb"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Equal(t, want, strings.Fields(string(body))[0], path)
+	}
+}
+
+func TestProxy_BidirectionalStreaming(t *testing.T) {
+	// The backend echoes every line as soon as it is received
+	server := newProxyServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).EnableFullDuplex()
+		w.WriteHeader(http.StatusOK)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			w.Write([]byte("echo " + scanner.Text() + "\n"))
+			http.NewResponseController(w).Flush()
+		}
+	}), Config{})
+
+	body, input := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, server.URL, body)
+	require.NoError(t, err)
+
+	go input.Write([]byte("one\n"))
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			respCh <- resp
+		}
+	}()
+
+	var resp *http.Response
+	select {
+	case resp = <-respCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("response not streamed before the request body ended")
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "echo one\n", line)
+
+	// The request body is still open while the response is read
+	go input.Write([]byte("two\n"))
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "echo two\n", line)
+	input.Close()
+}
+
+func TestProxy_Trailers(t *testing.T) {
+	server := newProxyServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("request trailer " + r.Trailer.Get("X-Checksum")))
+		w.Header().Set("Grpc-Status", "0")
+	}), Config{})
+
+	trailer := http.Header{"X-Checksum": nil}
+	body := &trailerBody{Reader: strings.NewReader("payload"), done: func() {
+		trailer.Set("X-Checksum", "abc")
+	}}
+	req, err := http.NewRequest(http.MethodPost, server.URL, body)
+	require.NoError(t, err)
+	req.Trailer = trailer
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	require.Equal(t, "request trailer abc", string(data))
+	require.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+}
+
+// trailerBody calls done when the body is exhausted, so that the trailer
+// values are set after the body was sent
+type trailerBody struct {
+	io.Reader
+	done func()
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *trailerBody) Close() error { return nil }
+
+func TestProxy_ExpectContinue(t *testing.T) {
+	bodyRead := make(chan struct{}, 1)
+	server := newProxyServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		bodyRead <- struct{}{}
+	}), Config{})
+
+	send := func(headers string) (*bufio.Reader, net.Conn) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 5\r\nExpect: 100-continue\r\n" + headers + "\r\n"))
+		require.NoError(t, err)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return bufio.NewReader(conn), conn
+	}
+
+	// The backend rejects the request before the body is sent
+	reader, _ := send("")
+	status, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/1.1 401 Unauthorized\r\n", status)
+
+	// The backend accepts the request and the client is told to continue
+	reader, conn := send("Authorization: Bearer x\r\n")
+	status, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/1.1 100 Continue\r\n", status)
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	<-bodyRead
+}
+
+func TestProxy_Errors(t *testing.T) {
+	_, err := New(Config{Target: "localhost:8080"})
+	require.ErrorIs(t, err, ErrInvalidTarget)
+
+	// Nothing listens on the backend address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	p, err := New(Config{Target: "http://" + addr, ErrorLog: log.New(io.Discard, "", 0)})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusBadGateway, w.Code)
+}
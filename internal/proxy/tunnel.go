@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTunnelIdleTimeout is how long tunnels may stay without traffic
+// when TunnelConfig.IdleTimeout is not set
+const DefaultTunnelIdleTimeout = 5 * time.Minute
+
+// TunnelConfig configures upgraded connections, e.g. WebSockets, and
+// CONNECT tunnels
+type TunnelConfig struct {
+	// IdleTimeout closes tunnels without traffic in either direction,
+	// DefaultTunnelIdleTimeout if 0 and disabled if negative
+	IdleTimeout time.Duration
+
+	// Connect accepts CONNECT requests, which are tunneled to the selected
+	// backend whatever the requested authority. They are rejected with 405
+	// Method Not Allowed otherwise.
+	Connect bool
+}
+
+// TunnelStats is a snapshot of the metrics of the tunnels of a proxy
+type TunnelStats struct {
+	Active     int64  `json:"active"`
+	Total      uint64 `json:"total"`
+	IdleClosed uint64 `json:"idle_closed"`
+	Sent       uint64 `json:"sent"`     // bytes from clients to backends
+	Received   uint64 `json:"received"` // bytes from backends to clients
+}
+
+// tunnels tracks the tunnels of a proxy
+type tunnels struct {
+	idle time.Duration
+
+	active     atomic.Int64
+	total      atomic.Uint64
+	idleClosed atomic.Uint64
+	sent       atomic.Uint64
+	received   atomic.Uint64
+}
+
+// newTunnels creates the tunnel tracking of a proxy
+func newTunnels(config TunnelConfig) *tunnels {
+	idle := config.IdleTimeout
+	if idle == 0 {
+		idle = DefaultTunnelIdleTimeout
+	}
+	return &tunnels{idle: idle}
+}
+
+// stats returns a snapshot of the tunnel metrics
+func (t *tunnels) stats() TunnelStats {
+	return TunnelStats{
+		Active:     t.active.Load(),
+		Total:      t.total.Load(),
+		IdleClosed: t.idleClosed.Load(),
+		Sent:       t.sent.Load(),
+		Received:   t.received.Load(),
+	}
+}
+
+// open tracks the backend side of a new tunnel
+//
+// The tunnel counts as active until the returned connection is closed,
+// which happens when it stays idle for too long.
+func (t *tunnels) open(backend io.ReadWriteCloser) *tunnelConn {
+	t.active.Add(1)
+	t.total.Add(1)
+
+	c := &tunnelConn{ReadWriteCloser: backend, tunnels: t}
+	c.touch()
+	if t.idle > 0 {
+		c.timer = time.AfterFunc(t.idle, c.checkIdle)
+	}
+	return c
+}
+
+// upgrade tracks the backend connection of a Switching Protocols
+// response, which the reverse proxy splices with the client connection
+func (t *tunnels) upgrade(resp *http.Response) {
+	if backend, ok := resp.Body.(io.ReadWriteCloser); ok {
+		resp.Body = t.open(backend)
+	}
+}
+
+// connect tunnels a CONNECT request to the backend
+func (t *tunnels) connect(w http.ResponseWriter, r *http.Request, upstream *Upstream) error {
+	dialer := &net.Dialer{Timeout: DefaultDialTimeout}
+	backend, err := dialer.DialContext(r.Context(), "tcp", hostPort(upstream.URL.Scheme, upstream.URL.Host))
+	if err != nil {
+		return err
+	}
+
+	client, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		backend.Close()
+		return err
+	}
+	if _, err := buffered.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		client.Close()
+		backend.Close()
+		return nil
+	}
+	if err := buffered.Flush(); err != nil {
+		client.Close()
+		backend.Close()
+		return nil
+	}
+
+	// The client may have sent tunneled bytes along with the request
+	var src io.Reader = client
+	if n := buffered.Reader.Buffered(); n > 0 {
+		head, _ := buffered.Reader.Peek(n)
+		src = io.MultiReader(bytes.NewReader(head), client)
+	}
+
+	t.splice(client, src, t.open(backend))
+	return nil
+}
+
+// splice copies bytes in both directions until both are done, propagating
+// half-closes, or until either side fails
+func (t *tunnels) splice(client net.Conn, src io.Reader, backend *tunnelConn) {
+	defer client.Close()
+	defer backend.Close()
+
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(backend, src)
+		if err == nil {
+			closeWrite(backend.ReadWriteCloser)
+		}
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(client, backend)
+		if err == nil {
+			closeWrite(client)
+		}
+		errc <- err
+	}()
+
+	for range 2 {
+		if err := <-errc; err != nil {
+			return
+		}
+	}
+}
+
+// closeWrite shuts down the writing side of a connection, when supported
+func closeWrite(c any) {
+	if conn, ok := c.(interface{ CloseWrite() error }); ok {
+		conn.CloseWrite()
+	}
+}
+
+// tunnelConn is the backend side of a tunnel, counting the bytes through
+// it and closing it when idle
+type tunnelConn struct {
+	io.ReadWriteCloser
+	tunnels *tunnels
+
+	last  atomic.Int64 // time of the last activity, in unix nanoseconds
+	timer *time.Timer
+	once  sync.Once
+}
+
+// Read implements io.Reader
+func (c *tunnelConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if n > 0 {
+		c.touch()
+		c.tunnels.received.Add(uint64(n))
+	}
+	return n, err
+}
+
+// Write implements io.Writer
+func (c *tunnelConn) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+	if n > 0 {
+		c.touch()
+		c.tunnels.sent.Add(uint64(n))
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (c *tunnelConn) Close() error {
+	err := c.ReadWriteCloser.Close()
+	c.once.Do(func() {
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		c.tunnels.active.Add(-1)
+	})
+	return err
+}
+
+// touch records activity on the tunnel
+func (c *tunnelConn) touch() {
+	c.last.Store(time.Now().UnixNano())
+}
+
+// checkIdle closes the tunnel if it was idle for the whole timeout, or
+// checks again once the timeout since the last activity elapsed
+func (c *tunnelConn) checkIdle() {
+	idle := time.Since(time.Unix(0, c.last.Load()))
+	if idle < c.tunnels.idle {
+		c.timer.Reset(c.tunnels.idle - idle)
+		return
+	}
+
+	c.tunnels.idleClosed.Add(1)
+	c.Close()
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTunnelProxy starts a server proxying to the backend address
+func newTunnelProxy(t *testing.T, backend string, config TunnelConfig) (*Proxy, string) {
+	p, err := New(Config{Target: backend, Tunnel: config, ErrorLog: log.New(io.Discard, "", 0)})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	return p, strings.TrimPrefix(server.URL, "http://")
+}
+
+// dialTunnel sends a request head over a new connection to the proxy
+func dialTunnel(t *testing.T, addr, head string) (*net.TCPConn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = conn.Write([]byte(head))
+	require.NoError(t, err)
+	return conn.(*net.TCPConn), bufio.NewReader(conn)
+}
+
+// readHead reads a response head and returns its status line
+func readHead(t *testing.T, reader *bufio.Reader) string {
+	status, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if line == "\r\n" {
+			return strings.TrimSpace(status)
+		}
+	}
+}
+
+func TestProxy_Upgrade(t *testing.T) {
+	// The backend echoes lines once the connection is upgraded
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "echo")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+		conn, buffered, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, buffered)
+	}))
+	defer backend.Close()
+
+	p, addr := newTunnelProxy(t, backend.URL, TunnelConfig{IdleTimeout: 200 * time.Millisecond})
+	upgrade := "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"
+
+	conn, reader := dialTunnel(t, addr, upgrade)
+	require.Equal(t, "HTTP/1.1 101 Switching Protocols", readHead(t, reader))
+	_, err := conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", line)
+	require.Equal(t, int64(1), p.Tunnels().Active)
+
+	// Closing the client closes the backend side
+	conn.Close()
+	require.Eventually(t, func() bool { return p.Tunnels().Active == 0 }, time.Second, 10*time.Millisecond)
+
+	// Idle tunnels are closed
+	_, reader = dialTunnel(t, addr, upgrade)
+	require.Equal(t, "HTTP/1.1 101 Switching Protocols", readHead(t, reader))
+	_, err = reader.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+
+	stats := p.Tunnels()
+	require.Equal(t, uint64(2), stats.Total)
+	require.Equal(t, uint64(1), stats.IdleClosed)
+	require.Equal(t, uint64(len("hello\n")), stats.Sent)
+	require.Equal(t, uint64(len("hello\n")), stats.Received)
+}
+
+func TestProxy_Connect(t *testing.T) {
+	// The backend answers with everything received once the client is
+	// done writing
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Write([]byte("got " + string(data)))
+			conn.Close()
+		}
+	}()
+
+	_, addr := newTunnelProxy(t, "http://"+ln.Addr().String(), TunnelConfig{})
+	_, reader := dialTunnel(t, addr, "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n")
+	require.Equal(t, "HTTP/1.1 405 Method Not Allowed", readHead(t, reader))
+
+	_, addr = newTunnelProxy(t, "http://"+ln.Addr().String(), TunnelConfig{Connect: true})
+
+	// Bytes sent along with the request are tunneled, and the half-close
+	// of the client reaches the backend
+	conn, reader := dialTunnel(t, addr, "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\nhello")
+	require.Equal(t, "HTTP/1.1 200 Connection Established", readHead(t, reader))
+	_, err = conn.Write([]byte(" world"))
+	require.NoError(t, err)
+	require.NoError(t, conn.CloseWrite())
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "got hello world", string(data))
+}
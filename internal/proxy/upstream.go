@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upstream is a backend requests are balanced across
+type Upstream struct {
+	URL *url.URL
+
+	active   atomic.Int64
+	requests atomic.Uint64
+	failures atomic.Uint64
+	latency  atomic.Int64 // total, in nanoseconds
+
+	mu           sync.Mutex
+	fails        int // consecutive
+	ejections    uint64
+	ejectedUntil time.Time
+
+	// Active health check state, see HealthConfig.Interval
+	down      bool
+	probes    int // consecutive results contradicting down
+	lastCheck time.Time
+	lastError string
+}
+
+// UpstreamStats is a snapshot of the metrics of an upstream
+type UpstreamStats struct {
+	URL       string        `json:"url"`
+	Healthy   bool          `json:"healthy"`
+	Active    int64         `json:"active"`
+	Requests  uint64        `json:"requests"`
+	Failures  uint64        `json:"failures"`
+	Ejections uint64        `json:"ejections"`
+	Latency   time.Duration `json:"latency"` // average
+
+	LastCheck time.Time `json:"last_check,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Active returns the number of requests in flight
+func (u *Upstream) Active() int64 {
+	return u.active.Load()
+}
+
+// Healthy reports whether the upstream passes its health checks and is
+// not ejected
+func (u *Upstream) Healthy() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !u.down && !time.Now().Before(u.ejectedUntil)
+}
+
+// Stats returns a snapshot of the upstream metrics
+func (u *Upstream) Stats() UpstreamStats {
+	stats := UpstreamStats{
+		URL:      u.URL.String(),
+		Healthy:  u.Healthy(),
+		Active:   u.active.Load(),
+		Requests: u.requests.Load(),
+		Failures: u.failures.Load(),
+	}
+	if stats.Requests > 0 {
+		stats.Latency = time.Duration(u.latency.Load() / int64(stats.Requests))
+	}
+
+	u.mu.Lock()
+	stats.Ejections = u.ejections
+	stats.LastCheck = u.lastCheck
+	stats.LastError = u.lastError
+	u.mu.Unlock()
+	return stats
+}
+
+// record updates the metrics and health of the upstream with the outcome
+// of a request
+func (u *Upstream) record(failed bool, latency time.Duration, health *HealthConfig) {
+	u.requests.Add(1)
+	u.latency.Add(int64(latency))
+	if failed {
+		u.failures.Add(1)
+	}
+
+	if health.MaxFails <= 0 {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !failed {
+		u.fails = 0
+		return
+	}
+
+	u.fails++
+	if u.fails >= health.MaxFails {
+		eject := health.EjectDuration
+		if eject == 0 {
+			eject = DefaultEjectDuration
+		}
+		u.fails = 0
+		u.ejections++
+		u.ejectedUntil = time.Now().Add(eject)
+	}
+}
+
+// upstreamKey is the context key of the upstream selected for a request
+type upstreamKey struct{}
+
+// upstreamFromContext returns the upstream selected for a request
+func upstreamFromContext(ctx context.Context) *Upstream {
+	upstream, _ := ctx.Value(upstreamKey{}).(*Upstream)
+	return upstream
+}
+
+// trackingTransport records the outcome of backend requests on the
+// upstream selected for them
+type trackingTransport struct {
+	base   http.RoundTripper
+	health HealthConfig
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *trackingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	upstream := upstreamFromContext(r.Context())
+	if upstream == nil {
+		return t.base.RoundTrip(r)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(r)
+
+	// Requests canceled by the client say nothing about the backend
+	if err != nil && r.Context().Err() != nil {
+		return resp, err
+	}
+
+	failed := err != nil
+	if resp != nil {
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			failed = true
+		}
+	}
+	upstream.record(failed, time.Since(start), &t.health)
+	return resp, err
+}
@@ -0,0 +1,117 @@
+// Package replay rejects replayed messages, e.g. webhooks, signed requests
+// and idempotency keys: a nonce is accepted once, with a timestamp within
+// a window around the current time
+package replay
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+)
+
+// Defaults of the replay protection
+const (
+	DefaultWindow = 5 * time.Minute
+	DefaultSkew   = time.Minute
+	DefaultPrefix = "replay:"
+)
+
+var (
+	// ErrReplayed is returned for nonces used already
+	ErrReplayed = errors.New("replay: nonce already used")
+
+	// ErrOutsideWindow is returned for timestamps too old or too far in
+	// the future
+	ErrOutsideWindow = errors.New("replay: timestamp outside of the window")
+
+	// ErrNoNonce is returned for empty nonces
+	ErrNoNonce = errors.New("replay: missing nonce")
+)
+
+// Config configures a Guard
+type Config struct {
+	// Store remembers the nonces, shared by the instances of the
+	// application, an in-memory store if nil
+	Store store.Store
+
+	// Window is how old timestamps may be, DefaultWindow if 0
+	Window time.Duration
+
+	// Skew is how far in the future timestamps may be, for senders whose
+	// clock runs ahead, DefaultSkew if 0
+	Skew time.Duration
+
+	// Prefix of the keys of the nonces, DefaultPrefix if empty, e.g. to
+	// keep apart the subsystems sharing a store
+	Prefix string
+}
+
+// Guard accepts every nonce once within the timestamp window
+//
+// Nonces are only remembered until their timestamp leaves the window,
+// since older messages are rejected by their timestamp anyway.
+type Guard struct {
+	config Config
+	now    func() time.Time
+}
+
+// New creates a Guard, filling in defaults for unset fields
+func New(config Config) *Guard {
+	if config.Store == nil {
+		config.Store = store.NewMemory(store.MemoryConfig{})
+	}
+	if config.Window == 0 {
+		config.Window = DefaultWindow
+	}
+	if config.Skew == 0 {
+		config.Skew = DefaultSkew
+	}
+	if config.Prefix == "" {
+		config.Prefix = DefaultPrefix
+	}
+	return &Guard{config: config, now: time.Now}
+}
+
+// Window returns the accepted age of the timestamps
+func (g *Guard) Window() time.Duration {
+	return g.config.Window
+}
+
+// Check accepts a message with its nonce and timestamp, once
+//
+// @return: ErrOutsideWindow or ErrReplayed if the message is rejected, or
+// the error of the store
+func (g *Guard) Check(ctx context.Context, nonce string, timestamp time.Time) error {
+	if nonce == "" {
+		return ErrNoNonce
+	}
+	if err := g.CheckTimestamp(timestamp); err != nil {
+		return err
+	}
+
+	// The nonce is kept until the timestamp leaves the window, including
+	// the skew so that both ends of the window are covered
+	ttl := timestamp.Add(g.config.Window + g.config.Skew).Sub(g.now())
+	ok, err := g.config.Store.SetNX(ctx, g.config.Prefix+nonce, []byte{1}, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// CheckTimestamp checks that a timestamp is within the window, for
+// messages without nonce
+//
+// @return: ErrOutsideWindow if it is not
+func (g *Guard) CheckTimestamp(timestamp time.Time) error {
+	now := g.now()
+	if now.Sub(timestamp) > g.config.Window || timestamp.Sub(now) > g.config.Skew {
+		return ErrOutsideWindow
+	}
+	return nil
+}
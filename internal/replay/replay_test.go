@@ -0,0 +1,47 @@
+package replay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard_Check(t *testing.T) {
+	ctx := context.Background()
+	memory := store.NewMemory(store.MemoryConfig{})
+	defer memory.Close()
+	g := New(Config{Store: memory, Window: time.Minute, Skew: 10 * time.Second})
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	require.NoError(t, g.Check(ctx, "a", now.Add(-30*time.Second)))
+	require.ErrorIs(t, g.Check(ctx, "a", now), ErrReplayed)
+	require.ErrorIs(t, g.Check(ctx, "", now), ErrNoNonce)
+
+	// Clock skew is tolerated up to the limit in both directions
+	require.NoError(t, g.Check(ctx, "b", now.Add(5*time.Second)))
+	require.ErrorIs(t, g.Check(ctx, "c", now.Add(20*time.Second)), ErrOutsideWindow)
+	require.ErrorIs(t, g.Check(ctx, "d", now.Add(-2*time.Minute)), ErrOutsideWindow)
+
+	// Nonces are kept until their timestamp leaves the window
+	_, err := memory.Get(ctx, DefaultPrefix+"a")
+	require.NoError(t, err)
+	ttlStore := &recordingStore{Store: memory}
+	g.config.Store = ttlStore
+	require.NoError(t, g.Check(ctx, "e", now.Add(-30*time.Second)))
+	require.Equal(t, 40*time.Second, ttlStore.ttl)
+}
+
+// recordingStore records the ttl of the last SetNX
+type recordingStore struct {
+	store.Store
+	ttl time.Duration
+}
+
+func (s *recordingStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.ttl = ttl
+	return s.Store.SetNX(ctx, key, value, ttl)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 type RouteError struct {
@@ -29,6 +30,25 @@ func (e *RouteError) Error() string {
 
 var (
 	ErrRouteNotFound      = errors.New("route not found")
+	ErrMethodNotAllowed   = errors.New("method not allowed")
 	ErrRouteAlreadyExists = errors.New("route already exists")
 	ErrRouteMalformedPath = errors.New("malformed path")
+	ErrRouteParamConflict = errors.New("conflicting route parameter")
 )
+
+// MethodNotAllowedError is returned when a path matches a route in the tree
+// but no handler is registered for the requested method
+//
+// It unwraps to ErrMethodNotAllowed and carries the set of methods that are
+// registered for the matched path, sorted alphabetically.
+type MethodNotAllowedError struct {
+	Allowed []string
+}
+
+func (e *MethodNotAllowedError) Unwrap() error {
+	return ErrMethodNotAllowed
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return fmt.Sprintf("%v (allowed: %s)", ErrMethodNotAllowed, strings.Join(e.Allowed, ", "))
+}
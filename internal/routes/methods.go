@@ -2,23 +2,27 @@ package routes
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/skjdfhkskjds/go-api/internal/types"
 )
 
-// Use adds middleware to the route group
+// Use adds middleware to the route group, recompiling the middleware
+// chains of the routes below it
 //
 // @return: the route group that the middleware was added to
 func (n *RouteNode) Use(middlewares ...types.MiddlewareFunc) *RouteNode {
 	n.middlewares = append(n.middlewares, middlewares...)
+	n.compileChains()
 	return n
 }
 
-// Group creates a new route group with the specified prefix
+// Group creates a new route group with the specified prefix, or returns the
+// existing node for that prefix
 //
-// @return: the newly created route node for the group
-// @return: an error if the route already exists
+// @return: the route node for the group
+// @return: an error if the prefix is malformed
 //
 // @see: RouteNode.Route
 func (n *RouteNode) Group(
@@ -218,14 +222,29 @@ func (n *RouteNode) addRoute(
 ) (*RouteNode, error) {
 	// If we've consumed the entire path, this is our destination
 	if path == "" || path == "/" {
+		// Groups only attach middleware, they don't register a handler
+		if method == "" {
+			if len(middlewares) > 0 {
+				n.Use(middlewares...)
+			}
+			return n, nil
+		}
+
 		// Check if route already exists for this method
-		if _, exists := n.handlers[method]; exists {
+		if n.handlers.get(method) != nil {
 			return nil, ErrRouteAlreadyExists
 		}
 
-		// Store handler and any route-specific middleware on this node
-		n.middlewares = append(n.middlewares, middlewares...)
-		n.handlers[method] = handler
+		// Store handler and any route-specific middleware on this node,
+		// the middleware only applies to this method
+		route := &methodRoute{method: method, handler: handler}
+		if len(middlewares) > 0 {
+			route.middlewares = slices.Clone(middlewares)
+		}
+		n.handlers.set(route)
+		n.pattern = n.Path()
+		n.compileChain(route)
+		n.trackParams()
 		return n, nil
 	}
 
@@ -240,10 +259,16 @@ func (n *RouteNode) addRoute(
 	// Determine node type
 	routeType, paramName := getRouteTypeFromSegment(segment)
 
-	// Parameter route
+	// Parameter route, {id} and :id are equivalent so that both syntaxes
+	// resolve to the same node, but a different name is a conflict
 	if routeType == RouteTypeParam {
+		if !n.paramSyntax.allows(segment) {
+			return nil, ErrRouteMalformedPath
+		}
 		if n.param == nil {
 			n.param = NewRouteNode(segment, routeType, paramName, n)
+		} else if n.param.paramName != paramName {
+			return nil, ErrRouteParamConflict
 		}
 		return n.param.addRoute(method, remaining, handler, middlewares...)
 	}
@@ -252,48 +277,138 @@ func (n *RouteNode) addRoute(
 	if routeType == RouteTypeWildcard {
 		if n.wildcard == nil {
 			n.wildcard = NewRouteNode(segment, routeType, paramName, n)
+		} else if n.wildcard.paramName != paramName {
+			return nil, ErrRouteParamConflict
 		}
 		return n.wildcard.addRoute(method, remaining, handler, middlewares...)
 	}
 
-	// Static route - check if the segment already exists in the static children
-	for _, child := range n.static {
-		if child.path == segment {
-			return child.addRoute(method, remaining, handler, middlewares...)
+	// Static route, an existing child sharing the first segment is
+	// descended into, or split when only some of its segments are shared
+	for i, child := range n.static {
+		if n.indices[i] != path[0] {
+			continue
 		}
+
+		shared := sharedSegments(child.path, path)
+		if shared < 0 {
+			continue
+		}
+		if shared < len(child.path) {
+			child = n.splitStatic(i, shared)
+		}
+		return child.addRoute(method, path[shared:], handler, middlewares...)
 	}
 
-	// Create new child node
-	child := NewRouteNode(segment, RouteTypeStatic, "", n)
+	// New child holding the whole run of static segments
+	run, err := staticRun(path)
+	if err != nil {
+		return nil, err
+	}
+
+	child := NewRouteNode(path[:run], RouteTypeStatic, "", n)
 	n.static = append(n.static, child)
-	return child.addRoute(method, remaining, handler, middlewares...)
+	n.indices = append(n.indices, staticIndex(child.path))
+	return child.addRoute(method, path[run:], handler, middlewares...)
 }
 
-// getPathSegment splits a path into a segment and a remaining path
-func getPathSegment(path string) (string, string) {
-	segments := strings.SplitN(path, "/", 2)
-	if len(segments) == 0 {
-		return "", ""
+// splitStatic splits the static child at the index after its first shared
+// bytes, which end at a segment boundary
+//
+// The child keeps its identity, so that group nodes stay valid, and is
+// moved below a new node holding the shared segments.
+//
+// @return: the new node holding the shared segments
+func (n *RouteNode) splitStatic(index, shared int) *RouteNode {
+	child := n.static[index]
+
+	prefix := NewRouteNode(child.path[:shared], RouteTypeStatic, "", n)
+	child.path = child.path[shared+1:]
+	child.parent = prefix
+	prefix.static = append(prefix.static, child)
+	prefix.indices = append(prefix.indices, staticIndex(child.path))
+
+	n.static[index] = prefix
+	return prefix
+}
+
+// sharedSegments returns the length of the longest run of whole segments
+// at the start of both paths
+//
+// @return: the length in bytes, or -1 if not even the first segment is
+// shared
+func sharedSegments(a, b string) int {
+	shared := -1
+	i := 0
+	for ; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '/' {
+			shared = i
+		}
 	}
+	if (i == len(a) || a[i] == '/') && (i == len(b) || b[i] == '/') {
+		shared = i
+	}
+	return shared
+}
 
-	segment := segments[0]
-	remaining := ""
+// staticRun returns the length of the run of static segments at the start
+// of the path, excluding a trailing slash
+//
+// @return: the length in bytes
+// @return: an error if a segment looks like a malformed parameter
+func staticRun(path string) (int, error) {
+	end := 0
+	for start := 0; ; {
+		segmentEnd := len(path)
+		if i := strings.IndexByte(path[start:], '/'); i >= 0 {
+			segmentEnd = start + i
+		}
 
-	if len(segments) > 1 {
-		remaining = segments[1]
-		if remaining != "" {
-			remaining = "/" + remaining
+		segment := path[start:segmentEnd]
+		if start > 0 && segment == "" && segmentEnd == len(path) {
+			return end, nil
+		}
+		if routeType, _ := getRouteTypeFromSegment(segment); routeType != RouteTypeStatic {
+			return end, nil
 		}
+		if isMalformedParam(segment) {
+			return 0, ErrRouteMalformedPath
+		}
+
+		end = segmentEnd
+		if segmentEnd == len(path) {
+			return end, nil
+		}
+		start = segmentEnd + 1
+	}
+}
+
+// staticIndex returns the byte a static child is indexed by, the first
+// byte of its path, or the separator for an empty segment
+func staticIndex(path string) byte {
+	if path == "" {
+		return '/'
 	}
+	return path[0]
+}
 
-	return segment, remaining
+// getPathSegment splits a path into a segment and a remaining path
+func getPathSegment(path string) (string, string) {
+	// Slicing instead of splitting keeps lookups free of allocations
+	segment, remaining, found := strings.Cut(path, "/")
+	if !found || remaining == "" {
+		return segment, ""
+	}
+	return segment, path[len(segment):]
 }
 
 // getRouteTypeFromSegment determines the node type and parameter name from a
 // segment of a path
 func getRouteTypeFromSegment(segment string) (RouteType, string) {
-	if isPathParam(segment) {
+	if isBracesParam(segment) {
 		return RouteTypeParam, segment[1 : len(segment)-1]
+	} else if isColonParam(segment) {
+		return RouteTypeParam, segment[1:]
 	} else if isWildcard(segment) {
 		return RouteTypeWildcard, segment[1:]
 	}
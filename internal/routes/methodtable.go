@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// standardMethods are the methods with a slot in a methodTable, in the
+// order of methodIndex
+var standardMethods = [...]string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// methodIndex returns the slot of a standard method, -1 for other methods
+func methodIndex(method string) int {
+	switch method {
+	case http.MethodGet:
+		return 0
+	case http.MethodHead:
+		return 1
+	case http.MethodPost:
+		return 2
+	case http.MethodPut:
+		return 3
+	case http.MethodPatch:
+		return 4
+	case http.MethodDelete:
+		return 5
+	case http.MethodConnect:
+		return 6
+	case http.MethodOptions:
+		return 7
+	case http.MethodTrace:
+		return 8
+	}
+	return -1
+}
+
+// methodRoute is the route registered on a node for a method
+type methodRoute struct {
+	method  string
+	handler types.HandlerFunc
+
+	// Route-specific middleware, applied to this method only
+	middlewares []types.MiddlewareFunc
+
+	// Final middleware chain, from the root down to the route, and the
+	// handlers composing it with the handler, compiled at registration so
+	// that lookups and requests neither allocate nor walk the ancestry
+	chain    []types.MiddlewareFunc
+	handlers []types.HandlerFunc
+}
+
+// methodTable holds the routes of a node by method, in a slot per standard
+// method so that lookups need no hashing, and in a list for the others
+type methodTable struct {
+	standard [len(standardMethods)]*methodRoute
+	custom   []*methodRoute
+	count    int
+}
+
+// get returns the route of a method, nil if none
+func (t *methodTable) get(method string) *methodRoute {
+	if i := methodIndex(method); i >= 0 {
+		return t.standard[i]
+	}
+	for _, route := range t.custom {
+		if route.method == method {
+			return route
+		}
+	}
+	return nil
+}
+
+// set registers the route of a method, which must not have one
+func (t *methodTable) set(route *methodRoute) {
+	if i := methodIndex(route.method); i >= 0 {
+		t.standard[i] = route
+	} else {
+		t.custom = append(t.custom, route)
+	}
+	t.count++
+}
+
+// all returns the routes in the order of standardMethods, then of
+// registration
+func (t *methodTable) all() []*methodRoute {
+	routes := make([]*methodRoute, 0, t.count)
+	for _, route := range t.standard {
+		if route != nil {
+			routes = append(routes, route)
+		}
+	}
+	return append(routes, t.custom...)
+}
+
+// methods returns the sorted list of registered methods
+func (t *methodTable) methods() []string {
+	methods := make([]string, 0, t.count)
+	for _, route := range t.all() {
+		methods = append(methods, route.method)
+	}
+	slices.Sort(methods)
+	return methods
+}
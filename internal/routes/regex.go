@@ -3,18 +3,31 @@ package routes
 import "regexp"
 
 const (
-	regexPathParamPattern = `^\{[^}]*\}$`
-	regexWildcardPattern  = `^\*[^/]*$`
+	regexBracesParamPattern = `^\{[^{}/:*]+\}$`
+	regexColonParamPattern  = `^:[^{}/:*]+$`
+	regexWildcardPattern    = `^\*[^/]*$`
 )
 
 var (
-	regexPathParam = regexp.MustCompile(regexPathParamPattern)
-	regexWildcard  = regexp.MustCompile(regexWildcardPattern)
+	regexBracesParam = regexp.MustCompile(regexBracesParamPattern)
+	regexColonParam  = regexp.MustCompile(regexColonParamPattern)
+	regexWildcard    = regexp.MustCompile(regexWildcardPattern)
 )
 
-// isPathParam checks if a segment is a path parameter
+// isPathParam checks if a segment is a path parameter, in either the
+// {id} or the :id syntax
 func isPathParam(segment string) bool {
-	return regexPathParam.MatchString(segment)
+	return isBracesParam(segment) || isColonParam(segment)
+}
+
+// isBracesParam checks if a segment is a path parameter in the {id} syntax
+func isBracesParam(segment string) bool {
+	return regexBracesParam.MatchString(segment)
+}
+
+// isColonParam checks if a segment is a path parameter in the :id syntax
+func isColonParam(segment string) bool {
+	return regexColonParam.MatchString(segment)
 }
 
 // isWildcard checks if a segment is a wildcard
@@ -8,10 +8,16 @@ import (
 
 func TestIsPathParam(t *testing.T) {
 	require.True(t, isPathParam("{id}"))
+	require.True(t, isPathParam(":id"))
 	require.False(t, isPathParam("*id"))
+	require.False(t, isPathParam("{}"))
+	require.False(t, isPathParam(":"))
+	require.False(t, isPathParam("{id"))
+	require.False(t, isPathParam(":{id}"))
 }
 
 func TestIsWildcard(t *testing.T) {
 	require.True(t, isWildcard("*id"))
 	require.False(t, isWildcard("{id}"))
+	require.False(t, isWildcard(":id"))
 }
@@ -1,7 +1,9 @@
 package routes
 
 import (
-	"maps"
+	"errors"
+	"slices"
+	"strings"
 
 	"github.com/skjdfhkskjds/go-api/internal/types"
 )
@@ -13,9 +15,19 @@ import (
 type Route struct {
 	Method      string
 	Path        string
+	Pattern     string // path of the route as registered, e.g. /users/:id
 	Handler     types.HandlerFunc
 	Middlewares []types.MiddlewareFunc
-	PathParams  map[string]string
+	Params      types.Params
+
+	// Middlewares composed with Handler by types.Chain, for
+	// Context.Execute, compiled at registration and shared by the lookups
+	Handlers []types.HandlerFunc
+}
+
+// PathParams returns the path parameters as a map, built on each call
+func (r *Route) PathParams() map[string]string {
+	return r.Params.Map()
 }
 
 // RouteType represents the type of a route node
@@ -42,19 +54,36 @@ type RouteNode struct {
 	// Parameter name (only for param and wildcard routes)
 	paramName string
 
-	// Handlers for different HTTP methods (method -> handler)
-	handlers map[string]types.HandlerFunc
+	// Full path of the node once a handler is registered on it, which
+	// splitting static nodes leaves unchanged
+	pattern string
+
+	// Accepted parameter syntax for routes registered below this node
+	paramSyntax ParamSyntax
 
-	// Middleware accumulated from parent nodes
+	// Routes registered on this node, with their handler and middleware
+	handlers methodTable
+
+	// Middleware of the group ending at this node, applied to every route
+	// below it
 	middlewares []types.MiddlewareFunc
 
+	// Largest number of parameters of a route below the root node, used
+	// to preallocate the parameters of lookups
+	maxParams int
+
 	// Parent node
 	parent *RouteNode
 
-	// Child nodes
+	// Child nodes, static children hold one or more whole segments, e.g.
+	// "api/v1", and never share their first segment
 	static   []*RouteNode
 	param    *RouteNode
 	wildcard *RouteNode
+
+	// First byte of the path of each static child, scanned before the
+	// paths are compared
+	indices []byte
 }
 
 func NewRouteNode(
@@ -63,12 +92,17 @@ func NewRouteNode(
 	paramName string,
 	parent *RouteNode,
 ) *RouteNode {
+	syntax := ParamSyntaxAny
+	if parent != nil {
+		syntax = parent.paramSyntax
+	}
+
 	return &RouteNode{
 		path:        path,
 		routeType:   routeType,
 		paramName:   paramName,
+		paramSyntax: syntax,
 		parent:      parent,
-		handlers:    make(map[string]types.HandlerFunc),
 		middlewares: make([]types.MiddlewareFunc, 0),
 		static:      make([]*RouteNode, 0),
 		param:       nil,
@@ -94,15 +128,18 @@ func (n *RouteNode) Path() string {
 // Find finds a route in the tree
 //
 // @return: the matched route as a Route struct, pathParams are populated
-// @return: an error if the route is not found
+// @return: an error if the route is not found, wrapping a
+// MethodNotAllowedError if the path exists but the method does not
 //
 // @see: RouteNode.find
 func (n *RouteNode) Find(method, path string) (*Route, error) {
 	var err error
 	route := &Route{
-		Method:     method,
-		Path:       path,
-		PathParams: make(map[string]string),
+		Method: method,
+		Path:   path,
+	}
+	if maxParams := n.root().maxParams; maxParams > 0 {
+		route.Params = make(types.Params, 0, maxParams)
 	}
 	route, err = n.find(route, method, path)
 	if err != nil {
@@ -119,14 +156,19 @@ func (n *RouteNode) Find(method, path string) (*Route, error) {
 // @see: RouteNode.Find
 func (n *RouteNode) find(route *Route, method, path string) (*Route, error) {
 	if path == "" || path == "/" {
-		handler, exists := n.handlers[method]
-		if !exists {
+		handler := n.handlers.get(method)
+		if handler == nil {
+			if n.handlers.count > 0 {
+				return nil, &MethodNotAllowedError{Allowed: n.allowedMethods()}
+			}
 			return nil, ErrRouteNotFound
 		}
 
 		route.Method = method
-		route.Handler = handler
-		n.collectMiddlewares(&route.Middlewares)
+		route.Pattern = n.pattern
+		route.Handler = handler.handler
+		route.Middlewares = handler.chain
+		route.Handlers = handler.handlers
 		return route, nil
 	}
 
@@ -135,30 +177,48 @@ func (n *RouteNode) find(route *Route, method, path string) (*Route, error) {
 		path = path[1:]
 	}
 
-	segment, remaining := getPathSegment(path)
+	// Keep track of the most specific error across the branches tried, so
+	// that a method mismatch is not masked by a later missing route
+	var findErr error = ErrRouteNotFound
 
-	// Check static routes first
-	for _, child := range n.static {
-		if child.path == segment {
-			return child.find(route, method, remaining)
+	// Check static routes first, at most one child matches since siblings
+	// never share their first segment
+	for i, index := range n.indices {
+		if index != path[0] {
+			continue
+		}
+
+		child := n.static[i]
+		if !strings.HasPrefix(path, child.path) ||
+			(len(path) > len(child.path) && path[len(child.path)] != '/') {
+			continue
 		}
+
+		result, err := child.find(route, method, path[len(child.path):])
+		if err == nil {
+			return result, nil
+		}
+		findErr = preferError(findErr, err)
+		break
+	}
+
+	if n.param == nil && n.wildcard == nil {
+		return nil, findErr
 	}
+	segment, remaining := getPathSegment(path)
 
 	// Check parameter routes
 	if n.param != nil {
-		// Save the current pathParams state for backtracking
-		originalPathParams := make(map[string]string, len(route.PathParams))
-		maps.Copy(route.PathParams, originalPathParams)
-
-		// Store parameter name to value mapping
-		route.PathParams[n.param.paramName] = segment
+		// Remember the params of this level for backtracking
+		mark := len(route.Params)
+		route.Params = append(route.Params, types.Param{Key: n.param.paramName, Value: segment})
 		result, err := n.param.find(route, method, remaining)
 		if err == nil {
 			return result, nil
 		}
 
-		// Restore pathParams state for backtracking
-		route.PathParams = originalPathParams
+		route.Params = route.Params[:mark]
+		findErr = preferError(findErr, err)
 	}
 
 	// Check wildcard routes
@@ -168,11 +228,63 @@ func (n *RouteNode) find(route *Route, method, path string) (*Route, error) {
 		if remaining != "" {
 			wildcardValue += remaining
 		}
-		route.PathParams[n.wildcard.paramName] = wildcardValue
-		return n.wildcard.find(route, method, "")
+		mark := len(route.Params)
+		route.Params = append(route.Params, types.Param{Key: n.wildcard.paramName, Value: wildcardValue})
+		result, err := n.wildcard.find(route, method, "")
+		if err == nil {
+			return result, nil
+		}
+
+		route.Params = route.Params[:mark]
+		findErr = preferError(findErr, err)
 	}
 
-	return nil, ErrRouteNotFound
+	return nil, findErr
+}
+
+// root returns the root node of the tree
+func (n *RouteNode) root() *RouteNode {
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+// trackParams records the number of parameters of the route ending at the
+// node on the root node
+func (n *RouteNode) trackParams() {
+	count := 0
+	for node := n; node != nil; node = node.parent {
+		if node.routeType == RouteTypeParam || node.routeType == RouteTypeWildcard {
+			count++
+		}
+	}
+
+	if root := n.root(); count > root.maxParams {
+		root.maxParams = count
+	}
+}
+
+// allowedMethods returns the sorted list of methods registered on the node
+func (n *RouteNode) allowedMethods() []string {
+	return n.handlers.methods()
+}
+
+// preferError returns the more specific of two find errors, favouring a
+// method mismatch over a missing route and merging the allowed methods when
+// both branches matched the path
+func preferError(current, next error) error {
+	var currentErr, nextErr *MethodNotAllowedError
+	if !errors.As(current, &currentErr) {
+		return next
+	}
+	if !errors.As(next, &nextErr) {
+		return current
+	}
+
+	allowed := slices.Concat(currentErr.Allowed, nextErr.Allowed)
+	slices.Sort(allowed)
+	return &MethodNotAllowedError{Allowed: slices.Compact(allowed)}
 }
 
 // collectMiddlewares collects middleware from root to current node
@@ -182,3 +294,32 @@ func (n *RouteNode) collectMiddlewares(middlewares *[]types.MiddlewareFunc) {
 	}
 	*middlewares = append(*middlewares, n.middlewares...)
 }
+
+// compileChain compiles the middleware chain of a route registered on the
+// node
+func (n *RouteNode) compileChain(route *methodRoute) {
+	var chain []types.MiddlewareFunc
+	n.collectMiddlewares(&chain)
+	chain = append(chain, route.middlewares...)
+
+	// Clipped so that appending to a matched chain never writes into it
+	route.chain = slices.Clip(chain)
+	route.handlers = types.Chain(route.chain, route.handler)
+}
+
+// compileChains recompiles the middleware chains of every route at or
+// below the node, after the middleware of the node changed
+func (n *RouteNode) compileChains() {
+	for _, route := range n.handlers.all() {
+		n.compileChain(route)
+	}
+	for _, child := range n.static {
+		child.compileChains()
+	}
+	if n.param != nil {
+		n.param.compileChains()
+	}
+	if n.wildcard != nil {
+		n.wildcard.compileChains()
+	}
+}
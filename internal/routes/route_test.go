@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/skjdfhkskjds/go-api/internal/types"
@@ -9,6 +10,15 @@ import (
 )
 
 // Test helper functions
+func tagMiddleware(name string) types.MiddlewareFunc {
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			c.Writer.Header().Add("X-Trace", name)
+			next(c)
+		}
+	}
+}
+
 func newTestHandler(name string) types.HandlerFunc {
 	return func(c *types.Context) {
 		c.String(200, name)
@@ -62,7 +72,7 @@ func TestNewRouteNode(t *testing.T) {
 			require.Equal(t, tt.routeType, node.routeType)
 			require.Equal(t, tt.paramName, node.paramName)
 			require.Equal(t, tt.parent, node.parent)
-			require.NotNil(t, node.handlers)
+			require.Zero(t, node.handlers.count)
 			require.NotNil(t, node.middlewares)
 			require.NotNil(t, node.static)
 		})
@@ -84,10 +94,15 @@ func TestRouteNode_Route_StaticRoutes(t *testing.T) {
 	require.Equal(t, "/users", route.Path)
 	require.NotNil(t, route.Handler)
 
-	// Test route not found
+	// Test method not allowed on an existing path
 	_, err = root.Find(http.MethodPost, "/users")
 	require.Error(t, err)
-	require.EqualError(t, err, NewRouteError(http.MethodPost, "/users", ErrRouteNotFound).Error())
+	require.ErrorIs(t, err, ErrMethodNotAllowed)
+
+	// Test route not found
+	_, err = root.Find(http.MethodGet, "/groups")
+	require.Error(t, err)
+	require.EqualError(t, err, NewRouteError(http.MethodGet, "/groups", ErrRouteNotFound).Error())
 
 	// Test adding duplicate route
 	_, err = root.Route(http.MethodGet, "/users", handler)
@@ -137,15 +152,81 @@ func TestRouteNode_Route_ParameterRoutes(t *testing.T) {
 	// Test finding parameter routes
 	route, err := root.Find(http.MethodGet, "/users/123")
 	require.NoError(t, err)
-	require.Equal(t, 1, len(route.PathParams))
-	require.Equal(t, "123", route.PathParams["id"])
+	require.Equal(t, 1, len(route.Params))
+	require.Equal(t, "123", route.PathParams()["id"])
 
 	// Test nested parameter routes
 	route, err = root.Find(http.MethodGet, "/users/456/posts/789")
 	require.NoError(t, err)
-	require.Equal(t, 2, len(route.PathParams))
-	require.Equal(t, "456", route.PathParams["id"])
-	require.Equal(t, "789", route.PathParams["postId"])
+	require.Equal(t, 2, len(route.Params))
+	require.Equal(t, "456", route.PathParams()["id"])
+	require.Equal(t, "789", route.PathParams()["postId"])
+}
+
+func TestRouteNode_Route_ColonParameterRoutes(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+
+	_, err := root.Route(http.MethodGet, "/users/:id/posts/:postId", newTestHandler("userPost"))
+	require.NoError(t, err)
+
+	route, err := root.Find(http.MethodGet, "/users/456/posts/789")
+	require.NoError(t, err)
+	require.Equal(t, "456", route.PathParams()["id"])
+	require.Equal(t, "789", route.PathParams()["postId"])
+
+	// Both syntaxes resolve to the same parameter node
+	_, err = root.Route(http.MethodDelete, "/users/{id}/posts/{postId}", newTestHandler("delete"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodGet, "/users/{id}/posts/{postId}", newTestHandler("duplicate"))
+	require.ErrorIs(t, err, ErrRouteAlreadyExists)
+}
+
+func TestRouteNode_Route_ParamConflicts(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+
+	_, err := root.Route(http.MethodGet, "/users/:id", newTestHandler("user"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodGet, "/users/{userId}/posts", newTestHandler("posts"))
+	require.ErrorIs(t, err, ErrRouteParamConflict)
+
+	_, err = root.Route(http.MethodGet, "/files/*path", newTestHandler("files"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodPost, "/files/*name", newTestHandler("upload"))
+	require.ErrorIs(t, err, ErrRouteParamConflict)
+
+	for _, path := range []string{"/bad/{id", "/bad/:", "/bad/{}", "/bad/x{id}"} {
+		_, err = root.Route(http.MethodGet, path, newTestHandler("bad"))
+		require.ErrorIs(t, err, ErrRouteMalformedPath, path)
+	}
+}
+
+func TestRouteNode_SetParamSyntax(t *testing.T) {
+	tests := []struct {
+		syntax ParamSyntax
+		colon  bool
+		braces bool
+	}{
+		{ParamSyntaxAny, true, true},
+		{ParamSyntaxColon, true, false},
+		{ParamSyntaxBraces, false, true},
+	}
+
+	for _, tt := range tests {
+		root := NewRouteNode("", RouteTypeNone, "", nil)
+		group, err := root.Group("/api")
+		require.NoError(t, err)
+
+		// The restriction applies to existing nodes as well
+		root.SetParamSyntax(tt.syntax)
+
+		_, err = group.Route(http.MethodGet, "/users/:id", newTestHandler("colon"))
+		require.Equal(t, tt.colon, err == nil)
+		_, err = group.Route(http.MethodGet, "/posts/{id}", newTestHandler("braces"))
+		require.Equal(t, tt.braces, err == nil)
+	}
+
+	_, err := ParseParamSyntax("dollar")
+	require.Error(t, err)
 }
 
 func TestRouteNode_Route_WildcardRoutes(t *testing.T) {
@@ -170,8 +251,8 @@ func TestRouteNode_Route_WildcardRoutes(t *testing.T) {
 		t.Run(tt.path, func(t *testing.T) {
 			route, err := root.Find(http.MethodGet, tt.path)
 			require.NoError(t, err)
-			require.Equal(t, 1, len(route.PathParams))
-			require.Equal(t, tt.expected, route.PathParams["path"])
+			require.Equal(t, 1, len(route.Params))
+			require.Equal(t, tt.expected, route.PathParams()["path"])
 		})
 	}
 }
@@ -195,19 +276,19 @@ func TestRouteNode_Route_RoutePriority(t *testing.T) {
 	// Test that static routes have highest priority
 	route, err := root.Find(http.MethodGet, "/users/admin")
 	require.NoError(t, err)
-	require.Empty(t, route.PathParams)
+	require.Empty(t, route.Params)
 
 	// Test that param routes have higher priority than wildcard
 	route, err = root.Find(http.MethodGet, "/users/123")
 	require.NoError(t, err)
-	require.Equal(t, 1, len(route.PathParams))
-	require.Equal(t, "123", route.PathParams["id"])
+	require.Equal(t, 1, len(route.Params))
+	require.Equal(t, "123", route.PathParams()["id"])
 
 	// Test that wildcard matches anything else
 	route, err = root.Find(http.MethodGet, "/users/some/long/path")
 	require.NoError(t, err)
-	require.Equal(t, 1, len(route.PathParams))
-	require.Equal(t, "some/long/path", route.PathParams["path"])
+	require.Equal(t, 1, len(route.Params))
+	require.Equal(t, "some/long/path", route.PathParams()["path"])
 }
 
 func TestRouteNode_Route_MultipleMethodsSamePath(t *testing.T) {
@@ -235,10 +316,67 @@ func TestRouteNode_Route_MultipleMethodsSamePath(t *testing.T) {
 		require.Equal(t, method, route.Method)
 	}
 
-	// Test that non-existent method returns error
+	// Test that non-existent method returns the allowed methods
 	_, err := root.Find(http.MethodOptions, "/users")
 	require.Error(t, err)
-	require.EqualError(t, err, NewRouteError(http.MethodOptions, "/users", ErrRouteNotFound).Error())
+	require.ErrorIs(t, err, ErrMethodNotAllowed)
+
+	var methodErr *MethodNotAllowedError
+	require.ErrorAs(t, err, &methodErr)
+	require.Equal(t, []string{
+		http.MethodDelete,
+		http.MethodGet,
+		http.MethodPatch,
+		http.MethodPost,
+		http.MethodPut,
+	}, methodErr.Allowed)
+}
+
+func TestRouteNode_Find_CustomMethods(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+
+	// Methods outside net/http's constants are kept apart from the
+	// standard ones
+	for _, method := range []string{"PURGE", http.MethodGet, "PROPFIND"} {
+		_, err := root.Route(method, "/cache", newTestHandler(method))
+		require.NoError(t, err)
+	}
+	_, err := root.Route("PURGE", "/cache", newTestHandler("again"))
+	require.ErrorIs(t, err, ErrRouteAlreadyExists)
+
+	route, err := root.Find("PROPFIND", "/cache")
+	require.NoError(t, err)
+	require.Equal(t, "PROPFIND", route.Method)
+
+	_, err = root.Find("MKCOL", "/cache")
+	var methodErr *MethodNotAllowedError
+	require.ErrorAs(t, err, &methodErr)
+	require.Equal(t, []string{http.MethodGet, "PROPFIND", "PURGE"}, methodErr.Allowed)
+}
+
+func TestRouteNode_Find_MethodNotAllowedAcrossBranches(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+
+	_, err := root.Route(http.MethodGet, "/files/{id}", newTestHandler("file"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodPost, "/files/*path", newTestHandler("upload"))
+	require.NoError(t, err)
+
+	// The param branch matches the path but not the method, the wildcard
+	// branch matches both
+	route, err := root.Find(http.MethodPost, "/files/123")
+	require.NoError(t, err)
+	require.Equal(t, "123", route.PathParams()["path"])
+
+	// Neither branch matches the method, the allowed methods are merged
+	_, err = root.Find(http.MethodDelete, "/files/123")
+	var methodErr *MethodNotAllowedError
+	require.ErrorAs(t, err, &methodErr)
+	require.Equal(t, []string{http.MethodGet, http.MethodPost}, methodErr.Allowed)
+
+	// Unmatched paths are still reported as not found
+	_, err = root.Find(http.MethodGet, "/other")
+	require.ErrorIs(t, err, ErrRouteNotFound)
 }
 
 func TestRouteNode_Use_Middleware(t *testing.T) {
@@ -370,6 +508,7 @@ func TestGetRouteTypeFromSegment(t *testing.T) {
 		{"users", RouteTypeStatic, ""},
 		{"{id}", RouteTypeParam, "id"},
 		{"{userId}", RouteTypeParam, "userId"},
+		{":id", RouteTypeParam, "id"},
 		{"*path", RouteTypeWildcard, "path"},
 		{"*filepath", RouteTypeWildcard, "filepath"},
 	}
@@ -428,3 +567,152 @@ func BenchmarkRouteNode_FindWildcardRoute(b *testing.B) {
 		root.Find(http.MethodGet, "/files/documents/file.txt")
 	}
 }
+
+func TestRouteNode_Find_ParamBacktracking(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+
+	_, err := root.Route(http.MethodGet, "/files/:id/meta", newTestHandler("meta"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodGet, "/files/*path", newTestHandler("file"))
+	require.NoError(t, err)
+
+	// The param branch is tried first and its value must not leak into
+	// the wildcard match
+	route, err := root.Find(http.MethodGet, "/files/a/b")
+	require.NoError(t, err)
+	require.Equal(t, types.Params{{Key: "path", Value: "a/b"}}, route.Params)
+	require.Equal(t, "/files/*path", route.Pattern)
+
+	route, err = root.Find(http.MethodGet, "/files/a/meta")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"id": "a"}, route.PathParams())
+	require.Equal(t, "/files/:id/meta", route.Pattern)
+}
+
+func TestRouteNode_Find_Allocations(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+	_, err := root.Route(http.MethodGet, "/api/v1/users/:id/posts/:postId", newTestHandler("post"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodGet, "/api/v1/status", newTestHandler("status"))
+	require.NoError(t, err)
+
+	// Only the route and its preallocated params are allocated
+	allocs := testing.AllocsPerRun(100, func() {
+		root.Find(http.MethodGet, "/api/v1/users/1/posts/2")
+	})
+	require.LessOrEqual(t, allocs, 2.0)
+
+	allocs = testing.AllocsPerRun(100, func() {
+		root.Find(http.MethodGet, "/api/v1/status")
+	})
+	require.LessOrEqual(t, allocs, 2.0)
+}
+
+func TestRouteNode_Route_CompressedStaticPaths(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+
+	users, err := root.Route(http.MethodGet, "/api/v1/users", newTestHandler("users"))
+	require.NoError(t, err)
+	require.Len(t, root.static, 1)
+	require.Equal(t, "api/v1/users", users.path)
+
+	// Sharing some segments splits the node, which keeps its identity
+	_, err = root.Route(http.MethodGet, "/api/v1/posts", newTestHandler("posts"))
+	require.NoError(t, err)
+	require.Equal(t, "users", users.path)
+	require.Equal(t, "api/v1", users.parent.path)
+	require.Equal(t, "/api/v1/users", users.Path())
+	require.Equal(t, "/api/v1/users", users.pattern)
+
+	// A prefix that is not a whole segment is not shared
+	_, err = root.Route(http.MethodGet, "/apidocs", newTestHandler("docs"))
+	require.NoError(t, err)
+	require.Len(t, root.static, 2)
+	require.Equal(t, []byte("aa"), root.indices)
+
+	// Groups split nodes too, their middleware only applies below them
+	group, err := root.Group("/api", tagMiddleware("api"))
+	require.NoError(t, err)
+	require.Equal(t, "api", group.path)
+	require.Same(t, group, users.parent.parent)
+
+	for path, name := range map[string]string{
+		"/api/v1/users":  "users",
+		"/api/v1/posts/": "posts",
+		"/apidocs":       "docs",
+	} {
+		route, err := root.Find(http.MethodGet, path)
+		require.NoError(t, err, path)
+		w := httptest.NewRecorder()
+		route.Handler(&types.Context{Writer: w})
+		require.Equal(t, name, w.Body.String())
+	}
+
+	route, err := root.Find(http.MethodGet, "/api/v1/users")
+	require.NoError(t, err)
+	require.Len(t, route.Middlewares, 1)
+	route, err = root.Find(http.MethodGet, "/apidocs")
+	require.NoError(t, err)
+	require.Empty(t, route.Middlewares)
+
+	for _, path := range []string{"/api", "/api/v1", "/api/v1/user", "/api/v1/usersx", "/apid"} {
+		_, err = root.Find(http.MethodGet, path)
+		require.ErrorIs(t, err, ErrRouteNotFound, path)
+	}
+}
+
+func TestRouteNode_Find_StaticBacktracking(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+
+	_, err := root.Route(http.MethodGet, "/users/admin/settings", newTestHandler("settings"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodGet, "/users/:id/profile", newTestHandler("profile"))
+	require.NoError(t, err)
+	_, err = root.Route(http.MethodPost, "/users/:id", newTestHandler("update"))
+	require.NoError(t, err)
+
+	// A static prefix that does not lead to a route falls back to the
+	// parameter branch
+	route, err := root.Find(http.MethodGet, "/users/admin/profile")
+	require.NoError(t, err)
+	require.Equal(t, "admin", route.PathParams()["id"])
+
+	route, err = root.Find(http.MethodPost, "/users/admin")
+	require.NoError(t, err)
+	require.Equal(t, "admin", route.PathParams()["id"])
+}
+
+func TestRouteNode_Find_CompiledMiddlewareChains(t *testing.T) {
+	root := NewRouteNode("", RouteTypeNone, "", nil)
+	api, err := root.Group("/api", tagMiddleware("api"))
+	require.NoError(t, err)
+	_, err = api.Route(http.MethodGet, "/users/:id", newTestHandler("get"), tagMiddleware("cache"))
+	require.NoError(t, err)
+	_, err = api.Route(http.MethodDelete, "/users/:id", newTestHandler("delete"), tagMiddleware("audit"))
+	require.NoError(t, err)
+
+	trace := func(method string) []string {
+		route, err := root.Find(method, "/api/users/1")
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		c := &types.Context{Writer: w}
+		c.Execute(route.Handlers)
+		return w.Header().Values("X-Trace")
+	}
+
+	// Route-specific middleware only applies to its method
+	require.Equal(t, []string{"api", "cache"}, trace(http.MethodGet))
+	require.Equal(t, []string{"api", "audit"}, trace(http.MethodDelete))
+
+	// Middleware added to an ancestor after registration is compiled into
+	// the chains below it, before the route-specific middleware
+	root.Use(tagMiddleware("root"))
+	api.Use(tagMiddleware("auth"))
+	require.Equal(t, []string{"root", "api", "auth", "cache"}, trace(http.MethodGet))
+
+	// Matched chains are shared and never traversed again
+	allocs := testing.AllocsPerRun(100, func() {
+		root.Find(http.MethodGet, "/api/users/1")
+	})
+	require.LessOrEqual(t, allocs, 2.0)
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParamSyntax restricts the accepted path parameter syntax
+type ParamSyntax int
+
+const (
+	ParamSyntaxAny    ParamSyntax = iota // both :id and {id}
+	ParamSyntaxColon                     // only :id
+	ParamSyntaxBraces                    // only {id}
+)
+
+// ParseParamSyntax parses a syntax name: any, colon or braces, the empty
+// string is treated as any
+func ParseParamSyntax(name string) (ParamSyntax, error) {
+	switch strings.ToLower(name) {
+	case "", "any":
+		return ParamSyntaxAny, nil
+	case "colon":
+		return ParamSyntaxColon, nil
+	case "braces":
+		return ParamSyntaxBraces, nil
+	default:
+		return ParamSyntaxAny, fmt.Errorf("unknown route parameter syntax: %q", name)
+	}
+}
+
+// allows reports whether a parameter segment uses an accepted syntax
+func (s ParamSyntax) allows(segment string) bool {
+	switch s {
+	case ParamSyntaxColon:
+		return isColonParam(segment)
+	case ParamSyntaxBraces:
+		return isBracesParam(segment)
+	default:
+		return isPathParam(segment)
+	}
+}
+
+// isMalformedParam reports whether a segment that is not a valid parameter
+// looks like an attempt at one, e.g. "{id" or ":"
+func isMalformedParam(segment string) bool {
+	return strings.ContainsAny(segment, "{}") || strings.HasPrefix(segment, ":")
+}
+
+// SetParamSyntax restricts the parameter syntax accepted when registering
+// routes below the node, including nodes that already exist
+//
+// @return: the route node, for method chaining
+func (n *RouteNode) SetParamSyntax(syntax ParamSyntax) *RouteNode {
+	n.paramSyntax = syntax
+	for _, child := range n.static {
+		child.SetParamSyntax(syntax)
+	}
+	if n.param != nil {
+		n.param.SetParamSyntax(syntax)
+	}
+	if n.wildcard != nil {
+		n.wildcard.SetParamSyntax(syntax)
+	}
+	return n
+}
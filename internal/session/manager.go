@@ -0,0 +1,152 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Defaults of the session configuration
+const (
+	DefaultCookieName      = "session"
+	DefaultIdleTimeout     = 30 * time.Minute
+	DefaultAbsoluteTimeout = 24 * time.Hour
+)
+
+// Config configures a Manager
+type Config struct {
+	// Store keeps the sessions, a memory store if nil
+	Store Store
+
+	// Cookie identifying the sessions, DefaultCookieName if empty
+	CookieName string
+
+	// IdleTimeout ends the sessions without requests for that long,
+	// DefaultIdleTimeout if 0
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout ends the sessions that long after they started,
+	// whatever their activity, DefaultAbsoluteTimeout if 0
+	AbsoluteTimeout time.Duration
+
+	// Attributes of the cookie, "/" for an empty Path and SameSite Lax if
+	// unset
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Manager loads the sessions of the requests and saves them with their
+// responses, see middleware.Sessions
+type Manager struct {
+	config Config
+	now    func() time.Time
+}
+
+// NewManager creates a manager, filling in defaults for unset fields
+func NewManager(config Config) *Manager {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCookieName
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultIdleTimeout
+	}
+	if config.AbsoluteTimeout <= 0 {
+		config.AbsoluteTimeout = DefaultAbsoluteTimeout
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+	return &Manager{config: config, now: time.Now}
+}
+
+// Load returns the session of the request, a new one if it has none or
+// its session expired
+//
+// @return: the error of the store, with a new session
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	now := m.now()
+	cookie, err := r.Cookie(m.config.CookieName)
+	if err != nil || cookie.Value == "" {
+		return newSession(now), nil
+	}
+
+	record, err := m.config.Store.Load(r.Context(), cookie.Value)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			err = nil
+		}
+		return newSession(now), err
+	}
+	if now.Sub(record.LastSeen) > m.config.IdleTimeout || now.Sub(record.Created) > m.config.AbsoluteTimeout {
+		// The stale state is discarded with the cookie on save
+		s := newSession(now)
+		s.loadedFrom, s.regenerated = cookie.Value, true
+		return s, nil
+	}
+	if record.Values == nil {
+		record.Values = map[string]any{}
+	}
+	return &Session{record: *record, loadedFrom: cookie.Value}, nil
+}
+
+// Save saves the session and sets its cookie on the response, before the
+// header is written
+//
+// Sessions are saved when they changed, and when their last request is
+// older than a tenth of the idle timeout so that the timeout is extended
+// without saving every request. New sessions are only saved once they hold
+// values.
+func (m *Manager) Save(w http.ResponseWriter, r *http.Request, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := m.now()
+	ctx := r.Context()
+	if s.destroyed || (s.loadedFrom != "" && s.regenerated) {
+		if err := m.config.Store.Delete(ctx, s.loadedFrom); err != nil {
+			return err
+		}
+	}
+	if s.destroyed || (s.loadedFrom == "" || s.regenerated) && len(s.record.Values) == 0 {
+		if s.loadedFrom != "" {
+			m.setCookie(w, "", -1)
+			s.loadedFrom = ""
+		}
+		return nil
+	}
+	if !s.modified && now.Sub(s.record.LastSeen) < m.config.IdleTimeout/10 {
+		return nil
+	}
+
+	s.record.LastSeen = now
+	ttl := max(time.Second, min(m.config.IdleTimeout, s.record.Created.Add(m.config.AbsoluteTimeout).Sub(now)))
+	value, err := m.config.Store.Save(ctx, &s.record, ttl)
+	if err != nil {
+		return err
+	}
+	m.setCookie(w, value, int(ttl/time.Second))
+	s.loadedFrom, s.modified, s.regenerated = value, false, false
+	return nil
+}
+
+// setCookie sets the session cookie, removing it if maxAge is negative
+func (m *Manager) setCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    value,
+		Path:     m.config.Path,
+		Domain:   m.config.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.config.Secure,
+		HttpOnly: true,
+		SameSite: m.config.SameSite,
+	})
+}
@@ -0,0 +1,147 @@
+// Package session keeps the state of the clients across requests in
+// sessions identified by a cookie, stored in the cookie itself or on the
+// server, see Store
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Record is the stored state of a session
+//
+// Values are encoded as JSON, so numbers are read back as float64 and
+// structs as maps unless the stores keep them in memory.
+type Record struct {
+	ID       string         `json:"id"`
+	Values   map[string]any `json:"values"`
+	Created  time.Time      `json:"created"`
+	LastSeen time.Time      `json:"last_seen"`
+}
+
+// Session is the session of a request, safe for concurrent use
+type Session struct {
+	mu     sync.RWMutex
+	record Record
+
+	// Cookie value the session was loaded from, or of the expired session
+	// it replaces, empty for new sessions
+	loadedFrom string
+	isNew      bool
+
+	// State of the session since it was loaded
+	modified    bool
+	regenerated bool
+	destroyed   bool
+}
+
+// newSession creates an empty session
+func newSession(now time.Time) *Session {
+	return &Session{
+		record:   Record{ID: newID(), Values: map[string]any{}, Created: now, LastSeen: now},
+		isNew:    true,
+		modified: true,
+	}
+}
+
+// ID returns the id of the session, which changes when it is regenerated
+func (s *Session) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.record.ID
+}
+
+// Created returns when the session started
+func (s *Session) Created() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.record.Created
+}
+
+// IsNew reports whether the session started with this request
+func (s *Session) IsNew() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.isNew
+}
+
+// Get returns a value of the session, and false if it is missing
+func (s *Session) Get(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.record.Values[key]
+	return value, ok
+}
+
+// GetString returns a string value of the session, empty if it is missing
+// or not a string
+func (s *Session) GetString(key string) string {
+	value, _ := s.Get(key)
+	str, _ := value.(string)
+	return str
+}
+
+// Set sets a value of the session
+func (s *Session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record.Values[key] = value
+	s.modified = true
+}
+
+// Delete removes a value of the session
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.record.Values[key]; ok {
+		delete(s.record.Values, key)
+		s.modified = true
+	}
+}
+
+// Clear removes the values of the session, keeping its id
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.record.Values)
+	s.modified = true
+}
+
+// Values returns a copy of the values of the session
+func (s *Session) Values() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.record.Values)
+}
+
+// Regenerate gives the session a new id, keeping its values, and discards
+// the stored state under the old id
+//
+// Call it when the privileges of the session change, e.g. on login or
+// logout, so that an id known before, e.g. planted by an attacker, does not
+// gain them, see session fixation.
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record.ID = newID()
+	s.regenerated = true
+	s.modified = true
+}
+
+// Destroy ends the session, removing it from the store and the client
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.record.Values)
+	s.destroyed = true
+}
+
+// newID returns a random session id of 256 bits
+func newID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
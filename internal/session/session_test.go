@@ -0,0 +1,118 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// roundTrip loads the session of a request with the cookie, lets the
+// handler use it and saves it
+//
+// @return: the cookie of the response, nil if none was set
+func roundTrip(t *testing.T, m *Manager, cookie *http.Cookie, handler func(s *Session)) *http.Cookie {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	s, err := m.Load(r)
+	require.NoError(t, err)
+	handler(s)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, m.Save(w, r, s))
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		return cookies[0]
+	}
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	for name, store := range map[string]Store{
+		"memory": NewMemoryStore(),
+		"cookie": NewCookieStore([]byte("secret")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			m := NewManager(Config{Store: store})
+			m.now = func() time.Time { return now }
+
+			// Sessions without values are not saved
+			require.Nil(t, roundTrip(t, m, nil, func(s *Session) { require.True(t, s.IsNew()) }))
+
+			cookie := roundTrip(t, m, nil, func(s *Session) { s.Set("user", "ada") })
+			require.NotNil(t, cookie)
+			require.True(t, cookie.HttpOnly)
+			require.Equal(t, int(DefaultIdleTimeout/time.Second), cookie.MaxAge)
+
+			var id string
+			roundTrip(t, m, cookie, func(s *Session) {
+				require.False(t, s.IsNew())
+				require.Equal(t, "ada", s.GetString("user"))
+				id = s.ID()
+			})
+
+			// Regenerating keeps the values under a new id, the old one
+			// being discarded by server stores
+			regenerated := roundTrip(t, m, cookie, func(s *Session) { s.Regenerate() })
+			require.NotNil(t, regenerated)
+			roundTrip(t, m, regenerated, func(s *Session) {
+				require.Equal(t, "ada", s.GetString("user"))
+				require.NotEqual(t, id, s.ID())
+			})
+			if name == "memory" {
+				roundTrip(t, m, cookie, func(s *Session) { require.True(t, s.IsNew()) })
+			}
+
+			// Destroying removes the cookie
+			destroyed := roundTrip(t, m, regenerated, func(s *Session) { s.Destroy() })
+			require.Equal(t, -1, destroyed.MaxAge)
+			if name == "memory" {
+				roundTrip(t, m, regenerated, func(s *Session) { require.True(t, s.IsNew()) })
+			}
+		})
+	}
+}
+
+func TestManager_Expiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewManager(Config{IdleTimeout: 10 * time.Minute, AbsoluteTimeout: time.Hour})
+	m.now = func() time.Time { return now }
+	cookie := roundTrip(t, m, nil, func(s *Session) { s.Set("user", "ada") })
+
+	// Requests extend the idle timeout, not the absolute one
+	for range 6 {
+		now = now.Add(9 * time.Minute)
+		if refreshed := roundTrip(t, m, cookie, func(s *Session) {
+			require.Equal(t, "ada", s.GetString("user"))
+		}); refreshed != nil {
+			cookie = refreshed
+		}
+	}
+	now = now.Add(7 * time.Minute)
+	expired := roundTrip(t, m, cookie, func(s *Session) { require.True(t, s.IsNew()) })
+	require.Equal(t, -1, expired.MaxAge)
+
+	// Idle sessions expire
+	cookie = roundTrip(t, m, nil, func(s *Session) { s.Set("user", "ada") })
+	now = now.Add(11 * time.Minute)
+	roundTrip(t, m, cookie, func(s *Session) { require.True(t, s.IsNew()) })
+}
+
+func TestCookieStore_Tampered(t *testing.T) {
+	store := NewCookieStore([]byte("secret"))
+	value, err := store.Save(t.Context(), &Record{ID: "id", Values: map[string]any{"admin": false}}, time.Hour)
+	require.NoError(t, err)
+
+	record, err := store.Load(t.Context(), value)
+	require.NoError(t, err)
+	require.Equal(t, false, record.Values["admin"])
+
+	_, err = NewCookieStore([]byte("other")).Load(t.Context(), value)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Load(t.Context(), value[:len(value)-2]+"AA")
+	require.ErrorIs(t, err, ErrNotFound)
+}
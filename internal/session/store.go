@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+)
+
+// DefaultPrefix prefixes the keys of the sessions of a ServerStore
+const DefaultPrefix = "session:"
+
+// ErrNotFound is returned by the stores for missing, expired or tampered
+// sessions
+var ErrNotFound = errors.New("session: not found")
+
+// Store keeps the sessions, identified by the value of their cookie
+type Store interface {
+	// Load returns the session of the cookie value
+	//
+	// @return: ErrNotFound if there is none
+	Load(ctx context.Context, value string) (*Record, error)
+
+	// Save stores the session for the ttl
+	//
+	// @return: the value of the cookie of the session
+	Save(ctx context.Context, record *Record, ttl time.Duration) (string, error)
+
+	// Delete removes the session of the cookie value
+	Delete(ctx context.Context, value string) error
+}
+
+// CookieStore keeps the sessions in their cookie, encrypted and
+// authenticated with AES-GCM, so that no state is kept on the server
+//
+// The cookies hold at most about 4KB, and sessions cannot be revoked
+// before they expire, a ServerStore doing both.
+type CookieStore struct {
+	aead cipher.AEAD
+}
+
+// NewCookieStore creates a cookie store with the secret, shared by the
+// instances of the application
+func NewCookieStore(secret []byte) *CookieStore {
+	key := sha256.Sum256(secret)
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	return &CookieStore{aead: aead}
+}
+
+// cookieRecord is a record sealed in a cookie, with its expiry
+type cookieRecord struct {
+	Record
+	Expires time.Time `json:"expires"`
+}
+
+// Load implements Store
+func (s *CookieStore) Load(_ context.Context, value string) (*Record, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, ErrNotFound
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	data, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var record cookieRecord
+	if err := json.Unmarshal(data, &record); err != nil || time.Now().After(record.Expires) {
+		return nil, ErrNotFound
+	}
+	return &record.Record, nil
+}
+
+// Save implements Store
+func (s *CookieStore) Save(_ context.Context, record *Record, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(cookieRecord{Record: *record, Expires: time.Now().Add(ttl)})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(data)+s.aead.Overhead())
+	rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+// Delete implements Store, the cookie being removed by the Manager
+func (s *CookieStore) Delete(context.Context, string) error {
+	return nil
+}
+
+// ServerStore keeps the sessions in a store.Store, e.g. a redisstore.Store
+// shared by the instances of the application, the cookies holding their id
+type ServerStore struct {
+	backend store.Store
+	prefix  string
+}
+
+// NewServerStore creates a server store keeping the sessions in the
+// backend, under keys prefixed with DefaultPrefix
+func NewServerStore(backend store.Store) *ServerStore {
+	return &ServerStore{backend: backend, prefix: DefaultPrefix}
+}
+
+// NewMemoryStore creates a server store keeping the sessions in memory, lost
+// on restart and not shared by the instances of the application
+func NewMemoryStore() *ServerStore {
+	return NewServerStore(store.NewMemory(store.MemoryConfig{}))
+}
+
+// Load implements Store
+func (s *ServerStore) Load(ctx context.Context, value string) (*Record, error) {
+	data, err := s.backend.Get(ctx, s.prefix+value)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Save implements Store
+func (s *ServerStore) Save(ctx context.Context, record *Record, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	if err := s.backend.Set(ctx, s.prefix+record.ID, data, ttl); err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+// Delete implements Store
+func (s *ServerStore) Delete(ctx context.Context, value string) error {
+	return s.backend.Delete(ctx, s.prefix+value)
+}
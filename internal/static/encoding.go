@@ -0,0 +1,58 @@
+package static
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Encoding describes a precompressed sidecar variant of a file
+type Encoding struct {
+	// Content coding advertised in Accept-Encoding and Content-Encoding
+	Name string
+
+	// Extension appended to the original file name to find the sidecar
+	Extension string
+}
+
+// DefaultEncodings are the sidecar variants looked up by default, in order
+// of preference
+var DefaultEncodings = []Encoding{
+	{Name: "br", Extension: ".br"},
+	{Name: "gzip", Extension: ".gz"},
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value allows
+// the given content coding
+//
+// Codings listed with q=0 are refused, and "*" matches any coding not
+// listed explicitly.
+func acceptsEncoding(header, coding string) bool {
+	wildcard := false
+	for part := range strings.SplitSeq(header, ",") {
+		name, q := parseCoding(part)
+		switch {
+		case strings.EqualFold(name, coding):
+			return q > 0
+		case name == "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+// parseCoding splits a single Accept-Encoding element into its coding and
+// quality value
+func parseCoding(part string) (string, float64) {
+	name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+	q := 1.0
+	for param := range strings.SplitSeq(params, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			q = v
+		}
+	}
+	return strings.TrimSpace(name), q
+}
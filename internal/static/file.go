@@ -0,0 +1,160 @@
+package static
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sync"
+)
+
+// ErrIsDirectory is returned when a file operation is attempted on a directory
+var ErrIsDirectory = errors.New("is a directory")
+
+// ServeFile serves the named file from the file system
+//
+// When encodings are given, a sidecar file (e.g. app.js.br) is served in
+// place of the original if the client accepts its content coding, with the
+// Content-Encoding and Vary headers set accordingly. Sidecars that are
+// missing or unreadable fall back to the original file.
+//
+// An ETag derived from the served file's size and modification time is set
+// before delegating to ServeContent, so conditional, If-Range and
+// multi-range requests are honored for both the original and the
+// precompressed variants.
+// Files without a modification time, such as those of an embed.FS, are
+// tagged by a hash of their content instead.
+//
+// @return: an error if the original file could not be opened, or
+// ErrIsDirectory if it names a directory
+func ServeFile(
+	w http.ResponseWriter,
+	r *http.Request,
+	fsys http.FileSystem,
+	name string,
+	encodings []Encoding,
+) error {
+	return serveFile(w, r, fsys, name, encodings, nil)
+}
+
+// serveFile implements ServeFile, caching content hashes in etags if set
+func serveFile(
+	w http.ResponseWriter,
+	r *http.Request,
+	fsys http.FileSystem,
+	name string,
+	encodings []Encoding,
+	etags *sync.Map,
+) error {
+	file, info, err := openFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if len(encodings) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	for _, encoding := range encodings {
+		if !acceptsEncoding(acceptEncoding, encoding.Name) {
+			continue
+		}
+
+		sidecar, sidecarInfo, err := openFile(fsys, name+encoding.Extension)
+		if err != nil {
+			continue
+		}
+		defer sidecar.Close()
+
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", encoding.Name)
+		tag, err := etag(sidecar, sidecarInfo, name+encoding.Extension, encoding.Name, etags)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", tag)
+		ServeContent(w, r, name, sidecarInfo.ModTime(), sidecar)
+		return nil
+	}
+
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	tag, err := etag(file, info, name, "", etags)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", tag)
+	ServeContent(w, r, name, info.ModTime(), file)
+	return nil
+}
+
+// openFile opens a regular file from the file system
+//
+// @return: the open file and its file info
+// @return: an error if the file could not be opened, or ErrIsDirectory
+func openFile(fsys http.FileSystem, name string) (http.File, fs.FileInfo, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, nil, ErrIsDirectory
+	}
+	return file, info, nil
+}
+
+// etag returns a strong entity tag for a file, suffixed with the content
+// coding for precompressed variants
+//
+// @return: the entity tag
+// @return: an error if the content of a file without modification time
+// could not be hashed
+func etag(file http.File, info fs.FileInfo, name, coding string, etags *sync.Map) (string, error) {
+	var tag string
+	if info.ModTime().IsZero() {
+		if etags != nil {
+			if cached, ok := etags.Load(name); ok {
+				return cached.(string), nil
+			}
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return "", err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		tag = fmt.Sprintf("%x", hash.Sum(nil)[:16])
+	} else {
+		tag = fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+	}
+
+	if coding != "" {
+		tag += "-" + coding
+	}
+	tag = `"` + tag + `"`
+
+	if etags != nil && info.ModTime().IsZero() {
+		etags.Store(name, tag)
+	}
+	return tag, nil
+}
@@ -0,0 +1,131 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newPrecompressedDir(t *testing.T) http.FileSystem {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log('identity')"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.js.gz"), []byte("gzip-bytes"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plain.txt"), []byte("plain"), 0o644))
+	return http.Dir(dir)
+}
+
+func TestServeFile_Precompressed(t *testing.T) {
+	fsys := newPrecompressedDir(t)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		expectEncoding string
+		expectBody     string
+	}{
+		{"no accept-encoding", "", "", "console.log('identity')"},
+		{"gzip accepted", "gzip, deflate", "gzip", "gzip-bytes"},
+		{"br preferred but missing", "br, gzip", "gzip", "gzip-bytes"},
+		{"gzip refused", "gzip;q=0, br", "", "console.log('identity')"},
+		{"wildcard", "*", "gzip", "gzip-bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			require.NoError(t, ServeFile(w, r, fsys, "/app.js", DefaultEncodings))
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, tt.expectEncoding, w.Header().Get("Content-Encoding"))
+			require.Equal(t, tt.expectBody, w.Body.String())
+			require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			require.Contains(t, w.Header().Get("Content-Type"), "javascript")
+			require.NotEmpty(t, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestServeFile_WithoutEncodings(t *testing.T) {
+	fsys := newPrecompressedDir(t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	require.NoError(t, ServeFile(w, r, fsys, "/app.js", nil))
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Empty(t, w.Header().Get("Vary"))
+}
+
+func TestServeFile_IfRange(t *testing.T) {
+	fsys := newPrecompressedDir(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/plain.txt", nil)
+	require.NoError(t, ServeFile(w, r, fsys, "/plain.txt", DefaultEncodings))
+	tag := w.Header().Get("ETag")
+
+	// Matching validator returns the requested range
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/plain.txt", nil)
+	r.Header.Set("Range", "bytes=0-1")
+	r.Header.Set("If-Range", tag)
+	require.NoError(t, ServeFile(w, r, fsys, "/plain.txt", DefaultEncodings))
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "pl", w.Body.String())
+
+	// Stale validator returns the full representation
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/plain.txt", nil)
+	r.Header.Set("Range", "bytes=0-1")
+	r.Header.Set("If-Range", `"stale"`)
+	require.NoError(t, ServeFile(w, r, fsys, "/plain.txt", DefaultEncodings))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "plain", w.Body.String())
+}
+
+func TestServeFile_Errors(t *testing.T) {
+	fsys := newPrecompressedDir(t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	require.ErrorIs(t, ServeFile(w, r, fsys, "/", nil), ErrIsDirectory)
+	require.ErrorIs(t, ServeFile(w, r, fsys, "/missing.js", nil), os.ErrNotExist)
+}
+
+func TestServeFile_ContentETag(t *testing.T) {
+	fsys := http.FS(fstest.MapFS{
+		"app.js": {Data: []byte("console.log(1)")},
+		"lib.js": {Data: []byte("console.log(2)")},
+	})
+	server := NewServer(fsys, Config{})
+
+	serve := func(name, ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/"+name, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		require.NoError(t, server.Serve(w, r, name))
+		return w
+	}
+
+	w := serve("app.js", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "console.log(1)", w.Body.String())
+	require.Contains(t, w.Header().Get("Content-Type"), "javascript")
+	tag := w.Header().Get("ETag")
+	require.NotEmpty(t, tag)
+
+	// Files of the same size without modification time are told apart
+	require.NotEqual(t, tag, serve("lib.js", "").Header().Get("ETag"))
+	require.Equal(t, http.StatusNotModified, serve("app.js", tag).Code)
+}
@@ -0,0 +1,173 @@
+package static
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry describes a single item in a directory listing
+type Entry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	IsDir   bool      `json:"is_dir"`
+}
+
+// Lister renders a directory listing
+//
+// Implementations receive the request path of the directory and its
+// entries, sorted with directories first and then by name.
+type Lister interface {
+	List(w http.ResponseWriter, r *http.Request, dir string, entries []Entry) error
+}
+
+// ListerFunc adapts an ordinary function to the Lister interface
+type ListerFunc func(w http.ResponseWriter, r *http.Request, dir string, entries []Entry) error
+
+// List calls f(w, r, dir, entries)
+func (f ListerFunc) List(w http.ResponseWriter, r *http.Request, dir string, entries []Entry) error {
+	return f(w, r, dir, entries)
+}
+
+// JSONLister renders a directory listing as a JSON document
+type JSONLister struct{}
+
+// jsonListing is the document written by JSONLister
+type jsonListing struct {
+	Path    string  `json:"path"`
+	Entries []Entry `json:"entries"`
+}
+
+// List implements Lister
+func (JSONLister) List(w http.ResponseWriter, r *http.Request, dir string, entries []Entry) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	return json.NewEncoder(w).Encode(jsonListing{Path: dir, Entries: entries})
+}
+
+// HTMLLister renders a directory listing as an HTML index page
+//
+// If Template is nil, a minimal built-in index page is used.
+type HTMLLister struct {
+	Template *template.Template
+}
+
+// htmlListing is the data passed to the HTMLLister template
+type htmlListing struct {
+	Path    string
+	Parent  bool
+	Entries []Entry
+}
+
+var defaultIndexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"href": entryHref,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{- if .Parent}}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{href .}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// List implements Lister
+func (l HTMLLister) List(w http.ResponseWriter, r *http.Request, dir string, entries []Entry) error {
+	tmpl := l.Template
+	if tmpl == nil {
+		tmpl = defaultIndexTemplate
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	return tmpl.Execute(w, htmlListing{
+		Path:    dir,
+		Parent:  dir != "/",
+		Entries: entries,
+	})
+}
+
+// ReadEntries reads the entries of an open directory
+//
+// @return: the directory entries, directories first and then by name
+// @return: an error if the directory could not be read
+func ReadEntries(dir http.File) ([]Entry, error) {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, Entry{
+			Name:    info.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// ServeListing writes the listing of the named directory using the lister
+//
+// @return: an error if the directory could not be opened, read or rendered
+func ServeListing(w http.ResponseWriter, r *http.Request, fs http.FileSystem, name string, lister Lister) error {
+	dir, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	entries, err := ReadEntries(dir)
+	if err != nil {
+		return err
+	}
+
+	return lister.List(w, r, cleanDir(name), entries)
+}
+
+// entryHref returns the escaped relative link for an entry
+func entryHref(e Entry) string {
+	href := (&url.URL{Path: e.Name}).String()
+	if e.IsDir {
+		href += "/"
+	}
+	return href
+}
+
+// cleanDir normalizes a directory path for display
+func cleanDir(name string) string {
+	dir := path.Clean("/" + name)
+	if dir != "/" && !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return dir
+}
@@ -0,0 +1,73 @@
+package static
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDir(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a b.txt"), []byte("hi"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	return dir
+}
+
+func TestServeListing_JSON(t *testing.T) {
+	fs := http.Dir(newTestDir(t))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	require.NoError(t, ServeListing(w, r, fs, "/", JSONLister{}))
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var listing jsonListing
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	require.Equal(t, "/", listing.Path)
+	require.Len(t, listing.Entries, 3)
+
+	// Directories are listed first, then files by name
+	require.Equal(t, "sub", listing.Entries[0].Name)
+	require.True(t, listing.Entries[0].IsDir)
+	require.Equal(t, "a b.txt", listing.Entries[1].Name)
+	require.Equal(t, "b.txt", listing.Entries[2].Name)
+	require.Equal(t, int64(5), listing.Entries[2].Size)
+}
+
+func TestServeListing_HTML(t *testing.T) {
+	fs := http.Dir(newTestDir(t))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/sub/", nil)
+
+	require.NoError(t, ServeListing(w, r, fs, "/", HTMLLister{}))
+	require.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	require.Contains(t, body, `<a href="sub/">sub/</a>`)
+	require.Contains(t, body, `<a href="a%20b.txt">a b.txt</a>`)
+	require.NotContains(t, body, `href="../"`)
+}
+
+func TestServeListing_HEAD(t *testing.T) {
+	fs := http.Dir(newTestDir(t))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodHead, "/", nil)
+
+	require.NoError(t, ServeListing(w, r, fs, "/", HTMLLister{}))
+	require.Empty(t, w.Body.String())
+}
+
+func TestServeListing_NotFound(t *testing.T) {
+	fs := http.Dir(newTestDir(t))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+
+	err := ServeListing(w, r, fs, "/missing", JSONLister{})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,124 @@
+package static
+
+import (
+	"cmp"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRanges is the number of ranges of a request served as a
+// multipart/byteranges response, requests with more ranges being served the
+// whole content
+const DefaultMaxRanges = 16
+
+// byteRange is a range of a Range header, from start to end inclusive
+type byteRange struct {
+	start, end int64
+}
+
+// ServeContent serves content like http.ServeContent, including the
+// multipart/byteranges responses of multi-range requests, e.g. of download
+// managers and video players
+//
+// Overlapping and adjacent ranges are coalesced, and requests with more
+// than DefaultMaxRanges ranges left are served the whole content, so that
+// clients cannot make the server send the same bytes many times over, see
+// RFC 9110 section 14.2.
+func ServeContent(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+	if header := r.Header.Get("Range"); header != "" {
+		if size, err := content.Seek(0, io.SeekEnd); err == nil {
+			if _, err := content.Seek(0, io.SeekStart); err == nil {
+				if normalized, ok := normalizeRange(header, size, DefaultMaxRanges); ok {
+					r = r.Clone(r.Context())
+					if normalized == "" {
+						r.Header.Del("Range")
+					} else {
+						r.Header.Set("Range", normalized)
+					}
+				}
+			}
+		}
+	}
+	http.ServeContent(w, r, name, modtime, content)
+}
+
+// normalizeRange coalesces the ranges of a Range header for content of the
+// size
+//
+// @return: the header to serve instead, empty to serve the whole content,
+// and false if the header is kept, e.g. when it is invalid and left for
+// http.ServeContent to reject
+func normalizeRange(header string, size int64, maxRanges int) (string, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return "", false
+	}
+
+	var ranges []byteRange
+	for part := range strings.SplitSeq(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return "", false
+		}
+		var rng byteRange
+		if first == "" {
+			// Suffix range, the last bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return "", false
+			}
+			rng = byteRange{start: max(0, size-n), end: size - 1}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return "", false
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return "", false
+				}
+			}
+			rng = byteRange{start: start, end: min(end, size-1)}
+		}
+		if rng.start < size && rng.start <= rng.end {
+			ranges = append(ranges, rng)
+		}
+	}
+	if len(ranges) <= 1 {
+		// Single and unsatisfiable ranges are left to http.ServeContent
+		return "", false
+	}
+
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b byteRange) int { return cmp.Compare(a.start, b.start) })
+	merged := sorted[:1]
+	for _, rng := range sorted[1:] {
+		if last := &merged[len(merged)-1]; rng.start <= last.end+1 {
+			last.end = max(last.end, rng.end)
+		} else {
+			merged = append(merged, rng)
+		}
+	}
+
+	switch {
+	case len(merged) > maxRanges:
+		return "", true
+	case len(merged) == len(ranges):
+		// Nothing to coalesce, the ranges are served in the order requested
+		return "", false
+	}
+	parts := make([]string, len(merged))
+	for i, rng := range merged {
+		parts[i] = strconv.FormatInt(rng.start, 10) + "-" + strconv.FormatInt(rng.end, 10)
+	}
+	return "bytes=" + strings.Join(parts, ","), true
+}
@@ -0,0 +1,87 @@
+package static
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeContent_MultiRange(t *testing.T) {
+	serve := func(rangeHeader string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/video.txt", nil)
+		r.Header.Set("Range", rangeHeader)
+		ServeContent(w, r, "video.txt", time.Time{}, strings.NewReader("0123456789abcdef"))
+		return w
+	}
+
+	// Ranges are sent as parts, in the order requested
+	w := serve("bytes=10-11, 0-1, -2")
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/byteranges", mediaType)
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	for _, want := range []struct{ contentRange, body string }{
+		{"bytes 10-11/16", "ab"},
+		{"bytes 0-1/16", "01"},
+		{"bytes 14-15/16", "ef"},
+	} {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, want.contentRange, part.Header.Get("Content-Range"))
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, want.body, string(body))
+	}
+	_, err = reader.NextPart()
+	require.ErrorIs(t, err, io.EOF)
+
+	// Overlapping and adjacent ranges are coalesced
+	w = serve("bytes=4-5, 0-2, 1-3")
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "bytes 0-5/16", w.Header().Get("Content-Range"))
+	require.Equal(t, "012345", w.Body.String())
+
+	// Too many ranges are served the whole content
+	content := strings.Repeat("x", 4*DefaultMaxRanges)
+	ranges := make([]string, DefaultMaxRanges+1)
+	for i := range ranges {
+		ranges[i] = strconv.Itoa(i*2) + "-" + strconv.Itoa(i*2)
+	}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/video.txt", nil)
+	r.Header.Set("Range", "bytes="+strings.Join(ranges, ","))
+	ServeContent(w, r, "video.txt", time.Time{}, strings.NewReader(content))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, content, w.Body.String())
+	require.Equal(t, "bytes="+strings.Join(ranges, ","), r.Header.Get("Range"))
+
+	// Unsatisfiable ranges are still rejected
+	w = serve("bytes=100-200, 300-")
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+}
+
+func TestNormalizeRange(t *testing.T) {
+	for header, want := range map[string]string{
+		"bytes=0-1":           "",
+		"bytes=0-1,5-6":       "",
+		"bytes=5-6,0-9":       "bytes=0-9",
+		"bytes=0-1,2-3,8-":    "bytes=0-3,8-15",
+		"bytes=-4,10-":        "bytes=10-15",
+		"bytes=0-1,abc":       "",
+		"items=0-1,0-1":       "",
+		"bytes=0-1,0-1,50-60": "bytes=0-1",
+	} {
+		normalized, _ := normalizeRange(header, 16, DefaultMaxRanges)
+		require.Equal(t, want, normalized, header)
+	}
+}
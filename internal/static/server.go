@@ -0,0 +1,105 @@
+package static
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// DefaultIndex is the file served for directory requests
+const DefaultIndex = "index.html"
+
+// Config contains the settings of a Server
+type Config struct {
+	// Index is the file served for directories, DefaultIndex if empty
+	Index string
+
+	// Listing enables directory listings for directories without an index
+	Listing bool
+
+	// Lister renders directory listings, an HTMLLister if nil
+	Lister Lister
+
+	// Encodings of precompressed sidecar files to serve, none if empty
+	Encodings []Encoding
+}
+
+// Server serves the files of a file system
+type Server struct {
+	fs     http.FileSystem
+	config Config
+
+	// Content hashes of files without modification time, by name
+	etags sync.Map
+}
+
+// NewServer creates a server for the file system
+func NewServer(fsys http.FileSystem, config Config) *Server {
+	if config.Index == "" {
+		config.Index = DefaultIndex
+	}
+	if config.Lister == nil {
+		config.Lister = HTMLLister{}
+	}
+	return &Server{fs: fsys, config: config}
+}
+
+// Serve serves the named file or directory
+//
+// Directories are redirected to their path with a trailing slash, then
+// served by their index file or, when enabled, a directory listing.
+//
+// @return: an error if nothing could be served, see ErrorStatus
+func (s *Server) Serve(w http.ResponseWriter, r *http.Request, name string) error {
+	name = path.Clean("/" + name)
+
+	file, err := s.fs.Open(name)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return serveFile(w, r, s.fs, name, s.config.Encodings, &s.etags)
+	}
+
+	// Relative links in index pages and listings require the trailing slash
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		target := path.Base(r.URL.Path) + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return nil
+	}
+
+	err = serveFile(w, r, s.fs, path.Join(name, s.config.Index), s.config.Encodings, &s.etags)
+	if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, ErrIsDirectory) {
+		return err
+	}
+
+	if !s.config.Listing {
+		return fs.ErrNotExist
+	}
+	return ServeListing(w, r, s.fs, name, s.config.Lister)
+}
+
+// ErrorStatus returns the HTTP status code describing an error returned
+// by the functions of this package
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, ErrIsDirectory):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,56 @@
+package static
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Serve(t *testing.T) {
+	dir := newTestDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "index.html"), []byte("<h1>sub</h1>"), 0o644))
+	server := NewServer(http.Dir(dir), Config{})
+
+	serve := func(target, name string) (*httptest.ResponseRecorder, error) {
+		w := httptest.NewRecorder()
+		err := server.Serve(w, httptest.NewRequest(http.MethodGet, target, nil), name)
+		return w, err
+	}
+
+	w, err := serve("/assets/b.txt", "b.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello", w.Body.String())
+
+	// Directories are redirected to their canonical path
+	w, err = serve("/assets/sub?v=1", "sub")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "/assets/sub/?v=1", w.Header().Get("Location"))
+
+	w, err = serve("/assets/sub/", "sub")
+	require.NoError(t, err)
+	require.Equal(t, "<h1>sub</h1>", w.Body.String())
+
+	// Listings are disabled by default
+	_, err = serve("/assets/", "")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.Equal(t, http.StatusNotFound, ErrorStatus(err))
+
+	_, err = serve("/assets/../missing.txt", "../missing.txt")
+	require.Equal(t, http.StatusNotFound, ErrorStatus(err))
+}
+
+func TestServer_Listing(t *testing.T) {
+	server := NewServer(http.Dir(newTestDir(t)), Config{Listing: true, Lister: JSONLister{}})
+
+	w := httptest.NewRecorder()
+	err := server.Serve(w, httptest.NewRequest(http.MethodGet, "/assets/", nil), "")
+	require.NoError(t, err)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), `"name":"b.txt"`)
+}
@@ -0,0 +1,457 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"hash/maphash"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the memory store configuration
+const (
+	DefaultMemoryShards     = 32
+	DefaultMemoryResolution = time.Second
+	DefaultSubscriberBuffer = 64
+)
+
+// memoryWheelSlots is the number of slots of the expiry wheel, keys
+// expiring after a turn of the wheel are checked at every turn
+const memoryWheelSlots = 512
+
+var (
+	_ Store   = (*Memory)(nil)
+	_ Counter = (*Memory)(nil)
+	_ PubSub  = (*Memory)(nil)
+)
+
+// ErrNotInteger is returned when incrementing a value that is not an
+// integer
+var ErrNotInteger = errors.New("store: value is not an integer")
+
+// MemoryConfig configures a memory store
+type MemoryConfig struct {
+	// MaxEntries bounds the number of keys, evicting the least recently
+	// used ones, 0 for no bound. The bound is split among the shards, so a
+	// key may be evicted slightly before the store is full.
+	MaxEntries int
+
+	// Shards splits the keys to reduce lock contention,
+	// DefaultMemoryShards if 0
+	Shards int
+
+	// Resolution of the expiry of keys, DefaultMemoryResolution if 0.
+	// Expired keys are never returned, they are removed from memory within
+	// the resolution.
+	Resolution time.Duration
+
+	// SubscriberBuffer is the number of messages buffered for every
+	// subscription, DefaultSubscriberBuffer if 0. Messages are dropped for
+	// subscribers with a full buffer.
+	SubscriberBuffer int
+}
+
+// MemoryStats is a snapshot of the metrics of a memory store
+type MemoryStats struct {
+	Entries     int    `json:"entries"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	Subscribers int    `json:"subscribers"`
+	Dropped     uint64 `json:"dropped"` // messages not delivered to full subscribers
+}
+
+// Memory implements Store, Counter and PubSub in memory, for deployments
+// with a single instance
+//
+// Keys are spread over shards, each bounded by a least recently used list
+// and expired by a timing wheel, so that expired keys do not linger in
+// memory until they are read again.
+type Memory struct {
+	shards     []*memoryShard
+	seed       maphash.Seed
+	resolution time.Duration
+	now        func() time.Time
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+
+	subMu       sync.RWMutex
+	subscribers map[string]map[*memorySubscription]struct{}
+	buffer      int
+	dropped     atomic.Uint64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewMemory creates a memory store, which expires keys in the background
+// until it is closed
+func NewMemory(config MemoryConfig) *Memory {
+	return newMemory(config, time.Now)
+}
+
+// newMemory creates a memory store reading the time from a clock
+func newMemory(config MemoryConfig, now func() time.Time) *Memory {
+	if config.Shards <= 0 {
+		config.Shards = DefaultMemoryShards
+	}
+	if config.Resolution <= 0 {
+		config.Resolution = DefaultMemoryResolution
+	}
+	if config.SubscriberBuffer <= 0 {
+		config.SubscriberBuffer = DefaultSubscriberBuffer
+	}
+
+	limit := 0
+	if config.MaxEntries > 0 {
+		limit = max(1, (config.MaxEntries+config.Shards-1)/config.Shards)
+	}
+
+	m := &Memory{
+		shards:      make([]*memoryShard, config.Shards),
+		seed:        maphash.MakeSeed(),
+		resolution:  config.Resolution,
+		now:         now,
+		subscribers: make(map[string]map[*memorySubscription]struct{}),
+		buffer:      config.SubscriberBuffer,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for i := range m.shards {
+		m.shards[i] = newMemoryShard(limit)
+	}
+	go m.run()
+	return m
+}
+
+// Close stops expiring keys in the background and closes the
+// subscriptions
+func (m *Memory) Close() error {
+	m.once.Do(func() {
+		close(m.stop)
+		<-m.done
+
+		m.subMu.Lock()
+		subscriptions := m.subscribers
+		m.subscribers = make(map[string]map[*memorySubscription]struct{})
+		m.subMu.Unlock()
+		for _, subs := range subscriptions {
+			for sub := range subs {
+				sub.close()
+			}
+		}
+	})
+	return nil
+}
+
+// Stats returns a snapshot of the metrics of the store
+func (m *Memory) Stats() MemoryStats {
+	stats := MemoryStats{
+		Hits:        m.hits.Load(),
+		Misses:      m.misses.Load(),
+		Evictions:   m.evictions.Load(),
+		Expirations: m.expirations.Load(),
+		Dropped:     m.dropped.Load(),
+	}
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		stats.Entries += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	m.subMu.RLock()
+	for _, subs := range m.subscribers {
+		stats.Subscribers += len(subs)
+	}
+	m.subMu.RUnlock()
+	return stats
+}
+
+// Get implements Store
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry := shard.lookup(m, key, m.now())
+	if entry == nil {
+		m.misses.Add(1)
+		return nil, ErrNotFound
+	}
+	m.hits.Add(1)
+	return entry.value, nil
+}
+
+// Set implements Store
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.store(m, key, value, m.expiry(ttl))
+	return nil
+}
+
+// SetNX implements Store
+func (m *Memory) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.lookup(m, key, m.now()) != nil {
+		return false, nil
+	}
+	shard.store(m, key, value, m.expiry(ttl))
+	return true, nil
+}
+
+// Delete implements Store
+func (m *Memory) Delete(_ context.Context, key string) error {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if element, ok := shard.entries[key]; ok {
+		shard.remove(element)
+	}
+	return nil
+}
+
+// Increment implements Counter, the count is stored as a decimal value
+func (m *Memory) Increment(_ context.Context, key string, n int64, ttl time.Duration) (int64, time.Duration, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := m.now()
+	count, expires := n, m.expiry(ttl)
+	if entry := shard.lookup(m, key, now); entry != nil {
+		current, err := strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, 0, ErrNotInteger
+		}
+		count, expires = current+n, entry.expires
+	} else if n == 0 {
+		return 0, 0, nil
+	}
+	shard.store(m, key, strconv.AppendInt(nil, count, 10), expires)
+
+	var remaining time.Duration
+	if !expires.IsZero() {
+		remaining = expires.Sub(now)
+	}
+	return count, remaining, nil
+}
+
+// Publish implements PubSub
+func (m *Memory) Publish(_ context.Context, channel string, message []byte) error {
+	m.subMu.RLock()
+	defer m.subMu.RUnlock()
+
+	for sub := range m.subscribers[channel] {
+		select {
+		case sub.messages <- message:
+		default:
+			m.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Subscribe implements PubSub
+func (m *Memory) Subscribe(_ context.Context, channel string) (Subscription, error) {
+	sub := &memorySubscription{memory: m, channel: channel, messages: make(chan []byte, m.buffer)}
+
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	if m.subscribers[channel] == nil {
+		m.subscribers[channel] = make(map[*memorySubscription]struct{})
+	}
+	m.subscribers[channel][sub] = struct{}{}
+	return sub, nil
+}
+
+// shard returns the shard of a key
+func (m *Memory) shard(key string) *memoryShard {
+	return m.shards[maphash.String(m.seed, key)%uint64(len(m.shards))]
+}
+
+// expiry returns the expiry time of a ttl, zero for no expiry
+func (m *Memory) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return m.now().Add(ttl)
+}
+
+// slot returns the wheel slot of a time
+func (m *Memory) slot(t time.Time) int {
+	return int(t.UnixNano() / int64(m.resolution) % memoryWheelSlots)
+}
+
+// run expires keys at every tick of the wheel until the store is closed
+func (m *Memory) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.resolution)
+	defer ticker.Stop()
+
+	last := m.now()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			now := m.now()
+			m.expire(last, now)
+			last = now
+		}
+	}
+}
+
+// expire removes the expired keys of the slots passed since the last tick
+func (m *Memory) expire(last, now time.Time) {
+	ticks := int(now.Sub(last)/m.resolution) + 1
+	for i := range min(ticks, memoryWheelSlots) {
+		slot := m.slot(now.Add(-time.Duration(i) * m.resolution))
+		for _, shard := range m.shards {
+			shard.mu.Lock()
+			for key := range shard.wheel[slot] {
+				// Keys expiring in a later turn of the wheel stay in the slot
+				if element := shard.entries[key]; !element.Value.(*memoryEntry).expires.After(now) {
+					shard.remove(element)
+					m.expirations.Add(1)
+				}
+			}
+			shard.mu.Unlock()
+		}
+	}
+}
+
+// memoryShard is a part of the keys of a memory store
+type memoryShard struct {
+	mu      sync.Mutex
+	limit   int
+	entries map[string]*list.Element // values are *memoryEntry
+	lru     *list.List               // most recently used first
+	wheel   []map[string]struct{}    // keys by expiry slot
+}
+
+// memoryEntry is the value of a key
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero for no expiry
+	slot    int       // in the wheel, -1 without expiry
+}
+
+// newMemoryShard creates a shard holding up to limit keys, 0 for no limit
+func newMemoryShard(limit int) *memoryShard {
+	return &memoryShard{
+		limit:   limit,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		wheel:   make([]map[string]struct{}, memoryWheelSlots),
+	}
+}
+
+// lookup returns the entry of a key and marks it used, removing it if
+// expired
+func (s *memoryShard) lookup(m *Memory, key string, now time.Time) *memoryEntry {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && !entry.expires.After(now) {
+		s.remove(element)
+		m.expirations.Add(1)
+		return nil
+	}
+	s.lru.MoveToFront(element)
+	return entry
+}
+
+// store sets the value of a key, evicting the least recently used key if
+// the shard is full
+func (s *memoryShard) store(m *Memory, key string, value []byte, expires time.Time) {
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		s.unschedule(entry)
+		entry.value, entry.expires = value, expires
+		s.schedule(m, entry)
+		s.lru.MoveToFront(element)
+		return
+	}
+
+	entry := &memoryEntry{key: key, value: value, expires: expires}
+	s.schedule(m, entry)
+	s.entries[key] = s.lru.PushFront(entry)
+	if s.limit > 0 && len(s.entries) > s.limit {
+		s.remove(s.lru.Back())
+		m.evictions.Add(1)
+	}
+}
+
+// remove deletes an entry
+func (s *memoryShard) remove(element *list.Element) {
+	entry := s.lru.Remove(element).(*memoryEntry)
+	s.unschedule(entry)
+	delete(s.entries, entry.key)
+}
+
+// schedule adds an entry with an expiry to the wheel
+func (s *memoryShard) schedule(m *Memory, entry *memoryEntry) {
+	entry.slot = -1
+	if entry.expires.IsZero() {
+		return
+	}
+	entry.slot = m.slot(entry.expires)
+	if s.wheel[entry.slot] == nil {
+		s.wheel[entry.slot] = make(map[string]struct{})
+	}
+	s.wheel[entry.slot][entry.key] = struct{}{}
+}
+
+// unschedule removes an entry from the wheel
+func (s *memoryShard) unschedule(entry *memoryEntry) {
+	if entry.slot >= 0 {
+		delete(s.wheel[entry.slot], entry.key)
+	}
+}
+
+// memorySubscription receives the messages of a channel of a memory store
+type memorySubscription struct {
+	memory   *Memory
+	channel  string
+	messages chan []byte
+	once     sync.Once
+}
+
+// Messages implements Subscription
+func (s *memorySubscription) Messages() <-chan []byte {
+	return s.messages
+}
+
+// Close implements Subscription
+func (s *memorySubscription) Close() error {
+	s.memory.subMu.Lock()
+	delete(s.memory.subscribers[s.channel], s)
+	if len(s.memory.subscribers[s.channel]) == 0 {
+		delete(s.memory.subscribers, s.channel)
+	}
+	s.memory.subMu.Unlock()
+	s.close()
+	return nil
+}
+
+// close closes the messages, once no message can be published anymore
+func (s *memorySubscription) close() {
+	s.once.Do(func() { close(s.messages) })
+}
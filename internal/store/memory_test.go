@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a settable clock for memory stores
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestMemory creates a memory store using a fake clock
+func newTestMemory(t *testing.T, config MemoryConfig) (*Memory, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	m := newMemory(config, clock.Now)
+	t.Cleanup(func() { m.Close() })
+	return m, clock
+}
+
+func TestMemory(t *testing.T) {
+	m, clock := newTestMemory(t, MemoryConfig{})
+	ctx := context.Background()
+
+	_, err := m.Get(ctx, "session")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, m.Set(ctx, "session", []byte("data"), time.Minute))
+	value, err := m.Get(ctx, "session")
+	require.NoError(t, err)
+	require.Equal(t, "data", string(value))
+
+	stored, err := m.SetNX(ctx, "session", []byte("other"), time.Minute)
+	require.NoError(t, err)
+	require.False(t, stored)
+
+	clock.Advance(time.Minute)
+	_, err = m.Get(ctx, "session")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	stored, err = m.SetNX(ctx, "session", []byte("other"), 0)
+	require.NoError(t, err)
+	require.True(t, stored)
+	require.NoError(t, m.Delete(ctx, "session"))
+	require.NoError(t, m.Delete(ctx, "session"))
+
+	stats := m.Stats()
+	require.Equal(t, 0, stats.Entries)
+	require.Equal(t, uint64(1), stats.Hits)
+	require.Equal(t, uint64(2), stats.Misses)
+	require.Equal(t, uint64(1), stats.Expirations)
+}
+
+func TestMemory_Expire(t *testing.T) {
+	m, clock := newTestMemory(t, MemoryConfig{Shards: 4})
+	ctx := context.Background()
+
+	start := clock.Now()
+	require.NoError(t, m.Set(ctx, "short", []byte("1"), 2*time.Second))
+	require.NoError(t, m.Set(ctx, "long", []byte("2"), time.Hour))
+	require.NoError(t, m.Set(ctx, "forever", []byte("3"), 0))
+
+	// Expired keys are removed without being read, keys expiring in a
+	// later turn of the wheel are kept
+	clock.Advance(3 * time.Second)
+	m.expire(start, clock.Now())
+	require.Equal(t, 2, m.Stats().Entries)
+	require.Equal(t, uint64(1), m.Stats().Expirations)
+
+	// Overwriting a key reschedules it
+	require.NoError(t, m.Set(ctx, "long", []byte("2"), time.Second))
+	last := clock.Now()
+	clock.Advance(time.Hour)
+	m.expire(last, clock.Now())
+	require.Equal(t, 1, m.Stats().Entries)
+
+	value, err := m.Get(ctx, "forever")
+	require.NoError(t, err)
+	require.Equal(t, "3", string(value))
+}
+
+func TestMemory_LRU(t *testing.T) {
+	m, _ := newTestMemory(t, MemoryConfig{MaxEntries: 3, Shards: 1})
+	ctx := context.Background()
+
+	for i := range 3 {
+		require.NoError(t, m.Set(ctx, fmt.Sprint(i), []byte("v"), 0))
+	}
+	// Reading 0 makes 1 the least recently used
+	_, err := m.Get(ctx, "0")
+	require.NoError(t, err)
+	require.NoError(t, m.Set(ctx, "3", []byte("v"), time.Minute))
+
+	_, err = m.Get(ctx, "1")
+	require.ErrorIs(t, err, ErrNotFound)
+	for _, key := range []string{"0", "2", "3"} {
+		_, err := m.Get(ctx, key)
+		require.NoError(t, err, key)
+	}
+	require.Equal(t, uint64(1), m.Stats().Evictions)
+	require.Equal(t, 3, m.Stats().Entries)
+}
+
+func TestMemory_Increment(t *testing.T) {
+	m, clock := newTestMemory(t, MemoryConfig{})
+	ctx := context.Background()
+
+	// Reading a missing counter does not create it
+	count, ttl, err := m.Increment(ctx, "ip:1", 0, time.Minute)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	require.Zero(t, ttl)
+	_, err = m.Get(ctx, "ip:1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	count, ttl, err = m.Increment(ctx, "ip:1", 1, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+	require.Equal(t, time.Minute, ttl)
+
+	clock.Advance(20 * time.Second)
+	count, ttl, err = m.Increment(ctx, "ip:1", 2, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+	require.Equal(t, 40*time.Second, ttl)
+
+	value, err := m.Get(ctx, "ip:1")
+	require.NoError(t, err)
+	require.Equal(t, "3", string(value))
+
+	// A new window starts once the previous one expired
+	clock.Advance(time.Minute)
+	count, _, err = m.Increment(ctx, "ip:1", 1, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	require.NoError(t, m.Set(ctx, "name", []byte("alice"), 0))
+	_, _, err = m.Increment(ctx, "name", 1, time.Minute)
+	require.ErrorIs(t, err, ErrNotInteger)
+}
+
+func TestMemory_PubSub(t *testing.T) {
+	m, _ := newTestMemory(t, MemoryConfig{SubscriberBuffer: 1})
+	ctx := context.Background()
+
+	sub, err := m.Subscribe(ctx, "invalidate")
+	require.NoError(t, err)
+	require.NoError(t, m.Publish(ctx, "other", []byte("ignored")))
+	require.NoError(t, m.Publish(ctx, "invalidate", []byte("users/1")))
+	require.Equal(t, "users/1", string(<-sub.Messages()))
+
+	// Messages to full subscribers are dropped rather than blocking
+	require.NoError(t, m.Publish(ctx, "invalidate", []byte("users/2")))
+	require.NoError(t, m.Publish(ctx, "invalidate", []byte("users/3")))
+	require.Equal(t, uint64(1), m.Stats().Dropped)
+	require.Equal(t, 1, m.Stats().Subscribers)
+
+	require.NoError(t, sub.Close())
+	require.Equal(t, "users/2", string(<-sub.Messages()))
+	_, ok := <-sub.Messages()
+	require.False(t, ok)
+	require.Equal(t, 0, m.Stats().Subscribers)
+
+	// Closing the store closes the remaining subscriptions
+	sub, err = m.Subscribe(ctx, "invalidate")
+	require.NoError(t, err)
+	require.NoError(t, m.Close())
+	_, ok = <-sub.Messages()
+	require.False(t, ok)
+}
@@ -0,0 +1,133 @@
+// Package redisstore implements the store interfaces with Redis, so that
+// one connection serves sessions, caching, rate limiting, idempotency keys
+// and pub/sub
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+)
+
+var (
+	_ store.Store   = (*Store)(nil)
+	_ store.Counter = (*Store)(nil)
+	_ store.PubSub  = (*Store)(nil)
+)
+
+// Store implements store.Store, store.Counter and store.PubSub with a
+// Redis client, a cluster client or a ring
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// New creates a store with the client, prefixing its keys and channels,
+// e.g. with "myapp:" to share a Redis deployment
+func New(client redis.UniversalClient, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+// Get implements store.Store
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, store.ErrNotFound
+	}
+	return value, err
+}
+
+// Set implements store.Store
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+// SetNX implements store.Store
+func (s *Store) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+key, value, ttl).Result()
+}
+
+// Delete implements store.Store
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// incrementScript increments a counter and starts its window, atomically
+// so that concurrent first increments cannot leave it without expiry.
+// Reading a missing counter with an increment of 0 leaves it missing.
+var incrementScript = redis.NewScript(`
+if ARGV[1] == '0' and redis.call('EXISTS', KEYS[1]) == 0 then
+	return {0, 0}
+end
+local count = redis.call('INCRBY', KEYS[1], ARGV[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+return {count, ttl}
+`)
+
+// Increment implements store.Counter
+func (s *Store) Increment(ctx context.Context, key string, n int64, ttl time.Duration) (int64, time.Duration, error) {
+	result, err := incrementScript.Run(ctx, s.client, []string{s.prefix + key}, n, ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return result[0], time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// Publish implements store.PubSub
+func (s *Store) Publish(ctx context.Context, channel string, message []byte) error {
+	return s.client.Publish(ctx, s.prefix+channel, message).Err()
+}
+
+// Subscribe implements store.PubSub, returning once Redis confirmed the
+// subscription so that no message published afterwards is missed
+func (s *Store) Subscribe(ctx context.Context, channel string) (store.Subscription, error) {
+	pubsub := s.client.Subscribe(ctx, s.prefix+channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &subscription{pubsub: pubsub, messages: make(chan []byte), done: make(chan struct{})}
+	go sub.run()
+	return sub, nil
+}
+
+// subscription forwards the messages of a Redis subscription
+type subscription struct {
+	pubsub   *redis.PubSub
+	messages chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+// Messages implements store.Subscription
+func (s *subscription) Messages() <-chan []byte {
+	return s.messages
+}
+
+// Close implements store.Subscription
+func (s *subscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.pubsub.Close()
+}
+
+// run forwards the messages until the subscription is closed
+func (s *subscription) run() {
+	defer close(s.messages)
+	for msg := range s.pubsub.Channel() {
+		select {
+		case s.messages <- []byte(msg.Payload):
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skjdfhkskjds/go-api/internal/store"
+)
+
+// newStore creates a store backed by an in-process Redis server
+func newStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, "app:"), server
+}
+
+func TestStore(t *testing.T) {
+	s, server := newStore(t)
+	ctx := context.Background()
+
+	_, err := s.Get(ctx, "session")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.Set(ctx, "session", []byte("data"), time.Minute))
+	value, err := s.Get(ctx, "session")
+	require.NoError(t, err)
+	require.Equal(t, "data", string(value))
+	require.True(t, server.Exists("app:session"))
+
+	stored, err := s.SetNX(ctx, "session", []byte("other"), time.Minute)
+	require.NoError(t, err)
+	require.False(t, stored)
+
+	server.FastForward(time.Minute)
+	_, err = s.Get(ctx, "session")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	stored, err = s.SetNX(ctx, "session", []byte("other"), 0)
+	require.NoError(t, err)
+	require.True(t, stored)
+	require.NoError(t, s.Delete(ctx, "session"))
+	require.NoError(t, s.Delete(ctx, "session"))
+	require.False(t, server.Exists("app:session"))
+}
+
+func TestStore_Increment(t *testing.T) {
+	s, server := newStore(t)
+	ctx := context.Background()
+
+	// Reading a missing counter does not create it
+	count, ttl, err := s.Increment(ctx, "ip:1", 0, time.Minute)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	require.Zero(t, ttl)
+	require.False(t, server.Exists("app:ip:1"))
+
+	count, ttl, err = s.Increment(ctx, "ip:1", 1, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+	require.Equal(t, time.Minute, ttl)
+
+	server.FastForward(20 * time.Second)
+	count, ttl, err = s.Increment(ctx, "ip:1", 2, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+	require.Equal(t, 40*time.Second, ttl)
+
+	// A new window starts once the previous one expired
+	server.FastForward(time.Minute)
+	count, _, err = s.Increment(ctx, "ip:1", 1, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+}
+
+func TestStore_PubSub(t *testing.T) {
+	s, _ := newStore(t)
+	ctx := context.Background()
+
+	sub, err := s.Subscribe(ctx, "invalidate")
+	require.NoError(t, err)
+	require.NoError(t, s.Publish(ctx, "invalidate", []byte("users/1")))
+
+	select {
+	case msg := <-sub.Messages():
+		require.Equal(t, "users/1", string(msg))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	require.NoError(t, sub.Close())
+	require.Eventually(t, func() bool {
+		_, ok := <-sub.Messages()
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,60 @@
+// Package store defines the storage interfaces of the subsystems keeping
+// state across requests, e.g. sessions, response caching, rate limiting,
+// idempotency keys and pub/sub, so that one backend serves all of them
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned for missing or expired keys
+var ErrNotFound = errors.New("store: not found")
+
+// Store is a key-value store with expiring entries, e.g. for sessions,
+// cached responses and idempotency keys
+type Store interface {
+	// Get returns the value of a key, ErrNotFound if it is missing
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores the value of a key, expiring after the ttl, never if 0
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// SetNX stores the value of a key only if it is missing, e.g. to claim
+	// an idempotency key, and reports whether it was stored
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+
+	// Delete removes a key, missing keys are not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Counter counts events per key in expiring windows, e.g. for rate
+// limiting
+type Counter interface {
+	// Increment adds n to the counter of a key, whose window expires after
+	// the ttl from its first increment. An n of 0 reads the counter
+	// without creating it, a missing one having a count and a time left
+	// of 0.
+	//
+	// @return: the count and the time left in the window
+	Increment(ctx context.Context, key string, n int64, ttl time.Duration) (int64, time.Duration, error)
+}
+
+// PubSub delivers messages to the subscribers of a channel, e.g. to
+// invalidate caches across instances
+type PubSub interface {
+	// Publish sends a message to the current subscribers of the channel
+	Publish(ctx context.Context, channel string, message []byte) error
+
+	// Subscribe starts receiving the messages of the channel, until the
+	// subscription is closed
+	Subscribe(ctx context.Context, channel string) (Subscription, error)
+}
+
+// Subscription receives the messages of a channel
+type Subscription interface {
+	// Messages is closed once the subscription is closed
+	Messages() <-chan []byte
+	Close() error
+}
@@ -0,0 +1,99 @@
+// Package templates loads the HTML templates rendered by
+// Context.HTMLTemplate, parsing them once or, in development, on every
+// use so that edits show up without restarting
+package templates
+
+import (
+	"errors"
+	"html/template"
+	"path/filepath"
+	"sync"
+
+	"github.com/skjdfhkskjds/go-api/internal/i18n"
+)
+
+// Config configures a template set
+type Config struct {
+	// Patterns select the template files, see filepath.Glob, e.g.
+	// "templates/*.html"
+	Patterns []string
+
+	// Funcs are added to the templates along with the i18n functions, may
+	// be nil
+	Funcs template.FuncMap
+
+	// Reload parses the templates again on every use, e.g. in debug mode
+	Reload bool
+}
+
+// Set is a set of HTML templates parsed from files
+type Set struct {
+	config Config
+
+	mu     sync.Mutex
+	parsed *template.Template
+}
+
+// New parses the templates of the configuration
+//
+// @return: the template set
+// @return: an error if the templates could not be parsed
+func New(config Config) (*Set, error) {
+	s := &Set{config: config}
+	parsed, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+	s.parsed = parsed
+	return s, nil
+}
+
+// Template returns the parsed templates, parsed again when reloading
+//
+// @return: an error if the templates could not be parsed again, e.g. after
+// an invalid edit
+func (s *Set) Template() (*template.Template, error) {
+	if !s.config.Reload {
+		return s.parsed, nil
+	}
+
+	// Concurrent requests would parse the same files anyway
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parsed, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+	s.parsed = parsed
+	return parsed, nil
+}
+
+// parse parses the files matched by the patterns
+func (s *Set) parse() (*template.Template, error) {
+	var files []string
+	for _, pattern := range s.config.Patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("templates: no files match the patterns")
+	}
+
+	tmpl := template.New("").Funcs(i18n.FuncMap())
+	if s.config.Funcs != nil {
+		tmpl.Funcs(s.config.Funcs)
+	}
+	tmpl, err := tmpl.ParseFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cloned for the locales up front, rendering then clones nothing
+	if err := i18n.LocalizeAll(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
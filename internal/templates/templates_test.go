@@ -0,0 +1,58 @@
+package templates
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// execute renders the named template of the set
+func execute(t *testing.T, s *Set, name string) (string, error) {
+	tmpl, err := s.Template()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.ExecuteTemplate(&buf, name, nil))
+	return buf.String(), nil
+}
+
+func TestSet_Reload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	require.NoError(t, os.WriteFile(file, []byte(`<p>{{upper "v1"}}</p>`), 0o600))
+
+	config := Config{
+		Patterns: []string{filepath.Join(dir, "*.html")},
+		Funcs:    map[string]any{"upper": func(s string) string { return s + "!" }},
+	}
+	cached, err := New(config)
+	require.NoError(t, err)
+	config.Reload = true
+	reloaded, err := New(config)
+	require.NoError(t, err)
+
+	// Edits only show up when reloading
+	require.NoError(t, os.WriteFile(file, []byte(`<p>{{upper "v2"}}</p>`), 0o600))
+	out, err := execute(t, cached, "index.html")
+	require.NoError(t, err)
+	require.Equal(t, "<p>v1!</p>", out)
+	out, err = execute(t, reloaded, "index.html")
+	require.NoError(t, err)
+	require.Equal(t, "<p>v2!</p>", out)
+
+	// Invalid edits are reported, and fixing them recovers
+	require.NoError(t, os.WriteFile(file, []byte(`<p>{{if}}</p>`), 0o600))
+	_, err = execute(t, reloaded, "index.html")
+	require.ErrorContains(t, err, "index.html")
+	require.NoError(t, os.WriteFile(file, []byte(`<p>v3</p>`), 0o600))
+	out, err = execute(t, reloaded, "index.html")
+	require.NoError(t, err)
+	require.Equal(t, "<p>v3</p>", out)
+
+	_, err = New(Config{Patterns: []string{filepath.Join(dir, "*.tmpl")}})
+	require.Error(t, err)
+}
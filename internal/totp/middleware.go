@@ -0,0 +1,256 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/replay"
+	"github.com/skjdfhkskjds/go-api/internal/store"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Defaults of the middleware
+const (
+	DefaultHeader     = "X-OTP"
+	DefaultQuery      = "otp"
+	DefaultCookieName = "__otp_pass"
+	DefaultPassTTL    = 15 * time.Minute
+
+	DefaultMaxAttempts = 5
+	DefaultLockout     = 15 * time.Minute
+)
+
+// Config configures the Middleware
+type Config struct {
+	Options
+
+	// Secret of the key protecting the routes, in base32
+	Secret string
+
+	// Lookup returns the secret of the requesting account instead, e.g.
+	// of the user of the basic authentication, "" rejecting the request
+	Lookup func(c *types.Context) string
+
+	// Store remembers the passwords used, so that each is used once, an
+	// in-memory store if nil
+	Store store.Store
+
+	// Attempts counts the wrong passwords per account and per client IP,
+	// Store if it is a store.Counter, an in-memory store otherwise
+	Attempts store.Counter
+
+	// MaxAttempts is the number of wrong passwords locking the account, or
+	// the client IP, out for Lockout from the first one, so that the
+	// passwords cannot be guessed, DefaultMaxAttempts if 0
+	MaxAttempts int
+
+	// Lockout is the window of the wrong passwords, DefaultLockout if 0
+	Lockout time.Duration
+
+	// PassSecret signs the pass cookies with the secret of their account,
+	// random if empty: the passes then end with the process and are only
+	// valid on the instance that issued them
+	PassSecret []byte
+
+	// Name of the pass cookie, DefaultCookieName if empty
+	CookieName string
+
+	// Lifetime of a pass, DefaultPassTTL if 0, sparing the password for the
+	// next requests
+	PassTTL time.Duration
+
+	// Whether the pass cookie is only sent over HTTPS
+	Secure bool
+}
+
+// Middleware returns a middleware requiring a one-time password, e.g. for
+// the admin, pprof or docs routes
+//
+// The password is sent in the DefaultHeader header or the DefaultQuery
+// query parameter, for browsers, and is accepted once. The response sets a
+// pass cookie so that the next requests need no password until it expires.
+// Other requests are rejected with 401 Unauthorized, and with 429 Too Many
+// Requests once the account or the client IP sent MaxAttempts wrong
+// passwords.
+func Middleware(config Config) types.MiddlewareFunc {
+	if config.Secret == "" && config.Lookup == nil {
+		panic("totp: secret or lookup required")
+	}
+	if config.Lookup == nil {
+		config.Lookup = func(*types.Context) string { return config.Secret }
+	}
+	config.Options = config.Options.withDefaults()
+	if len(config.PassSecret) == 0 {
+		config.PassSecret = make([]byte, 32)
+		rand.Read(config.PassSecret)
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCookieName
+	}
+	if config.PassTTL <= 0 {
+		config.PassTTL = DefaultPassTTL
+	}
+	if config.Store == nil {
+		config.Store = store.NewMemory(store.MemoryConfig{})
+	}
+	if config.Attempts == nil {
+		if counter, ok := config.Store.(store.Counter); ok {
+			config.Attempts = counter
+		} else {
+			config.Attempts = store.NewMemory(store.MemoryConfig{})
+		}
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.Lockout <= 0 {
+		config.Lockout = DefaultLockout
+	}
+
+	// The passwords are remembered while they are accepted
+	window := config.Period * time.Duration(config.Skew+1)
+	used := replay.New(replay.Config{Store: config.Store, Window: window, Skew: window, Prefix: "totp:"})
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			secret := config.Lookup(c)
+			if secret != "" && passed(c, config, secret) {
+				next(c)
+				return
+			}
+			if secret != "" {
+				keys := attemptKeys(secret, c.GetClientIP())
+				if retryAfter := lockedOut(c, config, keys); retryAfter > 0 {
+					c.Abort()
+					c.Header("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+					c.ErrorString(http.StatusTooManyRequests, "too many wrong one-time passwords")
+					return
+				}
+				if checkPassword(c, config, used, secret, keys) {
+					next(c)
+					return
+				}
+			}
+			c.Abort()
+			c.ErrorString(http.StatusUnauthorized, "one-time password required")
+		}
+	}
+}
+
+// checkPassword validates the password of the request, setting the pass
+// cookie if it is valid and unused, and counting it as a wrong attempt
+// otherwise
+func checkPassword(c *types.Context, config Config, used *replay.Guard, secret string, keys []string) bool {
+	password := c.Request.Header.Get(DefaultHeader)
+	if password == "" {
+		password = c.GetQuery(DefaultQuery)
+	}
+	if password == "" {
+		return false
+	}
+
+	now := time.Now()
+	n, ok, err := Validate(secret, password, now, config.Options)
+	if err != nil {
+		c.Logger().Error("totp: validation failed", "error", err)
+		return false
+	}
+	if !ok {
+		countAttempt(c, config, keys)
+		return false
+	}
+	timestamp := time.Unix(int64(n)*int64(config.Period/time.Second), 0)
+	if err := used.Check(c.Request.Context(), fingerprint(secret)+":"+strconv.FormatUint(n, 10), timestamp); err != nil {
+		if !errors.Is(err, replay.ErrReplayed) {
+			c.Logger().Error("totp: validation failed", "error", err)
+			return false
+		}
+		countAttempt(c, config, keys)
+		return false
+	}
+
+	expiry := strconv.FormatInt(now.Add(config.PassTTL).Unix(), 10)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     config.CookieName,
+		Value:    expiry + "." + sign(config, secret, expiry),
+		Path:     "/",
+		MaxAge:   int(config.PassTTL.Seconds()),
+		Secure:   config.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return true
+}
+
+// attemptKeys returns the keys counting the wrong passwords of the account
+// of the secret and of the client IP
+func attemptKeys(secret, ip string) []string {
+	return []string{"totp:attempts:account:" + fingerprint(secret), "totp:attempts:ip:" + ip}
+}
+
+// lockedOut returns how long the account or the client IP are locked out
+// for, 0 if they are not
+func lockedOut(c *types.Context, config Config, keys []string) time.Duration {
+	var retryAfter time.Duration
+	for _, key := range keys {
+		count, ttl, err := config.Attempts.Increment(c.Request.Context(), key, 0, config.Lockout)
+		if err != nil {
+			c.Logger().Error("totp: counting attempts failed", "error", err)
+			continue
+		}
+		if count >= int64(config.MaxAttempts) {
+			retryAfter = max(retryAfter, ttl, time.Second)
+		}
+	}
+	return retryAfter
+}
+
+// countAttempt counts a wrong password of the account and the client IP
+func countAttempt(c *types.Context, config Config, keys []string) {
+	for _, key := range keys {
+		if _, _, err := config.Attempts.Increment(c.Request.Context(), key, 1, config.Lockout); err != nil {
+			c.Logger().Error("totp: counting attempts failed", "error", err)
+		}
+	}
+}
+
+// passed reports whether the request carries an unexpired pass of the
+// secret
+func passed(c *types.Context, config Config, secret string) bool {
+	value, err := c.GetCookie(config.CookieName)
+	if err != nil {
+		return false
+	}
+	expiry, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(sign(config, secret, expiry)))
+}
+
+// sign returns the signature of a pass of the secret, so that the passes
+// of an account are rejected for the others
+func sign(config Config, secret, expiry string) string {
+	mac := hmac.New(sha256.New, config.PassSecret)
+	mac.Write([]byte(expiry))
+	mac.Write([]byte{0})
+	mac.Write([]byte(secret))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// fingerprint identifies a secret in the store without revealing it
+func fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,168 @@
+// Package totp generates and validates time-based one-time passwords, see
+// RFC 6238, e.g. as a second factor for the admin endpoints
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the passwords, those of the authenticator apps
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30 * time.Second
+	DefaultSkew   = 1
+)
+
+// ErrInvalidSecret is returned for secrets that are not base32
+var ErrInvalidSecret = errors.New("totp: invalid secret")
+
+// encoding is the base32 encoding of the secrets, without padding
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Options are the parameters of the passwords, shared by the enrollment
+// and the validation
+type Options struct {
+	// Digits of the passwords, DefaultDigits if 0
+	Digits int
+
+	// Period of the passwords, in seconds, DefaultPeriod if 0
+	Period time.Duration
+
+	// Skew is the number of periods before and after the current one whose
+	// passwords are accepted, for clocks running late or early,
+	// DefaultSkew if 0, none if negative
+	Skew int
+}
+
+// withDefaults returns the options with the defaults for unset fields
+func (o Options) withDefaults() Options {
+	if o.Digits <= 0 {
+		o.Digits = DefaultDigits
+	}
+	if o.Period < time.Second {
+		o.Period = DefaultPeriod
+	}
+	if o.Skew == 0 {
+		o.Skew = DefaultSkew
+	} else if o.Skew < 0 {
+		o.Skew = 0
+	}
+	return o
+}
+
+// Key is the key of an account, enrolled in its authenticator app
+type Key struct {
+	// Secret in base32, as entered in the apps
+	Secret string
+
+	// Issuer and Account label the key in the apps, e.g. "api" and
+	// "ada@example.com"
+	Issuer  string
+	Account string
+
+	Options Options
+}
+
+// GenerateKey generates a key with a random secret of 160 bits, the size
+// recommended by RFC 4226
+func GenerateKey(issuer, account string, options Options) *Key {
+	secret := make([]byte, 20)
+	rand.Read(secret)
+	return &Key{Secret: encoding.EncodeToString(secret), Issuer: issuer, Account: account, Options: options}
+}
+
+// URL returns the otpauth URL of the key, shown as a QR code for the apps
+// to scan, e.g. "otpauth://totp/api:ada?secret=...&issuer=api"
+func (k *Key) URL() string {
+	options := k.Options.withDefaults()
+	label := url.PathEscape(k.Account)
+	if k.Issuer != "" {
+		label = url.PathEscape(k.Issuer) + ":" + label
+	}
+	query := url.Values{
+		"secret":    {k.Secret},
+		"algorithm": {"SHA1"},
+		"digits":    {strconv.Itoa(options.Digits)},
+		"period":    {strconv.Itoa(int(options.Period / time.Second))},
+	}
+	if k.Issuer != "" {
+		query.Set("issuer", k.Issuer)
+	}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Code returns the password of the secret at the time
+func Code(secret string, t time.Time, options Options) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	options = options.withDefaults()
+	return code(key, counter(t, options.Period), options.Digits), nil
+}
+
+// Validate reports whether the password is that of the secret at the time
+//
+// @return: the counter of the period of the password, for callers
+// rejecting the passwords used already, see Middleware
+func Validate(secret, password string, t time.Time, options Options) (uint64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	options = options.withDefaults()
+	password = strings.ReplaceAll(password, " ", "")
+	if len(password) != options.Digits {
+		return 0, false, nil
+	}
+
+	current := counter(t, options.Period)
+	for delta := -options.Skew; delta <= options.Skew; delta++ {
+		n := current + uint64(delta)
+		if subtle.ConstantTimeCompare([]byte(code(key, n, options.Digits)), []byte(password)) == 1 {
+			return n, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// decodeSecret decodes a base32 secret, ignoring case, spaces and padding
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// counter returns the number of periods since the Unix epoch
+func counter(t time.Time, period time.Duration) uint64 {
+	return uint64(t.Unix() / int64(period/time.Second))
+}
+
+// code returns the HOTP password of the counter, see RFC 4226 section 5.3
+func code(key []byte, counter uint64, digits int) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+
+	modulo := uint64(1)
+	for range digits {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
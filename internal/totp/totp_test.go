@@ -0,0 +1,132 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCode(t *testing.T) {
+	// Test vectors of RFC 6238 appendix B, for SHA-1
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "94287082",
+		1111111109: "07081804",
+		1234567890: "89005924",
+		2000000000: "69279037",
+	} {
+		code, err := Code(secret, time.Unix(unix, 0), Options{Digits: 8})
+		require.NoError(t, err)
+		require.Equal(t, want, code)
+	}
+
+	_, err := Code("not base32!", time.Now(), Options{})
+	require.ErrorIs(t, err, ErrInvalidSecret)
+}
+
+func TestValidate(t *testing.T) {
+	key := GenerateKey("api", "ada@example.com", Options{})
+	now := time.Now()
+	code, err := Code(key.Secret, now, Options{})
+	require.NoError(t, err)
+	require.Len(t, code, DefaultDigits)
+
+	// The passwords of the neighbouring periods are accepted
+	n, ok, err := Validate(key.Secret, code, now.Add(DefaultPeriod), Options{})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(now.Unix()/30), n)
+	_, ok, _ = Validate(key.Secret, code, now.Add(3*DefaultPeriod), Options{})
+	require.False(t, ok)
+	_, ok, _ = Validate(key.Secret, code, now.Add(DefaultPeriod), Options{Skew: -1})
+	require.False(t, ok)
+	_, ok, _ = Validate(key.Secret, "000", now, Options{})
+	require.False(t, ok)
+
+	u, err := url.Parse(key.URL())
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "/api:ada@example.com", u.Path)
+	require.Equal(t, key.Secret, u.Query().Get("secret"))
+	require.Equal(t, "30", u.Query().Get("period"))
+}
+
+func TestMiddleware(t *testing.T) {
+	key := GenerateKey("api", "admin", Options{})
+	handler := Middleware(Config{Secret: key.Secret})(func(c *types.Context) {
+		c.String(http.StatusOK, "pprof")
+	})
+	serve := func(password string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+		if password != "" {
+			r.Header.Set(DefaultHeader, password)
+		}
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler(&types.Context{Request: r, Writer: w})
+		return w
+	}
+
+	require.Equal(t, http.StatusUnauthorized, serve("").Code)
+	require.Equal(t, http.StatusUnauthorized, serve("123456").Code)
+
+	code, err := Code(key.Secret, time.Now(), Options{})
+	require.NoError(t, err)
+	w := serve(code)
+	require.Equal(t, http.StatusOK, w.Code)
+	pass := w.Result().Cookies()[0]
+	require.Equal(t, DefaultCookieName, pass.Name)
+
+	// The password is used once, the pass lets the next requests through
+	require.Equal(t, http.StatusUnauthorized, serve(code).Code)
+	require.Equal(t, http.StatusOK, serve("", pass).Code)
+	pass.Value += "x"
+	require.Equal(t, http.StatusUnauthorized, serve("", pass).Code)
+}
+
+func TestMiddleware_Lockout(t *testing.T) {
+	alice, bob := GenerateKey("api", "alice", Options{}), GenerateKey("api", "bob", Options{})
+	secrets := map[string]string{"alice": alice.Secret, "bob": bob.Secret}
+	handler := Middleware(Config{
+		Lookup:      func(c *types.Context) string { return secrets[c.GetHeader("X-User")] },
+		MaxAttempts: 3,
+	})(func(c *types.Context) {
+		c.String(http.StatusOK, "pprof")
+	})
+	serve := func(user, ip, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+		r.RemoteAddr = ip + ":1234"
+		r.Header.Set("X-User", user)
+		r.Header.Set(DefaultHeader, password)
+		w := httptest.NewRecorder()
+		handler(&types.Context{Request: r, Writer: w})
+		return w
+	}
+	code := func(key *Key) string {
+		code, err := Code(key.Secret, time.Now(), Options{})
+		require.NoError(t, err)
+		return code
+	}
+
+	// Wrong passwords from different IPs lock the account out
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		require.Equal(t, http.StatusUnauthorized, serve("alice", ip, "000000").Code)
+	}
+	w := serve("alice", "10.0.0.4", code(alice))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// and wrong passwords for different accounts lock the IP out
+	require.Equal(t, http.StatusUnauthorized, serve("bob", "10.0.0.1", "000000").Code)
+	require.Equal(t, http.StatusUnauthorized, serve("bob", "10.0.0.1", "000000").Code)
+	require.Equal(t, http.StatusTooManyRequests, serve("bob", "10.0.0.1", code(bob)).Code)
+	require.Equal(t, http.StatusOK, serve("bob", "10.0.0.5", code(bob)).Code)
+}
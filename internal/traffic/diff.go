@@ -0,0 +1,257 @@
+package traffic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/textproto"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/skjdfhkskjds/go-api/internal/engine"
+)
+
+// DefaultIgnoredHeaders are the response headers not compared when
+// CompareConfig.IgnoreHeaders is nil, they differ between any two
+// responses
+var DefaultIgnoredHeaders = []string{"Date", "Age", "X-Request-Id"}
+
+// CompareConfig configures a comparison
+type CompareConfig struct {
+	// Rate is the number of records replayed per second, against both
+	// engines, as fast as possible if 0
+	Rate float64
+
+	// IgnoreHeaders lists the response headers not compared,
+	// DefaultIgnoredHeaders if nil
+	IgnoreHeaders []string
+
+	// IgnorePaths lists the parts of JSON bodies not compared, e.g.
+	// "$.updated_at" or "$.items[*].id", where * matches any key or index.
+	// The values below an ignored path are ignored too.
+	IgnorePaths []string
+
+	// OnDiff is called with every record whose responses differ, may be nil
+	OnDiff func(diff *ResponseDiff)
+}
+
+// Difference is a difference between the baseline and the candidate
+// response, values are formatted as JSON for bodies and as text otherwise
+type Difference struct {
+	Path      string `json:"path"` // status, header X-Name, body or a JSON path, e.g. $.users[0].name
+	Baseline  string `json:"baseline"`
+	Candidate string `json:"candidate"`
+}
+
+// ResponseDiff lists the differences of the responses to a record
+type ResponseDiff struct {
+	Record      *Record      `json:"record"`
+	Differences []Difference `json:"differences"`
+}
+
+// CompareReport summarizes a comparison
+type CompareReport struct {
+	Requests int             `json:"requests"` // records replayed
+	Skipped  int             `json:"skipped"`  // records with a truncated body
+	Diffs    []*ResponseDiff `json:"diffs"`    // records whose responses differ
+}
+
+// Compare replays the records read from r against a baseline and a
+// candidate engine, e.g. the current and the refactored handlers, and
+// reports the differences of their responses
+//
+// Statuses and headers are compared, as well as bodies, structurally when
+// both are JSON and byte for byte otherwise.
+//
+// @return: the report of the records compared
+// @return: an error if a record could not be read or dispatched, the
+// ignored paths are invalid or the context ended
+func Compare(ctx context.Context, baseline, candidate *engine.Engine, r io.Reader, config CompareConfig) (*CompareReport, error) {
+	differ, err := newDiffer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompareReport{}
+	report.Requests, report.Skipped, err = replay(ctx, r, config.Rate, func(record *Record) error {
+		base, err := baseline.Dispatch(record.Method, record.Target, dispatchOptions(ctx, record)...)
+		if err != nil {
+			return fmt.Errorf("baseline: %w", err)
+		}
+		cand, err := candidate.Dispatch(record.Method, record.Target, dispatchOptions(ctx, record)...)
+		if err != nil {
+			return fmt.Errorf("candidate: %w", err)
+		}
+
+		if differences := differ.diff(base, cand); len(differences) > 0 {
+			diff := &ResponseDiff{Record: record, Differences: differences}
+			report.Diffs = append(report.Diffs, diff)
+			if config.OnDiff != nil {
+				config.OnDiff(diff)
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// differ compares responses according to the ignore rules
+type differ struct {
+	headers map[string]struct{}
+	paths   []*regexp.Regexp
+}
+
+// newDiffer prepares the ignore rules of a comparison
+func newDiffer(config CompareConfig) (*differ, error) {
+	ignored := config.IgnoreHeaders
+	if ignored == nil {
+		ignored = DefaultIgnoredHeaders
+	}
+	d := &differ{headers: make(map[string]struct{}, len(ignored))}
+	for _, name := range ignored {
+		d.headers[textproto.CanonicalMIMEHeaderKey(name)] = struct{}{}
+	}
+
+	for _, path := range config.IgnorePaths {
+		if !strings.HasPrefix(path, "$") {
+			return nil, fmt.Errorf("traffic: ignored path %q must start with $", path)
+		}
+		pattern := regexp.QuoteMeta(path)
+		pattern = strings.ReplaceAll(pattern, `\[\*\]`, `\[\d+\]`)
+		pattern = strings.ReplaceAll(pattern, `\.\*`, `\.[^.\[]+`)
+		d.paths = append(d.paths, regexp.MustCompile("^"+pattern+`($|[.\[])`))
+	}
+	return d, nil
+}
+
+// diff returns the differences between two responses
+func (d *differ) diff(base, cand *engine.RecordedResponse) []Difference {
+	var differences []Difference
+	if base.Status != cand.Status {
+		differences = append(differences, Difference{
+			Path:      "status",
+			Baseline:  strconv.Itoa(base.Status),
+			Candidate: strconv.Itoa(cand.Status),
+		})
+	}
+
+	var names []string
+	for name := range base.Header {
+		names = append(names, name)
+	}
+	for name := range cand.Header {
+		if _, ok := base.Header[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if _, ok := d.headers[name]; ok {
+			continue
+		}
+		baseValue, candValue := strings.Join(base.Header[name], ", "), strings.Join(cand.Header[name], ", ")
+		if baseValue != candValue {
+			differences = append(differences, Difference{Path: "header " + name, Baseline: baseValue, Candidate: candValue})
+		}
+	}
+
+	baseJSON, baseOK := decodeJSON(base)
+	candJSON, candOK := decodeJSON(cand)
+	switch {
+	case baseOK && candOK:
+		differences = d.diffJSON(differences, "$", baseJSON, candJSON)
+	case !bytes.Equal(base.Body, cand.Body):
+		differences = append(differences, Difference{Path: "body", Baseline: string(base.Body), Candidate: string(cand.Body)})
+	}
+	return differences
+}
+
+// diffJSON appends the differences between two decoded JSON values
+func (d *differ) diffJSON(differences []Difference, path string, base, cand any) []Difference {
+	for _, ignored := range d.paths {
+		if ignored.MatchString(path) {
+			return differences
+		}
+	}
+
+	switch base := base.(type) {
+	case map[string]any:
+		if cand, ok := cand.(map[string]any); ok {
+			keys := make([]string, 0, len(base)+len(cand))
+			for key := range base {
+				keys = append(keys, key)
+			}
+			for key := range cand {
+				if _, ok := base[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				var baseValue, candValue any = missing{}, missing{}
+				if value, ok := base[key]; ok {
+					baseValue = value
+				}
+				if value, ok := cand[key]; ok {
+					candValue = value
+				}
+				differences = d.diffJSON(differences, path+"."+key, baseValue, candValue)
+			}
+			return differences
+		}
+	case []any:
+		if cand, ok := cand.([]any); ok {
+			for i := range max(len(base), len(cand)) {
+				var baseItem, candItem any = missing{}, missing{}
+				if i < len(base) {
+					baseItem = base[i]
+				}
+				if i < len(cand) {
+					candItem = cand[i]
+				}
+				differences = d.diffJSON(differences, path+"["+strconv.Itoa(i)+"]", baseItem, candItem)
+			}
+			return differences
+		}
+	}
+
+	baseValue, candValue := formatJSON(base), formatJSON(cand)
+	if baseValue != candValue {
+		differences = append(differences, Difference{Path: path, Baseline: baseValue, Candidate: candValue})
+	}
+	return differences
+}
+
+// missing stands for absent object keys and array items, so that they
+// differ from null values
+type missing struct{}
+
+// decodeJSON decodes the body of a JSON response, numbers being kept as
+// written
+func decodeJSON(resp *engine.RecordedResponse) (any, bool) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(resp.Body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// formatJSON formats a decoded JSON value, empty for absent values
+func formatJSON(value any) string {
+	if _, ok := value.(missing); ok {
+		return ""
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
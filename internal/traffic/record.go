@@ -0,0 +1,145 @@
+// Package traffic records sampled requests and replays them through an
+// engine, e.g. for load testing or to compare the responses of a new
+// version with the recorded ones
+package traffic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// DefaultMaxBodySize is the largest request body recorded when
+// RecorderConfig.MaxBodySize is not set
+const DefaultMaxBodySize = 64 << 10
+
+// DefaultRedactedHeaders are the headers whose values are not recorded when
+// RecorderConfig.Redact is nil
+var DefaultRedactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// redacted replaces the values of redacted headers
+const redacted = "REDACTED"
+
+// Record is a recorded request, stored as one line of JSON
+type Record struct {
+	Time      time.Time   `json:"time"`
+	Method    string      `json:"method"`
+	Target    string      `json:"target"` // path and query
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"body,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"` // the body exceeded the size limit
+	Status    int         `json:"status"`              // of the recorded response
+}
+
+// RecorderConfig configures the recorded requests
+type RecorderConfig struct {
+	// Output receives the records, one JSON object per line, e.g. a file
+	Output io.Writer
+
+	// SampleRate is the fraction of requests recorded, between 0 and 1,
+	// every request if 0
+	SampleRate float64
+
+	// MaxBodySize is the largest body recorded, larger bodies are recorded
+	// truncated and skipped when replayed, DefaultMaxBodySize if 0
+	MaxBodySize int64
+
+	// Redact lists the headers recorded without their values,
+	// DefaultRedactedHeaders if nil
+	Redact []string
+}
+
+// Recorder returns a middleware recording the sampled requests along with
+// the status of their responses
+//
+// The recorded bodies are still read in full by the handlers. Records that
+// cannot be written are logged.
+func Recorder(config RecorderConfig) types.MiddlewareFunc {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	if config.Redact == nil {
+		config.Redact = DefaultRedactedHeaders
+	}
+	redact := make(map[string]struct{}, len(config.Redact))
+	for _, name := range config.Redact {
+		redact[textproto.CanonicalMIMEHeaderKey(name)] = struct{}{}
+	}
+
+	var mu sync.Mutex
+	encoder := json.NewEncoder(config.Output)
+
+	return func(next types.HandlerFunc) types.HandlerFunc {
+		return func(c *types.Context) {
+			if config.SampleRate > 0 && rand.Float64() >= config.SampleRate {
+				next(c)
+				return
+			}
+
+			record := &Record{
+				Time:   time.Now(),
+				Method: c.Request.Method,
+				Target: c.Request.URL.RequestURI(),
+				Header: c.Request.Header.Clone(),
+			}
+			for name := range record.Header {
+				if _, ok := redact[name]; ok {
+					record.Header[name] = []string{redacted}
+				}
+			}
+
+			if c.Request.Body != nil && c.Request.Body != http.NoBody {
+				body, err := io.ReadAll(io.LimitReader(c.Request.Body, config.MaxBodySize+1))
+				if int64(len(body)) > config.MaxBodySize {
+					record.Truncated = true
+				}
+				record.Body = body[:min(int64(len(body)), config.MaxBodySize)]
+				c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), errReader{err}, c.Request.Body), c.Request.Body}
+			}
+
+			writer, ok := c.Writer.(*types.ResponseWriter)
+			if !ok {
+				writer = types.NewResponseWriter(c.Writer)
+				c.Writer = writer
+				defer func() { c.Writer = writer.ResponseWriter }()
+			}
+
+			next(c)
+
+			record.Status = writer.Status()
+			mu.Lock()
+			err := encoder.Encode(record)
+			mu.Unlock()
+			if err != nil {
+				c.Logger().Error("traffic: recording failed", "target", record.Target, "error", err)
+			}
+		}
+	}
+}
+
+// readCloser reads the recorded start of a body before the rest of it
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader returns the error that interrupted the recording of a body,
+// io.EOF once the body was read without error
+type errReader struct {
+	err error
+}
+
+// Read implements io.Reader
+func (r errReader) Read([]byte) (int, error) {
+	if r.err == nil {
+		return 0, io.EOF
+	}
+	return 0, r.err
+}
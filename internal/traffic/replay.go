@@ -0,0 +1,129 @@
+package traffic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/engine"
+)
+
+// maxRecordSize is the longest line read by Replay
+const maxRecordSize = 16 << 20
+
+// ReplayConfig configures a replay
+type ReplayConfig struct {
+	// Rate is the number of requests replayed per second, as fast as
+	// possible if 0
+	Rate float64
+
+	// OnResponse is called with every replayed record and its response, may
+	// be nil
+	OnResponse func(record *Record, resp *engine.RecordedResponse)
+}
+
+// ReplayReport summarizes a replay
+type ReplayReport struct {
+	Requests   int         // replayed requests
+	Skipped    int         // records with a truncated body
+	Mismatches int         // responses whose status differs from the recorded one
+	Statuses   map[int]int // number of responses by status
+	Duration   time.Duration
+}
+
+// Replay feeds the records read from r through the engine with
+// Engine.Dispatch, in order, until the records or the context end
+//
+// @return: the report of the requests replayed
+// @return: an error if a record could not be read or dispatched, or the
+// context ended
+func Replay(ctx context.Context, e *engine.Engine, r io.Reader, config ReplayConfig) (*ReplayReport, error) {
+	report := &ReplayReport{Statuses: make(map[int]int)}
+	start := time.Now()
+	defer func() { report.Duration = time.Since(start) }()
+
+	var err error
+	report.Requests, report.Skipped, err = replay(ctx, r, config.Rate, func(record *Record) error {
+		resp, err := e.Dispatch(record.Method, record.Target, dispatchOptions(ctx, record)...)
+		if err != nil {
+			return err
+		}
+		report.Statuses[resp.Status]++
+		if record.Status != 0 && resp.Status != record.Status {
+			report.Mismatches++
+		}
+		if config.OnResponse != nil {
+			config.OnResponse(record, resp)
+		}
+		return nil
+	})
+	return report, err
+}
+
+// replay calls fn with the records read from r, at most rate times per
+// second if positive, skipping the records with a truncated body
+//
+// @return: the number of records replayed and skipped
+// @return: an error if a record could not be read, fn failed or the
+// context ended
+func replay(ctx context.Context, r io.Reader, rate float64, fn func(record *Record) error) (int, int, error) {
+	var ticker *time.Ticker
+	if rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+	}
+
+	requests, skipped := 0, 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return requests, skipped, fmt.Errorf("traffic: record %d: %w", line, err)
+		}
+		if record.Truncated {
+			skipped++
+			continue
+		}
+
+		if ticker != nil && requests > 0 {
+			select {
+			case <-ctx.Done():
+				return requests, skipped, ctx.Err()
+			case <-ticker.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return requests, skipped, err
+		}
+
+		if err := fn(&record); err != nil {
+			return requests, skipped, fmt.Errorf("traffic: record %d: %w", line, err)
+		}
+		requests++
+	}
+	if err := scanner.Err(); err != nil {
+		return requests, skipped, fmt.Errorf("traffic: %w", err)
+	}
+	return requests, skipped, nil
+}
+
+// dispatchOptions returns the options dispatching a record
+func dispatchOptions(ctx context.Context, record *Record) []engine.DispatchOption {
+	opts := []engine.DispatchOption{engine.DispatchContext(ctx)}
+	for name, values := range record.Header {
+		if name == "Content-Type" || name == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			opts = append(opts, engine.DispatchHeader(name, value))
+		}
+	}
+	if len(record.Body) > 0 {
+		opts = append(opts, engine.DispatchBody(record.Header.Get("Content-Type"), bytes.NewReader(record.Body)))
+	}
+	return opts
+}
@@ -0,0 +1,185 @@
+package traffic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/engine"
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// newEngine creates an engine echoing request bodies, answering with the
+// given status
+func newEngine(t *testing.T, status int) *engine.Engine {
+	e := engine.MustNew(nil)
+	e.POST("/echo", func(c *types.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(status, "text/plain", body)
+	})
+	e.GET("/users/:id", func(c *types.Context) { c.String(http.StatusOK, c.GetHeader("Authorization")) })
+	return e
+}
+
+func TestRecorder(t *testing.T) {
+	var out bytes.Buffer
+	e := newEngine(t, http.StatusOK)
+	e.Use(Recorder(RecorderConfig{Output: &out, MaxBodySize: 8}))
+
+	for _, body := range []string{"short", "longer than eight"} {
+		r := httptest.NewRequest(http.MethodPost, "/echo?x=1", strings.NewReader(body))
+		r.Header.Set("Content-Type", "text/plain")
+		r.Header.Set("Cookie", "session=secret")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		// Handlers read the whole body
+		require.Equal(t, body, w.Body.String())
+	}
+
+	var records []Record
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var record Record
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+	require.Equal(t, http.MethodPost, records[0].Method)
+	require.Equal(t, "/echo?x=1", records[0].Target)
+	require.Equal(t, "short", string(records[0].Body))
+	require.Equal(t, "REDACTED", records[0].Header.Get("Cookie"))
+	require.Equal(t, "text/plain", records[0].Header.Get("Content-Type"))
+	require.Equal(t, http.StatusOK, records[0].Status)
+	require.False(t, records[0].Truncated)
+	require.Equal(t, "longer t", string(records[1].Body))
+	require.True(t, records[1].Truncated)
+}
+
+func TestRecorder_SampleRate(t *testing.T) {
+	var out bytes.Buffer
+	recorder := Recorder(RecorderConfig{Output: &out, SampleRate: 0.5})
+	for range 1000 {
+		c := &types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: httptest.NewRecorder()}
+		c.Execute(types.Chain([]types.MiddlewareFunc{recorder}, func(c *types.Context) {}))
+	}
+	require.InDelta(t, 500, strings.Count(out.String(), "\n"), 100)
+}
+
+func TestReplay(t *testing.T) {
+	records := []Record{
+		{Method: http.MethodPost, Target: "/echo", Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("hello"), Status: http.StatusOK},
+		{Method: http.MethodGet, Target: "/users/1", Header: http.Header{"Authorization": {"Bearer t"}}, Status: http.StatusOK},
+		{Method: http.MethodPost, Target: "/echo", Body: []byte("trunc"), Truncated: true},
+		{Method: http.MethodGet, Target: "/missing", Status: http.StatusNotFound},
+	}
+	var in bytes.Buffer
+	for _, record := range records {
+		require.NoError(t, json.NewEncoder(&in).Encode(record))
+	}
+
+	// The new version answers the echo with another status
+	var bodies []string
+	report, err := Replay(context.Background(), newEngine(t, http.StatusAccepted), bytes.NewReader(in.Bytes()), ReplayConfig{
+		Rate: 100,
+		OnResponse: func(record *Record, resp *engine.RecordedResponse) {
+			bodies = append(bodies, string(resp.Body))
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Requests)
+	require.Equal(t, 1, report.Skipped)
+	require.Equal(t, 1, report.Mismatches)
+	require.Equal(t, map[int]int{http.StatusAccepted: 1, http.StatusOK: 1, http.StatusNotFound: 1}, report.Statuses)
+	require.Equal(t, "hello", bodies[0])
+	require.Equal(t, "Bearer t", bodies[1])
+	require.GreaterOrEqual(t, report.Duration, 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Replay(ctx, newEngine(t, http.StatusOK), bytes.NewReader(in.Bytes()), ReplayConfig{})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = Replay(context.Background(), newEngine(t, http.StatusOK), strings.NewReader("{}\nnot json\n"), ReplayConfig{})
+	require.ErrorContains(t, err, "record 2")
+}
+
+func TestCompare(t *testing.T) {
+	// The candidate renames a field, adds an item and a header, and
+	// generates other ids
+	handler := func(candidate bool) types.HandlerFunc {
+		return func(c *types.Context) {
+			users := []map[string]any{{"id": 1, "name": "ada", "updated_at": "monday"}}
+			if candidate {
+				users = []map[string]any{{"id": 1, "full_name": "ada", "updated_at": "tuesday"}, {"id": 2}}
+				c.Header("X-Version", "2")
+			}
+			c.Header("Date", "now")
+			c.JSON(http.StatusOK, map[string]any{"users": users, "request": map[string]any{"id": c.GetHeader("X-Seed")}})
+		}
+	}
+	baseline, candidate := engine.MustNew(nil), engine.MustNew(nil)
+	baseline.GET("/users", handler(false))
+	candidate.GET("/users", handler(true))
+	for _, e := range []*engine.Engine{baseline, candidate} {
+		e.GET("/text", func(c *types.Context) { c.String(http.StatusOK, "same") })
+	}
+
+	var in bytes.Buffer
+	for _, record := range []Record{
+		{Method: http.MethodGet, Target: "/users"},
+		{Method: http.MethodGet, Target: "/text"},
+		{Method: http.MethodGet, Target: "/missing"},
+	} {
+		require.NoError(t, json.NewEncoder(&in).Encode(record))
+	}
+
+	var diffs int
+	report, err := Compare(context.Background(), baseline, candidate, &in, CompareConfig{
+		IgnorePaths: []string{"$.users[*].updated_at", "$.request"},
+		OnDiff:      func(*ResponseDiff) { diffs++ },
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Requests)
+	require.Len(t, report.Diffs, 1)
+	require.Equal(t, 1, diffs)
+	require.Equal(t, "/users", report.Diffs[0].Record.Target)
+	require.Equal(t, []Difference{
+		{Path: "header X-Version", Baseline: "", Candidate: "2"},
+		{Path: "$.users[0].full_name", Baseline: "", Candidate: `"ada"`},
+		{Path: "$.users[0].name", Baseline: `"ada"`, Candidate: ""},
+		{Path: "$.users[1]", Baseline: "", Candidate: `{"id":2}`},
+	}, report.Diffs[0].Differences)
+
+	_, err = Compare(context.Background(), baseline, candidate, strings.NewReader(""), CompareConfig{IgnorePaths: []string{"users"}})
+	require.Error(t, err)
+}
+
+func TestDiffer(t *testing.T) {
+	d, err := newDiffer(CompareConfig{IgnoreHeaders: []string{}})
+	require.NoError(t, err)
+
+	text := func(status int, body string) *engine.RecordedResponse {
+		return &engine.RecordedResponse{Status: status, Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte(body)}
+	}
+	require.Empty(t, d.diff(text(http.StatusOK, "a"), text(http.StatusOK, "a")))
+	require.Equal(t, []Difference{
+		{Path: "status", Baseline: "200", Candidate: "500"},
+		{Path: "body", Baseline: "a", Candidate: "b"},
+	}, d.diff(text(http.StatusOK, "a"), text(http.StatusInternalServerError, "b")))
+
+	// Numbers are compared as written, null differs from absent keys
+	jsonBody := func(body string) *engine.RecordedResponse {
+		return &engine.RecordedResponse{Status: http.StatusOK, Header: http.Header{"Content-Type": {"application/problem+json"}}, Body: []byte(body)}
+	}
+	require.Equal(t, []Difference{
+		{Path: "$.a", Baseline: "1.0", Candidate: "1"},
+		{Path: "$.b", Baseline: "null", Candidate: ""},
+	}, d.diff(jsonBody(`{"a":1.0,"b":null}`), jsonBody(`{"a":1}`)))
+}
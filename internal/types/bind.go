@@ -0,0 +1,234 @@
+package types
+
+import (
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedMediaType is returned by Context.ShouldBind for request
+// bodies it cannot decode
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// ShouldBind binds the request to a struct, with the binding matching the
+// request
+//
+// Requests without a body, e.g. GET requests, bind their query with
+// Context.BindQuery. Bodies are bound by their Content-Type: JSON and XML,
+// including the +json and +xml suffixes, and urlencoded and multipart
+// forms.
+//
+// @return: ErrUnsupportedMediaType for other bodies, or the error of the
+// binding
+func (c *Context) ShouldBind(obj any) error {
+	r := c.Request
+	if r.Method == http.MethodGet || r.Method == http.MethodHead ||
+		(r.ContentLength == 0 && r.Header.Get("Content-Type") == "") {
+		return c.BindQuery(obj)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return c.BindJSON(obj)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return c.BindXML(obj)
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		return c.BindForm(obj)
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedMediaType, mediaType)
+}
+
+// MustBind binds the request like Context.ShouldBind, and on failure
+// aborts the request with 400 Bad Request, or 415 Unsupported Media Type
+//
+// @return: whether the request was bound, the handler should return
+// otherwise
+func (c *Context) MustBind(obj any) bool {
+	err := c.ShouldBind(obj)
+	if err == nil {
+		return true
+	}
+
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		status = http.StatusUnsupportedMediaType
+	}
+	c.Abort()
+	c.Error(status, err)
+	return false
+}
+
+// BindXML binds XML request body to a struct
+func (c *Context) BindXML(obj any) error {
+	return xml.NewDecoder(c.Request.Body).Decode(obj)
+}
+
+// BindQuery binds the query parameters of the request to a struct, named
+// by the form tags of the fields like with Context.BindForm, so that one
+// struct binds both GET and POST forms
+//
+// @return: an error naming every parameter that could not be parsed
+func (c *Context) BindQuery(obj any) error {
+	return bind(obj, binding{tag: "form", what: "query parameter", values: c.Request.URL.Query()})
+}
+
+// BindQueryInto binds the query parameters like Context.BindQuery into a
+// struct reused across requests, e.g. taken from a sync.Pool, zeroing it
+// first so that no value of a previous request remains
+//
+// @return: an error naming every parameter that could not be parsed
+func (c *Context) BindQueryInto(obj any) error {
+	if v := reflect.ValueOf(obj); v.Kind() == reflect.Pointer && !v.IsNil() {
+		v.Elem().SetZero()
+	}
+	return c.BindQuery(obj)
+}
+
+// BindURI binds the path parameters of the request to a struct
+//
+// Fields are named by their uri tag, or by their Go name without one, and
+// converted like with Context.BindForm, e.g. to integers, booleans or
+// uuid.UUID through encoding.TextUnmarshaler.
+//
+// @return: an error naming every parameter that could not be parsed
+func (c *Context) BindURI(obj any) error {
+	values := make(url.Values, len(c.Params))
+	for _, p := range c.Params {
+		values[p.Key] = append(values[p.Key], p.Value)
+	}
+	return bind(obj, binding{tag: "uri", what: "path parameter", values: values})
+}
+
+// binding is a source of values bound to struct fields, e.g. form fields
+type binding struct {
+	tag    string // struct tag naming the fields
+	what   string // kind of value, in errors
+	values url.Values
+	files  map[string][]*multipart.FileHeader
+}
+
+// bind binds values and files to the struct pointed to by obj
+func bind(obj any, b binding) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binding %s: %T is not a pointer to a struct", b.tag, obj)
+	}
+	return bindStruct(v.Elem(), b)
+}
+
+// bindStruct binds values and files to the fields of a struct, described
+// by DefaultBindCache
+func bindStruct(v reflect.Value, b binding) error {
+	var errs []error
+	for _, field := range DefaultBindCache.fields(v.Type(), b.tag) {
+		fv := v.FieldByIndex(field.index)
+		switch field.kind {
+		case fileField:
+			if headers := b.files[field.name]; len(headers) > 0 {
+				fv.Set(reflect.ValueOf(headers[0]))
+			}
+		case filesField:
+			if headers := b.files[field.name]; len(headers) > 0 {
+				fv.Set(reflect.ValueOf(headers))
+			}
+		case sliceField:
+			if err := setSlice(fv, b.values[field.name]); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", b.what, field.name, err))
+			}
+		default:
+			if values := b.values[field.name]; len(values) > 0 {
+				if err := setValue(fv, values[0]); err != nil {
+					errs = append(errs, fmt.Errorf("%s %s: %w", b.what, field.name, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setSlice parses the values of a name into a slice field
+func setSlice(v reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	slice := reflect.MakeSlice(v.Type(), 0, len(values))
+	for _, value := range values {
+		item := reflect.New(v.Type().Elem()).Elem()
+		if err := setValue(item, value); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, item)
+	}
+	v.Set(slice)
+	return nil
+}
+
+// setValue parses a single value
+func setValue(v reflect.Value, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		target := reflect.New(v.Type().Elem())
+		if err := setValue(target.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(target)
+		return nil
+	}
+
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(value))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		switch strings.ToLower(value) {
+		case "on": // checkboxes without a value
+			v.SetBool(true)
+		case "off":
+			v.SetBool(false)
+		default:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", value)
+			}
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", value)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
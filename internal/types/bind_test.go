@@ -0,0 +1,180 @@
+package types
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testUUID parses like uuid.UUID
+type testUUID [16]byte
+
+func (u *testUUID) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(strings.ReplaceAll(string(text), "-", ""))
+	if err != nil || len(b) != 16 {
+		return fmt.Errorf("invalid UUID %q", text)
+	}
+	copy(u[:], b)
+	return nil
+}
+
+func TestContext_BindURI(t *testing.T) {
+	type userURI struct {
+		ID      int      `uri:"id"`
+		Org     testUUID `uri:"org"`
+		Active  bool     `uri:"active"`
+		Page    *uint    `uri:"page"`
+		Missing string   `uri:"missing"`
+	}
+
+	c := &Context{Params: Params{
+		{Key: "id", Value: "42"},
+		{Key: "org", Value: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{Key: "active", Value: "true"},
+		{Key: "page", Value: "3"},
+	}}
+
+	var got userURI
+	require.NoError(t, c.BindURI(&got))
+	require.Equal(t, 42, got.ID)
+	require.Equal(t, byte(0x6b), got.Org[0])
+	require.True(t, got.Active)
+	require.Equal(t, uint(3), *got.Page)
+	require.Empty(t, got.Missing)
+
+	c.Params = Params{{Key: "id", Value: "me"}, {Key: "org", Value: "acme"}}
+	err := c.BindURI(&got)
+	require.ErrorContains(t, err, "path parameter id: invalid integer")
+	require.ErrorContains(t, err, "path parameter org")
+
+	require.Error(t, c.BindURI(got))
+}
+
+func TestContext_ShouldBind(t *testing.T) {
+	type search struct {
+		Query string `form:"q" json:"q" xml:"q"`
+		Page  int    `form:"page" json:"page" xml:"page"`
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        search
+		err         error
+	}{
+		{"query", http.MethodGet, "/?q=go&page=2", "", "", search{"go", 2}, nil},
+		{"query without body", http.MethodDelete, "/?q=go", "", "", search{Query: "go"}, nil},
+		{"json", http.MethodPost, "/?q=ignored", "application/json; charset=utf-8", `{"q":"go","page":3}`, search{"go", 3}, nil},
+		{"json suffix", http.MethodPost, "/", "application/merge-patch+json", `{"q":"go"}`, search{Query: "go"}, nil},
+		{"xml", http.MethodPut, "/", "application/xml", `<search><q>go</q><page>4</page></search>`, search{"go", 4}, nil},
+		{"form", http.MethodPost, "/", "application/x-www-form-urlencoded", "q=go&page=5", search{"go", 5}, nil},
+		{"unsupported", http.MethodPost, "/", "text/csv", "q,page", search{}, ErrUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.body == "" {
+				r.ContentLength = 0
+			}
+
+			var got search
+			err := (&Context{Request: r}).ShouldBind(&got)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestContext_MustBind(t *testing.T) {
+	var got struct {
+		Page int `form:"page"`
+	}
+
+	w := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/?page=two", nil), Writer: w}
+	require.False(t, c.MustBind(&got))
+	require.True(t, c.IsAborted())
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "query parameter page")
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a,b"))
+	r.Header.Set("Content-Type", "text/csv")
+	w = httptest.NewRecorder()
+	require.False(t, (&Context{Request: r, Writer: w}).MustBind(&got))
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	c = &Context{Request: httptest.NewRequest(http.MethodGet, "/?page=2", nil), Writer: httptest.NewRecorder()}
+	require.True(t, c.MustBind(&got))
+	require.Equal(t, 2, got.Page)
+}
+
+func TestContext_BindQueryInto(t *testing.T) {
+	type search struct {
+		Query string   `form:"q"`
+		Tags  []string `form:"tag"`
+		Page  int      `form:"page"`
+	}
+
+	var s search
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/?q=go&tag=a&tag=b&page=2", nil)}
+	require.NoError(t, c.BindQueryInto(&s))
+	require.Equal(t, search{Query: "go", Tags: []string{"a", "b"}, Page: 2}, s)
+
+	// The struct is reused without the values of the previous request
+	c = &Context{Request: httptest.NewRequest(http.MethodGet, "/?q=rust", nil)}
+	require.NoError(t, c.BindQueryInto(&s))
+	require.Equal(t, search{Query: "rust"}, s)
+}
+
+func TestBindCache(t *testing.T) {
+	type base struct {
+		ID int `form:"id"`
+	}
+	type item struct {
+		base
+		Name   string `form:"name"`
+		Hidden string `form:"-"`
+	}
+
+	cache := &BindCache{}
+	fields := cache.fields(reflect.TypeFor[item](), "form")
+	require.Equal(t, []boundField{
+		{index: []int{0, 0}, name: "id", kind: valueField},
+		{index: []int{1}, name: "name", kind: valueField},
+	}, fields)
+	cache.fields(reflect.TypeFor[item](), "form")
+	cache.fields(reflect.TypeFor[item](), "uri")
+	require.Equal(t, BindCacheStats{Hits: 1, Misses: 2, Types: 2}, cache.Stats())
+}
+
+func TestContext_BindJSON(t *testing.T) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	c := &Context{Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"ada"}`))}
+	require.NoError(t, c.BindJSON(&body))
+	require.Equal(t, "ada", body.Name)
+
+	c = &Context{Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(""))}
+	require.ErrorIs(t, c.BindJSON(&body), io.EOF)
+	c = &Context{Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"ada"} trailing`))}
+	require.Error(t, c.BindJSON(&body))
+}
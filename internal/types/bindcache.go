@@ -0,0 +1,128 @@
+package types
+
+import (
+	"bytes"
+	"encoding"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledBuffer is the capacity over which the buffers of request bodies
+// are not pooled
+const maxPooledBuffer = 64 << 10
+
+// bindBuffers pools the buffers of the request bodies bound by
+// Context.BindJSON
+var bindBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+var (
+	fileHeaderType  = reflect.TypeFor[*multipart.FileHeader]()
+	fileHeadersType = reflect.TypeFor[[]*multipart.FileHeader]()
+	textType        = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// DefaultBindCache caches the fields bound by Context.BindQuery,
+// Context.BindForm and Context.BindURI, so that the structs of hot
+// endpoints are only reflected on once
+var DefaultBindCache = &BindCache{}
+
+// BindCache caches the bound fields of struct types, per struct tag
+type BindCache struct {
+	types  sync.Map // bindKey -> []boundField
+	count  atomic.Int64
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// BindCacheStats are the statistics of a BindCache
+type BindCacheStats struct {
+	Hits   uint64 // lookups of cached types
+	Misses uint64 // lookups reflecting on a type
+	Types  int    // cached types
+}
+
+// Stats returns the statistics of the cache
+func (c *BindCache) Stats() BindCacheStats {
+	return BindCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Types: int(c.count.Load())}
+}
+
+// bindKey is the key of the fields of a struct type bound by a tag
+type bindKey struct {
+	typ reflect.Type
+	tag string
+}
+
+// fieldKind is how a field is bound
+type fieldKind int
+
+const (
+	valueField fieldKind = iota // the first value, parsed
+	sliceField                  // every value, parsed
+	fileField                   // the first file
+	filesField                  // every file
+)
+
+// boundField is a field bound by name
+type boundField struct {
+	index []int // see reflect.Value.FieldByIndex
+	name  string
+	kind  fieldKind
+}
+
+// fields returns the bound fields of a struct type, reflecting on it on
+// first use
+func (c *BindCache) fields(t reflect.Type, tag string) []boundField {
+	key := bindKey{typ: t, tag: tag}
+	if fields, ok := c.types.Load(key); ok {
+		c.hits.Add(1)
+		return fields.([]boundField)
+	}
+	c.misses.Add(1)
+	fields, loaded := c.types.LoadOrStore(key, structFields(t, tag, nil))
+	if !loaded {
+		c.count.Add(1)
+	}
+	return fields.([]boundField)
+}
+
+// structFields returns the bound fields of a struct type, flattening
+// embedded structs
+func structFields(t reflect.Type, tag string, index []int) []boundField {
+	var fields []boundField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs are flattened even when unexported, as their
+		// exported fields are promoted
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && name == "" {
+			fields = append(fields, structFields(field.Type, tag, fieldIndex)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		kind := valueField
+		switch {
+		case field.Type == fileHeaderType:
+			kind = fileField
+		case field.Type == fileHeadersType:
+			kind = filesField
+		case field.Type.Kind() == reflect.Slice && !reflect.PointerTo(field.Type).Implements(textType):
+			kind = sliceField
+		}
+		fields = append(fields, boundField{index: fieldIndex, name: name, kind: kind})
+	}
+	return fields
+}
@@ -0,0 +1,96 @@
+package types
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// BufferedWriter holds the status and the body of a response until it is
+// sent, so that middleware can process whole responses, e.g. to digest,
+// compress or encrypt them
+//
+// Informational responses are sent right away. Once sent, e.g. because
+// the handler flushed, the rest of the response is written through.
+type BufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	sent   bool
+}
+
+// NewBufferedWriter wraps w
+func NewBufferedWriter(w http.ResponseWriter) *BufferedWriter {
+	return &BufferedWriter{ResponseWriter: w}
+}
+
+// WriteHeader implements http.ResponseWriter, holding the status
+func (w *BufferedWriter) WriteHeader(status int) {
+	if w.sent || status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write implements http.ResponseWriter, holding the body
+func (w *BufferedWriter) Write(b []byte) (int, error) {
+	if w.sent {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// Flush implements http.Flusher, sending the response held so far
+func (w *BufferedWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.Send()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *BufferedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status held, 0 if nothing was written
+func (w *BufferedWriter) Status() int {
+	return w.status
+}
+
+// Body returns the body held, which may be replaced before Send
+func (w *BufferedWriter) Body() *bytes.Buffer {
+	return &w.body
+}
+
+// Sent reports whether the response was sent
+func (w *BufferedWriter) Sent() bool {
+	return w.sent
+}
+
+// Send sends the status and the body held, unless nothing was written,
+// and writes the rest of the response through
+//
+// @return: an error if the body could not be written
+func (w *BufferedWriter) Send() error {
+	if w.sent {
+		return nil
+	}
+	w.sent = true
+	if w.status == 0 {
+		return nil
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	w.body.Reset()
+	return err
+}
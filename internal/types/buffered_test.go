@@ -0,0 +1,48 @@
+package types
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	buffered := NewBufferedWriter(w)
+
+	// The response is held until sent
+	buffered.WriteHeader(http.StatusCreated)
+	buffered.WriteHeader(http.StatusOK)
+	buffered.Write([]byte("held"))
+	require.False(t, w.Flushed)
+	require.Empty(t, w.Body.String())
+	require.Equal(t, http.StatusCreated, buffered.Status())
+
+	buffered.Body().Reset()
+	buffered.Body().WriteString("replaced")
+	require.NoError(t, buffered.Send())
+	require.True(t, buffered.Sent())
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "replaced", w.Body.String())
+
+	// and written through afterwards
+	buffered.Write([]byte(" more"))
+	require.Equal(t, "replaced more", w.Body.String())
+
+	// Flushing sends the response held so far
+	w = httptest.NewRecorder()
+	buffered = NewBufferedWriter(w)
+	buffered.Write([]byte("part"))
+	http.NewResponseController(buffered).Flush()
+	require.True(t, w.Flushed)
+	require.Equal(t, "part", w.Body.String())
+
+	// Nothing is sent if nothing was written
+	w = httptest.NewRecorder()
+	buffered = NewBufferedWriter(w)
+	require.NoError(t, buffered.Send())
+	require.False(t, w.Flushed)
+	require.Zero(t, buffered.Status())
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheFor lets clients and shared caches reuse the response for the
+// duration, "Cache-Control: public, max-age=N", or sets NoCache if it is
+// not positive
+func (c *Context) CacheFor(d time.Duration) {
+	if d <= 0 {
+		c.NoCache()
+		return
+	}
+	c.Writer.Header().Set("Cache-Control", "public, max-age="+strconv.FormatInt(int64(d/time.Second), 10))
+}
+
+// CachePrivately lets the client, but not shared caches, reuse the
+// response for the duration, e.g. for responses depending on the user
+func (c *Context) CachePrivately(d time.Duration) {
+	c.Writer.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(int64(max(0, d/time.Second)), 10))
+}
+
+// NoCache makes caches revalidate the response before reusing it,
+// "Cache-Control: no-cache", e.g. with Context.LastModified
+func (c *Context) NoCache() {
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+}
+
+// NoStore keeps the response out of every cache, "Cache-Control: no-store",
+// e.g. for sensitive data
+func (c *Context) NoStore() {
+	c.Writer.Header().Set("Cache-Control", "no-store")
+}
+
+// LastModified sets the Last-Modified header, and answers 304 Not Modified
+// when the If-Modified-Since header of a GET or HEAD request shows that the
+// client has the response already
+//
+//	if c.LastModified(article.UpdatedAt) {
+//		return
+//	}
+//
+// @return: true if the response was sent
+func (c *Context) LastModified(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	t = t.UTC().Truncate(time.Second)
+	c.Writer.Header().Set("Last-Modified", t.Format(http.TimeFormat))
+
+	method := c.Request.Method
+	if (method != http.MethodGet && method != http.MethodHead) || c.Request.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(c.Request.Header.Get("If-Modified-Since"))
+	if err != nil || t.After(since) {
+		return false
+	}
+	c.Writer.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// Vary adds the request headers to the Vary header, e.g. Accept-Language
+// for localized responses, once each
+func (c *Context) Vary(headers ...string) {
+	header := c.Writer.Header()
+	for _, name := range headers {
+		if !varies(header, name) {
+			header.Add("Vary", http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// varies reports whether the Vary header lists the name or *
+func varies(header http.Header, name string) bool {
+	for _, value := range header.Values("Vary") {
+		for listed := range strings.SplitSeq(value, ",") {
+			listed = strings.TrimSpace(listed)
+			if listed == "*" || strings.EqualFold(listed, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_CacheControl(t *testing.T) {
+	run := func(set func(c *Context)) string {
+		w := httptest.NewRecorder()
+		set(&Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w})
+		return w.Header().Get("Cache-Control")
+	}
+
+	require.Equal(t, "public, max-age=3600", run(func(c *Context) { c.CacheFor(time.Hour) }))
+	require.Equal(t, "no-cache", run(func(c *Context) { c.CacheFor(0) }))
+	require.Equal(t, "private, max-age=90", run(func(c *Context) { c.CachePrivately(90 * time.Second) }))
+	require.Equal(t, "no-cache", run(func(c *Context) { c.NoCache() }))
+	require.Equal(t, "no-store", run(func(c *Context) { c.NoStore() }))
+}
+
+func TestContext_LastModified(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	run := func(method string, header ...string) (*httptest.ResponseRecorder, bool) {
+		r := httptest.NewRequest(method, "/", nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		return w, (&Context{Request: r, Writer: w}).LastModified(modified)
+	}
+
+	w, sent := run(http.MethodGet)
+	require.False(t, sent)
+	require.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", w.Header().Get("Last-Modified"))
+
+	w, sent = run(http.MethodGet, "If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT")
+	require.True(t, sent)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	_, sent = run(http.MethodGet, "If-Modified-Since", "Wed, 01 May 2024 11:59:59 GMT")
+	require.False(t, sent)
+	_, sent = run(http.MethodPost, "If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT")
+	require.False(t, sent)
+
+	// If-None-Match takes precedence, see RFC 9110
+	_, sent = run(http.MethodGet, "If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT", "If-None-Match", `"v1"`)
+	require.False(t, sent)
+}
+
+func TestContext_Vary(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Accept-Encoding")
+	c := &Context{Writer: w}
+
+	c.Vary("accept-language", "Accept-Encoding")
+	c.Vary("Accept-Language", "Cookie")
+	require.Equal(t, []string{"Accept-Encoding", "Accept-Language", "Cookie"}, w.Header().Values("Vary"))
+}
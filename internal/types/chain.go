@@ -0,0 +1,129 @@
+package types
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"slices"
+)
+
+// Chain composes middlewares around a handler into the ordered list of
+// handlers run by Context.Next
+//
+// Each middleware receives a next handler that continues the chain. A
+// middleware that returns without calling next stops the chain, exactly as
+// with plain function composition.
+func Chain(middlewares []MiddlewareFunc, handler HandlerFunc) []HandlerFunc {
+	handlers := make([]HandlerFunc, 0, len(middlewares)+1)
+	for _, middleware := range middlewares {
+		handlers = append(handlers, adaptMiddleware(middleware))
+	}
+	return append(handlers, handler)
+}
+
+// adaptMiddleware turns a wrapping middleware into a chain handler
+func adaptMiddleware(middleware MiddlewareFunc) HandlerFunc {
+	return func(c *Context) {
+		middleware(func(c *Context) {
+			c.Next()
+		})(c)
+
+		// The rest of the chain ran through next, or is skipped without
+		// marking it aborted if next was not called, or called with a copy
+		// of the context
+		c.index = len(c.handlers)
+	}
+}
+
+// Execute runs the handler chain from the start
+func (c *Context) Execute(handlers []HandlerFunc) {
+	c.handlers = handlers
+	c.index = -1
+	c.aborted = false
+	c.Next()
+}
+
+// Next runs the remaining handlers in the chain
+//
+// It should only be called from within a handler of the chain, and returns
+// once every downstream handler has run or the chain was aborted.
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) && !c.aborted {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Fork returns a copy of the context continuing the chain after the
+// current handler, with its own request and writer
+//
+// Calling Next on the copy runs the remaining handlers again, e.g. to
+// refresh a cached response in the background once the request ended. The
+// copy must not share anything tied to the original request.
+func (c *Context) Fork(w http.ResponseWriter, r *http.Request) *Context {
+	return &Context{
+		Context:        c.Context,
+		Request:        r,
+		Writer:         w,
+		Params:         slices.Clone(c.Params),
+		Route:          c.Route,
+		ClientParser:   c.ClientParser,
+		IDGenerator:    c.IDGenerator,
+		TrustedProxies: c.TrustedProxies,
+		Log:            c.Log,
+		logAttrs:       slices.Clip(c.logAttrs),
+
+		MaxMultipartMemory: c.MaxMultipartMemory,
+		Templates:          c.Templates,
+		Debug:              c.Debug,
+		Development:        c.Development,
+
+		handlers: c.handlers,
+		index:    c.index,
+	}
+}
+
+// Go runs a task in the background, e.g. sending an email once the
+// response is sent
+//
+// The task gets the values of the request context, e.g. its baggage and
+// locale, without its cancellation, and the logger of the request with
+// its attributes, see Context.Logger. Panics of the task are logged.
+func (c *Context) Go(task func(ctx context.Context, logger Logger)) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	logger := c.Logger()
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Error("Background task panicked", "panic", err, "stack", string(debug.Stack()))
+			}
+		}()
+		task(ctx, logger)
+	}()
+}
+
+// Abort prevents the remaining handlers in the chain from running
+//
+// It does not stop the current handler, which should return after
+// calling Abort.
+func (c *Context) Abort() {
+	c.aborted = true
+}
+
+// AbortWithStatus aborts the chain and writes the status code
+func (c *Context) AbortWithStatus(status int) {
+	c.Abort()
+	c.Status(status)
+}
+
+// AbortWithError aborts the chain and sends an error response
+func (c *Context) AbortWithError(status int, err error) {
+	c.Abort()
+	c.Error(status, err)
+}
+
+// IsAborted reports whether the chain was aborted
+func (c *Context) IsAborted() bool {
+	return c.aborted
+}
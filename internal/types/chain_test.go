@@ -0,0 +1,193 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skjdfhkskjds/go-api/internal/baggage"
+)
+
+func newTestContext() (*Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	return &Context{
+		Request: httptest.NewRequest(http.MethodGet, "/", nil),
+		Writer:  w,
+	}, w
+}
+
+// recordMiddleware appends its name before and after calling next
+func recordMiddleware(name string, calls *[]string) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			*calls = append(*calls, name+":before")
+			next(c)
+			*calls = append(*calls, name+":after")
+		}
+	}
+}
+
+func TestChain_Order(t *testing.T) {
+	var calls []string
+	handler := func(c *Context) { calls = append(calls, "handler") }
+
+	c, _ := newTestContext()
+	c.Execute(Chain([]MiddlewareFunc{
+		recordMiddleware("outer", &calls),
+		recordMiddleware("inner", &calls),
+	}, handler))
+
+	require.Equal(t, []string{
+		"outer:before",
+		"inner:before",
+		"handler",
+		"inner:after",
+		"outer:after",
+	}, calls)
+	require.False(t, c.IsAborted())
+}
+
+func TestChain_AbortWithStatus(t *testing.T) {
+	var calls []string
+	auth := func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			next(c)
+		}
+	}
+	handler := func(c *Context) { calls = append(calls, "handler") }
+
+	c, w := newTestContext()
+	c.Execute(Chain([]MiddlewareFunc{
+		recordMiddleware("outer", &calls),
+		auth,
+		recordMiddleware("inner", &calls),
+	}, handler))
+
+	require.Equal(t, []string{"outer:before", "outer:after"}, calls)
+	require.True(t, c.IsAborted())
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestChain_AbortWithError(t *testing.T) {
+	limit := func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			c.AbortWithError(http.StatusTooManyRequests, errors.New("slow down"))
+		}
+	}
+	called := false
+	handler := func(c *Context) { called = true }
+
+	c, w := newTestContext()
+	c.Execute(Chain([]MiddlewareFunc{limit}, handler))
+
+	require.False(t, called)
+	require.True(t, c.IsAborted())
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Contains(t, w.Body.String(), "slow down")
+}
+
+func TestChain_MiddlewareWithoutNext(t *testing.T) {
+	var calls []string
+	shortCircuit := func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			calls = append(calls, "cached")
+		}
+	}
+	handler := func(c *Context) { calls = append(calls, "handler") }
+
+	c, _ := newTestContext()
+	c.Execute(Chain([]MiddlewareFunc{
+		shortCircuit,
+		recordMiddleware("inner", &calls),
+	}, handler))
+
+	require.Equal(t, []string{"cached"}, calls)
+	require.False(t, c.IsAborted())
+}
+
+func TestChain_Next(t *testing.T) {
+	var calls []string
+	c, _ := newTestContext()
+	c.Execute([]HandlerFunc{
+		func(c *Context) {
+			calls = append(calls, "first:before")
+			c.Next()
+			calls = append(calls, "first:after")
+		},
+		func(c *Context) { calls = append(calls, "second") },
+		func(c *Context) { calls = append(calls, "third") },
+	})
+
+	require.Equal(t, []string{"first:before", "second", "third", "first:after"}, calls)
+}
+
+func TestChain_Fork(t *testing.T) {
+	var calls []string
+	var fork *Context
+	c, _ := newTestContext()
+	c.MaxMultipartMemory = 1 << 10
+	c.Templates = templateLoader{}
+	c.Debug, c.Development = true, true
+	c.Execute(Chain([]MiddlewareFunc{
+		func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) {
+				fork = c.Fork(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fork", nil))
+				next(c)
+			}
+		},
+		recordMiddleware("inner", &calls),
+	}, func(c *Context) { calls = append(calls, "handler "+c.Request.URL.Path) }))
+	require.Equal(t, []string{"inner:before", "handler /", "inner:after"}, calls)
+
+	// The fork keeps the settings of the engine
+	require.Equal(t, int64(1<<10), fork.MaxMultipartMemory)
+	require.Equal(t, templateLoader{}, fork.Templates)
+	require.True(t, fork.Debug)
+	require.True(t, fork.Development)
+
+	// The fork runs the handlers after the forking middleware again
+	calls = nil
+	fork.Next()
+	require.Equal(t, []string{"inner:before", "handler /fork", "inner:after"}, calls)
+}
+
+// lineWriter sends the lines written to it, e.g. log entries of other
+// goroutines
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestContext_Go(t *testing.T) {
+	logs := make(lineWriter, 1)
+	c, _ := newTestContext()
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	c.Log = slog.New(slog.NewTextHandler(logs, nil))
+	c.AddLogAttrs("request_id", "r1")
+	b, err := baggage.Parse("tenant=acme")
+	require.NoError(t, err)
+	c.SetBaggage(b)
+
+	// The task outlives the request, with its values and logger
+	c.Go(func(ctx context.Context, logger Logger) {
+		<-c.Request.Context().Done()
+		b, _ := baggage.FromContext(ctx)
+		tenant, _ := b.Get("tenant")
+		logger.Info("sent", "tenant", tenant, "error", ctx.Err())
+	})
+	cancel()
+	require.Contains(t, <-logs, `msg=sent method=GET path=/ request_id=r1 tenant=acme error=<nil>`)
+
+	// Panics are logged
+	c.Go(func(ctx context.Context, logger Logger) { panic("boom") })
+	require.Contains(t, <-logs, `msg="Background task panicked" method=GET path=/ request_id=r1 panic=boom`)
+}
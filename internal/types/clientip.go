@@ -0,0 +1,153 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Headers of the client IP set by reverse proxies, see TrustedProxies
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+	HeaderForwarded     = "Forwarded" // RFC 7239
+)
+
+// TrustedProxies resolves the IP of the clients of requests coming through
+// reverse proxies, trusting the forwarding headers only when set by the
+// proxies
+type TrustedProxies struct {
+	// Prefixes of the proxies
+	Prefixes []netip.Prefix
+
+	// Header carrying the client IP: HeaderXForwardedFor, HeaderXRealIP or
+	// HeaderForwarded
+	Header string
+}
+
+// ParseTrustedProxies parses the IPs or CIDR prefixes of the proxies and
+// the header carrying the client IP, X-Forwarded-For if empty
+//
+// @return: an error if an entry is neither an IP nor a CIDR prefix, or the
+// header is not supported
+func ParseTrustedProxies(proxies []string, header string) (*TrustedProxies, error) {
+	header = http.CanonicalHeaderKey(header)
+	switch header {
+	case "":
+		header = HeaderXForwardedFor
+	case HeaderXForwardedFor, HeaderForwarded:
+	case http.CanonicalHeaderKey(HeaderXRealIP):
+		header = HeaderXRealIP
+	default:
+		return nil, fmt.Errorf("unsupported client IP header: %s", header)
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, value := range proxies {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return &TrustedProxies{Prefixes: prefixes, Header: header}, nil
+}
+
+// ClientIP returns the IP of the client of a request
+//
+// The forwarding header is only read when the connection comes from a
+// trusted proxy. X-Forwarded-For and Forwarded chains are read from the
+// right, the client being the first hop not trusted, so that hops
+// prepended by the client itself are ignored. Without trusted proxies, the
+// IP of the connection's remote address is returned.
+func (p *TrustedProxies) ClientIP(r *http.Request) string {
+	remote, err := parseHop(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	if p == nil || !p.trusted(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	switch p.Header {
+	case HeaderXRealIP:
+		if addr, err := parseHop(strings.TrimSpace(r.Header.Get(HeaderXRealIP))); err == nil {
+			return addr.String()
+		}
+		return remote.String()
+	case HeaderForwarded:
+		hops = forwardedFor(r.Header.Values(HeaderForwarded))
+	default:
+		for _, value := range r.Header.Values(HeaderXForwardedFor) {
+			for hop := range strings.SplitSeq(value, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := parseHop(hops[i])
+		if err != nil {
+			// e.g. "unknown" or obfuscated identifiers, nothing further
+			// left can be trusted
+			break
+		}
+		client = addr
+		if !p.trusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// trusted reports whether an address is one of the proxies
+func (p *TrustedProxies) trusted(addr netip.Addr) bool {
+	for _, prefix := range p.Prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for= parameters of Forwarded headers, in order
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for element := range strings.SplitSeq(value, ",") {
+			for pair := range strings.SplitSeq(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hops = append(hops, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop parses an IP, optionally with a port, e.g. "192.0.2.1",
+// "192.0.2.1:4711", "2001:db8::1" or "[2001:db8::1]:4711"
+func parseHop(value string) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap(), nil
+	}
+	host, _, err := net.SplitHostPort(value)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"}, "")
+	require.NoError(t, err)
+	realIP, err := ParseTrustedProxies([]string{"10.0.0.1"}, "x-real-ip")
+	require.NoError(t, err)
+	forwarded, err := ParseTrustedProxies([]string{"10.0.0.0/8"}, "forwarded")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		proxies    *TrustedProxies
+		remoteAddr string
+		header     string
+		value      string
+		want       string
+	}{
+		{"no proxies", nil, "192.0.2.1:1234", "X-Forwarded-For", "203.0.113.9", "192.0.2.1"},
+		{"untrusted peer", proxies, "192.0.2.1:1234", "X-Forwarded-For", "203.0.113.9", "192.0.2.1"},
+		{"trusted peer", proxies, "10.0.0.1:1234", "X-Forwarded-For", "203.0.113.9", "203.0.113.9"},
+		{"spoofed hops", proxies, "10.0.0.1:1234", "X-Forwarded-For", "1.1.1.1, 203.0.113.9, 10.0.0.2", "203.0.113.9"},
+		{"only proxies", proxies, "10.0.0.1:1234", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"invalid hop", proxies, "10.0.0.1:1234", "X-Forwarded-For", "bogus, 10.0.0.2", "10.0.0.2"},
+		{"no header", proxies, "10.0.0.1:1234", "", "", "10.0.0.1"},
+		{"ipv6 peer", proxies, "[2001:db8::1]:1234", "X-Forwarded-For", "203.0.113.9", "203.0.113.9"},
+		{"x-real-ip", realIP, "10.0.0.1:1234", "X-Real-IP", "203.0.113.9", "203.0.113.9"},
+		{"x-real-ip ignores xff", realIP, "10.0.0.1:1234", "X-Forwarded-For", "203.0.113.9", "10.0.0.1"},
+		{"forwarded", forwarded, "10.0.0.1:1234", "Forwarded", `for=1.1.1.1, for="[2001:db8::9]:4711";proto=https, for=10.0.0.2`, "2001:db8::9"},
+		{"forwarded unknown", forwarded, "10.0.0.1:1234", "Forwarded", "for=unknown, for=10.0.0.2", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			c := &Context{Request: r, TrustedProxies: tt.proxies}
+			require.Equal(t, tt.want, c.GetClientIP())
+		})
+	}
+}
+
+func TestParseTrustedProxies_Invalid(t *testing.T) {
+	_, err := ParseTrustedProxies([]string{"not-an-ip"}, "")
+	require.Error(t, err)
+	_, err = ParseTrustedProxies(nil, "X-Client-IP")
+	require.Error(t, err)
+}
@@ -1,20 +1,84 @@
 package types
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
 	"net/http"
+	"regexp"
 	"strconv"
+
+	"github.com/skjdfhkskjds/go-api/internal/baggage"
+	"github.com/skjdfhkskjds/go-api/internal/i18n"
+	"github.com/skjdfhkskjds/go-api/internal/idgen"
+	"github.com/skjdfhkskjds/go-api/internal/session"
+	"github.com/skjdfhkskjds/go-api/internal/useragent"
 )
 
 // Context provides request context and response utilities
 type Context struct {
 	context.Context
 
-	Request    *http.Request
-	Writer     http.ResponseWriter
-	PathParams map[string]string
+	Request *http.Request
+	Writer  http.ResponseWriter
+	Params  Params
+
+	// Route is the path of the matched route as registered, e.g.
+	// /users/:id, empty if no route matched
+	Route string
+
+	// Parser used by Context.Client, useragent.BasicParser if nil
+	ClientParser useragent.Parser
+	client       *useragent.Client
+
+	// Generator of Context.NewID, idgen.Default if nil
+	IDGenerator idgen.Generator
+
+	// Proxies whose forwarding headers are trusted by Context.GetClientIP,
+	// none if nil
+	TrustedProxies *TrustedProxies
+
+	// Logger of the engine, extended by Context.Logger with the attributes
+	// of the request, slog.Default if nil
+	Log Logger
+
+	// Memory used to parse multipart forms, the rest of the files being
+	// stored in temporary files, DefaultMaxMultipartMemory if 0
+	MaxMultipartMemory int64
+
+	// Templates rendered by Context.HTMLTemplate, may be nil
+	Templates TemplateLoader
+
+	// Debug is set in the debug mode of the engine, e.g. to show detailed
+	// error pages
+	Debug bool
+
+	// Development is set in the debug and test modes of the engine, i.e.
+	// outside production, e.g. to enable testing aids. It is unset in the
+	// contexts not created by the engine.
+	Development bool
+
+	// Session of the request, see Context.Session
+	session *session.Session
+
+	// Logger of the request and its attributes, see Context.Logger
+	logger   Logger
+	logAttrs []any
+
+	// Map of Params, built on first use by PathParams
+	pathParams map[string]string
+
+	// Values of the request, see Context.Set
+	locals map[string]any
+
+	// Handler chain state, see Context.Next
+	handlers []HandlerFunc
+	index    int
+	aborted  bool
 }
 
 // JSON sends a JSON response
@@ -27,6 +91,62 @@ func (c *Context) JSON(status int, data any) {
 	}
 }
 
+// IndentedJSON sends a JSON response indented for humans, e.g. for
+// debugging clients
+func (c *Context) IndentedJSON(status int, data any) {
+	c.encodeJSON(status, data, "  ", true)
+}
+
+// PureJSON sends a JSON response without escaping <, > and & in strings,
+// unlike Context.JSON
+func (c *Context) PureJSON(status int, data any) {
+	c.encodeJSON(status, data, "", false)
+}
+
+// JSONP sends a JSON response wrapped in a call to the callback, for
+// clients loading it with a script tag, or a JSON response without
+// callback
+//
+// Callbacks must be JavaScript identifiers, optionally dotted, other
+// callbacks are answered with 400 Bad Request.
+func (c *Context) JSONP(status int, callback string, data any) {
+	if callback == "" {
+		c.JSON(status, data)
+		return
+	}
+	if !jsonpCallback.MatchString(callback) {
+		c.ErrorString(http.StatusBadRequest, "invalid callback")
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		c.Error(http.StatusInternalServerError, err)
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "application/javascript")
+	c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+	c.Writer.WriteHeader(status)
+	// The comment prevents the Rosetta Flash attack on the callback name
+	fmt.Fprintf(c.Writer, "/**/ typeof %s === 'function' && %s(%s);", callback, callback, payload)
+}
+
+// jsonpCallback matches the callbacks accepted by Context.JSONP
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)*$`)
+
+// encodeJSON sends a JSON response with the encoder options
+func (c *Context) encodeJSON(status int, data any, indent string, escapeHTML bool) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", indent)
+	encoder.SetEscapeHTML(escapeHTML)
+	if err := encoder.Encode(data); err != nil {
+		c.Error(http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(status, "application/json", buf.Bytes())
+}
+
 // String sends a string response
 func (c *Context) String(status int, data string) {
 	c.Writer.Header().Set("Content-Type", "text/plain")
@@ -41,6 +161,70 @@ func (c *Context) HTML(status int, html string) {
 	c.Writer.Write([]byte(html))
 }
 
+// Render executes the named template and sends the result as HTML
+//
+// The i18n formatting functions (number, currency, date, datetime) are
+// bound to the request locale, see Context.Locale, by executing the clone
+// of the template for the locale, see i18n.Localize. The template must
+// have been parsed with i18n.FuncMap so that the functions are defined,
+// and must not be executed otherwise.
+func (c *Context) Render(status int, tmpl *template.Template, name string, data any) error {
+	localized, err := i18n.Localize(tmpl, c.Locale())
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := localized.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	c.Data(status, "text/html", buf.Bytes())
+	return nil
+}
+
+// TemplateLoader provides the templates rendered by Context.HTMLTemplate
+type TemplateLoader interface {
+	Template() (*template.Template, error)
+}
+
+// HTMLTemplate renders the named template of Context.Templates as HTML,
+// see Context.Render
+//
+// Templates that cannot be loaded or executed are answered with 500
+// Internal Server Error, along with the error in debug mode.
+func (c *Context) HTMLTemplate(status int, name string, data any) {
+	err := errors.New("no templates loaded")
+	if c.Templates != nil {
+		var tmpl *template.Template
+		if tmpl, err = c.Templates.Template(); err == nil {
+			err = c.Render(status, tmpl, name, data)
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	c.Logger().Error("template failed", "template", name, "error", err)
+	if !c.Debug {
+		c.ErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	var page bytes.Buffer
+	templateErrorPage.Execute(&page, map[string]string{"Name": name, "Error": err.Error()})
+	c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", page.Bytes())
+}
+
+// templateErrorPage shows template errors in debug mode
+var templateErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Template error</title></head>
+<body>
+<h1>Template error in {{.Name}}</h1>
+<pre>{{.Error}}</pre>
+</body>
+</html>
+`))
+
 // Data sends raw data response
 func (c *Context) Data(status int, contentType string, data []byte) {
 	c.Writer.Header().Set("Content-Type", contentType)
@@ -58,6 +242,24 @@ func (c *Context) Header(key, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// DeclareTrailer announces the trailers of the response in the Trailer
+// header, it must be called before the body is written
+func (c *Context) DeclareTrailer(keys ...string) {
+	for _, key := range keys {
+		c.Writer.Header().Add("Trailer", http.CanonicalHeaderKey(key))
+	}
+}
+
+// Trailer sets a trailer, sent after the body, e.g. the checksum or the
+// final status of a streamed export
+//
+// It may be called while and after the body is written, until the handler
+// returns. Trailers are only sent with chunked responses, so not once a
+// Content-Length was set.
+func (c *Context) Trailer(key, value string) {
+	c.Writer.Header().Set(http.TrailerPrefix+key, value)
+}
+
 // GetHeader gets a request header
 func (c *Context) GetHeader(key string) string {
 	return c.Request.Header.Get(key)
@@ -65,12 +267,28 @@ func (c *Context) GetHeader(key string) string {
 
 // GetParam gets a path parameter by name
 func (c *Context) GetParam(name string) string {
-	return c.PathParams[name]
+	value, _ := c.Params.Get(name)
+	return value
+}
+
+// Param gets a path parameter by name, scanning the ordered Params without
+// allocating, and reports whether the route has the parameter, e.g. to
+// tell an empty wildcard from a missing one
+func (c *Context) Param(name string) (string, bool) {
+	return c.Params.Get(name)
+}
+
+// PathParams returns the path parameters as a map, built on first use
+func (c *Context) PathParams() map[string]string {
+	if c.pathParams == nil {
+		c.pathParams = c.Params.Map()
+	}
+	return c.pathParams
 }
 
 // GetParamInt gets a path parameter as integer
 func (c *Context) GetParamInt(name string) (int, error) {
-	param := c.PathParams[name]
+	param := c.GetParam(name)
 	if param == "" {
 		return 0, fmt.Errorf("parameter %s not found", name)
 	}
@@ -79,7 +297,7 @@ func (c *Context) GetParamInt(name string) (int, error) {
 
 // GetParamInt64 gets a path parameter as int64
 func (c *Context) GetParamInt64(name string) (int64, error) {
-	param := c.PathParams[name]
+	param := c.GetParam(name)
 	if param == "" {
 		return 0, fmt.Errorf("parameter %s not found", name)
 	}
@@ -146,8 +364,27 @@ func (c *Context) SetCookie(name, value string, maxAge int, path, domain string,
 }
 
 // BindJSON binds JSON request body to a struct
+//
+// The body is read into a pooled buffer, and data after the JSON value is
+// rejected.
+//
+// @return: io.EOF for empty bodies, or the error of the decoding
 func (c *Context) BindJSON(obj any) error {
-	return json.NewDecoder(c.Request.Body).Decode(obj)
+	buf := bindBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		// Oversized buffers are left to the garbage collector
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			bindBuffers.Put(buf)
+		}
+	}()
+	if _, err := buf.ReadFrom(c.Request.Body); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		return io.EOF
+	}
+	return json.Unmarshal(buf.Bytes(), obj)
 }
 
 // GetUserAgent gets the User-Agent header
@@ -155,20 +392,71 @@ func (c *Context) GetUserAgent() string {
 	return c.Request.Header.Get("User-Agent")
 }
 
-// GetClientIP gets the client IP address
-func (c *Context) GetClientIP() string {
-	// Check for X-Forwarded-For header first
-	if xff := c.Request.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
+// Locale returns the locale negotiated for the request, see
+// middleware.Locale, or i18n.DefaultLocale
+func (c *Context) Locale() i18n.Locale {
+	return i18n.FromContext(c.Request.Context())
+}
+
+// Baggage returns the W3C baggage of the request, parsed from the Baggage
+// header on first use
+//
+// Malformed headers yield an empty baggage.
+func (c *Context) Baggage() baggage.Baggage {
+	if b, ok := baggage.FromContext(c.Request.Context()); ok {
+		return b
 	}
 
-	// Check for X-Real-IP header
-	if xri := c.Request.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+	b, _ := baggage.Parse(c.Request.Header.Get(baggage.Header))
+	c.SetBaggage(b)
+	return b
+}
+
+// SetBaggage replaces the baggage of the request, which is propagated by
+// outbound requests made with the request context, see baggage.Transport
+func (c *Context) SetBaggage(b baggage.Baggage) {
+	c.Request = c.Request.WithContext(baggage.NewContext(c.Request.Context(), b))
+}
+
+// Client returns the client described by the User-Agent and client hint
+// headers, parsed on first use
+func (c *Context) Client() useragent.Client {
+	if c.client == nil {
+		parser := c.ClientParser
+		if parser == nil {
+			parser = useragent.BasicParser{}
+		}
+		client := parser.Parse(c.Request.Header)
+		c.client = &client
 	}
+	return *c.client
+}
 
-	// Fall back to RemoteAddr
-	return c.Request.RemoteAddr
+// NewID returns a new unique id, e.g. for a resource created by the
+// request, in the format configured on the engine
+func (c *Context) NewID() string {
+	if c.IDGenerator == nil {
+		return idgen.Default.NewID()
+	}
+	return c.IDGenerator.NewID()
+}
+
+// Session returns the session of the request, loaded by the
+// middleware.Sessions middleware, nil without it
+func (c *Context) Session() *session.Session {
+	return c.session
+}
+
+// SetSession sets the session of the request, for the middleware loading
+// them
+func (c *Context) SetSession(s *session.Session) {
+	c.session = s
+}
+
+// GetClientIP gets the client IP address, read from the forwarding
+// headers of trusted proxies only, see TrustedProxies.ClientIP
+func (c *Context) GetClientIP() string {
+	return c.TrustedProxies.ClientIP(c.Request)
 }
 
 // IsAjax checks if the request is an AJAX request
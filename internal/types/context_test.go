@@ -0,0 +1,205 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/baggage"
+	"github.com/skjdfhkskjds/go-api/internal/idgen"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_Baggage(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(baggage.Header, "tenant=acme")
+	c := &Context{Request: r, Writer: httptest.NewRecorder()}
+
+	tenant, _ := c.Baggage().Get("tenant")
+	require.Equal(t, "acme", tenant)
+
+	b, err := c.Baggage().Set("user", "42")
+	require.NoError(t, err)
+	c.SetBaggage(b)
+
+	propagated, ok := baggage.FromContext(c.Request.Context())
+	require.True(t, ok)
+	require.Equal(t, "tenant=acme,user=42", propagated.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(baggage.Header, "malformed")
+	c = &Context{Request: r}
+	require.Zero(t, c.Baggage().Len())
+}
+
+func TestContext_JSONVariants(t *testing.T) {
+	data := map[string]any{"html": "<b>&</b>", "n": 1}
+	run := func(render func(c *Context)) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		render(&Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w})
+		return w
+	}
+
+	w := run(func(c *Context) { c.JSON(http.StatusOK, data) })
+	require.Equal(t, `{"html":"\u003cb\u003e\u0026\u003c/b\u003e","n":1}`+"\n", w.Body.String())
+
+	w = run(func(c *Context) { c.PureJSON(http.StatusCreated, data) })
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.Equal(t, `{"html":"<b>&</b>","n":1}`+"\n", w.Body.String())
+
+	w = run(func(c *Context) { c.IndentedJSON(http.StatusOK, data) })
+	require.Equal(t, "{\n  \"html\": \"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\",\n  \"n\": 1\n}\n", w.Body.String())
+
+	w = run(func(c *Context) { c.IndentedJSON(http.StatusOK, func() {}) })
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = run(func(c *Context) { c.JSONP(http.StatusOK, "app.onData", map[string]int{"n": 1}) })
+	require.Equal(t, "application/javascript", w.Header().Get("Content-Type"))
+	require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, `/**/ typeof app.onData === 'function' && app.onData({"n":1});`, w.Body.String())
+
+	w = run(func(c *Context) { c.JSONP(http.StatusOK, "", map[string]int{"n": 1}) })
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	w = run(func(c *Context) { c.JSONP(http.StatusOK, "alert(1);x", nil) })
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestContext_Trailer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &Context{Request: r, Writer: w}
+		c.DeclareTrailer("x-stream-error")
+		c.String(http.StatusOK, "partial export")
+		http.NewResponseController(w).Flush()
+		c.Trailer("X-Stream-Error", "database went away")
+		c.Trailer("X-Checksum", "abc")
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	// Declared trailers are known before the body is read
+	require.Contains(t, resp.Trailer, "X-Stream-Error")
+	require.NotContains(t, resp.Trailer, "X-Checksum")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "partial export", string(body))
+	require.Equal(t, "database went away", resp.Trailer.Get("X-Stream-Error"))
+	require.Equal(t, "abc", resp.Trailer.Get("X-Checksum"))
+}
+
+// templateLoader is a TemplateLoader of fixed templates
+type templateLoader struct {
+	tmpl *template.Template
+	err  error
+}
+
+func (l templateLoader) Template() (*template.Template, error) { return l.tmpl, l.err }
+
+func TestContext_HTMLTemplate(t *testing.T) {
+	render := func(loader TemplateLoader, debug bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := &Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w, Templates: loader, Debug: debug}
+		c.HTMLTemplate(http.StatusOK, "index.html", "world")
+		return w
+	}
+
+	tmpl := template.Must(template.New("index.html").Parse(`<p>hello {{.}}</p>`))
+	w := render(templateLoader{tmpl: tmpl}, false)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "<p>hello world</p>", w.Body.String())
+
+	// Errors are only detailed in debug mode, escaped
+	broken := templateLoader{err: errors.New(`unexpected "<script>"`)}
+	w = render(broken, false)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NotContains(t, w.Body.String(), "unexpected")
+
+	w = render(broken, true)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	require.Contains(t, w.Body.String(), "unexpected &#34;&lt;script&gt;&#34;")
+
+	require.Equal(t, http.StatusInternalServerError, render(nil, true).Code)
+}
+
+func TestContext_NewID(t *testing.T) {
+	c := &Context{}
+	require.Len(t, c.NewID(), 36)
+
+	c.IDGenerator = idgen.NewULID()
+	require.Len(t, c.NewID(), 26)
+}
+
+func TestContext_Param(t *testing.T) {
+	c := &Context{Params: Params{{Key: "org", Value: "acme"}, {Key: "id", Value: "42"}, {Key: "path", Value: ""}}}
+
+	value, ok := c.Param("id")
+	require.True(t, ok)
+	require.Equal(t, "42", value)
+	_, ok = c.Param("path")
+	require.True(t, ok)
+	_, ok = c.Param("missing")
+	require.False(t, ok)
+	require.Equal(t, map[string]string{"org": "acme", "id": "42", "path": ""}, c.PathParams())
+
+	// Lookups scan the slice, the map is only built by PathParams
+	c = &Context{Params: Params{{Key: "id", Value: "42"}}}
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		c.Param("id")
+		c.GetParam("id")
+	}))
+	require.Nil(t, c.pathParams)
+}
+
+// recordingLogger records the arguments of its entries
+type recordingLogger struct{ entries [][]any }
+
+func (l *recordingLogger) Debug(msg string, args ...any) {
+	l.entries = append(l.entries, append([]any{msg}, args...))
+}
+func (l *recordingLogger) Info(msg string, args ...any)  { l.Debug(msg, args...) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.Debug(msg, args...) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.Debug(msg, args...) }
+
+func TestContext_Logger(t *testing.T) {
+	var logs bytes.Buffer
+	c := &Context{
+		Request: httptest.NewRequest(http.MethodGet, "/users/42", nil),
+		Route:   "/users/:id",
+		Log:     slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+	c.AddLogAttrs("request_id", "abc")
+	c.Logger().Info("loaded", "user", 42)
+	require.Contains(t, logs.String(), `msg=loaded method=GET path=/users/42 route=/users/:id request_id=abc user=42`)
+
+	// Attributes added later apply to the following entries
+	c.AddLogAttrs("tenant", "acme")
+	c.Logger().Info("saved")
+	require.Contains(t, logs.String(), `msg=saved method=GET path=/users/42 route=/users/:id request_id=abc tenant=acme`)
+
+	// Other loggers get the attributes after those of the entry
+	recorder := &recordingLogger{}
+	c = &Context{Request: httptest.NewRequest(http.MethodPost, "/items", nil), Log: recorder}
+	c.Logger().Error("failed", "error", "boom")
+	require.Equal(t, [][]any{{"failed", "error", "boom", slog.String("method", "POST"), slog.String("path", "/items")}}, recorder.entries)
+}
+
+func TestLocalPath(t *testing.T) {
+	require.Equal(t, "/account?tab=1", LocalPath("/account?tab=1"))
+	require.Equal(t, "/", LocalPath(""))
+	require.Equal(t, "/", LocalPath("account"))
+	require.Equal(t, "/", LocalPath("https://evil.com"))
+	require.Equal(t, "/", LocalPath("//evil.com"))
+	require.Equal(t, "/", LocalPath("/\\evil.com"))
+	require.Equal(t, "/", LocalPath("/\t/evil.com"))
+	require.Equal(t, "/", LocalPath("/\n/evil.com"))
+	require.Equal(t, "/", LocalPath("/%2F/evil.com"))
+}
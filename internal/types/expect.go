@@ -0,0 +1,32 @@
+package types
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ExpectsContinue reports whether the client waits for 100 Continue before
+// sending the body of the request, see the Expect header
+func (c *Context) ExpectsContinue() bool {
+	return strings.EqualFold(c.Request.Header.Get("Expect"), "100-continue")
+}
+
+// Continue asks a client expecting 100 Continue to send the body of the
+// request right away, rather than on its first read
+func (c *Context) Continue() {
+	if c.ExpectsContinue() {
+		c.Writer.WriteHeader(http.StatusContinue)
+	}
+}
+
+// Reject100 aborts the request with the status before its body is sent,
+// e.g. http.StatusUnauthorized, the body must not be read afterwards
+//
+// Clients expecting 100 Continue then never send the body, saving the
+// bandwidth of large uploads. Other clients may have sent it already, the
+// connection is closed rather than drained.
+func (c *Context) Reject100(status int) {
+	c.Abort()
+	c.Header("Connection", "close")
+	c.ErrorString(status, http.StatusText(status))
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/static"
+)
+
+// File sends a file of the local file system
+//
+// The Content-Type is derived from the extension of the file, or sniffed
+// from its content, and conditional and range requests are honored. Missing
+// files and directories are answered with 404 Not Found.
+func (c *Context) File(path string) {
+	dir, name := filepath.Split(path)
+	c.FileFromFS(name, http.Dir(dir))
+}
+
+// FileFromFS sends a file of a file system, e.g. http.FS of an embed.FS,
+// like Context.File
+func (c *Context) FileFromFS(name string, fsys http.FileSystem) {
+	if err := static.ServeFile(c.Writer, c.Request, fsys, name, nil); err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist), errors.Is(err, static.ErrIsDirectory):
+			c.ErrorString(http.StatusNotFound, "Not Found")
+		case errors.Is(err, fs.ErrPermission):
+			c.ErrorString(http.StatusForbidden, "Forbidden")
+		default:
+			c.Error(http.StatusInternalServerError, err)
+		}
+	}
+}
+
+// Attachment sends a file of the local file system for download, saved as
+// filename by the client
+//
+// Non-ASCII file names are encoded as specified by RFC 6266.
+func (c *Context) Attachment(path, filename string) {
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.File(path)
+}
+
+// Stream sends the content of a reader with a 200 OK status
+//
+// The Content-Type is sniffed from the content unless already set. Readers
+// implementing io.Seeker, e.g. *os.File or *bytes.Reader, are served with
+// static.ServeContent so that range requests are honored.
+//
+// @return: an error if the content could not be read or written
+func (c *Context) Stream(r io.Reader) error {
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		static.ServeContent(c.Writer, c.Request, "", time.Time{}, seeker)
+		return nil
+	}
+
+	if c.Writer.Header().Get("Content-Type") == "" {
+		sniff := make([]byte, 512)
+		n, err := io.ReadFull(r, sniff)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		c.Header("Content-Type", http.DetectContentType(sniff[:n]))
+		r = io.MultiReader(bytes.NewReader(sniff[:n]), r)
+	}
+	c.Writer.WriteHeader(http.StatusOK)
+	_, err := io.Copy(c.Writer, r)
+	return err
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serveFile runs a handler for a GET request with the given headers
+func serveFile(handler HandlerFunc, header map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	handler(&Context{Request: r, Writer: w})
+	return w
+}
+
+func TestContext_File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n1,2\n"), 0o600))
+
+	w := serveFile(func(c *Context) { c.File(path) }, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	require.NotEmpty(t, w.Header().Get("ETag"))
+	require.Equal(t, "a,b\n1,2\n", w.Body.String())
+
+	w = serveFile(func(c *Context) { c.File(path) }, map[string]string{"Range": "bytes=4-"})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "1,2\n", w.Body.String())
+
+	w = serveFile(func(c *Context) { c.Attachment(path, "résumé 2024.csv") }, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "attachment; filename*=utf-8''r%C3%A9sum%C3%A9%202024.csv", w.Header().Get("Content-Disposition"))
+	w = serveFile(func(c *Context) { c.Attachment(path, "report.csv") }, nil)
+	require.Equal(t, "attachment; filename=report.csv", w.Header().Get("Content-Disposition"))
+
+	require.Equal(t, http.StatusNotFound, serveFile(func(c *Context) { c.File(filepath.Join(dir, "missing")) }, nil).Code)
+	require.Equal(t, http.StatusNotFound, serveFile(func(c *Context) { c.File(dir) }, nil).Code)
+
+	fsys := http.FS(fstest.MapFS{"logo.svg": {Data: []byte("<svg/>")}})
+	w = serveFile(func(c *Context) { c.FileFromFS("logo.svg", fsys) }, nil)
+	require.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	require.Equal(t, "<svg/>", w.Body.String())
+}
+
+func TestContext_Stream(t *testing.T) {
+	// Content types are sniffed from readers
+	w := serveFile(func(c *Context) {
+		require.NoError(t, c.Stream(io.MultiReader(strings.NewReader("<html><body>"), strings.NewReader("hi"))))
+	}, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "<html><body>hi", w.Body.String())
+
+	w = serveFile(func(c *Context) {
+		c.Header("Content-Type", "application/x-ndjson")
+		require.NoError(t, c.Stream(io.LimitReader(strings.NewReader("{}\n{}\n"), 3)))
+	}, nil)
+	require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	require.Equal(t, "{}\n", w.Body.String())
+
+	// Seekable readers support ranges
+	w = serveFile(func(c *Context) {
+		require.NoError(t, c.Stream(bytes.NewReader([]byte("0123456789"))))
+	}, map[string]string{"Range": "bytes=2-4"})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "bytes 2-4/10", w.Header().Get("Content-Range"))
+	require.Equal(t, "234", w.Body.String())
+}
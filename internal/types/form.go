@@ -0,0 +1,120 @@
+package types
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// DefaultMaxMultipartMemory is the amount of a multipart form kept in
+// memory, the rest of the files being stored in temporary files
+const DefaultMaxMultipartMemory = 32 << 20
+
+// ErrUnsupportedForm is returned by Context.BindForm for request bodies
+// that are not forms
+var ErrUnsupportedForm = errors.New("request body is not a form")
+
+// PostForm gets a field of a urlencoded or multipart form body
+func (c *Context) PostForm(name string) string {
+	return c.Request.PostFormValue(name)
+}
+
+// PostFormDefault gets a field of a form body with default value
+func (c *Context) PostFormDefault(name, defaultValue string) string {
+	value := c.Request.PostFormValue(name)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// BindForm binds the fields of an application/x-www-form-urlencoded or
+// multipart/form-data body to a struct
+//
+// Fields are named by their form tag, or by their Go name without one, and
+// fields tagged "-" are skipped. Strings, booleans, numbers, slices and
+// pointers of these, and encoding.TextUnmarshaler implementations are
+// supported, as well as *multipart.FileHeader and []*multipart.FileHeader
+// for uploads. Empty values leave fields unchanged.
+//
+// @return: ErrUnsupportedForm for other bodies, or an error naming every
+// field that could not be parsed
+func (c *Context) BindForm(obj any) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+	var files map[string][]*multipart.FileHeader
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if err := c.Request.ParseForm(); err != nil {
+			return err
+		}
+	case "multipart/form-data":
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		files = form.File
+	default:
+		return ErrUnsupportedForm
+	}
+	return bind(obj, binding{tag: "form", what: "form field", values: c.Request.PostForm, files: files})
+}
+
+// MultipartForm parses a multipart/form-data body, keeping up to
+// MaxMultipartMemory of it in memory
+//
+// @return: the parsed form, including the query parameters among its
+// values, or http.ErrNotMultipart for other bodies
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	memory := c.MaxMultipartMemory
+	if memory <= 0 {
+		memory = DefaultMaxMultipartMemory
+	}
+	if err := c.Request.ParseMultipartForm(memory); err != nil {
+		return nil, err
+	}
+	return c.Request.MultipartForm, nil
+}
+
+// FormFile gets the first file uploaded in a field of a multipart form
+//
+// @return: http.ErrMissingFile if the field has no file
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if files := form.File[name]; len(files) > 0 {
+		return files[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile writes an uploaded file to dst, creating its directory
+// if needed
+//
+// The file name sent by the client must not be used in dst as is, since
+// it may contain path elements, see filepath.Base.
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,149 @@
+package types
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type signupForm struct {
+	formMeta
+	Name     string   `form:"name"`
+	Age      int      `form:"age"`
+	Score    *float64 `form:"score"`
+	Agree    bool     `form:"agree"`
+	Tags     []string `form:"tag"`
+	Birthday time.Time
+	Ignored  string `form:"-"`
+
+	Avatar *multipart.FileHeader   `form:"avatar"`
+	Photos []*multipart.FileHeader `form:"photo"`
+}
+
+type formMeta struct {
+	Source string `form:"source"`
+}
+
+func TestContext_BindForm(t *testing.T) {
+	form := url.Values{
+		"name":     {"ada"},
+		"age":      {"36"},
+		"score":    {"9.5"},
+		"agree":    {"on"},
+		"tag":      {"a", "b"},
+		"Birthday": {"1815-12-10T00:00:00Z"},
+		"Ignored":  {"x"},
+		"-":        {"x"},
+		"source":   {"ad"},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := &Context{Request: r}
+
+	var got signupForm
+	require.NoError(t, c.BindForm(&got))
+	require.Equal(t, "ada", got.Name)
+	require.Equal(t, 36, got.Age)
+	require.Equal(t, 9.5, *got.Score)
+	require.True(t, got.Agree)
+	require.Equal(t, []string{"a", "b"}, got.Tags)
+	require.Equal(t, 1815, got.Birthday.Year())
+	require.Empty(t, got.Ignored)
+	require.Equal(t, "ad", got.Source)
+	require.Equal(t, "ada", c.PostForm("name"))
+	require.Equal(t, "none", c.PostFormDefault("missing", "none"))
+
+	// Invalid fields are reported together
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("age=old&score=high&name=ok"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	got = signupForm{}
+	err := (&Context{Request: r}).BindForm(&got)
+	require.ErrorContains(t, err, "form field age")
+	require.ErrorContains(t, err, "form field score")
+	require.Equal(t, "ok", got.Name)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"ada"}`))
+	r.Header.Set("Content-Type", "application/json")
+	require.ErrorIs(t, (&Context{Request: r}).BindForm(&got), ErrUnsupportedForm)
+	require.Error(t, (&Context{Request: r}).BindForm(got))
+}
+
+func TestContext_BindForm_Multipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "ada")
+	for _, name := range []string{"avatar.png", "photo1.jpg"} {
+		field := "photo"
+		if name == "avatar.png" {
+			field = "avatar"
+		}
+		part, err := mw.CreateFormFile(field, name)
+		require.NoError(t, err)
+		part.Write([]byte("data of " + name))
+	}
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	c := &Context{Request: r}
+
+	var got signupForm
+	require.NoError(t, c.BindForm(&got))
+	require.Equal(t, "ada", got.Name)
+	require.Equal(t, "avatar.png", got.Avatar.Filename)
+	require.Len(t, got.Photos, 1)
+
+	f, err := got.Photos[0].Open()
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "data of photo1.jpg", string(data))
+	require.Equal(t, "ada", c.PostForm("name"))
+}
+
+func TestContext_FormFile(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("upload", "../report.csv")
+	require.NoError(t, err)
+	part.Write([]byte(strings.Repeat("x", 1024)))
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	c := &Context{Request: r, MaxMultipartMemory: 16}
+
+	// The file does not fit in memory and is kept in a temporary file
+	form, err := c.MultipartForm()
+	require.NoError(t, err)
+	require.Len(t, form.File["upload"], 1)
+
+	_, err = c.FormFile("missing")
+	require.ErrorIs(t, err, http.ErrMissingFile)
+	file, err := c.FormFile("upload")
+	require.NoError(t, err)
+	require.Equal(t, "report.csv", file.Filename)
+	require.Equal(t, int64(1024), file.Size)
+
+	dst := filepath.Join(t.TempDir(), "uploads", "report.csv")
+	require.NoError(t, c.SaveUploadedFile(file, dst))
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	require.Len(t, data, 1024)
+
+	c = &Context{Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a=b"))}
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = c.FormFile("upload")
+	require.ErrorIs(t, err, http.ErrNotMultipart)
+}
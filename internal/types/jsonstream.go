@@ -0,0 +1,71 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BindJSONStream passes a decoder of the JSON request body to fn, for
+// bodies too large to be bound at once, e.g. bulk imports decoded with
+// DecodeArray
+//
+// @return: the error of fn
+func (c *Context) BindJSONStream(fn func(decoder *json.Decoder) error) error {
+	return fn(json.NewDecoder(c.Request.Body))
+}
+
+// ItemError is the error of an element of a JSON array, see DecodeArray
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+// Error implements error
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the element
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeArray decodes a JSON array one element at a time, calling fn with
+// the index of every element, and either the element or the *ItemError of
+// an element that does not decode as a T, so that bulk imports report
+// invalid elements and go on with the others
+//
+// @return: the error returned by fn, which stops the decoding, or an
+// error if the array is not valid JSON
+func DecodeArray[T any](decoder *json.Decoder, fn func(index int, item T, err error) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected a JSON array, got %v", token)
+	}
+
+	for index := 0; decoder.More(); index++ {
+		// Elements are read as raw values first, so that an element of the
+		// wrong type does not stop the decoding of the array
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+
+		var item T
+		if err := json.Unmarshal(raw, &item); err != nil {
+			if err := fn(index, item, &ItemError{Index: index, Err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(index, item, nil); err != nil {
+			return err
+		}
+	}
+
+	_, err = decoder.Token() // the closing bracket
+	return err
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_BindJSONStream(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Price int    `json:"price"`
+	}
+
+	body := `[{"name":"a","price":1}, {"name":"b","price":"free"}, {"name":"c","price":3}]`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c := &Context{Request: r, Writer: httptest.NewRecorder()}
+
+	var items []item
+	var itemErrs []error
+	err := c.BindJSONStream(func(decoder *json.Decoder) error {
+		return DecodeArray(decoder, func(index int, it item, err error) error {
+			if err != nil {
+				itemErrs = append(itemErrs, err)
+				return nil
+			}
+			items = append(items, it)
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	require.Equal(t, []item{{"a", 1}, {"c", 3}}, items)
+	require.Len(t, itemErrs, 1)
+	var itemErr *ItemError
+	require.ErrorAs(t, itemErrs[0], &itemErr)
+	require.Equal(t, 1, itemErr.Index)
+	require.Contains(t, itemErr.Error(), "item 1: ")
+}
+
+func TestDecodeArray_Errors(t *testing.T) {
+	decode := func(body string, fn func(int, int, error) error) error {
+		return DecodeArray(json.NewDecoder(strings.NewReader(body)), fn)
+	}
+	ignore := func(int, int, error) error { return nil }
+
+	require.Error(t, decode(`{"a":1}`, ignore))
+	require.Error(t, decode(`[1, 2`, ignore))
+	require.Error(t, decode(`[1, }`, ignore))
+	require.NoError(t, decode(`[]`, ignore))
+
+	// Errors of fn stop the decoding
+	stop := errors.New("stop")
+	var seen []int
+	err := decode(`[1, 2, 3]`, func(_ int, n int, _ error) error {
+		seen = append(seen, n)
+		if n == 2 {
+			return stop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, []int{1, 2}, seen)
+}
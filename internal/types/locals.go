@@ -0,0 +1,21 @@
+package types
+
+// Set stores a value for the rest of the request, e.g. the user
+// authenticated by a middleware, see apictx.Set for typed values
+func (c *Context) Set(key string, value any) {
+	if c.locals == nil {
+		c.locals = make(map[string]any)
+	}
+	c.locals[key] = value
+}
+
+// Get returns the value stored with the key, and whether it was stored
+func (c *Context) Get(key string) (any, bool) {
+	value, ok := c.locals[key]
+	return value, ok
+}
+
+// Delete removes the value stored with the key
+func (c *Context) Delete(key string) {
+	delete(c.locals, key)
+}
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// Logger logs the events of the engine, the middleware and the handlers,
+// e.g. *slog.Logger
+//
+// The arguments after the message are key-value pairs or slog.Attr, see
+// slog.Logger.Info.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Logger returns the logger of the request, adding its method, path and
+// route, and the attributes of Context.AddLogAttrs, e.g. the request id,
+// to every entry
+func (c *Context) Logger() Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+
+	base := c.Log
+	if base == nil {
+		base = slog.Default()
+	}
+	attrs := []any{slog.String("method", c.Request.Method), slog.String("path", c.Request.URL.Path)}
+	if c.Route != "" {
+		attrs = append(attrs, slog.String("route", c.Route))
+	}
+	attrs = append(attrs, c.logAttrs...)
+
+	if l, ok := base.(*slog.Logger); ok {
+		c.logger = l.With(attrs...)
+	} else {
+		c.logger = &attrLogger{Logger: base, attrs: attrs}
+	}
+	return c.logger
+}
+
+// AddLogAttrs adds attributes to the entries of Context.Logger, e.g. the
+// request id or the user
+func (c *Context) AddLogAttrs(args ...any) {
+	c.logAttrs = append(c.logAttrs, args...)
+	c.logger = nil
+}
+
+// attrLogger adds attributes to the entries of a Logger other than
+// *slog.Logger
+type attrLogger struct {
+	Logger
+	attrs []any
+}
+
+func (l *attrLogger) Debug(msg string, args ...any) {
+	l.Logger.Debug(msg, slices.Concat(args, l.attrs)...)
+}
+
+func (l *attrLogger) Info(msg string, args ...any) {
+	l.Logger.Info(msg, slices.Concat(args, l.attrs)...)
+}
+
+func (l *attrLogger) Warn(msg string, args ...any) {
+	l.Logger.Warn(msg, slices.Concat(args, l.attrs)...)
+}
+
+func (l *attrLogger) Error(msg string, args ...any) {
+	l.Logger.Error(msg, slices.Concat(args, l.attrs)...)
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// NDJSONWriter writes a newline-delimited JSON response one value at a
+// time, see Context.NDJSONWriter
+type NDJSONWriter struct {
+	c       *Context
+	status  int
+	encoder *json.Encoder
+	rc      *http.ResponseController
+}
+
+// NDJSONWriter returns a writer streaming a newline-delimited JSON
+// response, whose header is sent with the first value
+//
+// Every value is flushed to the client once written, so that large result
+// sets are never buffered in full.
+func (c *Context) NDJSONWriter(status int) *NDJSONWriter {
+	return &NDJSONWriter{
+		c:       c,
+		status:  status,
+		encoder: json.NewEncoder(c.Writer),
+		rc:      http.NewResponseController(c.Writer),
+	}
+}
+
+// Encode writes a value on its own line and flushes it
+//
+// @return: an error if the value could not be encoded, or written, e.g.
+// once the client went away
+func (w *NDJSONWriter) Encode(value any) error {
+	// Values are encoded first, so that invalid values do not leave half
+	// a line behind
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	w.writeHeader()
+	if err := w.encoder.Encode(json.RawMessage(data)); err != nil {
+		return err
+	}
+	return w.rc.Flush()
+}
+
+// Close sends the header if no value was written, for empty results
+func (w *NDJSONWriter) Close() error {
+	w.writeHeader()
+	return nil
+}
+
+// writeHeader sends the header once
+func (w *NDJSONWriter) writeHeader() {
+	if w.status == 0 {
+		return
+	}
+	w.c.Writer.Header().Set("Content-Type", MIMENDJSON)
+	w.c.Writer.WriteHeader(w.status)
+	w.status = 0
+}
+
+// NDJSON streams the values received from a channel as newline-delimited
+// JSON, until the channel is closed or the client goes away
+//
+// The producer should stop sending when the request context is done, the
+// channel is not drained.
+//
+// @return: an error if a value could not be encoded or written, or the
+// request context error if the client went away
+func (c *Context) NDJSON(status int, values <-chan any) error {
+	w := c.NDJSONWriter(status)
+	defer w.Close()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case value, ok := <-values:
+			if !ok {
+				return nil
+			}
+			if err := w.Encode(value); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_NDJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/export", nil), Writer: w}
+
+	values := make(chan any, 3)
+	values <- map[string]int{"id": 1}
+	values <- "<line>"
+	values <- nil
+	close(values)
+	require.NoError(t, c.NDJSON(http.StatusOK, values))
+
+	require.Equal(t, MIMENDJSON, w.Header().Get("Content-Type"))
+	require.True(t, w.Flushed)
+	require.Equal(t, "{\"id\":1}\n\"\\u003cline\\u003e\"\nnull\n", w.Body.String())
+
+	// Streams end when the client goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Request = c.Request.WithContext(ctx)
+	require.ErrorIs(t, c.NDJSON(http.StatusOK, make(chan any)), context.Canceled)
+}
+
+func TestContext_NDJSONWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w}
+
+	writer := c.NDJSONWriter(http.StatusPartialContent)
+	require.NoError(t, writer.Encode(1))
+	require.Error(t, writer.Encode(func() {}))
+	require.NoError(t, writer.Encode([]int{2}))
+	require.NoError(t, writer.Close())
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "1\n[2]\n", w.Body.String())
+
+	// Empty results still send the header
+	w = httptest.NewRecorder()
+	c.Writer = w
+	require.NoError(t, c.NDJSONWriter(http.StatusOK).Close())
+	require.Equal(t, MIMENDJSON, w.Header().Get("Content-Type"))
+	require.Empty(t, w.Body.String())
+}
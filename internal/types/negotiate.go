@@ -0,0 +1,185 @@
+package types
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types of the responses, e.g. of the offers rendered by
+// Context.Negotiate
+const (
+	MIMEJSON = "application/json"
+	MIMEXML  = "application/xml"
+	MIMEHTML = "text/html"
+	MIMEText = "text/plain"
+
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// Offer is a representation of a response, one of which is picked by
+// Context.Negotiate
+type Offer struct {
+	MediaType string
+	Render    func(c *Context, status int)
+}
+
+// JSONOffer offers the data encoded as JSON
+func JSONOffer(data any) Offer {
+	return Offer{MediaType: MIMEJSON, Render: func(c *Context, status int) {
+		c.JSON(status, data)
+	}}
+}
+
+// XMLOffer offers the data encoded as XML
+func XMLOffer(data any) Offer {
+	return Offer{MediaType: MIMEXML, Render: func(c *Context, status int) {
+		c.XML(status, data)
+	}}
+}
+
+// HTMLOffer offers an HTML document
+func HTMLOffer(html string) Offer {
+	return Offer{MediaType: MIMEHTML, Render: func(c *Context, status int) {
+		c.HTML(status, html)
+	}}
+}
+
+// TextOffer offers plain text
+func TextOffer(text string) Offer {
+	return Offer{MediaType: MIMEText, Render: func(c *Context, status int) {
+		c.String(status, text)
+	}}
+}
+
+// XML sends an XML response
+func (c *Context) XML(status int, data any) {
+	body, err := xml.Marshal(data)
+	if err != nil {
+		c.Error(http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(status, MIMEXML, append([]byte(xml.Header), body...))
+}
+
+// Negotiate renders the offer best matching the Accept header of the
+// request, so that one handler can serve browsers and API clients
+//
+// Offers are listed in order of preference, which breaks ties between the
+// media types the client accepts equally. Requests accepting none of them
+// are answered with 406 Not Acceptable.
+func (c *Context) Negotiate(status int, offers ...Offer) {
+	mediaTypes := make([]string, len(offers))
+	for i, offer := range offers {
+		mediaTypes[i] = offer.MediaType
+	}
+
+	c.Writer.Header().Add("Vary", "Accept")
+	i := negotiate(c.Request.Header.Values("Accept"), mediaTypes)
+	if i < 0 {
+		c.ErrorString(http.StatusNotAcceptable, "none of "+strings.Join(mediaTypes, ", ")+" is acceptable")
+		return
+	}
+	offers[i].Render(c, status)
+}
+
+// Accepts returns the media type best matching the Accept header of the
+// request, see Context.Negotiate
+//
+// @return: the matching media type, or "" if none is acceptable
+func (c *Context) Accepts(mediaTypes ...string) string {
+	if i := negotiate(c.Request.Header.Values("Accept"), mediaTypes); i >= 0 {
+		return mediaTypes[i]
+	}
+	return ""
+}
+
+// mediaRange is a media range of an Accept header, e.g. text/*;q=0.5
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// negotiate picks the media type best matching the Accept header values,
+// see RFC 9110 section 12.5.1
+//
+// Every media type takes the quality of the most specific range matching
+// it. Without an Accept header, every media type is acceptable.
+//
+// @return: the index of the media type with the highest quality, the first
+// one on ties, or -1 if none is acceptable
+func negotiate(accept []string, mediaTypes []string) int {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		if len(mediaTypes) == 0 {
+			return -1
+		}
+		return 0
+	}
+
+	best, bestQ := -1, 0.0
+	for i, mediaType := range mediaTypes {
+		typ, subtype, ok := splitMediaType(mediaType)
+		if !ok {
+			continue
+		}
+
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*" && r.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+// parseAccept parses the media ranges of Accept header values, skipping
+// the malformed ones
+func parseAccept(values []string) []mediaRange {
+	var ranges []mediaRange
+	for _, value := range values {
+		for part := range strings.SplitSeq(value, ",") {
+			mediaType, params, _ := strings.Cut(part, ";")
+			typ, subtype, ok := splitMediaType(mediaType)
+			if !ok || (typ == "*" && subtype != "*") {
+				continue
+			}
+
+			q := 1.0
+			for param := range strings.SplitSeq(params, ";") {
+				name, value, _ := strings.Cut(param, "=")
+				if strings.EqualFold(strings.TrimSpace(name), "q") {
+					if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && parsed >= 0 && parsed <= 1 {
+						q = parsed
+					}
+				}
+			}
+			ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+		}
+	}
+	return ranges
+}
+
+// splitMediaType splits a media type, ignoring its parameters
+func splitMediaType(mediaType string) (string, string, bool) {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+	if !ok || typ == "" || subtype == "" {
+		return "", "", false
+	}
+	return typ, subtype, true
+}
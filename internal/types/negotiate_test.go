@@ -0,0 +1,73 @@
+package types
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_Accepts(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		offers []string
+		want   string
+	}{
+		{"no header", "", []string{MIMEJSON, MIMEHTML}, MIMEJSON},
+		{"exact", "text/html", []string{MIMEJSON, MIMEHTML}, MIMEHTML},
+		{"quality", "application/json;q=0.5, text/html", []string{MIMEJSON, MIMEHTML}, MIMEHTML},
+		{"server preference on ties", "*/*", []string{MIMEXML, MIMEJSON}, MIMEXML},
+		{"specific range wins", "text/*;q=0.8, text/plain;q=0.2", []string{MIMEText, MIMEHTML}, MIMEHTML},
+		{"excluded", "application/json;q=0, */*", []string{MIMEJSON, MIMEText}, MIMEText},
+		{"browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", []string{MIMEJSON, MIMEHTML}, MIMEHTML},
+		{"offer parameters", "text/plain", []string{"text/plain; charset=utf-8"}, "text/plain; charset=utf-8"},
+		{"case insensitive", "Application/JSON", []string{MIMEJSON}, MIMEJSON},
+		{"none acceptable", "image/png", []string{MIMEJSON, MIMEHTML}, ""},
+		{"malformed ranges skipped", "html, */html, application/json", []string{MIMEHTML, MIMEJSON}, MIMEJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			c := &Context{Request: r}
+			require.Equal(t, tt.want, c.Accepts(tt.offers...))
+		})
+	}
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+	negotiate := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		c := &Context{Request: r, Writer: w}
+		c.Negotiate(http.StatusCreated,
+			JSONOffer(user{"ada"}),
+			XMLOffer(user{"ada"}),
+			HTMLOffer("<p>ada</p>"),
+			TextOffer("ada"),
+		)
+		return w
+	}
+
+	w := negotiate("application/json")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.JSONEq(t, `{"name":"ada"}`, w.Body.String())
+	require.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	w = negotiate("application/xml")
+	require.Equal(t, MIMEXML, w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "<user><name>ada</name></user>")
+
+	require.Equal(t, "<p>ada</p>", negotiate("text/html,*/*;q=0.8").Body.String())
+	require.Equal(t, "ada", negotiate("text/plain").Body.String())
+	require.Equal(t, http.StatusNotAcceptable, negotiate("image/png").Code)
+}
@@ -0,0 +1,29 @@
+package types
+
+// Param is a single path parameter
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params are the path parameters of a matched route, in path order
+type Params []Param
+
+// Get returns the value of the parameter with the name
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// Map returns the parameters as a map
+func (ps Params) Map() map[string]string {
+	m := make(map[string]string, len(ps))
+	for _, p := range ps {
+		m[p.Key] = p.Value
+	}
+	return m
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter wraps an http.ResponseWriter to record the status code and
+// the number of body bytes written
+//
+// Flush and Hijack are forwarded to the wrapped writer, and Unwrap exposes
+// it to http.ResponseController.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// NewResponseWriter wraps w
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 || w.status < 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Status returns the status code sent, or 200 if nothing was written yet
+// since that is what net/http sends by default
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of body bytes written
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Written reports whether the status code was sent
+func (w *ResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+// Flush implements http.Flusher
+func (w *ResponseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
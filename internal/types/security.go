@@ -0,0 +1,47 @@
+package types
+
+import (
+	"net/url"
+	"strings"
+)
+
+// cspNonceKey is the key of the nonce of Context.CSPNonce, see Context.Set
+const cspNonceKey = "csp.nonce"
+
+// CSPNonce returns the nonce of the Content-Security-Policy of the
+// response, set by middleware.SecureHeaders, for the nonce attribute of the
+// inline scripts and styles, e.g. <script nonce="{{.Nonce}}">. Empty
+// without nonce.
+func (c *Context) CSPNonce() string {
+	nonce, _ := c.locals[cspNonceKey].(string)
+	return nonce
+}
+
+// SetCSPNonce sets the nonce returned by Context.CSPNonce, for the
+// middleware sending the policy
+func (c *Context) SetCSPNonce(nonce string) {
+	c.Set(cspNonceKey, nonce)
+}
+
+// LocalPath returns path if it is a path on this site, "/" otherwise, for
+// the redirects to a path taken from the request, e.g. after a login, so
+// that they cannot lead to other sites
+//
+// Backslashes and control characters are rejected anywhere, as browsers
+// read a backslash as a slash and strip tabs and newlines, turning e.g.
+// "/\t/evil.com" into "//evil.com".
+func LocalPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return "/"
+	}
+	for i := 0; i < len(path); i++ {
+		if b := path[i]; b < 0x20 || b == 0x7f || b == '\\' {
+			return "/"
+		}
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil || strings.HasPrefix(u.Path, "//") {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,108 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidSSEField is returned for server-sent event names and ids that
+// contain line breaks, which would inject fields or events
+var ErrInvalidSSEField = errors.New("line break in server-sent event name or id")
+
+// SSEvent sends a server-sent event and flushes it to the client
+//
+// Strings and byte slices are sent as is, split in one data line per line,
+// whether lines end with LF, CRLF or CR, other values are encoded as JSON.
+// The event name is omitted if empty, so that clients receive a message
+// event. The text/event-stream headers are set with the first event.
+//
+// @return: ErrInvalidSSEField if the event name contains CR or LF
+// @return: an error if the data could not be encoded or written, e.g. once
+// the client went away
+func (c *Context) SSEvent(event string, data any) error {
+	return c.SSEventID("", event, data)
+}
+
+// SSEventID sends a server-sent event like SSEvent, with the id the client
+// sends back in Last-Event-ID when it reconnects, omitted if empty
+//
+// @return: ErrInvalidSSEField if the id or the event name contains CR or
+// LF, or the id NUL, which clients ignore
+func (c *Context) SSEventID(id, event string, data any) error {
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n\x00") {
+		return ErrInvalidSSEField
+	}
+
+	var payload []byte
+	switch data := data.(type) {
+	case string:
+		payload = []byte(data)
+	case []byte:
+		payload = data
+	default:
+		var err error
+		if payload, err = json.Marshal(data); err != nil {
+			return err
+		}
+	}
+
+	header := c.Writer.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		// Proxies such as nginx would buffer the events otherwise
+		header.Set("X-Accel-Buffering", "no")
+	}
+
+	var buf bytes.Buffer
+	if id != "" {
+		fmt.Fprintf(&buf, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	payload = bytes.ReplaceAll(payload, []byte("\r\n"), []byte("\n"))
+	payload = bytes.ReplaceAll(payload, []byte("\r"), []byte("\n"))
+	for line := range bytes.Lines(payload) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\n")))
+		buf.WriteByte('\n')
+	}
+	if len(payload) == 0 {
+		buf.WriteString("data: \n")
+	}
+	buf.WriteByte('\n')
+
+	if _, err := c.Writer.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return http.NewResponseController(c.Writer).Flush()
+}
+
+// StreamFunc calls step until it returns false or the client goes away,
+// flushing the response after every call, e.g. to send events with
+// Context.SSEvent as they happen
+//
+// Steps waiting for something to send should also watch the request
+// context, so that they return when the client goes away.
+//
+// @return: true if the client went away before step returned false
+func (c *Context) StreamFunc(step func(w io.Writer) bool) bool {
+	ctx := c.Request.Context()
+	rc := http.NewResponseController(c.Writer)
+	for {
+		if ctx.Err() != nil {
+			return true
+		}
+		keepOpen := step(c.Writer)
+		rc.Flush()
+		if !keepOpen {
+			return ctx.Err() != nil
+		}
+	}
+}
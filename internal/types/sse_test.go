@@ -0,0 +1,65 @@
+package types
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_SSEvent(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/events", nil), Writer: w}
+
+	require.NoError(t, c.SSEvent("greeting", "hello\nworld"))
+	require.NoError(t, c.SSEvent("", map[string]int{"count": 1}))
+	require.NoError(t, c.SSEvent("ping", nil))
+	require.Error(t, c.SSEvent("invalid", func() {}))
+
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	require.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	require.True(t, w.Flushed)
+	require.Equal(t, "event: greeting\ndata: hello\ndata: world\n\n"+
+		"data: {\"count\":1}\n\n"+
+		"event: ping\ndata: null\n\n", w.Body.String())
+
+	// Every line ending splits the data, line breaks cannot inject fields
+	w.Body.Reset()
+	require.NoError(t, c.SSEventID("7", "update", "a\r\nb\rc\nd"))
+	require.Equal(t, "id: 7\nevent: update\ndata: a\ndata: b\ndata: c\ndata: d\n\n", w.Body.String())
+	require.ErrorIs(t, c.SSEvent("x\rdata: injected", "a"), ErrInvalidSSEField)
+	require.ErrorIs(t, c.SSEventID("1\nevent: x", "", "a"), ErrInvalidSSEField)
+	require.ErrorIs(t, c.SSEventID("1\x00", "", "a"), ErrInvalidSSEField)
+	require.Equal(t, "id: 7\nevent: update\ndata: a\ndata: b\ndata: c\ndata: d\n\n", w.Body.String())
+}
+
+func TestContext_StreamFunc(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/events", nil), Writer: w}
+
+	n := 0
+	gone := c.StreamFunc(func(w io.Writer) bool {
+		n++
+		c.SSEvent("tick", n)
+		return n < 3
+	})
+	require.False(t, gone)
+	require.Equal(t, 3, n)
+	require.Equal(t, "event: tick\ndata: 1\n\nevent: tick\ndata: 2\n\nevent: tick\ndata: 3\n\n", w.Body.String())
+
+	// Streams end when the client goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Request = c.Request.WithContext(ctx)
+	n = 0
+	gone = c.StreamFunc(func(w io.Writer) bool {
+		if n++; n == 2 {
+			cancel()
+		}
+		return true
+	})
+	require.True(t, gone)
+	require.Equal(t, 2, n)
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"context"
+	"maps"
+)
+
+// tagsKey is the context key of the tags of the request
+type tagsKey struct{}
+
+// WithTags returns a copy of the context carrying the tags, merged over
+// the tags it carries already
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := maps.Clone(TagsFromContext(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(tags))
+	}
+	maps.Copy(merged, tags)
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// TagsFromContext returns the tags of the route handling a request, e.g.
+// to add them to the attributes of spans, see middleware.Tags. The map
+// must not be modified.
+func TagsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// Tags returns the tags of the route of the request, nil if it has none
+func (c *Context) Tags() map[string]string {
+	return TagsFromContext(c.Request.Context())
+}
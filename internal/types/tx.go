@@ -0,0 +1,33 @@
+package types
+
+import "context"
+
+// Tx is a request-scoped transaction, see middleware.Tx
+//
+// pgx.Tx implements it, other transactions are adapted, e.g. *sql.Tx with
+// middleware.SQLTx.
+type Tx interface {
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// txKey is the context key of the request transaction
+type txKey struct{}
+
+// WithTx returns a copy of the context carrying the transaction
+func WithTx(ctx context.Context, tx Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by the context, e.g. in
+// repositories called with the request context
+func TxFromContext(ctx context.Context) (Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(Tx)
+	return tx, ok
+}
+
+// Tx returns the transaction of the request, nil outside middleware.Tx
+func (c *Context) Tx() Tx {
+	tx, _ := TxFromContext(c.Request.Context())
+	return tx
+}
@@ -0,0 +1,198 @@
+package useragent
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Client hint request headers
+const (
+	HeaderUA                = "Sec-CH-UA"
+	HeaderUAMobile          = "Sec-CH-UA-Mobile"
+	HeaderUAPlatform        = "Sec-CH-UA-Platform"
+	HeaderUAFullVersionList = "Sec-CH-UA-Full-Version-List"
+	HeaderUAPlatformVersion = "Sec-CH-UA-Platform-Version"
+	HeaderUAModel           = "Sec-CH-UA-Model"
+)
+
+// Client describes the software making a request
+type Client struct {
+	Family   string `json:"family"`   // e.g. Chrome, Firefox, Googlebot
+	Version  string `json:"version"`  // version of the family, may be empty
+	Platform string `json:"platform"` // e.g. Windows, Android, may be empty
+	Mobile   bool   `json:"mobile"`
+	Bot      bool   `json:"bot"`
+}
+
+// Parser extracts the client description from request headers
+type Parser interface {
+	Parse(header http.Header) Client
+}
+
+// ParserFunc adapts an ordinary function to the Parser interface
+type ParserFunc func(header http.Header) Client
+
+// Parse calls f(header)
+func (f ParserFunc) Parse(header http.Header) Client {
+	return f(header)
+}
+
+// BasicParser recognizes the common browsers, platforms and crawlers
+//
+// Client hints take precedence over the User-Agent string when present.
+// Requests without either are reported as bots, since every browser
+// sends a User-Agent.
+type BasicParser struct{}
+
+var (
+	// productPattern matches product tokens, e.g. Firefox/126.0
+	productPattern = regexp.MustCompile(`([A-Za-z][\w.\-]*)/(\d[\w.]*)`)
+
+	// botPattern matches product names and comments of automated clients
+	botPattern = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|scraper|headless|curl|wget|python|go-http-client|java/|okhttp|libwww|httpclient|facebookexternalhit`)
+)
+
+// browserFamilies maps product tokens to browser families, in order of
+// precedence since most browsers also claim to be Chrome and Safari
+var browserFamilies = []struct {
+	token  string
+	family string
+}{
+	{"Edg", "Edge"},
+	{"EdgA", "Edge"},
+	{"EdgiOS", "Edge"},
+	{"OPR", "Opera"},
+	{"SamsungBrowser", "Samsung Internet"},
+	{"CriOS", "Chrome"},
+	{"FxiOS", "Firefox"},
+	{"Firefox", "Firefox"},
+	{"Chrome", "Chrome"},
+}
+
+// platforms maps User-Agent substrings to platforms, in order of precedence
+var platforms = []struct {
+	token    string
+	platform string
+}{
+	{"Windows", "Windows"},
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"CrOS", "Chrome OS"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// Parse implements Parser
+func (BasicParser) Parse(header http.Header) Client {
+	ua := header.Get("User-Agent")
+	client := parseUserAgent(ua)
+
+	brands := header.Get(HeaderUAFullVersionList)
+	if brands == "" {
+		brands = header.Get(HeaderUA)
+	}
+	if family, version := parseBrands(brands); family != "" {
+		client.Family, client.Version = family, version
+	}
+	if platform := unquote(header.Get(HeaderUAPlatform)); platform != "" {
+		client.Platform = platform
+	}
+	if mobile := header.Get(HeaderUAMobile); mobile != "" {
+		client.Mobile = mobile == "?1"
+	}
+
+	client.Bot = client.Bot || (ua == "" && brands == "")
+	return client
+}
+
+// parseUserAgent parses a User-Agent string
+func parseUserAgent(ua string) Client {
+	var client Client
+
+	products := productPattern.FindAllStringSubmatch(ua, -1)
+	versions := make(map[string]string, len(products))
+	for _, product := range products {
+		if _, ok := versions[product[1]]; !ok {
+			versions[product[1]] = product[2]
+		}
+	}
+
+	for _, platform := range platforms {
+		if strings.Contains(ua, platform.token) {
+			client.Platform = platform.platform
+			break
+		}
+	}
+	client.Mobile = strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone")
+
+	// Crawlers are named after their own product token, e.g. Googlebot/2.1
+	if botPattern.MatchString(ua) {
+		client.Bot = true
+		for _, product := range products {
+			if botPattern.MatchString(product[1] + "/") {
+				client.Family, client.Version = product[1], product[2]
+				return client
+			}
+		}
+	}
+
+	for _, browser := range browserFamilies {
+		if version, ok := versions[browser.token]; ok {
+			client.Family, client.Version = browser.family, version
+			return client
+		}
+	}
+
+	if version, ok := versions["Version"]; ok && versions["Safari"] != "" {
+		client.Family, client.Version = "Safari", version
+		return client
+	}
+
+	if len(products) > 0 && products[0][1] != "Mozilla" {
+		client.Family, client.Version = products[0][1], products[0][2]
+	}
+	return client
+}
+
+// parseBrands parses a Sec-CH-UA brand list, e.g.
+// "Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"
+//
+// @return: the most specific brand and its version, preferring any brand
+// over the generic Chromium one and skipping GREASE brands
+func parseBrands(list string) (string, string) {
+	var family, version string
+	for item := range strings.SplitSeq(list, ",") {
+		brand, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		brand = unquote(brand)
+		if brand == "" || isGreaseBrand(brand) {
+			continue
+		}
+
+		v := ""
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "v="); ok {
+			v = unquote(value)
+		}
+
+		if family == "" || family == "Chromium" {
+			family, version = brand, v
+		}
+	}
+	return family, version
+}
+
+// isGreaseBrand reports whether a brand is a placeholder added by browsers
+// to keep servers from relying on the brand list format
+func isGreaseBrand(brand string) bool {
+	return strings.HasPrefix(brand, "Not") && strings.Contains(brand, "Brand")
+}
+
+// unquote removes the quotes around a structured header string
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package useragent
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicParser_UserAgent(t *testing.T) {
+	tests := []struct {
+		ua     string
+		client Client
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			Client{Family: "Chrome", Version: "124.0.0.0", Platform: "Windows"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			Client{Family: "Edge", Version: "124.0.2478.51", Platform: "Windows"},
+		},
+		{
+			"Mozilla/5.0 (X11; Linux x86_64; rv:126.0) Gecko/20100101 Firefox/126.0",
+			Client{Family: "Firefox", Version: "126.0", Platform: "Linux"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			Client{Family: "Safari", Version: "17.5", Platform: "iOS", Mobile: true},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			Client{Family: "Chrome", Version: "124.0.0.0", Platform: "Android", Mobile: true},
+		},
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Client{Family: "Googlebot", Version: "2.1", Bot: true},
+		},
+		{
+			"curl/8.5.0",
+			Client{Family: "curl", Version: "8.5.0", Bot: true},
+		},
+		{
+			"",
+			Client{Bot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.ua != "" {
+			header.Set("User-Agent", tt.ua)
+		}
+		require.Equal(t, tt.client, BasicParser{}.Parse(header), tt.ua)
+	}
+}
+
+func TestBasicParser_ClientHints(t *testing.T) {
+	header := http.Header{}
+	header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+	header.Set(HeaderUA, `"Chromium";v="124", "Microsoft Edge";v="124", "Not-A.Brand";v="99"`)
+	header.Set(HeaderUAMobile, "?0")
+	header.Set(HeaderUAPlatform, `"macOS"`)
+
+	client := BasicParser{}.Parse(header)
+	require.Equal(t, Client{Family: "Microsoft Edge", Version: "124", Platform: "macOS"}, client)
+
+	// The full version list is preferred over the major versions
+	header.Set(HeaderUAFullVersionList, `"Not_A Brand";v="8.0.0.0", "Chromium";v="124.0.6367.91"`)
+	client = BasicParser{}.Parse(header)
+	require.Equal(t, "Chromium", client.Family)
+	require.Equal(t, "124.0.6367.91", client.Version)
+}
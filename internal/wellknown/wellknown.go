@@ -0,0 +1,188 @@
+// Package wellknown serves standard documents below /.well-known/, see
+// RFC 8615
+package wellknown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+)
+
+// Prefix is the path of the well-known URIs
+const Prefix = "/.well-known/"
+
+// Names of the documents served from Config
+const (
+	SecurityTXTName              = "security.txt"
+	ChangePasswordName           = "change-password"
+	OAuthAuthorizationServerName = "oauth-authorization-server"
+	AssetLinksName               = "assetlinks.json"
+)
+
+// Config contains the well-known documents served from configuration,
+// documents left empty are not served
+type Config struct {
+	SecurityTXT SecurityTXT `yaml:"security_txt"`
+
+	// URL of the page changing the password of the user, see the W3C
+	// Well-Known URL for Changing Passwords
+	ChangePassword string `yaml:"change_password"`
+
+	// Authorization server metadata, see RFC 8414. The issuer is
+	// required.
+	OAuthAuthorizationServer map[string]any `yaml:"oauth_authorization_server"`
+
+	// Statements of the Digital Asset Links of the site, e.g. the Android
+	// apps handling its links
+	AssetLinks []AssetLink `yaml:"assetlinks"`
+}
+
+// SecurityTXT is the content of security.txt, see RFC 9116. It is served
+// when it lists contacts.
+type SecurityTXT struct {
+	Contact            []string `yaml:"contact"`
+	Expires            string   `yaml:"expires"` // RFC 3339, required
+	Encryption         []string `yaml:"encryption"`
+	Acknowledgments    []string `yaml:"acknowledgments"`
+	PreferredLanguages []string `yaml:"preferred_languages"`
+	Canonical          []string `yaml:"canonical"`
+	Policy             []string `yaml:"policy"`
+	Hiring             []string `yaml:"hiring"`
+}
+
+// AssetLink is a statement of assetlinks.json
+type AssetLink struct {
+	Relation []string        `yaml:"relation" json:"relation"`
+	Target   AssetLinkTarget `yaml:"target" json:"target"`
+}
+
+// AssetLinkTarget is the app or site a statement is about
+type AssetLinkTarget struct {
+	Namespace              string   `yaml:"namespace" json:"namespace"` // android_app or web
+	PackageName            string   `yaml:"package_name" json:"package_name,omitempty"`
+	SHA256CertFingerprints []string `yaml:"sha256_cert_fingerprints" json:"sha256_cert_fingerprints,omitempty"`
+	Site                   string   `yaml:"site" json:"site,omitempty"`
+}
+
+// Validate validates the configured documents
+func (c *Config) Validate() error {
+	var errs []error
+	if len(c.SecurityTXT.Contact) > 0 {
+		if _, err := time.Parse(time.RFC3339, c.SecurityTXT.Expires); err != nil {
+			errs = append(errs, fmt.Errorf("%s: expires must be an RFC 3339 time: %q", SecurityTXTName, c.SecurityTXT.Expires))
+		}
+	}
+	if len(c.OAuthAuthorizationServer) > 0 {
+		if issuer, _ := c.OAuthAuthorizationServer["issuer"].(string); issuer == "" {
+			errs = append(errs, fmt.Errorf("%s: issuer is required", OAuthAuthorizationServerName))
+		}
+	}
+	for i, link := range c.AssetLinks {
+		if len(link.Relation) == 0 || link.Target.Namespace == "" {
+			errs = append(errs, fmt.Errorf("%s: statement %d needs a relation and a target namespace", AssetLinksName, i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Documents returns the handlers of the configured documents, keyed by
+// their name below Prefix
+//
+// @return: an error if a document is invalid, see Config.Validate
+func (c *Config) Documents() (map[string]types.HandlerFunc, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	documents := make(map[string]types.HandlerFunc)
+	if len(c.SecurityTXT.Contact) > 0 {
+		documents[SecurityTXTName] = SecurityTXTHandler(c.SecurityTXT)
+	}
+	if c.ChangePassword != "" {
+		documents[ChangePasswordName] = RedirectHandler(c.ChangePassword)
+	}
+	if len(c.OAuthAuthorizationServer) > 0 {
+		handler, err := JSONHandler(c.OAuthAuthorizationServer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", OAuthAuthorizationServerName, err)
+		}
+		documents[OAuthAuthorizationServerName] = handler
+	}
+	if len(c.AssetLinks) > 0 {
+		handler, err := JSONHandler(c.AssetLinks)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", AssetLinksName, err)
+		}
+		documents[AssetLinksName] = handler
+	}
+	return documents, nil
+}
+
+// String formats the fields of security.txt, one per line
+func (s SecurityTXT) String() string {
+	var b strings.Builder
+	field := func(name string, values ...string) {
+		for _, value := range values {
+			fmt.Fprintf(&b, "%s: %s\n", name, value)
+		}
+	}
+
+	field("Contact", s.Contact...)
+	field("Expires", s.Expires)
+	field("Encryption", s.Encryption...)
+	field("Acknowledgments", s.Acknowledgments...)
+	if len(s.PreferredLanguages) > 0 {
+		field("Preferred-Languages", strings.Join(s.PreferredLanguages, ", "))
+	}
+	field("Canonical", s.Canonical...)
+	field("Policy", s.Policy...)
+	field("Hiring", s.Hiring...)
+	return b.String()
+}
+
+// SecurityTXTHandler serves security.txt
+func SecurityTXTHandler(doc SecurityTXT) types.HandlerFunc {
+	body := []byte(doc.String())
+	return func(c *types.Context) {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", body)
+	}
+}
+
+// RedirectHandler redirects to the URL, e.g. for change-password
+func RedirectHandler(url string) types.HandlerFunc {
+	return func(c *types.Context) {
+		c.Redirect(http.StatusFound, url)
+	}
+}
+
+// JSONHandler serves a JSON document, encoded once
+//
+// @return: an error if the document cannot be encoded
+func JSONHandler(doc any) (types.HandlerFunc, error) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *types.Context) {
+		c.Data(http.StatusOK, "application/json", body)
+	}, nil
+}
+
+// JSONFunc serves a JSON document built for every request, e.g. metadata
+// depending on the requested host. Errors are answered with 500 Internal
+// Server Error.
+func JSONFunc(build func(c *types.Context) (any, error)) types.HandlerFunc {
+	return func(c *types.Context) {
+		doc, err := build(c)
+		if err != nil {
+			c.Error(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, doc)
+	}
+}
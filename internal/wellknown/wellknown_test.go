@@ -0,0 +1,74 @@
+package wellknown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skjdfhkskjds/go-api/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// serve runs a request through a handler
+func serve(handler types.HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(&types.Context{Request: httptest.NewRequest(http.MethodGet, "/", nil), Writer: w})
+	return w
+}
+
+func TestConfig_Documents(t *testing.T) {
+	config := Config{
+		SecurityTXT: SecurityTXT{
+			Contact:            []string{"mailto:security@example.com", "https://example.com/security"},
+			Expires:            "2030-01-01T00:00:00Z",
+			PreferredLanguages: []string{"en", "fr"},
+			Policy:             []string{"https://example.com/policy"},
+		},
+		ChangePassword:           "https://example.com/account/password",
+		OAuthAuthorizationServer: map[string]any{"issuer": "https://auth.example.com"},
+		AssetLinks: []AssetLink{{
+			Relation: []string{"delegate_permission/common.handle_all_urls"},
+			Target:   AssetLinkTarget{Namespace: "android_app", PackageName: "com.example.app"},
+		}},
+	}
+
+	documents, err := config.Documents()
+	require.NoError(t, err)
+	require.Len(t, documents, 4)
+
+	w := serve(documents[SecurityTXTName])
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "Contact: mailto:security@example.com\n"+
+		"Contact: https://example.com/security\n"+
+		"Expires: 2030-01-01T00:00:00Z\n"+
+		"Preferred-Languages: en, fr\n"+
+		"Policy: https://example.com/policy\n", w.Body.String())
+
+	w = serve(documents[ChangePasswordName])
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "https://example.com/account/password", w.Header().Get("Location"))
+
+	require.JSONEq(t, `{"issuer":"https://auth.example.com"}`, serve(documents[OAuthAuthorizationServerName]).Body.String())
+	require.JSONEq(t, `[{"relation":["delegate_permission/common.handle_all_urls"],`+
+		`"target":{"namespace":"android_app","package_name":"com.example.app"}}]`,
+		serve(documents[AssetLinksName]).Body.String())
+
+	documents, err = (&Config{}).Documents()
+	require.NoError(t, err)
+	require.Empty(t, documents)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	config := Config{
+		SecurityTXT:              SecurityTXT{Contact: []string{"mailto:security@example.com"}, Expires: "next year"},
+		OAuthAuthorizationServer: map[string]any{"token_endpoint": "https://auth.example.com/token"},
+		AssetLinks:               []AssetLink{{}},
+	}
+	err := config.Validate()
+	require.ErrorContains(t, err, "security.txt")
+	require.ErrorContains(t, err, "issuer is required")
+	require.ErrorContains(t, err, "statement 0")
+
+	_, err = config.Documents()
+	require.Error(t, err)
+}